@@ -1,14 +1,32 @@
 package v1alpha1
 
 import (
+	"slices"
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // AuthPolicySpec defines the desired state of AuthPolicy.
 //
-// +kubebuilder:validation:XValidation:message="acceptedResources must be non-empty when using Ansattporten or ID-Porten",rule="!(self.wellKnownURI in ['https://test.idporten.no/.well-known/openid-configuration', 'https://idporten.no/.well-known/openid-configuration', 'https://test.ansattporten.no/.well-known/openid-configuration', 'https://ansattporten.no/.well-known/openid-configuration']) || (has(self.acceptedResources) && self.acceptedResources.size() > 0)"
+// +kubebuilder:validation:XValidation:message="either wellKnownURI, endpoints, identityProviderRef or identityProviders must be set",rule="has(self.wellKnownURI) || has(self.endpoints) || has(self.identityProviderRef) || (has(self.identityProviders) && self.identityProviders.size() > 0)"
+// +kubebuilder:validation:XValidation:message="wellKnownURI and identityProviderRef cannot both be set",rule="!(has(self.wellKnownURI) && has(self.identityProviderRef))"
+// +kubebuilder:validation:XValidation:message="endpoints and identityProviderRef cannot both be set",rule="!(has(self.endpoints) && has(self.identityProviderRef))"
+// +kubebuilder:validation:XValidation:message="endpoints must set issuer, jwksUri and tokenEndpoint unless wellKnownURI is set; jwksUri may be omitted when jwks is set",rule="!has(self.endpoints) || has(self.wellKnownURI) || (has(self.endpoints.issuer) && (has(self.endpoints.jwksUri) || has(self.jwks)) && has(self.endpoints.tokenEndpoint))"
+// +kubebuilder:validation:XValidation:message="jwks requires wellKnownURI or endpoints to be set",rule="!has(self.jwks) || has(self.wellKnownURI) || has(self.endpoints)"
+// +kubebuilder:validation:XValidation:message="acceptedResources must be non-empty when using Ansattporten or ID-Porten",rule="!has(self.wellKnownURI) || !(self.wellKnownURI in ['https://test.idporten.no/.well-known/openid-configuration', 'https://idporten.no/.well-known/openid-configuration', 'https://test.ansattporten.no/.well-known/openid-configuration', 'https://ansattporten.no/.well-known/openid-configuration']) || (has(self.acceptedResources) && self.acceptedResources.size() > 0)"
 // +kubebuilder:validation:XValidation:message="oAuthCredentials must be set when autoLogin is enabled",rule="!has(self.autoLogin) || !self.autoLogin.enabled || has(self.oAuthCredentials)"
-// +kubebuilder:validation:XValidation:message="oAuthCredentials cannot be set unless autoLogin is configured",rule="!has(self.oAuthCredentials) || has(self.autoLogin)"
+// +kubebuilder:validation:XValidation:message="oAuthCredentials cannot be set unless autoLogin, egress or tokenExchange is configured",rule="!has(self.oAuthCredentials) || has(self.autoLogin) || has(self.egress) || has(self.tokenExchange)"
+// +kubebuilder:validation:XValidation:message="oAuthCredentials must be set when egress is enabled",rule="!has(self.egress) || !self.egress.enabled || has(self.oAuthCredentials)"
+// +kubebuilder:validation:XValidation:message="oAuthCredentials must be set when tokenExchange is enabled",rule="!has(self.tokenExchange) || !self.tokenExchange.enabled || has(self.oAuthCredentials)"
+// +kubebuilder:validation:XValidation:message="wellKnownURI, endpoints or identityProviderRef must be set when tokenExchange is enabled",rule="!has(self.tokenExchange) || !self.tokenExchange.enabled || has(self.wellKnownURI) || has(self.endpoints) || has(self.identityProviderRef)"
+// +kubebuilder:validation:XValidation:message="exactly one of selector or targetRefs must be set",rule="has(self.selector) != has(self.targetRefs)"
+// +kubebuilder:validation:XValidation:message="autoLogin requires selector or targetRefs of kind Gateway",rule="!has(self.targetRefs) || !has(self.autoLogin) || !self.autoLogin.enabled || self.targetRefs.all(ref, ref.kind == 'Gateway')"
+// +kubebuilder:validation:XValidation:message="denyResponse requires selector or targetRefs of kind Gateway",rule="!has(self.targetRefs) || self.targetRefs.all(ref, ref.kind == 'Gateway') || (!has(self.denyResponse) && (!has(self.authRules) || self.authRules.all(rule, !has(rule.denyResponse))))"
+// +kubebuilder:validation:XValidation:message="egress and tokenExchange require selector",rule="!has(self.targetRefs) || (!has(self.egress) && !has(self.tokenExchange))"
+// +kubebuilder:validation:XValidation:message="autoLogin cannot be enabled in Audit enforcementMode",rule="!has(self.enforcementMode) || self.enforcementMode != 'Audit' || !has(self.autoLogin) || !self.autoLogin.enabled"
+// +kubebuilder:validation:XValidation:message="autoLogin.scopes must be set unless identityProviderRef is set",rule="!has(self.autoLogin) || has(self.autoLogin.scopes) || has(self.identityProviderRef)"
+// +kubebuilder:validation:XValidation:message="wellKnownURI, endpoints or identityProviderRef must be set when autoLogin is enabled",rule="!has(self.autoLogin) || !self.autoLogin.enabled || has(self.wellKnownURI) || has(self.endpoints) || has(self.identityProviderRef)"
 type AuthPolicySpec struct {
 	// Whether to enable JWT validation.
 	// If enabled, incoming JWTs will be validated against the issuer specified in the app registration and the generated audience.
@@ -16,6 +34,28 @@ type AuthPolicySpec struct {
 	// +kubebuilder:validation:Required
 	Enabled bool `json:"enabled"`
 
+	// EnforcementMode specifies whether requests violating the AuthPolicy are denied (`Enforce`), or only logged by
+	// the sidecar (`Audit`). In `Audit` mode, the same AuthorizationPolicies are generated in Istio dry-run mode,
+	// and every request which would have been denied is logged with the matched policy rule.
+	// Requests with an invalid JWT are still rejected, and autoLogin cannot be enabled.
+	//
+	// +kubebuilder:validation:Enum=Enforce;Audit
+	// +kubebuilder:default=Enforce
+	// +kubebuilder:validation:Optional
+	EnforcementMode EnforcementMode `json:"enforcementMode,omitempty"`
+
+	// FailureMode specifies how the AuthPolicy fails when its configuration turns out to be invalid, or cannot be
+	// resolved, e.g. as the discovery document of the identity provider is unavailable.
+	// In `FailClosed` mode, an invalid configuration denies all requests, and a resolution failure is retried without
+	// updating the generated resources.
+	// In `KeepLastKnownGood` mode, the resources generated from the last configuration which was successfully applied
+	// are kept untouched in both cases, and the AuthPolicy is reported as `Degraded` until its configuration is fixed.
+	//
+	// +kubebuilder:validation:Enum=FailClosed;KeepLastKnownGood
+	// +kubebuilder:default=FailClosed
+	// +kubebuilder:validation:Optional
+	FailureMode FailureMode `json:"failureMode,omitempty"`
+
 	// AutoLogin specifies the required configuration needed to log in users.
 	//
 	// +kubebuilder:validation:Optional
@@ -26,10 +66,59 @@ type AuthPolicySpec struct {
 	// +kubebuilder:validation:Optional
 	OAuthCredentials *OAuthCredentials `json:"oAuthCredentials,omitempty"`
 
+	// Egress specifies outbound destinations which the workload calls with an access token obtained on its behalf.
+	// The token is obtained with the OAuth 2.0 client credentials grant, using the client given by .oAuthCredentials,
+	// and is injected as the `Authorization` header of outbound requests to the destination.
+	//
+	// +kubebuilder:validation:Optional
+	Egress *Egress `json:"egress,omitempty"`
+
+	// TokenExchange specifies outbound destinations for which the token of the user is exchanged for a token scoped
+	// to the destination, following [RFC8693](https://datatracker.ietf.org/doc/html/rfc8693).
+	// The token is exchanged at the identity provider given by .wellKnownURI, using the client given by .oAuthCredentials.
+	//
+	// +kubebuilder:validation:Optional
+	TokenExchange *TokenExchange `json:"tokenExchange,omitempty"`
+
 	// WellKnownURI specifies the URi to the identity provider's discovery document (also known as well-known endpoint).
+	// The identity provider configured by the top-level fields is referred to as `default` in .authRules[].identityProviders.
+	// May be omitted when .identityProviderRef or .endpoints is set, or when all trusted identity providers are listed in .identityProviders.
 	//
-	// +kubebuilder:validation:Required
-	WellKnownURI string `json:"wellKnownURI"`
+	// +kubebuilder:validation:Optional
+	WellKnownURI string `json:"wellKnownURI,omitempty"`
+
+	// Endpoints specifies static endpoints which override those of the discovery document given by .wellKnownURI.
+	// When .wellKnownURI is omitted, no discovery document is fetched, and .endpoints must set the issuer, JWKS URI and
+	// token endpoint of the identity provider. The JWKS URI may be omitted when .jwks is set.
+	//
+	// +kubebuilder:validation:Optional
+	Endpoints *IdentityProviderEndpoints `json:"endpoints,omitempty"`
+
+	// Jwks specifies the JSON Web Key Set used to verify JWTs, either inline or from a ConfigMap/Secret.
+	// When set, the keys are given to the sidecar directly instead of being fetched from the JWKS URI of the identity
+	// provider, and .endpoints may omit the JWKS URI.
+	// The JWKS must be valid JSON containing at least one key using a supported signing algorithm.
+	//
+	// +kubebuilder:validation:Optional
+	Jwks *Jwks `json:"jwks,omitempty"`
+
+	// IdentityProviderRef specifies the name of an IdentityProvider to use instead of .wellKnownURI.
+	// The endpoints of the identity provider are taken from the status of the IdentityProvider,
+	// and its default scopes and login parameters are used for .autoLogin unless set on the AuthPolicy.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Optional
+	IdentityProviderRef *string `json:"identityProviderRef,omitempty"`
+
+	// IdentityProviders specifies additional trusted identity providers.
+	// A JWT issued by any of the listed identity providers, or by the identity provider given by .wellKnownURI, is accepted.
+	// Each identity provider has its own set of allowed audiences, accepted resources and claim-to-header mappings.
+	//
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:Optional
+	IdentityProviders []TrustedIdentityProvider `json:"identityProviders,omitempty"`
 
 	// AllowedAudiences defines the allowed audience (`aud`) values in the JWT.
 	// At least one of the listed audience values must be present in the token's `aud` claim for validation to succeed.
@@ -51,6 +140,34 @@ type AuthPolicySpec struct {
 	// +kubebuilder:validation:Optional
 	OutputClaimToHeaders *[]ClaimToHeader `json:"outputClaimToHeaders,omitempty"`
 
+	// FromHeaders specifies additional HTTP headers to extract the JWT from, e.g. `X-Api-Token`.
+	// JWTs are always extracted from the `Authorization` header with the `Bearer ` prefix, and from the `access_token`
+	// query parameter, in addition to any specified locations.
+	//
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:Optional
+	FromHeaders []JWTHeader `json:"fromHeaders,omitempty"`
+
+	// FromParams specifies additional query parameters to extract the JWT from.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[a-zA-Z0-9-._~]+$`
+	// +kubebuilder:validation:items:MaxLength=64
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:Optional
+	FromParams []string `json:"fromParams,omitempty"`
+
+	// FromCookies specifies cookies to extract the JWT from.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[a-zA-Z0-9-._~]+$`
+	// +kubebuilder:validation:items:MaxLength=64
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:Optional
+	FromCookies []string `json:"fromCookies,omitempty"`
+
 	// AcceptedResources specifies resource indicators used to request an audience limited access token following [RFC8707](https://datatracker.ietf.org/doc/html/rfc8707).
 	// It defines accepted audience resource indicators in the JWT token.
 	//
@@ -88,9 +205,96 @@ type AuthPolicySpec struct {
 	// +kubebuilder:validation:Optional
 	IgnoreAuthRules *[]RequestMatcher `json:"ignoreAuthRules,omitempty"`
 
+	// DenyResponse specifies the response sent when a request is denied for lacking a valid JWT or sufficient claims,
+	// instead of the plain responses of the sidecar.
+	// May be overridden for requests matching an auth rule by .authRules[].denyResponse.
+	//
+	// +kubebuilder:validation:Optional
+	DenyResponse *DenyResponse `json:"denyResponse,omitempty"`
+
 	// The Selector specifies which workload the defined auth policy should be applied to.
+	// Exactly one of .selector and .targetRefs must be set.
+	//
+	// +kubebuilder:validation:Optional
+	Selector *WorkloadSelector `json:"selector,omitempty"`
+
+	// TargetRefs specifies the resources the defined auth policy should be applied to, as an alternative to .selector.
+	// Use targetRefs for workloads without a sidecar, e.g. to authenticate at an ingress gateway,
+	// or at the waypoint of a Service in ambient mode.
+	//
+	// .autoLogin and .denyResponse require all targetRefs to be of kind `Gateway`,
+	// while .egress and .tokenExchange require .selector.
+	//
+	// +listType=atomic
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:Optional
+	TargetRefs []PolicyTargetReference `json:"targetRefs,omitempty"`
+}
+
+// TrustedIdentityProvider defines an additional trusted identity provider.
+//
+// +kubebuilder:validation:XValidation:message="either wellKnownURI, endpoints or identityProviderRef must be set",rule="has(self.wellKnownURI) || has(self.endpoints) || has(self.identityProviderRef)"
+// +kubebuilder:validation:XValidation:message="identityProviderRef cannot be set together with wellKnownURI or endpoints",rule="!has(self.identityProviderRef) || (!has(self.wellKnownURI) && !has(self.endpoints))"
+// +kubebuilder:validation:XValidation:message="endpoints must set issuer, jwksUri and tokenEndpoint unless wellKnownURI is set; jwksUri may be omitted when jwks is set",rule="!has(self.endpoints) || has(self.wellKnownURI) || (has(self.endpoints.issuer) && (has(self.endpoints.jwksUri) || has(self.jwks)) && has(self.endpoints.tokenEndpoint))"
+// +kubebuilder:validation:XValidation:message="jwks requires wellKnownURI or endpoints to be set",rule="!has(self.jwks) || has(self.wellKnownURI) || has(self.endpoints)"
+// +kubebuilder:validation:XValidation:message="acceptedResources must be non-empty when using Ansattporten or ID-Porten",rule="!has(self.wellKnownURI) || !(self.wellKnownURI in ['https://test.idporten.no/.well-known/openid-configuration', 'https://idporten.no/.well-known/openid-configuration', 'https://test.ansattporten.no/.well-known/openid-configuration', 'https://ansattporten.no/.well-known/openid-configuration']) || (has(self.acceptedResources) && self.acceptedResources.size() > 0)"
+// +kubebuilder:object:generate=true
+type TrustedIdentityProvider struct {
+	// Name uniquely identifies the identity provider within the AuthPolicy.
+	// The name `default` is reserved for the identity provider given by .wellKnownURI.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:XValidation:message="name 'default' is reserved",rule="self != 'default'"
 	// +kubebuilder:validation:Required
-	Selector WorkloadSelector `json:"selector"`
+	Name string `json:"name"`
+
+	// WellKnownURI specifies the URI to the identity provider's discovery document (also known as well-known endpoint).
+	// Either .wellKnownURI, .endpoints or .identityProviderRef must be set.
+	//
+	// +kubebuilder:validation:Optional
+	WellKnownURI string `json:"wellKnownURI,omitempty"`
+
+	// Endpoints specifies static endpoints of the identity provider. See .spec.endpoints for details.
+	//
+	// +kubebuilder:validation:Optional
+	Endpoints *IdentityProviderEndpoints `json:"endpoints,omitempty"`
+
+	// Jwks specifies the JSON Web Key Set of the identity provider. See .spec.jwks for details.
+	//
+	// +kubebuilder:validation:Optional
+	Jwks *Jwks `json:"jwks,omitempty"`
+
+	// IdentityProviderRef specifies the name of an IdentityProvider to use instead of .wellKnownURI and .endpoints.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Optional
+	IdentityProviderRef *string `json:"identityProviderRef,omitempty"`
+
+	// AllowedAudiences defines the allowed audience (`aud`) values in JWTs issued by this identity provider.
+	//
+	// +kubebuilder:validation:Optional
+	AllowedAudiences []AllowedAudience `json:"allowedAudiences,omitempty"`
+
+	// AcceptedResources defines accepted audience resource indicators in JWTs issued by this identity provider.
+	// See .spec.acceptedResources for details.
+	//
+	// +listType=set
+	// +kubebuilder:validation:Items.Pattern=`^(https?):\/\/[^\s\/$.?#].[^\s]*$`
+	// +kubebuilder:validation:Optional
+	AcceptedResources *[]string `json:"acceptedResources,omitempty"`
+
+	// If set to `true`, the original token issued by this identity provider will be kept for the upstream request. Defaults to `true`.
+	//
+	// +kubebuilder:validation:Optional
+	ForwardJwt *bool `json:"forwardJwt,omitempty"`
+
+	// OutputClaimsToHeaders specifies a list of operations to copy claims from a successfully verified token issued by this identity provider to HTTP headers.
+	//
+	// +kubebuilder:validation:Optional
+	OutputClaimToHeaders *[]ClaimToHeader `json:"outputClaimToHeaders,omitempty"`
 }
 
 // AllowedAudience defines an audience that is validated against the `aud` claim in the JWT.
@@ -112,6 +316,24 @@ type AllowedAudience struct {
 	ValueFrom *ValueFrom `json:"valueFrom,omitempty"`
 }
 
+// Jwks defines a JSON Web Key Set, given either as a static value or retrieved from a kubernetes resource.
+//
+// +kubebuilder:validation:XValidation:message="either 'value' or 'valueFrom' must be set",rule="has(self.value) || has(self.valueFrom)"
+// +kubebuilder:validation:XValidation:message="jwks cannot be defined from both 'value' and 'valueFrom'",rule="!(has(self.value) && has(self.valueFrom))"
+// +kubebuilder:validation:XValidation:message="field 'value' cannot be empty string",rule="!has(self.value) || size(self.value) > 0"
+// +kubebuilder:object:generate=true
+type Jwks struct {
+	// Value specifies the JWKS as a JSON document.
+	//
+	// +kubebuilder:validation:Optional
+	Value *string `json:"value,omitempty"`
+
+	// ValueFrom specifies a reference to a kubernetes resource to retrieve the JWKS from.
+	//
+	// +kubebuilder:validation:Optional
+	ValueFrom *ValueFrom `json:"valueFrom,omitempty"`
+}
+
 // ValueFrom specifies a reference to a kubernetes resource to retrieve a value from.
 //
 // +kubebuilder:validation:XValidation:message="either 'configMapKeyRef' or 'secretKeyRef' must be set",rule="has(self.configMapKeyRef) || has(self.secretKeyRef)"
@@ -187,9 +409,10 @@ type AutoLogin struct {
 	PostLogoutRedirectURI *string `json:"postLogoutRedirectUri,omitempty"`
 
 	// Scopes specifies the OAuth2 scopes used during authorization code flow.
+	// Required unless default scopes are given by the IdentityProvider referenced by .identityProviderRef.
 	//
-	// +kubebuilder:validation:Required
-	Scopes []string `json:"scopes"`
+	// +kubebuilder:validation:Optional
+	Scopes []string `json:"scopes,omitempty"`
 
 	// LoginParams specifies a map of query parameters and their values which will be added in the authorize request made towards the configured identity provider.
 	// Keys must be valid OAuth parameter names (letters, digits, and underscores).
@@ -220,6 +443,149 @@ type OAuthCredentials struct {
 	ClientIDKey string `json:"clientIDKey"`
 }
 
+// Egress specifies outbound destinations which the workload calls with an access token obtained on its behalf.
+//
+// +kubebuilder:object:generate=true
+type Egress struct {
+	// Whether to inject access tokens into outbound requests.
+	//
+	// +kubebuilder:validation:Required
+	Enabled bool `json:"enabled"`
+
+	// Destinations specifies the outbound destinations to inject access tokens for.
+	//
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:Required
+	Destinations []EgressDestination `json:"destinations"`
+}
+
+// EgressDestination specifies a set of outbound hosts sharing the access token to inject.
+//
+// +kubebuilder:object:generate=true
+type EgressDestination struct {
+	// Name uniquely identifies the destination within the AuthPolicy.
+	//
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Hosts specifies the hostnames of the destination, as known to the service mesh, e.g. through a ServiceEntry.
+	// The sidecar can only inject the token into plain HTTP requests, so TLS towards external hosts must be
+	// originated by the sidecar.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[a-zA-Z0-9]([-a-zA-Z0-9.]*[a-zA-Z0-9])?$`
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:Required
+	Hosts []string `json:"hosts"`
+
+	// Port specifies the port of the destination hosts. If omitted, requests to all ports are matched.
+	//
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:validation:Optional
+	Port *int32 `json:"port,omitempty"`
+
+	// Scopes specifies the OAuth2 scopes requested for the access token, e.g. `api://my-api/.default`.
+	//
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:Required
+	Scopes []string `json:"scopes"`
+
+	// IdentityProvider specifies the name of the identity provider to obtain the access token from.
+	// Must be one of the trusted identity providers. If omitted, the identity provider given by .wellKnownURI is used.
+	//
+	// +kubebuilder:validation:Optional
+	IdentityProvider *string `json:"identityProvider,omitempty"`
+}
+
+// TokenExchange specifies outbound destinations for which the token of the user is exchanged.
+//
+// +kubebuilder:object:generate=true
+type TokenExchange struct {
+	// Whether to exchange the token of outbound requests.
+	//
+	// +kubebuilder:validation:Required
+	Enabled bool `json:"enabled"`
+
+	// Destinations specifies the outbound destinations to exchange tokens for.
+	//
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:Required
+	Destinations []TokenExchangeDestination `json:"destinations"`
+}
+
+// TokenExchangeDestination specifies a set of outbound hosts sharing the audience of the exchanged token.
+//
+// +kubebuilder:object:generate=true
+type TokenExchangeDestination struct {
+	// Name uniquely identifies the destination within the AuthPolicy.
+	//
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Hosts specifies the hostnames of the destination, as used in the Host header of outbound requests.
+	// The sidecar can only exchange the token of plain HTTP requests, so TLS towards external hosts must be
+	// originated by the sidecar.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[a-zA-Z0-9]([-a-zA-Z0-9.]*[a-zA-Z0-9])?$`
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:Required
+	Hosts []string `json:"hosts"`
+
+	// Audience specifies the audience of the exchanged token, i.e. the logical name of the destination API.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Required
+	Audience string `json:"audience"`
+
+	// Scopes specifies the OAuth2 scopes requested for the exchanged token.
+	//
+	// +listType=set
+	// +kubebuilder:validation:Optional
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// PolicyTargetReference refers to a resource in the namespace of the AuthPolicy, which the AuthPolicy applies to.
+//
+// +kubebuilder:object:generate=true
+type PolicyTargetReference struct {
+	// Kind specifies the kind of the resource, either a Kubernetes Gateway API `Gateway`, including waypoints,
+	// or a `Service`.
+	//
+	// +kubebuilder:validation:Enum=Gateway;Service
+	// +kubebuilder:validation:Required
+	Kind TargetRefKind `json:"kind"`
+
+	// Name specifies the name of the resource.
+	//
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+// TargetRefKind is the kind of resource an AuthPolicy may apply to.
+type TargetRefKind string
+
+const (
+	TargetRefKindGateway TargetRefKind = "Gateway"
+	TargetRefKindService TargetRefKind = "Service"
+)
+
 type WorkloadSelector struct {
 	// One or more labels that indicate a specific set of pods/VMs
 	// on which a policy should be applied. The scope of label search is restricted to
@@ -251,18 +617,55 @@ type ClaimToHeader struct {
 	Claim string `json:"claim"`
 }
 
+// JWTHeader specifies an HTTP header to extract the JWT from.
+//
+// +kubebuilder:object:generate=true
+type JWTHeader struct {
+	// Name specifies the name of the HTTP header.
+	//
+	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9-]+$"
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Prefix specifies a prefix which is stripped from the header value before the JWT is extracted, e.g. `Bearer `.
+	//
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Optional
+	Prefix string `json:"prefix,omitempty"`
+}
+
 // BaselineAuth defines additional JWT authentication, beyond standard JWT verification.
 //
 // +kubebuilder:object:generate=true
-// +kubebuilder:validation:XValidation:message="claims must be a non-empty list",rule="has(self.claims) && self.claims.size() > 0"
+// +kubebuilder:validation:XValidation:message="claims must be a non-empty list unless anyOf is set",rule="(has(self.claims) && self.claims.size() > 0) || (has(self.anyOf) && self.anyOf.size() > 0)"
 type BaselineAuth struct {
 	// Claims defines conditions based on JWT claims that must be met.
 	// These conditions are applied to all paths and methods not explicitly ignored in .ignoreAuthRules,
 	// including those covered by other specified AuthRules.
 	//
 	// The request is permitted if all the specified conditions are satisfied.
+	// +kubebuilder:validation:Optional
+	Claims []Condition `json:"claims,omitempty"`
+
+	// AnyOf defines groups of conditions based on JWT claims, where the conditions of at least one group must be met.
+	// The groups are applied in addition to .claims, to the same paths and methods.
+	//
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:Optional
+	AnyOf []ConditionGroup `json:"anyOf,omitempty"`
+}
+
+// ConditionGroup defines a group of conditions based on JWT claims that must all be met.
+//
+// +kubebuilder:object:generate=true
+type ConditionGroup struct {
+	// AllOf defines conditions based on JWT claims that must all be met for the group to be satisfied.
+	//
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=8
 	// +kubebuilder:validation:Required
-	Claims []Condition `json:"claims"`
+	AllOf []Condition `json:"allOf"`
 }
 
 // RequestAuthRule defines a rule for controlling access to HTTP requests using JWT authentication.
@@ -277,11 +680,139 @@ type RequestAuthRule struct {
 	// +kubebuilder:validation:Optional
 	When *[]Condition `json:"when,omitempty"`
 
+	// AnyOf defines groups of conditions based on JWT claims, where the conditions of at least one group must be met.
+	//
+	// The request is permitted if all the conditions in .when are satisfied and
+	// all the conditions of at least one of the groups are satisfied (OR logic between groups).
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:Optional
+	AnyOf *[]ConditionGroup `json:"anyOf,omitempty"`
+
+	// From defines sources, authenticated by mesh mTLS or identified by IP, which are permitted without a JWT.
+	//
+	// The request is permitted if it originates from any of the sources (OR logic between sources),
+	// or if it carries a JWT meeting the conditions of the rule.
+	// +kubebuilder:validation:MaxItems=4
+	// +kubebuilder:validation:Optional
+	From *[]Source `json:"from,omitempty"`
+
+	// IdentityProviders restricts the rule to JWTs issued by the named identity providers.
+	// Use `default` to refer to the identity provider given by .wellKnownURI.
+	// If omitted, JWTs issued by any trusted identity provider are accepted.
+	//
+	// +listType=set
+	// +kubebuilder:validation:Optional
+	IdentityProviders []string `json:"identityProviders,omitempty"`
+
 	// DenyRedirect specifies whether a denied request should trigger auto-login (if configured) or not when it is denied due to missing or invalid authentication.
 	// Defaults to false, meaning auto-login will be triggered (if configured).
 	//
 	// +kubebuilder:validation:Optional
 	DenyRedirect *bool `json:"denyRedirect,omitempty"`
+
+	// DenyResponse specifies the response sent when a request matching the auth rule is denied.
+	// Overrides .denyResponse of the AuthPolicy.
+	//
+	// +kubebuilder:validation:Optional
+	DenyResponse *DenyResponse `json:"denyResponse,omitempty"`
+}
+
+// DenyResponse specifies the response sent when a request is denied.
+// The response carries a `WWW-Authenticate` header following [RFC6750](https://datatracker.ietf.org/doc/html/rfc6750),
+// and an `application/problem+json` body following [RFC9457](https://datatracker.ietf.org/doc/html/rfc9457).
+// Requests without a token are answered with 401, while requests with a token lacking the required claims are
+// answered with 403 and the `insufficient_scope` error.
+//
+// +kubebuilder:object:generate=true
+type DenyResponse struct {
+	// Realm specifies the `realm` attribute of the `WWW-Authenticate` header.
+	//
+	// +kubebuilder:validation:Pattern=`^[ !#-\[\]-~]*$`
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Optional
+	Realm *string `json:"realm,omitempty"`
+
+	// Scopes specifies the scopes required to access the resource, given in the `scope` attribute of the
+	// `WWW-Authenticate` header.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[!#-\[\]-~]+$`
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:Optional
+	Scopes []string `json:"scopes,omitempty"`
+
+	// ResourceMetadata specifies the URL of the OAuth 2.0 protected resource metadata of the resource, given in the
+	// `resource_metadata` attribute of the `WWW-Authenticate` header following
+	// [RFC9728](https://datatracker.ietf.org/doc/html/rfc9728).
+	//
+	// +kubebuilder:validation:Pattern=`^https?://[!#-\[\]-~]+$`
+	// +kubebuilder:validation:MaxLength=2048
+	// +kubebuilder:validation:Optional
+	ResourceMetadata *string `json:"resourceMetadata,omitempty"`
+
+	// ErrorDescription specifies the `error_description` attribute of the `WWW-Authenticate` header and the `detail`
+	// of the problem. If omitted, a description of the reason for denying the request is used.
+	//
+	// +kubebuilder:validation:Pattern=`^[ !#-\[\]-~]*$`
+	// +kubebuilder:validation:MaxLength=512
+	// +kubebuilder:validation:Optional
+	ErrorDescription *string `json:"errorDescription,omitempty"`
+
+	// ProblemType specifies the `type` URI of the problem. Defaults to `about:blank`.
+	//
+	// +kubebuilder:validation:Pattern=`^[!#-\[\]-~]+$`
+	// +kubebuilder:validation:MaxLength=2048
+	// +kubebuilder:validation:Optional
+	ProblemType *string `json:"problemType,omitempty"`
+}
+
+// Source defines the origin of a request. All the specified fields must match for a request to originate
+// from the source (AND logic between fields).
+//
+// +kubebuilder:validation:XValidation:message="at least one of 'principals', 'serviceAccounts', 'namespaces' or 'ipBlocks' must be set",rule="has(self.principals) || has(self.serviceAccounts) || has(self.namespaces) || has(self.ipBlocks)"
+// +kubebuilder:validation:XValidation:message="'serviceAccounts' cannot be combined with 'principals'",rule="!(has(self.serviceAccounts) && has(self.principals))"
+// +kubebuilder:object:generate=true
+type Source struct {
+	// Principals specifies the SPIFFE identities of the peer, as established by mesh mTLS,
+	// e.g. `cluster.local/ns/batch/sa/report-job`.
+	// A principal starting or ending with `*` matches principals with the given suffix or prefix.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^(\*|\*?[^*]+|[^*]+\*)$`
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:Optional
+	Principals []string `json:"principals,omitempty"`
+
+	// ServiceAccounts specifies the service accounts of the peer, as established by mesh mTLS,
+	// in the format `<namespace>/<name>`. Service accounts are matched regardless of trust domain.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?/[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:Optional
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+
+	// Namespaces specifies the namespaces of the peer, as established by mesh mTLS.
+	// A namespace starting or ending with `*` matches namespaces with the given suffix or prefix.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^(\*|\*?[^*]+|[^*]+\*)$`
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:Optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// IPBlocks specifies IP addresses or CIDR blocks, e.g. `10.0.0.0/16`, of the peer.
+	// Note that the peer address is the address of the immediate downstream connection.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^[0-9a-fA-F:.]+(/[0-9]{1,3})?$`
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:Optional
+	IPBlocks []string `json:"ipBlocks,omitempty"`
 }
 
 // RequestMatcher defines paths and methods to match incoming HTTP requests.
@@ -315,15 +846,87 @@ type RequestMatcher struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MaxItems=9
 	Methods []string `json:"methods,omitempty"`
+
+	// Hosts specifies a set of hosts, as given by the Host header of the request, that this rule applies to.
+	// A host starting with `*` matches hosts with the given suffix, and a host ending with `*` matches hosts with the given prefix.
+	// Hosts are matched case-insensitively. Note that the Host header may include a port, e.g. `example.com:8080`.
+	// If omitted, all hosts are matched.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^(\*|\*?[^*]+|[^*]+\*)$`
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:Optional
+	Hosts []string `json:"hosts,omitempty"`
+
+	// NotHosts specifies a set of hosts that this rule does not apply to, using the same format as .hosts.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^(\*|\*?[^*]+|[^*]+\*)$`
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:Optional
+	NotHosts []string `json:"notHosts,omitempty"`
+
+	// Headers specifies conditions on HTTP request headers that must all be met for this rule to apply.
+	//
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:Optional
+	Headers []HeaderMatcher `json:"headers,omitempty"`
+}
+
+// HeaderMatcher defines a condition on an HTTP request header.
+//
+// A value starting with `*` matches header values with the given suffix,
+// a value ending with `*` matches header values with the given prefix,
+// and the value `*` alone matches any non-empty header value.
+//
+// +kubebuilder:validation:XValidation:message="at least one of 'values' or 'notValues' must be set",rule="has(self.values) || has(self.notValues)"
+// +kubebuilder:validation:XValidation:message="'values' must be non-empty when set",rule="!has(self.values) || size(self.values) > 0"
+// +kubebuilder:validation:XValidation:message="'notValues' must be non-empty when set",rule="!has(self.notValues) || size(self.notValues) > 0"
+// +kubebuilder:object:generate=true
+type HeaderMatcher struct {
+	// Name specifies the name of the HTTP header. Header names are matched case-insensitively.
+	//
+	// +kubebuilder:validation:Pattern="^[a-zA-Z0-9-]+$"
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Values specifies a list of values, of which the header must match one.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^(\*|\*?[^*]+|[^*]+\*)$`
+	// +kubebuilder:validation:Optional
+	Values []string `json:"values,omitempty"`
+
+	// NotValues specifies a list of values which the header must not match.
+	// A request without the header meets this condition.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^(\*|\*?[^*]+|[^*]+\*)$`
+	// +kubebuilder:validation:Optional
+	NotValues []string `json:"notValues,omitempty"`
 }
 
 // Condition represents a rule that evaluates JWT claims to determine access control.
 //
 // This type allows defining conditions that check whether a specific claim in
-// the JWT token contains one of the expected values.
+// the JWT token contains one of the expected values, does not contain any of the
+// given values, or is present at all.
+//
+// A value starting with `*` matches claim values with the given suffix,
+// a value ending with `*` matches claim values with the given prefix,
+// and the value `*` alone matches any non-empty claim value.
 //
 // If multiple conditions are specified, all must be met (AND logic) for the request to be allowed.
+// Likewise, if multiple operators are set on the same condition, all must be met.
 //
+// +kubebuilder:validation:XValidation:message="at least one of 'values', 'notValues' or 'present' must be set",rule="has(self.values) || has(self.notValues) || has(self.present)"
+// +kubebuilder:validation:XValidation:message="'values' must be non-empty when set",rule="!has(self.values) || size(self.values) > 0"
+// +kubebuilder:validation:XValidation:message="'notValues' must be non-empty when set",rule="!has(self.notValues) || size(self.notValues) > 0"
+// +kubebuilder:validation:XValidation:message="'present' cannot be combined with 'values'",rule="!has(self.present) || !has(self.values)"
+// +kubebuilder:validation:XValidation:message="'present: false' cannot be combined with 'notValues'",rule="!has(self.present) || self.present || !has(self.notValues)"
 // +kubebuilder:object:generate=true
 type Condition struct {
 	// Claim specifies the name of the JWT claim to check.
@@ -335,8 +938,23 @@ type Condition struct {
 	// If the claim in the JWT contains any of these values (OR logic), the condition is met.
 	//
 	// +listType=set
-	// +kubebuilder:validation:Required
-	Values []string `json:"values"`
+	// +kubebuilder:validation:items:Pattern=`^(\*|\*?[^*]+|[^*]+\*)$`
+	// +kubebuilder:validation:Optional
+	Values []string `json:"values,omitempty"`
+
+	// NotValues specifies a list of disallowed values for the claim.
+	// If the claim in the JWT contains any of these values, the condition is not met.
+	// A JWT without the claim meets the condition.
+	//
+	// +listType=set
+	// +kubebuilder:validation:items:Pattern=`^(\*|\*?[^*]+|[^*]+\*)$`
+	// +kubebuilder:validation:Optional
+	NotValues []string `json:"notValues,omitempty"`
+
+	// Present specifies whether the claim must be present (`true`) or absent (`false`) in the JWT.
+	//
+	// +kubebuilder:validation:Optional
+	Present *bool `json:"present,omitempty"`
 }
 
 // AuthPolicyStatus defines the observed state of AuthPolicy.
@@ -346,20 +964,100 @@ type AuthPolicyStatus struct {
 	Phase              Phase              `json:"phase,omitempty"`
 	Message            string             `json:"message,omitempty"`
 	Ready              bool               `json:"ready"`
+
+	// EnforcementMode shows whether the AuthPolicy is enforced, or only audited.
+	EnforcementMode EnforcementMode `json:"enforcementMode,omitempty"`
+
+	// ClusterAuthPolicies lists the ClusterAuthPolicies merged into the AuthPolicy.
+	ClusterAuthPolicies []string `json:"clusterAuthPolicies,omitempty"`
+
+	// EffectiveRules shows the rules enforced after merging ClusterAuthPolicies into the AuthPolicy.
+	// Only set when at least one ClusterAuthPolicy applies.
+	EffectiveRules *EffectiveRules `json:"effectiveRules,omitempty"`
+
+	// Conflicts lists rules that were dropped or overridden while merging ClusterAuthPolicies into the AuthPolicy.
+	Conflicts []RuleConflict `json:"conflicts,omitempty"`
+
+	// LastKnownGood describes the configuration the generated resources were last successfully applied from.
+	// Only set in `KeepLastKnownGood` failure mode.
+	LastKnownGood *LastKnownGood `json:"lastKnownGood,omitempty"`
+}
+
+// LastKnownGood describes the resolved configuration of an AuthPolicy which was last successfully applied, and which
+// the generated resources are kept at while the AuthPolicy is degraded.
+//
+// +kubebuilder:object:generate=true
+type LastKnownGood struct {
+	// ObservedGeneration is the generation of the AuthPolicy which was applied.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Issuer is the issuer of the identity provider given by .spec.wellKnownURI, .spec.endpoints or
+	// .spec.identityProviderRef.
+	Issuer string `json:"issuer,omitempty"`
+
+	// JwksURI is the URI of the JWKS of the identity provider.
+	JwksURI string `json:"jwksUri,omitempty"`
+
+	// Audiences are the resolved audiences accepted from the identity provider.
+	Audiences []string `json:"audiences,omitempty"`
+
+	// IdentityProviders lists the names of the trusted identity providers given by .spec.identityProviders.
+	IdentityProviders []string `json:"identityProviders,omitempty"`
+
+	// AppliedAt is the time the configuration was applied.
+	AppliedAt metav1.Time `json:"appliedAt"`
+}
+
+// EffectiveRules holds the rules of an AuthPolicy after merging ClusterAuthPolicies into it.
+//
+// +kubebuilder:object:generate=true
+type EffectiveRules struct {
+	BaselineAuth    *BaselineAuth     `json:"baselineAuth,omitempty"`
+	AuthRules       []RequestAuthRule `json:"authRules,omitempty"`
+	IgnoreAuthRules []RequestMatcher  `json:"ignoreAuthRules,omitempty"`
 }
 
 type Phase string
 
+// EnforcementMode specifies whether an AuthPolicy denies requests, or only logs requests which would have been denied.
+type EnforcementMode string
+
 const (
-	PhasePending Phase = "Pending"
-	PhaseReady   Phase = "Ready"
-	PhaseFailed  Phase = "Failed"
-	PhaseInvalid Phase = "Invalid"
+	EnforcementModeEnforce EnforcementMode = "Enforce"
+	EnforcementModeAudit   EnforcementMode = "Audit"
+)
+
+// FailureMode specifies how an AuthPolicy fails when its configuration is invalid, or cannot be resolved.
+type FailureMode string
+
+const (
+	FailureModeFailClosed        FailureMode = "FailClosed"
+	FailureModeKeepLastKnownGood FailureMode = "KeepLastKnownGood"
+)
+
+// DefaultIdentityProviderName is the name used to refer to the identity provider given by .spec.wellKnownURI.
+const DefaultIdentityProviderName = "default"
+
+// The default locations JWTs are extracted from, which are always used in addition to any custom token locations.
+const (
+	DefaultTokenHeaderName   = "Authorization"
+	DefaultTokenHeaderPrefix = "Bearer "
+	DefaultTokenParamName    = "access_token"
+)
+
+const (
+	PhasePending  Phase = "Pending"
+	PhaseReady    Phase = "Ready"
+	PhaseFailed   Phase = "Failed"
+	PhaseInvalid  Phase = "Invalid"
+	PhaseConflict Phase = "Conflict"
+	PhaseDegraded Phase = "Degraded"
 )
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.status.enforcementMode`
 
 // AuthPolicy is the Schema for the authpolicies API.
 type AuthPolicy struct {
@@ -402,6 +1100,143 @@ func (ap *AuthPolicy) InitializeStatus() {
 	ap.Status.Phase = PhasePending
 }
 
+// HasCustomTokenLocations reports whether the spec defines locations to extract JWTs from, beyond the default
+// `Authorization: Bearer` header and `access_token` query parameter.
+func (ap *AuthPolicy) HasCustomTokenLocations() bool {
+	return len(ap.Spec.FromHeaders) > 0 || len(ap.Spec.FromParams) > 0 || len(ap.Spec.FromCookies) > 0
+}
+
+// GetTokenHeaders returns the default `Authorization: Bearer` header followed by any additional headers to extract
+// JWTs from.
+func (ap *AuthPolicy) GetTokenHeaders() []JWTHeader {
+	defaultHeader := JWTHeader{Name: DefaultTokenHeaderName, Prefix: DefaultTokenHeaderPrefix}
+	tokenHeaders := []JWTHeader{defaultHeader}
+	for _, header := range ap.Spec.FromHeaders {
+		if strings.EqualFold(header.Name, defaultHeader.Name) && header.Prefix == defaultHeader.Prefix {
+			continue
+		}
+		tokenHeaders = append(tokenHeaders, header)
+	}
+	return tokenHeaders
+}
+
+// GetTokenParams returns the default `access_token` query parameter followed by any additional query parameters
+// to extract JWTs from.
+func (ap *AuthPolicy) GetTokenParams() []string {
+	tokenParams := []string{DefaultTokenParamName}
+	for _, param := range ap.Spec.FromParams {
+		if !slices.Contains(tokenParams, param) {
+			tokenParams = append(tokenParams, param)
+		}
+	}
+	return tokenParams
+}
+
+// HasDefaultIdentityProvider reports whether the top-level fields of the spec define a trusted identity provider.
+// This is the case unless the AuthPolicy relies solely on .spec.identityProviders.
+func (ap *AuthPolicy) HasDefaultIdentityProvider() bool {
+	return ap.Spec.WellKnownURI != "" || ap.Spec.Endpoints != nil || ap.Spec.IdentityProviderRef != nil ||
+		len(ap.Spec.IdentityProviders) == 0
+}
+
+// GetIdentityProviderRefs returns the names of all IdentityProviders referenced by the AuthPolicy, without duplicates.
+func (ap *AuthPolicy) GetIdentityProviderRefs() []string {
+	var identityProviderRefs []string
+	if ap.Spec.IdentityProviderRef != nil {
+		identityProviderRefs = append(identityProviderRefs, *ap.Spec.IdentityProviderRef)
+	}
+	for _, identityProvider := range ap.Spec.IdentityProviders {
+		if identityProvider.IdentityProviderRef != nil &&
+			!slices.Contains(identityProviderRefs, *identityProvider.IdentityProviderRef) {
+			identityProviderRefs = append(identityProviderRefs, *identityProvider.IdentityProviderRef)
+		}
+	}
+	return identityProviderRefs
+}
+
+// GetSelectorMatchLabels returns the labels of the workloads selected by .selector, or nil when .targetRefs is used.
+func (ap *AuthPolicy) GetSelectorMatchLabels() map[string]string {
+	if ap.Spec.Selector == nil {
+		return nil
+	}
+	return ap.Spec.Selector.MatchLabels
+}
+
+// TargetsGateways reports whether the AuthPolicy applies to gateways given by .targetRefs, rather than to sidecars.
+func (ap *AuthPolicy) TargetsGateways() bool {
+	return len(ap.Spec.TargetRefs) > 0 && !slices.ContainsFunc(ap.Spec.TargetRefs, func(ref PolicyTargetReference) bool {
+		return ref.Kind != TargetRefKindGateway
+	})
+}
+
+// GetEnforcementMode returns the enforcement mode of the AuthPolicy, defaulting to Enforce.
+func (ap *AuthPolicy) GetEnforcementMode() EnforcementMode {
+	if ap.Spec.EnforcementMode == "" {
+		return EnforcementModeEnforce
+	}
+	return ap.Spec.EnforcementMode
+}
+
+// IsAuditMode reports whether requests violating the AuthPolicy are only logged instead of denied.
+func (ap *AuthPolicy) IsAuditMode() bool {
+	return ap.GetEnforcementMode() == EnforcementModeAudit
+}
+
+// GetFailureMode returns the failure mode of the AuthPolicy, defaulting to FailClosed.
+func (ap *AuthPolicy) GetFailureMode() FailureMode {
+	if ap.Spec.FailureMode == "" {
+		return FailureModeFailClosed
+	}
+	return ap.Spec.FailureMode
+}
+
+// KeepsLastKnownGood reports whether the resources generated from the last successfully applied configuration are
+// kept when the configuration of the AuthPolicy is invalid, or cannot be resolved.
+func (ap *AuthPolicy) KeepsLastKnownGood() bool {
+	return ap.GetFailureMode() == FailureModeKeepLastKnownGood
+}
+
+// IsEgressEnabled reports whether access tokens are to be injected into outbound requests.
+func (ap *AuthPolicy) IsEgressEnabled() bool {
+	return ap.Spec.Egress != nil && ap.Spec.Egress.Enabled
+}
+
+// IsTokenExchangeEnabled reports whether tokens of outbound requests are to be exchanged.
+func (ap *AuthPolicy) IsTokenExchangeEnabled() bool {
+	return ap.Spec.TokenExchange != nil && ap.Spec.TokenExchange.Enabled
+}
+
+// UsesOAuthCredentials reports whether the OAuth credentials of the AuthPolicy are used by any of its features.
+func (ap *AuthPolicy) UsesOAuthCredentials() bool {
+	autoLoginEnabled := ap.Spec.AutoLogin != nil && ap.Spec.AutoLogin.Enabled
+	return autoLoginEnabled || ap.IsEgressEnabled() || ap.IsTokenExchangeEnabled()
+}
+
+// HasDenyResponse reports whether a deny response is configured for the AuthPolicy or any of its auth rules.
+func (ap *AuthPolicy) HasDenyResponse() bool {
+	if ap.Spec.DenyResponse != nil {
+		return true
+	}
+	if ap.Spec.AuthRules == nil {
+		return false
+	}
+	return slices.ContainsFunc(*ap.Spec.AuthRules, func(authRule RequestAuthRule) bool {
+		return authRule.DenyResponse != nil
+	})
+}
+
+// GetIdentityProviderNames returns the names of all trusted identity providers, starting with the default one if present.
+func (ap *AuthPolicy) GetIdentityProviderNames() []string {
+	var identityProviderNames []string
+	if ap.HasDefaultIdentityProvider() {
+		identityProviderNames = append(identityProviderNames, DefaultIdentityProviderName)
+	}
+	for _, identityProvider := range ap.Spec.IdentityProviders {
+		identityProviderNames = append(identityProviderNames, identityProvider.Name)
+	}
+	return identityProviderNames
+}
+
 func (ap *AuthPolicy) GetRequireAuthRequestMatchers() []RequestMatcher {
 	var requireAuthRequestMatchers []RequestMatcher
 	if ap.Spec.AuthRules != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -20,7 +21,7 @@ func getValidAuthPolicy() *ztoperatorv1alpha1.AuthPolicy {
 		Spec: ztoperatorv1alpha1.AuthPolicySpec{
 			Enabled:      true,
 			WellKnownURI: "http://mock-oauth2.auth:8080/entraid/.well-known/openid-configuration",
-			Selector: ztoperatorv1alpha1.WorkloadSelector{
+			Selector: &ztoperatorv1alpha1.WorkloadSelector{
 				MatchLabels: map[string]string{"app": "application"},
 			},
 		},
@@ -185,7 +186,175 @@ var _ = Describe("AuthPolicy CRD", func() {
 			err := k8sClient.Update(testCtx, authPolicy)
 			Expect(err).To(HaveOccurred())
 			Expect(apierrors.IsInvalid(err)).To(BeTrue())
-			Expect(err.Error()).To(ContainSubstring("oAuthCredentials cannot be set unless autoLogin is configured"))
+			Expect(err.Error()).To(ContainSubstring("oAuthCredentials cannot be set unless autoLogin, egress or tokenExchange is configured"))
+		})
+
+		It("should reject updates when egress is enabled without oAuthCredentials", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.Egress = &ztoperatorv1alpha1.Egress{
+				Enabled: true,
+				Destinations: []ztoperatorv1alpha1.EgressDestination{
+					{Name: "api", Hosts: []string{"api.example.com"}, Scopes: []string{"api.read"}},
+				},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("oAuthCredentials must be set when egress is enabled"))
+		})
+
+		It("should reject updates when tokenExchange is enabled without oAuthCredentials", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.TokenExchange = &ztoperatorv1alpha1.TokenExchange{
+				Enabled: true,
+				Destinations: []ztoperatorv1alpha1.TokenExchangeDestination{
+					{Name: "api", Hosts: []string{"api.example.com"}, Audience: "api"},
+				},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("oAuthCredentials must be set when tokenExchange is enabled"))
+		})
+
+		It("should accept oAuthCredentials without autoLogin when egress is configured", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.OAuthCredentials = &ztoperatorv1alpha1.OAuthCredentials{
+				SecretRef:       "oauth-secret",
+				ClientIDKey:     "client-id",
+				ClientSecretKey: "client-secret",
+			}
+			authPolicy.Spec.Egress = &ztoperatorv1alpha1.Egress{
+				Enabled: true,
+				Destinations: []ztoperatorv1alpha1.EgressDestination{
+					{Name: "api", Hosts: []string{"api.example.com"}, Scopes: []string{"api.read"}},
+				},
+			}
+
+			Expect(k8sClient.Update(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when autoLogin is enabled in Audit enforcementMode", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.EnforcementMode = ztoperatorv1alpha1.EnforcementModeAudit
+			authPolicy.Spec.AutoLogin = &ztoperatorv1alpha1.AutoLogin{
+				Enabled: true,
+				Scopes:  []string{"openid"},
+			}
+			authPolicy.Spec.OAuthCredentials = &ztoperatorv1alpha1.OAuthCredentials{
+				SecretRef:       "oauth-secret",
+				ClientIDKey:     "client-id",
+				ClientSecretKey: "client-secret",
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("autoLogin cannot be enabled in Audit enforcementMode"))
+		})
+
+		It("should default enforcementMode to Enforce", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			Expect(authPolicy.Spec.EnforcementMode).To(Equal(ztoperatorv1alpha1.EnforcementModeEnforce))
+		})
+
+		It("should accept targetRefs instead of selector", func() {
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.Selector = nil
+			authPolicy.Spec.TargetRefs = []ztoperatorv1alpha1.PolicyTargetReference{
+				{Kind: ztoperatorv1alpha1.TargetRefKindGateway, Name: "my-gateway"},
+			}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when both selector and targetRefs are set", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.TargetRefs = []ztoperatorv1alpha1.PolicyTargetReference{
+				{Kind: ztoperatorv1alpha1.TargetRefKindService, Name: "my-service"},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("exactly one of selector or targetRefs must be set"))
+		})
+
+		It("should reject updates when autoLogin is enabled for targetRefs of kind Service", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.Selector = nil
+			authPolicy.Spec.TargetRefs = []ztoperatorv1alpha1.PolicyTargetReference{
+				{Kind: ztoperatorv1alpha1.TargetRefKindService, Name: "my-service"},
+			}
+			authPolicy.Spec.AutoLogin = &ztoperatorv1alpha1.AutoLogin{
+				Enabled: true,
+				Scopes:  []string{"openid"},
+			}
+			authPolicy.Spec.OAuthCredentials = &ztoperatorv1alpha1.OAuthCredentials{
+				SecretRef:       "oauth-secret",
+				ClientIDKey:     "client-id",
+				ClientSecretKey: "client-secret",
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("autoLogin requires selector or targetRefs of kind Gateway"))
+		})
+
+		It("should reject updates when denyResponse realm contains a quote", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			realm := `api", error="invalid_request`
+			authPolicy.Spec.DenyResponse = &ztoperatorv1alpha1.DenyResponse{Realm: &realm}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.denyResponse.realm"))
+		})
+
+		It("should accept a denyResponse on the AuthPolicy and its auth rules", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			realm := "api"
+			resourceMetadata := "https://api.example.com/.well-known/oauth-protected-resource"
+			errorDescription := "Administrators only"
+			problemType := "https://errors.example.com/admin-only"
+			authPolicy.Spec.DenyResponse = &ztoperatorv1alpha1.DenyResponse{
+				Realm:            &realm,
+				Scopes:           []string{"api.read"},
+				ResourceMetadata: &resourceMetadata,
+			}
+			authPolicy.Spec.AuthRules = &[]ztoperatorv1alpha1.RequestAuthRule{
+				{
+					RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/admin"}},
+					DenyResponse: &ztoperatorv1alpha1.DenyResponse{
+						ErrorDescription: &errorDescription,
+						ProblemType:      &problemType,
+					},
+				},
+			}
+
+			Expect(k8sClient.Update(testCtx, authPolicy)).To(Succeed())
 		})
 
 		It("should reject updates when autoLogin loginParams contains an invalid key", func() {
@@ -249,5 +418,416 @@ var _ = Describe("AuthPolicy CRD", func() {
 			Expect(apierrors.IsInvalid(err)).To(BeTrue())
 			Expect(err.Error()).To(ContainSubstring(`Unsupported value: "INVALID_METHOD"`))
 		})
+
+		It("should reject updates when neither wellKnownURI, endpoints, identityProviderRef nor identityProviders is set", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.WellKnownURI = ""
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(
+				"either wellKnownURI, endpoints, identityProviderRef or identityProviders must be set",
+			))
+		})
+
+		It("should accept an AuthPolicy with only identityProviderRef", func() {
+			identityProviderRef := "maskinporten"
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.WellKnownURI = ""
+			authPolicy.Spec.IdentityProviderRef = &identityProviderRef
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when both wellKnownURI and identityProviderRef are set", func() {
+			identityProviderRef := "maskinporten"
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.IdentityProviderRef = &identityProviderRef
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("wellKnownURI and identityProviderRef cannot both be set"))
+		})
+
+		It("should reject updates when an identity provider sets both wellKnownURI and identityProviderRef", func() {
+			identityProviderRef := "maskinporten"
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.IdentityProviders = []ztoperatorv1alpha1.TrustedIdentityProvider{
+				{
+					Name:                "maskinporten",
+					WellKnownURI:        "http://mock-oauth2.auth:8080/maskinporten/.well-known/openid-configuration",
+					IdentityProviderRef: &identityProviderRef,
+				},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(
+				"identityProviderRef cannot be set together with wellKnownURI or endpoints",
+			))
+		})
+
+		It("should accept an AuthPolicy with endpoints instead of wellKnownURI", func() {
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.WellKnownURI = ""
+			authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+				Issuer:        helperfunctions.Ptr("https://oauth.example.com"),
+				JwksURI:       helperfunctions.Ptr("https://oauth.example.com/jwks"),
+				TokenEndpoint: helperfunctions.Ptr("https://oauth.example.com/token"),
+			}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should accept an AuthPolicy with endpoints overriding wellKnownURI", func() {
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+				TokenEndpoint: helperfunctions.Ptr("https://proxy.example.com/token"),
+			}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when endpoints are incomplete without wellKnownURI", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.WellKnownURI = ""
+			authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+				Issuer:  helperfunctions.Ptr("https://oauth.example.com"),
+				JwksURI: helperfunctions.Ptr("https://oauth.example.com/jwks"),
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(
+				"endpoints must set issuer, jwksUri and tokenEndpoint unless wellKnownURI is set",
+			))
+		})
+
+		It("should accept an AuthPolicy with jwks and endpoints without jwksUri", func() {
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.WellKnownURI = ""
+			authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+				Issuer:        helperfunctions.Ptr("https://oauth.example.com"),
+				TokenEndpoint: helperfunctions.Ptr("https://oauth.example.com/token"),
+			}
+			authPolicy.Spec.Jwks = &ztoperatorv1alpha1.Jwks{
+				ValueFrom: &ztoperatorv1alpha1.ValueFrom{
+					ConfigMapKeyRef: &ztoperatorv1alpha1.KeyRef{Name: "jwks", Key: "jwks.json"},
+				},
+			}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when jwks is set without wellKnownURI or endpoints", func() {
+			identityProviderRef := "maskinporten"
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.WellKnownURI = ""
+			authPolicy.Spec.IdentityProviderRef = &identityProviderRef
+			authPolicy.Spec.Jwks = &ztoperatorv1alpha1.Jwks{Value: helperfunctions.Ptr(`{"keys":[]}`)}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("jwks requires wellKnownURI or endpoints to be set"))
+		})
+
+		It("should reject updates when jwks sets both value and valueFrom", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.Jwks = &ztoperatorv1alpha1.Jwks{
+				Value: helperfunctions.Ptr(`{"keys":[]}`),
+				ValueFrom: &ztoperatorv1alpha1.ValueFrom{
+					SecretKeyRef: &ztoperatorv1alpha1.KeyRef{Name: "jwks", Key: "jwks.json"},
+				},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("jwks cannot be defined from both 'value' and 'valueFrom'"))
+		})
+
+		It("should reject updates when both endpoints and identityProviderRef are set", func() {
+			identityProviderRef := "maskinporten"
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.WellKnownURI = ""
+			authPolicy.Spec.IdentityProviderRef = &identityProviderRef
+			authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+				TokenEndpoint: helperfunctions.Ptr("https://proxy.example.com/token"),
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("endpoints and identityProviderRef cannot both be set"))
+		})
+
+		It("should accept an AuthPolicy with only identityProviders", func() {
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.WellKnownURI = ""
+			authPolicy.Spec.IdentityProviders = []ztoperatorv1alpha1.TrustedIdentityProvider{
+				{
+					Name:         "maskinporten",
+					WellKnownURI: "http://mock-oauth2.auth:8080/maskinporten/.well-known/openid-configuration",
+				},
+			}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when an identity provider is named default", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.IdentityProviders = []ztoperatorv1alpha1.TrustedIdentityProvider{
+				{
+					Name:         "default",
+					WellKnownURI: "http://mock-oauth2.auth:8080/maskinporten/.well-known/openid-configuration",
+				},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("name 'default' is reserved"))
+		})
+
+		It("should reject updates when autoLogin is enabled without wellKnownURI", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.WellKnownURI = ""
+			authPolicy.Spec.IdentityProviders = []ztoperatorv1alpha1.TrustedIdentityProvider{
+				{
+					Name:         "maskinporten",
+					WellKnownURI: "http://mock-oauth2.auth:8080/maskinporten/.well-known/openid-configuration",
+				},
+			}
+			authPolicy.Spec.AutoLogin = &ztoperatorv1alpha1.AutoLogin{
+				Enabled: true,
+				Scopes:  []string{"openid"},
+			}
+			authPolicy.Spec.OAuthCredentials = &ztoperatorv1alpha1.OAuthCredentials{
+				SecretRef:       "oauth-credentials",
+				ClientSecretKey: "CLIENT_SECRET",
+				ClientIDKey:     "CLIENT_ID",
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(
+				"wellKnownURI, endpoints or identityProviderRef must be set when autoLogin is enabled",
+			))
+		})
+
+		It("should reject updates when a condition has no operator", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.BaselineAuth = &ztoperatorv1alpha1.BaselineAuth{
+				Claims: []ztoperatorv1alpha1.Condition{{Claim: "pid"}},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("at least one of 'values', 'notValues' or 'present' must be set"))
+		})
+
+		It("should reject updates when a condition combines present and values", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			present := true
+			authPolicy.Spec.BaselineAuth = &ztoperatorv1alpha1.BaselineAuth{
+				Claims: []ztoperatorv1alpha1.Condition{{Claim: "pid", Present: &present, Values: []string{"123"}}},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("'present' cannot be combined with 'values'"))
+		})
+
+		It("should reject updates when a condition value contains a wildcard in the middle", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.AuthRules = &[]ztoperatorv1alpha1.RequestAuthRule{
+				{
+					RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/api"}},
+					When: &[]ztoperatorv1alpha1.Condition{
+						{Claim: "groups", Values: []string{"team-*-admins"}},
+					},
+				},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("should accept prefix, suffix, negative and presence conditions", func() {
+			authPolicy := getValidAuthPolicy()
+			present := true
+			authPolicy.Spec.BaselineAuth = &ztoperatorv1alpha1.BaselineAuth{
+				Claims: []ztoperatorv1alpha1.Condition{
+					{Claim: "pid", Present: &present},
+					{Claim: "groups", Values: []string{"team-*"}, NotValues: []string{"team-external"}},
+					{Claim: "email", NotValues: []string{"*@example.com"}},
+				},
+			}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when a condition group has an empty allOf", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.BaselineAuth = &ztoperatorv1alpha1.BaselineAuth{
+				AnyOf: []ztoperatorv1alpha1.ConditionGroup{{AllOf: []ztoperatorv1alpha1.Condition{}}},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("should accept anyOf condition groups in baselineAuth and authRules", func() {
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.BaselineAuth = &ztoperatorv1alpha1.BaselineAuth{
+				AnyOf: []ztoperatorv1alpha1.ConditionGroup{
+					{AllOf: []ztoperatorv1alpha1.Condition{{Claim: "scope", Values: []string{"read"}}}},
+					{AllOf: []ztoperatorv1alpha1.Condition{{Claim: "scope", Values: []string{"write"}}}},
+				},
+			}
+			authPolicy.Spec.AuthRules = &[]ztoperatorv1alpha1.RequestAuthRule{
+				{
+					RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/admin"}},
+					AnyOf: &[]ztoperatorv1alpha1.ConditionGroup{
+						{AllOf: []ztoperatorv1alpha1.Condition{{Claim: "role", Values: []string{"admin"}}}},
+						{AllOf: []ztoperatorv1alpha1.Condition{
+							{Claim: "group", Values: []string{"ops"}},
+							{Claim: "acr", Values: []string{"high"}},
+						}},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when a header matcher has neither values nor notValues", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.IgnoreAuthRules = &[]ztoperatorv1alpha1.RequestMatcher{
+				{
+					Paths:   []string{"/api"},
+					Headers: []ztoperatorv1alpha1.HeaderMatcher{{Name: "x-api-key"}},
+				},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("at least one of 'values' or 'notValues' must be set"))
+		})
+
+		It("should accept hosts, notHosts and header matchers", func() {
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.AuthRules = &[]ztoperatorv1alpha1.RequestAuthRule{
+				{
+					RequestMatcher: ztoperatorv1alpha1.RequestMatcher{
+						Paths:    []string{"/api"},
+						Hosts:    []string{"api.example.com", "*.example.org"},
+						NotHosts: []string{"internal.*"},
+						Headers: []ztoperatorv1alpha1.HeaderMatcher{
+							{Name: "Accept", NotValues: []string{"text/html*"}},
+						},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when a source combines serviceAccounts and principals", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.AuthRules = &[]ztoperatorv1alpha1.RequestAuthRule{
+				{
+					RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/internal"}},
+					From: &[]ztoperatorv1alpha1.Source{
+						{
+							Principals:      []string{"cluster.local/ns/batch/sa/report-job"},
+							ServiceAccounts: []string{"batch/report-job"},
+						},
+					},
+				},
+			}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("'serviceAccounts' cannot be combined with 'principals'"))
+		})
+
+		It("should accept sources in authRules", func() {
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.AuthRules = &[]ztoperatorv1alpha1.RequestAuthRule{
+				{
+					RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/internal/{**}"}},
+					When:           &[]ztoperatorv1alpha1.Condition{{Claim: "role", Values: []string{"admin"}}},
+					From: &[]ztoperatorv1alpha1.Source{
+						{Namespaces: []string{"batch"}},
+						{ServiceAccounts: []string{"monitoring/prometheus"}, IPBlocks: []string{"10.0.0.0/8"}},
+					},
+				},
+			}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should accept custom token locations", func() {
+			authPolicy := getValidAuthPolicy()
+			authPolicy.Spec.FromHeaders = []ztoperatorv1alpha1.JWTHeader{{Name: "X-Api-Token"}}
+			authPolicy.Spec.FromParams = []string{"access_token"}
+			authPolicy.Spec.FromCookies = []string{"session"}
+
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+		})
+
+		It("should reject updates when a token cookie name is invalid", func() {
+			authPolicy := getValidAuthPolicy()
+			Expect(k8sClient.Create(testCtx, authPolicy)).To(Succeed())
+
+			authPolicy.Spec.FromCookies = []string{"session; evil"}
+
+			err := k8sClient.Update(testCtx, authPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
 	})
 })
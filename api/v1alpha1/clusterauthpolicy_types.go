@@ -0,0 +1,113 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ClusterAuthPolicySpec defines the desired state of ClusterAuthPolicy.
+//
+// +kubebuilder:validation:XValidation:message="at least one of baselineAuth, authRules, ignoreAuthRules or defaultDeny must be set",rule="has(self.baselineAuth) || has(self.authRules) || has(self.ignoreAuthRules) || (has(self.defaultDeny) && self.defaultDeny)"
+// +kubebuilder:validation:XValidation:message="authRules of a ClusterAuthPolicy cannot reference identityProviders",rule="!has(self.authRules) || self.authRules.all(r, !has(r.identityProviders))"
+type ClusterAuthPolicySpec struct {
+	// NamespaceSelector selects the namespaces whose AuthPolicies the ClusterAuthPolicy is merged into.
+	// If omitted, the ClusterAuthPolicy is merged into AuthPolicies in all namespaces.
+	//
+	// +kubebuilder:validation:Optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// BaselineAuth defines conditions based on JWT claims that every matching AuthPolicy must enforce.
+	// The conditions are added to the AuthPolicy's own .baselineAuth, so both must be met.
+	// Paths ignored by an AuthPolicy or another ClusterAuthPolicy are only ignored if .ignoreAuthRules of this
+	// ClusterAuthPolicy ignores them as well, for all of their methods, so the conditions cannot be bypassed.
+	//
+	// +kubebuilder:validation:Optional
+	BaselineAuth *BaselineAuth `json:"baselineAuth,omitempty"`
+
+	// AuthRules defines rules that are added to the .authRules of every matching AuthPolicy.
+	// An authRule takes precedence over any ignoreAuthRule for the same path, regardless of where either is defined.
+	//
+	// +kubebuilder:validation:Optional
+	AuthRules *[]RequestAuthRule `json:"authRules,omitempty"`
+
+	// IgnoreAuthRules defines request matchers that are added to the .ignoreAuthRules of every matching AuthPolicy.
+	// A path is not ignored if an AuthPolicy or another ClusterAuthPolicy defines an authRule for the same path.
+	// Along with .baselineAuth, they define the only paths other policies are allowed to ignore.
+	//
+	// +kubebuilder:validation:Optional
+	IgnoreAuthRules *[]RequestMatcher `json:"ignoreAuthRules,omitempty"`
+
+	// DefaultDeny denies all requests to workloads in the selected namespaces which no AuthPolicy protects.
+	// An AuthorizationPolicy allowing nothing is created in each selected namespace, so that only requests allowed by
+	// the AuthorizationPolicies of an AuthPolicy are accepted. Workloads of an AuthPolicy in Audit mode, or of a
+	// disabled AuthPolicy sharing no pods with an applied AuthPolicy, are exempt and allow all requests.
+	//
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=false
+	DefaultDeny bool `json:"defaultDeny,omitempty"`
+}
+
+// ClusterAuthPolicyStatus defines the observed state of ClusterAuthPolicy.
+type ClusterAuthPolicyStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+
+	// AuthPolicies lists the AuthPolicies the ClusterAuthPolicy is merged into, formatted as namespace/name.
+	// The effective rules are shown in the status of each AuthPolicy.
+	AuthPolicies []string `json:"authPolicies,omitempty"`
+
+	// Conflicts lists rules that were dropped or overridden while merging the ClusterAuthPolicy into AuthPolicies.
+	Conflicts []RuleConflict `json:"conflicts,omitempty"`
+
+	// DefaultDenyNamespaces lists the namespaces in which requests to unprotected workloads are denied.
+	DefaultDenyNamespaces []string `json:"defaultDenyNamespaces,omitempty"`
+}
+
+// RuleConflict describes a rule that was dropped or overridden while merging a ClusterAuthPolicy into an AuthPolicy.
+//
+// +kubebuilder:object:generate=true
+type RuleConflict struct {
+	// ClusterAuthPolicy is the name of the ClusterAuthPolicy involved in the conflict.
+	ClusterAuthPolicy string `json:"clusterAuthPolicy"`
+
+	// AuthPolicy is the AuthPolicy involved in the conflict, formatted as namespace/name.
+	AuthPolicy string `json:"authPolicy"`
+
+	// Message describes the conflict and how it was resolved.
+	Message string `json:"message"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+
+// ClusterAuthPolicy is the Schema for the clusterauthpolicies API.
+// It defines baseline requirements which are merged into every AuthPolicy in the selected namespaces.
+type ClusterAuthPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterAuthPolicySpec   `json:"spec,omitempty"`
+	Status ClusterAuthPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterAuthPolicyList contains a list of ClusterAuthPolicy.
+type ClusterAuthPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterAuthPolicy `json:"items"`
+}
+
+// MatchesNamespace reports whether the ClusterAuthPolicy applies to AuthPolicies in a namespace with the given labels.
+func (cp *ClusterAuthPolicy) MatchesNamespace(namespaceLabels map[string]string) (bool, error) {
+	if cp.Spec.NamespaceSelector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(cp.Spec.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(namespaceLabels)), nil
+}
@@ -0,0 +1,81 @@
+package v1alpha1_test
+
+import (
+	"context"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getValidClusterAuthPolicy() *ztoperatorv1alpha1.ClusterAuthPolicy {
+	return &ztoperatorv1alpha1.ClusterAuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-auth-policy",
+		},
+		Spec: ztoperatorv1alpha1.ClusterAuthPolicySpec{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "platform"},
+			},
+			BaselineAuth: &ztoperatorv1alpha1.BaselineAuth{
+				Claims: []ztoperatorv1alpha1.Condition{{Claim: "acr", Values: []string{"high"}}},
+			},
+		},
+	}
+}
+
+var _ = Describe("ClusterAuthPolicy CRD", func() {
+	Context("When applying a ClusterAuthPolicy resource", func() {
+		testCtx := context.Background()
+
+		AfterEach(func() {
+			clusterAuthPolicyList := &ztoperatorv1alpha1.ClusterAuthPolicyList{}
+			if err := k8sClient.List(testCtx, clusterAuthPolicyList); err == nil {
+				for _, clusterAuthPolicy := range clusterAuthPolicyList.Items {
+					_ = k8sClient.Delete(testCtx, &clusterAuthPolicy)
+				}
+			}
+		})
+
+		It("should accept a valid ClusterAuthPolicy", func() {
+			Expect(k8sClient.Create(testCtx, getValidClusterAuthPolicy())).To(Succeed())
+		})
+
+		It("should reject a ClusterAuthPolicy without any rules", func() {
+			clusterAuthPolicy := getValidClusterAuthPolicy()
+			clusterAuthPolicy.Spec.BaselineAuth = nil
+
+			err := k8sClient.Create(testCtx, clusterAuthPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(
+				"at least one of baselineAuth, authRules, ignoreAuthRules or defaultDeny must be set",
+			))
+		})
+
+		It("should accept a ClusterAuthPolicy which only denies by default", func() {
+			clusterAuthPolicy := getValidClusterAuthPolicy()
+			clusterAuthPolicy.Spec.BaselineAuth = nil
+			clusterAuthPolicy.Spec.DefaultDeny = true
+
+			Expect(k8sClient.Create(testCtx, clusterAuthPolicy)).To(Succeed())
+		})
+
+		It("should reject a ClusterAuthPolicy with authRules referencing identityProviders", func() {
+			clusterAuthPolicy := getValidClusterAuthPolicy()
+			clusterAuthPolicy.Spec.AuthRules = &[]ztoperatorv1alpha1.RequestAuthRule{
+				{
+					RequestMatcher:    ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/admin"}},
+					IdentityProviders: []string{"default"},
+				},
+			}
+
+			err := k8sClient.Create(testCtx, clusterAuthPolicy)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("authRules of a ClusterAuthPolicy cannot reference identityProviders"))
+		})
+	})
+})
@@ -24,6 +24,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(GroupVersion,
 		&AuthPolicy{},
 		&AuthPolicyList{},
+		&ClusterAuthPolicy{},
+		&ClusterAuthPolicyList{},
+		&IdentityProvider{},
+		&IdentityProviderList{},
 	)
 
 	metav1.AddToGroupVersion(scheme, GroupVersion)
@@ -0,0 +1,175 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IdentityProviderSpec defines the desired state of IdentityProvider.
+//
+// +kubebuilder:validation:XValidation:message="endpoints must set issuer, jwksUri and tokenEndpoint unless wellKnownURI is set",rule="has(self.wellKnownURI) || (has(self.endpoints) && has(self.endpoints.issuer) && has(self.endpoints.jwksUri) && has(self.endpoints.tokenEndpoint))"
+type IdentityProviderSpec struct {
+	// WellKnownURI specifies the URI to the identity provider's discovery document (also known as well-known endpoint).
+	// May be omitted for identity providers without a discovery document, when .endpoints sets all required endpoints.
+	//
+	// +kubebuilder:validation:Pattern=`^(https?):\/\/[^\s\/$.?#].[^\s]*$`
+	// +kubebuilder:validation:Optional
+	WellKnownURI string `json:"wellKnownURI,omitempty"`
+
+	// Endpoints specifies static endpoints which override the endpoints of the discovery document.
+	// When .wellKnownURI is omitted, .endpoints must set the issuer, JWKS URI and token endpoint.
+	//
+	// +kubebuilder:validation:Optional
+	Endpoints *IdentityProviderEndpoints `json:"endpoints,omitempty"`
+
+	// CABundle specifies PEM encoded CA certificates trusted by Ztoperator when fetching the discovery document and the
+	// JWKS of the identity provider, in addition to the system trust store.
+	// It is only used by the health check of Ztoperator. Istio fetching the JWKS and Envoy calling the token endpoint
+	// do not trust it, so the identity provider may be reported as reachable while requests cannot be authenticated.
+	//
+	// +kubebuilder:validation:Optional
+	CABundle string `json:"caBundle,omitempty"`
+
+	// RequiredFields specifies fields which must be non-empty in every AuthPolicy referencing the IdentityProvider,
+	// e.g. `acceptedResources` for identity providers issuing audience limited access tokens, such as ID-porten.
+	//
+	// +listType=set
+	// +kubebuilder:validation:Optional
+	RequiredFields []IdentityProviderRequiredField `json:"requiredFields,omitempty"`
+
+	// AutoLogin specifies defaults for .autoLogin of AuthPolicies referencing the IdentityProvider.
+	//
+	// +kubebuilder:validation:Optional
+	AutoLogin *IdentityProviderAutoLogin `json:"autoLogin,omitempty"`
+}
+
+// IdentityProviderEndpoints specifies static endpoints of an identity provider, for identity providers without a
+// discovery document or whose discovery document advertises unreachable endpoints.
+//
+// +kubebuilder:object:generate=true
+type IdentityProviderEndpoints struct {
+	// Issuer overrides the `issuer` of the discovery document.
+	//
+	// +kubebuilder:validation:Pattern=`^(https?):\/\/[^\s\/$.?#].[^\s]*$`
+	// +kubebuilder:validation:Optional
+	Issuer *string `json:"issuer,omitempty"`
+
+	// JwksURI overrides the `jwks_uri` of the discovery document.
+	//
+	// +kubebuilder:validation:Pattern=`^(https?):\/\/[^\s\/$.?#].[^\s]*$`
+	// +kubebuilder:validation:Optional
+	JwksURI *string `json:"jwksUri,omitempty"`
+
+	// TokenEndpoint overrides the `token_endpoint` of the discovery document.
+	//
+	// +kubebuilder:validation:Pattern=`^(https?):\/\/[^\s\/$.?#].[^\s]*$`
+	// +kubebuilder:validation:Optional
+	TokenEndpoint *string `json:"tokenEndpoint,omitempty"`
+
+	// AuthorizationEndpoint overrides the `authorization_endpoint` of the discovery document.
+	//
+	// +kubebuilder:validation:Pattern=`^(https?):\/\/[^\s\/$.?#].[^\s]*$`
+	// +kubebuilder:validation:Optional
+	AuthorizationEndpoint *string `json:"authorizationEndpoint,omitempty"`
+
+	// EndSessionEndpoint overrides the `end_session_endpoint` of the discovery document.
+	//
+	// +kubebuilder:validation:Pattern=`^(https?):\/\/[^\s\/$.?#].[^\s]*$`
+	// +kubebuilder:validation:Optional
+	EndSessionEndpoint *string `json:"endSessionEndpoint,omitempty"`
+}
+
+// IdentityProviderRequiredField is a field which must be set by AuthPolicies referencing an IdentityProvider.
+//
+// +kubebuilder:validation:Enum=allowedAudiences;acceptedResources
+type IdentityProviderRequiredField string
+
+const (
+	RequiredFieldAllowedAudiences  IdentityProviderRequiredField = "allowedAudiences"
+	RequiredFieldAcceptedResources IdentityProviderRequiredField = "acceptedResources"
+)
+
+// IdentityProviderAutoLogin specifies defaults for .autoLogin of AuthPolicies referencing an IdentityProvider.
+//
+// +kubebuilder:object:generate=true
+type IdentityProviderAutoLogin struct {
+	// Scopes specifies the OAuth2 scopes used during authorization code flow, unless set by the AuthPolicy.
+	//
+	// +kubebuilder:validation:Optional
+	Scopes []string `json:"scopes,omitempty"`
+
+	// LoginParams specifies query parameters added in the authorize request made towards the identity provider.
+	// Login parameters set by the AuthPolicy take precedence.
+	//
+	// +kubebuilder:validation:XValidation:message="loginParams keys must match ^[a-zA-Z_][a-zA-Z0-9_]*$",rule="self.all(k, k.matches('^[a-zA-Z_][a-zA-Z0-9_]*$'))"
+	// +kubebuilder:validation:MaxProperties=32
+	// +kubebuilder:validation:Optional
+	LoginParams map[string]string `json:"loginParams,omitempty"`
+}
+
+// IdentityProviderStatus defines the observed state of IdentityProvider.
+type IdentityProviderStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+
+	// Issuer is the issuer of the identity provider.
+	Issuer string `json:"issuer,omitempty"`
+
+	// JwksURI is the URI of the JWKS of the identity provider.
+	JwksURI string `json:"jwksUri,omitempty"`
+
+	// TokenEndpoint is the token endpoint of the identity provider.
+	TokenEndpoint string `json:"tokenEndpoint,omitempty"`
+
+	// AuthorizationEndpoint is the authorization endpoint of the identity provider, if supported.
+	AuthorizationEndpoint string `json:"authorizationEndpoint,omitempty"`
+
+	// EndSessionEndpoint is the end session endpoint of the identity provider, if supported.
+	EndSessionEndpoint string `json:"endSessionEndpoint,omitempty"`
+
+	// KeyIDs lists the key IDs (`kid`) of the JWKS the last time it was fetched.
+	KeyIDs []string `json:"keyIds,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Issuer",type=string,JSONPath=`.status.issuer`
+// +kubebuilder:printcolumn:name="Reachable",type=string,JSONPath=`.status.conditions[?(@.type=="Reachable")].status`
+
+// IdentityProvider is the Schema for the identityproviders API.
+// It defines an identity provider which AuthPolicies reference by name, instead of repeating its well-known URI.
+type IdentityProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   IdentityProviderSpec   `json:"spec,omitempty"`
+	Status IdentityProviderStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IdentityProviderList contains a list of IdentityProvider.
+type IdentityProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IdentityProvider `json:"items"`
+}
+
+// IsReachable reports whether the identity provider was reachable the last time it was checked.
+func (idp *IdentityProvider) IsReachable() bool {
+	return idp.Status.ObservedGeneration == idp.Generation &&
+		meta.IsStatusConditionTrue(idp.Status.Conditions, IdentityProviderConditionReachable)
+}
+
+// HasStaleEndpoints reports whether the identity provider was not reachable the last time it was checked, while the
+// status still holds the endpoints last resolved for its current generation.
+func (idp *IdentityProvider) HasStaleEndpoints() bool {
+	return idp.Status.ObservedGeneration == idp.Generation &&
+		!meta.IsStatusConditionTrue(idp.Status.Conditions, IdentityProviderConditionReachable) &&
+		idp.Status.Issuer != "" &&
+		idp.Status.JwksURI != ""
+}
+
+// IdentityProviderConditionReachable is the condition reporting whether the identity provider is reachable.
+const IdentityProviderConditionReachable = "Reachable"
@@ -0,0 +1,106 @@
+package v1alpha1_test
+
+import (
+	"context"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getValidIdentityProvider() *ztoperatorv1alpha1.IdentityProvider {
+	return &ztoperatorv1alpha1.IdentityProvider{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "idporten",
+		},
+		Spec: ztoperatorv1alpha1.IdentityProviderSpec{
+			WellKnownURI: "https://idporten.no/.well-known/openid-configuration",
+			RequiredFields: []ztoperatorv1alpha1.IdentityProviderRequiredField{
+				ztoperatorv1alpha1.RequiredFieldAcceptedResources,
+			},
+			AutoLogin: &ztoperatorv1alpha1.IdentityProviderAutoLogin{
+				Scopes:      []string{"openid", "profile"},
+				LoginParams: map[string]string{"acr_values": "idporten-loa-high"},
+			},
+		},
+	}
+}
+
+var _ = Describe("IdentityProvider CRD", func() {
+	Context("When applying an IdentityProvider resource", func() {
+		testCtx := context.Background()
+
+		AfterEach(func() {
+			identityProviderList := &ztoperatorv1alpha1.IdentityProviderList{}
+			if err := k8sClient.List(testCtx, identityProviderList); err == nil {
+				for _, identityProvider := range identityProviderList.Items {
+					_ = k8sClient.Delete(testCtx, &identityProvider)
+				}
+			}
+		})
+
+		It("should accept a valid IdentityProvider", func() {
+			Expect(k8sClient.Create(testCtx, getValidIdentityProvider())).To(Succeed())
+		})
+
+		It("should reject an IdentityProvider with an invalid wellKnownURI", func() {
+			identityProvider := getValidIdentityProvider()
+			identityProvider.Spec.WellKnownURI = "idporten.no/.well-known/openid-configuration"
+
+			err := k8sClient.Create(testCtx, identityProvider)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.wellKnownURI"))
+		})
+
+		It("should accept an IdentityProvider with endpoints instead of wellKnownURI", func() {
+			identityProvider := getValidIdentityProvider()
+			identityProvider.Spec.WellKnownURI = ""
+			identityProvider.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+				Issuer:        helperfunctions.Ptr("https://oauth.example.com"),
+				JwksURI:       helperfunctions.Ptr("https://oauth.example.com/jwks"),
+				TokenEndpoint: helperfunctions.Ptr("https://oauth.example.com/token"),
+			}
+
+			Expect(k8sClient.Create(testCtx, identityProvider)).To(Succeed())
+		})
+
+		It("should reject an IdentityProvider with incomplete endpoints and no wellKnownURI", func() {
+			identityProvider := getValidIdentityProvider()
+			identityProvider.Spec.WellKnownURI = ""
+			identityProvider.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+				Issuer: helperfunctions.Ptr("https://oauth.example.com"),
+			}
+
+			err := k8sClient.Create(testCtx, identityProvider)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(
+				"endpoints must set issuer, jwksUri and tokenEndpoint unless wellKnownURI is set",
+			))
+		})
+
+		It("should reject an IdentityProvider with an unknown required field", func() {
+			identityProvider := getValidIdentityProvider()
+			identityProvider.Spec.RequiredFields = []ztoperatorv1alpha1.IdentityProviderRequiredField{"selector"}
+
+			err := k8sClient.Create(testCtx, identityProvider)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`Unsupported value: "selector"`))
+		})
+
+		It("should reject an IdentityProvider with invalid loginParams keys", func() {
+			identityProvider := getValidIdentityProvider()
+			identityProvider.Spec.AutoLogin.LoginParams = map[string]string{"acr-values": "high"}
+
+			err := k8sClient.Create(testCtx, identityProvider)
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("loginParams keys must match"))
+		})
+	})
+})
@@ -122,6 +122,38 @@ func (in *AuthPolicySpec) DeepCopyInto(out *AuthPolicySpec) {
 		*out = new(OAuthCredentials)
 		**out = **in
 	}
+	if in.Egress != nil {
+		in, out := &in.Egress, &out.Egress
+		*out = new(Egress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TokenExchange != nil {
+		in, out := &in.TokenExchange, &out.TokenExchange
+		*out = new(TokenExchange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(IdentityProviderEndpoints)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Jwks != nil {
+		in, out := &in.Jwks, &out.Jwks
+		*out = new(Jwks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IdentityProviderRef != nil {
+		in, out := &in.IdentityProviderRef, &out.IdentityProviderRef
+		*out = new(string)
+		**out = **in
+	}
+	if in.IdentityProviders != nil {
+		in, out := &in.IdentityProviders, &out.IdentityProviders
+		*out = make([]TrustedIdentityProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.AllowedAudiences != nil {
 		in, out := &in.AllowedAudiences, &out.AllowedAudiences
 		*out = make([]AllowedAudience, len(*in))
@@ -143,6 +175,21 @@ func (in *AuthPolicySpec) DeepCopyInto(out *AuthPolicySpec) {
 			copy(*out, *in)
 		}
 	}
+	if in.FromHeaders != nil {
+		in, out := &in.FromHeaders, &out.FromHeaders
+		*out = make([]JWTHeader, len(*in))
+		copy(*out, *in)
+	}
+	if in.FromParams != nil {
+		in, out := &in.FromParams, &out.FromParams
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FromCookies != nil {
+		in, out := &in.FromCookies, &out.FromCookies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.AcceptedResources != nil {
 		in, out := &in.AcceptedResources, &out.AcceptedResources
 		*out = new([]string)
@@ -179,7 +226,21 @@ func (in *AuthPolicySpec) DeepCopyInto(out *AuthPolicySpec) {
 			}
 		}
 	}
-	in.Selector.DeepCopyInto(&out.Selector)
+	if in.DenyResponse != nil {
+		in, out := &in.DenyResponse, &out.DenyResponse
+		*out = new(DenyResponse)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(WorkloadSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TargetRefs != nil {
+		in, out := &in.TargetRefs, &out.TargetRefs
+		*out = make([]PolicyTargetReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthPolicySpec.
@@ -202,6 +263,26 @@ func (in *AuthPolicyStatus) DeepCopyInto(out *AuthPolicyStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ClusterAuthPolicies != nil {
+		in, out := &in.ClusterAuthPolicies, &out.ClusterAuthPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EffectiveRules != nil {
+		in, out := &in.EffectiveRules, &out.EffectiveRules
+		*out = new(EffectiveRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]RuleConflict, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastKnownGood != nil {
+		in, out := &in.LastKnownGood, &out.LastKnownGood
+		*out = new(LastKnownGood)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthPolicyStatus.
@@ -271,6 +352,13 @@ func (in *BaselineAuth) DeepCopyInto(out *BaselineAuth) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.AnyOf != nil {
+		in, out := &in.AnyOf, &out.AnyOf
+		*out = make([]ConditionGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaselineAuth.
@@ -298,6 +386,149 @@ func (in *ClaimToHeader) DeepCopy() *ClaimToHeader {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAuthPolicy) DeepCopyInto(out *ClusterAuthPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAuthPolicy.
+func (in *ClusterAuthPolicy) DeepCopy() *ClusterAuthPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAuthPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterAuthPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAuthPolicyList) DeepCopyInto(out *ClusterAuthPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterAuthPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAuthPolicyList.
+func (in *ClusterAuthPolicyList) DeepCopy() *ClusterAuthPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAuthPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterAuthPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAuthPolicySpec) DeepCopyInto(out *ClusterAuthPolicySpec) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BaselineAuth != nil {
+		in, out := &in.BaselineAuth, &out.BaselineAuth
+		*out = new(BaselineAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AuthRules != nil {
+		in, out := &in.AuthRules, &out.AuthRules
+		*out = new([]RequestAuthRule)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]RequestAuthRule, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
+	if in.IgnoreAuthRules != nil {
+		in, out := &in.IgnoreAuthRules, &out.IgnoreAuthRules
+		*out = new([]RequestMatcher)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]RequestMatcher, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAuthPolicySpec.
+func (in *ClusterAuthPolicySpec) DeepCopy() *ClusterAuthPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAuthPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAuthPolicyStatus) DeepCopyInto(out *ClusterAuthPolicyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AuthPolicies != nil {
+		in, out := &in.AuthPolicies, &out.AuthPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]RuleConflict, len(*in))
+		copy(*out, *in)
+	}
+	if in.DefaultDenyNamespaces != nil {
+		in, out := &in.DefaultDenyNamespaces, &out.DefaultDenyNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAuthPolicyStatus.
+func (in *ClusterAuthPolicyStatus) DeepCopy() *ClusterAuthPolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAuthPolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Condition) DeepCopyInto(out *Condition) {
 	*out = *in
@@ -306,6 +537,16 @@ func (in *Condition) DeepCopyInto(out *Condition) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NotValues != nil {
+		in, out := &in.NotValues, &out.NotValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Present != nil {
+		in, out := &in.Present, &out.Present
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Condition.
@@ -319,88 +560,736 @@ func (in *Condition) DeepCopy() *Condition {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KeyRef) DeepCopyInto(out *KeyRef) {
+func (in *ConditionGroup) DeepCopyInto(out *ConditionGroup) {
 	*out = *in
+	if in.AllOf != nil {
+		in, out := &in.AllOf, &out.AllOf
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRef.
-func (in *KeyRef) DeepCopy() *KeyRef {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConditionGroup.
+func (in *ConditionGroup) DeepCopy() *ConditionGroup {
 	if in == nil {
 		return nil
 	}
-	out := new(KeyRef)
+	out := new(ConditionGroup)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OAuthCredentials) DeepCopyInto(out *OAuthCredentials) {
+func (in *DenyResponse) DeepCopyInto(out *DenyResponse) {
 	*out = *in
+	if in.Realm != nil {
+		in, out := &in.Realm, &out.Realm
+		*out = new(string)
+		**out = **in
+	}
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResourceMetadata != nil {
+		in, out := &in.ResourceMetadata, &out.ResourceMetadata
+		*out = new(string)
+		**out = **in
+	}
+	if in.ErrorDescription != nil {
+		in, out := &in.ErrorDescription, &out.ErrorDescription
+		*out = new(string)
+		**out = **in
+	}
+	if in.ProblemType != nil {
+		in, out := &in.ProblemType, &out.ProblemType
+		*out = new(string)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuthCredentials.
-func (in *OAuthCredentials) DeepCopy() *OAuthCredentials {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DenyResponse.
+func (in *DenyResponse) DeepCopy() *DenyResponse {
 	if in == nil {
 		return nil
 	}
-	out := new(OAuthCredentials)
+	out := new(DenyResponse)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RequestAuthRule) DeepCopyInto(out *RequestAuthRule) {
+func (in *EffectiveRules) DeepCopyInto(out *EffectiveRules) {
 	*out = *in
-	in.RequestMatcher.DeepCopyInto(&out.RequestMatcher)
-	if in.When != nil {
-		in, out := &in.When, &out.When
-		*out = new([]Condition)
-		if **in != nil {
-			in, out := *in, *out
-			*out = make([]Condition, len(*in))
-			for i := range *in {
-				(*in)[i].DeepCopyInto(&(*out)[i])
-			}
+	if in.BaselineAuth != nil {
+		in, out := &in.BaselineAuth, &out.BaselineAuth
+		*out = new(BaselineAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AuthRules != nil {
+		in, out := &in.AuthRules, &out.AuthRules
+		*out = make([]RequestAuthRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.DenyRedirect != nil {
-		in, out := &in.DenyRedirect, &out.DenyRedirect
-		*out = new(bool)
-		**out = **in
+	if in.IgnoreAuthRules != nil {
+		in, out := &in.IgnoreAuthRules, &out.IgnoreAuthRules
+		*out = make([]RequestMatcher, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestAuthRule.
-func (in *RequestAuthRule) DeepCopy() *RequestAuthRule {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectiveRules.
+func (in *EffectiveRules) DeepCopy() *EffectiveRules {
 	if in == nil {
 		return nil
 	}
-	out := new(RequestAuthRule)
+	out := new(EffectiveRules)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RequestMatcher) DeepCopyInto(out *RequestMatcher) {
+func (in *Egress) DeepCopyInto(out *Egress) {
 	*out = *in
-	if in.Paths != nil {
-		in, out := &in.Paths, &out.Paths
+	if in.Destinations != nil {
+		in, out := &in.Destinations, &out.Destinations
+		*out = make([]EgressDestination, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Egress.
+func (in *Egress) DeepCopy() *Egress {
+	if in == nil {
+		return nil
+	}
+	out := new(Egress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressDestination) DeepCopyInto(out *EgressDestination) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.Methods != nil {
-		in, out := &in.Methods, &out.Methods
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.IdentityProvider != nil {
+		in, out := &in.IdentityProvider, &out.IdentityProvider
+		*out = new(string)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestMatcher.
-func (in *RequestMatcher) DeepCopy() *RequestMatcher {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressDestination.
+func (in *EgressDestination) DeepCopy() *EgressDestination {
 	if in == nil {
 		return nil
 	}
-	out := new(RequestMatcher)
+	out := new(EgressDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeaderMatcher) DeepCopyInto(out *HeaderMatcher) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NotValues != nil {
+		in, out := &in.NotValues, &out.NotValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeaderMatcher.
+func (in *HeaderMatcher) DeepCopy() *HeaderMatcher {
+	if in == nil {
+		return nil
+	}
+	out := new(HeaderMatcher)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityProvider) DeepCopyInto(out *IdentityProvider) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityProvider.
+func (in *IdentityProvider) DeepCopy() *IdentityProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IdentityProvider) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityProviderAutoLogin) DeepCopyInto(out *IdentityProviderAutoLogin) {
+	*out = *in
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoginParams != nil {
+		in, out := &in.LoginParams, &out.LoginParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityProviderAutoLogin.
+func (in *IdentityProviderAutoLogin) DeepCopy() *IdentityProviderAutoLogin {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityProviderAutoLogin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityProviderEndpoints) DeepCopyInto(out *IdentityProviderEndpoints) {
+	*out = *in
+	if in.Issuer != nil {
+		in, out := &in.Issuer, &out.Issuer
+		*out = new(string)
+		**out = **in
+	}
+	if in.JwksURI != nil {
+		in, out := &in.JwksURI, &out.JwksURI
+		*out = new(string)
+		**out = **in
+	}
+	if in.TokenEndpoint != nil {
+		in, out := &in.TokenEndpoint, &out.TokenEndpoint
+		*out = new(string)
+		**out = **in
+	}
+	if in.AuthorizationEndpoint != nil {
+		in, out := &in.AuthorizationEndpoint, &out.AuthorizationEndpoint
+		*out = new(string)
+		**out = **in
+	}
+	if in.EndSessionEndpoint != nil {
+		in, out := &in.EndSessionEndpoint, &out.EndSessionEndpoint
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityProviderEndpoints.
+func (in *IdentityProviderEndpoints) DeepCopy() *IdentityProviderEndpoints {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityProviderEndpoints)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityProviderList) DeepCopyInto(out *IdentityProviderList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]IdentityProvider, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityProviderList.
+func (in *IdentityProviderList) DeepCopy() *IdentityProviderList {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityProviderList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IdentityProviderList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityProviderSpec) DeepCopyInto(out *IdentityProviderSpec) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(IdentityProviderEndpoints)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequiredFields != nil {
+		in, out := &in.RequiredFields, &out.RequiredFields
+		*out = make([]IdentityProviderRequiredField, len(*in))
+		copy(*out, *in)
+	}
+	if in.AutoLogin != nil {
+		in, out := &in.AutoLogin, &out.AutoLogin
+		*out = new(IdentityProviderAutoLogin)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityProviderSpec.
+func (in *IdentityProviderSpec) DeepCopy() *IdentityProviderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityProviderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityProviderStatus) DeepCopyInto(out *IdentityProviderStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KeyIDs != nil {
+		in, out := &in.KeyIDs, &out.KeyIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityProviderStatus.
+func (in *IdentityProviderStatus) DeepCopy() *IdentityProviderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityProviderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JWTHeader) DeepCopyInto(out *JWTHeader) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JWTHeader.
+func (in *JWTHeader) DeepCopy() *JWTHeader {
+	if in == nil {
+		return nil
+	}
+	out := new(JWTHeader)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Jwks) DeepCopyInto(out *Jwks) {
+	*out = *in
+	if in.Value != nil {
+		in, out := &in.Value, &out.Value
+		*out = new(string)
+		**out = **in
+	}
+	if in.ValueFrom != nil {
+		in, out := &in.ValueFrom, &out.ValueFrom
+		*out = new(ValueFrom)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Jwks.
+func (in *Jwks) DeepCopy() *Jwks {
+	if in == nil {
+		return nil
+	}
+	out := new(Jwks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRef) DeepCopyInto(out *KeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRef.
+func (in *KeyRef) DeepCopy() *KeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LastKnownGood) DeepCopyInto(out *LastKnownGood) {
+	*out = *in
+	if in.Audiences != nil {
+		in, out := &in.Audiences, &out.Audiences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IdentityProviders != nil {
+		in, out := &in.IdentityProviders, &out.IdentityProviders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.AppliedAt.DeepCopyInto(&out.AppliedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LastKnownGood.
+func (in *LastKnownGood) DeepCopy() *LastKnownGood {
+	if in == nil {
+		return nil
+	}
+	out := new(LastKnownGood)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuthCredentials) DeepCopyInto(out *OAuthCredentials) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuthCredentials.
+func (in *OAuthCredentials) DeepCopy() *OAuthCredentials {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuthCredentials)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyTargetReference) DeepCopyInto(out *PolicyTargetReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyTargetReference.
+func (in *PolicyTargetReference) DeepCopy() *PolicyTargetReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyTargetReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestAuthRule) DeepCopyInto(out *RequestAuthRule) {
+	*out = *in
+	in.RequestMatcher.DeepCopyInto(&out.RequestMatcher)
+	if in.When != nil {
+		in, out := &in.When, &out.When
+		*out = new([]Condition)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]Condition, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
+	if in.AnyOf != nil {
+		in, out := &in.AnyOf, &out.AnyOf
+		*out = new([]ConditionGroup)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]ConditionGroup, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = new([]Source)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]Source, len(*in))
+			for i := range *in {
+				(*in)[i].DeepCopyInto(&(*out)[i])
+			}
+		}
+	}
+	if in.IdentityProviders != nil {
+		in, out := &in.IdentityProviders, &out.IdentityProviders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DenyRedirect != nil {
+		in, out := &in.DenyRedirect, &out.DenyRedirect
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DenyResponse != nil {
+		in, out := &in.DenyResponse, &out.DenyResponse
+		*out = new(DenyResponse)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestAuthRule.
+func (in *RequestAuthRule) DeepCopy() *RequestAuthRule {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestAuthRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestMatcher) DeepCopyInto(out *RequestMatcher) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NotHosts != nil {
+		in, out := &in.NotHosts, &out.NotHosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]HeaderMatcher, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestMatcher.
+func (in *RequestMatcher) DeepCopy() *RequestMatcher {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestMatcher)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleConflict) DeepCopyInto(out *RuleConflict) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleConflict.
+func (in *RuleConflict) DeepCopy() *RuleConflict {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleConflict)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Source) DeepCopyInto(out *Source) {
+	*out = *in
+	if in.Principals != nil {
+		in, out := &in.Principals, &out.Principals
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPBlocks != nil {
+		in, out := &in.IPBlocks, &out.IPBlocks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Source.
+func (in *Source) DeepCopy() *Source {
+	if in == nil {
+		return nil
+	}
+	out := new(Source)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenExchange) DeepCopyInto(out *TokenExchange) {
+	*out = *in
+	if in.Destinations != nil {
+		in, out := &in.Destinations, &out.Destinations
+		*out = make([]TokenExchangeDestination, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenExchange.
+func (in *TokenExchange) DeepCopy() *TokenExchange {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenExchange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenExchangeDestination) DeepCopyInto(out *TokenExchangeDestination) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenExchangeDestination.
+func (in *TokenExchangeDestination) DeepCopy() *TokenExchangeDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenExchangeDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustedIdentityProvider) DeepCopyInto(out *TrustedIdentityProvider) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = new(IdentityProviderEndpoints)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Jwks != nil {
+		in, out := &in.Jwks, &out.Jwks
+		*out = new(Jwks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IdentityProviderRef != nil {
+		in, out := &in.IdentityProviderRef, &out.IdentityProviderRef
+		*out = new(string)
+		**out = **in
+	}
+	if in.AllowedAudiences != nil {
+		in, out := &in.AllowedAudiences, &out.AllowedAudiences
+		*out = make([]AllowedAudience, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AcceptedResources != nil {
+		in, out := &in.AcceptedResources, &out.AcceptedResources
+		*out = new([]string)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]string, len(*in))
+			copy(*out, *in)
+		}
+	}
+	if in.ForwardJwt != nil {
+		in, out := &in.ForwardJwt, &out.ForwardJwt
+		*out = new(bool)
+		**out = **in
+	}
+	if in.OutputClaimToHeaders != nil {
+		in, out := &in.OutputClaimToHeaders, &out.OutputClaimToHeaders
+		*out = new([]ClaimToHeader)
+		if **in != nil {
+			in, out := *in, *out
+			*out = make([]ClaimToHeader, len(*in))
+			copy(*out, *in)
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustedIdentityProvider.
+func (in *TrustedIdentityProvider) DeepCopy() *TrustedIdentityProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustedIdentityProvider)
 	in.DeepCopyInto(out)
 	return out
 }
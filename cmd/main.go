@@ -17,12 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
 
+	"github.com/kartverket/ztoperator/internal/render"
 	v1 "github.com/kartverket/ztoperator/internal/webhook/v1"
 	"github.com/kartverket/ztoperator/pkg/config"
 	"github.com/kartverket/ztoperator/pkg/metrics"
@@ -65,6 +67,13 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(render.Run(context.Background(), os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		os.Exit(render.RunExplain(context.Background(), os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	var metricsAddr string
 	var webhookCertPath, webhookCertName, webhookCertKey string
 	var enableLeaderElection bool
@@ -196,21 +205,50 @@ func main() {
 		os.Exit(1)
 	}
 
+	discoveryDocumentResolver, discoveryDocumentResolverErr := rest.NewStaticDiscoveryDocumentResolver(
+		mgr.GetClient(),
+		rest.NewDefaultDiscoveryDocumentResolver(),
+	)
+	if discoveryDocumentResolverErr != nil {
+		setupLog.Error(discoveryDocumentResolverErr, "unable to create discovery document resolver")
+		os.Exit(1)
+	}
+
 	if err = (&controller.AuthPolicyReconciler{
 		Client:                    mgr.GetClient(),
 		Scheme:                    mgr.GetScheme(),
 		Recorder:                  mgr.GetEventRecorder("authpolicy-controller"),
-		DiscoveryDocumentResolver: rest.NewDefaultDiscoveryDocumentResolver(),
+		DiscoveryDocumentResolver: discoveryDocumentResolver,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AuthPolicy")
 		os.Exit(1)
 	}
+	if err = (&controller.ClusterAuthPolicyReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterAuthPolicy")
+		os.Exit(1)
+	}
+	if err = (&controller.IdentityProviderReconciler{
+		Client:                    mgr.GetClient(),
+		Scheme:                    mgr.GetScheme(),
+		DiscoveryDocumentResolver: discoveryDocumentResolver,
+		JWKSResolver:              rest.NewDefaultJWKSResolver(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "IdentityProvider")
+		os.Exit(1)
+	}
 	// nolint:goconst
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
 		if err := v1.SetupPodWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Pod")
 			os.Exit(1)
 		}
+		if err := v1.SetupAuthPolicyWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "AuthPolicy")
+			os.Exit(1)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 
@@ -5,11 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"slices"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/eventhandler/authpolicy"
+	"github.com/kartverket/ztoperator/internal/eventhandler/clusterauthpolicy"
 	"github.com/kartverket/ztoperator/internal/eventhandler/configmap"
+	"github.com/kartverket/ztoperator/internal/eventhandler/gateway"
+	"github.com/kartverket/ztoperator/internal/eventhandler/identityprovider"
+	"github.com/kartverket/ztoperator/internal/eventhandler/namespace"
 	"github.com/kartverket/ztoperator/internal/eventhandler/pod"
 	"github.com/kartverket/ztoperator/internal/eventhandler/secret"
+	"github.com/kartverket/ztoperator/internal/eventhandler/service"
 	"github.com/kartverket/ztoperator/internal/reconciler"
 	"github.com/kartverket/ztoperator/internal/resolver"
 	"github.com/kartverket/ztoperator/internal/state"
@@ -25,6 +32,7 @@ import (
 	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8sErrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/events"
@@ -45,7 +53,7 @@ type AuthPolicyReconciler struct {
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AuthPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
 		For(
 			&ztoperatorv1alpha1.AuthPolicy{},
 			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
@@ -55,20 +63,56 @@ func (r *AuthPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&v1alpha4.EnvoyFilter{}).
 		Owns(&v1.Secret{}).
 		Watches(&v1.Pod{}, pod.EventHandler(r.Client)).
+		Watches(&v1.Service{}, service.EventHandler(r.Client)).
 		Watches(&v1.Secret{}, secret.EventHandler(r.Client)).
 		Watches(&v1.ConfigMap{}, configmap.EventHandler(r.Client)).
-		Complete(r)
+		Watches(
+			&ztoperatorv1alpha1.ClusterAuthPolicy{},
+			clusterauthpolicy.EventHandler(r.Client),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Watches(&ztoperatorv1alpha1.IdentityProvider{}, identityprovider.EventHandler(r.Client)).
+		Watches(
+			&v1.Namespace{},
+			namespace.EventHandler(r.Client),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}),
+		).
+		Watches(
+			&ztoperatorv1alpha1.AuthPolicy{},
+			authpolicy.NamespaceEventHandler(r.Client),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		)
+
+	// Gateways are only watched where the Gateway API is installed, as it is only required for AuthPolicies
+	// targeting gateways
+	gatewayGroupVersionKind := resolver.GatewayGroupVersionKind
+	if _, err := mgr.GetRESTMapper().RESTMapping(
+		gatewayGroupVersionKind.GroupKind(),
+		gatewayGroupVersionKind.Version,
+	); err == nil {
+		gatewayObject := &unstructured.Unstructured{}
+		gatewayObject.SetGroupVersionKind(gatewayGroupVersionKind)
+		controllerBuilder = controllerBuilder.Watches(
+			gatewayObject,
+			gateway.EventHandler(r.Client),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		)
+	}
+
+	return controllerBuilder.Complete(r)
 }
 
 // +kubebuilder:rbac:groups=ztoperator.kartverket.no,resources=authpolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=ztoperator.kartverket.no,resources=authpolicies/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=ztoperator.kartverket.no,resources=authpolicies/finalizers,verbs=update
+// +kubebuilder:rbac:groups=ztoperator.kartverket.no,resources=identityproviders,verbs=get;list;watch
 // +kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
-// +kubebuilder:rbac:groups=core,resources=namespaces;pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces;pods;services,verbs=get;list;watch
 // +kubebuilder:rbac:groups=security.istio.io,resources=authorizationpolicies;requestauthentications,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=networking.istio.io,resources=envoyfilters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
 
 func (r *AuthPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	rLog := log.GetLogger(ctx)
@@ -122,6 +166,21 @@ func (r *AuthPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	scope, err := resolveAuthPolicy(ctx, r.Client, authPolicy, r.DiscoveryDocumentResolver)
 	if err != nil {
 		rLog.Error(err, fmt.Sprintf("Failed to resolve AuthPolicy with name %s", req.String()))
+		if hasLastKnownGood(authPolicy) {
+			updateDegradedStatusErr := statusmanager.UpdateDegradedAuthPolicyStatus(
+				ctx,
+				r.Client,
+				r.Recorder,
+				authPolicy,
+				originalAuthPolicy,
+				statusmanager.DegradedReasonResolutionFailed,
+				fmt.Sprintf("Failed to resolve AuthPolicy: %s", err),
+			)
+			if updateDegradedStatusErr != nil {
+				return ctrl.Result{}, updateDegradedStatusErr
+			}
+			return reconcile.Result{}, err
+		}
 		authPolicy.Status.Phase = ztoperatorv1alpha1.PhaseFailed
 		authPolicy.Status.Message = err.Error()
 		updateStatusOnResolveFailedErr := statusmanager.UpdateStatus(ctx, r.Client, *authPolicy)
@@ -131,8 +190,46 @@ func (r *AuthPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return reconcile.Result{}, err
 	}
 
+	for _, ruleConflict := range scope.RuleConflicts {
+		r.Recorder.Eventf(
+			authPolicy,
+			nil,
+			"Warning",
+			"ClusterAuthPolicyConflict",
+			"Reconcile",
+			"%s", ruleConflict.Message,
+		)
+	}
+
+	if conflictMessage := scope.GetConflictMessage(); conflictMessage != nil {
+		r.Recorder.Eventf(
+			authPolicy,
+			nil,
+			"Warning",
+			"Conflict",
+			"Reconcile",
+			"%s", *conflictMessage,
+		)
+	}
+
 	scope = validateAuthPolicy(ctx, scope)
 
+	if scope.InvalidConfig && scope.IsEnabled() && hasLastKnownGood(authPolicy) {
+		rLog.Info(fmt.Sprintf(
+			"Keeping the last known good resources of AuthPolicy with name %s, as its configuration is invalid",
+			req.String(),
+		))
+		return ctrl.Result{}, statusmanager.UpdateDegradedAuthPolicyStatus(
+			ctx,
+			r.Client,
+			r.Recorder,
+			&scope.AuthPolicy,
+			originalAuthPolicy,
+			statusmanager.DegradedReasonInvalidConfiguration,
+			*scope.ValidationErrorMessage,
+		)
+	}
+
 	controllerResources := reconciler.ControllerResources(scope)
 
 	defer func() {
@@ -199,6 +296,29 @@ func (r *AuthPolicyReconciler) doReconcile(
 	return result, nil
 }
 
+// hasLastKnownGood reports whether the resources of the AuthPolicy are to be kept untouched when its configuration is
+// invalid or cannot be resolved, which requires a configuration to have been successfully applied before.
+func hasLastKnownGood(authPolicy *ztoperatorv1alpha1.AuthPolicy) bool {
+	return authPolicy.KeepsLastKnownGood() && authPolicy.Status.LastKnownGood != nil
+}
+
+// DesiredResources resolves and validates the AuthPolicy the same way as Reconcile, and returns the resources Reconcile
+// would reconcile towards, without reconciling them. It lets the resources of an AuthPolicy be rendered offline, with
+// k8sClient serving the objects the AuthPolicy depends on.
+func DesiredResources(
+	ctx context.Context,
+	k8sClient client.Client,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+	discoveryDocumentResolver rest.DiscoveryDocumentResolver,
+) (*state.Scope, []reconciliation.ControllerResource, error) {
+	scope, err := resolveAuthPolicy(ctx, k8sClient, authPolicy, discoveryDocumentResolver)
+	if err != nil {
+		return nil, nil, err
+	}
+	scope = validateAuthPolicy(ctx, scope)
+	return scope, reconciler.ControllerResources(scope), nil
+}
+
 func resolveAuthPolicy(
 	ctx context.Context,
 	k8sClient client.Client,
@@ -211,66 +331,169 @@ func resolveAuthPolicy(
 	}
 	rLog.Info(fmt.Sprintf("Trying to resolve auth policy %s/%s", authPolicy.Namespace, authPolicy.Name))
 
+	clusterAuthPolicies, err := resolver.ResolveClusterAuthPolicies(ctx, k8sClient, authPolicy)
+	if err != nil {
+		return nil, err
+	}
+	authPolicy, ruleConflicts := resolver.MergeClusterAuthPolicies(authPolicy, clusterAuthPolicies)
+	clusterAuthPolicyNames := make([]string, 0, len(clusterAuthPolicies))
+	defaultDeny := false
+	for _, clusterAuthPolicy := range clusterAuthPolicies {
+		clusterAuthPolicyNames = append(clusterAuthPolicyNames, clusterAuthPolicy.Name)
+		defaultDeny = defaultDeny || clusterAuthPolicy.Spec.DefaultDeny
+	}
+
+	identityProviderRefs, err := resolver.ResolveIdentityProviderRefs(ctx, k8sClient, authPolicy)
+	if err != nil {
+		return nil, err
+	}
+	authPolicy = resolver.MergeIdentityProviderDefaults(authPolicy, identityProviderRefs)
+
 	oAuthCredentials, err := resolver.ResolveOAuthCredentials(ctx, k8sClient, authPolicy)
 	if err != nil {
 		return nil, err
 	}
 
-	rLog.Info(
-		fmt.Sprintf(
-			"Trying to resolve discovery document from well-known uri: %s for AuthPolicy with name %s/%s",
-			authPolicy.Spec.WellKnownURI,
+	identityProviderUris := &state.IdentityProviderUris{}
+	resolvedAudiences := &[]string{}
+	var resolvedJWKS *string
+	if authPolicy.HasDefaultIdentityProvider() {
+		var errIdentityProviderUris error
+		if authPolicy.Spec.IdentityProviderRef != nil {
+			identityProviderUris, errIdentityProviderUris = resolver.ResolveIdentityProviderRef(
+				authPolicy,
+				identityProviderRefs[*authPolicy.Spec.IdentityProviderRef],
+				authPolicy.Spec.AutoLogin != nil && authPolicy.Spec.AutoLogin.Enabled,
+			)
+		} else {
+			rLog.Info(
+				fmt.Sprintf(
+					"Trying to resolve discovery document from well-known uri: %s for AuthPolicy with name %s/%s",
+					authPolicy.Spec.WellKnownURI,
+					authPolicy.Namespace,
+					authPolicy.Name,
+				),
+			)
+			identityProviderUris, errIdentityProviderUris = resolver.ResolveDiscoveryDocument(
+				ctx,
+				authPolicy,
+				discoveryDocumentResolver,
+			)
+		}
+		if errIdentityProviderUris != nil {
+			return nil, errIdentityProviderUris
+		}
+
+		var errAudiences error
+		resolvedAudiences, errAudiences = resolver.ResolveAudiences(
+			ctx,
+			k8sClient,
 			authPolicy.Namespace,
-			authPolicy.Name,
-		),
-	)
-	var identityProviderUris, errIdentityProviderUris = resolver.ResolveDiscoveryDocument(
+			authPolicy.Spec.AllowedAudiences,
+		)
+		if errAudiences != nil {
+			return nil, fmt.Errorf("failed to resolve audiences: %w", errAudiences)
+		}
+
+		var errJWKS error
+		resolvedJWKS, errJWKS = resolver.ResolveJWKS(ctx, k8sClient, authPolicy.Namespace, authPolicy.Spec.Jwks)
+		if errJWKS != nil {
+			return nil, fmt.Errorf("failed to resolve jwks: %w", errJWKS)
+		}
+	}
+
+	identityProviders, errIdentityProviders := resolver.ResolveIdentityProviders(
 		ctx,
+		k8sClient,
 		authPolicy,
+		identityProviderRefs,
 		discoveryDocumentResolver,
 	)
-	if errIdentityProviderUris != nil {
-		return nil, errIdentityProviderUris
+	if errIdentityProviders != nil {
+		return nil, errIdentityProviders
 	}
 
-	autoLoginConfig := resolver.ResolveAutoLoginConfig(authPolicy, *identityProviderUris)
+	signalHeaderSuffix, err := resolver.ResolveSignalHeaderSuffix(ctx, k8sClient, authPolicy)
+	if err != nil {
+		return nil, err
+	}
+	autoLoginConfig := resolver.ResolveAutoLoginConfig(authPolicy, *identityProviderUris, signalHeaderSuffix)
 
-	resolvedAudiences, errAudiences := resolver.ResolveAudiences(
-		ctx,
-		k8sClient,
-		authPolicy.Namespace,
-		authPolicy.Spec.AllowedAudiences,
-	)
-	if errAudiences != nil {
-		return nil, fmt.Errorf("failed to resolve audiences: %w", errAudiences)
+	gateways, err := resolver.ResolveGateways(ctx, k8sClient, authPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	overlappingAuthPolicy, err := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, authPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	// A disabled AuthPolicy is only exempt from the default deny if no applied AuthPolicy shares its pods
+	var sharingAuthPolicy *string
+	if defaultDeny && !authPolicy.Spec.Enabled {
+		sharingAuthPolicy, err = resolver.ResolveAuthPolicySharingPods(ctx, k8sClient, authPolicy)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	rLog.Info(fmt.Sprintf("Successfully resolved AuthPolicy with name %s/%s", authPolicy.Namespace, authPolicy.Name))
 
 	return &state.Scope{
-		Audiences:            *resolvedAudiences,
-		AuthPolicy:           *authPolicy,
-		AutoLoginConfig:      autoLoginConfig,
-		OAuthCredentials:     *oAuthCredentials,
-		IdentityProviderUris: *identityProviderUris,
+		Audiences:             *resolvedAudiences,
+		AuthPolicy:            *authPolicy,
+		AutoLoginConfig:       autoLoginConfig,
+		OAuthCredentials:      *oAuthCredentials,
+		IdentityProviderUris:  *identityProviderUris,
+		Jwks:                  resolvedJWKS,
+		IdentityProviders:     identityProviders,
+		IdentityProviderRefs:  identityProviderRefs,
+		ClusterAuthPolicies:   clusterAuthPolicyNames,
+		RuleConflicts:         ruleConflicts,
+		OverlappingAuthPolicy: overlappingAuthPolicy,
+		DefaultDeny:           defaultDeny,
+		SharingAuthPolicy:     sharingAuthPolicy,
+		Gateways:              gateways,
 	}, nil
 }
 
 func validateAuthPolicy(ctx context.Context, scope *state.Scope) *state.Scope {
 	rLog := log.GetLogger(ctx)
 
-	rLog.Debug("Validating paths for AuthPolicy", "namespace", scope.AuthPolicy.Namespace, "name", scope.AuthPolicy.Name)
-	if err := validation.ValidatePaths(scope.AuthPolicy.GetPaths()); err != nil {
-		rLog.Error(
-			err,
-			"path validation failed for AuthPolicy",
+	authPolicyValidations := append(
+		slices.Clone(validation.AuthPolicyValidations),
+		validation.AuthPolicyValidation{
+			Description: "identity provider requirements",
+			Validate: func(authPolicy ztoperatorv1alpha1.AuthPolicy) error {
+				return validation.ValidateIdentityProviderRequirements(authPolicy, scope.IdentityProviderRefs)
+			},
+		},
+		validation.AuthPolicyValidation{
+			Description: "auto-login gateways",
+			Validate: func(authPolicy ztoperatorv1alpha1.AuthPolicy) error {
+				return validation.ValidateAutoLoginGateways(authPolicy, scope.Gateways)
+			},
+		},
+	)
+	for _, v := range authPolicyValidations {
+		rLog.Debug(
+			fmt.Sprintf("Validating %s for AuthPolicy", v.Description),
 			"namespace", scope.AuthPolicy.Namespace,
 			"name", scope.AuthPolicy.Name,
 		)
-		scope.InvalidConfig = true
-		validationErrorMessage := err.Error()
-		scope.ValidationErrorMessage = &validationErrorMessage
-		return scope
+		if err := v.Validate(scope.AuthPolicy); err != nil {
+			rLog.Error(
+				err,
+				fmt.Sprintf("%s validation failed for AuthPolicy", v.Description),
+				"namespace", scope.AuthPolicy.Namespace,
+				"name", scope.AuthPolicy.Name,
+			)
+			scope.InvalidConfig = true
+			validationErrorMessage := err.Error()
+			scope.ValidationErrorMessage = &validationErrorMessage
+			return scope
+		}
 	}
 
 	scope.InvalidConfig = false
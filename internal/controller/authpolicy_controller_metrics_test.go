@@ -92,7 +92,7 @@ var _ = Describe("AuthPolicy Controller", func() {
 			Spec: ztoperatorv1alpha1.AuthPolicySpec{
 				Enabled:      true,
 				WellKnownURI: wellKnownURI,
-				Selector: ztoperatorv1alpha1.WorkloadSelector{
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
 					MatchLabels: map[string]string{
 						"app": "test-app",
 					},
@@ -150,7 +150,7 @@ var _ = Describe("AuthPolicy Controller", func() {
 			Spec: ztoperatorv1alpha1.AuthPolicySpec{
 				Enabled:      true,
 				WellKnownURI: wellKnownURI,
-				Selector: ztoperatorv1alpha1.WorkloadSelector{
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
 					MatchLabels: map[string]string{
 						"app": "test-app-with-pod",
 					},
@@ -184,7 +184,7 @@ var _ = Describe("AuthPolicy Controller", func() {
 			Spec: ztoperatorv1alpha1.AuthPolicySpec{
 				Enabled:      true,
 				WellKnownURI: wellKnownURI,
-				Selector: ztoperatorv1alpha1.WorkloadSelector{
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
 					MatchLabels: map[string]string{
 						"app": "test-app-delete",
 					},
@@ -7,6 +7,7 @@ import (
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/controller"
 	"github.com/kartverket/ztoperator/internal/names"
+	"github.com/kartverket/ztoperator/internal/statusmanager"
 	"github.com/kartverket/ztoperator/pkg/helperfunctions"
 	"github.com/kartverket/ztoperator/pkg/log"
 	"github.com/kartverket/ztoperator/pkg/rest"
@@ -16,6 +17,7 @@ import (
 	securityv1 "istio.io/client-go/pkg/apis/security/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaapi "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -78,7 +80,7 @@ var _ = Describe("AuthPolicy Controller Reconcile", func() {
 			Spec: ztoperatorv1alpha1.AuthPolicySpec{
 				Enabled:      true,
 				WellKnownURI: "https://idp.example.com/.well-known/openid-configuration",
-				Selector: ztoperatorv1alpha1.WorkloadSelector{
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
 					MatchLabels: map[string]string{"app": appName},
 				},
 			},
@@ -152,6 +154,187 @@ var _ = Describe("AuthPolicy Controller Reconcile", func() {
 		})
 	})
 
+	Context("when a ClusterAuthPolicy applies to the namespace", func() {
+		It("merges its rules, reports effective rules and conflicts, and generates policies for them", func() {
+			By("creating the namespace and a ClusterAuthPolicy")
+			Expect(fakeClient.Create(testCtx, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: map[string]string{"tier": "prod"}},
+			})).To(Succeed())
+			Expect(fakeClient.Create(testCtx, &ztoperatorv1alpha1.ClusterAuthPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "platform"},
+				Spec: ztoperatorv1alpha1.ClusterAuthPolicySpec{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}},
+					BaselineAuth: &ztoperatorv1alpha1.BaselineAuth{
+						Claims: []ztoperatorv1alpha1.Condition{{Claim: "tenant", Values: []string{"kartverket"}}},
+					},
+					AuthRules: &[]ztoperatorv1alpha1.RequestAuthRule{
+						{RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/admin"}}},
+					},
+					IgnoreAuthRules: &[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/public"}}},
+				},
+			})).To(Succeed())
+
+			By("ignoring the path required by the ClusterAuthPolicy in the AuthPolicy")
+			authPolicy := &ztoperatorv1alpha1.AuthPolicy{}
+			Expect(fakeClient.Get(testCtx, types.NamespacedName{Name: appName, Namespace: namespace}, authPolicy)).To(Succeed())
+			authPolicy.Spec.IgnoreAuthRules = &[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/admin", "/public"}}}
+			Expect(fakeClient.Update(testCtx, authPolicy)).To(Succeed())
+
+			By("reconciling the AuthPolicy")
+			_, err := reconciler.Reconcile(testCtx, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: appName, Namespace: namespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("verifying the status shows the merged rules and the conflict")
+			updatedPolicy := &ztoperatorv1alpha1.AuthPolicy{}
+			Expect(fakeClient.Get(testCtx, types.NamespacedName{Name: appName, Namespace: namespace}, updatedPolicy)).To(Succeed())
+			Expect(updatedPolicy.Status.Phase).To(Equal(ztoperatorv1alpha1.PhaseReady))
+			Expect(updatedPolicy.Status.ClusterAuthPolicies).To(Equal([]string{"platform"}))
+			Expect(updatedPolicy.Status.EffectiveRules).NotTo(BeNil())
+			Expect(updatedPolicy.Status.EffectiveRules.BaselineAuth.Claims).To(HaveLen(1))
+			Expect(updatedPolicy.Status.EffectiveRules.AuthRules).To(HaveLen(1))
+			Expect(updatedPolicy.Status.EffectiveRules.IgnoreAuthRules).To(Equal(
+				[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/public"}}, {Paths: []string{"/public"}}},
+			))
+			Expect(updatedPolicy.Status.Conflicts).To(HaveLen(1))
+			Expect(updatedPolicy.Status.Conflicts[0].ClusterAuthPolicy).To(Equal("platform"))
+
+			By("verifying the spec of the AuthPolicy is left untouched")
+			Expect(updatedPolicy.Spec.BaselineAuth).To(BeNil())
+			Expect(updatedPolicy.Spec.AuthRules).To(BeNil())
+
+			By("verifying the deny policy enforces the merged auth rule")
+			denyPolicy := &securityv1.AuthorizationPolicy{}
+			Expect(fakeClient.Get(testCtx, types.NamespacedName{
+				Name:      names.DenyPolicy(appName),
+				Namespace: namespace,
+			}, denyPolicy)).To(Succeed())
+			Expect(denyPolicy.Spec.GetRules()).NotTo(BeEmpty())
+			Expect(denyPolicy.Spec.GetRules()[0].GetTo()[0].GetOperation().GetPaths()).To(ContainElement("/admin"))
+		})
+	})
+
+	Context("when an AuthPolicy taking precedence shares a single pod", func() {
+		It("reports the conflict, and generates nothing for the refused AuthPolicy", func() {
+			By("letting the refused AuthPolicy ignore all paths")
+			authPolicy := &ztoperatorv1alpha1.AuthPolicy{}
+			Expect(fakeClient.Get(testCtx, types.NamespacedName{Name: appName, Namespace: namespace}, authPolicy)).To(Succeed())
+			authPolicy.Spec.IgnoreAuthRules = &[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/**"}}}
+			Expect(fakeClient.Update(testCtx, authPolicy)).To(Succeed())
+
+			By("creating an older AuthPolicy and pods of which only one is selected by both")
+			Expect(fakeClient.Create(testCtx, &ztoperatorv1alpha1.AuthPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "a-older", Namespace: namespace},
+				Spec: ztoperatorv1alpha1.AuthPolicySpec{
+					Enabled:      true,
+					WellKnownURI: "https://idp.example.com/.well-known/openid-configuration",
+					Selector:     &ztoperatorv1alpha1.WorkloadSelector{MatchLabels: map[string]string{"tier": "web"}},
+				},
+			})).To(Succeed())
+			Expect(fakeClient.Create(testCtx, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "shared",
+					Namespace: namespace,
+					Labels:    map[string]string{"app": appName, "tier": "web"},
+				},
+			})).To(Succeed())
+			Expect(fakeClient.Create(testCtx, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "unshared",
+					Namespace: namespace,
+					Labels:    map[string]string{"app": appName},
+				},
+			})).To(Succeed())
+
+			By("reconciling the AuthPolicy")
+			_, err := reconciler.Reconcile(testCtx, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: appName, Namespace: namespace},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("verifying the AuthPolicy is refused")
+			updatedPolicy := &ztoperatorv1alpha1.AuthPolicy{}
+			Expect(fakeClient.Get(testCtx, types.NamespacedName{Name: appName, Namespace: namespace}, updatedPolicy)).To(Succeed())
+			conflictCondition := metaapi.FindStatusCondition(
+				updatedPolicy.Status.Conditions,
+				statusmanager.ConflictConditionType,
+			)
+			Expect(conflictCondition).NotTo(BeNil())
+			Expect(conflictCondition.Message).To(ContainSubstring("a-older"))
+
+			By("verifying no RequestAuthentication or AuthorizationPolicies are generated")
+			raErr := fakeClient.Get(
+				testCtx,
+				types.NamespacedName{Name: appName, Namespace: namespace},
+				&securityv1.RequestAuthentication{},
+			)
+			Expect(apierrors.IsNotFound(raErr)).To(BeTrue())
+			for _, name := range []string{
+				names.RequirePolicy(appName),
+				names.IgnorePolicy(appName),
+				names.DenyPolicy(appName),
+			} {
+				apErr := fakeClient.Get(
+					testCtx,
+					types.NamespacedName{Name: name, Namespace: namespace},
+					&securityv1.AuthorizationPolicy{},
+				)
+				Expect(apierrors.IsNotFound(apErr)).To(BeTrue(), name)
+			}
+		})
+	})
+
+	Context("when the JWKS is given by a ConfigMap", func() {
+		It("renders the keys inline, follows key rotation, and never rolls out an invalid JWKS", func() {
+			const (
+				jwksKeyOne = `{"keys":[{"kid":"key-1","kty":"RSA","alg":"RS256","n":"AQAB","e":"AQAB"}]}`
+				jwksKeyTwo = `{"keys":[{"kid":"key-2","kty":"EC","alg":"ES256","crv":"P-256","x":"eA","y":"eQ"}]}`
+			)
+			request := ctrl.Request{NamespacedName: types.NamespacedName{Name: appName, Namespace: namespace}}
+
+			By("creating the ConfigMap and referencing it from the AuthPolicy")
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "jwks", Namespace: namespace},
+				Data:       map[string]string{"jwks.json": jwksKeyOne},
+			}
+			Expect(fakeClient.Create(testCtx, configMap)).To(Succeed())
+			authPolicy := &ztoperatorv1alpha1.AuthPolicy{}
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, authPolicy)).To(Succeed())
+			authPolicy.Spec.Jwks = &ztoperatorv1alpha1.Jwks{
+				ValueFrom: &ztoperatorv1alpha1.ValueFrom{
+					ConfigMapKeyRef: &ztoperatorv1alpha1.KeyRef{Name: "jwks", Key: "jwks.json"},
+				},
+			}
+			Expect(fakeClient.Update(testCtx, authPolicy)).To(Succeed())
+
+			By("verifying the JWKS is rendered instead of the JWKS URI")
+			_, err := reconciler.Reconcile(testCtx, request)
+			Expect(err).NotTo(HaveOccurred())
+			ra := &securityv1.RequestAuthentication{}
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, ra)).To(Succeed())
+			Expect(ra.Spec.GetJwtRules()).To(HaveLen(1))
+			Expect(ra.Spec.GetJwtRules()[0].GetJwks()).To(Equal(jwksKeyOne))
+			Expect(ra.Spec.GetJwtRules()[0].GetJwksUri()).To(BeEmpty())
+
+			By("rotating the keys in the ConfigMap")
+			configMap.Data["jwks.json"] = jwksKeyTwo
+			Expect(fakeClient.Update(testCtx, configMap)).To(Succeed())
+			_, err = reconciler.Reconcile(testCtx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, ra)).To(Succeed())
+			Expect(ra.Spec.GetJwtRules()[0].GetJwks()).To(Equal(jwksKeyTwo))
+
+			By("replacing the keys with an invalid JWKS")
+			configMap.Data["jwks.json"] = `{"keys":[]}`
+			Expect(fakeClient.Update(testCtx, configMap)).To(Succeed())
+			_, err = reconciler.Reconcile(testCtx, request)
+			Expect(err).To(MatchError(ContainSubstring("failed to resolve jwks: JWKS must contain at least one key")))
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, ra)).To(Succeed())
+			Expect(ra.Spec.GetJwtRules()[0].GetJwks()).To(Equal(jwksKeyTwo))
+		})
+	})
+
 	Context("when the discovery document resolver returns an error", func() {
 		It("returns the error, sets status to Failed, and does not create child resources", func() {
 			By("configuring the resolver to return an error")
@@ -185,4 +368,95 @@ var _ = Describe("AuthPolicy Controller Reconcile", func() {
 			)).To(BeTrue())
 		})
 	})
+
+	Context("in KeepLastKnownGood failure mode", func() {
+		var request ctrl.Request
+
+		BeforeEach(func() {
+			request = ctrl.Request{NamespacedName: types.NamespacedName{Name: appName, Namespace: namespace}}
+
+			By("applying a valid configuration")
+			authPolicy := &ztoperatorv1alpha1.AuthPolicy{}
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, authPolicy)).To(Succeed())
+			authPolicy.Spec.FailureMode = ztoperatorv1alpha1.FailureModeKeepLastKnownGood
+			Expect(fakeClient.Update(testCtx, authPolicy)).To(Succeed())
+			_, err := reconciler.Reconcile(testCtx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, authPolicy)).To(Succeed())
+			Expect(authPolicy.Status.Phase).To(Equal(ztoperatorv1alpha1.PhaseReady))
+			Expect(authPolicy.Status.LastKnownGood).NotTo(BeNil())
+			Expect(authPolicy.Status.LastKnownGood.Issuer).To(Equal("https://idp.example.com"))
+			Expect(authPolicy.Status.LastKnownGood.JwksURI).To(Equal("https://idp.example.com/jwks"))
+		})
+
+		expectLastKnownGoodResources := func() {
+			ra := &securityv1.RequestAuthentication{}
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, ra)).To(Succeed())
+			Expect(ra.Spec.GetJwtRules()[0].GetJwksUri()).To(Equal("https://idp.example.com/jwks"))
+
+			requirePolicy := &securityv1.AuthorizationPolicy{}
+			Expect(fakeClient.Get(testCtx, types.NamespacedName{
+				Name:      names.RequirePolicy(appName),
+				Namespace: namespace,
+			}, requirePolicy)).To(Succeed())
+
+			denyPolicy := &securityv1.AuthorizationPolicy{}
+			denyErr := fakeClient.Get(testCtx, types.NamespacedName{Name: names.DenyPolicy(appName), Namespace: namespace}, denyPolicy)
+			Expect(apierrors.IsNotFound(denyErr)).To(BeTrue(), "no deny-all policy should be generated")
+		}
+
+		expectDegraded := func(reason string, message string) {
+			authPolicy := &ztoperatorv1alpha1.AuthPolicy{}
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, authPolicy)).To(Succeed())
+			Expect(authPolicy.Status.Phase).To(Equal(ztoperatorv1alpha1.PhaseDegraded))
+			Expect(authPolicy.Status.Ready).To(BeFalse())
+			Expect(authPolicy.Status.Message).To(ContainSubstring(message))
+			Expect(authPolicy.Status.Message).To(ContainSubstring("Keeping the resources applied from generation 1."))
+			Expect(authPolicy.Status.LastKnownGood.ObservedGeneration).To(Equal(int64(1)))
+			degraded := metaapi.FindStatusCondition(authPolicy.Status.Conditions, statusmanager.DegradedConditionType)
+			Expect(degraded).NotTo(BeNil())
+			Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal(reason))
+		}
+
+		It("keeps the resources untouched on an invalid configuration, and recovers once it is fixed", func() {
+			By("applying an invalid configuration")
+			authPolicy := &ztoperatorv1alpha1.AuthPolicy{}
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, authPolicy)).To(Succeed())
+			authPolicy.Generation = 2
+			authPolicy.Spec.OutputClaimToHeaders = &[]ztoperatorv1alpha1.ClaimToHeader{
+				{Header: "x-ztoperator-bypass-login-abc", Claim: "sub"},
+			}
+			Expect(fakeClient.Update(testCtx, authPolicy)).To(Succeed())
+
+			_, err := reconciler.Reconcile(testCtx, request)
+			Expect(err).NotTo(HaveOccurred())
+			expectLastKnownGoodResources()
+			expectDegraded(statusmanager.DegradedReasonInvalidConfiguration, "must not start with x-ztoperator-")
+
+			By("fixing the configuration")
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, authPolicy)).To(Succeed())
+			authPolicy.Generation = 3
+			authPolicy.Spec.OutputClaimToHeaders = nil
+			Expect(fakeClient.Update(testCtx, authPolicy)).To(Succeed())
+
+			_, err = reconciler.Reconcile(testCtx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeClient.Get(testCtx, request.NamespacedName, authPolicy)).To(Succeed())
+			Expect(authPolicy.Status.Phase).To(Equal(ztoperatorv1alpha1.PhaseReady))
+			Expect(authPolicy.Status.LastKnownGood.ObservedGeneration).To(Equal(int64(3)))
+			Expect(metaapi.FindStatusCondition(authPolicy.Status.Conditions, statusmanager.DegradedConditionType)).To(BeNil())
+		})
+
+		It("keeps the resources untouched, and retries, when the AuthPolicy cannot be resolved", func() {
+			resolveErr := errors.New("discovery resolver failed")
+			reconciler.DiscoveryDocumentResolver = &fakeDiscoveryDocumentResolver{err: resolveErr}
+
+			_, err := reconciler.Reconcile(testCtx, request)
+			Expect(err).To(MatchError(ContainSubstring(resolveErr.Error())))
+			expectLastKnownGoodResources()
+			expectDegraded(statusmanager.DegradedReasonResolutionFailed, resolveErr.Error())
+		})
+	})
 })
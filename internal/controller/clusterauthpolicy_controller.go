@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/eventhandler/authpolicy"
+	"github.com/kartverket/ztoperator/internal/eventhandler/namespace"
+	"github.com/kartverket/ztoperator/internal/names"
+	"github.com/kartverket/ztoperator/internal/reconciler"
+	"github.com/kartverket/ztoperator/pkg/labels"
+	"github.com/kartverket/ztoperator/pkg/log"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/defaultdeny"
+	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	ClusterAuthPolicyConditionReady      = "Ready"
+	ClusterAuthPolicyConditionConflicted = "Conflicted"
+)
+
+// ClusterAuthPolicyReconciler reconciles the default deny AuthorizationPolicies and the status of a ClusterAuthPolicy
+// object. The ClusterAuthPolicy itself is merged into AuthPolicies by the AuthPolicyReconciler,
+// which reports the outcome in the status of each AuthPolicy.
+type ClusterAuthPolicyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterAuthPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(
+			&ztoperatorv1alpha1.ClusterAuthPolicy{},
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Owns(&istioclientsecurityv1.AuthorizationPolicy{}).
+		Watches(&ztoperatorv1alpha1.AuthPolicy{}, authpolicy.EventHandler(r.Client)).
+		Watches(
+			&v1.Namespace{},
+			namespace.ClusterAuthPolicyEventHandler(r.Client),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}),
+		).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=ztoperator.kartverket.no,resources=clusterauthpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=ztoperator.kartverket.no,resources=clusterauthpolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=security.istio.io,resources=authorizationpolicies,verbs=get;list;watch;create;update;patch;delete
+
+func (r *ClusterAuthPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	rLog := log.GetLogger(ctx)
+
+	clusterAuthPolicy := new(ztoperatorv1alpha1.ClusterAuthPolicy)
+	if err := r.Get(ctx, req.NamespacedName, clusterAuthPolicy); err != nil {
+		if apierrors.IsNotFound(err) {
+			rLog.Debug(fmt.Sprintf("ClusterAuthPolicy with name %s not found. Probably a delete.", req.Name))
+			return reconcile.Result{}, nil
+		}
+		rLog.Error(err, fmt.Sprintf("Failed to get ClusterAuthPolicy with name %s", req.Name))
+		return reconcile.Result{}, err
+	}
+
+	defaultDenyNamespaces, err := r.reconcileDefaultDeny(ctx, clusterAuthPolicy)
+	if err != nil {
+		rLog.Error(err, fmt.Sprintf("Failed to reconcile default deny of ClusterAuthPolicy with name %s", req.Name))
+		return reconcile.Result{}, err
+	}
+
+	authPolicyList := &ztoperatorv1alpha1.AuthPolicyList{}
+	if err := r.List(ctx, authPolicyList); err != nil {
+		rLog.Error(err, "Failed to list AuthPolicies")
+		return reconcile.Result{}, err
+	}
+
+	status := BuildClusterAuthPolicyStatus(clusterAuthPolicy, authPolicyList.Items, defaultDenyNamespaces)
+	if equality.Semantic.DeepEqual(clusterAuthPolicy.Status, status) {
+		return reconcile.Result{}, nil
+	}
+
+	rLog.Debug(fmt.Sprintf("Updating ClusterAuthPolicy status with name %s", req.Name))
+	return reconcile.Result{}, retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		latest := &ztoperatorv1alpha1.ClusterAuthPolicy{}
+		if err := r.Get(ctx, req.NamespacedName, latest); err != nil {
+			return err
+		}
+		latest.Status = status
+		return r.Status().Update(ctx, latest)
+	})
+}
+
+// reconcileDefaultDeny creates the default deny AuthorizationPolicy in every namespace selected by the
+// ClusterAuthPolicy, and deletes it from namespaces which are no longer selected. It returns the sorted names of
+// the namespaces in which requests are denied by default.
+func (r *ClusterAuthPolicyReconciler) reconcileDefaultDeny(
+	ctx context.Context,
+	clusterAuthPolicy *ztoperatorv1alpha1.ClusterAuthPolicy,
+) ([]string, error) {
+	var desiredNamespaces []string
+	if clusterAuthPolicy.Spec.DefaultDeny {
+		namespaceList := &v1.NamespaceList{}
+		if err := r.List(ctx, namespaceList); err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, ns := range namespaceList.Items {
+			if !ns.DeletionTimestamp.IsZero() {
+				continue
+			}
+			matches, err := clusterAuthPolicy.MatchesNamespace(ns.Labels)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate namespace selector: %w", err)
+			}
+			if matches {
+				desiredNamespaces = append(desiredNamespaces, ns.Name)
+			}
+		}
+	}
+
+	currentList := &istioclientsecurityv1.AuthorizationPolicyList{}
+	if err := r.List(ctx, currentList, client.MatchingLabels(labels.ClusterAuthPolicyStandardLabels())); err != nil {
+		return nil, fmt.Errorf("failed to list AuthorizationPolicies: %w", err)
+	}
+	for _, current := range currentList.Items {
+		if !metav1.IsControlledBy(current, clusterAuthPolicy) || slices.Contains(desiredNamespaces, current.Namespace) {
+			continue
+		}
+		if err := r.Delete(ctx, current); client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to delete AuthorizationPolicy %s/%s: %w", current.Namespace, current.Name, err)
+		}
+	}
+
+	for _, ns := range desiredNamespaces {
+		desired := defaultdeny.GetDesired(clusterAuthPolicy, metav1.ObjectMeta{
+			Name:      names.DefaultDenyPolicy(clusterAuthPolicy.Name),
+			Namespace: ns,
+			Labels:    labels.ClusterAuthPolicyStandardLabels(),
+		})
+		if err := r.applyDefaultDenyPolicy(ctx, clusterAuthPolicy, desired); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.Sort(desiredNamespaces)
+	return desiredNamespaces, nil
+}
+
+func (r *ClusterAuthPolicyReconciler) applyDefaultDenyPolicy(
+	ctx context.Context,
+	clusterAuthPolicy *ztoperatorv1alpha1.ClusterAuthPolicy,
+	desired *istioclientsecurityv1.AuthorizationPolicy,
+) error {
+	if err := ctrl.SetControllerReference(clusterAuthPolicy, desired, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set owner of AuthorizationPolicy %s/%s: %w", desired.Namespace, desired.Name, err)
+	}
+
+	current := &istioclientsecurityv1.AuthorizationPolicy{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(desired), current)
+	if apierrors.IsNotFound(err) {
+		if err := r.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create AuthorizationPolicy %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get AuthorizationPolicy %s/%s: %w", desired.Namespace, desired.Name, err)
+	}
+
+	if !metav1.IsControlledBy(current, clusterAuthPolicy) {
+		return fmt.Errorf(
+			"AuthorizationPolicy %s/%s already exists and is not controlled by ClusterAuthPolicy %s",
+			desired.Namespace,
+			desired.Name,
+			clusterAuthPolicy.Name,
+		)
+	}
+	if !reconciler.AuthorizationPolicyShouldUpdate(current, desired) &&
+		current.Spec.GetAction() == desired.Spec.GetAction() {
+		return nil
+	}
+	reconciler.AuthorizationPolicyUpdateFields(current, desired)
+	current.Spec.Action = desired.Spec.GetAction()
+	if err := r.Update(ctx, current); err != nil {
+		return fmt.Errorf("failed to update AuthorizationPolicy %s/%s: %w", desired.Namespace, desired.Name, err)
+	}
+	return nil
+}
+
+// BuildClusterAuthPolicyStatus builds the status of a ClusterAuthPolicy from the status of the AuthPolicies
+// it is merged into and the namespaces in which it denies requests by default.
+func BuildClusterAuthPolicyStatus(
+	clusterAuthPolicy *ztoperatorv1alpha1.ClusterAuthPolicy,
+	authPolicies []ztoperatorv1alpha1.AuthPolicy,
+	defaultDenyNamespaces []string,
+) ztoperatorv1alpha1.ClusterAuthPolicyStatus {
+	status := ztoperatorv1alpha1.ClusterAuthPolicyStatus{
+		ObservedGeneration:    clusterAuthPolicy.GetGeneration(),
+		Conditions:            slices.Clone(clusterAuthPolicy.Status.Conditions),
+		DefaultDenyNamespaces: defaultDenyNamespaces,
+	}
+
+	for _, authPolicy := range authPolicies {
+		if !slices.Contains(authPolicy.Status.ClusterAuthPolicies, clusterAuthPolicy.Name) {
+			continue
+		}
+		status.AuthPolicies = append(status.AuthPolicies, authPolicy.Namespace+"/"+authPolicy.Name)
+		for _, ruleConflict := range authPolicy.Status.Conflicts {
+			if ruleConflict.ClusterAuthPolicy == clusterAuthPolicy.Name {
+				status.Conflicts = append(status.Conflicts, ruleConflict)
+			}
+		}
+	}
+	slices.Sort(status.AuthPolicies)
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ClusterAuthPolicyConditionReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: status.ObservedGeneration,
+		Reason:             "Merged",
+		Message:            fmt.Sprintf("ClusterAuthPolicy is merged into %d AuthPolicies.", len(status.AuthPolicies)),
+	})
+	conflictedCondition := metav1.Condition{
+		Type:               ClusterAuthPolicyConditionConflicted,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: status.ObservedGeneration,
+		Reason:             "NoConflicts",
+		Message:            "No rules were dropped or overridden while merging the ClusterAuthPolicy.",
+	}
+	if len(status.Conflicts) > 0 {
+		conflictedCondition.Status = metav1.ConditionTrue
+		conflictedCondition.Reason = "RulesDropped"
+		conflictedCondition.Message = fmt.Sprintf(
+			"%d rules were dropped or overridden while merging the ClusterAuthPolicy. See .status.conflicts for details.",
+			len(status.Conflicts),
+		)
+	}
+	meta.SetStatusCondition(&status.Conditions, conflictedCondition)
+
+	return status
+}
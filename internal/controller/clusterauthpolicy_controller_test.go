@@ -0,0 +1,158 @@
+package controller_test
+
+import (
+	"context"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/controller"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"istio.io/api/security/v1beta1"
+	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ClusterAuthPolicy Controller Reconcile", func() {
+	var (
+		testCtx    context.Context
+		fakeClient client.Client
+		reconciler *controller.ClusterAuthPolicyReconciler
+	)
+
+	BeforeEach(func() {
+		testCtx = context.Background()
+
+		testScheme := runtime.NewScheme()
+		Expect(ztoperatorv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+		Expect(istioclientsecurityv1.AddToScheme(testScheme)).To(Succeed())
+
+		clusterAuthPolicy := &ztoperatorv1alpha1.ClusterAuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "platform", Generation: 2},
+		}
+		secureClusterAuthPolicy := &ztoperatorv1alpha1.ClusterAuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "secure", UID: "secure-uid"},
+			Spec: ztoperatorv1alpha1.ClusterAuthPolicySpec{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}},
+				DefaultDeny:       true,
+			},
+		}
+		prodNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "team", Labels: map[string]string{"tier": "prod"}},
+		}
+		devNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-team", Labels: map[string]string{"tier": "dev"}},
+		}
+		mergedAuthPolicy := &ztoperatorv1alpha1.AuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "merged", Namespace: "team"},
+			Status: ztoperatorv1alpha1.AuthPolicyStatus{
+				ClusterAuthPolicies: []string{"platform"},
+				Conflicts: []ztoperatorv1alpha1.RuleConflict{
+					{ClusterAuthPolicy: "platform", AuthPolicy: "team/merged", Message: "dropped by platform"},
+					{ClusterAuthPolicy: "other", AuthPolicy: "team/merged", Message: "dropped by other"},
+				},
+			},
+		}
+		unrelatedAuthPolicy := &ztoperatorv1alpha1.AuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "other-team"},
+		}
+
+		fakeClient = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(
+				clusterAuthPolicy,
+				secureClusterAuthPolicy,
+				prodNamespace,
+				devNamespace,
+				mergedAuthPolicy,
+				unrelatedAuthPolicy,
+			).
+			WithStatusSubresource(clusterAuthPolicy, secureClusterAuthPolicy).
+			Build()
+
+		reconciler = &controller.ClusterAuthPolicyReconciler{
+			Client: fakeClient,
+			Scheme: testScheme,
+		}
+	})
+
+	It("reports the AuthPolicies it is merged into and its conflicts", func() {
+		_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "platform"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		updated := &ztoperatorv1alpha1.ClusterAuthPolicy{}
+		Expect(fakeClient.Get(testCtx, types.NamespacedName{Name: "platform"}, updated)).To(Succeed())
+		Expect(updated.Status.ObservedGeneration).To(Equal(int64(2)))
+		Expect(updated.Status.AuthPolicies).To(Equal([]string{"team/merged"}))
+		Expect(updated.Status.Conflicts).To(Equal([]ztoperatorv1alpha1.RuleConflict{
+			{ClusterAuthPolicy: "platform", AuthPolicy: "team/merged", Message: "dropped by platform"},
+		}))
+		Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, controller.ClusterAuthPolicyConditionReady)).To(BeTrue())
+		Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, controller.ClusterAuthPolicyConditionConflicted)).To(BeTrue())
+	})
+
+	It("denies requests by default in the selected namespaces", func() {
+		_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "secure"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		defaultDeny := &istioclientsecurityv1.AuthorizationPolicy{}
+		Expect(fakeClient.Get(
+			testCtx,
+			types.NamespacedName{Name: "secure-default-deny", Namespace: "team"},
+			defaultDeny,
+		)).To(Succeed())
+		Expect(defaultDeny.Spec.GetAction()).To(Equal(v1beta1.AuthorizationPolicy_ALLOW))
+		Expect(defaultDeny.Spec.GetSelector()).To(BeNil())
+		Expect(defaultDeny.Spec.GetRules()).To(BeEmpty())
+		Expect(defaultDeny.OwnerReferences).To(HaveLen(1))
+		Expect(defaultDeny.OwnerReferences[0].Name).To(Equal("secure"))
+
+		err = fakeClient.Get(
+			testCtx,
+			types.NamespacedName{Name: "secure-default-deny", Namespace: "other-team"},
+			&istioclientsecurityv1.AuthorizationPolicy{},
+		)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		updated := &ztoperatorv1alpha1.ClusterAuthPolicy{}
+		Expect(fakeClient.Get(testCtx, types.NamespacedName{Name: "secure"}, updated)).To(Succeed())
+		Expect(updated.Status.DefaultDenyNamespaces).To(Equal([]string{"team"}))
+	})
+
+	It("removes the default deny from namespaces which are no longer selected", func() {
+		_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "secure"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		prodNamespace := &corev1.Namespace{}
+		Expect(fakeClient.Get(testCtx, types.NamespacedName{Name: "team"}, prodNamespace)).To(Succeed())
+		prodNamespace.Labels = map[string]string{"tier": "dev"}
+		Expect(fakeClient.Update(testCtx, prodNamespace)).To(Succeed())
+
+		_, err = reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "secure"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		err = fakeClient.Get(
+			testCtx,
+			types.NamespacedName{Name: "secure-default-deny", Namespace: "team"},
+			&istioclientsecurityv1.AuthorizationPolicy{},
+		)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		updated := &ztoperatorv1alpha1.ClusterAuthPolicy{}
+		Expect(fakeClient.Get(testCtx, types.NamespacedName{Name: "secure"}, updated)).To(Succeed())
+		Expect(updated.Status.DefaultDenyNamespaces).To(BeEmpty())
+	})
+
+	It("ignores ClusterAuthPolicies that no longer exist", func() {
+		_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "deleted"}})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/config"
+	"github.com/kartverket/ztoperator/pkg/log"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// IdentityProviderReconciler reconciles the status of an IdentityProvider object.
+// It periodically fetches the discovery document and JWKS of the identity provider, and reports the resolved
+// endpoints in the status, which is read by the AuthPolicyReconciler for AuthPolicies referencing it.
+type IdentityProviderReconciler struct {
+	client.Client
+	Scheme                    *runtime.Scheme
+	DiscoveryDocumentResolver rest.DiscoveryDocumentResolver
+	JWKSResolver              rest.JWKSResolver
+
+	mu                sync.Mutex
+	caBundleResolvers map[string]*caBundleResolvers
+}
+
+// caBundleResolvers are the resolvers used for an IdentityProvider with a CA bundle. They are kept between reconciles,
+// so that discovery documents are cached and connections are reused, until the CA bundle changes.
+type caBundleResolvers struct {
+	caBundle                  string
+	discoveryDocumentResolver *rest.DefaultDiscoveryDocumentResolver
+	staticResolver            rest.DiscoveryDocumentResolver
+	jwksResolver              *rest.DefaultJWKSResolver
+}
+
+func (c *caBundleResolvers) close() {
+	_ = c.discoveryDocumentResolver.Close()
+	_ = c.jwksResolver.Close()
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IdentityProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(
+			&ztoperatorv1alpha1.IdentityProvider{},
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=ztoperator.kartverket.no,resources=identityproviders,verbs=get;list;watch
+// +kubebuilder:rbac:groups=ztoperator.kartverket.no,resources=identityproviders/status,verbs=get;update;patch
+
+func (r *IdentityProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	rLog := log.GetLogger(ctx)
+
+	identityProvider := new(ztoperatorv1alpha1.IdentityProvider)
+	if err := r.Get(ctx, req.NamespacedName, identityProvider); err != nil {
+		if apierrors.IsNotFound(err) {
+			rLog.Debug(fmt.Sprintf("IdentityProvider with name %s not found. Probably a delete.", req.Name))
+			r.removeCABundleResolvers(req.Name)
+			return reconcile.Result{}, nil
+		}
+		rLog.Error(err, fmt.Sprintf("Failed to get IdentityProvider with name %s", req.Name))
+		return reconcile.Result{}, err
+	}
+
+	identityProviderUris, keyIDs, err := r.checkIdentityProvider(ctx, identityProvider)
+	if err != nil {
+		rLog.Info(fmt.Sprintf("IdentityProvider with name %s is not reachable: %s", req.Name, err))
+	}
+
+	result := reconcile.Result{RequeueAfter: config.Get().IdentityProviderHealthCheckInterval}
+	status := BuildIdentityProviderStatus(identityProvider, identityProviderUris, keyIDs, err)
+	if equality.Semantic.DeepEqual(identityProvider.Status, status) {
+		return result, nil
+	}
+
+	rLog.Debug(fmt.Sprintf("Updating IdentityProvider status with name %s", req.Name))
+	return result, retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		latest := &ztoperatorv1alpha1.IdentityProvider{}
+		if err := r.Get(ctx, req.NamespacedName, latest); err != nil {
+			return err
+		}
+		latest.Status = status
+		return r.Status().Update(ctx, latest)
+	})
+}
+
+func (r *IdentityProviderReconciler) checkIdentityProvider(
+	ctx context.Context,
+	identityProvider *ztoperatorv1alpha1.IdentityProvider,
+) (*state.IdentityProviderUris, []string, error) {
+	discoveryDocumentResolver, jwksResolver := r.DiscoveryDocumentResolver, r.JWKSResolver
+	if identityProvider.Spec.CABundle != "" {
+		resolvers, err := r.getCABundleResolvers(identityProvider.Name, identityProvider.Spec.CABundle)
+		if err != nil {
+			return nil, nil, err
+		}
+		discoveryDocumentResolver, jwksResolver = resolvers.staticResolver, resolvers.jwksResolver
+	} else {
+		r.removeCABundleResolvers(identityProvider.Name)
+	}
+
+	identityProviderUris, err := resolver.ResolveIdentityProviderDiscoveryDocument(
+		ctx,
+		identityProvider,
+		discoveryDocumentResolver,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jwks, err := jwksResolver.GetJWKS(identityProviderUris.JwksURI, log.GetLogger(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get JWKS from %s: %w", identityProviderUris.JwksURI, err)
+	}
+	keyIDs := make([]string, 0, len(jwks.Keys))
+	for _, key := range jwks.Keys {
+		if key.KeyID != "" {
+			keyIDs = append(keyIDs, key.KeyID)
+		}
+	}
+	slices.Sort(keyIDs)
+
+	return identityProviderUris, keyIDs, nil
+}
+
+// getCABundleResolvers returns the resolvers trusting the CA bundle of the IdentityProvider, creating them if the
+// IdentityProvider has not been checked with the CA bundle before.
+func (r *IdentityProviderReconciler) getCABundleResolvers(name string, caBundle string) (*caBundleResolvers, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if resolvers, ok := r.caBundleResolvers[name]; ok {
+		if resolvers.caBundle == caBundle {
+			return resolvers, nil
+		}
+		resolvers.close()
+		delete(r.caBundleResolvers, name)
+	}
+
+	discoveryDocumentResolver, err := rest.NewDiscoveryDocumentResolverWithCABundle(caBundle)
+	if err != nil {
+		return nil, err
+	}
+	staticResolver, err := rest.NewStaticDiscoveryDocumentResolver(r.Client, discoveryDocumentResolver)
+	if err != nil {
+		_ = discoveryDocumentResolver.Close()
+		return nil, err
+	}
+	jwksResolver, err := rest.NewJWKSResolverWithCABundle(caBundle)
+	if err != nil {
+		_ = discoveryDocumentResolver.Close()
+		return nil, err
+	}
+
+	resolvers := &caBundleResolvers{
+		caBundle:                  caBundle,
+		discoveryDocumentResolver: discoveryDocumentResolver,
+		staticResolver:            staticResolver,
+		jwksResolver:              jwksResolver,
+	}
+	if r.caBundleResolvers == nil {
+		r.caBundleResolvers = map[string]*caBundleResolvers{}
+	}
+	r.caBundleResolvers[name] = resolvers
+	return resolvers, nil
+}
+
+// removeCABundleResolvers closes and forgets the resolvers of an IdentityProvider which no longer has a CA bundle.
+func (r *IdentityProviderReconciler) removeCABundleResolvers(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if resolvers, ok := r.caBundleResolvers[name]; ok {
+		resolvers.close()
+		delete(r.caBundleResolvers, name)
+	}
+}
+
+// BuildIdentityProviderStatus builds the status of an IdentityProvider from the outcome of fetching its discovery
+// document and JWKS. The endpoints of the previous status are kept while the identity provider is unreachable, unless
+// they were resolved for a previous generation, as they may no longer match the spec.
+func BuildIdentityProviderStatus(
+	identityProvider *ztoperatorv1alpha1.IdentityProvider,
+	identityProviderUris *state.IdentityProviderUris,
+	keyIDs []string,
+	checkErr error,
+) ztoperatorv1alpha1.IdentityProviderStatus {
+	status := *identityProvider.Status.DeepCopy()
+	status.ObservedGeneration = identityProvider.GetGeneration()
+
+	if checkErr != nil {
+		if identityProvider.Status.ObservedGeneration != identityProvider.GetGeneration() {
+			status.Issuer = ""
+			status.JwksURI = ""
+			status.TokenEndpoint = ""
+			status.AuthorizationEndpoint = ""
+			status.EndSessionEndpoint = ""
+			status.KeyIDs = nil
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               ztoperatorv1alpha1.IdentityProviderConditionReachable,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: status.ObservedGeneration,
+			Reason:             "Unreachable",
+			Message:            checkErr.Error(),
+		})
+		return status
+	}
+
+	status.Issuer = identityProviderUris.IssuerURI
+	status.JwksURI = identityProviderUris.JwksURI
+	status.TokenEndpoint = identityProviderUris.TokenURI
+	status.AuthorizationEndpoint = identityProviderUris.AuthorizationURI
+	status.EndSessionEndpoint = ""
+	if identityProviderUris.EndSessionURI != nil {
+		status.EndSessionEndpoint = *identityProviderUris.EndSessionURI
+	}
+	status.KeyIDs = keyIDs
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ztoperatorv1alpha1.IdentityProviderConditionReachable,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: status.ObservedGeneration,
+		Reason:             "Reachable",
+		Message:            "Discovery document and JWKS were fetched successfully.",
+	})
+	return status
+}
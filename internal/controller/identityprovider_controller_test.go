@@ -0,0 +1,186 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/controller"
+	"github.com/kartverket/ztoperator/pkg/log"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeJWKSResolver struct {
+	jwks *rest.JWKS
+	err  error
+}
+
+func (f *fakeJWKSResolver) GetJWKS(_ string, _ log.Logger) (*rest.JWKS, error) {
+	return f.jwks, f.err
+}
+
+var _ = Describe("IdentityProvider Controller Reconcile", func() {
+	var (
+		testCtx            context.Context
+		fakeClient         client.Client
+		reconciler         *controller.IdentityProviderReconciler
+		discoveryResolver  *fakeDiscoveryDocumentResolver
+		jwksResolver       *fakeJWKSResolver
+		identityProviderID = types.NamespacedName{Name: "idporten"}
+	)
+
+	BeforeEach(func() {
+		testCtx = context.Background()
+
+		testScheme := runtime.NewScheme()
+		Expect(ztoperatorv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+		identityProvider := &ztoperatorv1alpha1.IdentityProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: "idporten", Generation: 3},
+			Spec: ztoperatorv1alpha1.IdentityProviderSpec{
+				WellKnownURI: "https://idp.example.com/.well-known/openid-configuration",
+			},
+		}
+
+		fakeClient = fake.NewClientBuilder().
+			WithScheme(testScheme).
+			WithObjects(identityProvider).
+			WithStatusSubresource(identityProvider).
+			Build()
+
+		discoveryResolver = newBasicDiscoveryResolver()
+		jwksResolver = &fakeJWKSResolver{
+			jwks: &rest.JWKS{Keys: []rest.JWK{{KeyID: "key-2"}, {KeyID: "key-1"}}},
+		}
+		reconciler = &controller.IdentityProviderReconciler{
+			Client:                    fakeClient,
+			Scheme:                    testScheme,
+			DiscoveryDocumentResolver: discoveryResolver,
+			JWKSResolver:              jwksResolver,
+		}
+	})
+
+	It("reports the resolved endpoints and key IDs of a reachable identity provider", func() {
+		_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: identityProviderID})
+		Expect(err).NotTo(HaveOccurred())
+
+		updated := &ztoperatorv1alpha1.IdentityProvider{}
+		Expect(fakeClient.Get(testCtx, identityProviderID, updated)).To(Succeed())
+		Expect(updated.Status.ObservedGeneration).To(Equal(int64(3)))
+		Expect(updated.Status.Issuer).To(Equal("https://idp.example.com"))
+		Expect(updated.Status.JwksURI).To(Equal("https://idp.example.com/jwks"))
+		Expect(updated.Status.TokenEndpoint).To(Equal("https://idp.example.com/token"))
+		Expect(updated.Status.KeyIDs).To(Equal([]string{"key-1", "key-2"}))
+		Expect(updated.IsReachable()).To(BeTrue())
+	})
+
+	It("reports an identity provider with an unavailable JWKS as unreachable", func() {
+		jwksResolver.err = errors.New("connection refused")
+
+		_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: identityProviderID})
+		Expect(err).NotTo(HaveOccurred())
+
+		updated := &ztoperatorv1alpha1.IdentityProvider{}
+		Expect(fakeClient.Get(testCtx, identityProviderID, updated)).To(Succeed())
+		Expect(updated.IsReachable()).To(BeFalse())
+		condition := meta.FindStatusCondition(
+			updated.Status.Conditions,
+			ztoperatorv1alpha1.IdentityProviderConditionReachable,
+		)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal("Unreachable"))
+		Expect(condition.Message).To(ContainSubstring("connection refused"))
+	})
+
+	It("keeps the last known endpoints while the identity provider is unreachable", func() {
+		_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: identityProviderID})
+		Expect(err).NotTo(HaveOccurred())
+
+		discoveryResolver.err = errors.New("timeout")
+		_, err = reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: identityProviderID})
+		Expect(err).NotTo(HaveOccurred())
+
+		updated := &ztoperatorv1alpha1.IdentityProvider{}
+		Expect(fakeClient.Get(testCtx, identityProviderID, updated)).To(Succeed())
+		Expect(updated.IsReachable()).To(BeFalse())
+		Expect(updated.HasStaleEndpoints()).To(BeTrue())
+		Expect(updated.Status.Issuer).To(Equal("https://idp.example.com"))
+	})
+
+	It("clears the endpoints resolved for a previous generation while the identity provider is unreachable", func() {
+		_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: identityProviderID})
+		Expect(err).NotTo(HaveOccurred())
+
+		updated := &ztoperatorv1alpha1.IdentityProvider{}
+		Expect(fakeClient.Get(testCtx, identityProviderID, updated)).To(Succeed())
+		updated.Generation++
+		Expect(fakeClient.Update(testCtx, updated)).To(Succeed())
+
+		discoveryResolver.err = errors.New("timeout")
+		_, err = reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: identityProviderID})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeClient.Get(testCtx, identityProviderID, updated)).To(Succeed())
+		Expect(updated.IsReachable()).To(BeFalse())
+		Expect(updated.HasStaleEndpoints()).To(BeFalse())
+		Expect(updated.Status.Issuer).To(BeEmpty())
+	})
+
+	It("reuses the resolvers trusting the CA bundle between reconciles", func() {
+		var discoveryDocumentRequests, jwksRequests atomic.Int32
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path == "/jwks" {
+				jwksRequests.Add(1)
+				_, _ = w.Write([]byte(`{"keys":[{"kid":"key-1"}]}`))
+				return
+			}
+			discoveryDocumentRequests.Add(1)
+			_, _ = fmt.Fprintf(
+				w,
+				`{"issuer":"%[1]s","jwks_uri":"%[1]s/jwks","token_endpoint":"%[1]s/token"}`,
+				"https://"+r.Host,
+			)
+		}))
+		DeferCleanup(server.Close)
+
+		updated := &ztoperatorv1alpha1.IdentityProvider{}
+		Expect(fakeClient.Get(testCtx, identityProviderID, updated)).To(Succeed())
+		updated.Spec.WellKnownURI = server.URL + "/.well-known/openid-configuration"
+		updated.Spec.CABundle = string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: server.Certificate().Raw,
+		}))
+		Expect(fakeClient.Update(testCtx, updated)).To(Succeed())
+
+		for range 2 {
+			_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: identityProviderID})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(fakeClient.Get(testCtx, identityProviderID, updated)).To(Succeed())
+		Expect(updated.IsReachable()).To(BeTrue())
+		Expect(updated.Status.KeyIDs).To(Equal([]string{"key-1"}))
+		Expect(discoveryDocumentRequests.Load()).To(Equal(int32(1)))
+		Expect(jwksRequests.Load()).To(Equal(int32(2)))
+	})
+
+	It("ignores IdentityProviders that no longer exist", func() {
+		_, err := reconciler.Reconcile(testCtx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "deleted"}})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
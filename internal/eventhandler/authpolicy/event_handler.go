@@ -0,0 +1,35 @@
+package authpolicy
+
+import (
+	"context"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/eventhandler"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// EventHandler enqueues all ClusterAuthPolicies when an AuthPolicy changes,
+// as the status of an AuthPolicy reports which ClusterAuthPolicies are merged into it.
+func EventHandler(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		if _, ok := obj.(*ztoperatorv1alpha1.AuthPolicy); !ok {
+			return nil
+		}
+
+		return eventhandler.EnqueueClusterAuthPolicies(ctx, c)
+	})
+}
+
+// NamespaceEventHandler enqueues all AuthPolicies in the namespace of an AuthPolicy when it changes,
+// as an AuthPolicy is refused while it overlaps with another AuthPolicy taking precedence.
+func NamespaceEventHandler(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		if _, ok := obj.(*ztoperatorv1alpha1.AuthPolicy); !ok {
+			return nil
+		}
+
+		return eventhandler.EnqueueAuthPoliciesInNamespace(ctx, c, obj.GetNamespace())
+	})
+}
@@ -0,0 +1,125 @@
+package authpolicy_test
+
+import (
+	"context"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/eventhandler/authpolicy"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestAuthPolicyEventHandler_WithNonAuthPolicyObject_ReturnsNoRequests(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	clusterAuthPolicy := &ztoperatorv1alpha1.ClusterAuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "platform"}}
+	k8sClient := createFakeClientForAuthPolicyHandler(clusterAuthPolicy)
+	h := authpolicy.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-configmap", Namespace: "default"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: configMap}, queue)
+
+	// 3. Assert
+	assert.Equal(t, 0, queue.Len(), "Expected no reconcile requests for non-authpolicy object")
+}
+
+func TestAuthPolicyEventHandler_WithAuthPolicy_ReturnsRequestForEachClusterAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	platform := &ztoperatorv1alpha1.ClusterAuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "platform"}}
+	security := &ztoperatorv1alpha1.ClusterAuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "security"}}
+	k8sClient := createFakeClientForAuthPolicyHandler(platform, security)
+	h := authpolicy.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	authPolicy := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-policy", Namespace: "default"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: authPolicy}, queue)
+
+	// 3. Assert
+	var requests []reconcile.Request
+	for queue.Len() > 0 {
+		item, _ := queue.Get()
+		requests = append(requests, item)
+		queue.Done(item)
+	}
+	assert.Len(t, requests, 2)
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "platform"}})
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "security"}})
+}
+
+func TestAuthPolicyNamespaceEventHandler_WithAuthPolicy_ReturnsRequestForEachAuthPolicyInNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	first := &ztoperatorv1alpha1.AuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "default"}}
+	second := &ztoperatorv1alpha1.AuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "default"}}
+	other := &ztoperatorv1alpha1.AuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"}}
+	k8sClient := createFakeClientForAuthPolicyHandler(first, second, other)
+	h := authpolicy.NamespaceEventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	// 2. Act
+	h.Delete(ctx, event.DeleteEvent{Object: first}, queue)
+
+	// 3. Assert
+	var requests []reconcile.Request
+	for queue.Len() > 0 {
+		item, _ := queue.Get()
+		requests = append(requests, item)
+		queue.Done(item)
+	}
+	assert.Len(t, requests, 2)
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "first"}})
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "second"}})
+}
+
+func TestAuthPolicyNamespaceEventHandler_WithNonAuthPolicyObject_ReturnsNoRequests(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := &ztoperatorv1alpha1.AuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "default"}}
+	k8sClient := createFakeClientForAuthPolicyHandler(authPolicy)
+	h := authpolicy.NamespaceEventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-configmap", Namespace: "default"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: configMap}, queue)
+
+	// 3. Assert
+	assert.Equal(t, 0, queue.Len(), "Expected no reconcile requests for non-authpolicy object")
+}
+
+func createFakeClientForAuthPolicyHandler(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
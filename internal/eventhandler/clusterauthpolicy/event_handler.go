@@ -0,0 +1,23 @@
+package clusterauthpolicy
+
+import (
+	"context"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/eventhandler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func EventHandler(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		if _, ok := obj.(*ztoperatorv1alpha1.ClusterAuthPolicy); !ok {
+			return nil
+		}
+
+		// A changed namespace selector may both add and remove namespaces, thus all AuthPolicies are enqueued
+		return eventhandler.EnqueueAuthPoliciesInNamespace(ctx, c, metav1.NamespaceAll)
+	})
+}
@@ -0,0 +1,88 @@
+package clusterauthpolicy_test
+
+import (
+	"context"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/eventhandler/clusterauthpolicy"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestClusterAuthPolicyEventHandler_WithNonClusterAuthPolicyObject_ReturnsNoRequests(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-one", Namespace: "default"},
+	}
+	k8sClient := createFakeClientForClusterAuthPolicyHandler(authPolicy)
+	h := clusterauthpolicy.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-configmap", Namespace: "default"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: configMap}, queue)
+
+	// 3. Assert
+	assert.Equal(t, 0, queue.Len(), "Expected no reconcile requests for non-clusterauthpolicy object")
+}
+
+func TestClusterAuthPolicyEventHandler_WithClusterAuthPolicy_ReturnsRequestForAuthPoliciesInAllNamespaces(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy1 := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-one", Namespace: "default"},
+	}
+	authPolicy2 := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-two", Namespace: "other"},
+	}
+	k8sClient := createFakeClientForClusterAuthPolicyHandler(authPolicy1, authPolicy2)
+	h := clusterauthpolicy.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	clusterAuthPolicy := &ztoperatorv1alpha1.ClusterAuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "platform"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: clusterAuthPolicy}, queue)
+
+	// 3. Assert
+	requests := drainQueue(queue)
+	assert.Len(t, requests, 2)
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "policy-one", Namespace: "default"}})
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "policy-two", Namespace: "other"}})
+}
+
+func createFakeClientForClusterAuthPolicyHandler(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func drainQueue(queue workqueue.TypedRateLimitingInterface[reconcile.Request]) []reconcile.Request {
+	var requests []reconcile.Request
+	for queue.Len() > 0 {
+		item, _ := queue.Get()
+		requests = append(requests, item)
+		queue.Done(item)
+	}
+	return requests
+}
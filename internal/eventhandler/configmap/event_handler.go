@@ -4,7 +4,9 @@ import (
 	"context"
 
 	"github.com/kartverket/ztoperator/internal/eventhandler"
+	"github.com/kartverket/ztoperator/pkg/rest"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -22,6 +24,17 @@ func EventHandler(c client.Client) handler.EventHandler {
 			return nil
 		}
 
+		// Any AuthPolicy may use a discovery document from the static discovery documents ConfigMap
+		if isStaticDiscoveryDocumentsConfigMap(configMap) {
+			return eventhandler.EnqueueAuthPoliciesInNamespace(ctx, c, metav1.NamespaceAll)
+		}
+
 		return eventhandler.EnqueueAuthPoliciesInNamespace(ctx, c, configMap.Namespace)
 	})
 }
+
+func isStaticDiscoveryDocumentsConfigMap(configMap *corev1.ConfigMap) bool {
+	staticDiscoveryDocumentsConfigMap, err := rest.GetStaticDiscoveryDocumentsConfigMap()
+	return err == nil && staticDiscoveryDocumentsConfigMap != nil &&
+		*staticDiscoveryDocumentsConfigMap == client.ObjectKeyFromObject(configMap)
+}
@@ -2,11 +2,14 @@ package configmap_test
 
 import (
 	"context"
+	"os"
 	"testing"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/eventhandler/configmap"
+	"github.com/kartverket/ztoperator/pkg/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -145,6 +148,42 @@ func TestConfigMapEventHandler_WithConfigMapInNamespace_DoesNotEnqueueAuthPolici
 	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "same-namespace-policy", Namespace: "default"}})
 }
 
+func TestConfigMapEventHandler_WithStaticDiscoveryDocumentsConfigMap_ReturnsRequestForAllAuthPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	t.Setenv("ZTOPERATOR_STATIC_DISCOVERY_DOCUMENTS_CONFIG_MAP", "auth/static-discovery-documents")
+	require.NoError(t, config.Load())
+	t.Cleanup(func() {
+		_ = os.Unsetenv("ZTOPERATOR_STATIC_DISCOVERY_DOCUMENTS_CONFIG_MAP")
+		_ = config.Load()
+	})
+
+	authPolicy1 := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-one", Namespace: "default"},
+	}
+	authPolicy2 := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-two", Namespace: "other"},
+	}
+	k8sClient := createFakeClientForConfigMapHandler(authPolicy1, authPolicy2)
+	h := configmap.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "static-discovery-documents", Namespace: "auth"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: cm}, queue)
+
+	// 3. Assert
+	requests := drainQueue(queue)
+	assert.Len(t, requests, 2)
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "policy-one", Namespace: "default"}})
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "policy-two", Namespace: "other"}})
+}
+
 func createFakeClientForConfigMapHandler(objects ...client.Object) client.Client {
 	scheme := runtime.NewScheme()
 	_ = corev1.AddToScheme(scheme)
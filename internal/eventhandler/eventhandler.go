@@ -14,6 +14,8 @@ const (
 	authPolicyGroup   = "ztoperator.kartverket.no"
 	authPolicyVersion = "v1alpha1"
 	authPolicyKind    = "AuthPolicy"
+
+	clusterAuthPolicyKind = "ClusterAuthPolicy"
 )
 
 // IsOwnedByAuthPolicy returns true if the object has an owner reference pointing to an AuthPolicy.
@@ -52,3 +54,26 @@ func EnqueueAuthPoliciesInNamespace(ctx context.Context, c client.Client, namesp
 
 	return reqs
 }
+
+// EnqueueClusterAuthPolicies lists all ClusterAuthPolicy resources and returns a reconcile request for each one.
+func EnqueueClusterAuthPolicies(ctx context.Context, c client.Client) []reconcile.Request {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   authPolicyGroup,
+		Version: authPolicyVersion,
+		Kind:    clusterAuthPolicyKind + "List",
+	})
+
+	if err := c.List(ctx, list); err != nil {
+		return nil
+	}
+
+	reqs := make([]reconcile.Request, 0, len(list.Items))
+	for _, item := range list.Items {
+		reqs = append(reqs, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: item.GetName()},
+		})
+	}
+
+	return reqs
+}
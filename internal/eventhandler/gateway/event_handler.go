@@ -0,0 +1,22 @@
+package gateway
+
+import (
+	"context"
+
+	"github.com/kartverket/ztoperator/internal/eventhandler"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// EventHandler enqueues all AuthPolicies in the namespace of a Gateway when it changes,
+// as the listeners of a Gateway decide where the auto-login filters of an AuthPolicy targeting it are inserted.
+func EventHandler(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		if obj.GetObjectKind().GroupVersionKind().Kind != "Gateway" {
+			return nil
+		}
+
+		return eventhandler.EnqueueAuthPoliciesInNamespace(ctx, c, obj.GetNamespace())
+	})
+}
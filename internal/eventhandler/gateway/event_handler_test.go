@@ -0,0 +1,74 @@
+package gateway_test
+
+import (
+	"context"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/eventhandler/gateway"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestEventHandler_WithNonGatewayObject_ReturnsNoRequests(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := &ztoperatorv1alpha1.AuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "my-policy", Namespace: "default"}}
+	k8sClient := createFakeClientForGatewayHandler(authPolicy)
+	h := gateway.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-pod", Namespace: "default"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: pod}, queue)
+
+	// 3. Assert
+	assert.Equal(t, 0, queue.Len(), "Expected no reconcile requests for non-gateway object")
+}
+
+func TestEventHandler_WithGateway_ReturnsRequestForEachAuthPolicyInNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := &ztoperatorv1alpha1.AuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "my-policy", Namespace: "default"}}
+	otherAuthPolicy := &ztoperatorv1alpha1.AuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"}}
+	k8sClient := createFakeClientForGatewayHandler(authPolicy, otherAuthPolicy)
+	h := gateway.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	gw := &unstructured.Unstructured{}
+	gw.SetGroupVersionKind(resolver.GatewayGroupVersionKind)
+	gw.SetName("my-gateway")
+	gw.SetNamespace("default")
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: gw}, queue)
+
+	// 3. Assert
+	assert.Equal(t, 1, queue.Len())
+	item, _ := queue.Get()
+	assert.Equal(t, reconcile.Request{NamespacedName: types.NamespacedName{Name: "my-policy", Namespace: "default"}}, item)
+}
+
+func createFakeClientForGatewayHandler(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
@@ -0,0 +1,37 @@
+package identityprovider
+
+import (
+	"context"
+	"slices"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// EventHandler enqueues the AuthPolicies referencing an IdentityProvider when it changes,
+// as AuthPolicies use the endpoints reported in the status of the IdentityProvider.
+func EventHandler(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		if _, ok := obj.(*ztoperatorv1alpha1.IdentityProvider); !ok {
+			return nil
+		}
+
+		list := &ztoperatorv1alpha1.AuthPolicyList{}
+		if err := c.List(ctx, list); err != nil {
+			return nil
+		}
+
+		var reqs []reconcile.Request
+		for _, item := range list.Items {
+			if slices.Contains(item.GetIdentityProviderRefs(), obj.GetName()) {
+				reqs = append(reqs, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name},
+				})
+			}
+		}
+		return reqs
+	})
+}
@@ -0,0 +1,108 @@
+package identityprovider_test
+
+import (
+	"context"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/eventhandler/identityprovider"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestIdentityProviderEventHandler_WithNonIdentityProviderObject_ReturnsNoRequests(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	identityProviderRef := "idporten"
+	authPolicy := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-one", Namespace: "default"},
+		Spec:       ztoperatorv1alpha1.AuthPolicySpec{IdentityProviderRef: &identityProviderRef},
+	}
+	k8sClient := createFakeClientForIdentityProviderHandler(authPolicy)
+	h := identityprovider.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "idporten", Namespace: "default"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: configMap}, queue)
+
+	// 3. Assert
+	assert.Equal(t, 0, queue.Len(), "Expected no reconcile requests for non-identityprovider object")
+}
+
+func TestIdentityProviderEventHandler_WithIdentityProvider_ReturnsRequestForReferencingAuthPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	identityProviderRef := "idporten"
+	otherIdentityProviderRef := "entra"
+	authPolicy1 := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-one", Namespace: "default"},
+		Spec:       ztoperatorv1alpha1.AuthPolicySpec{IdentityProviderRef: &identityProviderRef},
+	}
+	authPolicy2 := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-two", Namespace: "other"},
+		Spec: ztoperatorv1alpha1.AuthPolicySpec{
+			IdentityProviders: []ztoperatorv1alpha1.TrustedIdentityProvider{
+				{Name: "idporten", IdentityProviderRef: &identityProviderRef},
+			},
+		},
+	}
+	authPolicy3 := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-three", Namespace: "default"},
+		Spec:       ztoperatorv1alpha1.AuthPolicySpec{IdentityProviderRef: &otherIdentityProviderRef},
+	}
+	authPolicy4 := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-four", Namespace: "default"},
+		Spec: ztoperatorv1alpha1.AuthPolicySpec{
+			WellKnownURI: "https://idporten.no/.well-known/openid-configuration",
+		},
+	}
+	k8sClient := createFakeClientForIdentityProviderHandler(authPolicy1, authPolicy2, authPolicy3, authPolicy4)
+	h := identityprovider.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	identityProvider := &ztoperatorv1alpha1.IdentityProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: "idporten"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: identityProvider}, queue)
+
+	// 3. Assert
+	requests := drainQueue(queue)
+	assert.Len(t, requests, 2)
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "policy-one", Namespace: "default"}})
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "policy-two", Namespace: "other"}})
+}
+
+func createFakeClientForIdentityProviderHandler(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func drainQueue(queue workqueue.TypedRateLimitingInterface[reconcile.Request]) []reconcile.Request {
+	var requests []reconcile.Request
+	for queue.Len() > 0 {
+		item, _ := queue.Get()
+		requests = append(requests, item)
+		queue.Done(item)
+	}
+	return requests
+}
@@ -0,0 +1,35 @@
+package namespace
+
+import (
+	"context"
+
+	"github.com/kartverket/ztoperator/internal/eventhandler"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func EventHandler(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		namespace, ok := obj.(*corev1.Namespace)
+		if !ok {
+			return nil
+		}
+
+		// Namespace labels decide which ClusterAuthPolicies apply to the AuthPolicies in the namespace
+		return eventhandler.EnqueueAuthPoliciesInNamespace(ctx, c, namespace.Name)
+	})
+}
+
+// ClusterAuthPolicyEventHandler enqueues all ClusterAuthPolicies when a namespace changes,
+// as namespace labels decide in which namespaces a ClusterAuthPolicy denies requests by default.
+func ClusterAuthPolicyEventHandler(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		if _, ok := obj.(*corev1.Namespace); !ok {
+			return nil
+		}
+
+		return eventhandler.EnqueueClusterAuthPolicies(ctx, c)
+	})
+}
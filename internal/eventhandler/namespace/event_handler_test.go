@@ -0,0 +1,108 @@
+package namespace_test
+
+import (
+	"context"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/eventhandler/namespace"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestNamespaceEventHandler_WithNonNamespaceObject_ReturnsNoRequests(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	k8sClient := createFakeClientForNamespaceHandler()
+	h := namespace.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-configmap", Namespace: "default"},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: configMap}, queue)
+
+	// 3. Assert
+	assert.Equal(t, 0, queue.Len(), "Expected no reconcile requests for non-namespace object")
+}
+
+func TestNamespaceEventHandler_WithNamespace_ReturnsRequestForEachAuthPolicyInNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicyInNamespace := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "same-namespace-policy", Namespace: "default"},
+	}
+	authPolicyInOtherNamespace := &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "other-namespace-policy", Namespace: "other"},
+	}
+	k8sClient := createFakeClientForNamespaceHandler(authPolicyInNamespace, authPolicyInOtherNamespace)
+	h := namespace.EventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"tier": "prod"}},
+	}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: ns}, queue)
+
+	// 3. Assert
+	var requests []reconcile.Request
+	for queue.Len() > 0 {
+		item, _ := queue.Get()
+		requests = append(requests, item)
+		queue.Done(item)
+	}
+	assert.Len(t, requests, 1)
+	assert.Contains(t, requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: "same-namespace-policy", Namespace: "default"}})
+}
+
+func createFakeClientForNamespaceHandler(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func TestNamespaceClusterAuthPolicyEventHandler_WithNamespace_ReturnsRequestForEachClusterAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	platform := &ztoperatorv1alpha1.ClusterAuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "platform"}}
+	security := &ztoperatorv1alpha1.ClusterAuthPolicy{ObjectMeta: metav1.ObjectMeta{Name: "security"}}
+	k8sClient := createFakeClientForNamespaceHandler(platform, security)
+	h := namespace.ClusterAuthPolicyEventHandler(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team"}}
+
+	// 2. Act
+	h.Create(ctx, event.CreateEvent{Object: ns}, queue)
+
+	// 3. Assert
+	var requests []reconcile.Request
+	for queue.Len() > 0 {
+		item, _ := queue.Get()
+		requests = append(requests, item)
+		queue.Done(item)
+	}
+	assert.ElementsMatch(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "platform"}},
+		{NamespacedName: types.NamespacedName{Name: "security"}},
+	}, requests)
+}
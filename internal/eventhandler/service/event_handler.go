@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+
+	"github.com/kartverket/ztoperator/internal/eventhandler"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// EventHandler enqueues all AuthPolicies in the namespace of a Service when it changes,
+// as the selector of a Service decides which pods an AuthPolicy targeting it overlaps with.
+func EventHandler(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		service, ok := obj.(*corev1.Service)
+		if !ok {
+			return nil
+		}
+
+		return eventhandler.EnqueueAuthPoliciesInNamespace(ctx, c, service.Namespace)
+	})
+}
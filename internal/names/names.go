@@ -1,7 +1,13 @@
 package names
 
-func EnvoyFilter(base string) string   { return base + "-login" }
-func EnvoySecret(base string) string   { return base + "-envoy-secret" }
-func DenyPolicy(base string) string    { return base + "-deny-auth-rules" }
-func IgnorePolicy(base string) string  { return base + "-ignore-auth" }
-func RequirePolicy(base string) string { return base + "-require-auth" }
+func EnvoyFilter(base string) string                { return base + "-login" }
+func EgressEnvoyFilter(base string) string          { return base + "-egress" }
+func TokenExchangeEnvoyFilter(base string) string   { return base + "-token-exchange" }
+func DenyResponseEnvoyFilter(base string) string    { return base + "-deny-response" }
+func AuditEnvoyFilter(base string) string           { return base + "-audit" }
+func EnvoySecret(base string) string                { return base + "-envoy-secret" }
+func DenyPolicy(base string) string                 { return base + "-deny-auth-rules" }
+func IgnorePolicy(base string) string               { return base + "-ignore-auth" }
+func RequirePolicy(base string) string              { return base + "-require-auth" }
+func DefaultDenyPolicy(base string) string          { return base + "-default-deny" }
+func DefaultDenyExemptionPolicy(base string) string { return base + "-default-deny-exemption" }
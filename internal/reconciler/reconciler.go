@@ -45,3 +45,11 @@ func (a ControllerResourceAdapter[T]) GetResourceName() string {
 func (a ControllerResourceAdapter[T]) IsResourceNil() bool {
 	return a.Func.DesiredResource == nil || reflect.ValueOf(*a.Func.DesiredResource).IsNil()
 }
+
+// GetDesiredResource returns the desired resource, or nil when the resource is not desired.
+func (a ControllerResourceAdapter[T]) GetDesiredResource() client.Object {
+	if a.IsResourceNil() {
+		return nil
+	}
+	return *a.Func.DesiredResource
+}
@@ -8,14 +8,21 @@ import (
 	"github.com/kartverket/ztoperator/internal/state"
 	"github.com/kartverket/ztoperator/pkg/helperfunctions"
 	"github.com/kartverket/ztoperator/pkg/labels"
+	"github.com/kartverket/ztoperator/pkg/luascript"
 	"github.com/kartverket/ztoperator/pkg/reconciliation"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/defaultdeny"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/deny"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/ignore"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/require"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/audit"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/denyresponse"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/egress"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/tokenexchange"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/requestauthentication"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/secret"
+	"istio.io/api/annotation"
 	v1alpha4 "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
 	v1 "k8s.io/api/core/v1"
@@ -27,16 +34,22 @@ func ControllerResources(scope *state.Scope) []reconciliation.ControllerResource
 	return []reconciliation.ControllerResource{
 		secretResource(scope),
 		envoyFilterResource(scope),
+		egressEnvoyFilterResource(scope),
+		tokenExchangeEnvoyFilterResource(scope),
+		denyResponseEnvoyFilterResource(scope),
+		auditEnvoyFilterResource(scope),
 		requestAuthenticationResource(scope),
 		denyAuthorizationPolicyResource(scope),
 		ignoreAuthorizationPolicyResource(scope),
 		requireAuthorizationPolicyResource(scope),
+		defaultDenyExemptionAuthorizationPolicyResource(scope),
 	}
 }
 
 /*
 secretResource reconciles a Secret resource containing a HMAC secret (cookie signing key) and token secret
-(OAuth client secret), if auto-login is enabled. The secrets are used by Envoy during Authorization Code Flow.
+(OAuth client secret), if auto-login, egress or token exchange is enabled. The secrets are used by Envoy during
+Authorization Code Flow and when obtaining access tokens for outbound requests.
 */
 func secretResource(scope *state.Scope) ControllerResourceAdapter[*v1.Secret] {
 	desiredResource := secret.GetDesired(
@@ -62,6 +75,11 @@ func SecretShouldUpdate(current, desired *v1.Secret) bool {
 	desiredTokenSecret, hasDesired := desired.Data[configpatch.TokenSecretFileName]
 	currentTokenSecret, hasCurrent := current.Data[configpatch.TokenSecretFileName]
 	return !hasDesired || !hasCurrent || !bytes.Equal(currentTokenSecret, desiredTokenSecret) ||
+		!bytes.Equal(current.Data[configpatch.ClientSecretFileName], desired.Data[configpatch.ClientSecretFileName]) ||
+		!bytes.Equal(
+			current.Data[luascript.SignalHeaderSuffixSecretKey],
+			desired.Data[luascript.SignalHeaderSuffixSecretKey],
+		) ||
 		labelsNeedUpdate(current, desired)
 }
 
@@ -95,10 +113,113 @@ func envoyFilterResource(scope *state.Scope) ControllerResourceAdapter[*v1alpha4
 	}
 }
 
+/*
+egressEnvoyFilterResource reconciles an EnvoyFilter resource injecting access tokens, obtained with the OAuth2 Client
+Credentials Grant, into outbound requests to the configured egress destinations when egress is enabled.
+*/
+func egressEnvoyFilterResource(scope *state.Scope) ControllerResourceAdapter[*v1alpha4.EnvoyFilter] {
+	egressEnvoyFilterName := names.EgressEnvoyFilter(scope.AuthPolicy.Name)
+	desiredResource := egress.GetDesired(
+		scope,
+		buildObjectMeta(egressEnvoyFilterName, scope.AuthPolicy.Namespace),
+	)
+
+	return ControllerResourceAdapter[*v1alpha4.EnvoyFilter]{
+		reconciliation.ReconcilerAdapter[*v1alpha4.EnvoyFilter]{
+			Func: reconciliation.ResourceReconciler[*v1alpha4.EnvoyFilter]{
+				ResourceKind:    "EnvoyFilter",
+				ResourceName:    egressEnvoyFilterName,
+				DesiredResource: helperfunctions.Ptr(desiredResource),
+				Scope:           scope,
+				ShouldUpdate:    EnvoyFilterShouldUpdate,
+				UpdateFields:    EnvoyFilterUpdateFields,
+			},
+		},
+	}
+}
+
+/*
+tokenExchangeEnvoyFilterResource reconciles an EnvoyFilter resource exchanging the bearer token of outbound requests to
+the configured destinations for a token scoped to the destination (RFC 8693), when token exchange is enabled.
+*/
+func tokenExchangeEnvoyFilterResource(scope *state.Scope) ControllerResourceAdapter[*v1alpha4.EnvoyFilter] {
+	tokenExchangeEnvoyFilterName := names.TokenExchangeEnvoyFilter(scope.AuthPolicy.Name)
+	desiredResource := tokenexchange.GetDesired(
+		scope,
+		buildObjectMeta(tokenExchangeEnvoyFilterName, scope.AuthPolicy.Namespace),
+	)
+
+	return ControllerResourceAdapter[*v1alpha4.EnvoyFilter]{
+		reconciliation.ReconcilerAdapter[*v1alpha4.EnvoyFilter]{
+			Func: reconciliation.ResourceReconciler[*v1alpha4.EnvoyFilter]{
+				ResourceKind:    "EnvoyFilter",
+				ResourceName:    tokenExchangeEnvoyFilterName,
+				DesiredResource: helperfunctions.Ptr(desiredResource),
+				Scope:           scope,
+				ShouldUpdate:    EnvoyFilterShouldUpdate,
+				UpdateFields:    EnvoyFilterUpdateFields,
+			},
+		},
+	}
+}
+
+/*
+denyResponseEnvoyFilterResource reconciles an EnvoyFilter resource rewriting the responses of inbound requests denied
+by the sidecar, when a deny response is configured for the AuthPolicy or any of its auth rules.
+*/
+func denyResponseEnvoyFilterResource(scope *state.Scope) ControllerResourceAdapter[*v1alpha4.EnvoyFilter] {
+	denyResponseEnvoyFilterName := names.DenyResponseEnvoyFilter(scope.AuthPolicy.Name)
+	desiredResource := denyresponse.GetDesired(
+		scope,
+		buildObjectMeta(denyResponseEnvoyFilterName, scope.AuthPolicy.Namespace),
+	)
+
+	return ControllerResourceAdapter[*v1alpha4.EnvoyFilter]{
+		reconciliation.ReconcilerAdapter[*v1alpha4.EnvoyFilter]{
+			Func: reconciliation.ResourceReconciler[*v1alpha4.EnvoyFilter]{
+				ResourceKind:    "EnvoyFilter",
+				ResourceName:    denyResponseEnvoyFilterName,
+				DesiredResource: helperfunctions.Ptr(desiredResource),
+				Scope:           scope,
+				ShouldUpdate:    EnvoyFilterShouldUpdate,
+				UpdateFields:    EnvoyFilterUpdateFields,
+			},
+		},
+	}
+}
+
+/*
+auditEnvoyFilterResource reconciles an EnvoyFilter resource logging inbound requests which would have been denied,
+when the AuthPolicy is in audit mode.
+*/
+func auditEnvoyFilterResource(scope *state.Scope) ControllerResourceAdapter[*v1alpha4.EnvoyFilter] {
+	auditEnvoyFilterName := names.AuditEnvoyFilter(scope.AuthPolicy.Name)
+	desiredResource := audit.GetDesired(
+		scope,
+		buildObjectMeta(auditEnvoyFilterName, scope.AuthPolicy.Namespace),
+	)
+
+	return ControllerResourceAdapter[*v1alpha4.EnvoyFilter]{
+		reconciliation.ReconcilerAdapter[*v1alpha4.EnvoyFilter]{
+			Func: reconciliation.ResourceReconciler[*v1alpha4.EnvoyFilter]{
+				ResourceKind:    "EnvoyFilter",
+				ResourceName:    auditEnvoyFilterName,
+				DesiredResource: helperfunctions.Ptr(desiredResource),
+				Scope:           scope,
+				ShouldUpdate:    EnvoyFilterShouldUpdate,
+				UpdateFields:    EnvoyFilterUpdateFields,
+			},
+		},
+	}
+}
+
 func EnvoyFilterShouldUpdate(current, desired *v1alpha4.EnvoyFilter) bool {
 	return !reflect.DeepEqual(
 		current.Spec.GetWorkloadSelector(),
 		desired.Spec.GetWorkloadSelector(),
+	) || !reflect.DeepEqual(
+		current.Spec.GetTargetRefs(),
+		desired.Spec.GetTargetRefs(),
 	) || !reflect.DeepEqual(
 		current.Spec.GetConfigPatches(),
 		desired.Spec.GetConfigPatches(),
@@ -108,6 +229,7 @@ func EnvoyFilterShouldUpdate(current, desired *v1alpha4.EnvoyFilter) bool {
 
 func EnvoyFilterUpdateFields(current, desired *v1alpha4.EnvoyFilter) {
 	current.Spec.WorkloadSelector = desired.Spec.GetWorkloadSelector()
+	current.Spec.TargetRefs = desired.Spec.GetTargetRefs()
 	current.Spec.ConfigPatches = desired.Spec.GetConfigPatches()
 	current.Labels = desired.Labels
 }
@@ -141,12 +263,14 @@ func requestAuthenticationResource(
 
 func RequestAuthenticationShouldUpdate(current, desired *istioclientsecurityv1.RequestAuthentication) bool {
 	return !reflect.DeepEqual(current.Spec.GetSelector(), desired.Spec.GetSelector()) ||
+		!reflect.DeepEqual(current.Spec.GetTargetRefs(), desired.Spec.GetTargetRefs()) ||
 		!reflect.DeepEqual(current.Spec.GetJwtRules(), desired.Spec.GetJwtRules()) ||
 		labelsNeedUpdate(current, desired)
 }
 
 func RequestAuthenticationUpdateFields(current, desired *istioclientsecurityv1.RequestAuthentication) {
 	current.Spec.Selector = desired.Spec.GetSelector()
+	current.Spec.TargetRefs = desired.Spec.GetTargetRefs()
 	current.Spec.JwtRules = desired.Spec.GetJwtRules()
 	current.Labels = desired.Labels
 }
@@ -235,16 +359,55 @@ func requireAuthorizationPolicyResource(
 	}
 }
 
+/*
+defaultDenyExemptionAuthorizationPolicyResource reconciles an ALLOW AuthorizationPolicy allowing all requests to the
+workloads of the AuthPolicy, if they are exempt from the default deny of a ClusterAuthPolicy.
+*/
+func defaultDenyExemptionAuthorizationPolicyResource(
+	scope *state.Scope,
+) ControllerResourceAdapter[*istioclientsecurityv1.AuthorizationPolicy] {
+	exemptionAuthorizationPolicyName := names.DefaultDenyExemptionPolicy(scope.AuthPolicy.Name)
+	desiredResource := defaultdeny.GetDesiredExemption(
+		scope,
+		buildObjectMeta(exemptionAuthorizationPolicyName, scope.AuthPolicy.Namespace),
+	)
+
+	return ControllerResourceAdapter[*istioclientsecurityv1.AuthorizationPolicy]{
+		reconciliation.ReconcilerAdapter[*istioclientsecurityv1.AuthorizationPolicy]{
+			Func: reconciliation.ResourceReconciler[*istioclientsecurityv1.AuthorizationPolicy]{
+				ResourceKind:    "AuthorizationPolicy",
+				ResourceName:    exemptionAuthorizationPolicyName,
+				DesiredResource: helperfunctions.Ptr(desiredResource),
+				Scope:           scope,
+				ShouldUpdate:    AuthorizationPolicyShouldUpdate,
+				UpdateFields:    AuthorizationPolicyUpdateFields,
+			},
+		},
+	}
+}
+
 func AuthorizationPolicyShouldUpdate(current, desired *istioclientsecurityv1.AuthorizationPolicy) bool {
 	return !reflect.DeepEqual(current.Spec.GetSelector(), desired.Spec.GetSelector()) ||
+		!reflect.DeepEqual(current.Spec.GetTargetRefs(), desired.Spec.GetTargetRefs()) ||
 		!reflect.DeepEqual(current.Spec.GetRules(), desired.Spec.GetRules()) ||
-		labelsNeedUpdate(current, desired)
+		labelsNeedUpdate(current, desired) ||
+		current.GetAnnotations()[annotation.IoIstioDryRun.Name] != desired.GetAnnotations()[annotation.IoIstioDryRun.Name]
 }
 
 func AuthorizationPolicyUpdateFields(current, desired *istioclientsecurityv1.AuthorizationPolicy) {
 	current.Spec.Selector = desired.Spec.GetSelector()
+	current.Spec.TargetRefs = desired.Spec.GetTargetRefs()
 	current.Spec.Rules = desired.Spec.GetRules()
 	current.Labels = desired.Labels
+	// The dry-run annotation is removed when switching back to enforcement, other annotations are left untouched
+	if dryRun, ok := desired.GetAnnotations()[annotation.IoIstioDryRun.Name]; ok {
+		if current.Annotations == nil {
+			current.Annotations = map[string]string{}
+		}
+		current.Annotations[annotation.IoIstioDryRun.Name] = dryRun
+	} else {
+		delete(current.Annotations, annotation.IoIstioDryRun.Name)
+	}
 }
 
 type LabeledObject interface {
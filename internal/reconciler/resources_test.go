@@ -11,11 +11,13 @@ import (
 	"github.com/kartverket/ztoperator/internal/state"
 	"github.com/kartverket/ztoperator/pkg/helperfunctions"
 	"github.com/kartverket/ztoperator/pkg/labels"
+	"github.com/kartverket/ztoperator/pkg/luascript"
 	"github.com/kartverket/ztoperator/pkg/reconciliation"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/secret"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"istio.io/api/annotation"
 	istioapinetworkingv1alpha3 "istio.io/api/networking/v1alpha3"
 	istioapisecurityv1 "istio.io/api/security/v1"
 	istioapisecurityv1beta1 "istio.io/api/security/v1beta1"
@@ -56,10 +58,15 @@ var _ = Describe("ControllerResources", func() {
 			ConsistOf(
 				"Secret",
 				"EnvoyFilter",
+				"EnvoyFilter",
+				"EnvoyFilter",
+				"EnvoyFilter",
+				"EnvoyFilter",
 				"RequestAuthentication",
 				"AuthorizationPolicy",
 				"AuthorizationPolicy",
 				"AuthorizationPolicy",
+				"AuthorizationPolicy",
 			),
 		)
 	})
@@ -76,10 +83,15 @@ var _ = Describe("ControllerResources", func() {
 			ConsistOf(
 				fmt.Sprintf("%s/%s", "Secret", names.EnvoySecret(authPolicyName)),
 				fmt.Sprintf("%s/%s", "EnvoyFilter", names.EnvoyFilter(authPolicyName)),
+				fmt.Sprintf("%s/%s", "EnvoyFilter", names.EgressEnvoyFilter(authPolicyName)),
+				fmt.Sprintf("%s/%s", "EnvoyFilter", names.TokenExchangeEnvoyFilter(authPolicyName)),
+				fmt.Sprintf("%s/%s", "EnvoyFilter", names.DenyResponseEnvoyFilter(authPolicyName)),
+				fmt.Sprintf("%s/%s", "EnvoyFilter", names.AuditEnvoyFilter(authPolicyName)),
 				fmt.Sprintf("%s/%s", "RequestAuthentication", authPolicyName),
 				fmt.Sprintf("%s/%s", "AuthorizationPolicy", names.DenyPolicy(authPolicyName)),
 				fmt.Sprintf("%s/%s", "AuthorizationPolicy", names.IgnorePolicy(authPolicyName)),
 				fmt.Sprintf("%s/%s", "AuthorizationPolicy", names.RequirePolicy(authPolicyName)),
+				fmt.Sprintf("%s/%s", "AuthorizationPolicy", names.DefaultDenyExemptionPolicy(authPolicyName)),
 			),
 		)
 	})
@@ -101,6 +113,7 @@ var _ = Describe("ControllerResources", func() {
 				AutoLoginConfig: resolver.ResolveAutoLoginConfig(
 					authPolicy,
 					state.IdentityProviderUris{},
+					"6f1c2b1e3d4a4c5b",
 				),
 			}
 
@@ -311,6 +324,20 @@ var _ = Describe("SecretShouldUpdate", func() {
 		Expect(reconciler.SecretShouldUpdate(current, desired)).To(BeTrue())
 	})
 
+	It("returns true when the client secret used for token exchange is missing on current", func() {
+		current := secretWithToken("same", labels.AuthPolicyStandardLabels())
+		desired := secretWithToken("same", labels.AuthPolicyStandardLabels())
+		desired.Data[configpatch.ClientSecretFileName] = []byte("secret")
+		Expect(reconciler.SecretShouldUpdate(current, desired)).To(BeTrue())
+	})
+
+	It("returns true when the current Secret is missing the signal header suffix", func() {
+		current := secretWithToken("same", labels.AuthPolicyStandardLabels())
+		desired := secretWithToken("same", labels.AuthPolicyStandardLabels())
+		desired.Data[luascript.SignalHeaderSuffixSecretKey] = []byte("6f1c2b1e3d4a4c5b")
+		Expect(reconciler.SecretShouldUpdate(current, desired)).To(BeTrue())
+	})
+
 	It("returns true when a desired label is missing on current", func() {
 		current := secretWithToken("same", nil)
 		desired := secretWithToken("same", labels.AuthPolicyStandardLabels())
@@ -519,3 +546,35 @@ var _ = Describe("AuthorizationPolicyUpdateFields", func() {
 		Expect(current.Labels).To(Equal(desired.Labels))
 	})
 })
+
+var _ = Describe("AuthorizationPolicyShouldUpdate", func() {
+	policyWithAnnotations := func(annotations map[string]string) *istioclientsecurityv1.AuthorizationPolicy {
+		return &istioclientsecurityv1.AuthorizationPolicy{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels.AuthPolicyStandardLabels(), Annotations: annotations},
+		}
+	}
+
+	It("returns false when neither policy is in dry-run mode", func() {
+		current := policyWithAnnotations(map[string]string{"custom.example.com/owner": "platform"})
+		desired := policyWithAnnotations(nil)
+		Expect(reconciler.AuthorizationPolicyShouldUpdate(current, desired)).To(BeFalse())
+	})
+
+	It("returns true when switching to audit mode", func() {
+		current := policyWithAnnotations(nil)
+		desired := policyWithAnnotations(map[string]string{annotation.IoIstioDryRun.Name: "true"})
+		Expect(reconciler.AuthorizationPolicyShouldUpdate(current, desired)).To(BeTrue())
+	})
+
+	It("removes only the dry-run annotation when switching back to enforcement", func() {
+		current := policyWithAnnotations(map[string]string{
+			annotation.IoIstioDryRun.Name: "true",
+			"custom.example.com/owner":    "platform",
+		})
+		desired := policyWithAnnotations(nil)
+		Expect(reconciler.AuthorizationPolicyShouldUpdate(current, desired)).To(BeTrue())
+
+		reconciler.AuthorizationPolicyUpdateFields(current, desired)
+		Expect(current.Annotations).To(Equal(map[string]string{"custom.example.com/owner": "platform"}))
+	})
+})
@@ -0,0 +1,146 @@
+package render
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	"go.uber.org/zap/zapcore"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+const usage = `Usage: ztoperator render [flags] FILE...
+
+Renders the resources ztoperator generates for the AuthPolicies in the given files, without a cluster. The files may
+also hold the Secrets, ConfigMaps, IdentityProviders, ClusterAuthPolicies and Namespaces the AuthPolicies depend on.
+Use - to read from standard input.
+
+Flags:
+`
+
+// stringsFlag is a flag which may be given several times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Run runs the render command with the given arguments, and returns its exit code.
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("render", usage, stderr)
+	input := registerInputFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	ctx = input.withLogger(ctx, stderr)
+	objects, discoveryDocuments, err := input.load(flags.Args(), stdin)
+	if err == nil {
+		err = Render(ctx, objects, discoveryDocuments, stdout)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func newFlagSet(name string, usage string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// inputFlags are the flags of the commands reading objects and discovery documents from files.
+type inputFlags struct {
+	discoveryDocumentFiles stringsFlag
+	namespace              *string
+	verbose                *bool
+}
+
+func registerInputFlags(flags *flag.FlagSet) *inputFlags {
+	input := &inputFlags{}
+	flags.Var(&input.discoveryDocumentFiles, "discovery-document",
+		"File holding a discovery document along with its wellKnownURI. May be given several times.")
+	input.namespace = flags.String("namespace", "default", "The namespace of objects which do not set a namespace.")
+	input.verbose = flags.Bool("v", false, "Log how the AuthPolicies are resolved to standard error.")
+	return input
+}
+
+func (f *inputFlags) withLogger(ctx context.Context, stderr io.Writer) context.Context {
+	logger := logr.Discard()
+	if *f.verbose {
+		logger = zap.New(zap.WriteTo(stderr), zap.Level(zapcore.DebugLevel))
+	}
+	return ctrl.LoggerInto(ctx, logger)
+}
+
+// load reads the objects in files, and the discovery documents given by -discovery-document.
+func (f *inputFlags) load(
+	files []string,
+	stdin io.Reader,
+) ([]client.Object, []rest.StaticDiscoveryDocument, error) {
+	var objects []client.Object
+	for _, file := range files {
+		err := readFile(file, stdin, func(reader io.Reader) error {
+			fileObjects, err := LoadObjects(reader, *f.namespace)
+			objects = append(objects, fileObjects...)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var discoveryDocuments []rest.StaticDiscoveryDocument
+	for _, file := range f.discoveryDocumentFiles {
+		err := readFile(file, stdin, func(reader io.Reader) error {
+			discoveryDocument, err := LoadDiscoveryDocument(reader)
+			if err != nil {
+				return err
+			}
+			discoveryDocuments = append(discoveryDocuments, *discoveryDocument)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return objects, discoveryDocuments, nil
+}
+
+func readFile(file string, stdin io.Reader, read func(io.Reader) error) error {
+	if file == "-" {
+		if err := read(stdin); err != nil {
+			return fmt.Errorf("failed to read standard input: %w", err)
+		}
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if err := read(f); err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return nil
+}
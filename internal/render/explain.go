@@ -0,0 +1,57 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/explain"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Explain explains how the resources generated for an AuthPolicy among the objects handle the request, without a
+// cluster. The AuthPolicy is given by its name, or by namespace/name, and may be left empty when the objects hold a
+// single AuthPolicy. The objects and discovery documents are used the same way as by Render.
+func Explain(
+	ctx context.Context,
+	objects []client.Object,
+	discoveryDocuments []rest.StaticDiscoveryDocument,
+	authPolicyName string,
+	request explain.Request,
+) (*ztoperatorv1alpha1.AuthPolicy, explain.Explanation, error) {
+	cluster, err := newOfflineCluster(ctx, objects, discoveryDocuments)
+	if err != nil {
+		return nil, explain.Explanation{}, err
+	}
+	authPolicy, err := cluster.findAuthPolicy(authPolicyName)
+	if err != nil {
+		return nil, explain.Explanation{}, err
+	}
+	scope, _, err := cluster.resolve(ctx, authPolicy)
+	if err != nil {
+		return nil, explain.Explanation{}, err
+	}
+	return authPolicy, explain.Explain(scope, request), nil
+}
+
+func (c *offlineCluster) findAuthPolicy(name string) (*ztoperatorv1alpha1.AuthPolicy, error) {
+	var found []*ztoperatorv1alpha1.AuthPolicy
+	var names []string
+	for _, authPolicy := range c.authPolicies {
+		namespacedName := authPolicy.Namespace + "/" + authPolicy.Name
+		names = append(names, namespacedName)
+		if name == "" || name == authPolicy.Name || name == namespacedName {
+			found = append(found, authPolicy)
+		}
+	}
+	switch {
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) == 0:
+		return nil, fmt.Errorf("no AuthPolicy %s found among %s", name, strings.Join(names, ", "))
+	default:
+		return nil, fmt.Errorf("several AuthPolicies found, select one of %s", strings.Join(names, ", "))
+	}
+}
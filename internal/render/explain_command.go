@@ -0,0 +1,134 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/kartverket/ztoperator/pkg/explain"
+)
+
+const explainUsage = `Usage: ztoperator explain [flags] -path PATH FILE...
+
+Explains how the resources ztoperator generates for an AuthPolicy in the given files handle a request, without a
+cluster: whether login is bypassed, the request is redirected to login, denied with 401 instead of being redirected,
+allowed or denied, which rule decided it, and which condition was not met. The files are read the same way as by
+ztoperator render. The token is not verified, and is assumed to be signed by a trusted key and not to be expired.
+
+Flags:
+`
+
+// RunExplain runs the explain command with the given arguments, and returns its exit code.
+func RunExplain(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("explain", explainUsage, stderr)
+	input := registerInputFlags(flags)
+	authPolicyName := flags.String("authpolicy", "",
+		"The name, or namespace/name, of the AuthPolicy. May be omitted when the files hold a single AuthPolicy.")
+	method := flags.String("method", http.MethodGet, "The method of the request.")
+	path := flags.String("path", "", "The path of the request, which may include a query string.")
+	host := flags.String("host", "", "The host of the request.")
+	var headers stringsFlag
+	flags.Var(&headers, "H", "A header of the request, as 'name: value'. May be given several times.")
+	token := flags.String("token", "", "The JWT of the request. Its signature is not verified.")
+	claimsFile := flags.String("claims", "", "File holding the claims of the token of the request as a JSON object.")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || *path == "" || (*token != "" && *claimsFile != "") {
+		flags.Usage()
+		return 2
+	}
+
+	ctx = input.withLogger(ctx, stderr)
+	request := explain.Request{Method: strings.ToUpper(*method), Path: *path, Host: *host}
+	var err error
+	if request.Headers, err = parseHeaders(headers); err != nil {
+		_, _ = fmt.Fprintf(stderr, "error: %s\n", err)
+		return 2
+	}
+	request.Claims, err = loadClaims(*token, *claimsFile, stdin)
+	if err == nil {
+		err = explainFiles(ctx, input, flags.Args(), *authPolicyName, request, stdin, stdout)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "error: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func explainFiles(
+	ctx context.Context,
+	input *inputFlags,
+	files []string,
+	authPolicyName string,
+	request explain.Request,
+	stdin io.Reader,
+	stdout io.Writer,
+) error {
+	objects, discoveryDocuments, err := input.load(files, stdin)
+	if err != nil {
+		return err
+	}
+	authPolicy, explanation, err := Explain(ctx, objects, discoveryDocuments, authPolicyName, request)
+	if err != nil {
+		return err
+	}
+	return writeExplanation(stdout, authPolicy.Namespace+"/"+authPolicy.Name, explanation)
+}
+
+func parseHeaders(headers []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(headers))
+	for _, header := range headers {
+		name, value, found := strings.Cut(header, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("header %q must be given as 'name: value'", header)
+		}
+		parsed[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return parsed, nil
+}
+
+// loadClaims returns the claims of the token, or of the claims file, or nil if the request has no token.
+func loadClaims(token string, claimsFile string, stdin io.Reader) (map[string]interface{}, error) {
+	if token != "" {
+		return explain.ClaimsFromJWT(token)
+	}
+	if claimsFile == "" {
+		return nil, nil
+	}
+	var claims map[string]interface{}
+	err := readFile(claimsFile, stdin, func(reader io.Reader) error {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		claims, err = explain.ClaimsFromJSON(data)
+		return err
+	})
+	return claims, err
+}
+
+func writeExplanation(out io.Writer, authPolicy string, explanation explain.Explanation) error {
+	decision := string(explanation.Decision)
+	if explanation.StatusCode != 0 {
+		decision += fmt.Sprintf(" (%d)", explanation.StatusCode)
+	}
+	rule := explanation.Rule
+	if rule == "" {
+		rule = "-"
+	}
+	lines := []string{
+		"AuthPolicy: " + authPolicy,
+		"Decision:   " + decision,
+		"Rule:       " + rule,
+		"Reason:     " + explanation.Reason,
+	}
+	if explanation.DryRun {
+		lines = append(lines, "Dry run:    the AuthPolicy is audited, so the request is logged and forwarded instead")
+	}
+	_, err := fmt.Fprintln(out, strings.Join(lines, "\n"))
+	return err
+}
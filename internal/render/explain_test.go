@@ -0,0 +1,172 @@
+package render_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kartverket/ztoperator/internal/render"
+	"github.com/kartverket/ztoperator/pkg/explain"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const otherAuthPolicy = `
+apiVersion: ztoperator.kartverket.no/v1alpha1
+kind: AuthPolicy
+metadata:
+  name: other
+spec:
+  enabled: true
+  wellKnownURI: https://idp.example.com/.well-known/openid-configuration
+  selector:
+    matchLabels:
+      app: other
+`
+
+func explainManifests(
+	t *testing.T,
+	manifests string,
+	authPolicyName string,
+	request explain.Request,
+) (explain.Explanation, error) {
+	t.Helper()
+	objects, err := render.LoadObjects(strings.NewReader(manifests), "team")
+	require.NoError(t, err)
+	staticDiscoveryDocument, err := render.LoadDiscoveryDocument(strings.NewReader(discoveryDocument))
+	require.NoError(t, err)
+
+	_, explanation, err := render.Explain(
+		context.Background(),
+		objects,
+		[]rest.StaticDiscoveryDocument{*staticDiscoveryDocument},
+		authPolicyName,
+		request,
+	)
+	return explanation, err
+}
+
+func TestExplain_ResolvesAuthPolicy(t *testing.T) {
+	// 1. Arrange
+	request := explain.Request{Method: "GET", Path: "/public"}
+
+	// 2. Act
+	explanation, err := explainManifests(t, autoLoginAuthPolicy, "", request)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, explain.DecisionBypassLogin, explanation.Decision)
+	assert.Equal(t, "ignoreAuthRules[0]", explanation.Rule)
+}
+
+func TestExplain_UsesResolvedAudiences(t *testing.T) {
+	// 1. Arrange
+	request := explain.Request{
+		Method: "GET",
+		Path:   "/api/items",
+		Claims: map[string]interface{}{"iss": "https://idp.example.com", "aud": "other-client"},
+	}
+
+	// 2. Act
+	explanation, err := explainManifests(t, autoLoginAuthPolicy, "team/app", request)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, explain.DecisionDeny, explanation.Decision)
+	assert.Equal(t, "authRules[0]", explanation.Rule)
+	assert.Contains(t, explanation.Reason, "[my-client]")
+}
+
+func TestExplain_WithSeveralAuthPolicies_RequiresName(t *testing.T) {
+	// 1. Arrange
+	manifests := autoLoginAuthPolicy + "---" + otherAuthPolicy
+	request := explain.Request{Method: "GET", Path: "/public"}
+
+	// 2. Act
+	_, err := explainManifests(t, manifests, "", request)
+	_, otherErr := explainManifests(t, manifests, "app", request)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "several AuthPolicies found, select one of team/app, team/other")
+	assert.NoError(t, otherErr)
+}
+
+func TestRunExplain(t *testing.T) {
+	directory := t.TempDir()
+	discoveryDocumentFile := filepath.Join(directory, "discovery-document.yaml")
+	require.NoError(t, os.WriteFile(discoveryDocumentFile, []byte(discoveryDocument), 0o600))
+	claimsFile := filepath.Join(directory, "claims.json")
+	claims := `{"iss": "https://idp.example.com", "aud": "my-client"}`
+	require.NoError(t, os.WriteFile(claimsFile, []byte(claims), 0o600))
+
+	tests := []struct {
+		name         string
+		args         []string
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+	}{
+		{
+			name:         "no path",
+			args:         []string{"-"},
+			wantExitCode: 2,
+			wantStderr:   "Usage: ztoperator explain",
+		},
+		{
+			name:         "malformed header",
+			args:         []string{"-path", "/public", "-H", "x-beta", "-"},
+			wantExitCode: 2,
+			wantStderr:   "error: header \"x-beta\" must be given as 'name: value'",
+		},
+		{
+			name:         "invalid token",
+			args:         []string{"-path", "/public", "-token", "not-a-jwt", "-"},
+			wantExitCode: 1,
+			wantStderr:   "error: token is not a JWT",
+		},
+		{
+			name:         "request without token",
+			args:         []string{"-discovery-document", discoveryDocumentFile, "-path", "/api/items", "-"},
+			wantExitCode: 0,
+			wantStdout:   "AuthPolicy: default/app\nDecision:   Redirect (302)\nRule:       authRules[0]\n",
+		},
+		{
+			name: "request with claims",
+			args: []string{
+				"-discovery-document", discoveryDocumentFile,
+				"-method", "post",
+				"-path", "/api/items",
+				"-claims", claimsFile,
+				"-",
+			},
+			wantExitCode: 0,
+			wantStdout:   "Decision:   Allow\nRule:       authRules[0]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			var stdout, stderr bytes.Buffer
+
+			// 2. Act
+			exitCode := render.RunExplain(
+				context.Background(),
+				tt.args,
+				strings.NewReader(autoLoginAuthPolicy),
+				&stdout,
+				&stderr,
+			)
+
+			// 3. Assert
+			assert.Equal(t, tt.wantExitCode, exitCode)
+			assert.Contains(t, stdout.String(), tt.wantStdout)
+			assert.Contains(t, stderr.String(), tt.wantStderr)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadObjects decodes the objects of a stream of YAML or JSON documents. Objects without a namespace are put in
+// defaultNamespace, unless they are cluster-scoped. Objects of kinds unknown to ztoperator are skipped, so that all
+// manifests of an application can be given at once. Gateways are kept as unstructured objects, as the listeners of a
+// targeted Gateway decide where auto-login is applied.
+func LoadObjects(reader io.Reader, defaultNamespace string) ([]client.Object, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(reader, 4096)
+	deserializer := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+
+	var objects []client.Object
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("failed to parse document: %w", err)
+		}
+		if len(raw.Raw) == 0 || string(raw.Raw) == "null" {
+			continue
+		}
+
+		decoded, groupVersionKind, err := deserializer.Decode(raw.Raw, nil, nil)
+		if err != nil {
+			if !runtime.IsNotRegisteredError(err) {
+				return nil, fmt.Errorf("failed to decode document: %w", err)
+			}
+			if groupVersionKind == nil || *groupVersionKind != resolver.GatewayGroupVersionKind {
+				continue
+			}
+			gateway := &unstructured.Unstructured{}
+			if err := gateway.UnmarshalJSON(raw.Raw); err != nil {
+				return nil, fmt.Errorf("failed to decode Gateway: %w", err)
+			}
+			decoded = gateway
+		}
+		object, ok := decoded.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("unsupported object of kind %s", decoded.GetObjectKind().GroupVersionKind().Kind)
+		}
+		if object.GetNamespace() == "" && !isClusterScoped(object) {
+			object.SetNamespace(defaultNamespace)
+		}
+		if secret, isSecret := object.(*v1.Secret); isSecret {
+			mergeStringData(secret)
+		}
+		objects = append(objects, object)
+	}
+}
+
+// LoadDiscoveryDocument decodes a discovery document given in YAML or JSON, along with the well-known URI it is served
+// from, in the same format as the entries of the static discovery documents ConfigMap.
+func LoadDiscoveryDocument(reader io.Reader) (*rest.StaticDiscoveryDocument, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var discoveryDocument rest.StaticDiscoveryDocument
+	if err := yaml.Unmarshal(content, &discoveryDocument); err != nil {
+		return nil, fmt.Errorf("failed to parse discovery document: %w", err)
+	}
+	return &discoveryDocument, nil
+}
+
+// mergeStringData moves the stringData of the Secret into its data, as the API server does when the Secret is written.
+func mergeStringData(secret *v1.Secret) {
+	if len(secret.StringData) == 0 {
+		return
+	}
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte, len(secret.StringData))
+	}
+	for key, value := range secret.StringData {
+		secret.Data[key] = []byte(value)
+	}
+	secret.StringData = nil
+}
+
+func isClusterScoped(object client.Object) bool {
+	switch object.(type) {
+	case *v1.Namespace, *ztoperatorv1alpha1.IdentityProvider, *ztoperatorv1alpha1.ClusterAuthPolicy:
+		return true
+	}
+	return false
+}
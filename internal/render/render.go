@@ -0,0 +1,296 @@
+package render
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/controller"
+	"github.com/kartverket/ztoperator/internal/names"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/log"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/kartverket/ztoperator/pkg/reconciliation"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	"istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// RedactedValue replaces the value of every key of a rendered Secret.
+const RedactedValue = "REDACTED"
+
+// SignalHeaderSuffixPlaceholder stands in for the random suffix of the signal header names of an AuthPolicy with
+// auto-login, which ztoperator generates when the AuthPolicy is first reconciled and keeps in its generated Secret.
+const SignalHeaderSuffixPlaceholder = "rendered"
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(istioclientsecurityv1.AddToScheme(scheme))
+	utilruntime.Must(v1alpha3.AddToScheme(scheme))
+	utilruntime.Must(ztoperatorv1alpha1.AddToScheme(scheme))
+}
+
+// Render writes the resources ztoperator would generate for every AuthPolicy among the objects as a YAML stream,
+// without a cluster. The other objects, such as Secrets, ConfigMaps, IdentityProviders and ClusterAuthPolicies, are
+// served to the resolvers in place of the cluster, and discovery documents are only read from discoveryDocuments.
+//
+// The output is stable, so that it can be used as golden files: resources are written in the order they are
+// reconciled in, and the data of Secrets, which holds credentials and a random HMAC secret, is redacted. The signal
+// header names of auto-login end with SignalHeaderSuffixPlaceholder instead of the random suffix used in the cluster,
+// unless the generated Secret of the AuthPolicy is among the objects.
+func Render(
+	ctx context.Context,
+	objects []client.Object,
+	discoveryDocuments []rest.StaticDiscoveryDocument,
+	out io.Writer,
+) error {
+	cluster, err := newOfflineCluster(ctx, objects, discoveryDocuments)
+	if err != nil {
+		return err
+	}
+	for _, authPolicy := range cluster.authPolicies {
+		if err := renderAuthPolicy(ctx, cluster, authPolicy, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// offlineCluster serves the given objects and discovery documents to the resolvers in place of a cluster.
+type offlineCluster struct {
+	k8sClient                 client.Client
+	discoveryDocumentResolver discoveryDocumentResolver
+	// authPolicies holds the AuthPolicies among the objects, sorted by namespace and name.
+	authPolicies []*ztoperatorv1alpha1.AuthPolicy
+}
+
+func newOfflineCluster(
+	ctx context.Context,
+	objects []client.Object,
+	discoveryDocuments []rest.StaticDiscoveryDocument,
+) (*offlineCluster, error) {
+	documents, err := newDiscoveryDocumentResolver(discoveryDocuments)
+	if err != nil {
+		return nil, err
+	}
+
+	var authPolicies []*ztoperatorv1alpha1.AuthPolicy
+	namespaces := map[string]bool{}
+	secrets := map[types.NamespacedName]*v1.Secret{}
+	for _, object := range objects {
+		switch o := object.(type) {
+		case *ztoperatorv1alpha1.AuthPolicy:
+			authPolicies = append(authPolicies, o)
+		case *ztoperatorv1alpha1.IdentityProvider:
+			checkIdentityProvider(ctx, o, documents)
+		case *v1.Namespace:
+			namespaces[o.Name] = true
+		case *v1.Secret:
+			secrets[client.ObjectKeyFromObject(o)] = o
+		}
+	}
+	if len(authPolicies) == 0 {
+		return nil, errors.New("no AuthPolicy found")
+	}
+
+	// The signal header suffix is read from the generated Secret of the AuthPolicy, and would otherwise be random
+	for _, authPolicy := range authPolicies {
+		envoySecretKey := types.NamespacedName{Namespace: authPolicy.Namespace, Name: names.EnvoySecret(authPolicy.Name)}
+		envoySecret, exists := secrets[envoySecretKey]
+		if !exists {
+			envoySecret = &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: envoySecretKey.Namespace, Name: envoySecretKey.Name},
+			}
+			objects = append(objects, envoySecret)
+		}
+		if len(envoySecret.Data[luascript.SignalHeaderSuffixSecretKey]) == 0 {
+			if envoySecret.Data == nil {
+				envoySecret.Data = map[string][]byte{}
+			}
+			envoySecret.Data[luascript.SignalHeaderSuffixSecretKey] = []byte(SignalHeaderSuffixPlaceholder)
+		}
+	}
+
+	// ClusterAuthPolicies are matched against the labels of the namespace of the AuthPolicy, which therefore has to
+	// exist. Namespaces which are not given are rendered as namespaces without labels.
+	for _, authPolicy := range authPolicies {
+		if !namespaces[authPolicy.Namespace] {
+			objects = append(objects, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: authPolicy.Namespace}})
+			namespaces[authPolicy.Namespace] = true
+		}
+	}
+
+	slices.SortFunc(authPolicies, func(a, b *ztoperatorv1alpha1.AuthPolicy) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+	return &offlineCluster{
+		k8sClient:                 fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		discoveryDocumentResolver: documents,
+		authPolicies:              authPolicies,
+	}, nil
+}
+
+// resolve resolves and validates the AuthPolicy the same way as when it is reconciled, and returns the resources
+// generated for it.
+func (c *offlineCluster) resolve(
+	ctx context.Context,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+) (*state.Scope, []reconciliation.ControllerResource, error) {
+	scope, controllerResources, err := controller.DesiredResources(
+		ctx,
+		c.k8sClient,
+		authPolicy,
+		c.discoveryDocumentResolver,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve AuthPolicy %s/%s: %w", authPolicy.Namespace, authPolicy.Name, err)
+	}
+	return scope, controllerResources, nil
+}
+
+func renderAuthPolicy(
+	ctx context.Context,
+	cluster *offlineCluster,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+	out io.Writer,
+) error {
+	scope, controllerResources, err := cluster.resolve(ctx, authPolicy)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(out, "# Source: AuthPolicy %s/%s\n", authPolicy.Namespace, authPolicy.Name); err != nil {
+		return err
+	}
+	if scope.InvalidConfig && scope.ValidationErrorMessage != nil {
+		if _, err := fmt.Fprintf(out, "# Invalid configuration: %s\n", *scope.ValidationErrorMessage); err != nil {
+			return err
+		}
+	}
+	for _, controllerResource := range controllerResources {
+		desired := controllerResource.GetDesiredResource()
+		if desired == nil {
+			continue
+		}
+		manifest, err := toManifest(desired)
+		if err != nil {
+			return fmt.Errorf(
+				"failed to render %s %s: %w",
+				controllerResource.GetResourceKind(),
+				controllerResource.GetResourceName(),
+				err,
+			)
+		}
+		if _, err := fmt.Fprintf(out, "---\n%s", manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIdentityProvider sets the status of the IdentityProvider the way the IdentityProvider controller does, using
+// the given discovery documents. The JWKS of the identity provider is not fetched, as it is not rendered.
+func checkIdentityProvider(
+	ctx context.Context,
+	identityProvider *ztoperatorv1alpha1.IdentityProvider,
+	discoveryDocumentResolver rest.DiscoveryDocumentResolver,
+) {
+	identityProviderUris, err := resolver.ResolveIdentityProviderDiscoveryDocument(
+		ctx,
+		identityProvider,
+		discoveryDocumentResolver,
+	)
+	identityProvider.Status = controller.BuildIdentityProviderStatus(identityProvider, identityProviderUris, nil, err)
+}
+
+// toManifest returns the object as YAML, with its kind set and without the fields set by the API server.
+func toManifest(object client.Object) ([]byte, error) {
+	object, ok := object.DeepCopyObject().(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("failed to copy %T", object)
+	}
+	gvk, err := apiutil.GVKForObject(object, scheme)
+	if err != nil {
+		return nil, err
+	}
+	object.GetObjectKind().SetGroupVersionKind(gvk)
+	if secret, isSecret := object.(*v1.Secret); isSecret {
+		redact(secret)
+	}
+
+	objectJSON, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	var manifest map[string]interface{}
+	if err := json.Unmarshal(objectJSON, &manifest); err != nil {
+		return nil, err
+	}
+	delete(manifest, "status")
+	if metadata, ok := manifest["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	return yaml.Marshal(manifest)
+}
+
+// redact replaces the value of every key of the Secret with RedactedValue.
+func redact(secret *v1.Secret) {
+	stringData := make(map[string]string, len(secret.Data)+len(secret.StringData))
+	for key := range secret.Data {
+		stringData[key] = RedactedValue
+	}
+	for key := range secret.StringData {
+		stringData[key] = RedactedValue
+	}
+	secret.Data = nil
+	secret.StringData = stringData
+}
+
+// discoveryDocumentResolver serves the given discovery documents by well-known URI, so that rendering never reaches out
+// to an identity provider.
+type discoveryDocumentResolver map[string]rest.DiscoveryDocument
+
+func newDiscoveryDocumentResolver(
+	discoveryDocuments []rest.StaticDiscoveryDocument,
+) (discoveryDocumentResolver, error) {
+	documents := discoveryDocumentResolver{}
+	for _, discoveryDocument := range discoveryDocuments {
+		if discoveryDocument.WellKnownURI == "" {
+			return nil, errors.New("discovery document must set wellKnownURI")
+		}
+		if _, exists := documents[discoveryDocument.WellKnownURI]; exists {
+			return nil, fmt.Errorf("duplicate discovery document for well-known uri %s", discoveryDocument.WellKnownURI)
+		}
+		documents[discoveryDocument.WellKnownURI] = discoveryDocument.DiscoveryDocument
+	}
+	return documents, nil
+}
+
+func (r discoveryDocumentResolver) GetOAuthDiscoveryDocument(
+	uri string,
+	_ log.Logger,
+) (*rest.DiscoveryDocument, error) {
+	discoveryDocument, ok := r[uri]
+	if !ok {
+		return nil, fmt.Errorf("no discovery document given for well-known uri %s", uri)
+	}
+	return &discoveryDocument, nil
+}
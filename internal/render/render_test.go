@@ -0,0 +1,409 @@
+package render_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/render"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+const autoLoginAuthPolicy = `
+apiVersion: ztoperator.kartverket.no/v1alpha1
+kind: AuthPolicy
+metadata:
+  name: app
+spec:
+  enabled: true
+  wellKnownURI: https://idp.example.com/.well-known/openid-configuration
+  allowedAudiences:
+    - value: my-client
+  selector:
+    matchLabels:
+      app: app
+  oAuthCredentials:
+    secretRef: app-oauth
+    clientIDKey: CLIENT_ID
+    clientSecretKey: CLIENT_SECRET
+  autoLogin:
+    enabled: true
+    loginPath: /login
+    redirectPath: /oauth2/callback
+    logoutPath: /logout
+    scopes:
+      - openid
+  ignoreAuthRules:
+    - paths:
+        - /public
+  authRules:
+    - paths:
+        - "/api/{**}"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-oauth
+stringData:
+  CLIENT_ID: my-client
+  CLIENT_SECRET: very-secret-client-secret
+`
+
+const discoveryDocument = `
+wellKnownURI: https://idp.example.com/.well-known/openid-configuration
+issuer: https://idp.example.com
+authorization_endpoint: https://idp.example.com/authorize
+token_endpoint: https://idp.example.com/token
+jwks_uri: https://idp.example.com/jwks
+end_session_endpoint: https://idp.example.com/endsession
+`
+
+func renderManifests(t *testing.T, manifests string, discoveryDocuments ...string) (string, error) {
+	t.Helper()
+	objects, err := render.LoadObjects(strings.NewReader(manifests), "team")
+	require.NoError(t, err)
+	var staticDiscoveryDocuments []rest.StaticDiscoveryDocument
+	for _, document := range discoveryDocuments {
+		staticDiscoveryDocument, loadErr := render.LoadDiscoveryDocument(strings.NewReader(document))
+		require.NoError(t, loadErr)
+		staticDiscoveryDocuments = append(staticDiscoveryDocuments, *staticDiscoveryDocument)
+	}
+
+	var out bytes.Buffer
+	err = render.Render(context.Background(), objects, staticDiscoveryDocuments, &out)
+	return out.String(), err
+}
+
+func TestRender_WithAutoLogin_RendersResourcesInReconcileOrder(t *testing.T) {
+	// 1. Arrange
+	manifests := autoLoginAuthPolicy
+
+	// 2. Act
+	out, err := renderManifests(t, manifests, discoveryDocument)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out, "# Source: AuthPolicy team/app\n"))
+	var names []string
+	for _, document := range strings.Split(out, "---\n")[1:] {
+		objects, loadErr := render.LoadObjects(strings.NewReader(document), "")
+		require.NoError(t, loadErr)
+		require.Len(t, objects, 1)
+		assert.Equal(t, "team", objects[0].GetNamespace())
+		names = append(names, objects[0].GetObjectKind().GroupVersionKind().Kind+"/"+objects[0].GetName())
+	}
+	assert.Equal(t, []string{
+		"Secret/app-envoy-secret",
+		"EnvoyFilter/app-login",
+		"RequestAuthentication/app",
+		"AuthorizationPolicy/app-deny-auth-rules",
+		"AuthorizationPolicy/app-ignore-auth",
+		"AuthorizationPolicy/app-require-auth",
+	}, names)
+	assert.Contains(t, out, "jwksUri: https://idp.example.com/jwks")
+	assert.Contains(t, out, "envoy.filters.http.lua")
+	assert.NotContains(t, out, "creationTimestamp")
+	assert.NotContains(t, out, "status:")
+}
+
+func TestRender_WithAutoLogin_RedactsSecretData(t *testing.T) {
+	// 1. Arrange
+	manifests := autoLoginAuthPolicy
+
+	// 2. Act
+	out, err := renderManifests(t, manifests, discoveryDocument)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.NotContains(t, out, "very-secret-client-secret")
+	assert.Contains(t, out, "hmac-secret.yaml: "+render.RedactedValue)
+	assert.Contains(t, out, "token-secret.yaml: "+render.RedactedValue)
+}
+
+func TestRender_WithAutoLogin_UsesSignalHeaderSuffixPlaceholder(t *testing.T) {
+	// 1. Arrange
+	manifests := autoLoginAuthPolicy
+
+	// 2. Act
+	out, err := renderManifests(t, manifests, discoveryDocument)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Contains(t, out, "x-ztoperator-bypass-login-"+render.SignalHeaderSuffixPlaceholder)
+	assert.Contains(t, out, "x-ztoperator-deny-redirect-"+render.SignalHeaderSuffixPlaceholder)
+}
+
+func TestRender_WithGeneratedSecret_UsesSignalHeaderSuffixOfCluster(t *testing.T) {
+	// 1. Arrange
+	manifests := autoLoginAuthPolicy + `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-envoy-secret
+stringData:
+  signal-header-suffix: 6f1c2b1e3d4a4c5b
+`
+
+	// 2. Act
+	out, err := renderManifests(t, manifests, discoveryDocument)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Contains(t, out, "x-ztoperator-bypass-login-6f1c2b1e3d4a4c5b")
+	assert.NotContains(t, out, "x-ztoperator-bypass-login-"+render.SignalHeaderSuffixPlaceholder)
+}
+
+func TestRender_IsStable(t *testing.T) {
+	// 1. Arrange
+	manifests := autoLoginAuthPolicy
+
+	// 2. Act
+	out, err := renderManifests(t, manifests, discoveryDocument)
+	require.NoError(t, err)
+	otherOut, otherErr := renderManifests(t, manifests, discoveryDocument)
+
+	// 3. Assert
+	require.NoError(t, otherErr)
+	assert.Equal(t, out, otherOut)
+}
+
+func TestRender_WithoutDiscoveryDocument_ReturnsError(t *testing.T) {
+	// 1. Arrange
+	manifests := autoLoginAuthPolicy
+
+	// 2. Act
+	_, err := renderManifests(t, manifests)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to resolve AuthPolicy team/app")
+	assert.Contains(
+		t,
+		err.Error(),
+		"no discovery document given for well-known uri https://idp.example.com/.well-known/openid-configuration",
+	)
+}
+
+func TestRender_WithoutAuthPolicy_ReturnsError(t *testing.T) {
+	// 1. Arrange
+	manifests := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	// 2. Act
+	_, err := renderManifests(t, manifests)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no AuthPolicy found")
+}
+
+func TestRender_WithInvalidConfig_RendersDenyAllWithValidationError(t *testing.T) {
+	// 1. Arrange
+	manifests := `
+apiVersion: ztoperator.kartverket.no/v1alpha1
+kind: AuthPolicy
+metadata:
+  name: app
+spec:
+  enabled: true
+  wellKnownURI: https://idp.example.com/.well-known/openid-configuration
+  selector:
+    matchLabels:
+      app: app
+  outputClaimToHeaders:
+    - header: x-ztoperator-bypass-login-abc
+      claim: sub
+`
+
+	// 2. Act
+	out, err := renderManifests(t, manifests, discoveryDocument)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Contains(t, out, "# Invalid configuration: ")
+	assert.Contains(t, out, "must not start with x-ztoperator-")
+	assert.Contains(t, out, "name: app-deny-auth-rules")
+}
+
+func TestRender_WithIdentityProviderRef_UsesGivenDiscoveryDocument(t *testing.T) {
+	// 1. Arrange
+	manifests := `
+apiVersion: ztoperator.kartverket.no/v1alpha1
+kind: IdentityProvider
+metadata:
+  name: idp
+spec:
+  wellKnownURI: https://idp.example.com/.well-known/openid-configuration
+---
+apiVersion: ztoperator.kartverket.no/v1alpha1
+kind: AuthPolicy
+metadata:
+  name: app
+spec:
+  enabled: true
+  identityProviderRef: idp
+  selector:
+    matchLabels:
+      app: app
+`
+
+	// 2. Act
+	out, err := renderManifests(t, manifests, discoveryDocument)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Contains(t, out, "issuer: https://idp.example.com")
+	assert.Contains(t, out, "jwksUri: https://idp.example.com/jwks")
+}
+
+func TestLoadObjects(t *testing.T) {
+	// 1. Arrange
+	manifests := `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+stringData:
+  CLIENT_ID: my-client
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: other
+---
+apiVersion: ztoperator.kartverket.no/v1alpha1
+kind: IdentityProvider
+metadata:
+  name: idp
+---
+apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: gateway
+spec:
+  gatewayClassName: istio
+---
+apiVersion: skiperator.kartverket.no/v1alpha1
+kind: Application
+metadata:
+  name: app
+`
+
+	// 2. Act
+	objects, err := render.LoadObjects(strings.NewReader(manifests), "team")
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.Len(t, objects, 4, "objects of unknown kinds should be skipped")
+	secret, isSecret := objects[0].(*v1.Secret)
+	require.True(t, isSecret)
+	assert.Equal(t, "team", secret.Namespace)
+	assert.Equal(t, map[string][]byte{"CLIENT_ID": []byte("my-client")}, secret.Data)
+	assert.Empty(t, secret.StringData)
+	assert.Equal(t, "other", objects[1].GetNamespace())
+	assert.IsType(t, &ztoperatorv1alpha1.IdentityProvider{}, objects[2])
+	assert.Empty(t, objects[2].GetNamespace(), "cluster-scoped objects should not get a namespace")
+	assert.Equal(t, "Gateway", objects[3].GetObjectKind().GroupVersionKind().Kind)
+	assert.Equal(t, "team", objects[3].GetNamespace())
+}
+
+func TestRender_WithAutoLoginOnGateway_MatchesListenersOfGateway(t *testing.T) {
+	// 1. Arrange
+	manifests := strings.Replace(autoLoginAuthPolicy, `  selector:
+    matchLabels:
+      app: app
+`, `  targetRefs:
+    - kind: Gateway
+      name: gateway
+`, 1) + `---
+apiVersion: gateway.networking.k8s.io/v1
+kind: Gateway
+metadata:
+  name: gateway
+spec:
+  gatewayClassName: istio
+  listeners:
+    - name: https
+      protocol: HTTPS
+      port: 443
+      hostname: app.example.com
+`
+
+	// 2. Act
+	out, err := renderManifests(t, manifests, discoveryDocument)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.NotContains(t, out, "# Invalid configuration")
+	assert.Contains(t, out, "context: GATEWAY")
+	assert.Contains(t, out, "portNumber: 443")
+	assert.Contains(t, out, "sni: app.example.com")
+}
+
+func TestRun(t *testing.T) {
+	discoveryDocumentFile := filepath.Join(t.TempDir(), "discovery-document.yaml")
+	require.NoError(t, os.WriteFile(discoveryDocumentFile, []byte(discoveryDocument), 0o600))
+
+	tests := []struct {
+		name         string
+		args         []string
+		stdin        string
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+	}{
+		{
+			name:         "no files",
+			args:         []string{},
+			wantExitCode: 2,
+			wantStderr:   "Usage: ztoperator render",
+		},
+		{
+			name:         "missing file",
+			args:         []string{"does-not-exist.yaml"},
+			wantExitCode: 1,
+			wantStderr:   "error: open does-not-exist.yaml",
+		},
+		{
+			name:         "AuthPolicy from standard input",
+			args:         []string{"-namespace", "team", "-discovery-document", discoveryDocumentFile, "-"},
+			stdin:        autoLoginAuthPolicy,
+			wantExitCode: 0,
+			wantStdout:   "# Source: AuthPolicy team/app\n",
+		},
+		{
+			name:         "AuthPolicy without discovery document",
+			args:         []string{"-"},
+			stdin:        autoLoginAuthPolicy,
+			wantExitCode: 1,
+			wantStderr:   "error: failed to resolve AuthPolicy default/app",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			var stdout, stderr bytes.Buffer
+
+			// 2. Act
+			exitCode := render.Run(context.Background(), tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			// 3. Assert
+			assert.Equal(t, tt.wantExitCode, exitCode)
+			assert.Contains(t, stdout.String(), tt.wantStdout)
+			assert.Contains(t, stderr.String(), tt.wantStderr)
+		})
+	}
+}
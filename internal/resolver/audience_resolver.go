@@ -29,11 +29,12 @@ func ResolveAudiences(
 			}
 			resolvedAudiences = append(resolvedAudiences, *audience.Value)
 		} else if audience.ValueFrom != nil {
-			resolvedAudienceRef, resolvedAudienceRefErr := resolveAudienceRef(
+			resolvedAudienceRef, resolvedAudienceRefErr := resolveValueFrom(
 				ctx,
 				k8sClient,
 				namespace,
 				*audience.ValueFrom,
+				"audience",
 			)
 			if resolvedAudienceRefErr != nil {
 				return nil, fmt.Errorf("failed to resolve audience reference: %w", resolvedAudienceRefErr)
@@ -44,11 +45,14 @@ func ResolveAudiences(
 	return &resolvedAudiences, nil
 }
 
-func resolveAudienceRef(
+// resolveValueFrom resolves the value referenced by valueFrom from a ConfigMap or Secret in the given namespace.
+// The description names the value in error messages.
+func resolveValueFrom(
 	ctx context.Context,
 	k8sClient client.Client,
 	namespace string,
 	valueFrom ztoperatorv1alpha1.ValueFrom,
+	description string,
 ) (*string, error) {
 	if valueFrom.ConfigMapKeyRef != nil && valueFrom.SecretKeyRef != nil {
 		return nil, errors.New("cannot get value from both ConfigMap and Secret")
@@ -66,7 +70,8 @@ func resolveAudienceRef(
 		value := configMap.Data[valueFrom.ConfigMapKeyRef.Key]
 		if value == "" {
 			return nil, fmt.Errorf(
-				"audience value from configmap %s/%s key %s is empty or missing",
+				"%s value from configmap %s/%s key %s is empty or missing",
+				description,
 				namespace,
 				valueFrom.ConfigMapKeyRef.Name,
 				valueFrom.ConfigMapKeyRef.Key,
@@ -91,7 +96,8 @@ func resolveAudienceRef(
 	value := string(secret.Data[valueFrom.SecretKeyRef.Key])
 	if value == "" {
 		return nil, fmt.Errorf(
-			"audience value from secret %s/%s key %s is empty or missing",
+			"%s value from secret %s/%s key %s is empty or missing",
+			description,
 			namespace,
 			valueFrom.SecretKeyRef.Name,
 			valueFrom.SecretKeyRef.Key,
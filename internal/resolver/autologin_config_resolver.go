@@ -1,16 +1,25 @@
 package resolver
 
 import (
+	"context"
+	"fmt"
+
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/names"
 	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
 	"github.com/kartverket/ztoperator/pkg/luascript"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// ResolveAutoLoginConfig constructs the AutoLoginConfig from the AuthPolicy spec and resolved identity provider URIs.
+// ResolveAutoLoginConfig constructs the AutoLoginConfig from the AuthPolicy spec, the resolved identity provider URIs
+// and the suffix of the signal header names resolved by ResolveSignalHeaderSuffix.
 func ResolveAutoLoginConfig(
 	authPolicy *ztoperatorv1alpha1.AuthPolicy,
 	identityProviderUris state.IdentityProviderUris,
+	signalHeaderSuffix string,
 ) state.AutoLoginConfig {
 	envoySecretName := names.EnvoySecret(authPolicy.Name)
 
@@ -28,6 +37,7 @@ func ResolveAutoLoginConfig(
 		Scopes:                authPolicy.Spec.AutoLogin.Scopes,
 		LoginParams:           authPolicy.Spec.AutoLogin.LoginParams,
 		EnvoySecretName:       envoySecretName,
+		SignalHeaderSuffix:    signalHeaderSuffix,
 	}
 
 	autoLoginConfig.SetSaneDefaults(*authPolicy.Spec.AutoLogin)
@@ -42,3 +52,29 @@ func ResolveAutoLoginConfig(
 
 	return autoLoginConfig
 }
+
+// ResolveSignalHeaderSuffix returns the suffix of the signal header names of an AuthPolicy with auto-login enabled. The
+// suffix kept in the generated Secret of the AuthPolicy is reused, and a random suffix is generated when there is
+// none yet. Returns an empty suffix when auto-login is disabled.
+func ResolveSignalHeaderSuffix(
+	ctx context.Context,
+	k8sClient client.Client,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+) (string, error) {
+	if authPolicy.Spec.AutoLogin == nil || !authPolicy.Spec.AutoLogin.Enabled {
+		return "", nil
+	}
+
+	envoySecretName := names.EnvoySecret(authPolicy.Name)
+	envoySecret, err := helperfunctions.GetSecret(ctx, k8sClient, types.NamespacedName{
+		Namespace: authPolicy.Namespace,
+		Name:      envoySecretName,
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get Secret %s/%s: %w", authPolicy.Namespace, envoySecretName, err)
+	}
+	if suffix := string(envoySecret.Data[luascript.SignalHeaderSuffixSecretKey]); suffix != "" {
+		return suffix, nil
+	}
+	return luascript.GenerateSignalHeaderSuffix()
+}
@@ -1,14 +1,17 @@
 package resolver_test
 
 import (
+	"context"
 	"testing"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/resolver"
 	"github.com/kartverket/ztoperator/internal/state"
 	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/luascript"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -18,7 +21,7 @@ func TestResolveAutoLoginConfig_WithAutoLoginDisabled_ReturnsDisabledConfig(t *t
 	identityProviderUris := createTestIdentityProviderUris()
 
 	// 2. Act
-	result := resolver.ResolveAutoLoginConfig(authPolicy, identityProviderUris)
+	result := resolver.ResolveAutoLoginConfig(authPolicy, identityProviderUris, "6f1c2b1e3d4a4c5b")
 
 	// 3. Assert
 	assert.False(t, result.Enabled, "AutoLogin should be disabled")
@@ -37,7 +40,7 @@ func TestResolveAutoLoginConfig_WithAutoLoginNil_ReturnsDisabledConfig(t *testin
 	identityProviderUris := createTestIdentityProviderUris()
 
 	// 2. Act
-	result := resolver.ResolveAutoLoginConfig(authPolicy, identityProviderUris)
+	result := resolver.ResolveAutoLoginConfig(authPolicy, identityProviderUris, "6f1c2b1e3d4a4c5b")
 
 	// 3. Assert
 	assert.False(t, result.Enabled, "AutoLogin should be disabled when nil")
@@ -58,7 +61,7 @@ func TestResolveAutoLoginConfig_WithBasicAutoLogin_ReturnsConfigWithDefaults(t *
 	identityProviderUris := createTestIdentityProviderUris()
 
 	// 2. Act
-	result := resolver.ResolveAutoLoginConfig(authPolicy, identityProviderUris)
+	result := resolver.ResolveAutoLoginConfig(authPolicy, identityProviderUris, "6f1c2b1e3d4a4c5b")
 
 	// 3. Assert
 	assert.True(t, result.Enabled, "AutoLogin should be enabled")
@@ -66,6 +69,13 @@ func TestResolveAutoLoginConfig_WithBasicAutoLogin_ReturnsConfigWithDefaults(t *
 	assert.NotEmpty(t, result.LogoutPath, "LogoutPath should have default value")
 	assert.NotEmpty(t, result.LuaScriptConfig.LuaScript, "LuaScript should be generated")
 	assert.Equal(t, "test-policy-envoy-secret", result.EnvoySecretName, "EnvoySecretName should be set")
+	assert.Equal(t, "6f1c2b1e3d4a4c5b", result.SignalHeaderSuffix, "SignalHeaderSuffix should be set")
+	assert.Contains(
+		t,
+		result.LuaScriptConfig.LuaScript,
+		"x-ztoperator-bypass-login-6f1c2b1e3d4a4c5b",
+		"Lua script should use the signal header suffix",
+	)
 }
 
 func TestResolveAutoLoginConfig_WithCustomConfiguration_PreservesAllValues(t *testing.T) {
@@ -88,7 +98,7 @@ func TestResolveAutoLoginConfig_WithCustomConfiguration_PreservesAllValues(t *te
 	identityProviderUris := createTestIdentityProviderUris()
 
 	// 2. Act
-	result := resolver.ResolveAutoLoginConfig(authPolicy, identityProviderUris)
+	result := resolver.ResolveAutoLoginConfig(authPolicy, identityProviderUris, "6f1c2b1e3d4a4c5b")
 
 	// 3. Assert
 	assert.True(t, result.Enabled, "AutoLogin should be enabled")
@@ -123,6 +133,57 @@ func TestResolveAutoLoginConfig_WithCustomConfiguration_PreservesAllValues(t *te
 	)
 }
 
+func TestResolveSignalHeaderSuffix_WithExistingEnvoySecret_ReusesSuffix(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := createTestAuthPolicy("test-policy", &ztoperatorv1alpha1.AutoLogin{Enabled: true})
+	k8sClient := createFakeClientForOauthCredentials(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-policy-envoy-secret", Namespace: "default"},
+		Data:       map[string][]byte{luascript.SignalHeaderSuffixSecretKey: []byte("6f1c2b1e3d4a4c5b")},
+	})
+
+	// 2. Act
+	suffix, err := resolver.ResolveSignalHeaderSuffix(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, "6f1c2b1e3d4a4c5b", suffix, "The suffix of the generated Secret should be kept across reconciles")
+}
+
+func TestResolveSignalHeaderSuffix_WithoutEnvoySecret_GeneratesRandomSuffix(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := createTestAuthPolicy("test-policy", &ztoperatorv1alpha1.AutoLogin{Enabled: true})
+	k8sClient := createFakeClientForOauthCredentials()
+
+	// 2. Act
+	suffix, err := resolver.ResolveSignalHeaderSuffix(ctx, k8sClient, authPolicy)
+	otherSuffix, otherErr := resolver.ResolveSignalHeaderSuffix(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NoError(t, otherErr)
+	assert.Regexp(t, "^[0-9a-f]{16}$", suffix)
+	assert.NotEqual(t, suffix, otherSuffix, "The suffix should not be derived from the AuthPolicy")
+}
+
+func TestResolveSignalHeaderSuffix_WithAutoLoginDisabled_ReturnsEmptySuffix(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := createTestAuthPolicy("test-policy", &ztoperatorv1alpha1.AutoLogin{Enabled: false})
+	k8sClient := createFakeClientForOauthCredentials()
+
+	// 2. Act
+	suffix, err := resolver.ResolveSignalHeaderSuffix(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Empty(t, suffix)
+}
+
 func createTestAuthPolicy(name string, autoLogin *ztoperatorv1alpha1.AutoLogin) *ztoperatorv1alpha1.AuthPolicy {
 	return &ztoperatorv1alpha1.AuthPolicy{
 		ObjectMeta: metav1.ObjectMeta{
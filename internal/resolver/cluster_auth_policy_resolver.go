@@ -0,0 +1,351 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/log"
+	"github.com/kartverket/ztoperator/pkg/validation"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResolveClusterAuthPolicies returns the ClusterAuthPolicies whose namespace selector matches the namespace of
+// the AuthPolicy, sorted by name.
+func ResolveClusterAuthPolicies(
+	ctx context.Context,
+	k8sClient client.Client,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+) ([]ztoperatorv1alpha1.ClusterAuthPolicy, error) {
+	rLog := log.GetLogger(ctx)
+
+	clusterAuthPolicyList := &ztoperatorv1alpha1.ClusterAuthPolicyList{}
+	if err := k8sClient.List(ctx, clusterAuthPolicyList); err != nil {
+		return nil, fmt.Errorf("failed to list ClusterAuthPolicies: %w", err)
+	}
+	if len(clusterAuthPolicyList.Items) == 0 {
+		return nil, nil
+	}
+
+	namespace := &v1.Namespace{}
+	if err := k8sClient.Get(ctx, client.ObjectKey{Name: authPolicy.Namespace}, namespace); err != nil {
+		return nil, fmt.Errorf("failed to get namespace %s: %w", authPolicy.Namespace, err)
+	}
+
+	var clusterAuthPolicies []ztoperatorv1alpha1.ClusterAuthPolicy
+	for _, clusterAuthPolicy := range clusterAuthPolicyList.Items {
+		matches, err := clusterAuthPolicy.MatchesNamespace(namespace.Labels)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to evaluate namespace selector of ClusterAuthPolicy %s: %w",
+				clusterAuthPolicy.Name,
+				err,
+			)
+		}
+		if matches {
+			rLog.Debug(fmt.Sprintf(
+				"ClusterAuthPolicy %s applies to AuthPolicy %s/%s",
+				clusterAuthPolicy.Name,
+				authPolicy.Namespace,
+				authPolicy.Name,
+			))
+			clusterAuthPolicies = append(clusterAuthPolicies, clusterAuthPolicy)
+		}
+	}
+
+	slices.SortFunc(clusterAuthPolicies, func(a, b ztoperatorv1alpha1.ClusterAuthPolicy) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return clusterAuthPolicies, nil
+}
+
+// MergeClusterAuthPolicies returns a copy of the AuthPolicy with the baseline auth, auth rules and ignore auth rules
+// of the given ClusterAuthPolicies merged into its spec.
+//
+// Baseline conditions of all policies must be met. Auth rules and ignore auth rules are appended.
+// When a path ignored by one policy is covered by an auth rule of another for an overlapping set of methods,
+// the path is removed from the ignore auth rule and reported as a conflict, so that authentication is never skipped.
+// When the paths only partially overlap, such as /api* and /api/admin, the ignored path is kept and the overlap is
+// reported as a conflict, as auth rules take precedence over ignore auth rules for the requests matching both.
+//
+// A ClusterAuthPolicy with baseline auth only allows the paths it ignores itself to skip authentication. A path ignored
+// by the AuthPolicy or another ClusterAuthPolicy is removed from the ignore auth rule and reported as a conflict, unless
+// it is covered by an ignore auth rule of each such ClusterAuthPolicy for all of its methods.
+func MergeClusterAuthPolicies(
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+	clusterAuthPolicies []ztoperatorv1alpha1.ClusterAuthPolicy,
+) (*ztoperatorv1alpha1.AuthPolicy, []ztoperatorv1alpha1.RuleConflict) {
+	merged := authPolicy.DeepCopy()
+	if len(clusterAuthPolicies) == 0 {
+		return merged, nil
+	}
+
+	var authRules []sourcedRequestAuthRule
+	var ignoreAuthRules []sourcedRequestMatcher
+	if merged.Spec.AuthRules != nil {
+		for _, authRule := range *merged.Spec.AuthRules {
+			authRules = append(authRules, sourcedRequestAuthRule{authRule: authRule})
+		}
+	}
+	if merged.Spec.IgnoreAuthRules != nil {
+		for _, ignoreAuthRule := range *merged.Spec.IgnoreAuthRules {
+			ignoreAuthRules = append(ignoreAuthRules, sourcedRequestMatcher{matcher: ignoreAuthRule})
+		}
+	}
+
+	for _, clusterAuthPolicy := range clusterAuthPolicies {
+		merged.Spec.BaselineAuth = mergeBaselineAuth(merged.Spec.BaselineAuth, clusterAuthPolicy.Spec.BaselineAuth)
+		if clusterAuthPolicy.Spec.AuthRules != nil {
+			for _, authRule := range *clusterAuthPolicy.Spec.AuthRules {
+				authRules = append(authRules, sourcedRequestAuthRule{
+					clusterAuthPolicy: clusterAuthPolicy.Name,
+					authRule:          *authRule.DeepCopy(),
+				})
+			}
+		}
+		if clusterAuthPolicy.Spec.IgnoreAuthRules != nil {
+			for _, ignoreAuthRule := range *clusterAuthPolicy.Spec.IgnoreAuthRules {
+				ignoreAuthRules = append(ignoreAuthRules, sourcedRequestMatcher{
+					clusterAuthPolicy: clusterAuthPolicy.Name,
+					matcher:           *ignoreAuthRule.DeepCopy(),
+				})
+			}
+		}
+	}
+
+	var conflicts []ztoperatorv1alpha1.RuleConflict
+	mergedIgnoreAuthRules := make([]ztoperatorv1alpha1.RequestMatcher, 0, len(ignoreAuthRules))
+	for _, ignoreAuthRule := range ignoreAuthRules {
+		var paths []string
+		for _, path := range ignoreAuthRule.matcher.Paths {
+			if baselineClusterAuthPolicy := findBypassedBaselineAuth(
+				clusterAuthPolicies,
+				ignoreAuthRule,
+				path,
+			); baselineClusterAuthPolicy != nil {
+				conflicts = append(
+					conflicts,
+					newBaselineAuthConflict(authPolicy, ignoreAuthRule, baselineClusterAuthPolicy.Name, path),
+				)
+				continue
+			}
+			overlappingAuthRule, authRulePath := findOverlappingAuthRule(authRules, ignoreAuthRule, path)
+			if overlappingAuthRule == nil {
+				paths = append(paths, path)
+				continue
+			}
+			covered := validation.MatchPath(authRulePath, path)
+			if !covered {
+				paths = append(paths, path)
+			}
+			conflicts = append(
+				conflicts,
+				newRuleConflict(authPolicy, ignoreAuthRule, *overlappingAuthRule, path, authRulePath, covered),
+			)
+		}
+		if len(paths) > 0 {
+			ignoreAuthRule.matcher.Paths = paths
+			mergedIgnoreAuthRules = append(mergedIgnoreAuthRules, ignoreAuthRule.matcher)
+		}
+	}
+
+	mergedAuthRules := make([]ztoperatorv1alpha1.RequestAuthRule, 0, len(authRules))
+	for _, authRule := range authRules {
+		mergedAuthRules = append(mergedAuthRules, authRule.authRule)
+	}
+	if len(mergedAuthRules) > 0 {
+		merged.Spec.AuthRules = &mergedAuthRules
+	}
+	if len(mergedIgnoreAuthRules) > 0 || merged.Spec.IgnoreAuthRules != nil {
+		merged.Spec.IgnoreAuthRules = &mergedIgnoreAuthRules
+	}
+
+	return merged, conflicts
+}
+
+// sourcedRequestAuthRule is an auth rule along with the name of the ClusterAuthPolicy defining it.
+// An empty name denotes a rule defined by the AuthPolicy itself.
+type sourcedRequestAuthRule struct {
+	clusterAuthPolicy string
+	authRule          ztoperatorv1alpha1.RequestAuthRule
+}
+
+// sourcedRequestMatcher is an ignore auth rule along with the name of the ClusterAuthPolicy defining it.
+// An empty name denotes a rule defined by the AuthPolicy itself.
+type sourcedRequestMatcher struct {
+	clusterAuthPolicy string
+	matcher           ztoperatorv1alpha1.RequestMatcher
+}
+
+// findOverlappingAuthRule returns an auth rule of another policy with a path overlapping the ignored path, along
+// with the overlapping path. An auth rule path covering the ignored path is preferred over a partial overlap.
+func findOverlappingAuthRule(
+	authRules []sourcedRequestAuthRule,
+	ignoreAuthRule sourcedRequestMatcher,
+	path string,
+) (*sourcedRequestAuthRule, string) {
+	var overlappingAuthRule *sourcedRequestAuthRule
+	var overlappingPath string
+	for _, authRule := range authRules {
+		if authRule.clusterAuthPolicy == ignoreAuthRule.clusterAuthPolicy {
+			// Overlaps within a single policy are left as defined by its author
+			continue
+		}
+		if !methodsOverlap(authRule.authRule.Methods, ignoreAuthRule.matcher.Methods) {
+			continue
+		}
+		for _, authRulePath := range authRule.authRule.Paths {
+			if validation.MatchPath(authRulePath, path) {
+				return &authRule, authRulePath
+			}
+			if overlappingAuthRule == nil && validation.MatchPath(path, authRulePath) {
+				overlappingAuthRule = &authRule
+				overlappingPath = authRulePath
+			}
+		}
+	}
+	return overlappingAuthRule, overlappingPath
+}
+
+// findBypassedBaselineAuth returns the first ClusterAuthPolicy with baseline auth which does not allow the ignored
+// path to skip authentication, as none of its own ignore auth rules covers the path for all methods of the rule.
+func findBypassedBaselineAuth(
+	clusterAuthPolicies []ztoperatorv1alpha1.ClusterAuthPolicy,
+	ignoreAuthRule sourcedRequestMatcher,
+	path string,
+) *ztoperatorv1alpha1.ClusterAuthPolicy {
+	for i, clusterAuthPolicy := range clusterAuthPolicies {
+		if clusterAuthPolicy.Spec.BaselineAuth == nil || clusterAuthPolicy.Name == ignoreAuthRule.clusterAuthPolicy {
+			continue
+		}
+		var allowedIgnoreAuthRules []ztoperatorv1alpha1.RequestMatcher
+		if clusterAuthPolicy.Spec.IgnoreAuthRules != nil {
+			allowedIgnoreAuthRules = *clusterAuthPolicy.Spec.IgnoreAuthRules
+		}
+		allowed := slices.ContainsFunc(allowedIgnoreAuthRules, func(allowed ztoperatorv1alpha1.RequestMatcher) bool {
+			return methodsCovered(allowed.Methods, ignoreAuthRule.matcher.Methods) &&
+				slices.ContainsFunc(allowed.Paths, func(allowedPath string) bool {
+					return validation.MatchPath(allowedPath, path)
+				})
+		})
+		if !allowed {
+			return &clusterAuthPolicies[i]
+		}
+	}
+	return nil
+}
+
+// methodsCovered reports whether all of the methods are among the covering methods, where no methods denote all.
+func methodsCovered(coveringMethods []string, methods []string) bool {
+	if len(coveringMethods) == 0 {
+		return true
+	}
+	if len(methods) == 0 {
+		return false
+	}
+	return !slices.ContainsFunc(methods, func(method string) bool { return !slices.Contains(coveringMethods, method) })
+}
+
+func methodsOverlap(methods []string, otherMethods []string) bool {
+	if len(methods) == 0 || len(otherMethods) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(methods, func(method string) bool { return slices.Contains(otherMethods, method) })
+}
+
+func newRuleConflict(
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+	ignoreAuthRule sourcedRequestMatcher,
+	authRule sourcedRequestAuthRule,
+	path string,
+	authRulePath string,
+	covered bool,
+) ztoperatorv1alpha1.RuleConflict {
+	clusterAuthPolicy := ignoreAuthRule.clusterAuthPolicy
+	if clusterAuthPolicy == "" {
+		clusterAuthPolicy = authRule.clusterAuthPolicy
+	}
+	message := fmt.Sprintf(
+		"ignoreAuthRules path %s %s is not ignored, as it is covered by authRules %s",
+		path,
+		describeRuleSource(ignoreAuthRule.clusterAuthPolicy),
+		describeRuleSource(authRule.clusterAuthPolicy),
+	)
+	if !covered {
+		message = fmt.Sprintf(
+			"ignoreAuthRules path %s %s is not ignored where it overlaps authRules path %s %s",
+			path,
+			describeRuleSource(ignoreAuthRule.clusterAuthPolicy),
+			authRulePath,
+			describeRuleSource(authRule.clusterAuthPolicy),
+		)
+	}
+	return ztoperatorv1alpha1.RuleConflict{
+		ClusterAuthPolicy: clusterAuthPolicy,
+		AuthPolicy:        authPolicy.Namespace + "/" + authPolicy.Name,
+		Message:           message,
+	}
+}
+
+func newBaselineAuthConflict(
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+	ignoreAuthRule sourcedRequestMatcher,
+	clusterAuthPolicy string,
+	path string,
+) ztoperatorv1alpha1.RuleConflict {
+	return ztoperatorv1alpha1.RuleConflict{
+		ClusterAuthPolicy: clusterAuthPolicy,
+		AuthPolicy:        authPolicy.Namespace + "/" + authPolicy.Name,
+		Message: fmt.Sprintf(
+			"ignoreAuthRules path %s %s is not ignored, as baselineAuth of ClusterAuthPolicy %s must be met "+
+				"for all paths it does not ignore itself",
+			path,
+			describeRuleSource(ignoreAuthRule.clusterAuthPolicy),
+			clusterAuthPolicy,
+		),
+	}
+}
+
+func describeRuleSource(clusterAuthPolicy string) string {
+	if clusterAuthPolicy == "" {
+		return "of the AuthPolicy"
+	}
+	return "of ClusterAuthPolicy " + clusterAuthPolicy
+}
+
+// mergeBaselineAuth requires the conditions of both baseline auths to be met.
+// Groups of alternative conditions are combined pairwise, as a request must satisfy a group from each.
+func mergeBaselineAuth(
+	baselineAuth *ztoperatorv1alpha1.BaselineAuth,
+	other *ztoperatorv1alpha1.BaselineAuth,
+) *ztoperatorv1alpha1.BaselineAuth {
+	if other == nil {
+		return baselineAuth
+	}
+	if baselineAuth == nil {
+		return other.DeepCopy()
+	}
+
+	merged := &ztoperatorv1alpha1.BaselineAuth{
+		Claims: slices.Concat(baselineAuth.Claims, other.Claims),
+	}
+	switch {
+	case len(baselineAuth.AnyOf) == 0:
+		merged.AnyOf = slices.Clone(other.AnyOf)
+	case len(other.AnyOf) == 0:
+		merged.AnyOf = baselineAuth.AnyOf
+	default:
+		merged.AnyOf = make([]ztoperatorv1alpha1.ConditionGroup, 0, len(baselineAuth.AnyOf)*len(other.AnyOf))
+		for _, conditionGroup := range baselineAuth.AnyOf {
+			for _, otherConditionGroup := range other.AnyOf {
+				merged.AnyOf = append(merged.AnyOf, ztoperatorv1alpha1.ConditionGroup{
+					AllOf: slices.Concat(conditionGroup.AllOf, otherConditionGroup.AllOf),
+				})
+			}
+		}
+	}
+	return merged
+}
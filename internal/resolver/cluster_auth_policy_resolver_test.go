@@ -0,0 +1,368 @@
+package resolver_test
+
+import (
+	"context"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func clusterAuthPolicy(name string, spec ztoperatorv1alpha1.ClusterAuthPolicySpec) ztoperatorv1alpha1.ClusterAuthPolicy {
+	return ztoperatorv1alpha1.ClusterAuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       spec,
+	}
+}
+
+func authPolicyWithRules(
+	authRules *[]ztoperatorv1alpha1.RequestAuthRule,
+	ignoreAuthRules *[]ztoperatorv1alpha1.RequestMatcher,
+) *ztoperatorv1alpha1.AuthPolicy {
+	return &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-app", Namespace: "team"},
+		Spec: ztoperatorv1alpha1.AuthPolicySpec{
+			Enabled:         true,
+			WellKnownURI:    "https://idp.example.com/.well-known/openid-configuration",
+			AuthRules:       authRules,
+			IgnoreAuthRules: ignoreAuthRules,
+		},
+	}
+}
+
+func TestResolveClusterAuthPolicies_WithoutClusterAuthPolicies_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(nil, nil)
+
+	// 2. Act
+	result, err := resolver.ResolveClusterAuthPolicies(ctx, createFakeClientForAudiences(), authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestResolveClusterAuthPolicies_ReturnsPoliciesMatchingNamespaceSortedByName(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	namespace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Labels: map[string]string{"tier": "prod"}},
+	}
+	baselineAuth := &ztoperatorv1alpha1.BaselineAuth{
+		Claims: []ztoperatorv1alpha1.Condition{{Claim: "acr", Values: []string{"high"}}},
+	}
+	allNamespaces := clusterAuthPolicy("b-all", ztoperatorv1alpha1.ClusterAuthPolicySpec{BaselineAuth: baselineAuth})
+	prodNamespaces := clusterAuthPolicy("a-prod", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}},
+		BaselineAuth:      baselineAuth,
+	})
+	devNamespaces := clusterAuthPolicy("c-dev", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "dev"}},
+		BaselineAuth:      baselineAuth,
+	})
+	k8sClient := createFakeClientForAudiences(namespace, &allNamespaces, &prodNamespaces, &devNamespaces)
+
+	// 2. Act
+	result, err := resolver.ResolveClusterAuthPolicies(ctx, k8sClient, authPolicyWithRules(nil, nil))
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, "a-prod", result[0].Name)
+	assert.Equal(t, "b-all", result[1].Name)
+}
+
+func TestMergeClusterAuthPolicies_WithoutClusterAuthPolicies_ReturnsUnchangedCopy(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(nil, nil)
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(authPolicy, nil)
+
+	// 3. Assert
+	assert.Equal(t, authPolicy, merged)
+	assert.NotSame(t, authPolicy, merged)
+	assert.Empty(t, conflicts)
+}
+
+func TestMergeClusterAuthPolicies_CombinesBaselineAuthAndAppendsRules(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(
+		&[]ztoperatorv1alpha1.RequestAuthRule{
+			{RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/api"}}},
+		},
+		&[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/public/docs"}}},
+	)
+	authPolicy.Spec.BaselineAuth = &ztoperatorv1alpha1.BaselineAuth{
+		Claims: []ztoperatorv1alpha1.Condition{{Claim: "scope", Values: []string{"read"}}},
+		AnyOf: []ztoperatorv1alpha1.ConditionGroup{
+			{AllOf: []ztoperatorv1alpha1.Condition{{Claim: "role", Values: []string{"admin"}}}},
+			{AllOf: []ztoperatorv1alpha1.Condition{{Claim: "role", Values: []string{"owner"}}}},
+		},
+	}
+	platform := clusterAuthPolicy("platform", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		BaselineAuth: &ztoperatorv1alpha1.BaselineAuth{
+			Claims: []ztoperatorv1alpha1.Condition{{Claim: "tenant", Values: []string{"kartverket"}}},
+			AnyOf: []ztoperatorv1alpha1.ConditionGroup{
+				{AllOf: []ztoperatorv1alpha1.Condition{{Claim: "acr", Values: []string{"high"}}}},
+			},
+		},
+		AuthRules: &[]ztoperatorv1alpha1.RequestAuthRule{
+			{RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/admin"}}},
+		},
+		IgnoreAuthRules: &[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/healthz", "/public/{**}"}}},
+	})
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(authPolicy, []ztoperatorv1alpha1.ClusterAuthPolicy{platform})
+
+	// 3. Assert
+	assert.Empty(t, conflicts)
+	require.NotNil(t, merged.Spec.BaselineAuth)
+	assert.Equal(t, []ztoperatorv1alpha1.Condition{
+		{Claim: "scope", Values: []string{"read"}},
+		{Claim: "tenant", Values: []string{"kartverket"}},
+	}, merged.Spec.BaselineAuth.Claims)
+	assert.Equal(t, []ztoperatorv1alpha1.ConditionGroup{
+		{AllOf: []ztoperatorv1alpha1.Condition{
+			{Claim: "role", Values: []string{"admin"}},
+			{Claim: "acr", Values: []string{"high"}},
+		}},
+		{AllOf: []ztoperatorv1alpha1.Condition{
+			{Claim: "role", Values: []string{"owner"}},
+			{Claim: "acr", Values: []string{"high"}},
+		}},
+	}, merged.Spec.BaselineAuth.AnyOf)
+	require.NotNil(t, merged.Spec.AuthRules)
+	assert.Equal(t, []string{"/api", "/admin"}, merged.GetAuthorizedPaths())
+	require.NotNil(t, merged.Spec.IgnoreAuthRules)
+	assert.Equal(t, []ztoperatorv1alpha1.RequestMatcher{
+		{Paths: []string{"/public/docs"}},
+		{Paths: []string{"/healthz", "/public/{**}"}},
+	}, *merged.Spec.IgnoreAuthRules)
+
+	// The original AuthPolicy is left untouched
+	assert.Len(t, authPolicy.Spec.BaselineAuth.Claims, 1)
+	assert.Len(t, *authPolicy.Spec.AuthRules, 1)
+}
+
+func TestMergeClusterAuthPolicies_WithIgnoredPathRequiredByClusterAuthPolicy_DropsIgnoredPath(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(
+		nil,
+		&[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/admin", "/public"}, Methods: []string{"GET"}}},
+	)
+	platform := clusterAuthPolicy("platform", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		AuthRules: &[]ztoperatorv1alpha1.RequestAuthRule{
+			{RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/admin"}}},
+		},
+	})
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(authPolicy, []ztoperatorv1alpha1.ClusterAuthPolicy{platform})
+
+	// 3. Assert
+	require.NotNil(t, merged.Spec.IgnoreAuthRules)
+	assert.Equal(t, []ztoperatorv1alpha1.RequestMatcher{
+		{Paths: []string{"/public"}, Methods: []string{"GET"}},
+	}, *merged.Spec.IgnoreAuthRules)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "platform", conflicts[0].ClusterAuthPolicy)
+	assert.Equal(t, "team/my-app", conflicts[0].AuthPolicy)
+	assert.Equal(
+		t,
+		"ignoreAuthRules path /admin of the AuthPolicy is not ignored, as it is covered by authRules of ClusterAuthPolicy platform",
+		conflicts[0].Message,
+	)
+}
+
+func TestMergeClusterAuthPolicies_WithClusterIgnoredPathRequiredByAuthPolicy_DropsIgnoreRule(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(
+		&[]ztoperatorv1alpha1.RequestAuthRule{
+			{RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/metrics"}, Methods: []string{"GET"}}},
+		},
+		nil,
+	)
+	platform := clusterAuthPolicy("platform", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		IgnoreAuthRules: &[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/metrics"}}},
+	})
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(authPolicy, []ztoperatorv1alpha1.ClusterAuthPolicy{platform})
+
+	// 3. Assert
+	assert.Nil(t, merged.Spec.IgnoreAuthRules)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "platform", conflicts[0].ClusterAuthPolicy)
+	assert.Contains(t, conflicts[0].Message, "of ClusterAuthPolicy platform is not ignored")
+}
+
+func TestMergeClusterAuthPolicies_WithDisjointMethods_KeepsIgnoredPath(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(
+		nil,
+		&[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/admin"}, Methods: []string{"GET"}}},
+	)
+	platform := clusterAuthPolicy("platform", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		AuthRules: &[]ztoperatorv1alpha1.RequestAuthRule{
+			{RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/admin"}, Methods: []string{"POST"}}},
+		},
+	})
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(authPolicy, []ztoperatorv1alpha1.ClusterAuthPolicy{platform})
+
+	// 3. Assert
+	assert.Empty(t, conflicts)
+	require.NotNil(t, merged.Spec.IgnoreAuthRules)
+	assert.Equal(t, []string{"/admin"}, (*merged.Spec.IgnoreAuthRules)[0].Paths)
+}
+
+func TestMergeClusterAuthPolicies_WithIgnoredPathCoveredByTemplate_DropsIgnoredPath(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(
+		nil,
+		&[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/api/admin", "/public"}}},
+	)
+	platform := clusterAuthPolicy("platform", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		AuthRules: &[]ztoperatorv1alpha1.RequestAuthRule{
+			{RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/api/{**}"}}},
+		},
+	})
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(authPolicy, []ztoperatorv1alpha1.ClusterAuthPolicy{platform})
+
+	// 3. Assert
+	require.NotNil(t, merged.Spec.IgnoreAuthRules)
+	assert.Equal(t, []string{"/public"}, (*merged.Spec.IgnoreAuthRules)[0].Paths)
+	require.Len(t, conflicts, 1)
+	assert.Equal(
+		t,
+		"ignoreAuthRules path /api/admin of the AuthPolicy is not ignored, as it is covered by authRules of ClusterAuthPolicy platform",
+		conflicts[0].Message,
+	)
+}
+
+func TestMergeClusterAuthPolicies_WithIgnoredPathPartiallyOverlappingAuthRule_KeepsIgnoredPath(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(
+		nil,
+		&[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/api*"}}},
+	)
+	platform := clusterAuthPolicy("platform", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		AuthRules: &[]ztoperatorv1alpha1.RequestAuthRule{
+			{RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/api/admin"}}},
+		},
+	})
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(authPolicy, []ztoperatorv1alpha1.ClusterAuthPolicy{platform})
+
+	// 3. Assert
+	require.NotNil(t, merged.Spec.IgnoreAuthRules)
+	assert.Equal(t, []string{"/api*"}, (*merged.Spec.IgnoreAuthRules)[0].Paths)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "platform", conflicts[0].ClusterAuthPolicy)
+	assert.Equal(
+		t,
+		"ignoreAuthRules path /api* of the AuthPolicy is not ignored where it overlaps authRules path /api/admin "+
+			"of ClusterAuthPolicy platform",
+		conflicts[0].Message,
+	)
+}
+
+func TestMergeClusterAuthPolicies_WithIgnoredPathBypassingClusterBaselineAuth_DropsIgnoredPath(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(nil, &[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/**"}}})
+	platform := clusterAuthPolicy("platform", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		BaselineAuth: &ztoperatorv1alpha1.BaselineAuth{
+			Claims: []ztoperatorv1alpha1.Condition{{Claim: "tenant", Values: []string{"kartverket"}}},
+		},
+	})
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(authPolicy, []ztoperatorv1alpha1.ClusterAuthPolicy{platform})
+
+	// 3. Assert
+	require.NotNil(t, merged.Spec.IgnoreAuthRules)
+	assert.Empty(t, *merged.Spec.IgnoreAuthRules, "The tenant baseline must not be bypassed by ignoring all paths")
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "platform", conflicts[0].ClusterAuthPolicy)
+	assert.Equal(t, "team/my-app", conflicts[0].AuthPolicy)
+	assert.Equal(
+		t,
+		"ignoreAuthRules path /** of the AuthPolicy is not ignored, as baselineAuth of ClusterAuthPolicy platform "+
+			"must be met for all paths it does not ignore itself",
+		conflicts[0].Message,
+	)
+}
+
+func TestMergeClusterAuthPolicies_WithIgnoredMethodsNotAllowedByClusterAuthPolicy_DropsIgnoredPath(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(nil, &[]ztoperatorv1alpha1.RequestMatcher{
+		{Paths: []string{"/docs"}, Methods: []string{"GET"}},
+		{Paths: []string{"/docs"}},
+	})
+	platform := clusterAuthPolicy("platform", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		BaselineAuth: &ztoperatorv1alpha1.BaselineAuth{
+			Claims: []ztoperatorv1alpha1.Condition{{Claim: "tenant", Values: []string{"kartverket"}}},
+		},
+		IgnoreAuthRules: &[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/docs"}, Methods: []string{"GET", "HEAD"}}},
+	})
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(authPolicy, []ztoperatorv1alpha1.ClusterAuthPolicy{platform})
+
+	// 3. Assert
+	require.NotNil(t, merged.Spec.IgnoreAuthRules)
+	assert.Equal(t, []ztoperatorv1alpha1.RequestMatcher{
+		{Paths: []string{"/docs"}, Methods: []string{"GET"}},
+		{Paths: []string{"/docs"}, Methods: []string{"GET", "HEAD"}},
+	}, *merged.Spec.IgnoreAuthRules)
+	require.Len(t, conflicts, 1, "Ignoring /docs for all methods would bypass the baseline for POST requests")
+	assert.Contains(t, conflicts[0].Message, "baselineAuth of ClusterAuthPolicy platform")
+}
+
+func TestMergeClusterAuthPolicies_WithIgnoredPathOfOtherClusterAuthPolicy_DropsIgnoredPath(t *testing.T) {
+	// 1. Arrange
+	authPolicy := authPolicyWithRules(nil, nil)
+	platform := clusterAuthPolicy("platform", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		BaselineAuth: &ztoperatorv1alpha1.BaselineAuth{
+			Claims: []ztoperatorv1alpha1.Condition{{Claim: "tenant", Values: []string{"kartverket"}}},
+		},
+		IgnoreAuthRules: &[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/healthz"}}},
+	})
+	observability := clusterAuthPolicy("observability", ztoperatorv1alpha1.ClusterAuthPolicySpec{
+		IgnoreAuthRules: &[]ztoperatorv1alpha1.RequestMatcher{{Paths: []string{"/healthz", "/metrics"}}},
+	})
+
+	// 2. Act
+	merged, conflicts := resolver.MergeClusterAuthPolicies(
+		authPolicy,
+		[]ztoperatorv1alpha1.ClusterAuthPolicy{observability, platform},
+	)
+
+	// 3. Assert
+	require.NotNil(t, merged.Spec.IgnoreAuthRules)
+	assert.Equal(t, []ztoperatorv1alpha1.RequestMatcher{
+		{Paths: []string{"/healthz"}},
+		{Paths: []string{"/healthz"}},
+	}, *merged.Spec.IgnoreAuthRules)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "platform", conflicts[0].ClusterAuthPolicy)
+	assert.Equal(
+		t,
+		"ignoreAuthRules path /metrics of ClusterAuthPolicy observability is not ignored, as baselineAuth of "+
+			"ClusterAuthPolicy platform must be met for all paths it does not ignore itself",
+		conflicts[0].Message,
+	)
+}
@@ -12,54 +12,122 @@ import (
 	"github.com/kartverket/ztoperator/pkg/rest"
 )
 
+// ResolveDiscoveryDocument resolves the endpoints of the default identity provider of the AuthPolicy from the discovery
+// document given by .spec.wellKnownURI, overriding the endpoints given by .spec.endpoints.
+// The JWKS URI is not required when the JWKS is given by .spec.jwks.
 func ResolveDiscoveryDocument(
 	ctx context.Context,
 	authPolicy *ztoperatorv1alpha1.AuthPolicy,
 	resolver rest.DiscoveryDocumentResolver,
+) (*state.IdentityProviderUris, error) {
+	autoLoginEnabled := authPolicy.Spec.AutoLogin != nil && authPolicy.Spec.AutoLogin.Enabled
+	return resolveDiscoveryDocument(
+		ctx,
+		authPolicyDescription(authPolicy),
+		authPolicy.Spec.WellKnownURI,
+		authPolicy.Spec.Endpoints,
+		authPolicy.Spec.Jwks == nil,
+		autoLoginEnabled,
+		resolver,
+	)
+}
+
+// ResolveIdentityProviderDiscoveryDocument resolves the discovery document of an IdentityProvider, overriding the
+// endpoints given by .spec.endpoints.
+func ResolveIdentityProviderDiscoveryDocument(
+	ctx context.Context,
+	identityProvider *ztoperatorv1alpha1.IdentityProvider,
+	resolver rest.DiscoveryDocumentResolver,
+) (*state.IdentityProviderUris, error) {
+	return resolveDiscoveryDocument(
+		ctx,
+		fmt.Sprintf("IdentityProvider with name %s", identityProvider.Name),
+		identityProvider.Spec.WellKnownURI,
+		identityProvider.Spec.Endpoints,
+		true,
+		false,
+		resolver,
+	)
+}
+
+func authPolicyDescription(authPolicy *ztoperatorv1alpha1.AuthPolicy) string {
+	return fmt.Sprintf("AuthPolicy with name %s/%s", authPolicy.Namespace, authPolicy.Name)
+}
+
+// resolveDiscoveryDocument resolves the endpoints of an identity provider from the discovery document given by
+// wellKnownURI, overriding the endpoints which are set in endpoints. No discovery document is fetched when wellKnownURI
+// is empty, in which case the endpoints are taken from endpoints alone. The JWKS URI may be omitted unless
+// requireJwksURI is set.
+func resolveDiscoveryDocument(
+	ctx context.Context,
+	resourceDescription string,
+	wellKnownURI string,
+	endpoints *ztoperatorv1alpha1.IdentityProviderEndpoints,
+	requireJwksURI bool,
+	requireAutoLoginEndpoints bool,
+	resolver rest.DiscoveryDocumentResolver,
 ) (*state.IdentityProviderUris, error) {
 	rLog := log.GetLogger(ctx)
 	var identityProviderUris state.IdentityProviderUris
-	discoveryDocument, err := resolver.GetOAuthDiscoveryDocument(authPolicy.Spec.WellKnownURI, rLog)
-	if err != nil {
-		return nil, fmt.Errorf(
-			"failed to resolve discovery document from well-known uri: %s for AuthPolicy with name %s/%s: %w",
-			authPolicy.Spec.WellKnownURI,
-			authPolicy.Namespace,
-			authPolicy.Name,
-			err,
-		)
+	var resolvedDiscoveryDocument rest.DiscoveryDocument
+	if wellKnownURI != "" {
+		fetchedDiscoveryDocument, err := resolver.GetOAuthDiscoveryDocument(wellKnownURI, rLog)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to resolve discovery document from well-known uri: %s for %s: %w",
+				wellKnownURI,
+				resourceDescription,
+				err,
+			)
+		}
+		resolvedDiscoveryDocument = *fetchedDiscoveryDocument
 	}
+	discoveryDocument := overrideEndpoints(resolvedDiscoveryDocument, endpoints)
 
-	if discoveryDocument.Issuer == nil || discoveryDocument.JwksURI == nil || discoveryDocument.TokenEndpoint == nil {
+	missingJwksURI := requireJwksURI && discoveryDocument.JwksURI == nil
+	if discoveryDocument.Issuer == nil || missingJwksURI || discoveryDocument.TokenEndpoint == nil {
+		if wellKnownURI == "" && !requireJwksURI {
+			return nil, fmt.Errorf(
+				"endpoints for %s must set issuer and tokenEndpoint when wellKnownURI is not set",
+				resourceDescription,
+			)
+		}
+		if wellKnownURI == "" {
+			return nil, fmt.Errorf(
+				"endpoints for %s must set issuer, jwksUri and tokenEndpoint when wellKnownURI is not set",
+				resourceDescription,
+			)
+		}
 		return nil, fmt.Errorf(
-			"failed to parse discovery document from well-known uri: %s for AuthPolicy with name %s/%s",
-			authPolicy.Spec.WellKnownURI,
-			authPolicy.Namespace,
-			authPolicy.Name,
+			"failed to parse discovery document from well-known uri: %s for %s",
+			wellKnownURI,
+			resourceDescription,
 		)
 	}
 
-	if authPolicy.Spec.AutoLogin != nil && authPolicy.Spec.AutoLogin.Enabled {
+	if requireAutoLoginEndpoints {
 		if discoveryDocument.AuthorizationEndpoint == nil || discoveryDocument.EndSessionEndpoint == nil {
 			return nil, fmt.Errorf(
-				"issuer %s for AuthPolicy with name %s/%s does not support authorization endpoint or end session endpoint required for autologin",
+				"issuer %s for %s does not support authorization endpoint or end session endpoint required for autologin",
 				*discoveryDocument.Issuer,
-				authPolicy.Namespace,
-				authPolicy.Name,
+				resourceDescription,
 			)
 		}
 	}
 
 	identityProviderUris.IssuerURI = *discoveryDocument.Issuer
-	identityProviderUris.JwksURI = *discoveryDocument.JwksURI
 	identityProviderUris.TokenURI = *discoveryDocument.TokenEndpoint
 
 	urisToValidate := map[string]string{
 		"issuer":         identityProviderUris.IssuerURI,
-		"jwks_uri":       identityProviderUris.JwksURI,
 		"token_endpoint": identityProviderUris.TokenURI,
 	}
 
+	if discoveryDocument.JwksURI != nil {
+		identityProviderUris.JwksURI = *discoveryDocument.JwksURI
+		urisToValidate["jwks_uri"] = identityProviderUris.JwksURI
+	}
+
 	if discoveryDocument.AuthorizationEndpoint != nil {
 		identityProviderUris.AuthorizationURI = *discoveryDocument.AuthorizationEndpoint
 		urisToValidate["authorization_endpoint"] = identityProviderUris.AuthorizationURI
@@ -71,11 +139,13 @@ func ResolveDiscoveryDocument(
 
 	for field, uri := range urisToValidate {
 		if err := validateDiscoveryURI(field, uri); err != nil {
+			if wellKnownURI == "" {
+				return nil, fmt.Errorf("invalid endpoints for %s: %w", resourceDescription, err)
+			}
 			return nil, fmt.Errorf(
-				"invalid discovery document from well-known uri: %s for AuthPolicy %s/%s: %w",
-				authPolicy.Spec.WellKnownURI,
-				authPolicy.Namespace,
-				authPolicy.Name,
+				"invalid discovery document from well-known uri: %s for %s: %w",
+				wellKnownURI,
+				resourceDescription,
 				err,
 			)
 		}
@@ -84,6 +154,32 @@ func ResolveDiscoveryDocument(
 	return &identityProviderUris, nil
 }
 
+// overrideEndpoints returns the discovery document with the endpoints which are set in endpoints replaced.
+func overrideEndpoints(
+	discoveryDocument rest.DiscoveryDocument,
+	endpoints *ztoperatorv1alpha1.IdentityProviderEndpoints,
+) rest.DiscoveryDocument {
+	if endpoints == nil {
+		return discoveryDocument
+	}
+	if endpoints.Issuer != nil {
+		discoveryDocument.Issuer = endpoints.Issuer
+	}
+	if endpoints.JwksURI != nil {
+		discoveryDocument.JwksURI = endpoints.JwksURI
+	}
+	if endpoints.TokenEndpoint != nil {
+		discoveryDocument.TokenEndpoint = endpoints.TokenEndpoint
+	}
+	if endpoints.AuthorizationEndpoint != nil {
+		discoveryDocument.AuthorizationEndpoint = endpoints.AuthorizationEndpoint
+	}
+	if endpoints.EndSessionEndpoint != nil {
+		discoveryDocument.EndSessionEndpoint = endpoints.EndSessionEndpoint
+	}
+	return discoveryDocument
+}
+
 // validateDiscoveryURI checks that a URI from an OIDC discovery document is
 // structurally valid and does not contain characters that could break Lua
 // string interpolation (double quotes, backslashes, control characters).
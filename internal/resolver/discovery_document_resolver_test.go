@@ -2,6 +2,7 @@ package resolver_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
@@ -107,17 +108,13 @@ func TestMissingAuthorizationEndpointWithAutoLoginGivesError(t *testing.T) {
 	ctx := context.Background()
 
 	// 1. Arrange
-	authPolicy := defaultZtoperatorAuthPolicy(
-		// Maskinporten does not have session management endpoints
-		// Result for this uri is defined in pkg/rest/dto.go
-		"https://maskinporten.no/.well-known/oauth-authorization-server",
-	)
+	authPolicy := defaultZtoperatorAuthPolicy("https://maskinporten.no/.well-known/oauth-authorization-server")
 	authPolicy.Spec.AutoLogin = &ztoperatorv1alpha1.AutoLogin{
 		Enabled: true,
 	}
 
 	// 2. Act
-	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, rest.NewDefaultDiscoveryDocumentResolver())
+	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, newMaskinportenDiscoveryResolver())
 
 	// 3. Assert
 	require.Error(
@@ -133,17 +130,13 @@ func TestMissingAuthorizationEndpointWithoutAutoLoginResolvesSuccessfully(t *tes
 	ctx := context.Background()
 
 	// 1. Arrange
-	authPolicy := defaultZtoperatorAuthPolicy(
-		// Maskinporten does not have session management endpoints
-		// Result for this uri is defined in pkg/rest/dto.go
-		"https://maskinporten.no/.well-known/oauth-authorization-server",
-	)
+	authPolicy := defaultZtoperatorAuthPolicy("https://maskinporten.no/.well-known/oauth-authorization-server")
 	authPolicy.Spec.AutoLogin = &ztoperatorv1alpha1.AutoLogin{
 		Enabled: false,
 	}
 
 	// 2. Act
-	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, rest.NewDefaultDiscoveryDocumentResolver())
+	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, newMaskinportenDiscoveryResolver())
 
 	// 3. Assert
 	require.NoError(t, err, "ResolveDiscoveryDocument should not return an error when autologin is disabled")
@@ -165,13 +158,19 @@ func TestValidWellKnownUriResolvesSuccessfully(t *testing.T) {
 	ctx := context.Background()
 
 	// 1. Arrange
-	authPolicy := defaultZtoperatorAuthPolicy(
-		// Result for this uri is defined in pkg/rest/dto.go
-		"http://mock-oauth2.auth:8080/entraid/.well-known/openid-configuration",
-	)
+	authPolicy := defaultZtoperatorAuthPolicy("http://mock-oauth2.auth:8080/entraid/.well-known/openid-configuration")
+	mockResolver := &mockDiscoveryDocumentResolver{
+		document: &rest.DiscoveryDocument{
+			Issuer:                helperfunctions.Ptr("http://mock-oauth2.auth:8080/entraid"),
+			AuthorizationEndpoint: helperfunctions.Ptr("http://mock-oauth2.auth:8080/entraid/authorize"),
+			TokenEndpoint:         helperfunctions.Ptr("http://mock-oauth2.auth:8080/entraid/token"),
+			JwksURI:               helperfunctions.Ptr("http://mock-oauth2.auth:8080/entraid/jwks"),
+			EndSessionEndpoint:    helperfunctions.Ptr("http://mock-oauth2.auth:8080/entraid/endsession"),
+		},
+	}
 
 	// 2. Act
-	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, rest.NewDefaultDiscoveryDocumentResolver())
+	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, mockResolver)
 
 	// 3. Assert
 	require.NoError(t, err, "ResolveDiscoveryDocument should not return an error for valid well-known URI")
@@ -199,6 +198,17 @@ func defaultZtoperatorAuthPolicy(
 	}
 }
 
+func newMaskinportenDiscoveryResolver() *mockDiscoveryDocumentResolver {
+	// Maskinporten does not have session management endpoints
+	return &mockDiscoveryDocumentResolver{
+		document: &rest.DiscoveryDocument{
+			Issuer:        helperfunctions.Ptr("https://maskinporten.no/"),
+			TokenEndpoint: helperfunctions.Ptr("https://maskinporten.no/token"),
+			JwksURI:       helperfunctions.Ptr("https://maskinporten.no/jwk"),
+		},
+	}
+}
+
 type mockDiscoveryDocumentResolver struct {
 	document *rest.DiscoveryDocument
 	err      error
@@ -295,3 +305,117 @@ func TestDiscoveryDocumentWithNormalURIsResolvesSuccessfully(t *testing.T) {
 	assert.Equal(t, "http://test-idp.example.com", result.IssuerURI)
 	assert.Equal(t, "http://test-idp.example.com/authorize", result.AuthorizationURI)
 }
+
+func TestEndpointsOverrideDiscoveryDocument(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("https://maskinporten.no/.well-known/oauth-authorization-server")
+	authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+		TokenEndpoint: helperfunctions.Ptr("https://proxy.example.com/token"),
+	}
+
+	// 2. Act
+	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, newMaskinportenDiscoveryResolver())
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, "https://maskinporten.no/", result.IssuerURI)
+	assert.Equal(t, "https://maskinporten.no/jwk", result.JwksURI)
+	assert.Equal(t, "https://proxy.example.com/token", result.TokenURI)
+}
+
+func TestEndpointsWithoutWellKnownURIResolveWithoutDiscovery(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.AutoLogin = &ztoperatorv1alpha1.AutoLogin{Enabled: true}
+	authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+		Issuer:                helperfunctions.Ptr("https://oauth.example.com"),
+		JwksURI:               helperfunctions.Ptr("https://oauth.example.com/jwks"),
+		TokenEndpoint:         helperfunctions.Ptr("https://oauth.example.com/token"),
+		AuthorizationEndpoint: helperfunctions.Ptr("https://oauth.example.com/authorize"),
+		EndSessionEndpoint:    helperfunctions.Ptr("https://oauth.example.com/logout"),
+	}
+	mockResolver := &mockDiscoveryDocumentResolver{err: errors.New("discovery document should not be fetched")}
+
+	// 2. Act
+	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, mockResolver)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, "https://oauth.example.com", result.IssuerURI)
+	assert.Equal(t, "https://oauth.example.com/jwks", result.JwksURI)
+	assert.Equal(t, "https://oauth.example.com/token", result.TokenURI)
+	assert.Equal(t, "https://oauth.example.com/authorize", result.AuthorizationURI)
+	require.NotNil(t, result.EndSessionURI)
+	assert.Equal(t, "https://oauth.example.com/logout", *result.EndSessionURI)
+}
+
+func TestIncompleteEndpointsWithoutWellKnownURIGivesError(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+		Issuer:  helperfunctions.Ptr("https://oauth.example.com"),
+		JwksURI: helperfunctions.Ptr("https://oauth.example.com/jwks"),
+	}
+
+	// 2. Act
+	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, &mockDiscoveryDocumentResolver{})
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(
+		t,
+		err.Error(),
+		"endpoints for AuthPolicy with name default/test-policy must set issuer, jwksUri and tokenEndpoint",
+	)
+}
+
+func TestEndpointsWithoutJwksURIResolveWhenJwksIsSet(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+		Issuer:        helperfunctions.Ptr("https://oauth.example.com"),
+		TokenEndpoint: helperfunctions.Ptr("https://oauth.example.com/token"),
+	}
+	authPolicy.Spec.Jwks = &ztoperatorv1alpha1.Jwks{Value: helperfunctions.Ptr(`{"keys":[]}`)}
+
+	// 2. Act
+	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, &mockDiscoveryDocumentResolver{})
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "https://oauth.example.com", result.IssuerURI)
+	assert.Empty(t, result.JwksURI)
+}
+
+func TestEndpointsWithoutTokenEndpointGivesErrorWhenJwksIsSet(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.Endpoints = &ztoperatorv1alpha1.IdentityProviderEndpoints{
+		Issuer: helperfunctions.Ptr("https://oauth.example.com"),
+	}
+	authPolicy.Spec.Jwks = &ztoperatorv1alpha1.Jwks{Value: helperfunctions.Ptr(`{"keys":[]}`)}
+
+	// 2. Act
+	result, err := resolver.ResolveDiscoveryDocument(ctx, authPolicy, &mockDiscoveryDocumentResolver{})
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(
+		t,
+		err.Error(),
+		"endpoints for AuthPolicy with name default/test-policy must set issuer and tokenEndpoint",
+	)
+}
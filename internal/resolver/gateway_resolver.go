@@ -0,0 +1,84 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WaypointGatewayClassName is the class of the Gateways Istio deploys as waypoints in ambient mode.
+const WaypointGatewayClassName = "istio-waypoint"
+
+// GatewayGroupVersionKind identifies Kubernetes Gateway API Gateways, which are read as unstructured objects, as the
+// Gateway API is only required for AuthPolicies targeting gateways.
+var GatewayGroupVersionKind = schema.GroupVersionKind{
+	Group:   "gateway.networking.k8s.io",
+	Version: "v1",
+	Kind:    "Gateway",
+}
+
+// ResolveGateways returns the listeners of the Gateways targeted by an AuthPolicy with auto-login enabled, as the
+// OAuth2 filter is only inserted on the listeners of the Gateways serving HTTP. Listeners with other protocols, such as
+// TLS passthrough, are left out. Returns nil when auto-login is disabled or the AuthPolicy does not target gateways.
+func ResolveGateways(
+	ctx context.Context,
+	k8sClient client.Client,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+) ([]state.Gateway, error) {
+	if !authPolicy.TargetsGateways() || authPolicy.Spec.AutoLogin == nil || !authPolicy.Spec.AutoLogin.Enabled {
+		return nil, nil
+	}
+
+	gateways := make([]state.Gateway, 0, len(authPolicy.Spec.TargetRefs))
+	for _, targetRef := range authPolicy.Spec.TargetRefs {
+		gateway := &unstructured.Unstructured{}
+		gateway.SetGroupVersionKind(GatewayGroupVersionKind)
+		if err := k8sClient.Get(
+			ctx,
+			types.NamespacedName{Namespace: authPolicy.Namespace, Name: targetRef.Name},
+			gateway,
+		); err != nil {
+			return nil, fmt.Errorf(
+				"failed to get Gateway %s/%s targeted by %s: %w",
+				authPolicy.Namespace,
+				targetRef.Name,
+				authPolicyDescription(authPolicy),
+				err,
+			)
+		}
+
+		gatewayClassName, _, _ := unstructured.NestedString(gateway.Object, "spec", "gatewayClassName")
+		listeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+		resolvedGateway := state.Gateway{
+			Name:     targetRef.Name,
+			Waypoint: gatewayClassName == WaypointGatewayClassName,
+		}
+		for _, listener := range listeners {
+			listenerFields, ok := listener.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			protocol, _, _ := unstructured.NestedString(listenerFields, "protocol")
+			if protocol != "HTTP" && protocol != "HTTPS" {
+				continue
+			}
+			port, _, _ := unstructured.NestedInt64(listenerFields, "port")
+			resolvedListener := state.GatewayListener{Port: uint32(port)}
+			// The hostname of an HTTPS listener is matched through SNI, which plain HTTP listeners have no equivalent of
+			hostname, _, _ := unstructured.NestedString(listenerFields, "hostname")
+			if protocol == "HTTPS" && hostname != "" {
+				resolvedListener.Hostname = helperfunctions.Ptr(hostname)
+			}
+			resolvedGateway.Listeners = append(resolvedGateway.Listeners, resolvedListener)
+		}
+		gateways = append(gateways, resolvedGateway)
+	}
+	return gateways, nil
+}
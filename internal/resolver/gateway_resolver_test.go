@@ -0,0 +1,126 @@
+package resolver_test
+
+import (
+	"context"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResolveGateways_WithAutoLogin_ReturnsHTTPListeners(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := autoLoginGatewayAuthPolicy("my-gateway")
+	k8sClient := createFakeClientForGateways(gatewayObject("my-gateway", "istio", []interface{}{
+		map[string]interface{}{"name": "http", "protocol": "HTTP", "port": int64(80), "hostname": "app.example.com"},
+		map[string]interface{}{"name": "https", "protocol": "HTTPS", "port": int64(443), "hostname": "app.example.com"},
+		map[string]interface{}{"name": "passthrough", "protocol": "TLS", "port": int64(8443)},
+	}))
+
+	// 2. Act
+	gateways, err := resolver.ResolveGateways(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, []state.Gateway{{
+		Name: "my-gateway",
+		Listeners: []state.GatewayListener{
+			{Port: 80},
+			{Port: 443, Hostname: helperfunctions.Ptr("app.example.com")},
+		},
+	}}, gateways)
+}
+
+func TestResolveGateways_WithWaypoint_ReturnsWaypoint(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := autoLoginGatewayAuthPolicy("waypoint")
+	k8sClient := createFakeClientForGateways(gatewayObject("waypoint", resolver.WaypointGatewayClassName, []interface{}{
+		map[string]interface{}{"name": "mesh", "protocol": "HBONE", "port": int64(15008)},
+	}))
+
+	// 2. Act
+	gateways, err := resolver.ResolveGateways(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.Len(t, gateways, 1)
+	assert.True(t, gateways[0].Waypoint)
+	assert.Empty(t, gateways[0].Listeners)
+}
+
+func TestResolveGateways_WithMissingGateway_ReturnsError(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := autoLoginGatewayAuthPolicy("missing")
+	k8sClient := createFakeClientForGateways()
+
+	// 2. Act
+	gateways, err := resolver.ResolveGateways(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get Gateway default/missing")
+	assert.Nil(t, gateways)
+}
+
+func TestResolveGateways_WithoutAutoLogin_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := autoLoginGatewayAuthPolicy("missing")
+	authPolicy.Spec.AutoLogin.Enabled = false
+	k8sClient := createFakeClientForGateways()
+
+	// 2. Act
+	gateways, err := resolver.ResolveGateways(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err, "Gateways should not be read when auto-login is disabled")
+	assert.Nil(t, gateways)
+}
+
+func autoLoginGatewayAuthPolicy(gatewayName string) *ztoperatorv1alpha1.AuthPolicy {
+	return &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "auth-policy", Namespace: "default"},
+		Spec: ztoperatorv1alpha1.AuthPolicySpec{
+			Enabled: true,
+			TargetRefs: []ztoperatorv1alpha1.PolicyTargetReference{
+				{Kind: ztoperatorv1alpha1.TargetRefKindGateway, Name: gatewayName},
+			},
+			AutoLogin: &ztoperatorv1alpha1.AutoLogin{Enabled: true},
+		},
+	}
+}
+
+func gatewayObject(name, gatewayClassName string, listeners []interface{}) *unstructured.Unstructured {
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"gatewayClassName": gatewayClassName,
+			"listeners":        listeners,
+		},
+	}}
+	gateway.SetGroupVersionKind(resolver.GatewayGroupVersionKind)
+	gateway.SetName(name)
+	gateway.SetNamespace("default")
+	return gateway
+}
+
+func createFakeClientForGateways(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
@@ -0,0 +1,115 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/log"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResolveIdentityProviderRefs fetches every IdentityProvider referenced by the AuthPolicy, keyed by name.
+func ResolveIdentityProviderRefs(
+	ctx context.Context,
+	k8sClient client.Client,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+) (map[string]ztoperatorv1alpha1.IdentityProvider, error) {
+	rLog := log.GetLogger(ctx)
+	identityProviders := map[string]ztoperatorv1alpha1.IdentityProvider{}
+
+	for _, identityProviderRef := range authPolicy.GetIdentityProviderRefs() {
+		rLog.Debug(fmt.Sprintf("Trying to get IdentityProvider with name %s", identityProviderRef))
+		identityProvider := ztoperatorv1alpha1.IdentityProvider{}
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: identityProviderRef}, &identityProvider); err != nil {
+			return nil, fmt.Errorf(
+				"failed to get IdentityProvider %s referenced by %s: %w",
+				identityProviderRef,
+				authPolicyDescription(authPolicy),
+				err,
+			)
+		}
+		identityProviders[identityProviderRef] = identityProvider
+	}
+
+	return identityProviders, nil
+}
+
+// ResolveIdentityProviderRef returns the endpoints of an IdentityProvider, as last resolved by the IdentityProvider
+// controller. While the IdentityProvider is not reachable, the endpoints last resolved for its current generation are
+// used, so that a failed health check does not fail every AuthPolicy referencing it. Resolving fails if there are none.
+func ResolveIdentityProviderRef(
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+	identityProvider ztoperatorv1alpha1.IdentityProvider,
+	requireAutoLoginEndpoints bool,
+) (*state.IdentityProviderUris, error) {
+	if !identityProvider.IsReachable() && !identityProvider.HasStaleEndpoints() {
+		message := "it has not been checked yet"
+		reachableCondition := meta.FindStatusCondition(
+			identityProvider.Status.Conditions,
+			ztoperatorv1alpha1.IdentityProviderConditionReachable,
+		)
+		if reachableCondition != nil && identityProvider.Status.ObservedGeneration == identityProvider.Generation {
+			message = reachableCondition.Message
+		}
+		return nil, fmt.Errorf(
+			"IdentityProvider %s referenced by %s is not reachable: %s",
+			identityProvider.Name,
+			authPolicyDescription(authPolicy),
+			message,
+		)
+	}
+
+	status := identityProvider.Status
+	if requireAutoLoginEndpoints && (status.AuthorizationEndpoint == "" || status.EndSessionEndpoint == "") {
+		return nil, fmt.Errorf(
+			"issuer %s for %s does not support authorization endpoint or end session endpoint required for autologin",
+			status.Issuer,
+			authPolicyDescription(authPolicy),
+		)
+	}
+
+	identityProviderUris := state.IdentityProviderUris{
+		IssuerURI:        status.Issuer,
+		JwksURI:          status.JwksURI,
+		TokenURI:         status.TokenEndpoint,
+		AuthorizationURI: status.AuthorizationEndpoint,
+	}
+	if status.EndSessionEndpoint != "" {
+		endSessionURI := status.EndSessionEndpoint
+		identityProviderUris.EndSessionURI = &endSessionURI
+	}
+	return &identityProviderUris, nil
+}
+
+// MergeIdentityProviderDefaults returns a copy of the AuthPolicy where the .autoLogin defaults of the IdentityProvider
+// given by .spec.identityProviderRef are applied. Scopes are only used if the AuthPolicy does not set any, while login
+// parameters set by the AuthPolicy take precedence over those of the IdentityProvider.
+func MergeIdentityProviderDefaults(
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+	identityProviders map[string]ztoperatorv1alpha1.IdentityProvider,
+) *ztoperatorv1alpha1.AuthPolicy {
+	if authPolicy.Spec.IdentityProviderRef == nil || authPolicy.Spec.AutoLogin == nil {
+		return authPolicy
+	}
+	identityProvider, ok := identityProviders[*authPolicy.Spec.IdentityProviderRef]
+	if !ok || identityProvider.Spec.AutoLogin == nil {
+		return authPolicy
+	}
+
+	merged := authPolicy.DeepCopy()
+	defaults := identityProvider.Spec.AutoLogin
+	if len(merged.Spec.AutoLogin.Scopes) == 0 {
+		merged.Spec.AutoLogin.Scopes = slices.Clone(defaults.Scopes)
+	}
+	if len(defaults.LoginParams) > 0 {
+		loginParams := maps.Clone(defaults.LoginParams)
+		maps.Copy(loginParams, merged.Spec.AutoLogin.LoginParams)
+		merged.Spec.AutoLogin.LoginParams = loginParams
+	}
+	return merged
+}
@@ -0,0 +1,297 @@
+package resolver_test
+
+import (
+	"context"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResolveIdentityProviderRefs_ReturnsReferencedIdentityProviders(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.IdentityProviderRef = helperfunctions.Ptr("idporten")
+	authPolicy.Spec.IdentityProviders = []ztoperatorv1alpha1.TrustedIdentityProvider{
+		{Name: "entra", IdentityProviderRef: helperfunctions.Ptr("entra")},
+		{Name: "entra-again", IdentityProviderRef: helperfunctions.Ptr("entra")},
+	}
+	k8sClient := createFakeClientForIdentityProviders(
+		reachableIdentityProvider("idporten", "https://idporten.no"),
+		reachableIdentityProvider("entra", "https://login.microsoftonline.com/tenant/v2.0"),
+		reachableIdentityProvider("unreferenced", "https://unreferenced.example.com"),
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviderRefs(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, "https://idporten.no", result["idporten"].Status.Issuer)
+	assert.Equal(t, "https://login.microsoftonline.com/tenant/v2.0", result["entra"].Status.Issuer)
+}
+
+func TestResolveIdentityProviderRefs_WithMissingIdentityProvider_ReturnsError(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.IdentityProviderRef = helperfunctions.Ptr("idporten")
+	k8sClient := createFakeClientForIdentityProviders()
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviderRefs(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "failed to get IdentityProvider idporten referenced by AuthPolicy with name default/test-policy")
+}
+
+func TestResolveIdentityProviderRef_WithReachableIdentityProvider_ReturnsEndpointsFromStatus(t *testing.T) {
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	identityProvider := reachableIdentityProvider("idporten", "https://idporten.no")
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviderRef(authPolicy, *identityProvider, true)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, "https://idporten.no", result.IssuerURI)
+	assert.Equal(t, "https://idporten.no/jwks", result.JwksURI)
+	assert.Equal(t, "https://idporten.no/token", result.TokenURI)
+	assert.Equal(t, "https://idporten.no/authorize", result.AuthorizationURI)
+	require.NotNil(t, result.EndSessionURI)
+	assert.Equal(t, "https://idporten.no/logout", *result.EndSessionURI)
+}
+
+func TestResolveIdentityProviderRef_WithUnreachableIdentityProvider_ReturnsLastResolvedEndpoints(t *testing.T) {
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	identityProvider := reachableIdentityProvider("idporten", "https://idporten.no")
+	identityProvider.Status.Conditions[0].Status = metav1.ConditionFalse
+	identityProvider.Status.Conditions[0].Message = "connection refused"
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviderRef(authPolicy, *identityProvider, true)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, "https://idporten.no", result.IssuerURI)
+	assert.Equal(t, "https://idporten.no/jwks", result.JwksURI)
+	assert.Equal(t, "https://idporten.no/token", result.TokenURI)
+}
+
+func TestResolveIdentityProviderRef_WithUnreachableIdentityProviderWithoutEndpoints_ReturnsError(t *testing.T) {
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	identityProvider := reachableIdentityProvider("idporten", "https://idporten.no")
+	identityProvider.Status = ztoperatorv1alpha1.IdentityProviderStatus{
+		ObservedGeneration: 1,
+		Conditions: []metav1.Condition{
+			{
+				Type:    ztoperatorv1alpha1.IdentityProviderConditionReachable,
+				Status:  metav1.ConditionFalse,
+				Reason:  "Unreachable",
+				Message: "connection refused",
+			},
+		},
+	}
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviderRef(authPolicy, *identityProvider, false)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "IdentityProvider idporten referenced by AuthPolicy with name default/test-policy is not reachable: connection refused")
+}
+
+func TestResolveIdentityProviderRef_WithOutdatedStatus_ReturnsError(t *testing.T) {
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	identityProvider := reachableIdentityProvider("idporten", "https://idporten.no")
+	identityProvider.Generation = 2
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviderRef(authPolicy, *identityProvider, false)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "it has not been checked yet")
+}
+
+func TestResolveIdentityProviderRef_WithoutAutoLoginEndpoints_ReturnsErrorWhenRequired(t *testing.T) {
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	identityProvider := reachableIdentityProvider("maskinporten", "https://maskinporten.no")
+	identityProvider.Status.AuthorizationEndpoint = ""
+	identityProvider.Status.EndSessionEndpoint = ""
+
+	// 2. Act
+	_, errNotRequired := resolver.ResolveIdentityProviderRef(authPolicy, *identityProvider, false)
+	_, errRequired := resolver.ResolveIdentityProviderRef(authPolicy, *identityProvider, true)
+
+	// 3. Assert
+	require.NoError(t, errNotRequired)
+	require.Error(t, errRequired)
+	assert.Contains(t, errRequired.Error(), "required for autologin")
+}
+
+func TestMergeIdentityProviderDefaults_AppliesAutoLoginDefaults(t *testing.T) {
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.IdentityProviderRef = helperfunctions.Ptr("idporten")
+	authPolicy.Spec.AutoLogin = &ztoperatorv1alpha1.AutoLogin{
+		Enabled:     true,
+		LoginParams: map[string]string{"prompt": "login"},
+	}
+	identityProvider := reachableIdentityProvider("idporten", "https://idporten.no")
+	identityProvider.Spec.AutoLogin = &ztoperatorv1alpha1.IdentityProviderAutoLogin{
+		Scopes:      []string{"openid", "profile"},
+		LoginParams: map[string]string{"acr_values": "idporten-loa-high", "prompt": "none"},
+	}
+
+	// 2. Act
+	result := resolver.MergeIdentityProviderDefaults(
+		authPolicy,
+		map[string]ztoperatorv1alpha1.IdentityProvider{"idporten": *identityProvider},
+	)
+
+	// 3. Assert
+	assert.Equal(t, []string{"openid", "profile"}, result.Spec.AutoLogin.Scopes)
+	assert.Equal(
+		t,
+		map[string]string{"acr_values": "idporten-loa-high", "prompt": "login"},
+		result.Spec.AutoLogin.LoginParams,
+		"Login parameters set by the AuthPolicy should take precedence",
+	)
+	assert.Empty(t, authPolicy.Spec.AutoLogin.Scopes, "The AuthPolicy itself should not be changed")
+	assert.Equal(t, map[string]string{"prompt": "login"}, authPolicy.Spec.AutoLogin.LoginParams)
+}
+
+func TestMergeIdentityProviderDefaults_KeepsScopesOfAuthPolicy(t *testing.T) {
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.IdentityProviderRef = helperfunctions.Ptr("idporten")
+	authPolicy.Spec.AutoLogin = &ztoperatorv1alpha1.AutoLogin{Enabled: true, Scopes: []string{"openid"}}
+	identityProvider := reachableIdentityProvider("idporten", "https://idporten.no")
+	identityProvider.Spec.AutoLogin = &ztoperatorv1alpha1.IdentityProviderAutoLogin{
+		Scopes: []string{"openid", "profile"},
+	}
+
+	// 2. Act
+	result := resolver.MergeIdentityProviderDefaults(
+		authPolicy,
+		map[string]ztoperatorv1alpha1.IdentityProvider{"idporten": *identityProvider},
+	)
+
+	// 3. Assert
+	assert.Equal(t, []string{"openid"}, result.Spec.AutoLogin.Scopes)
+}
+
+func TestResolveIdentityProviders_WithIdentityProviderRef_UsesEndpointsFromStatus(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.IdentityProviders = []ztoperatorv1alpha1.TrustedIdentityProvider{
+		{Name: "entra", IdentityProviderRef: helperfunctions.Ptr("entra-prod")},
+	}
+	identityProviderRefs := map[string]ztoperatorv1alpha1.IdentityProvider{
+		"entra-prod": *reachableIdentityProvider("entra-prod", "https://entra.example.com"),
+	}
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviders(
+		ctx,
+		createFakeClientForAudiences(),
+		authPolicy,
+		identityProviderRefs,
+		&uriDiscoveryDocumentResolver{},
+	)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "entra", result[0].Name)
+	assert.Equal(t, "https://entra.example.com", result[0].IdentityProviderUris.IssuerURI)
+	assert.Equal(t, "https://entra.example.com/jwks", result[0].IdentityProviderUris.JwksURI)
+}
+
+func TestResolveIdentityProviderDiscoveryDocument_OverridesEndpoints(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	identityProvider := &ztoperatorv1alpha1.IdentityProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: "internal"},
+		Spec: ztoperatorv1alpha1.IdentityProviderSpec{
+			WellKnownURI: "https://internal.example.com/.well-known/openid-configuration",
+			Endpoints: &ztoperatorv1alpha1.IdentityProviderEndpoints{
+				JwksURI:            helperfunctions.Ptr("https://keys.internal.example.com/jwks"),
+				EndSessionEndpoint: helperfunctions.Ptr("https://internal.example.com/logout"),
+			},
+		},
+	}
+	discoveryResolver := &uriDiscoveryDocumentResolver{
+		documents: map[string]*rest.DiscoveryDocument{
+			"https://internal.example.com/.well-known/openid-configuration": discoveryDocumentForIssuer(
+				"https://internal.example.com",
+			),
+		},
+	}
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviderDiscoveryDocument(ctx, identityProvider, discoveryResolver)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, "https://internal.example.com", result.IssuerURI)
+	assert.Equal(t, "https://keys.internal.example.com/jwks", result.JwksURI)
+	assert.Equal(t, "https://internal.example.com/token", result.TokenURI)
+	require.NotNil(t, result.EndSessionURI)
+	assert.Equal(t, "https://internal.example.com/logout", *result.EndSessionURI)
+}
+
+func reachableIdentityProvider(name, issuer string) *ztoperatorv1alpha1.IdentityProvider {
+	return &ztoperatorv1alpha1.IdentityProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Generation: 1},
+		Spec: ztoperatorv1alpha1.IdentityProviderSpec{
+			WellKnownURI: issuer + "/.well-known/openid-configuration",
+		},
+		Status: ztoperatorv1alpha1.IdentityProviderStatus{
+			ObservedGeneration: 1,
+			Conditions: []metav1.Condition{
+				{
+					Type:   ztoperatorv1alpha1.IdentityProviderConditionReachable,
+					Status: metav1.ConditionTrue,
+					Reason: "Reachable",
+				},
+			},
+			Issuer:                issuer,
+			JwksURI:               issuer + "/jwks",
+			TokenEndpoint:         issuer + "/token",
+			AuthorizationEndpoint: issuer + "/authorize",
+			EndSessionEndpoint:    issuer + "/logout",
+		},
+	}
+}
+
+func createFakeClientForIdentityProviders(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
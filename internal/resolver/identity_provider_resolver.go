@@ -0,0 +1,96 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/log"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResolveIdentityProviders resolves the discovery document, audiences and JWKS of every identity provider listed in
+// .spec.identityProviders. The identity provider given by the top-level fields of the spec is not included.
+// Identity providers referencing an IdentityProvider are resolved from identityProviderRefs.
+func ResolveIdentityProviders(
+	ctx context.Context,
+	k8sClient client.Client,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+	identityProviderRefs map[string]ztoperatorv1alpha1.IdentityProvider,
+	resolver rest.DiscoveryDocumentResolver,
+) ([]state.IdentityProvider, error) {
+	rLog := log.GetLogger(ctx)
+	identityProviders := make([]state.IdentityProvider, 0, len(authPolicy.Spec.IdentityProviders))
+
+	for _, identityProvider := range authPolicy.Spec.IdentityProviders {
+		var identityProviderUris *state.IdentityProviderUris
+		var err error
+		if identityProvider.IdentityProviderRef != nil {
+			identityProviderUris, err = ResolveIdentityProviderRef(
+				authPolicy,
+				identityProviderRefs[*identityProvider.IdentityProviderRef],
+				false,
+			)
+		} else {
+			rLog.Info(
+				fmt.Sprintf(
+					"Trying to resolve discovery document from well-known uri: %s for identity provider %s in AuthPolicy with name %s/%s",
+					identityProvider.WellKnownURI,
+					identityProvider.Name,
+					authPolicy.Namespace,
+					authPolicy.Name,
+				),
+			)
+			identityProviderUris, err = resolveDiscoveryDocument(
+				ctx,
+				authPolicyDescription(authPolicy),
+				identityProvider.WellKnownURI,
+				identityProvider.Endpoints,
+				identityProvider.Jwks == nil,
+				false,
+				resolver,
+			)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve identity provider %s: %w", identityProvider.Name, err)
+		}
+
+		resolvedAudiences, err := ResolveAudiences(
+			ctx,
+			k8sClient,
+			authPolicy.Namespace,
+			identityProvider.AllowedAudiences,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to resolve audiences for identity provider %s: %w",
+				identityProvider.Name,
+				err,
+			)
+		}
+
+		resolvedJWKS, err := ResolveJWKS(ctx, k8sClient, authPolicy.Namespace, identityProvider.Jwks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve jwks for identity provider %s: %w", identityProvider.Name, err)
+		}
+
+		var acceptedResources []string
+		if identityProvider.AcceptedResources != nil {
+			acceptedResources = *identityProvider.AcceptedResources
+		}
+
+		identityProviders = append(identityProviders, state.IdentityProvider{
+			Name:                 identityProvider.Name,
+			IdentityProviderUris: *identityProviderUris,
+			Audiences:            *resolvedAudiences,
+			Jwks:                 resolvedJWKS,
+			AcceptedResources:    acceptedResources,
+			ForwardJwt:           identityProvider.ForwardJwt,
+			OutputClaimToHeaders: identityProvider.OutputClaimToHeaders,
+		})
+	}
+
+	return identityProviders, nil
+}
@@ -0,0 +1,139 @@
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/log"
+	"github.com/kartverket/ztoperator/pkg/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type uriDiscoveryDocumentResolver struct {
+	documents map[string]*rest.DiscoveryDocument
+}
+
+func (u *uriDiscoveryDocumentResolver) GetOAuthDiscoveryDocument(
+	uri string,
+	_ log.Logger,
+) (*rest.DiscoveryDocument, error) {
+	document, ok := u.documents[uri]
+	if !ok {
+		return nil, errors.New("discovery document not found")
+	}
+	return document, nil
+}
+
+func discoveryDocumentForIssuer(issuer string) *rest.DiscoveryDocument {
+	return &rest.DiscoveryDocument{
+		Issuer:        helperfunctions.Ptr(issuer),
+		JwksURI:       helperfunctions.Ptr(issuer + "/jwks"),
+		TokenEndpoint: helperfunctions.Ptr(issuer + "/token"),
+	}
+}
+
+func TestResolveIdentityProviders_WithoutIdentityProviders_ReturnsEmptyList(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("https://idp.example.com/.well-known/openid-configuration")
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviders(
+		ctx,
+		createFakeClientForAudiences(),
+		authPolicy,
+		nil,
+		&uriDiscoveryDocumentResolver{},
+	)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestResolveIdentityProviders_ResolvesEachIdentityProvider(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	forwardJwt := false
+	authPolicy.Spec.IdentityProviders = []ztoperatorv1alpha1.TrustedIdentityProvider{
+		{
+			Name:         "entra",
+			WellKnownURI: "https://entra.example.com/.well-known/openid-configuration",
+			AllowedAudiences: []ztoperatorv1alpha1.AllowedAudience{
+				{Value: helperfunctions.Ptr("entra-client")},
+			},
+			ForwardJwt: &forwardJwt,
+		},
+		{
+			Name:              "maskinporten",
+			WellKnownURI:      "https://maskinporten.example.com/.well-known/oauth-authorization-server",
+			AcceptedResources: &[]string{"https://api.example.com"},
+		},
+	}
+	discoveryResolver := &uriDiscoveryDocumentResolver{
+		documents: map[string]*rest.DiscoveryDocument{
+			"https://entra.example.com/.well-known/openid-configuration": discoveryDocumentForIssuer(
+				"https://entra.example.com",
+			),
+			"https://maskinporten.example.com/.well-known/oauth-authorization-server": discoveryDocumentForIssuer(
+				"https://maskinporten.example.com",
+			),
+		},
+	}
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviders(
+		ctx,
+		createFakeClientForAudiences(),
+		authPolicy,
+		nil,
+		discoveryResolver,
+	)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+
+	assert.Equal(t, "entra", result[0].Name)
+	assert.Equal(t, "https://entra.example.com", result[0].IdentityProviderUris.IssuerURI)
+	assert.Equal(t, "https://entra.example.com/jwks", result[0].IdentityProviderUris.JwksURI)
+	assert.Equal(t, []string{"entra-client"}, result[0].Audiences)
+	assert.Equal(t, &forwardJwt, result[0].ForwardJwt)
+
+	assert.Equal(t, "maskinporten", result[1].Name)
+	assert.Equal(t, "https://maskinporten.example.com", result[1].IdentityProviderUris.IssuerURI)
+	assert.Empty(t, result[1].Audiences)
+	assert.Equal(t, []string{"https://api.example.com"}, result[1].AcceptedResources)
+}
+
+func TestResolveIdentityProviders_WithUnresolvableDiscoveryDocument_ReturnsError(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := defaultZtoperatorAuthPolicy("")
+	authPolicy.Spec.IdentityProviders = []ztoperatorv1alpha1.TrustedIdentityProvider{
+		{Name: "unreachable", WellKnownURI: "https://unreachable.example.com/.well-known/openid-configuration"},
+	}
+
+	// 2. Act
+	result, err := resolver.ResolveIdentityProviders(
+		ctx,
+		createFakeClientForAudiences(),
+		authPolicy,
+		nil,
+		&uriDiscoveryDocumentResolver{},
+	)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "failed to resolve identity provider unreachable")
+}
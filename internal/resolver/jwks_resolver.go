@@ -0,0 +1,46 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResolveJWKS resolves the JWKS given inline or referenced from a ConfigMap/Secret, and validates it before it is
+// rendered into the RequestAuthentication. Nil is returned when no JWKS is given.
+func ResolveJWKS(
+	ctx context.Context,
+	k8sClient client.Client,
+	namespace string,
+	jwks *ztoperatorv1alpha1.Jwks,
+) (*string, error) {
+	if jwks == nil {
+		return nil, nil
+	}
+	if jwks.Value != nil && jwks.ValueFrom != nil {
+		return nil, errors.New("cannot define jwks as both string and ConfigMap/Secret ref")
+	}
+
+	var resolvedJWKS *string
+	switch {
+	case jwks.Value != nil:
+		resolvedJWKS = jwks.Value
+	case jwks.ValueFrom != nil:
+		var err error
+		resolvedJWKS, err = resolveValueFrom(ctx, k8sClient, namespace, *jwks.ValueFrom, "jwks")
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve jwks reference: %w", err)
+		}
+	default:
+		return nil, errors.New("either value or valueFrom must be set for jwks")
+	}
+
+	if err := validation.ValidateJWKS(*resolvedJWKS); err != nil {
+		return nil, err
+	}
+	return resolvedJWKS, nil
+}
@@ -0,0 +1,134 @@
+package resolver_test
+
+import (
+	"context"
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testJWKS = `{"keys":[{"kid":"key-1","kty":"RSA","alg":"RS256","use":"sig","n":"AQAB","e":"AQAB"}]}`
+
+func TestResolveJWKS_WithNoJWKS_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	k8sClient := createFakeClientForAudiences()
+
+	// 2. Act
+	result, err := resolver.ResolveJWKS(ctx, k8sClient, "default", nil)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Nil(t, result, "Result should be nil when no JWKS is given")
+}
+
+func TestResolveJWKS_WithStaticValue_ReturnsValue(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	jwks := &ztoperatorv1alpha1.Jwks{Value: helperfunctions.Ptr(testJWKS)}
+	k8sClient := createFakeClientForAudiences()
+
+	// 2. Act
+	result, err := resolver.ResolveJWKS(ctx, k8sClient, "default", jwks)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, testJWKS, *result)
+}
+
+func TestResolveJWKS_WithConfigMapRef_ReturnsConfigMapValue(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "jwks", Namespace: "default"},
+		Data:       map[string]string{"jwks.json": testJWKS},
+	}
+	jwks := &ztoperatorv1alpha1.Jwks{
+		ValueFrom: &ztoperatorv1alpha1.ValueFrom{
+			ConfigMapKeyRef: &ztoperatorv1alpha1.KeyRef{Name: "jwks", Key: "jwks.json"},
+		},
+	}
+	k8sClient := createFakeClientForAudiences(configMap)
+
+	// 2. Act
+	result, err := resolver.ResolveJWKS(ctx, k8sClient, "default", jwks)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, testJWKS, *result)
+}
+
+func TestResolveJWKS_WithSecretRef_ReturnsSecretValue(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "jwks", Namespace: "default"},
+		Data:       map[string][]byte{"jwks.json": []byte(testJWKS)},
+	}
+	jwks := &ztoperatorv1alpha1.Jwks{
+		ValueFrom: &ztoperatorv1alpha1.ValueFrom{
+			SecretKeyRef: &ztoperatorv1alpha1.KeyRef{Name: "jwks", Key: "jwks.json"},
+		},
+	}
+	k8sClient := createFakeClientForAudiences(secret)
+
+	// 2. Act
+	result, err := resolver.ResolveJWKS(ctx, k8sClient, "default", jwks)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, testJWKS, *result)
+}
+
+func TestResolveJWKS_WithMissingConfigMapKey_ReturnsError(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "jwks", Namespace: "default"},
+		Data:       map[string]string{"other": testJWKS},
+	}
+	jwks := &ztoperatorv1alpha1.Jwks{
+		ValueFrom: &ztoperatorv1alpha1.ValueFrom{
+			ConfigMapKeyRef: &ztoperatorv1alpha1.KeyRef{Name: "jwks", Key: "jwks.json"},
+		},
+	}
+	k8sClient := createFakeClientForAudiences(configMap)
+
+	// 2. Act
+	result, err := resolver.ResolveJWKS(ctx, k8sClient, "default", jwks)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "jwks value from configmap default/jwks key jwks.json is empty or missing")
+}
+
+func TestResolveJWKS_WithInvalidJWKS_ReturnsError(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	jwks := &ztoperatorv1alpha1.Jwks{Value: helperfunctions.Ptr(`{"keys":[{"kty":"oct","alg":"HS256"}]}`)}
+	k8sClient := createFakeClientForAudiences()
+
+	// 2. Act
+	result, err := resolver.ResolveJWKS(ctx, k8sClient, "default", jwks)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "unsupported algorithm HS256")
+}
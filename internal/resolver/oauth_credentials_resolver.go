@@ -12,14 +12,13 @@ import (
 )
 
 // ResolveOAuthCredentials retrieves and validates OAuth client credentials from a Kubernetes Secret.
+// The credentials are only resolved when used, i.e. when auto-login, egress or token exchange is enabled.
 func ResolveOAuthCredentials(
 	ctx context.Context,
 	k8sClient client.Client,
 	authPolicy *ztoperatorv1alpha1.AuthPolicy,
 ) (*state.OAuthCredentials, error) {
-	if authPolicy.Spec.OAuthCredentials == nil ||
-		authPolicy.Spec.AutoLogin == nil ||
-		!authPolicy.Spec.AutoLogin.Enabled {
+	if authPolicy.Spec.OAuthCredentials == nil || !authPolicy.UsesOAuthCredentials() {
 		return &state.OAuthCredentials{}, nil
 	}
 
@@ -228,6 +228,37 @@ func TestResolveOAuthCredentials_WithValidSecret_ReturnsCredentials(t *testing.T
 	assert.Equal(t, expectedClientSecret, *result.ClientSecret, "ClientSecret should match expected value")
 }
 
+func TestResolveOAuthCredentials_WithEgressEnabled_ReturnsCredentials(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "oauth-secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"client-id":     []byte("my-client-id"),
+			"client-secret": []byte("my-client-secret"),
+		},
+	}
+
+	authPolicy := createAuthPolicyWithOAuth("oauth-secret", false)
+	authPolicy.Spec.AutoLogin = nil
+	authPolicy.Spec.Egress = &ztoperatorv1alpha1.Egress{Enabled: true}
+	k8sClient := createFakeClientForOauthCredentials(secret)
+
+	// 2. Act
+	result, err := resolver.ResolveOAuthCredentials(ctx, k8sClient, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result.ClientID)
+	require.NotNil(t, result.ClientSecret)
+	assert.Equal(t, "my-client-id", *result.ClientID)
+	assert.Equal(t, "my-client-secret", *result.ClientSecret)
+}
+
 func createAuthPolicyWithOAuth(
 	secretRef string,
 	autoLoginEnabled bool,
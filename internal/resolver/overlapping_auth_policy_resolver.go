@@ -0,0 +1,283 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/log"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GatewayNameLabelKey is the label Istio sets on the pods it deploys for a Gateway, including waypoints.
+const GatewayNameLabelKey = "gateway.networking.k8s.io/gateway-name"
+
+// ResolveOverlappingAuthPolicy returns the name of the oldest applied AuthPolicy in the namespace of the AuthPolicy
+// which selects any of the pods protected by the AuthPolicy, or targets any of the same resources, provided it takes
+// precedence over the AuthPolicy. Returns nil when the AuthPolicy does not overlap with any applied AuthPolicy taking
+// precedence over it.
+//
+// The pods of an AuthPolicy with targetRefs are those selected by a targeted Service, and those Istio deploys for a
+// targeted Gateway.
+//
+// Envoy merges the filters of AuthPolicies applying to the same pods unpredictably, and Istio combines their
+// AuthorizationPolicies, hence only the AuthPolicy taking precedence is applied. An AuthPolicy takes precedence over
+// another if it was created first, or if both were created at the same time and its name sorts first. An AuthPolicy
+// which is refused itself is not applied, thus it does not refuse any other AuthPolicy.
+func ResolveOverlappingAuthPolicy(
+	ctx context.Context,
+	k8sClient client.Client,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+) (*string, error) {
+	rLog := log.GetLogger(ctx)
+	if !authPolicy.Spec.Enabled {
+		return nil, nil
+	}
+
+	targets, err := resolveAuthPolicyTargets(ctx, k8sClient, *authPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets.pods) == 0 && len(targets.targetRefs) == 0 {
+		return nil, nil
+	}
+
+	authPolicyList := &ztoperatorv1alpha1.AuthPolicyList{}
+	if listErr := k8sClient.List(ctx, authPolicyList, client.InNamespace(authPolicy.Namespace)); listErr != nil {
+		return nil, fmt.Errorf("failed to list AuthPolicies in namespace %s: %w", authPolicy.Namespace, listErr)
+	}
+
+	// Neither the AuthPolicy itself, nor any AuthPolicy following it, takes precedence over it
+	candidates := slices.DeleteFunc(authPolicyList.Items, func(candidate ztoperatorv1alpha1.AuthPolicy) bool {
+		return compareAuthPolicyPrecedence(candidate, *authPolicy) >= 0
+	})
+	appliedAuthPolicies, err := resolveAppliedAuthPolicies(ctx, k8sClient, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	if overlapping, shared := findOverlappingAuthPolicy(appliedAuthPolicies, targets); overlapping != nil {
+		rLog.Info(fmt.Sprintf(
+			"AuthPolicy %s/%s overlaps with AuthPolicy %s on %s",
+			authPolicy.Namespace,
+			authPolicy.Name,
+			overlapping.authPolicy.Name,
+			shared,
+		))
+		return &overlapping.authPolicy.Name, nil
+	}
+	return nil, nil
+}
+
+// ResolveAuthPolicySharingPods returns the name of an applied AuthPolicy in the namespace of the AuthPolicy which
+// selects any of the pods the AuthPolicy selects, or targets any of the same resources, regardless of precedence.
+// Returns nil when no applied AuthPolicy shares any of its pods.
+func ResolveAuthPolicySharingPods(
+	ctx context.Context,
+	k8sClient client.Client,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+) (*string, error) {
+	targets, err := resolveAuthPolicyTargets(ctx, k8sClient, *authPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets.pods) == 0 && len(targets.targetRefs) == 0 {
+		return nil, nil
+	}
+
+	authPolicyList := &ztoperatorv1alpha1.AuthPolicyList{}
+	if listErr := k8sClient.List(ctx, authPolicyList, client.InNamespace(authPolicy.Namespace)); listErr != nil {
+		return nil, fmt.Errorf("failed to list AuthPolicies in namespace %s: %w", authPolicy.Namespace, listErr)
+	}
+
+	candidates := slices.DeleteFunc(authPolicyList.Items, func(candidate ztoperatorv1alpha1.AuthPolicy) bool {
+		return candidate.Name == authPolicy.Name
+	})
+	appliedAuthPolicies, err := resolveAppliedAuthPolicies(ctx, k8sClient, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	if sharing, _ := findOverlappingAuthPolicy(appliedAuthPolicies, targets); sharing != nil {
+		return &sharing.authPolicy.Name, nil
+	}
+	return nil, nil
+}
+
+// ResolveAuthPolicyForPod returns the applied AuthPolicy in the namespace which selects a pod with the given labels,
+// and which takes precedence over any other AuthPolicy selecting the pod.
+// Returns nil when no applied AuthPolicy selects the pod.
+func ResolveAuthPolicyForPod(
+	ctx context.Context,
+	k8sClient client.Client,
+	namespace string,
+	podLabels map[string]string,
+) (*ztoperatorv1alpha1.AuthPolicy, error) {
+	authPolicyList := &ztoperatorv1alpha1.AuthPolicyList{}
+	if listErr := k8sClient.List(ctx, authPolicyList, client.InNamespace(namespace)); listErr != nil {
+		return nil, fmt.Errorf("failed to list AuthPolicies in namespace %s: %w", namespace, listErr)
+	}
+
+	appliedAuthPolicies, err := resolveAppliedAuthPolicies(ctx, k8sClient, authPolicyList.Items)
+	if err != nil {
+		return nil, err
+	}
+	for _, applied := range appliedAuthPolicies {
+		if applied.targets.selects(podLabels) {
+			return &applied.authPolicy, nil
+		}
+	}
+	return nil, nil
+}
+
+// appliedAuthPolicy is an AuthPolicy which is not refused, along with the pods and resources it applies to.
+type appliedAuthPolicy struct {
+	authPolicy ztoperatorv1alpha1.AuthPolicy
+	targets    authPolicyTargets
+}
+
+// authPolicyTargets holds the selectors of the pods an AuthPolicy applies to, the pods currently matching them, and
+// the resources referenced by its targetRefs.
+type authPolicyTargets struct {
+	selectors  []labels.Selector
+	pods       []v1.Pod
+	targetRefs []ztoperatorv1alpha1.PolicyTargetReference
+}
+
+// selects reports whether any of the selectors matches a pod with the given labels.
+func (t authPolicyTargets) selects(podLabels map[string]string) bool {
+	return slices.ContainsFunc(t.selectors, func(selector labels.Selector) bool {
+		return selector.Matches(labels.Set(podLabels))
+	})
+}
+
+// resolveAuthPolicyTargets resolves the pods an AuthPolicy applies to, either through its selector, or through the
+// Services and Gateways referenced by its targetRefs. A Service applies to the pods its selector matches, and a Gateway
+// to the pods Istio deploys for it. Services which do not exist, or select no pods, only count as a target reference.
+func resolveAuthPolicyTargets(
+	ctx context.Context,
+	k8sClient client.Client,
+	authPolicy ztoperatorv1alpha1.AuthPolicy,
+) (authPolicyTargets, error) {
+	targets := authPolicyTargets{targetRefs: authPolicy.Spec.TargetRefs}
+	if authPolicy.Spec.Selector != nil {
+		protectedPods, err := helperfunctions.GetProtectedPods(ctx, k8sClient, authPolicy)
+		if err != nil {
+			return targets, err
+		}
+		targets.selectors = append(targets.selectors, labels.SelectorFromSet(authPolicy.Spec.Selector.MatchLabels))
+		targets.pods = append(targets.pods, *protectedPods...)
+	}
+
+	for _, targetRef := range authPolicy.Spec.TargetRefs {
+		var podLabels map[string]string
+		switch targetRef.Kind {
+		case ztoperatorv1alpha1.TargetRefKindGateway:
+			podLabels = map[string]string{GatewayNameLabelKey: targetRef.Name}
+		case ztoperatorv1alpha1.TargetRefKindService:
+			service := &v1.Service{}
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: authPolicy.Namespace, Name: targetRef.Name}, service)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return targets, fmt.Errorf(
+					"failed to get Service %s/%s targeted by AuthPolicy %s: %w",
+					authPolicy.Namespace,
+					targetRef.Name,
+					authPolicy.Name,
+					err,
+				)
+			}
+			podLabels = service.Spec.Selector
+		}
+		if len(podLabels) == 0 {
+			continue
+		}
+
+		var podList v1.PodList
+		if listErr := k8sClient.List(
+			ctx,
+			&podList,
+			client.InNamespace(authPolicy.Namespace),
+			client.MatchingLabels(podLabels),
+		); listErr != nil {
+			return targets, fmt.Errorf(
+				"failed to list pods of %s %s targeted by AuthPolicy %s/%s: %w",
+				targetRef.Kind,
+				targetRef.Name,
+				authPolicy.Namespace,
+				authPolicy.Name,
+				listErr,
+			)
+		}
+		targets.selectors = append(targets.selectors, labels.SelectorFromSet(podLabels))
+		targets.pods = append(targets.pods, podList.Items...)
+	}
+	return targets, nil
+}
+
+// resolveAppliedAuthPolicies returns the enabled AuthPolicies among the candidates which are not refused due to an
+// overlapping AuthPolicy taking precedence, ordered by precedence.
+func resolveAppliedAuthPolicies(
+	ctx context.Context,
+	k8sClient client.Client,
+	candidates []ztoperatorv1alpha1.AuthPolicy,
+) ([]appliedAuthPolicy, error) {
+	slices.SortFunc(candidates, compareAuthPolicyPrecedence)
+
+	var appliedAuthPolicies []appliedAuthPolicy
+	for _, candidate := range candidates {
+		if !candidate.Spec.Enabled {
+			continue
+		}
+		targets, err := resolveAuthPolicyTargets(ctx, k8sClient, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if overlapping, _ := findOverlappingAuthPolicy(appliedAuthPolicies, targets); overlapping != nil {
+			continue
+		}
+		appliedAuthPolicies = append(appliedAuthPolicies, appliedAuthPolicy{authPolicy: candidate, targets: targets})
+	}
+	return appliedAuthPolicies, nil
+}
+
+// findOverlappingAuthPolicy returns the first of the applied AuthPolicies selecting any of the pods, or targeting any
+// of the same resources, along with a description of what is shared.
+func findOverlappingAuthPolicy(
+	appliedAuthPolicies []appliedAuthPolicy,
+	targets authPolicyTargets,
+) (*appliedAuthPolicy, string) {
+	for i := range appliedAuthPolicies {
+		for _, targetRef := range targets.targetRefs {
+			if slices.Contains(appliedAuthPolicies[i].targets.targetRefs, targetRef) {
+				return &appliedAuthPolicies[i], fmt.Sprintf("%s %s", targetRef.Kind, targetRef.Name)
+			}
+		}
+		for _, pod := range targets.pods {
+			if appliedAuthPolicies[i].targets.selects(pod.Labels) {
+				return &appliedAuthPolicies[i], "pod " + pod.Name
+			}
+		}
+	}
+	return nil, ""
+}
+
+// compareAuthPolicyPrecedence orders AuthPolicies by creation time, breaking ties by name.
+func compareAuthPolicyPrecedence(a, b ztoperatorv1alpha1.AuthPolicy) int {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		if a.CreationTimestamp.Before(&b.CreationTimestamp) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.Name, b.Name)
+}
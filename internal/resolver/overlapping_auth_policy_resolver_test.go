@@ -0,0 +1,462 @@
+package resolver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var overlapCreationTime = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestResolveOverlappingAuthPolicy_WithOlderOverlappingAuthPolicy_ReturnsOlderAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := overlappingAuthPolicy("older", 0, map[string]string{"app": "application"})
+	newer := overlappingAuthPolicy("newer", time.Minute, map[string]string{"app": "application", "tier": "web"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("application", map[string]string{"app": "application", "tier": "web"}),
+		older,
+		newer,
+	)
+
+	// 2. Act
+	olderResult, olderErr := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, older)
+	newerResult, newerErr := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, olderErr)
+	require.NoError(t, newerErr)
+	assert.Nil(t, olderResult, "The older AuthPolicy should take precedence")
+	require.NotNil(t, newerResult, "The newer AuthPolicy should overlap with the older AuthPolicy")
+	assert.Equal(t, "older", *newerResult)
+}
+
+func TestResolveOverlappingAuthPolicy_WithSameCreationTimestamp_BreaksTieByName(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	first := overlappingAuthPolicy("a-policy", 0, map[string]string{"app": "application"})
+	second := overlappingAuthPolicy("b-policy", 0, map[string]string{"app": "application"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("application", map[string]string{"app": "application"}),
+		first,
+		second,
+	)
+
+	// 2. Act
+	firstResult, firstErr := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, first)
+	secondResult, secondErr := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, second)
+
+	// 3. Assert
+	require.NoError(t, firstErr)
+	require.NoError(t, secondErr)
+	assert.Nil(t, firstResult)
+	require.NotNil(t, secondResult)
+	assert.Equal(t, "a-policy", *secondResult)
+}
+
+func TestResolveOverlappingAuthPolicy_WithoutSharedPods_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := overlappingAuthPolicy("older", 0, map[string]string{"app": "first"})
+	newer := overlappingAuthPolicy("newer", time.Minute, map[string]string{"app": "second"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("first", map[string]string{"app": "first"}),
+		overlappingPod("second", map[string]string{"app": "second"}),
+		older,
+		newer,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Nil(t, result, "AuthPolicies not sharing any pods should not overlap")
+}
+
+func TestResolveOverlappingAuthPolicy_WithoutProtectedPods_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := overlappingAuthPolicy("older", 0, map[string]string{"app": "application"})
+	newer := overlappingAuthPolicy("newer", time.Minute, map[string]string{"app": "application"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(older, newer)
+
+	// 2. Act
+	result, err := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Nil(t, result, "AuthPolicies should only overlap on existing pods")
+}
+
+func TestResolveOverlappingAuthPolicy_WithDisabledOlderAuthPolicy_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := overlappingAuthPolicy("older", 0, map[string]string{"app": "application"})
+	older.Spec.Enabled = false
+	newer := overlappingAuthPolicy("newer", time.Minute, map[string]string{"app": "application"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("application", map[string]string{"app": "application"}),
+		older,
+		newer,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Nil(t, result, "Disabled AuthPolicies should not take precedence")
+}
+
+func TestResolveOverlappingAuthPolicy_WithOlderAuthPolicyInOtherNamespace_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := overlappingAuthPolicy("older", 0, map[string]string{"app": "application"})
+	older.Namespace = "other"
+	newer := overlappingAuthPolicy("newer", time.Minute, map[string]string{"app": "application"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("application", map[string]string{"app": "application"}),
+		older,
+		newer,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Nil(t, result, "AuthPolicies in other namespaces should not overlap")
+}
+
+func TestResolveOverlappingAuthPolicy_WithSingleSharedPod_ReturnsOlderAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := overlappingAuthPolicy("older", 0, map[string]string{"team": "first"})
+	newer := overlappingAuthPolicy("newer", time.Minute, map[string]string{"component": "api"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("first", map[string]string{"team": "first"}),
+		overlappingPod("shared", map[string]string{"team": "first", "component": "api"}),
+		overlappingPod("second", map[string]string{"team": "second", "component": "api"}),
+		older,
+		newer,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result, "A single shared pod should make the AuthPolicies overlap")
+	assert.Equal(t, "older", *result)
+}
+
+func TestResolveOverlappingAuthPolicy_WithRefusedOlderAuthPolicy_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	oldest := overlappingAuthPolicy("oldest", 0, map[string]string{"app": "application"})
+	older := overlappingAuthPolicy("older", time.Minute, map[string]string{"tier": "web"})
+	newer := overlappingAuthPolicy("newer", 2*time.Minute, map[string]string{"zone": "edge"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("application", map[string]string{"app": "application", "tier": "web"}),
+		overlappingPod("web", map[string]string{"tier": "web", "zone": "edge"}),
+		oldest,
+		older,
+		newer,
+	)
+
+	// 2. Act
+	olderResult, olderErr := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, older)
+	newerResult, newerErr := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+	webPodAuthPolicy, webPodErr := resolver.ResolveAuthPolicyForPod(
+		ctx,
+		k8sClient,
+		"default",
+		map[string]string{"tier": "web", "zone": "edge"},
+	)
+
+	// 3. Assert
+	require.NoError(t, olderErr)
+	require.NotNil(t, olderResult)
+	assert.Equal(t, "oldest", *olderResult)
+	require.NoError(t, newerErr)
+	assert.Nil(t, newerResult, "An AuthPolicy which is refused itself should not refuse other AuthPolicies")
+	require.NoError(t, webPodErr)
+	require.NotNil(t, webPodAuthPolicy)
+	assert.Equal(t, "newer", webPodAuthPolicy.Name)
+}
+
+func TestResolveAuthPolicyForPod_WithMultipleAuthPolicies_ReturnsAuthPolicyTakingPrecedence(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := overlappingAuthPolicy("older", 0, map[string]string{"app": "application"})
+	newer := overlappingAuthPolicy("newer", time.Minute, map[string]string{"app": "application"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(newer, older)
+
+	// 2. Act
+	result, err := resolver.ResolveAuthPolicyForPod(
+		ctx,
+		k8sClient,
+		"default",
+		map[string]string{"app": "application", "pod-template-hash": "abc"},
+	)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "older", result.Name)
+}
+
+func TestResolveAuthPolicyForPod_WithoutMatchingAuthPolicy_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	disabled := overlappingAuthPolicy("disabled", 0, map[string]string{"app": "application"})
+	disabled.Spec.Enabled = false
+	other := overlappingAuthPolicy("other", 0, map[string]string{"app": "other"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(disabled, other)
+
+	// 2. Act
+	result, err := resolver.ResolveAuthPolicyForPod(ctx, k8sClient, "default", map[string]string{"app": "application"})
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestResolveOverlappingAuthPolicy_WithServiceTargetSelectingSamePods_ReturnsOlderAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := overlappingAuthPolicy("older", 0, map[string]string{"app": "application"})
+	newer := targetRefsAuthPolicy("newer", time.Minute, ztoperatorv1alpha1.PolicyTargetReference{
+		Kind: ztoperatorv1alpha1.TargetRefKindService,
+		Name: "application",
+	})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("application", map[string]string{"app": "application"}),
+		overlappingService("application", map[string]string{"app": "application"}),
+		older,
+		newer,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result, "A Service targeted by the AuthPolicy selects pods of the older AuthPolicy")
+	assert.Equal(t, "older", *result)
+}
+
+func TestResolveOverlappingAuthPolicy_WithSameServiceTarget_ReturnsOlderAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	targetRef := ztoperatorv1alpha1.PolicyTargetReference{Kind: ztoperatorv1alpha1.TargetRefKindService, Name: "application"}
+	older := targetRefsAuthPolicy("older", 0, targetRef)
+	newer := targetRefsAuthPolicy("newer", time.Minute, targetRef)
+	k8sClient := createFakeClientForOverlappingAuthPolicies(older, newer)
+
+	// 2. Act
+	olderResult, olderErr := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, older)
+	newerResult, newerErr := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, olderErr)
+	require.NoError(t, newerErr)
+	assert.Nil(t, olderResult)
+	require.NotNil(t, newerResult, "AuthPolicies targeting the same Service overlap, even without any pods")
+	assert.Equal(t, "older", *newerResult)
+}
+
+func TestResolveOverlappingAuthPolicy_WithGatewayTargetSelectingSamePods_ReturnsOlderAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := targetRefsAuthPolicy("older", 0, ztoperatorv1alpha1.PolicyTargetReference{
+		Kind: ztoperatorv1alpha1.TargetRefKindGateway,
+		Name: "ingress",
+	})
+	newer := overlappingAuthPolicy("newer", time.Minute, map[string]string{"istio": "ingressgateway"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("ingress", map[string]string{
+			resolver.GatewayNameLabelKey: "ingress",
+			"istio":                      "ingressgateway",
+		}),
+		older,
+		newer,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result, "The pods deployed for a targeted Gateway are protected by the older AuthPolicy")
+	assert.Equal(t, "older", *result)
+}
+
+func TestResolveOverlappingAuthPolicy_WithDifferentServiceTargets_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	older := targetRefsAuthPolicy("older", 0, ztoperatorv1alpha1.PolicyTargetReference{
+		Kind: ztoperatorv1alpha1.TargetRefKindService,
+		Name: "first",
+	})
+	newer := targetRefsAuthPolicy("newer", time.Minute, ztoperatorv1alpha1.PolicyTargetReference{
+		Kind: ztoperatorv1alpha1.TargetRefKindService,
+		Name: "second",
+	})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("first", map[string]string{"app": "first"}),
+		overlappingPod("second", map[string]string{"app": "second"}),
+		overlappingService("first", map[string]string{"app": "first"}),
+		overlappingService("second", map[string]string{"app": "second"}),
+		older,
+		newer,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveOverlappingAuthPolicy(ctx, k8sClient, newer)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestResolveAuthPolicyForPod_WithServiceTarget_ReturnsAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	authPolicy := targetRefsAuthPolicy("policy", 0, ztoperatorv1alpha1.PolicyTargetReference{
+		Kind: ztoperatorv1alpha1.TargetRefKindService,
+		Name: "application",
+	})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingService("application", map[string]string{"app": "application"}),
+		authPolicy,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveAuthPolicyForPod(ctx, k8sClient, "default", map[string]string{"app": "application"})
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "policy", result.Name)
+}
+
+func TestResolveAuthPolicySharingPods_WithNewerAuthPolicySelectingSamePods_ReturnsNewerAuthPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	disabled := overlappingAuthPolicy("disabled", 0, map[string]string{"app": "application"})
+	disabled.Spec.Enabled = false
+	newer := overlappingAuthPolicy("newer", time.Minute, map[string]string{"app": "application"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("application", map[string]string{"app": "application"}),
+		disabled,
+		newer,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveAuthPolicySharingPods(ctx, k8sClient, disabled)
+
+	// 3. Assert
+	require.NoError(t, err)
+	require.NotNil(t, result, "An AuthPolicy sharing pods should be found regardless of precedence")
+	assert.Equal(t, "newer", *result)
+}
+
+func TestResolveAuthPolicySharingPods_WithoutAppliedAuthPolicySelectingSamePods_ReturnsNil(t *testing.T) {
+	ctx := context.Background()
+
+	// 1. Arrange
+	disabled := overlappingAuthPolicy("disabled", 0, map[string]string{"app": "application"})
+	disabled.Spec.Enabled = false
+	otherDisabled := overlappingAuthPolicy("other-disabled", time.Minute, map[string]string{"app": "application"})
+	otherDisabled.Spec.Enabled = false
+	other := overlappingAuthPolicy("other", time.Minute, map[string]string{"app": "other"})
+	k8sClient := createFakeClientForOverlappingAuthPolicies(
+		overlappingPod("application", map[string]string{"app": "application"}),
+		overlappingPod("other", map[string]string{"app": "other"}),
+		disabled,
+		otherDisabled,
+		other,
+	)
+
+	// 2. Act
+	result, err := resolver.ResolveAuthPolicySharingPods(ctx, k8sClient, disabled)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Nil(t, result, "Disabled AuthPolicies and AuthPolicies selecting other pods should not share pods")
+}
+
+func overlappingAuthPolicy(
+	name string,
+	createdAfter time.Duration,
+	matchLabels map[string]string,
+) *ztoperatorv1alpha1.AuthPolicy {
+	return &ztoperatorv1alpha1.AuthPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(overlapCreationTime.Add(createdAfter)),
+		},
+		Spec: ztoperatorv1alpha1.AuthPolicySpec{
+			Enabled:  true,
+			Selector: &ztoperatorv1alpha1.WorkloadSelector{MatchLabels: matchLabels},
+		},
+	}
+}
+
+func targetRefsAuthPolicy(
+	name string,
+	createdAfter time.Duration,
+	targetRefs ...ztoperatorv1alpha1.PolicyTargetReference,
+) *ztoperatorv1alpha1.AuthPolicy {
+	authPolicy := overlappingAuthPolicy(name, createdAfter, nil)
+	authPolicy.Spec.Selector = nil
+	authPolicy.Spec.TargetRefs = targetRefs
+	return authPolicy
+}
+
+func overlappingService(name string, selector map[string]string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       v1.ServiceSpec{Selector: selector},
+	}
+}
+
+func overlappingPod(name string, podLabels map[string]string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: podLabels}}
+}
+
+func createFakeClientForOverlappingAuthPolicies(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
@@ -2,8 +2,12 @@ package state
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -13,6 +17,15 @@ type Scope struct {
 	AutoLoginConfig        AutoLoginConfig
 	OAuthCredentials       OAuthCredentials
 	IdentityProviderUris   IdentityProviderUris
+	Jwks                   *string
+	IdentityProviders      []IdentityProvider
+	IdentityProviderRefs   map[string]ztoperatorv1alpha1.IdentityProvider
+	ClusterAuthPolicies    []string
+	RuleConflicts          []ztoperatorv1alpha1.RuleConflict
+	OverlappingAuthPolicy  *string
+	DefaultDeny            bool
+	SharingAuthPolicy      *string
+	Gateways               []Gateway
 	Descendants            []Descendant[client.Object]
 	InvalidConfig          bool
 	ValidationErrorMessage *string
@@ -26,6 +39,30 @@ type IdentityProviderUris struct {
 	EndSessionURI    *string
 }
 
+// IdentityProvider holds the resolved configuration of a trusted identity provider.
+type IdentityProvider struct {
+	Name                 string
+	IdentityProviderUris IdentityProviderUris
+	Audiences            []string
+	Jwks                 *string
+	AcceptedResources    []string
+	ForwardJwt           *bool
+	OutputClaimToHeaders *[]ztoperatorv1alpha1.ClaimToHeader
+}
+
+// Gateway holds the resolved listeners of a Gateway targeted by an AuthPolicy.
+type Gateway struct {
+	Name      string
+	Waypoint  bool
+	Listeners []GatewayListener
+}
+
+// GatewayListener holds the port of a listener of a Gateway serving HTTP, and the hostname of HTTPS listeners.
+type GatewayListener struct {
+	Port     uint32
+	Hostname *string
+}
+
 type AutoLoginConfig struct {
 	Enabled               bool
 	LoginPath             *string
@@ -36,6 +73,8 @@ type AutoLoginConfig struct {
 	LoginParams           map[string]string
 	LuaScriptConfig       LuaScriptConfig
 	EnvoySecretName       string
+	// SignalHeaderSuffix ends the names of the headers by which the Lua filter signals the OAuth2 filter.
+	SignalHeaderSuffix string
 }
 
 type LuaScriptConfig struct {
@@ -54,6 +93,65 @@ type Descendant[T client.Object] struct {
 	SuccessMessage *string
 }
 
+// IsEnabled reports whether resources are generated for the AuthPolicy, which is not the case when the AuthPolicy is
+// disabled, or refused as it overlaps with an AuthPolicy taking precedence.
+func (s *Scope) IsEnabled() bool {
+	return s.AuthPolicy.Spec.Enabled && s.OverlappingAuthPolicy == nil
+}
+
+// IsExemptFromDefaultDeny reports whether requests to the workloads of the AuthPolicy are allowed despite the default
+// deny of a ClusterAuthPolicy, as the AuthPolicy does not enforce anything itself. This is the case in Audit mode, and
+// when the AuthPolicy is disabled and no applied AuthPolicy shares its pods. A refused AuthPolicy is never exempt, as
+// an exemption would allow all requests to the pods it shares with the AuthPolicy taking precedence.
+func (s *Scope) IsExemptFromDefaultDeny() bool {
+	if !s.DefaultDeny {
+		return false
+	}
+	if !s.AuthPolicy.Spec.Enabled {
+		return s.SharingAuthPolicy == nil
+	}
+	return s.IsEnabled() && s.AuthPolicy.IsAuditMode()
+}
+
+// GetConflictMessage returns the message reported for an AuthPolicy refused due to an overlapping AuthPolicy.
+func (s *Scope) GetConflictMessage() *string {
+	if s.OverlappingAuthPolicy == nil {
+		return nil
+	}
+	message := fmt.Sprintf(
+		"AuthPolicy selects the same pods as AuthPolicy %s, which takes precedence as it was created first. "+
+			"No resources are generated for it.",
+		*s.OverlappingAuthPolicy,
+	)
+	return &message
+}
+
+// GetUnreachableIdentityProviderMessage returns the message reported for an AuthPolicy referencing IdentityProviders
+// which were not reachable the last time they were checked, and whose last resolved endpoints are used instead.
+func (s *Scope) GetUnreachableIdentityProviderMessage() *string {
+	var messages []string
+	for _, name := range slices.Sorted(maps.Keys(s.IdentityProviderRefs)) {
+		identityProvider := s.IdentityProviderRefs[name]
+		if !identityProvider.HasStaleEndpoints() {
+			continue
+		}
+		message := fmt.Sprintf("IdentityProvider %s is not reachable", name)
+		reachableCondition := meta.FindStatusCondition(
+			identityProvider.Status.Conditions,
+			ztoperatorv1alpha1.IdentityProviderConditionReachable,
+		)
+		if reachableCondition != nil && reachableCondition.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, reachableCondition.Message)
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	message := strings.Join(messages, "; ") + ". The endpoints last resolved are used until it is reachable again."
+	return &message
+}
+
 func (s *Scope) GetErrors() []string {
 	var errs []string
 	if s != nil {
@@ -94,6 +192,60 @@ func (s *Scope) ReplaceDescendant(
 	}
 }
 
+// GetTrustedIdentityProviders returns all identity providers trusted by the AuthPolicy.
+// The identity provider defined by the top-level fields of the AuthPolicy spec is listed first, if present.
+func (s *Scope) GetTrustedIdentityProviders() []IdentityProvider {
+	var identityProviders []IdentityProvider
+	if s.AuthPolicy.HasDefaultIdentityProvider() {
+		var acceptedResources []string
+		if s.AuthPolicy.Spec.AcceptedResources != nil {
+			acceptedResources = *s.AuthPolicy.Spec.AcceptedResources
+		}
+		identityProviders = append(identityProviders, IdentityProvider{
+			Name:                 ztoperatorv1alpha1.DefaultIdentityProviderName,
+			IdentityProviderUris: s.IdentityProviderUris,
+			Audiences:            s.Audiences,
+			Jwks:                 s.Jwks,
+			AcceptedResources:    acceptedResources,
+			ForwardJwt:           s.AuthPolicy.Spec.ForwardJwt,
+			OutputClaimToHeaders: s.AuthPolicy.Spec.OutputClaimToHeaders,
+		})
+	}
+	return append(identityProviders, s.IdentityProviders...)
+}
+
+// GetIdentityProvidersForAuthRule returns the trusted identity providers accepted by the given auth rule.
+func (s *Scope) GetIdentityProvidersForAuthRule(authRule ztoperatorv1alpha1.RequestAuthRule) []IdentityProvider {
+	identityProviders := s.GetTrustedIdentityProviders()
+	if len(authRule.IdentityProviders) == 0 {
+		return identityProviders
+	}
+	var acceptedIdentityProviders []IdentityProvider
+	for _, identityProvider := range identityProviders {
+		if slices.Contains(authRule.IdentityProviders, identityProvider.Name) {
+			acceptedIdentityProviders = append(acceptedIdentityProviders, identityProvider)
+		}
+	}
+	return acceptedIdentityProviders
+}
+
+// GetIdentityProviderForEgressDestination returns the trusted identity provider issuing access tokens for the given
+// egress destination, or nil if it is not trusted.
+func (s *Scope) GetIdentityProviderForEgressDestination(
+	destination ztoperatorv1alpha1.EgressDestination,
+) *IdentityProvider {
+	identityProviderName := ztoperatorv1alpha1.DefaultIdentityProviderName
+	if destination.IdentityProvider != nil {
+		identityProviderName = *destination.IdentityProvider
+	}
+	for _, identityProvider := range s.GetTrustedIdentityProviders() {
+		if identityProvider.Name == identityProviderName {
+			return &identityProvider
+		}
+	}
+	return nil
+}
+
 func GetID(resourceKind, resourceName string) string {
 	return fmt.Sprintf("%s-%s", resourceKind, resourceName)
 }
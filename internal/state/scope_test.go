@@ -107,6 +107,51 @@ func TestSetSaneDefaults_PreservesExplicitPaths(t *testing.T) {
 	assert.Equal(t, logout, autoLoginConfig.LogoutPath)
 }
 
+func TestIsEnabled_WithOverlappingAuthPolicy_ReturnsFalse(t *testing.T) {
+	scope := state.Scope{
+		AuthPolicy: ztoperatorv1alpha1.AuthPolicy{Spec: ztoperatorv1alpha1.AuthPolicySpec{Enabled: true}},
+	}
+	assert.True(t, scope.IsEnabled())
+	assert.Nil(t, scope.GetConflictMessage())
+
+	scope.OverlappingAuthPolicy = helperfunctions.Ptr("older-policy")
+
+	assert.False(t, scope.IsEnabled())
+	require.NotNil(t, scope.GetConflictMessage())
+	assert.Contains(t, *scope.GetConflictMessage(), "older-policy")
+}
+
+func TestGetUnreachableIdentityProviderMessage_WithStaleIdentityProvider_ReturnsMessage(t *testing.T) {
+	reachableCondition := metav1.Condition{
+		Type:   ztoperatorv1alpha1.IdentityProviderConditionReachable,
+		Status: metav1.ConditionTrue,
+		Reason: "Reachable",
+	}
+	staleIdentityProvider := ztoperatorv1alpha1.IdentityProvider{
+		Status: ztoperatorv1alpha1.IdentityProviderStatus{
+			Conditions: []metav1.Condition{reachableCondition},
+			Issuer:     "https://idporten.no",
+			JwksURI:    "https://idporten.no/jwks",
+		},
+	}
+	scope := state.Scope{
+		IdentityProviderRefs: map[string]ztoperatorv1alpha1.IdentityProvider{
+			"idporten":     staleIdentityProvider,
+			"maskinporten": *staleIdentityProvider.DeepCopy(),
+		},
+	}
+	assert.Nil(t, scope.GetUnreachableIdentityProviderMessage())
+
+	staleIdentityProvider.Status.Conditions[0].Status = metav1.ConditionFalse
+	staleIdentityProvider.Status.Conditions[0].Message = "connection refused"
+	scope.IdentityProviderRefs["idporten"] = staleIdentityProvider
+
+	message := scope.GetUnreachableIdentityProviderMessage()
+	require.NotNil(t, message)
+	assert.Contains(t, *message, "IdentityProvider idporten is not reachable: connection refused")
+	assert.NotContains(t, *message, "maskinporten")
+}
+
 func newSecret(name string) *v1.Secret {
 	return &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
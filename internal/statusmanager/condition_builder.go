@@ -12,11 +12,29 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ConflictConditionType is the type of the condition reported for an AuthPolicy refused due to an overlapping
+// AuthPolicy.
+const ConflictConditionType = "Conflict"
+
+// DegradedConditionType is the type of the condition reported for an AuthPolicy in KeepLastKnownGood failure mode,
+// whose resources are kept at its last known good configuration.
+const DegradedConditionType = "Degraded"
+
+// IdentityProviderUnreachableConditionType is the type of the condition reported for an AuthPolicy referencing an
+// IdentityProvider which is not reachable, whose last resolved endpoints are used instead.
+const IdentityProviderUnreachableConditionType = "IdentityProviderUnreachable"
+
+// Reasons of the Degraded condition.
+const (
+	DegradedReasonInvalidConfiguration = "InvalidConfiguration"
+	DegradedReasonResolutionFailed     = "ResolutionFailed"
+)
+
 // BuildConditions builds all conditions for the AuthPolicy status.
 func BuildConditions(
 	authPolicy *ztoperatorv1alpha1.AuthPolicy,
 	reconciliationState ReconciliationState,
-	validationErrorMessage *string,
+	errorMessage *string,
 	descendants []state.Descendant[client.Object],
 	reconcileFuncs []reconciliation.ControllerResource,
 	existingConditions []metav1.Condition,
@@ -24,20 +42,24 @@ func BuildConditions(
 	authPolicyCondition := BuildAuthPolicyCondition(
 		authPolicy,
 		reconciliationState,
-		validationErrorMessage,
+		errorMessage,
 		existingConditions,
 	)
 	descendantConditions := BuildDescendantConditions(descendants, existingConditions)
 	missingResourceConditions := BuildMissingResourceConditions(descendants, reconcileFuncs, existingConditions)
 
-	return slices.Concat([]metav1.Condition{authPolicyCondition}, descendantConditions, missingResourceConditions)
+	conditions := []metav1.Condition{authPolicyCondition}
+	if reconciliationState == StateConflict {
+		conditions = append(conditions, BuildConflictCondition(*errorMessage, existingConditions))
+	}
+	return slices.Concat(conditions, descendantConditions, missingResourceConditions)
 }
 
 // BuildAuthPolicyCondition builds the AuthPolicy condition based on reconciliation state.
 func BuildAuthPolicyCondition(
 	authPolicy *ztoperatorv1alpha1.AuthPolicy,
 	reconciliationState ReconciliationState,
-	validationErrorMessage *string,
+	errorMessage *string,
 	existingConditions []metav1.Condition,
 ) metav1.Condition {
 	conditionType := state.GetID(strings.TrimPrefix(authPolicy.Kind, "*"), authPolicy.Name)
@@ -51,7 +73,12 @@ func BuildAuthPolicyCondition(
 	case StateInvalid:
 		condition.Status = metav1.ConditionFalse
 		condition.Reason = "InvalidConfiguration"
-		condition.Message = *validationErrorMessage
+		condition.Message = *errorMessage
+
+	case StateConflict:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ConflictConditionType
+		condition.Message = *errorMessage
 
 	case StatePending:
 		condition.Status = metav1.ConditionUnknown
@@ -80,6 +107,71 @@ func BuildAuthPolicyCondition(
 	return condition
 }
 
+// BuildConflictCondition builds the condition reported for an AuthPolicy refused due to an overlapping AuthPolicy.
+func BuildConflictCondition(message string, existingConditions []metav1.Condition) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               ConflictConditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             "OverlappingAuthPolicy",
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	// Preserve LastTransitionTime if the condition is unchanged
+	for _, existing := range existingConditions {
+		if isLogicallyEqualCondition(existing, condition) {
+			condition.LastTransitionTime = existing.LastTransitionTime
+			break
+		}
+	}
+
+	return condition
+}
+
+// BuildDegradedCondition builds the condition reported for an AuthPolicy whose resources are kept at its last known
+// good configuration.
+func BuildDegradedCondition(reason string, message string, existingConditions []metav1.Condition) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               DegradedConditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	// Preserve LastTransitionTime if the condition is unchanged
+	for _, existing := range existingConditions {
+		if isLogicallyEqualCondition(existing, condition) {
+			condition.LastTransitionTime = existing.LastTransitionTime
+			break
+		}
+	}
+
+	return condition
+}
+
+// BuildIdentityProviderUnreachableCondition builds the condition reported for an AuthPolicy referencing an
+// IdentityProvider which is not reachable.
+func BuildIdentityProviderUnreachableCondition(message string, existingConditions []metav1.Condition) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               IdentityProviderUnreachableConditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             "StaleEndpoints",
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	// Preserve LastTransitionTime if the condition is unchanged
+	for _, existing := range existingConditions {
+		if isLogicallyEqualCondition(existing, condition) {
+			condition.LastTransitionTime = existing.LastTransitionTime
+			break
+		}
+	}
+
+	return condition
+}
+
 // BuildDescendantConditions builds conditions for all descendants.
 func BuildDescendantConditions(
 	descendants []state.Descendant[client.Object],
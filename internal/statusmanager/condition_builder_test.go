@@ -2,6 +2,7 @@ package statusmanager_test
 
 import (
 	"testing"
+	"time"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/state"
@@ -557,6 +558,35 @@ func TestBuildConditions_IncludesAllConditionTypes(t *testing.T) {
 	assert.Equal(t, "NotFound", conditions[2].Reason)
 }
 
+func TestBuildConditions_WithConflictState_IncludesConflictCondition(t *testing.T) {
+	// 1. Arrange
+	authPolicy := createTestAuthPolicy()
+	conflictMessage := "AuthPolicy selects the same pods as AuthPolicy older-policy"
+
+	// 2. Act
+	conditions := statusmanager.BuildConditions(
+		authPolicy,
+		statusmanager.StateConflict,
+		&conflictMessage,
+		[]state.Descendant[client.Object]{},
+		[]reconciliation.ControllerResource{},
+		[]metav1.Condition{},
+	)
+
+	// 3. Assert
+	require.Len(t, conditions, 2, "Should have AuthPolicy + conflict condition")
+
+	assert.Equal(t, "AuthPolicy-test-policy", conditions[0].Type)
+	assert.Equal(t, metav1.ConditionFalse, conditions[0].Status)
+	assert.Equal(t, "Conflict", conditions[0].Reason)
+	assert.Equal(t, conflictMessage, conditions[0].Message)
+
+	assert.Equal(t, statusmanager.ConflictConditionType, conditions[1].Type)
+	assert.Equal(t, metav1.ConditionTrue, conditions[1].Status)
+	assert.Equal(t, "OverlappingAuthPolicy", conditions[1].Reason)
+	assert.Equal(t, conflictMessage, conditions[1].Message)
+}
+
 func createTestAuthPolicy() *ztoperatorv1alpha1.AuthPolicy {
 	return &ztoperatorv1alpha1.AuthPolicy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -568,3 +598,44 @@ func createTestAuthPolicy() *ztoperatorv1alpha1.AuthPolicy {
 		},
 	}
 }
+
+func TestBuildDegradedCondition_WithIdenticalExistingCondition_PreservesLastTransitionTime(t *testing.T) {
+	// 1. Arrange
+	oldTime := metav1.NewTime(metav1.Now().Add(-time.Hour))
+	message := "invalid path. Keeping the resources applied from generation 1."
+	existingConditions := []metav1.Condition{
+		{
+			Type:               statusmanager.DegradedConditionType,
+			Status:             metav1.ConditionTrue,
+			Reason:             statusmanager.DegradedReasonInvalidConfiguration,
+			Message:            message,
+			LastTransitionTime: oldTime,
+		},
+	}
+
+	// 2. Act
+	condition := statusmanager.BuildDegradedCondition(
+		statusmanager.DegradedReasonInvalidConfiguration,
+		message,
+		existingConditions,
+	)
+
+	// 3. Assert
+	assert.Equal(t, statusmanager.DegradedConditionType, condition.Type)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, oldTime, condition.LastTransitionTime)
+}
+
+func TestBuildIdentityProviderUnreachableCondition_ReturnsTrueCondition(t *testing.T) {
+	// 1. Arrange
+	message := "IdentityProvider idporten is not reachable: connection refused."
+
+	// 2. Act
+	condition := statusmanager.BuildIdentityProviderUnreachableCondition(message, []metav1.Condition{})
+
+	// 3. Assert
+	assert.Equal(t, statusmanager.IdentityProviderUnreachableConditionType, condition.Type)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+	assert.Equal(t, "StaleEndpoints", condition.Reason)
+	assert.Equal(t, message, condition.Message)
+}
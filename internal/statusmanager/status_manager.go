@@ -3,12 +3,15 @@ package statusmanager
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/state"
 	"github.com/kartverket/ztoperator/pkg/log"
 	"github.com/kartverket/ztoperator/pkg/reconciliation"
 	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -18,6 +21,7 @@ type ReconciliationState int
 // NB: used in switch cases, ensure they are exhaustive.
 const (
 	StateInvalid ReconciliationState = iota
+	StateConflict
 	StatePending
 	StateFailed
 	StateReady
@@ -44,19 +48,34 @@ func UpdateAuthPolicyStatus(
 		"Status update of AuthPolicy started.")
 
 	reconciliationState := DetermineReconciliationState(scope, controllerResources)
+	errorMessage := scope.ValidationErrorMessage
+	if reconciliationState == StateConflict {
+		errorMessage = scope.GetConflictMessage()
+	}
 
 	ap.Status.ObservedGeneration = ap.GetGeneration()
 	ap.Status.Phase = determinePhase(reconciliationState)
 	ap.Status.Ready = determineReadiness(reconciliationState)
-	ap.Status.Message = statusMessage(reconciliationState, scope.ValidationErrorMessage)
+	ap.Status.Message = statusMessage(reconciliationState, errorMessage)
+	ap.Status.EnforcementMode = ap.GetEnforcementMode()
+	ap.Status.ClusterAuthPolicies = scope.ClusterAuthPolicies
+	ap.Status.Conflicts = scope.RuleConflicts
+	ap.Status.EffectiveRules = effectiveRules(scope)
+	ap.Status.LastKnownGood = lastKnownGood(scope, reconciliationState, originalAuthPolicy.Status.LastKnownGood)
 	ap.Status.Conditions = BuildConditions(
 		ap,
 		reconciliationState,
-		scope.ValidationErrorMessage,
+		errorMessage,
 		scope.Descendants,
 		controllerResources,
 		originalAuthPolicy.Status.Conditions,
 	)
+	if unreachableMessage := scope.GetUnreachableIdentityProviderMessage(); unreachableMessage != nil {
+		ap.Status.Conditions = append(
+			ap.Status.Conditions,
+			BuildIdentityProviderUnreachableCondition(*unreachableMessage, originalAuthPolicy.Status.Conditions),
+		)
+	}
 
 	if !equality.Semantic.DeepEqual(originalAuthPolicy.Status, ap.Status) {
 		rLog.Debug(fmt.Sprintf("Updating AuthPolicy status with name %s/%s", ap.Namespace, ap.Name))
@@ -72,11 +91,108 @@ func UpdateAuthPolicyStatus(
 	}
 }
 
+// UpdateDegradedAuthPolicyStatus reports that the AuthPolicy is degraded, as its configuration is invalid or could not
+// be resolved, while the resources generated from its last known good configuration are kept untouched.
+// The conditions of the descendants are kept as they were, as the descendants are not reconciled.
+func UpdateDegradedAuthPolicyStatus(
+	ctx context.Context,
+	k8sClient client.Client,
+	recorder events.EventRecorder,
+	authPolicy *ztoperatorv1alpha1.AuthPolicy,
+	originalAuthPolicy *ztoperatorv1alpha1.AuthPolicy,
+	reason string,
+	errorMessage string,
+) error {
+	rLog := log.GetLogger(ctx)
+	message := fmt.Sprintf(
+		"%s. Keeping the resources applied from generation %d.",
+		strings.TrimSuffix(errorMessage, "."),
+		originalAuthPolicy.Status.LastKnownGood.ObservedGeneration,
+	)
+	recorder.Eventf(authPolicy, nil, "Warning", DegradedConditionType, "Reconcile", "%s", message)
+
+	authPolicy.Status.ObservedGeneration = authPolicy.GetGeneration()
+	authPolicy.Status.Phase = ztoperatorv1alpha1.PhaseDegraded
+	authPolicy.Status.Ready = false
+	authPolicy.Status.Message = message
+	authPolicy.Status.LastKnownGood = originalAuthPolicy.Status.LastKnownGood
+	authPolicy.Status.Conditions = append(
+		slices.DeleteFunc(slices.Clone(originalAuthPolicy.Status.Conditions), func(condition metav1.Condition) bool {
+			return condition.Type == DegradedConditionType
+		}),
+		BuildDegradedCondition(reason, message, originalAuthPolicy.Status.Conditions),
+	)
+
+	if equality.Semantic.DeepEqual(originalAuthPolicy.Status, authPolicy.Status) {
+		return nil
+	}
+	rLog.Debug(fmt.Sprintf("Updating degraded AuthPolicy status with name %s/%s", authPolicy.Namespace, authPolicy.Name))
+	return UpdateStatus(ctx, k8sClient, *authPolicy)
+}
+
+// lastKnownGood returns the configuration the resources of the AuthPolicy were last successfully applied from, which
+// is only tracked in KeepLastKnownGood failure mode. The configuration is recorded once the resources of an enabled
+// AuthPolicy are reconciled successfully, and forgotten when no resources are generated for the AuthPolicy.
+func lastKnownGood(
+	scope *state.Scope,
+	reconciliationState ReconciliationState,
+	previous *ztoperatorv1alpha1.LastKnownGood,
+) *ztoperatorv1alpha1.LastKnownGood {
+	if !scope.AuthPolicy.KeepsLastKnownGood() || !scope.IsEnabled() {
+		return nil
+	}
+	if reconciliationState != StateReady {
+		return previous
+	}
+
+	current := &ztoperatorv1alpha1.LastKnownGood{
+		ObservedGeneration: scope.AuthPolicy.GetGeneration(),
+		Issuer:             scope.IdentityProviderUris.IssuerURI,
+		JwksURI:            scope.IdentityProviderUris.JwksURI,
+		Audiences:          scope.Audiences,
+		AppliedAt:          metav1.Now(),
+	}
+	for _, identityProvider := range scope.IdentityProviders {
+		current.IdentityProviders = append(current.IdentityProviders, identityProvider.Name)
+	}
+	// Keep the time of the previous configuration if it is unchanged, so that the status is not updated on every
+	// reconcile.
+	if previous != nil {
+		current.AppliedAt = previous.AppliedAt
+		if equality.Semantic.DeepEqual(previous, current) {
+			return previous
+		}
+		current.AppliedAt = metav1.Now()
+	}
+	return current
+}
+
+// effectiveRules returns the rules of the AuthPolicy after merging ClusterAuthPolicies into it.
+// Rules are only reported when a ClusterAuthPolicy applies, and omitted for an invalid configuration
+// as the merged rules may then violate the limits of the CRD.
+func effectiveRules(scope *state.Scope) *ztoperatorv1alpha1.EffectiveRules {
+	if len(scope.ClusterAuthPolicies) == 0 || scope.InvalidConfig {
+		return nil
+	}
+	rules := &ztoperatorv1alpha1.EffectiveRules{
+		BaselineAuth: scope.AuthPolicy.Spec.BaselineAuth,
+	}
+	if scope.AuthPolicy.Spec.AuthRules != nil {
+		rules.AuthRules = *scope.AuthPolicy.Spec.AuthRules
+	}
+	if scope.AuthPolicy.Spec.IgnoreAuthRules != nil {
+		rules.IgnoreAuthRules = *scope.AuthPolicy.Spec.IgnoreAuthRules
+	}
+	return rules
+}
+
 func DetermineReconciliationState(
 	scope *state.Scope,
 	controllerResources []reconciliation.ControllerResource,
 ) ReconciliationState {
 	switch {
+	case scope.OverlappingAuthPolicy != nil:
+		return StateConflict
 	case scope.InvalidConfig:
 		return StateInvalid
 	case len(scope.Descendants) != reconciliation.CountNonNilResources(controllerResources):
@@ -92,6 +208,8 @@ func determinePhase(reconciliationState ReconciliationState) ztoperatorv1alpha1.
 	switch reconciliationState {
 	case StateInvalid:
 		return ztoperatorv1alpha1.PhaseInvalid
+	case StateConflict:
+		return ztoperatorv1alpha1.PhaseConflict
 	case StatePending:
 		return ztoperatorv1alpha1.PhasePending
 	case StateFailed:
@@ -104,7 +222,7 @@ func determinePhase(reconciliationState ReconciliationState) ztoperatorv1alpha1.
 
 func determineReadiness(reconciliationState ReconciliationState) bool {
 	switch reconciliationState {
-	case StateInvalid, StatePending, StateFailed:
+	case StateInvalid, StateConflict, StatePending, StateFailed:
 		return false
 	case StateReady:
 		return true
@@ -112,10 +230,10 @@ func determineReadiness(reconciliationState ReconciliationState) bool {
 	panic("could not determine readiness")
 }
 
-func statusMessage(reconciliationState ReconciliationState, validationErrorMessage *string) string {
+func statusMessage(reconciliationState ReconciliationState, errorMessage *string) string {
 	switch reconciliationState {
-	case StateInvalid:
-		return *validationErrorMessage
+	case StateInvalid, StateConflict:
+		return *errorMessage
 	case StatePending:
 		return "AuthPolicy pending due to missing Descendants."
 	case StateFailed:
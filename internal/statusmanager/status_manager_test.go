@@ -3,6 +3,7 @@ package statusmanager_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/state"
@@ -35,6 +36,23 @@ func TestDetermineReconciliationState_WithInvalidConfig_ReturnsStateInvalid(t *t
 	assert.Equal(t, statusmanager.StateInvalid, result)
 }
 
+func TestDetermineReconciliationState_WithOverlappingAuthPolicy_ReturnsStateConflict(t *testing.T) {
+	// 1. Arrange
+	scope := &state.Scope{
+		InvalidConfig:          true,
+		ValidationErrorMessage: helperfunctions.Ptr("Invalid configuration"),
+		OverlappingAuthPolicy:  helperfunctions.Ptr("older-policy"),
+		Descendants:            []state.Descendant[client.Object]{},
+	}
+	var controllerResources []reconciliation.ControllerResource
+
+	// 2. Act
+	result := statusmanager.DetermineReconciliationState(scope, controllerResources)
+
+	// 3. Assert
+	assert.Equal(t, statusmanager.StateConflict, result)
+}
+
 func TestDetermineReconciliationState_WithMissingDescendants_ReturnsStatePending(t *testing.T) {
 	// 1. Arrange
 	scope := &state.Scope{
@@ -324,6 +342,224 @@ func createTestAuthPolicyForStatusManager() *ztoperatorv1alpha1.AuthPolicy {
 			Ready:              true,
 			Message:            "AuthPolicy ready.",
 			ObservedGeneration: 1,
+			EnforcementMode:    ztoperatorv1alpha1.EnforcementModeEnforce,
+		},
+	}
+}
+
+func TestUpdateAuthPolicyStatus_InAuditMode_ReportsEnforcementMode(t *testing.T) {
+	// 1. Arrange
+	ctx := context.Background()
+	authPolicy := createTestAuthPolicyForStatusManager()
+	authPolicy.Spec.EnforcementMode = ztoperatorv1alpha1.EnforcementModeAudit
+	originalAuthPolicy := authPolicy.DeepCopy()
+
+	scheme := runtime.NewScheme()
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(authPolicy).
+		WithStatusSubresource(authPolicy).
+		Build()
+
+	scope := &state.Scope{
+		AuthPolicy:  *authPolicy,
+		Descendants: []state.Descendant[client.Object]{},
+	}
+
+	// 2. Act
+	statusmanager.UpdateAuthPolicyStatus(
+		ctx,
+		k8sClient,
+		events.NewFakeRecorder(10),
+		scope,
+		originalAuthPolicy,
+		[]reconciliation.ControllerResource{},
+	)
+
+	// 3. Assert
+	updated := &ztoperatorv1alpha1.AuthPolicy{}
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(authPolicy), updated))
+	assert.Equal(t, ztoperatorv1alpha1.EnforcementModeAudit, updated.Status.EnforcementMode)
+}
+
+func createReadyScopeForStatusManager(authPolicy *ztoperatorv1alpha1.AuthPolicy) *state.Scope {
+	return &state.Scope{
+		AuthPolicy: *authPolicy,
+		Audiences:  []string{"my-client"},
+		IdentityProviderUris: state.IdentityProviderUris{
+			IssuerURI: "http://test-idp.example.com",
+			JwksURI:   "http://test-idp.example.com/jwks",
+		},
+		Descendants: []state.Descendant[client.Object]{
+			{ID: "Secret-test", Object: &v1.Secret{}, SuccessMessage: helperfunctions.Ptr("Created")},
 		},
 	}
 }
+
+func TestUpdateAuthPolicyStatus_InKeepLastKnownGoodMode_RecordsLastKnownGood(t *testing.T) {
+	// 1. Arrange
+	ctx := context.Background()
+	authPolicy := createTestAuthPolicyForStatusManager()
+	authPolicy.Spec.Enabled = true
+	authPolicy.Spec.FailureMode = ztoperatorv1alpha1.FailureModeKeepLastKnownGood
+	originalAuthPolicy := authPolicy.DeepCopy()
+
+	scheme := runtime.NewScheme()
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(authPolicy).
+		WithStatusSubresource(authPolicy).
+		Build()
+
+	scope := createReadyScopeForStatusManager(authPolicy)
+	scope.IdentityProviders = []state.IdentityProvider{{Name: "partner"}}
+
+	// 2. Act
+	statusmanager.UpdateAuthPolicyStatus(
+		ctx,
+		k8sClient,
+		events.NewFakeRecorder(10),
+		scope,
+		originalAuthPolicy,
+		[]reconciliation.ControllerResource{createMockReconcileAction("Secret", "test", false)},
+	)
+
+	// 3. Assert
+	updated := &ztoperatorv1alpha1.AuthPolicy{}
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(authPolicy), updated))
+	require.NotNil(t, updated.Status.LastKnownGood)
+	assert.Equal(t, int64(1), updated.Status.LastKnownGood.ObservedGeneration)
+	assert.Equal(t, "http://test-idp.example.com", updated.Status.LastKnownGood.Issuer)
+	assert.Equal(t, "http://test-idp.example.com/jwks", updated.Status.LastKnownGood.JwksURI)
+	assert.Equal(t, []string{"my-client"}, updated.Status.LastKnownGood.Audiences)
+	assert.Equal(t, []string{"partner"}, updated.Status.LastKnownGood.IdentityProviders)
+	assert.False(t, updated.Status.LastKnownGood.AppliedAt.IsZero())
+}
+
+func TestUpdateAuthPolicyStatus_WithUnchangedLastKnownGood_KeepsAppliedAt(t *testing.T) {
+	// 1. Arrange
+	ctx := context.Background()
+	appliedAt := metav1.NewTime(metav1.Now().Add(-time.Hour).Truncate(time.Second))
+	authPolicy := createTestAuthPolicyForStatusManager()
+	authPolicy.Spec.Enabled = true
+	authPolicy.Spec.FailureMode = ztoperatorv1alpha1.FailureModeKeepLastKnownGood
+	authPolicy.Status.LastKnownGood = &ztoperatorv1alpha1.LastKnownGood{
+		ObservedGeneration: 1,
+		Issuer:             "http://test-idp.example.com",
+		JwksURI:            "http://test-idp.example.com/jwks",
+		Audiences:          []string{"my-client"},
+		AppliedAt:          appliedAt,
+	}
+	originalAuthPolicy := authPolicy.DeepCopy()
+
+	scheme := runtime.NewScheme()
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(authPolicy).
+		WithStatusSubresource(authPolicy).
+		Build()
+
+	// 2. Act
+	statusmanager.UpdateAuthPolicyStatus(
+		ctx,
+		k8sClient,
+		events.NewFakeRecorder(10),
+		createReadyScopeForStatusManager(authPolicy),
+		originalAuthPolicy,
+		[]reconciliation.ControllerResource{createMockReconcileAction("Secret", "test", false)},
+	)
+
+	// 3. Assert
+	updated := &ztoperatorv1alpha1.AuthPolicy{}
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(authPolicy), updated))
+	require.NotNil(t, updated.Status.LastKnownGood)
+	assert.True(t, appliedAt.Equal(&updated.Status.LastKnownGood.AppliedAt))
+}
+
+func TestUpdateAuthPolicyStatus_InFailClosedMode_ForgetsLastKnownGood(t *testing.T) {
+	// 1. Arrange
+	ctx := context.Background()
+	authPolicy := createTestAuthPolicyForStatusManager()
+	authPolicy.Spec.Enabled = true
+	authPolicy.Status.LastKnownGood = &ztoperatorv1alpha1.LastKnownGood{ObservedGeneration: 1}
+	originalAuthPolicy := authPolicy.DeepCopy()
+
+	scheme := runtime.NewScheme()
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(authPolicy).
+		WithStatusSubresource(authPolicy).
+		Build()
+
+	// 2. Act
+	statusmanager.UpdateAuthPolicyStatus(
+		ctx,
+		k8sClient,
+		events.NewFakeRecorder(10),
+		createReadyScopeForStatusManager(authPolicy),
+		originalAuthPolicy,
+		[]reconciliation.ControllerResource{createMockReconcileAction("Secret", "test", false)},
+	)
+
+	// 3. Assert
+	updated := &ztoperatorv1alpha1.AuthPolicy{}
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(authPolicy), updated))
+	assert.Nil(t, updated.Status.LastKnownGood)
+}
+
+func TestUpdateDegradedAuthPolicyStatus_KeepsLastKnownGoodAndDescendantConditions(t *testing.T) {
+	// 1. Arrange
+	ctx := context.Background()
+	authPolicy := createTestAuthPolicyForStatusManager()
+	authPolicy.Generation = 2
+	authPolicy.Spec.FailureMode = ztoperatorv1alpha1.FailureModeKeepLastKnownGood
+	authPolicy.Status.LastKnownGood = &ztoperatorv1alpha1.LastKnownGood{ObservedGeneration: 1}
+	descendantCondition := metav1.Condition{
+		Type:    "Secret-test",
+		Status:  metav1.ConditionTrue,
+		Reason:  "Success",
+		Message: "Created",
+	}
+	authPolicy.Status.Conditions = []metav1.Condition{descendantCondition}
+	originalAuthPolicy := authPolicy.DeepCopy()
+
+	scheme := runtime.NewScheme()
+	_ = ztoperatorv1alpha1.AddToScheme(scheme)
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(authPolicy).
+		WithStatusSubresource(authPolicy).
+		Build()
+	fakeRecorder := events.NewFakeRecorder(10)
+
+	// 2. Act
+	err := statusmanager.UpdateDegradedAuthPolicyStatus(
+		ctx,
+		k8sClient,
+		fakeRecorder,
+		authPolicy,
+		originalAuthPolicy,
+		statusmanager.DegradedReasonInvalidConfiguration,
+		"invalid path.",
+	)
+
+	// 3. Assert
+	require.NoError(t, err)
+	updated := &ztoperatorv1alpha1.AuthPolicy{}
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(authPolicy), updated))
+	assert.Equal(t, ztoperatorv1alpha1.PhaseDegraded, updated.Status.Phase)
+	assert.False(t, updated.Status.Ready)
+	assert.Equal(t, int64(2), updated.Status.ObservedGeneration)
+	assert.Equal(t, "invalid path. Keeping the resources applied from generation 1.", updated.Status.Message)
+	assert.Equal(t, originalAuthPolicy.Status.LastKnownGood, updated.Status.LastKnownGood)
+	require.Len(t, updated.Status.Conditions, 2)
+	assert.Equal(t, "Secret-test", updated.Status.Conditions[0].Type)
+	assert.Equal(t, statusmanager.DegradedConditionType, updated.Status.Conditions[1].Type)
+	assert.Equal(t, metav1.ConditionTrue, updated.Status.Conditions[1].Status)
+	assert.Equal(t, statusmanager.DegradedReasonInvalidConfiguration, updated.Status.Conditions[1].Reason)
+	assert.Contains(t, <-fakeRecorder.Events, "Warning Degraded")
+}
@@ -28,6 +28,10 @@ func (m *mockReconcileAction) IsResourceNil() bool {
 	return m.isNil
 }
 
+func (m *mockReconcileAction) GetDesiredResource() client.Object {
+	return nil
+}
+
 func (m *mockReconcileAction) Reconcile(
 	_ context.Context,
 	_ client.Client,
@@ -5,44 +5,52 @@ import (
 	"fmt"
 
 	"github.com/kartverket/ztoperator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// GetAuthPolicyForApplication fetches the single ready AuthPolicy for the given application.
+// GetAuthPolicyForPod fetches the single ready AuthPolicy whose selector matches the labels of the given Pod.
 // Returns an error if none, multiple, or an unready AuthPolicy is found.
-func GetAuthPolicyForApplication(
+func GetAuthPolicyForPod(
 	ctx context.Context,
 	k8sClient client.Client,
-	appKey client.ObjectKey,
+	pod *corev1.Pod,
 ) (*v1alpha1.AuthPolicy, error) {
+	podKey := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
 	var list v1alpha1.AuthPolicyList
-	podlog.Info("Fetching AuthPolicy resources", "namespacedName", appKey)
-	if err := k8sClient.List(ctx, &list, client.InNamespace(appKey.Namespace)); err != nil {
+	podlog.Info("Fetching AuthPolicy resources", "pod", podKey)
+	if err := k8sClient.List(ctx, &list, client.InNamespace(pod.Namespace)); err != nil {
 		return nil, fmt.Errorf("failed to fetch AuthPolicy resources: %w", err)
 	}
 
 	var matches []v1alpha1.AuthPolicy
 	for _, sc := range list.Items {
-		if sc.Spec.Selector.MatchLabels["app"] == appKey.Name {
+		if sc.Spec.Selector == nil {
+			// AuthPolicies applying to targetRefs do not select any pods by their labels
+			continue
+		}
+		if labels.SelectorFromSet(sc.Spec.Selector.MatchLabels).Matches(labels.Set(pod.Labels)) {
 			matches = append(matches, sc)
 		}
 	}
 
 	switch len(matches) {
 	case 0:
-		podlog.Info("No AuthPolicy found for Application", "namespacedName", appKey)
-		return nil, fmt.Errorf("no AuthPolicy resource was found for the corresponding Application")
+		podlog.Info("No AuthPolicy found selecting Pod", "pod", podKey)
+		return nil, fmt.Errorf("no AuthPolicy resource was found selecting the Pod")
 	case 1:
 		// expected
 	default:
-		podlog.Info("Multiple AuthPolicy found for Application", "namespacedName", appKey)
-		return nil, fmt.Errorf("multiple AuthPolicy resources found for Application")
+		podlog.Info("Multiple AuthPolicy found selecting Pod", "pod", podKey)
+		return nil, fmt.Errorf("multiple AuthPolicy resources found selecting the Pod")
 	}
 
 	sc := &matches[0]
 	if !sc.Status.Ready {
-		podlog.Info("AuthPolicy is not ready", "namespacedName", appKey)
-		return nil, fmt.Errorf("AuthPolicy resource for Application is not ready")
+		podlog.Info("AuthPolicy is not ready", "pod", podKey)
+		return nil, fmt.Errorf("AuthPolicy resource selecting the Pod is not ready")
 	}
 
 	return sc, nil
@@ -27,19 +27,23 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 		Expect(ztoperatorv1.AddToScheme(scheme)).To(Succeed())
 	})
 
-	Describe("GetAuthPolicyForApplication", func() {
-		It("errors when no AuthPolicy exists for the given application", func() {
-			cfg, err := v1.GetAuthPolicyForApplication(
+	newPod := func(podLabels map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns", Labels: podLabels}}
+	}
+
+	Describe("GetAuthPolicyForPod", func() {
+		It("errors when no AuthPolicy selects the Pod", func() {
+			cfg, err := v1.GetAuthPolicyForPod(
 				ctx,
 				k8sClient,
-				client.ObjectKey{Namespace: "ns", Name: "nonexistent-app"},
+				newPod(map[string]string{"app": "nonexistent-app"}),
 			)
-			Expect(err).To(MatchError(Equal("no AuthPolicy resource was found for the corresponding Application")))
+			Expect(err).To(MatchError(Equal("no AuthPolicy resource was found selecting the Pod")))
 			Expect(cfg).To(BeNil())
 		})
 
-		It("errors when multiple AuthPolicies exist for the given application", func() {
-			cfg, err := v1.GetAuthPolicyForApplication(
+		It("errors when multiple AuthPolicies select the Pod", func() {
+			cfg, err := v1.GetAuthPolicyForPod(
 				ctx,
 				GetMockKubernetesClient(
 					scheme,
@@ -50,7 +54,7 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 							Labels:    map[string]string{"app": "myapp"},
 						},
 						Spec: ztoperatorv1.AuthPolicySpec{
-							Selector: ztoperatorv1.WorkloadSelector{
+							Selector: &ztoperatorv1.WorkloadSelector{
 								MatchLabels: map[string]string{"app": "myapp"},
 							},
 						},
@@ -61,20 +65,20 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 							Namespace: "ns",
 						},
 						Spec: ztoperatorv1.AuthPolicySpec{
-							Selector: ztoperatorv1.WorkloadSelector{
+							Selector: &ztoperatorv1.WorkloadSelector{
 								MatchLabels: map[string]string{"app": "myapp"},
 							},
 						},
 					},
 				),
-				client.ObjectKey{Namespace: "ns", Name: "myapp"},
+				newPod(map[string]string{"app": "myapp", "pod-template-hash": "abc"}),
 			)
-			Expect(err).To(MatchError(Equal("multiple AuthPolicy resources found for Application")))
+			Expect(err).To(MatchError(Equal("multiple AuthPolicy resources found selecting the Pod")))
 			Expect(cfg).To(BeNil())
 		})
 
 		It("error when AuthPolicy is not ready", func() {
-			cfg, err := v1.GetAuthPolicyForApplication(
+			cfg, err := v1.GetAuthPolicyForPod(
 				ctx,
 				GetMockKubernetesClient(
 					scheme,
@@ -84,26 +88,54 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 							Namespace: "ns",
 						},
 						Spec: ztoperatorv1.AuthPolicySpec{
-							Selector: ztoperatorv1.WorkloadSelector{
+							Selector: &ztoperatorv1.WorkloadSelector{
 								MatchLabels: map[string]string{"app": "myapp"},
 							},
 						},
 					},
 				),
-				client.ObjectKey{Namespace: "ns", Name: "myapp"},
+				newPod(map[string]string{"app": "myapp", "pod-template-hash": "abc"}),
+			)
+			Expect(err).To(MatchError(Equal("AuthPolicy resource selecting the Pod is not ready")))
+			Expect(cfg).To(BeNil())
+		})
+
+		It("only matches AuthPolicies whose full selector matches the labels of the Pod", func() {
+			cfg, err := v1.GetAuthPolicyForPod(
+				ctx,
+				GetMockKubernetesClient(
+					scheme,
+					&ztoperatorv1.AuthPolicy{
+						ObjectMeta: metav1.ObjectMeta{Name: "auth-policy", Namespace: "ns"},
+						Spec: ztoperatorv1.AuthPolicySpec{
+							Selector: &ztoperatorv1.WorkloadSelector{
+								MatchLabels: map[string]string{"app": "myapp", "tier": "web"},
+							},
+						},
+					},
+					&ztoperatorv1.AuthPolicy{
+						ObjectMeta: metav1.ObjectMeta{Name: "gateway-auth-policy", Namespace: "ns"},
+						Spec: ztoperatorv1.AuthPolicySpec{
+							TargetRefs: []ztoperatorv1.PolicyTargetReference{
+								{Kind: ztoperatorv1.TargetRefKindGateway, Name: "gateway"},
+							},
+						},
+					},
+				),
+				newPod(map[string]string{"app": "myapp"}),
 			)
-			Expect(err).To(MatchError(Equal("AuthPolicy resource for Application is not ready")))
+			Expect(err).To(MatchError(Equal("no AuthPolicy resource was found selecting the Pod")))
 			Expect(cfg).To(BeNil())
 		})
 
-		It("returns the AuthPolicy when exactly one exists for the given application and it is ready", func() {
+		It("returns the AuthPolicy when exactly one selects the Pod and it is ready", func() {
 			expectedAuthPolicy := &ztoperatorv1.AuthPolicy{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "auth-policy",
 					Namespace: "ns",
 				},
 				Spec: ztoperatorv1.AuthPolicySpec{
-					Selector: ztoperatorv1.WorkloadSelector{
+					Selector: &ztoperatorv1.WorkloadSelector{
 						MatchLabels: map[string]string{"app": "myapp"},
 					},
 				},
@@ -114,10 +146,10 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 			expectedAuthPolicy.Status.Ready = true
 			Expect(mockClient.Update(ctx, expectedAuthPolicy)).To(Succeed())
 
-			cfg, err := v1.GetAuthPolicyForApplication(
+			cfg, err := v1.GetAuthPolicyForPod(
 				ctx,
 				mockClient,
-				client.ObjectKey{Namespace: "ns", Name: "myapp"},
+				newPod(map[string]string{"app": "myapp", "pod-template-hash": "abc"}),
 			)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(cfg).To(Equal(expectedAuthPolicy))
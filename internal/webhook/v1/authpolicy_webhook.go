@@ -0,0 +1,216 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/validation"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// nolint:unused
+var authpolicylog = logf.Log.WithName("authpolicy-webhook")
+
+// SetupAuthPolicyWebhookWithManager registers the webhook for AuthPolicy in the manager.
+func SetupAuthPolicyWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &v1alpha1.AuthPolicy{}).
+		WithValidator(&AuthPolicyCustomValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-ztoperator-kartverket-no-v1alpha1-authpolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=ztoperator.kartverket.no,resources=authpolicies,verbs=create;update,versions=v1alpha1,name=vauthpolicy-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// AuthPolicyCustomValidator is responsible for validating AuthPolicies on create and update.
+type AuthPolicyCustomValidator struct {
+	Client client.Client
+}
+
+var _ admission.Validator[*v1alpha1.AuthPolicy] = &AuthPolicyCustomValidator{}
+
+func (v *AuthPolicyCustomValidator) ValidateCreate(
+	ctx context.Context,
+	authPolicy *v1alpha1.AuthPolicy,
+) (admission.Warnings, error) {
+	if err := validateAuthPolicy(authPolicy); err != nil {
+		return nil, err
+	}
+	return checkReferences(ctx, v.Client, authPolicy), nil
+}
+
+// ValidateUpdate only checks the references of the AuthPolicy if they changed, so that an AuthPolicy whose referenced
+// resources are deleted can still be updated, e.g. when its labels are patched.
+func (v *AuthPolicyCustomValidator) ValidateUpdate(
+	ctx context.Context,
+	oldAuthPolicy, newAuthPolicy *v1alpha1.AuthPolicy,
+) (admission.Warnings, error) {
+	if err := validateAuthPolicy(newAuthPolicy); err != nil {
+		return nil, err
+	}
+	if slices.Equal(getReferences(oldAuthPolicy), getReferences(newAuthPolicy)) {
+		return nil, nil
+	}
+	return checkReferences(ctx, v.Client, newAuthPolicy), nil
+}
+
+func (v *AuthPolicyCustomValidator) ValidateDelete(
+	_ context.Context,
+	authPolicy *v1alpha1.AuthPolicy,
+) (admission.Warnings, error) {
+	authpolicylog.Info("Validation for AuthPolicy upon deletion", "name", authPolicy.GetName())
+	return nil, nil
+}
+
+// validateAuthPolicy runs the same validations as the AuthPolicy controller, so that invalid AuthPolicies are rejected
+// upon admission rather than resulting in a deny-all AuthorizationPolicy. It does no lookups in the cluster.
+func validateAuthPolicy(authPolicy *v1alpha1.AuthPolicy) error {
+	authpolicylog.Info("Validating for AuthPolicy", "namespace", authPolicy.GetNamespace(), "name", authPolicy.GetName())
+
+	var errs []error
+	for _, v := range validation.AuthPolicyValidations {
+		if err := v.Validate(*authPolicy); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", v.Description, err))
+		}
+	}
+	if authPolicy.Spec.Jwks != nil && authPolicy.Spec.Jwks.Value != nil {
+		if err := validation.ValidateJWKS(*authPolicy.Spec.Jwks.Value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid jwks: %w", err))
+		}
+	}
+	for _, identityProvider := range authPolicy.Spec.IdentityProviders {
+		if identityProvider.Jwks != nil && identityProvider.Jwks.Value != nil {
+			if err := validation.ValidateJWKS(*identityProvider.Jwks.Value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid identityProviders[%s].jwks: %w", identityProvider.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reference is a resource referenced by an AuthPolicy, along with the field referencing it.
+type reference struct {
+	field     string
+	kind      string
+	namespace string
+	name      string
+}
+
+// getReferences returns the Secrets, ConfigMaps and IdentityProviders referenced by the AuthPolicy.
+func getReferences(authPolicy *v1alpha1.AuthPolicy) []reference {
+	var references []reference
+	addValueFrom := func(field string, valueFrom *v1alpha1.ValueFrom) {
+		switch {
+		case valueFrom == nil:
+		case valueFrom.ConfigMapKeyRef != nil:
+			references = append(references, reference{
+				field:     field,
+				kind:      "ConfigMap",
+				namespace: authPolicy.Namespace,
+				name:      valueFrom.ConfigMapKeyRef.Name,
+			})
+		case valueFrom.SecretKeyRef != nil:
+			references = append(references, reference{
+				field:     field,
+				kind:      "Secret",
+				namespace: authPolicy.Namespace,
+				name:      valueFrom.SecretKeyRef.Name,
+			})
+		}
+	}
+	addTrustedIdentityProvider := func(
+		field string,
+		allowedAudiences []v1alpha1.AllowedAudience,
+		jwks *v1alpha1.Jwks,
+		identityProviderRef *string,
+	) {
+		for _, audience := range allowedAudiences {
+			addValueFrom(field+"allowedAudiences", audience.ValueFrom)
+		}
+		if jwks != nil {
+			addValueFrom(field+"jwks", jwks.ValueFrom)
+		}
+		if identityProviderRef != nil {
+			references = append(references, reference{
+				field: field + "identityProviderRef",
+				kind:  "IdentityProvider",
+				name:  *identityProviderRef,
+			})
+		}
+	}
+
+	addTrustedIdentityProvider(
+		"",
+		authPolicy.Spec.AllowedAudiences,
+		authPolicy.Spec.Jwks,
+		authPolicy.Spec.IdentityProviderRef,
+	)
+	for _, identityProvider := range authPolicy.Spec.IdentityProviders {
+		addTrustedIdentityProvider(
+			fmt.Sprintf("identityProviders[%s].", identityProvider.Name),
+			identityProvider.AllowedAudiences,
+			identityProvider.Jwks,
+			identityProvider.IdentityProviderRef,
+		)
+	}
+	if authPolicy.Spec.OAuthCredentials != nil && authPolicy.UsesOAuthCredentials() {
+		references = append(references, reference{
+			field:     "oAuthCredentials",
+			kind:      "Secret",
+			namespace: authPolicy.Namespace,
+			name:      authPolicy.Spec.OAuthCredentials.SecretRef,
+		})
+	}
+	return references
+}
+
+// checkReferences warns about resources referenced by the AuthPolicy which do not exist. They are not rejected, as
+// resources applied together, e.g. by GitOps tooling, may be created after the AuthPolicy, in which case the controller
+// applies the AuthPolicy once they exist.
+func checkReferences(ctx context.Context, k8sClient client.Client, authPolicy *v1alpha1.AuthPolicy) admission.Warnings {
+	if k8sClient == nil {
+		return admission.Warnings{"references were not checked, as the webhook client is not configured"}
+	}
+
+	var warnings admission.Warnings
+	for _, ref := range getReferences(authPolicy) {
+		var obj client.Object
+		switch ref.kind {
+		case "ConfigMap":
+			obj = &corev1.ConfigMap{}
+		case "Secret":
+			obj = &corev1.Secret{}
+		case "IdentityProvider":
+			obj = &v1alpha1.IdentityProvider{}
+		}
+		key := types.NamespacedName{Namespace: ref.namespace, Name: ref.name}
+		description := fmt.Sprintf("%s %s", ref.kind, key)
+		if ref.namespace == "" {
+			description = fmt.Sprintf("%s %s", ref.kind, ref.name)
+		}
+		err := k8sClient.Get(ctx, key, obj)
+		switch {
+		case apierrors.IsNotFound(err):
+			warnings = append(warnings, fmt.Sprintf(
+				"%s referenced by %s does not exist, the AuthPolicy is not applied until it is created",
+				description,
+				ref.field,
+			))
+		case err != nil:
+			warnings = append(warnings, fmt.Sprintf(
+				"%s referenced by %s could not be checked: %s",
+				description,
+				ref.field,
+				err,
+			))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,245 @@
+package v1_test
+
+import (
+	"context"
+
+	ztoperatorv1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	v1 "github.com/kartverket/ztoperator/internal/webhook/v1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var _ = Describe("authpolicy_webhook.go unit tests", func() {
+	var (
+		ctx    context.Context
+		scheme *runtime.Scheme
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme = runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		Expect(ztoperatorv1.AddToScheme(scheme)).To(Succeed())
+	})
+
+	validAuthPolicy := func() *ztoperatorv1.AuthPolicy {
+		return &ztoperatorv1.AuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "auth-policy", Namespace: "ns"},
+			Spec: ztoperatorv1.AuthPolicySpec{
+				Enabled:      true,
+				WellKnownURI: "https://idp.example.com/.well-known/openid-configuration",
+				Selector: &ztoperatorv1.WorkloadSelector{
+					MatchLabels: map[string]string{"app": "myapp"},
+				},
+				AuthRules: &[]ztoperatorv1.RequestAuthRule{
+					{RequestMatcher: ztoperatorv1.RequestMatcher{Paths: []string{"/api/{*}"}}},
+				},
+			},
+		}
+	}
+
+	Describe("ValidateCreate", func() {
+		It("accepts a valid AuthPolicy", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+
+			warnings, err := validator.ValidateCreate(ctx, validAuthPolicy())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("rejects invalid paths", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.IgnoreAuthRules = &[]ztoperatorv1.RequestMatcher{{Paths: []string{"/api/{**}/secure/{*}"}}}
+
+			_, err := validator.ValidateCreate(ctx, authPolicy)
+
+			Expect(err).To(MatchError(ContainSubstring("invalid paths: invalid or unsupported path /api/{**}/secure/{*}")))
+		})
+
+		It("rejects auto-login paths colliding with ignoreAuthRules", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme, oAuthSecret())}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.AutoLogin = &ztoperatorv1.AutoLogin{Enabled: true, LoginPath: helperfunctions.Ptr("/login")}
+			authPolicy.Spec.OAuthCredentials = oAuthCredentials()
+			authPolicy.Spec.IgnoreAuthRules = &[]ztoperatorv1.RequestMatcher{{Paths: []string{"/login"}}}
+
+			_, err := validator.ValidateCreate(ctx, authPolicy)
+
+			Expect(err).To(MatchError(ContainSubstring("autoLogin loginPath /login collides with ignoreAuthRules")))
+		})
+
+		It("warns about a missing OAuth credentials Secret", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.AutoLogin = &ztoperatorv1.AutoLogin{Enabled: true}
+			authPolicy.Spec.OAuthCredentials = oAuthCredentials()
+
+			warnings, err := validator.ValidateCreate(ctx, authPolicy)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(ConsistOf(
+				"Secret ns/oauth-secret referenced by oAuthCredentials does not exist, " +
+					"the AuthPolicy is not applied until it is created",
+			))
+		})
+
+		It("warns about audiences referencing a missing ConfigMap", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.AllowedAudiences = []ztoperatorv1.AllowedAudience{
+				{
+					ValueFrom: &ztoperatorv1.ValueFrom{
+						ConfigMapKeyRef: &ztoperatorv1.KeyRef{Name: "audience", Key: "AUDIENCE"},
+					},
+				},
+			}
+
+			warnings, err := validator.ValidateCreate(ctx, authPolicy)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("ConfigMap ns/audience referenced by allowedAudiences does not exist")))
+		})
+
+		It("does not resolve the JWKS of an existing ConfigMap", func() {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "jwks", Namespace: "ns"},
+				Data:       map[string]string{"jwks.json": `{"keys":[]}`},
+			}
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme, configMap)}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.Jwks = &ztoperatorv1.Jwks{
+				ValueFrom: &ztoperatorv1.ValueFrom{
+					ConfigMapKeyRef: &ztoperatorv1.KeyRef{Name: "jwks", Key: "jwks.json"},
+				},
+			}
+
+			warnings, err := validator.ValidateCreate(ctx, authPolicy)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("rejects an inline JWKS without keys", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.Jwks = &ztoperatorv1.Jwks{Value: helperfunctions.Ptr(`{"keys":[]}`)}
+
+			_, err := validator.ValidateCreate(ctx, authPolicy)
+
+			Expect(err).To(MatchError(ContainSubstring("invalid jwks: JWKS must contain at least one key")))
+		})
+
+		It("warns about a JWKS referencing a missing Secret for an additional identity provider", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.IdentityProviders = []ztoperatorv1.TrustedIdentityProvider{
+				{
+					Name:         "internal",
+					WellKnownURI: "https://internal.example.com/.well-known/openid-configuration",
+					Jwks: &ztoperatorv1.Jwks{
+						ValueFrom: &ztoperatorv1.ValueFrom{
+							SecretKeyRef: &ztoperatorv1.KeyRef{Name: "jwks", Key: "jwks.json"},
+						},
+					},
+				},
+			}
+
+			warnings, err := validator.ValidateCreate(ctx, authPolicy)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring(
+				"Secret ns/jwks referenced by identityProviders[internal].jwks does not exist",
+			)))
+		})
+
+		It("warns about a missing IdentityProvider", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.WellKnownURI = ""
+			authPolicy.Spec.IdentityProviderRef = helperfunctions.Ptr("idporten")
+
+			warnings, err := validator.ValidateCreate(ctx, authPolicy)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring(
+				"IdentityProvider idporten referenced by identityProviderRef does not exist",
+			)))
+		})
+
+		It("reports every failed validation", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.OutputClaimToHeaders = &[]ztoperatorv1.ClaimToHeader{
+				{Header: "x-ztoperator-bypass-login-abc", Claim: "sub"},
+				{Header: "X-Ztoperator-Bypass-Login-Abc", Claim: "oid"},
+			}
+
+			_, err := validator.ValidateCreate(ctx, authPolicy)
+
+			Expect(err).To(MatchError(ContainSubstring("outputClaimToHeaders contains duplicate header X-Ztoperator-Bypass-Login-Abc")))
+			Expect(err).To(MatchError(ContainSubstring("outputClaimToHeaders uses header x-ztoperator-bypass-login-abc")))
+		})
+	})
+
+	Describe("ValidateUpdate", func() {
+		It("validates the new AuthPolicy", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			authPolicy := validAuthPolicy()
+			authPolicy.Spec.FromHeaders = []ztoperatorv1.JWTHeader{{Name: "x-ztoperator-deny-redirect-abc"}}
+
+			_, err := validator.ValidateUpdate(ctx, validAuthPolicy(), authPolicy)
+
+			Expect(err).To(MatchError(ContainSubstring("fromHeaders uses header x-ztoperator-deny-redirect-abc")))
+		})
+
+		It("does not check unchanged references", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			oldAuthPolicy := validAuthPolicy()
+			oldAuthPolicy.Spec.AutoLogin = &ztoperatorv1.AutoLogin{Enabled: true}
+			oldAuthPolicy.Spec.OAuthCredentials = oAuthCredentials()
+			newAuthPolicy := oldAuthPolicy.DeepCopy()
+			newAuthPolicy.Labels = map[string]string{"team": "platform"}
+
+			warnings, err := validator.ValidateUpdate(ctx, oldAuthPolicy, newAuthPolicy)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("warns about changed references to missing resources", func() {
+			validator := &v1.AuthPolicyCustomValidator{Client: GetMockKubernetesClient(scheme)}
+			newAuthPolicy := validAuthPolicy()
+			newAuthPolicy.Spec.AutoLogin = &ztoperatorv1.AutoLogin{Enabled: true}
+			newAuthPolicy.Spec.OAuthCredentials = oAuthCredentials()
+
+			warnings, err := validator.ValidateUpdate(ctx, validAuthPolicy(), newAuthPolicy)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("Secret ns/oauth-secret referenced by oAuthCredentials does not exist")))
+		})
+	})
+})
+
+func oAuthCredentials() *ztoperatorv1.OAuthCredentials {
+	return &ztoperatorv1.OAuthCredentials{
+		SecretRef:       "oauth-secret",
+		ClientIDKey:     "CLIENT_ID",
+		ClientSecretKey: "CLIENT_SECRET",
+	}
+}
+
+func oAuthSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "oauth-secret", Namespace: "ns"},
+		Data: map[string][]byte{
+			"CLIENT_ID":     []byte("client-id"),
+			"CLIENT_SECRET": []byte("client-secret"),
+		},
+	}
+}
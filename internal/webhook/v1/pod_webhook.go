@@ -3,9 +3,13 @@ package v1
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/resolver"
+	"github.com/kartverket/ztoperator/pkg/config"
 	"github.com/kartverket/ztoperator/pkg/validation"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -17,11 +21,6 @@ import (
 )
 
 const (
-	CreatedBySkipNamespaceLabel      = "skip.kartverket.no/skip-managed"
-	CreatedBySkipNamespaceLabelValue = "true"
-
-	SkiperatorApplicationRefLabel = "application.skiperator.no/app-name"
-
 	ZtoperatorWebhookAnnotationPrefix = "ztoperator.kartverket.no/"
 	ZtoperatorVerifyAnnotationKey     = ZtoperatorWebhookAnnotationPrefix + "verify-authpolicy"
 	ZtoperatorVerifyAnnotationValue   = "true"
@@ -34,9 +33,61 @@ var podlog = logf.Log.WithName("pod-webhook")
 func SetupPodWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr, &corev1.Pod{}).
 		WithValidator(&PodCustomValidator{Client: mgr.GetClient()}).
+		WithDefaulter(&PodCustomDefaulter{Client: mgr.GetClient()}).
 		Complete()
 }
 
+// +kubebuilder:webhook:path=/mutate--v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod-v1.kb.io,admissionReviewVersions=v1,reinvocationPolicy=IfNeeded
+
+// PodCustomDefaulter is responsible for mounting the Secret generated for the AuthPolicy selecting a Pod into the
+// istio-proxy sidecar on create.
+type PodCustomDefaulter struct {
+	Client client.Client
+}
+
+var _ admission.Defaulter[*corev1.Pod] = &PodCustomDefaulter{}
+
+func (d *PodCustomDefaulter) Default(ctx context.Context, pod *corev1.Pod) error {
+	if d.Client == nil {
+		return fmt.Errorf("webhook client is not configured")
+	}
+
+	namespace := pod.Namespace
+	if namespace == "" {
+		// Pods created by controllers do not have their namespace set until after admission
+		if req, err := admission.RequestFromContext(ctx); err == nil {
+			namespace = req.Namespace
+		}
+	}
+
+	authPolicy, err := resolver.ResolveAuthPolicyForPod(ctx, d.Client, namespace, pod.Labels)
+	if err != nil {
+		return err
+	}
+	if authPolicy == nil {
+		return nil
+	}
+
+	mutated, err := validation.MutatePodAnnotations(pod, *authPolicy)
+	if err != nil {
+		return err
+	}
+	// The Istio sidecar injector usually runs before this webhook and is not reinvoked, so istio-proxy may already
+	// be rendered without the annotations. Mount the Secret in the injected sidecar as well.
+	if validation.MutateIstioProxyContainer(pod, *authPolicy) {
+		mutated = true
+	}
+	if mutated {
+		podlog.Info(
+			"Mounted Secret used by OAuth-EnvoyFilter in istio-proxy",
+			"namespace", namespace,
+			"pod", pod.GetName()+pod.GetGenerateName(),
+			"authPolicy", authPolicy.Name,
+		)
+	}
+	return nil
+}
+
 // +kubebuilder:webhook:path=/validate--v1-pod,mutating=false,failurePolicy=fail,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=vpod-v1.kb.io,admissionReviewVersions=v1
 
 // PodCustomValidator is responsible for validating Pods on create and update.
@@ -83,9 +134,9 @@ func validatePod(ctx context.Context, k8sClient client.Client, pod *corev1.Pod)
 	if err != nil {
 		return nil, err
 	}
-	if !podAuthPolicyConfiguration.CreatedFromSkiperatorApplication {
-		// Only validate Pods that are created from Skiperator Applications.
-		podlog.Info("Pod is not created from Skiperator Application, skipping validation", "pod", types.NamespacedName{
+	if !podAuthPolicyConfiguration.HasRequiredLabels {
+		// Only validate Pods with the labels required by the operator configuration.
+		podlog.Info("Pod does not have the required labels, skipping validation", "pod", types.NamespacedName{
 			Namespace: pod.Namespace,
 			Name:      pod.Name,
 		})
@@ -100,9 +151,8 @@ func validatePod(ctx context.Context, k8sClient client.Client, pod *corev1.Pod)
 // PodAuthPolicyConfiguration holds all resolved security context for a Pod,
 // used by both the mutating and validating webhooks.
 type PodAuthPolicyConfiguration struct {
-	AuthPolicy                       v1alpha1.AuthPolicy
-	AppName                          string
-	CreatedFromSkiperatorApplication bool
+	AuthPolicy        v1alpha1.AuthPolicy
+	HasRequiredLabels bool
 }
 
 // GetPodAuthPolicyConfiguration resolves the full security configuration for a Pod.
@@ -112,13 +162,8 @@ func GetPodAuthPolicyConfiguration(
 	k8sClient client.Client,
 	pod *corev1.Pod,
 ) (*PodAuthPolicyConfiguration, error) {
-	if pod.Labels == nil {
-		return &PodAuthPolicyConfiguration{CreatedFromSkiperatorApplication: false}, nil
-	}
-
-	appName, isSkiperatorPod := pod.Labels[SkiperatorApplicationRefLabel]
-	if !isSkiperatorPod {
-		return &PodAuthPolicyConfiguration{CreatedFromSkiperatorApplication: false}, nil
+	if missingLabel := getMissingPodLabel(*pod); missingLabel != nil {
+		return &PodAuthPolicyConfiguration{HasRequiredLabels: false}, nil
 	}
 
 	if k8sClient == nil {
@@ -129,35 +174,27 @@ func GetPodAuthPolicyConfiguration(
 
 	shouldFetchAuthPolicy := hasVerify && verifyAnnotation == ZtoperatorVerifyAnnotationValue
 	if !shouldFetchAuthPolicy {
-		return &PodAuthPolicyConfiguration{
-			AppName:                          appName,
-			CreatedFromSkiperatorApplication: true,
-		}, nil
+		return &PodAuthPolicyConfiguration{HasRequiredLabels: true}, nil
 	}
 
-	authPolicy, err := GetAuthPolicyForApplication(ctx, k8sClient, client.ObjectKey{
-		Namespace: pod.Namespace,
-		Name:      appName,
-	})
+	authPolicy, err := GetAuthPolicyForPod(ctx, k8sClient, pod)
 	if err != nil {
 		return nil, err
 	}
 
 	return &PodAuthPolicyConfiguration{
-		AuthPolicy:                       *authPolicy,
-		AppName:                          appName,
-		CreatedFromSkiperatorApplication: true,
+		AuthPolicy:        *authPolicy,
+		HasRequiredLabels: true,
 	}, nil
 }
 
+// IsWebhookEligible reports whether the Pod should be validated by the webhook, as configured by the
+// ZTOPERATOR_POD_WEBHOOK_POD_LABELS and ZTOPERATOR_POD_WEBHOOK_NAMESPACE_LABELS environment variables.
+// If not, the reason is returned.
 func IsWebhookEligible(ctx context.Context, k8sClient client.Client, pod corev1.Pod) (bool, string) {
-	// Verify that pod is created from skiperator app
-	if pod.Labels == nil {
-		return false, fmt.Sprintf("pod %s/%s has no labels", pod.Namespace, pod.Name)
-	}
-	_, isSkiperatorPod := pod.Labels[SkiperatorApplicationRefLabel]
-	if !isSkiperatorPod {
-		return false, fmt.Sprintf("pod %s/%s is not created from a Skiperator Application", pod.Namespace, pod.Name)
+	// Verify that pod has the required labels
+	if missingLabel := getMissingPodLabel(pod); missingLabel != nil {
+		return false, fmt.Sprintf("pod %s/%s does not have the label %s", pod.Namespace, pod.Name, *missingLabel)
 	}
 
 	// Verify that pod has annotations with the Ztoperator webhook annotation prefix
@@ -174,7 +211,11 @@ func IsWebhookEligible(ctx context.Context, k8sClient client.Client, pod corev1.
 		return false, fmt.Sprintf("pod %s/%s has no Ztoperator webhook annotations", pod.Namespace, pod.Name)
 	}
 
-	// Verify that the pod lies in a SKIP managed namespace
+	// Verify that the pod lies in a namespace with the required labels
+	namespaceLabels := config.Get().PodWebhookNamespaceLabels
+	if len(namespaceLabels) == 0 {
+		return true, ""
+	}
 	ns := &corev1.Namespace{}
 	if err := k8sClient.Get(ctx, client.ObjectKey{Name: pod.Namespace}, ns); err != nil {
 		if errors.IsNotFound(err) {
@@ -182,15 +223,28 @@ func IsWebhookEligible(ctx context.Context, k8sClient client.Client, pod corev1.
 		}
 		return false, fmt.Sprintf("failed to get namespace %s: %s", pod.Namespace, err.Error())
 	}
-	if ns.Labels == nil {
-		return false, fmt.Sprintf("namespace %s has no labels", pod.Namespace)
-	}
-	value, hasLabel := ns.Labels[CreatedBySkipNamespaceLabel]
-	if !hasLabel {
-		return false, fmt.Sprintf("namespace %s does not have the created by SKIP label", pod.Namespace)
-	}
-	if value != CreatedBySkipNamespaceLabelValue {
-		return false, fmt.Sprintf("namespace %s does have the created by SKIP label, but it's value is not %s", pod.Namespace, CreatedBySkipNamespaceLabelValue)
+	for _, label := range slices.Sorted(maps.Keys(namespaceLabels)) {
+		value, hasLabel := ns.Labels[label]
+		if !hasLabel {
+			return false, fmt.Sprintf("namespace %s does not have the label %s", pod.Namespace, label)
+		}
+		if value != namespaceLabels[label] {
+			return false, fmt.Sprintf(
+				"namespace %s does have the label %s, but its value is not %s",
+				pod.Namespace,
+				label,
+				namespaceLabels[label],
+			)
+		}
 	}
 	return true, ""
 }
+
+func getMissingPodLabel(pod corev1.Pod) *string {
+	for _, label := range config.Get().PodWebhookPodLabels {
+		if _, hasLabel := pod.Labels[label]; !hasLabel {
+			return &label
+		}
+	}
+	return nil
+}
@@ -3,10 +3,13 @@ package v1_test
 import (
 	"context"
 	"errors"
+	"os"
 
 	ztoperatorv1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	v1 "github.com/kartverket/ztoperator/internal/webhook/v1"
+	"github.com/kartverket/ztoperator/pkg/config"
 	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/validation"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
@@ -45,12 +48,62 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 		})
 	})
 
+	Describe("PodCustomDefaulter", func() {
+		newAuthPolicy := func(name string, matchLabels map[string]string) *ztoperatorv1.AuthPolicy {
+			return &ztoperatorv1.AuthPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+				Spec: ztoperatorv1.AuthPolicySpec{
+					Enabled:   true,
+					Selector:  &ztoperatorv1.WorkloadSelector{MatchLabels: matchLabels},
+					AutoLogin: &ztoperatorv1.AutoLogin{Enabled: true},
+				},
+			}
+		}
+
+		It("returns error when k8sClient is nil", func() {
+			defaulter := &v1.PodCustomDefaulter{}
+			Expect(defaulter.Default(ctx, &corev1.Pod{})).To(MatchError(Equal("webhook client is not configured")))
+		})
+
+		It("does not mutate Pods not selected by any AuthPolicy", func() {
+			defaulter := &v1.PodCustomDefaulter{
+				Client: helperfunctions.GetMockKubernetesClient(scheme, newAuthPolicy("auth-policy", map[string]string{"app": "other"})),
+			}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns", Labels: map[string]string{"app": "app"}}}
+
+			Expect(defaulter.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Annotations).To(BeNil())
+		})
+
+		It("mounts the Secret of the AuthPolicy selecting a Pod not created from a Skiperator Application", func() {
+			authPolicy := newAuthPolicy("auth-policy", map[string]string{"app": "app"})
+			defaulter := &v1.PodCustomDefaulter{Client: helperfunctions.GetMockKubernetesClient(scheme, authPolicy)}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "pod",
+					Namespace:   "ns",
+					Labels:      map[string]string{"app": "app"},
+					Annotations: map[string]string{"sidecar.istio.io/userVolume": `[{"name":"certs","configMap":{"name":"ca"}}]`},
+				},
+			}
+
+			Expect(defaulter.Default(ctx, pod)).To(Succeed())
+			Expect(pod.Annotations["sidecar.istio.io/userVolume"]).To(MatchJSON(
+				`[{"name":"certs","configMap":{"name":"ca"}},{"name":"ztoperator-envoy-secret","secret":{"secretName":"auth-policy-envoy-secret"}}]`,
+			))
+			Expect(pod.Annotations["sidecar.istio.io/userVolumeMount"]).To(MatchJSON(
+				`[{"name":"ztoperator-envoy-secret","mountPath":"/etc/istio/config","readonly":true}]`,
+			))
+			Expect(validation.ValidatePodAnnotations(pod, *authPolicy)).To(Succeed())
+		})
+	})
+
 	Describe("GetPodAuthPolicyConfiguration", func() {
-		It("returns CreatedFromSkiperatorApplication=false when Pod is not created from Skiperator Application", func() {
+		It("returns HasRequiredLabels=false when Pod does not have the required labels", func() {
 			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: skiperatorAppName, Namespace: "ns"}}
 			cfg, err := v1.GetPodAuthPolicyConfiguration(ctx, nil, pod)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(*cfg).To(Equal(v1.PodAuthPolicyConfiguration{CreatedFromSkiperatorApplication: false}))
+			Expect(*cfg).To(Equal(v1.PodAuthPolicyConfiguration{HasRequiredLabels: false}))
 		})
 
 		It("returns error when Pod is created from Skiperator Application, but k8sClient is nil", func() {
@@ -59,7 +112,8 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 					Name:      skiperatorAppName,
 					Namespace: "ns",
 					Labels: map[string]string{
-						v1.SkiperatorApplicationRefLabel: skiperatorAppName,
+						skiperatorApplicationRefLabel: skiperatorAppName,
+						"app":                         skiperatorAppName,
 					},
 				},
 			}
@@ -68,14 +122,15 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 			Expect(cfg).To(BeNil())
 		})
 
-		It("returns PodAuthPolicyConfiguration with only AppName and CreatedFromSkiperatorApplication=true when pod is NOT annotated to verify nor to have any services", func() {
+		It("returns PodAuthPolicyConfiguration with only HasRequiredLabels=true when pod is NOT annotated to verify", func() {
 			skiperatorAppName := skiperatorAppName
 			pod := &corev1.Pod{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      skiperatorAppName,
 					Namespace: "ns",
 					Labels: map[string]string{
-						v1.SkiperatorApplicationRefLabel: skiperatorAppName,
+						skiperatorApplicationRefLabel: skiperatorAppName,
+						"app":                         skiperatorAppName,
 					},
 				},
 			}
@@ -83,8 +138,7 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(*cfg).To(Equal(
 				v1.PodAuthPolicyConfiguration{
-					AppName:                          skiperatorAppName,
-					CreatedFromSkiperatorApplication: true,
+					HasRequiredLabels: true,
 				},
 			))
 		})
@@ -96,7 +150,8 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 					Name:      skiperatorAppName,
 					Namespace: "ns",
 					Labels: map[string]string{
-						v1.SkiperatorApplicationRefLabel: skiperatorAppName,
+						skiperatorApplicationRefLabel: skiperatorAppName,
+						"app":                         skiperatorAppName,
 					},
 					Annotations: map[string]string{
 						v1.ZtoperatorVerifyAnnotationKey: v1.ZtoperatorVerifyAnnotationValue,
@@ -108,7 +163,7 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 				GetMockKubernetesClient(scheme),
 				pod,
 			)
-			Expect(err).To(MatchError(Equal("no AuthPolicy resource was found for the corresponding Application")))
+			Expect(err).To(MatchError(Equal("no AuthPolicy resource was found selecting the Pod")))
 			Expect(cfg).To(BeNil())
 		})
 
@@ -119,7 +174,8 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 					Name:      skiperatorAppName,
 					Namespace: "ns",
 					Labels: map[string]string{
-						v1.SkiperatorApplicationRefLabel: skiperatorAppName,
+						skiperatorApplicationRefLabel: skiperatorAppName,
+						"app":                         skiperatorAppName,
 					},
 					Annotations: map[string]string{
 						v1.ZtoperatorVerifyAnnotationKey: v1.ZtoperatorVerifyAnnotationValue,
@@ -136,7 +192,7 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 							Namespace: pod.Namespace,
 						},
 						Spec: ztoperatorv1.AuthPolicySpec{
-							Selector: ztoperatorv1.WorkloadSelector{
+							Selector: &ztoperatorv1.WorkloadSelector{
 								MatchLabels: map[string]string{"app": skiperatorAppName},
 							},
 						},
@@ -147,7 +203,7 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 							Namespace: pod.Namespace,
 						},
 						Spec: ztoperatorv1.AuthPolicySpec{
-							Selector: ztoperatorv1.WorkloadSelector{
+							Selector: &ztoperatorv1.WorkloadSelector{
 								MatchLabels: map[string]string{"app": skiperatorAppName},
 							},
 						},
@@ -155,7 +211,7 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 				),
 				pod,
 			)
-			Expect(err).To(MatchError(Equal("multiple AuthPolicy resources found for Application")))
+			Expect(err).To(MatchError(Equal("multiple AuthPolicy resources found selecting the Pod")))
 			Expect(cfg).To(BeNil())
 		})
 
@@ -166,7 +222,8 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 					Name:      skiperatorAppName,
 					Namespace: "ns",
 					Labels: map[string]string{
-						v1.SkiperatorApplicationRefLabel: skiperatorAppName,
+						skiperatorApplicationRefLabel: skiperatorAppName,
+						"app":                         skiperatorAppName,
 					},
 					Annotations: map[string]string{
 						v1.ZtoperatorVerifyAnnotationKey: v1.ZtoperatorVerifyAnnotationValue,
@@ -180,7 +237,7 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 					Namespace: pod.Namespace,
 				},
 				Spec: ztoperatorv1.AuthPolicySpec{
-					Selector: ztoperatorv1.WorkloadSelector{
+					Selector: &ztoperatorv1.WorkloadSelector{
 						MatchLabels: map[string]string{"app": skiperatorAppName},
 					},
 				},
@@ -201,9 +258,8 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(*cfg).To(Equal(
 				v1.PodAuthPolicyConfiguration{
-					AuthPolicy:                       AuthPolicy,
-					AppName:                          skiperatorAppName,
-					CreatedFromSkiperatorApplication: true,
+					AuthPolicy:        AuthPolicy,
+					HasRequiredLabels: true,
 				},
 			))
 		})
@@ -216,7 +272,7 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 					Name:      "p",
 					Namespace: "ns",
 					Labels: map[string]string{
-						v1.SkiperatorApplicationRefLabel: "app",
+						skiperatorApplicationRefLabel: "app",
 					},
 					Annotations: map[string]string{
 						v1.ZtoperatorVerifyAnnotationKey: v1.ZtoperatorVerifyAnnotationValue,
@@ -241,17 +297,17 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 			eligible, msg := v1.IsWebhookEligible(ctx, helperfunctions.GetMockKubernetesClient(scheme), pod)
 
 			Expect(eligible).To(BeFalse())
-			Expect(msg).To(Equal("pod ns/p has no labels"))
+			Expect(msg).To(Equal("pod ns/p does not have the label application.skiperator.no/app-name"))
 		})
 
-		It("returns false when pod is not created from a Skiperator Application", func() {
+		It("returns false when pod does not have the required labels", func() {
 			pod := newPod()
-			delete(pod.Labels, v1.SkiperatorApplicationRefLabel)
+			delete(pod.Labels, skiperatorApplicationRefLabel)
 
 			eligible, msg := v1.IsWebhookEligible(ctx, helperfunctions.GetMockKubernetesClient(scheme), pod)
 
 			Expect(eligible).To(BeFalse())
-			Expect(msg).To(Equal("pod ns/p is not created from a Skiperator Application"))
+			Expect(msg).To(Equal("pod ns/p does not have the label application.skiperator.no/app-name"))
 		})
 
 		It("returns false when pod has no annotations", func() {
@@ -303,34 +359,61 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 			eligible, msg := v1.IsWebhookEligible(ctx, mockClient, pod)
 
 			Expect(eligible).To(BeFalse())
-			Expect(msg).To(Equal("namespace ns has no labels"))
+			Expect(msg).To(Equal("namespace ns does not have the label skip.kartverket.no/skip-managed"))
 		})
 
-		It("returns false when namespace does not have the created by SKIP label", func() {
+		It("returns false when namespace does not have the required label", func() {
 			pod := newPod()
 			mockClient := helperfunctions.GetMockKubernetesClient(scheme, newNamespace(map[string]string{"other": "label"}))
 
 			eligible, msg := v1.IsWebhookEligible(ctx, mockClient, pod)
 
 			Expect(eligible).To(BeFalse())
-			Expect(msg).To(Equal("namespace ns does not have the created by SKIP label"))
+			Expect(msg).To(Equal("namespace ns does not have the label skip.kartverket.no/skip-managed"))
 		})
 
-		It("returns false when created by SKIP label has wrong value", func() {
+		It("returns false when the required namespace label has wrong value", func() {
 			pod := newPod()
-			mockClient := helperfunctions.GetMockKubernetesClient(scheme, newNamespace(map[string]string{v1.CreatedBySkipNamespaceLabel: "false"}))
+			mockClient := helperfunctions.GetMockKubernetesClient(scheme, newNamespace(map[string]string{skipManagedNamespaceLabel: "false"}))
 
 			eligible, msg := v1.IsWebhookEligible(ctx, mockClient, pod)
 
 			Expect(eligible).To(BeFalse())
-			Expect(msg).To(Equal("namespace ns does have the created by SKIP label, but it's value is not true"))
+			Expect(msg).To(Equal("namespace ns does have the label skip.kartverket.no/skip-managed, but its value is not true"))
+		})
+
+		It("returns true for pods without labels in namespaces without labels when no labels are required", func() {
+			setPodWebhookLabels("", "")
+			pod := newPod()
+			pod.Labels = nil
+
+			eligible, msg := v1.IsWebhookEligible(ctx, helperfunctions.GetMockKubernetesClient(scheme), pod)
+
+			Expect(eligible).To(BeTrue())
+			Expect(msg).To(BeEmpty())
+		})
+
+		It("uses the pod and namespace labels configured for the operator", func() {
+			setPodWebhookLabels("app.kubernetes.io/name", "team:platform")
+			pod := newPod()
+			pod.Labels = map[string]string{"app.kubernetes.io/name": "my-chart"}
+			mockClient := helperfunctions.GetMockKubernetesClient(scheme, newNamespace(map[string]string{"team": "platform"}))
+
+			eligible, msg := v1.IsWebhookEligible(ctx, mockClient, pod)
+			Expect(eligible).To(BeTrue())
+			Expect(msg).To(BeEmpty())
+
+			delete(pod.Labels, "app.kubernetes.io/name")
+			eligible, msg = v1.IsWebhookEligible(ctx, mockClient, pod)
+			Expect(eligible).To(BeFalse())
+			Expect(msg).To(Equal("pod ns/p does not have the label app.kubernetes.io/name"))
 		})
 
 		It("returns true when pod and namespace satisfy all webhook eligibility requirements", func() {
 			pod := newPod()
 			mockClient := helperfunctions.GetMockKubernetesClient(
 				scheme,
-				newNamespace(map[string]string{v1.CreatedBySkipNamespaceLabel: v1.CreatedBySkipNamespaceLabelValue}),
+				newNamespace(map[string]string{skipManagedNamespaceLabel: "true"}),
 			)
 
 			eligible, msg := v1.IsWebhookEligible(ctx, mockClient, pod)
@@ -340,3 +423,15 @@ var _ = Describe("pod_webhook.go unit tests", func() {
 		})
 	})
 })
+
+// setPodWebhookLabels configures the labels required by the pod webhook for the duration of the current spec.
+func setPodWebhookLabels(podLabels string, namespaceLabels string) {
+	Expect(os.Setenv("ZTOPERATOR_POD_WEBHOOK_POD_LABELS", podLabels)).To(Succeed())
+	Expect(os.Setenv("ZTOPERATOR_POD_WEBHOOK_NAMESPACE_LABELS", namespaceLabels)).To(Succeed())
+	Expect(config.Load()).To(Succeed())
+	DeferCleanup(func() {
+		Expect(os.Unsetenv("ZTOPERATOR_POD_WEBHOOK_POD_LABELS")).To(Succeed())
+		Expect(os.Unsetenv("ZTOPERATOR_POD_WEBHOOK_NAMESPACE_LABELS")).To(Succeed())
+		Expect(config.Load()).To(Succeed())
+	})
+}
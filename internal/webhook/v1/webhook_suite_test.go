@@ -37,6 +37,10 @@ import (
 const (
 	authPolicyName    = "auth-policy"
 	skiperatorAppName = "skiperator-app"
+
+	// The labels required by the pod webhook by default
+	skiperatorApplicationRefLabel = "application.skiperator.no/app-name"
+	skipManagedNamespaceLabel     = "skip.kartverket.no/skip-managed"
 )
 
 var (
@@ -121,6 +125,9 @@ var _ = BeforeSuite(func() {
 	err = v1.SetupPodWebhookWithManager(mgr)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = v1.SetupAuthPolicyWebhookWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
+
 	// +kubebuilder:scaffold:webhook
 
 	go func() {
@@ -200,7 +207,7 @@ func getWebhookNamespace(name string, webhookEnabled bool) *corev1.Namespace {
 		},
 	}
 	if webhookEnabled {
-		ns.Labels[v1.CreatedBySkipNamespaceLabel] = v1.CreatedBySkipNamespaceLabelValue
+		ns.Labels[skipManagedNamespaceLabel] = "true"
 	}
 	return ns
 }
@@ -230,7 +237,8 @@ var _ = Describe("Pod validating webhook", func() {
 		if pod.Labels == nil {
 			pod.Labels = make(map[string]string)
 		}
-		pod.Labels[v1.SkiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels[skiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels["app"] = skiperatorAppName
 
 		Expect(k8sClient.Create(ctx, pod)).To(Succeed())
 	})
@@ -248,7 +256,7 @@ var _ = Describe("Pod validating webhook", func() {
 				Namespace: ns.GetName(),
 			},
 			Spec: ztoperatorv1.AuthPolicySpec{
-				Selector: ztoperatorv1.WorkloadSelector{
+				Selector: &ztoperatorv1.WorkloadSelector{
 					MatchLabels: map[string]string{"app": skiperatorAppName},
 				},
 			},
@@ -275,7 +283,8 @@ var _ = Describe("Pod validating webhook", func() {
 		if pod.Labels == nil {
 			pod.Labels = make(map[string]string)
 		}
-		pod.Labels[v1.SkiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels[skiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels["app"] = skiperatorAppName
 
 		Expect(k8sClient.Create(ctx, pod)).To(Succeed())
 	})
@@ -304,9 +313,10 @@ var _ = Describe("Pod validating webhook", func() {
 		if pod.Labels == nil {
 			pod.Labels = make(map[string]string)
 		}
-		pod.Labels[v1.SkiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels[skiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels["app"] = skiperatorAppName
 
-		Expect(k8sClient.Create(ctx, pod)).To(MatchError(ContainSubstring("no AuthPolicy resource was found for the corresponding Application")))
+		Expect(k8sClient.Create(ctx, pod)).To(MatchError(ContainSubstring("no AuthPolicy resource was found selecting the Pod")))
 	})
 
 	It("creates when pod is annotated correctly and authpolicy exists", func() {
@@ -322,7 +332,7 @@ var _ = Describe("Pod validating webhook", func() {
 				Namespace: ns.GetName(),
 			},
 			Spec: ztoperatorv1.AuthPolicySpec{
-				Selector: ztoperatorv1.WorkloadSelector{
+				Selector: &ztoperatorv1.WorkloadSelector{
 					MatchLabels: map[string]string{"app": skiperatorAppName},
 				},
 			},
@@ -349,7 +359,8 @@ var _ = Describe("Pod validating webhook", func() {
 		if pod.Labels == nil {
 			pod.Labels = make(map[string]string)
 		}
-		pod.Labels[v1.SkiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels[skiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels["app"] = skiperatorAppName
 
 		Expect(k8sClient.Create(ctx, pod)).To(Succeed())
 	})
@@ -367,7 +378,7 @@ var _ = Describe("Pod validating webhook", func() {
 				Namespace: ns.GetName(),
 			},
 			Spec: ztoperatorv1.AuthPolicySpec{
-				Selector: ztoperatorv1.WorkloadSelector{
+				Selector: &ztoperatorv1.WorkloadSelector{
 					MatchLabels: map[string]string{"app": skiperatorAppName + "not"},
 				},
 			},
@@ -394,9 +405,10 @@ var _ = Describe("Pod validating webhook", func() {
 		if pod.Labels == nil {
 			pod.Labels = make(map[string]string)
 		}
-		pod.Labels[v1.SkiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels[skiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels["app"] = skiperatorAppName
 
-		Expect(k8sClient.Create(ctx, pod)).To(MatchError(ContainSubstring("no AuthPolicy resource was found for the corresponding Application")))
+		Expect(k8sClient.Create(ctx, pod)).To(MatchError(ContainSubstring("no AuthPolicy resource was found selecting the Pod")))
 	})
 
 	It("does not create when pod is missing annotations (authPolicy has enabled autoLogin)", func() {
@@ -412,7 +424,7 @@ var _ = Describe("Pod validating webhook", func() {
 				Namespace: ns.GetName(),
 			},
 			Spec: ztoperatorv1.AuthPolicySpec{
-				Selector: ztoperatorv1.WorkloadSelector{
+				Selector: &ztoperatorv1.WorkloadSelector{
 					MatchLabels: map[string]string{"app": skiperatorAppName},
 				},
 				AutoLogin: &ztoperatorv1.AutoLogin{
@@ -435,7 +447,7 @@ var _ = Describe("Pod validating webhook", func() {
 				Name:      "pod-webhook-create",
 				Namespace: ns.Name,
 				Labels: map[string]string{
-					v1.SkiperatorApplicationRefLabel: skiperatorAppName,
+					skiperatorApplicationRefLabel: skiperatorAppName,
 				},
 				Annotations: map[string]string{
 					v1.ZtoperatorVerifyAnnotationKey: v1.ZtoperatorVerifyAnnotationValue,
@@ -451,7 +463,8 @@ var _ = Describe("Pod validating webhook", func() {
 		if pod.Labels == nil {
 			pod.Labels = make(map[string]string)
 		}
-		pod.Labels[v1.SkiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels[skiperatorApplicationRefLabel] = skiperatorAppName
+		pod.Labels["app"] = skiperatorAppName
 
 		Expect(k8sClient.Create(ctx, pod)).To(MatchError(ContainSubstring(
 			fmt.Sprintf(
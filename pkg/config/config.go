@@ -1,11 +1,28 @@
 package config
 
 import (
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
 )
 
 type Config struct {
 	GitRef string `split_words:"true" default:"main"`
+	// DiscoveryDocumentCacheTTL is how long a discovery document is served from the cache before it is revalidated
+	// against the identity provider.
+	DiscoveryDocumentCacheTTL time.Duration `split_words:"true" default:"5m"`
+	// IdentityProviderHealthCheckInterval is how often the discovery document and JWKS of an IdentityProvider are
+	// fetched to report whether it is reachable.
+	IdentityProviderHealthCheckInterval time.Duration `split_words:"true" default:"5m"`
+	// StaticDiscoveryDocumentsConfigMap is the ConfigMap, given as <namespace>/<name>, holding discovery documents
+	// which are used instead of fetching them from their well-known URI. Disabled when empty.
+	StaticDiscoveryDocumentsConfigMap string `split_words:"true"`
+	// PodWebhookNamespaceLabels are the labels, and their values, a namespace must have for the pod webhook to
+	// validate its pods.
+	PodWebhookNamespaceLabels map[string]string `split_words:"true" default:"skip.kartverket.no/skip-managed:true"`
+	// PodWebhookPodLabels are the labels a pod must have, regardless of their values, for the pod webhook to
+	// validate it.
+	PodWebhookPodLabels []string `split_words:"true" default:"application.skiperator.no/app-name"`
 }
 
 var cfg Config
@@ -0,0 +1,519 @@
+package explain
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy"
+	"github.com/kartverket/ztoperator/pkg/validation"
+)
+
+// Decision is the outcome of a request to a workload protected by an AuthPolicy.
+type Decision string
+
+const (
+	// DecisionAllow means the request is forwarded to the workload.
+	DecisionAllow Decision = "Allow"
+	// DecisionBypassLogin means the request is forwarded to the workload without login, although auto-login is
+	// enabled.
+	DecisionBypassLogin Decision = "BypassLogin"
+	// DecisionRedirect means the request is redirected, or handled, by the OAuth2 filter performing auto-login.
+	DecisionRedirect Decision = "Redirect"
+	// DecisionDenyRedirect means the request is denied with 401 instead of being redirected to login.
+	DecisionDenyRedirect Decision = "DenyRedirect"
+	// DecisionDeny means the request is denied, either with 401 as its token is rejected, or with 403 as it is not
+	// authorized. Unauthorized requests without a token are denied with 401 when a deny response applies to them.
+	DecisionDeny Decision = "Deny"
+)
+
+// Request is a request to a workload protected by an AuthPolicy.
+type Request struct {
+	Method string
+	// Path is the path of the request, which may include a query string.
+	Path string
+	Host string
+	// Headers holds the headers of the request by name.
+	Headers map[string]string
+	// Claims holds the claims of the token of the request, or nil if the request has no token. The token is assumed
+	// to be signed by a trusted key and not to be expired.
+	Claims map[string]interface{}
+}
+
+// Explanation explains the decision made for a request.
+type Explanation struct {
+	Decision Decision
+	// StatusCode is the status code of the response given by the sidecar, or 0 if the request is forwarded to the
+	// workload.
+	StatusCode int
+	// Rule is the rule of the AuthPolicy deciding the request, e.g. authRules[1], or empty if no rule matched.
+	Rule string
+	// Reason explains the decision, naming the condition which is not met when the request is denied.
+	Reason string
+	// DryRun is set if the AuthPolicy is in audit mode, in which case a denied request is only logged, and forwarded
+	// to the workload.
+	DryRun bool
+}
+
+// Explain simulates how the resources generated for the resolved AuthPolicy in scope handle the request.
+//
+// The filters of the sidecar are simulated in the order they run: the RequestAuthentication rejects tokens of untrusted
+// issuers, the auto-login EnvoyFilter redirects requests without a token unless login is bypassed, and the
+// AuthorizationPolicies decide the rest. As with the generated AuthorizationPolicies, the deny rules of every matching
+// auth rule are evaluated before any allow rule, and requests covered by no rule require a token from any trusted
+// identity provider. Requests are assumed not to originate from any of the sources given in auth rules.
+func Explain(scope *state.Scope, request Request) Explanation {
+	authPolicy := &scope.AuthPolicy
+	request.Path, _, _ = strings.Cut(request.Path, "?")
+
+	if !scope.IsEnabled() {
+		reason := "the AuthPolicy is disabled, so no resources are generated for it"
+		if conflictMessage := scope.GetConflictMessage(); conflictMessage != nil {
+			reason = "the AuthPolicy is not enforced: " + *conflictMessage
+		}
+		return Explanation{Decision: DecisionAllow, Reason: reason}
+	}
+	if scope.InvalidConfig {
+		reason := "the AuthPolicy has an invalid configuration, so all requests are denied"
+		if scope.ValidationErrorMessage != nil {
+			reason += ": " + *scope.ValidationErrorMessage
+		}
+		return deny(authPolicy, http.StatusForbidden, "", reason)
+	}
+
+	if request.Claims != nil {
+		if err := checkTrustedIssuer(scope.GetTrustedIdentityProviders(), request.Claims); err != nil {
+			return Explanation{
+				Decision:   DecisionDeny,
+				StatusCode: http.StatusUnauthorized,
+				Reason:     "the token is rejected by the RequestAuthentication: " + err.Error(),
+			}
+		}
+	} else if scope.AutoLoginConfig.Enabled {
+		return explainAutoLogin(scope, request)
+	}
+
+	return explainAuthorization(scope, request)
+}
+
+// explainAutoLogin mirrors the Lua script and OAuth2 filter of the auto-login EnvoyFilter for a request without a
+// token. Only requests bypassing login reach the AuthorizationPolicies.
+func explainAutoLogin(scope *state.Scope, request Request) Explanation {
+	autoLogin := scope.AutoLoginConfig
+	autoLoginPaths := map[string]string{
+		autoLogin.RedirectPath: "redirect",
+		autoLogin.LogoutPath:   "logout",
+	}
+	if autoLogin.LoginPath != nil {
+		autoLoginPaths[*autoLogin.LoginPath] = "login"
+	}
+	if kind, isAutoLoginPath := autoLoginPaths[request.Path]; isAutoLoginPath {
+		return Explanation{
+			Decision:   DecisionRedirect,
+			StatusCode: http.StatusFound,
+			Reason:     fmt.Sprintf("%s is the %s path, which is handled by the OAuth2 filter", request.Path, kind),
+		}
+	}
+
+	ignoreRule := firstMatchingRule(
+		"ignoreAuthRules",
+		luascript.IgnoreAuthMatchers(scope.AuthPolicy.Spec.IgnoreAuthRules),
+		request,
+	)
+	authRule := firstMatchingRule("authRules", v1alpha1.GetRequestMatchers(scope.AuthPolicy.Spec.AuthRules), request)
+	if ignoreRule != "" && authRule == "" {
+		explanation := explainAuthorization(scope, request)
+		if explanation.Decision == DecisionAllow {
+			explanation.Decision = DecisionBypassLogin
+			explanation.Reason = "login is bypassed, and " + explanation.Reason
+		}
+		return explanation
+	}
+
+	if authRule != "" && isDenyRedirect(scope.AuthPolicy.Spec.AuthRules, request) {
+		return Explanation{
+			Decision:   DecisionDenyRedirect,
+			StatusCode: http.StatusUnauthorized,
+			Rule:       authRule,
+			Reason:     "the request has no token, and the matching auth rule sets denyRedirect",
+		}
+	}
+
+	reason := "the request has no token and is not covered by any ignore auth rule, so it is redirected to login"
+	if ignoreRule != "" {
+		reason = fmt.Sprintf(
+			"the request has no token, and the auth rule %s takes precedence over the ignore auth rule %s, "+
+				"so it is redirected to login",
+			authRule,
+			ignoreRule,
+		)
+	}
+	return Explanation{Decision: DecisionRedirect, StatusCode: http.StatusFound, Rule: authRule, Reason: reason}
+}
+
+// explainAuthorization mirrors the deny, ignore and require AuthorizationPolicies.
+func explainAuthorization(scope *state.Scope, request Request) Explanation {
+	authPolicy := &scope.AuthPolicy
+
+	var allowingAuthRule string
+	if authPolicy.Spec.AuthRules != nil {
+		for i, authRule := range *authPolicy.Spec.AuthRules {
+			if !matchesRequest(authRule.RequestMatcher, request) {
+				continue
+			}
+			rule := fmt.Sprintf("authRules[%d]", i)
+			if err := checkAuthRule(scope, authRule, rule, request.Claims); err != nil {
+				reason := err.Error()
+				if authRule.From != nil && len(*authRule.From) > 0 {
+					reason += fmt.Sprintf(" (requests from the sources of %s.from are allowed without a token)", rule)
+				}
+				return deny(authPolicy, deniedStatusCode(scope, request), rule, reason)
+			}
+			if allowingAuthRule == "" {
+				allowingAuthRule = rule
+			}
+		}
+	}
+	if allowingAuthRule != "" {
+		return Explanation{
+			Decision: DecisionAllow,
+			Rule:     allowingAuthRule,
+			Reason:   "the token meets all conditions of every auth rule matching the request",
+		}
+	}
+
+	ignoreRule := firstMatchingRule("ignoreAuthRules", authPolicy.GetIgnoreAuthRequestMatchers(), request)
+	if ignoreRule != "" {
+		return Explanation{
+			Decision: DecisionAllow,
+			Rule:     ignoreRule,
+			Reason:   "the request matches an ignore auth rule, so no token is required",
+		}
+	}
+
+	if err := checkBaseConditions(scope, scope.GetTrustedIdentityProviders(), request.Claims); err != nil {
+		return deny(
+			authPolicy,
+			deniedStatusCode(scope, request),
+			"",
+			"the request matches no rule, so "+err.Error(),
+		)
+	}
+	return Explanation{
+		Decision: DecisionAllow,
+		Reason:   "the request matches no rule, and the token is issued by a trusted identity provider",
+	}
+}
+
+// deniedStatusCode returns the status code of a request denied by the AuthorizationPolicies. The deny response
+// EnvoyFilter answers requests without a token with 401 instead of 403, when a deny response applies to the request.
+func deniedStatusCode(scope *state.Scope, request Request) int {
+	if request.Claims == nil && appliesDenyResponse(scope, request) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusForbidden
+}
+
+// appliesDenyResponse mirrors the conditions under which the deny response EnvoyFilter is generated, and the selection
+// of the deny response of the first matching auth rule, falling back to the deny response of the AuthPolicy.
+func appliesDenyResponse(scope *state.Scope, request Request) bool {
+	authPolicy := &scope.AuthPolicy
+	if !scope.IsEnabled() || scope.InvalidConfig || authPolicy.IsAuditMode() || !authPolicy.HasDenyResponse() {
+		return false
+	}
+	if authPolicy.Spec.DenyResponse != nil {
+		return true
+	}
+	return slices.ContainsFunc(*authPolicy.Spec.AuthRules, func(authRule v1alpha1.RequestAuthRule) bool {
+		return authRule.DenyResponse != nil && matchesRequest(authRule.RequestMatcher, request)
+	})
+}
+
+func deny(authPolicy *v1alpha1.AuthPolicy, statusCode int, rule string, reason string) Explanation {
+	return Explanation{
+		Decision:   DecisionDeny,
+		StatusCode: statusCode,
+		Rule:       rule,
+		Reason:     reason,
+		DryRun:     authPolicy.IsAuditMode(),
+	}
+}
+
+// checkAuthRule checks the claims against the conditions of an auth rule matching the request, in the order of the
+// deny rules generated for it.
+func checkAuthRule(
+	scope *state.Scope,
+	authRule v1alpha1.RequestAuthRule,
+	rule string,
+	claims map[string]interface{},
+) error {
+	if err := checkBaseConditions(scope, scope.GetIdentityProvidersForAuthRule(authRule), claims); err != nil {
+		return fmt.Errorf("%s requires that %w", rule, err)
+	}
+	if authRule.When != nil {
+		if err := checkConditions(*authRule.When, rule+".when", claims); err != nil {
+			return err
+		}
+	}
+	if authRule.AnyOf != nil {
+		if err := checkConditionGroups(*authRule.AnyOf, rule+".anyOf", claims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBaseConditions checks that the token is issued by one of the identity providers for one of its accepted
+// audiences, and that it meets the baseline auth conditions.
+func checkBaseConditions(
+	scope *state.Scope,
+	identityProviders []state.IdentityProvider,
+	claims map[string]interface{},
+) error {
+	if claims == nil {
+		return fmt.Errorf("the request has a token issued by %s", describeIssuers(identityProviders))
+	}
+	if err := checkIdentityProviders(identityProviders, claims); err != nil {
+		return err
+	}
+	baselineAuth := scope.AuthPolicy.Spec.BaselineAuth
+	if baselineAuth == nil {
+		return nil
+	}
+	if err := checkConditions(baselineAuth.Claims, "baselineAuth.claims", claims); err != nil {
+		return err
+	}
+	return checkConditionGroups(baselineAuth.AnyOf, "baselineAuth.anyOf", claims)
+}
+
+// checkTrustedIssuer checks that the token is issued by any trusted identity provider, as tokens of other issuers are
+// rejected by the RequestAuthentication.
+func checkTrustedIssuer(identityProviders []state.IdentityProvider, claims map[string]interface{}) error {
+	issuer := claimValues(claims, "iss")
+	if !slices.ContainsFunc(identityProviders, func(identityProvider state.IdentityProvider) bool {
+		return slices.Contains(issuer, identityProvider.IdentityProviderUris.IssuerURI)
+	}) {
+		return fmt.Errorf("claim iss %s is not %s", describeValues(issuer), describeIssuers(identityProviders))
+	}
+	return nil
+}
+
+func checkIdentityProviders(identityProviders []state.IdentityProvider, claims map[string]interface{}) error {
+	if err := checkTrustedIssuer(identityProviders, claims); err != nil {
+		return fmt.Errorf("the token is issued by %s, but %w", describeIssuers(identityProviders), err)
+	}
+
+	// Identity providers sharing an issuer accept the union of their accepted resources, and any audience if any of
+	// them accepts any audience.
+	issuer := claimValues(claims, "iss")
+	var acceptedResources []string
+	for _, identityProvider := range identityProviders {
+		if !slices.Contains(issuer, identityProvider.IdentityProviderUris.IssuerURI) {
+			continue
+		}
+		identityProviderAcceptedResources := authorizationpolicy.ConstructAcceptedResourcesForIdentityProvider(
+			identityProvider,
+		)
+		if len(identityProviderAcceptedResources) == 0 {
+			return nil
+		}
+		acceptedResources = append(acceptedResources, identityProviderAcceptedResources...)
+	}
+	audience := claimValues(claims, "aud")
+	if !slices.ContainsFunc(audience, func(value string) bool { return matchesAny(acceptedResources, value) }) {
+		return fmt.Errorf(
+			"the token has one of the audiences %v, but claim aud %s does not",
+			acceptedResources,
+			describeValues(audience),
+		)
+	}
+	return nil
+}
+
+func checkConditions(conditions []v1alpha1.Condition, field string, claims map[string]interface{}) error {
+	for i, condition := range conditions {
+		if err := checkCondition(condition, claims); err != nil {
+			return fmt.Errorf("%s[%d] is not met: %w", field, i, err)
+		}
+	}
+	return nil
+}
+
+func checkConditionGroups(
+	conditionGroups []v1alpha1.ConditionGroup,
+	field string,
+	claims map[string]interface{},
+) error {
+	if len(conditionGroups) == 0 {
+		return nil
+	}
+	var errs []string
+	for i, conditionGroup := range conditionGroups {
+		err := checkConditions(conditionGroup.AllOf, fmt.Sprintf("%s[%d].allOf", field, i), claims)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return fmt.Errorf("none of the condition groups of %s are met: %s", field, strings.Join(errs, "; "))
+}
+
+// checkCondition checks a claim condition the way Istio does: every operator of the condition must be met, and a
+// claim holding a list meets values if any of its elements match.
+func checkCondition(condition v1alpha1.Condition, claims map[string]interface{}) error {
+	values := claimValues(claims, condition.Claim)
+	if len(condition.Values) > 0 &&
+		!slices.ContainsFunc(values, func(value string) bool { return matchesAny(condition.Values, value) }) {
+		if len(values) == 0 {
+			return fmt.Errorf("claim %s is missing, but must match one of %v", condition.Claim, condition.Values)
+		}
+		return fmt.Errorf(
+			"claim %s is %s, which does not match any of %v",
+			condition.Claim,
+			describeValues(values),
+			condition.Values,
+		)
+	}
+	for _, value := range values {
+		if matchesAny(condition.NotValues, value) {
+			return fmt.Errorf("claim %s is %s, which matches one of %v", condition.Claim, value, condition.NotValues)
+		}
+	}
+	if condition.Present != nil && *condition.Present && len(values) == 0 {
+		return fmt.Errorf("claim %s must be present", condition.Claim)
+	}
+	if condition.Present != nil && !*condition.Present && len(values) > 0 {
+		return fmt.Errorf("claim %s must not be present", condition.Claim)
+	}
+	return nil
+}
+
+// firstMatchingRule returns the name of the first request matcher matching the request, or empty if none matches.
+func firstMatchingRule(field string, matchers []v1alpha1.RequestMatcher, request Request) string {
+	index := slices.IndexFunc(matchers, func(matcher v1alpha1.RequestMatcher) bool {
+		return matchesRequest(matcher, request)
+	})
+	if index < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s[%d]", field, index)
+}
+
+func isDenyRedirect(authRules *[]v1alpha1.RequestAuthRule, request Request) bool {
+	return slices.ContainsFunc(luascript.DenyRedirectMatchers(authRules), func(matcher v1alpha1.RequestMatcher) bool {
+		return matchesRequest(matcher, request)
+	})
+}
+
+// matchesRequest reports whether the paths, methods, hosts and headers of the request matcher match the request.
+func matchesRequest(matcher v1alpha1.RequestMatcher, request Request) bool {
+	if !slices.ContainsFunc(matcher.Paths, func(path string) bool { return validation.MatchPath(path, request.Path) }) {
+		return false
+	}
+	if len(matcher.Methods) > 0 && !slices.Contains(matcher.Methods, request.Method) {
+		return false
+	}
+	host := strings.ToLower(request.Host)
+	if len(matcher.Hosts) > 0 && !matchesAny(lowercase(matcher.Hosts), host) {
+		return false
+	}
+	if matchesAny(lowercase(matcher.NotHosts), host) {
+		return false
+	}
+	for _, header := range matcher.Headers {
+		value := headerValue(request.Headers, header.Name)
+		if len(header.Values) > 0 && !matchesAny(header.Values, value) {
+			return false
+		}
+		if matchesAny(header.NotValues, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny reports whether the value matches any of the expected values, where a leading or trailing '*' denotes a
+// suffix or prefix match, and '*' alone matches any non-empty value.
+func matchesAny(expectedValues []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	return slices.ContainsFunc(expectedValues, func(expected string) bool {
+		switch {
+		case expected == "*":
+			return true
+		case strings.HasPrefix(expected, "*"):
+			return strings.HasSuffix(value, strings.TrimPrefix(expected, "*"))
+		case strings.HasSuffix(expected, "*"):
+			return strings.HasPrefix(value, strings.TrimSuffix(expected, "*"))
+		default:
+			return value == expected
+		}
+	})
+}
+
+func headerValue(headers map[string]string, name string) string {
+	for headerName, value := range headers {
+		if strings.EqualFold(headerName, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// claimValues returns the values of a claim, where a claim holding a list has a value per element.
+func claimValues(claims map[string]interface{}, claim string) []string {
+	switch value := claims[claim].(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, element := range value {
+			values = append(values, fmt.Sprint(element))
+		}
+		return values
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+func describeValues(values []string) string {
+	switch len(values) {
+	case 0:
+		return "missing"
+	case 1:
+		return values[0]
+	default:
+		return fmt.Sprint(values)
+	}
+}
+
+func describeIssuers(identityProviders []state.IdentityProvider) string {
+	if len(identityProviders) == 0 {
+		return "a trusted identity provider, but no identity provider is accepted"
+	}
+	issuers := make([]string, 0, len(identityProviders))
+	for _, identityProvider := range identityProviders {
+		if !slices.Contains(issuers, identityProvider.IdentityProviderUris.IssuerURI) {
+			issuers = append(issuers, identityProvider.IdentityProviderUris.IssuerURI)
+		}
+	}
+	if len(issuers) == 1 {
+		return issuers[0]
+	}
+	return "one of " + strings.Join(issuers, ", ")
+}
+
+func lowercase(values []string) []string {
+	lowercased := make([]string, 0, len(values))
+	for _, value := range values {
+		lowercased = append(lowercased, strings.ToLower(value))
+	}
+	return lowercased
+}
@@ -0,0 +1,393 @@
+package explain_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/explain"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const issuer = "https://idp.example.com"
+
+func explainScope() state.Scope {
+	return state.Scope{
+		AuthPolicy: v1alpha1.AuthPolicy{
+			Spec: v1alpha1.AuthPolicySpec{
+				Enabled:      true,
+				WellKnownURI: issuer + "/.well-known/openid-configuration",
+				Selector:     &v1alpha1.WorkloadSelector{MatchLabels: map[string]string{"app": "test"}},
+				IgnoreAuthRules: &[]v1alpha1.RequestMatcher{
+					{Paths: []string{"/public", "/assets/{**}"}, Methods: []string{"GET"}},
+					{Paths: []string{"/docs/{*}/index.html"}},
+				},
+				AuthRules: &[]v1alpha1.RequestAuthRule{
+					{RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/assets/private/*"}}},
+					{
+						RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/api/{**}"}},
+						DenyRedirect:   helperfunctions.Ptr(true),
+					},
+					{
+						RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/admin"}, Methods: []string{"POST"}},
+						When:           &[]v1alpha1.Condition{{Claim: "roles", Values: []string{"admin"}}},
+					},
+				},
+				BaselineAuth: &v1alpha1.BaselineAuth{
+					Claims: []v1alpha1.Condition{{Claim: "acr", NotValues: []string{"Level3"}}},
+				},
+			},
+		},
+		Audiences:            []string{"my-client"},
+		IdentityProviderUris: state.IdentityProviderUris{IssuerURI: issuer},
+	}
+}
+
+func autoLoginScope() state.Scope {
+	scope := explainScope()
+	scope.AutoLoginConfig = state.AutoLoginConfig{
+		Enabled:      true,
+		LoginPath:    helperfunctions.Ptr("/login"),
+		RedirectPath: "/oauth2/callback",
+		LogoutPath:   "/logout",
+	}
+	return scope
+}
+
+func claims(extra map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{"iss": issuer, "aud": "my-client", "sub": "user"}
+	for key, value := range extra {
+		result[key] = value
+	}
+	return result
+}
+
+func TestExplain_WithAutoLogin(t *testing.T) {
+	tests := []struct {
+		name           string
+		request        explain.Request
+		wantDecision   explain.Decision
+		wantStatusCode int
+		wantRule       string
+		wantReason     string
+	}{
+		{
+			name:         "ignored path bypasses login",
+			request:      explain.Request{Method: "GET", Path: "/public?page=2"},
+			wantDecision: explain.DecisionBypassLogin,
+			wantRule:     "ignoreAuthRules[0]",
+		},
+		{
+			name:         "ignored single segment template bypasses login",
+			request:      explain.Request{Method: "GET", Path: "/docs/v1/index.html"},
+			wantDecision: explain.DecisionBypassLogin,
+			wantRule:     "ignoreAuthRules[1]",
+		},
+		{
+			name:           "single segment template does not span segments",
+			request:        explain.Request{Method: "GET", Path: "/docs/v1/beta/index.html"},
+			wantDecision:   explain.DecisionRedirect,
+			wantStatusCode: http.StatusFound,
+		},
+		{
+			name:           "auth rule takes precedence over ignored path",
+			request:        explain.Request{Method: "GET", Path: "/assets/private/key.pem"},
+			wantDecision:   explain.DecisionRedirect,
+			wantStatusCode: http.StatusFound,
+			wantRule:       "authRules[0]",
+			wantReason:     "takes precedence over the ignore auth rule ignoreAuthRules[0]",
+		},
+		{
+			name:           "deny redirect auth rule",
+			request:        explain.Request{Method: "GET", Path: "/api/items"},
+			wantDecision:   explain.DecisionDenyRedirect,
+			wantStatusCode: http.StatusUnauthorized,
+			wantRule:       "authRules[1]",
+		},
+		{
+			name:           "redirect path is handled by the OAuth2 filter",
+			request:        explain.Request{Method: "GET", Path: "/oauth2/callback?code=abc"},
+			wantDecision:   explain.DecisionRedirect,
+			wantStatusCode: http.StatusFound,
+			wantReason:     "/oauth2/callback is the redirect path",
+		},
+		{
+			name: "token is authorized",
+			request: explain.Request{
+				Method: "POST",
+				Path:   "/admin",
+				Claims: claims(map[string]interface{}{"roles": []interface{}{"user", "admin"}}),
+			},
+			wantDecision: explain.DecisionAllow,
+			wantRule:     "authRules[2]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			scope := autoLoginScope()
+
+			// 2. Act
+			explanation := explain.Explain(&scope, tt.request)
+
+			// 3. Assert
+			assert.Equal(t, tt.wantDecision, explanation.Decision)
+			assert.Equal(t, tt.wantStatusCode, explanation.StatusCode)
+			assert.Equal(t, tt.wantRule, explanation.Rule)
+			assert.Contains(t, explanation.Reason, tt.wantReason)
+		})
+	}
+}
+
+func TestExplain_WithoutAutoLogin(t *testing.T) {
+	tests := []struct {
+		name           string
+		request        explain.Request
+		wantDecision   explain.Decision
+		wantStatusCode int
+		wantRule       string
+		wantReason     string
+	}{
+		{
+			name:         "ignored path without token",
+			request:      explain.Request{Method: "GET", Path: "/assets/site.css"},
+			wantDecision: explain.DecisionAllow,
+			wantRule:     "ignoreAuthRules[0]",
+		},
+		{
+			name:           "auth rule without token",
+			request:        explain.Request{Method: "GET", Path: "/assets/private/key.pem"},
+			wantDecision:   explain.DecisionDeny,
+			wantStatusCode: http.StatusForbidden,
+			wantRule:       "authRules[0]",
+			wantReason:     "authRules[0] requires that the request has a token issued by " + issuer,
+		},
+		{
+			name:           "path in no rule without token",
+			request:        explain.Request{Method: "GET", Path: "/unknown"},
+			wantDecision:   explain.DecisionDeny,
+			wantStatusCode: http.StatusForbidden,
+			wantReason:     "the request matches no rule",
+		},
+		{
+			name:         "path in no rule with token",
+			request:      explain.Request{Method: "GET", Path: "/unknown", Claims: claims(nil)},
+			wantDecision: explain.DecisionAllow,
+		},
+		{
+			name: "token of untrusted issuer",
+			request: explain.Request{
+				Method: "GET",
+				Path:   "/unknown",
+				Claims: claims(map[string]interface{}{"iss": "https://other.example.com"}),
+			},
+			wantDecision:   explain.DecisionDeny,
+			wantStatusCode: http.StatusUnauthorized,
+			wantReason:     "rejected by the RequestAuthentication",
+		},
+		{
+			name: "token for other audience",
+			request: explain.Request{
+				Method: "GET",
+				Path:   "/api/items",
+				Claims: claims(map[string]interface{}{"aud": []interface{}{"other-client"}}),
+			},
+			wantDecision:   explain.DecisionDeny,
+			wantStatusCode: http.StatusForbidden,
+			wantRule:       "authRules[1]",
+			wantReason:     "claim aud other-client does not",
+		},
+		{
+			name: "token failing baseline auth",
+			request: explain.Request{
+				Method: "GET",
+				Path:   "/api/items",
+				Claims: claims(map[string]interface{}{"acr": "Level3"}),
+			},
+			wantDecision:   explain.DecisionDeny,
+			wantStatusCode: http.StatusForbidden,
+			wantRule:       "authRules[1]",
+			wantReason:     "baselineAuth.claims[0] is not met: claim acr is Level3",
+		},
+		{
+			name:           "token failing when condition of auth rule",
+			request:        explain.Request{Method: "POST", Path: "/admin", Claims: claims(nil)},
+			wantDecision:   explain.DecisionDeny,
+			wantStatusCode: http.StatusForbidden,
+			wantRule:       "authRules[2]",
+			wantReason:     "authRules[2].when[0] is not met: claim roles is missing",
+		},
+		{
+			name:         "auth rule with other method falls back to any trusted token",
+			request:      explain.Request{Method: "GET", Path: "/admin", Claims: claims(nil)},
+			wantDecision: explain.DecisionAllow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			scope := explainScope()
+
+			// 2. Act
+			explanation := explain.Explain(&scope, tt.request)
+
+			// 3. Assert
+			assert.Equal(t, tt.wantDecision, explanation.Decision)
+			assert.Equal(t, tt.wantStatusCode, explanation.StatusCode)
+			assert.Equal(t, tt.wantRule, explanation.Rule)
+			assert.Contains(t, explanation.Reason, tt.wantReason)
+			assert.False(t, explanation.DryRun)
+		})
+	}
+}
+
+func TestExplain_WithInvalidConfig_DeniesAll(t *testing.T) {
+	// 1. Arrange
+	scope := explainScope()
+	scope.InvalidConfig = true
+	scope.ValidationErrorMessage = helperfunctions.Ptr("invalid path")
+
+	// 2. Act
+	explanation := explain.Explain(&scope, explain.Request{Method: "GET", Path: "/public", Claims: claims(nil)})
+
+	// 3. Assert
+	assert.Equal(t, explain.DecisionDeny, explanation.Decision)
+	assert.Equal(t, http.StatusForbidden, explanation.StatusCode)
+	assert.Contains(t, explanation.Reason, "invalid path")
+}
+
+func TestExplain_WithAuditMode_DeniesInDryRun(t *testing.T) {
+	// 1. Arrange
+	scope := explainScope()
+	scope.AuthPolicy.Spec.EnforcementMode = v1alpha1.EnforcementModeAudit
+
+	// 2. Act
+	explanation := explain.Explain(&scope, explain.Request{Method: "GET", Path: "/unknown"})
+
+	// 3. Assert
+	assert.Equal(t, explain.DecisionDeny, explanation.Decision)
+	assert.True(t, explanation.DryRun)
+}
+
+func TestExplain_WithDenyResponse(t *testing.T) {
+	tests := []struct {
+		name           string
+		denyResponse   func(scope *state.Scope)
+		request        explain.Request
+		wantStatusCode int
+	}{
+		{
+			name: "request without token is denied with 401",
+			denyResponse: func(scope *state.Scope) {
+				scope.AuthPolicy.Spec.DenyResponse = &v1alpha1.DenyResponse{}
+			},
+			request:        explain.Request{Method: "GET", Path: "/unknown"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "request with token lacking claims is denied with 403",
+			denyResponse: func(scope *state.Scope) {
+				scope.AuthPolicy.Spec.DenyResponse = &v1alpha1.DenyResponse{}
+			},
+			request:        explain.Request{Method: "POST", Path: "/admin", Claims: claims(nil)},
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name: "request without token matching auth rule with deny response is denied with 401",
+			denyResponse: func(scope *state.Scope) {
+				(*scope.AuthPolicy.Spec.AuthRules)[0].DenyResponse = &v1alpha1.DenyResponse{}
+			},
+			request:        explain.Request{Method: "GET", Path: "/assets/private/key.pem"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "request without token matching no deny response is denied with 403",
+			denyResponse: func(scope *state.Scope) {
+				(*scope.AuthPolicy.Spec.AuthRules)[0].DenyResponse = &v1alpha1.DenyResponse{}
+			},
+			request:        explain.Request{Method: "GET", Path: "/unknown"},
+			wantStatusCode: http.StatusForbidden,
+		},
+		{
+			name: "request without token in audit mode is denied with 403",
+			denyResponse: func(scope *state.Scope) {
+				scope.AuthPolicy.Spec.DenyResponse = &v1alpha1.DenyResponse{}
+				scope.AuthPolicy.Spec.EnforcementMode = v1alpha1.EnforcementModeAudit
+			},
+			request:        explain.Request{Method: "GET", Path: "/unknown"},
+			wantStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			scope := explainScope()
+			tt.denyResponse(&scope)
+
+			// 2. Act
+			explanation := explain.Explain(&scope, tt.request)
+
+			// 3. Assert
+			assert.Equal(t, explain.DecisionDeny, explanation.Decision)
+			assert.Equal(t, tt.wantStatusCode, explanation.StatusCode)
+		})
+	}
+}
+
+func TestExplain_WhenDisabled_Allows(t *testing.T) {
+	// 1. Arrange
+	scope := explainScope()
+	scope.AuthPolicy.Spec.Enabled = false
+
+	// 2. Act
+	explanation := explain.Explain(&scope, explain.Request{Method: "GET", Path: "/unknown"})
+
+	// 3. Assert
+	assert.Equal(t, explain.DecisionAllow, explanation.Decision)
+	assert.Contains(t, explanation.Reason, "disabled")
+}
+
+func TestExplain_WhenRefusedByOverlappingAuthPolicy_Allows(t *testing.T) {
+	// 1. Arrange
+	scope := explainScope()
+	scope.OverlappingAuthPolicy = helperfunctions.Ptr("older-policy")
+
+	// 2. Act
+	explanation := explain.Explain(&scope, explain.Request{Method: "GET", Path: "/unknown"})
+
+	// 3. Assert
+	assert.Equal(t, explain.DecisionAllow, explanation.Decision)
+	assert.Contains(t, explanation.Reason, "older-policy")
+}
+
+func TestClaimsFromJWT(t *testing.T) {
+	// 1. Arrange
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"https://idp.example.com","aud":["a","b"]}`))
+	token := "Bearer eyJhbGciOiJSUzI1NiJ9." + payload + ".signature"
+
+	// 2. Act
+	result, err := explain.ClaimsFromJWT(token)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"iss": issuer, "aud": []interface{}{"a", "b"}}, result)
+}
+
+func TestClaimsFromJWT_WithInvalidToken_ReturnsError(t *testing.T) {
+	// 1. Arrange
+	token := "not-a-jwt"
+
+	// 2. Act
+	_, err := explain.ClaimsFromJWT(token)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "token is not a JWT")
+}
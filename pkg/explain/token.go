@@ -0,0 +1,35 @@
+package explain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ClaimsFromJWT returns the claims of the payload of a JWT. The signature of the JWT is not verified, as the
+// signing keys of the identity provider are not known when explaining a decision.
+func ClaimsFromJWT(token string) (map[string]interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(token), "Bearer "), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT: expected three dot-separated parts")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWT payload: %w", err)
+	}
+	return ClaimsFromJSON(payload)
+}
+
+// ClaimsFromJSON returns the claims of a JSON object.
+func ClaimsFromJSON(data []byte) (map[string]interface{}, error) {
+	var claims map[string]interface{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse claims: %w", err)
+	}
+	if claims == nil {
+		return nil, errors.New("claims must be a JSON object")
+	}
+	return claims, nil
+}
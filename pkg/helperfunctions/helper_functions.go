@@ -83,6 +83,10 @@ func GenerateHMACSecret(size int) (*string, error) {
 
 func GetProtectedPods(ctx context.Context, k8sClient client.Client, authPolicy v1alpha1.AuthPolicy) (*[]v1.Pod, error) {
 	var podList v1.PodList
+	if authPolicy.Spec.Selector == nil {
+		// AuthPolicies applying to targetRefs do not select any pods by their labels
+		return &podList.Items, nil
+	}
 	if listErr := k8sClient.List(
 		ctx,
 		&podList,
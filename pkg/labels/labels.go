@@ -5,9 +5,10 @@ const (
 	ControllerLabelKey = "ztoperator.kartverket.no/controller"
 	TypeLabelKey       = "type"
 
-	ManagedByLabelValue            = "ztoperator"
-	AuthPolicyControllerLabelValue = "authpolicy"
-	TypeLabelValue                 = "ztoperator.kartverket.no"
+	ManagedByLabelValue                   = "ztoperator"
+	AuthPolicyControllerLabelValue        = "authpolicy"
+	ClusterAuthPolicyControllerLabelValue = "clusterauthpolicy"
+	TypeLabelValue                        = "ztoperator.kartverket.no"
 )
 
 // AuthPolicyStandardLabels returns the set of labels applied to every resource created by Ztoperator for the AuthPolicy
@@ -19,3 +20,13 @@ func AuthPolicyStandardLabels() map[string]string {
 		TypeLabelKey:       TypeLabelValue,
 	}
 }
+
+// ClusterAuthPolicyStandardLabels returns the set of labels applied to every resource created by Ztoperator for the
+// ClusterAuthPolicy controller.
+func ClusterAuthPolicyStandardLabels() map[string]string {
+	return map[string]string{
+		ManagedByLabelKey:  ManagedByLabelValue,
+		ControllerLabelKey: ClusterAuthPolicyControllerLabelValue,
+		TypeLabelKey:       TypeLabelValue,
+	}
+}
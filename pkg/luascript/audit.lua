@@ -0,0 +1,37 @@
+-- the RBAC filters of the sidecar record the results of dry-run AuthorizationPolicies in this namespace
+local rbac_metadata_namespace = "envoy.filters.http.rbac"
+local deny_result_key = "istio_dry_run_deny_shadow_engine_result"
+local deny_policy_key = "istio_dry_run_deny_shadow_effective_policy_id"
+local allow_result_key = "istio_dry_run_allow_shadow_engine_result"
+
+-- returns true when the effective policy id, e.g. "ns[default]-policy[name]-rule[0]", refers to the deny policy
+local function is_deny_policy(policy_id)
+    return string.find(policy_id, "ns[" .. namespace .. "]-policy[" .. deny_policy .. "]-", 1, true) ~= nil
+end
+
+function envoy_on_request(request_handle)
+    local rbac = request_handle:streamInfo():dynamicMetadata():get(rbac_metadata_namespace)
+    if rbac == nil then
+        return
+    end
+
+    local reason = nil
+    local deny_policy_id = rbac[deny_policy_key] or ""
+    if rbac[deny_result_key] == "denied" and is_deny_policy(deny_policy_id) then
+        reason = "matched " .. deny_policy_id
+    elseif rbac[allow_result_key] == "denied" then
+        reason = "matched no allow rule"
+    end
+    if reason == nil then
+        return
+    end
+
+    local headers = request_handle:headers()
+    local m = headers:get(":method") or ""
+    local p = string.match(headers:get(":path") or "", "^[^?]*")
+    local host = headers:get(":authority") or ""
+    request_handle:logWarn(
+        "ztoperator audit: AuthPolicy " .. namespace .. "/" .. auth_policy .. " would have denied " ..
+            m .. " " .. host .. p .. ": " .. reason
+    )
+end
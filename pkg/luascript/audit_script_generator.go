@@ -0,0 +1,27 @@
+package luascript
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed audit.lua
+var auditLuaScript string
+
+// GenerateAuditLuaScript produces the Lua source code of the Envoy Lua filter
+// logging inbound requests which an AuthPolicy in audit mode would have denied.
+//
+// The AuthorizationPolicies of an AuthPolicy in audit mode are generated in
+// Istio dry-run mode, where the RBAC filters of the sidecar record whether the
+// request would have been denied, and by which rule, as dynamic metadata
+// instead of denying it. The Lua filter runs after the RBAC filters and logs
+// every request which was denied by the dry-run deny policy with the given
+// name, or which matched none of the dry-run allow rules.
+func GenerateAuditLuaScript(namespace string, authPolicyName string, denyPolicyName string) string {
+	var builder strings.Builder
+	builder.WriteString("local namespace = \"" + EscapeLuaString(namespace) + "\"\n")
+	builder.WriteString("local auth_policy = \"" + EscapeLuaString(authPolicyName) + "\"\n")
+	builder.WriteString("local deny_policy = \"" + EscapeLuaString(denyPolicyName) + "\"\n")
+	builder.WriteString(auditLuaScript)
+	return builder.String()
+}
@@ -0,0 +1,141 @@
+package luascript_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// mockAuditHandleStub defines make_audit_handle(initial_headers, rbac_metadata), returning a handle whose dynamic
+// metadata holds the given results of the RBAC filters. Warnings logged through the handle are appended to the global
+// warnings table.
+const mockAuditHandleStub = `
+warnings = {}
+
+function make_audit_handle(initial_headers, rbac_metadata)
+    local hdrs = {}
+    for k, v in pairs(initial_headers or {}) do hdrs[k] = v end
+
+    local headers_obj = {
+        get = function(_, k) return hdrs[k] end,
+    }
+    local dynamic_metadata = {
+        get = function(_, namespace)
+            if namespace == "envoy.filters.http.rbac" then return rbac_metadata end
+            return nil
+        end,
+    }
+    return {
+        headers    = function(_) return headers_obj end,
+        streamInfo = function(_) return { dynamicMetadata = function(_) return dynamic_metadata end } end,
+        logWarn    = function(_, msg) table.insert(warnings, msg) end,
+    }
+end
+`
+
+func runAudit(t *testing.T, rbacMetadata map[string]string) []string {
+	t.Helper()
+	L := lua.NewState()
+	defer L.Close()
+	require.NoError(t, L.DoString(mockAuditHandleStub))
+	require.NoError(t, L.DoString(
+		luascript.GenerateAuditLuaScript("orders", "orders-api", "orders-api-deny-auth-rules"),
+	))
+
+	headers := L.NewTable()
+	L.SetField(headers, ":method", lua.LString("DELETE"))
+	L.SetField(headers, ":path", lua.LString("/admin/users?id=1"))
+	L.SetField(headers, ":authority", lua.LString("orders.example.com"))
+	var metadata lua.LValue = lua.LNil
+	if rbacMetadata != nil {
+		table := L.NewTable()
+		for k, v := range rbacMetadata {
+			L.SetField(table, k, lua.LString(v))
+		}
+		metadata = table
+	}
+	require.NoError(t, L.CallByParam(
+		lua.P{Fn: L.GetGlobal("make_audit_handle"), NRet: 1, Protect: true},
+		headers, metadata,
+	))
+	handle := L.Get(-1)
+	L.Pop(1)
+	require.NoError(t, L.CallByParam(lua.P{Fn: L.GetGlobal("envoy_on_request"), NRet: 0, Protect: true}, handle))
+
+	var warnings []string
+	L.GetGlobal("warnings").(*lua.LTable).ForEach(func(_, warning lua.LValue) {
+		warnings = append(warnings, warning.String())
+	})
+	return warnings
+}
+
+func TestAudit_DeniedByDenyPolicy_LogsMatchedRule(t *testing.T) {
+	// 1. Arrange
+	rbacMetadata := map[string]string{
+		"istio_dry_run_deny_shadow_engine_result":       "denied",
+		"istio_dry_run_deny_shadow_effective_policy_id": "ns[orders]-policy[orders-api-deny-auth-rules]-rule[1]",
+		"istio_dry_run_allow_shadow_engine_result":      "allowed",
+	}
+
+	// 2. Act
+	warnings := runAudit(t, rbacMetadata)
+
+	// 3. Assert
+	assert.Equal(t, []string{
+		"ztoperator audit: AuthPolicy orders/orders-api would have denied DELETE orders.example.com/admin/users: " +
+			"matched ns[orders]-policy[orders-api-deny-auth-rules]-rule[1]",
+	}, warnings)
+}
+
+func TestAudit_NoAllowRuleMatched_LogsRequest(t *testing.T) {
+	// 1. Arrange
+	rbacMetadata := map[string]string{
+		"istio_dry_run_allow_shadow_engine_result": "denied",
+	}
+
+	// 2. Act
+	warnings := runAudit(t, rbacMetadata)
+
+	// 3. Assert
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "would have denied DELETE orders.example.com/admin/users: matched no allow rule")
+}
+
+func TestAudit_LeavesOtherRequestsUnlogged(t *testing.T) {
+	tests := []struct {
+		name         string
+		rbacMetadata map[string]string
+	}{
+		{
+			name:         "no dry-run results",
+			rbacMetadata: nil,
+		},
+		{
+			name: "allowed by dry-run policies",
+			rbacMetadata: map[string]string{
+				"istio_dry_run_deny_shadow_engine_result":  "allowed",
+				"istio_dry_run_allow_shadow_engine_result": "allowed",
+			},
+		},
+		{
+			name: "denied by the deny policy of another AuthPolicy",
+			rbacMetadata: map[string]string{
+				"istio_dry_run_deny_shadow_engine_result":       "denied",
+				"istio_dry_run_deny_shadow_effective_policy_id": "ns[orders]-policy[other-deny-auth-rules]-rule[0]",
+				"istio_dry_run_allow_shadow_engine_result":      "allowed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange & 2. Act
+			warnings := runAudit(t, tt.rbacMetadata)
+
+			// 3. Assert
+			assert.Empty(t, warnings)
+		})
+	}
+}
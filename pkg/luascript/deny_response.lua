@@ -0,0 +1,181 @@
+local metadata_namespace = "ztoperator.deny_response"
+
+-- local replies of the JWT authentication filter start with one of these prefixes
+local invalid_token_body_prefixes = { "Jwt", "Jwks", "Audiences in Jwt" }
+local access_denied_body = "RBAC: access denied"
+
+local function starts_with(value, prefix)
+    return string.sub(value, 1, #prefix) == prefix
+end
+
+-- returns the index of the first deny response matching {p,m,host,headers}, or nil if none matches
+local function find_deny_response(p, m, host, headers)
+    for index, deny_response in ipairs(deny_responses) do
+        -- absent "rules" == all requests
+        if deny_response.rules == nil or match(deny_response.rules, p, m, host, headers) then
+            return index
+        end
+    end
+    return nil
+end
+
+local function has_query_param(raw_p, name)
+    local query = string.match(raw_p, "%?(.*)$")
+    if query == nil then
+        return false
+    end
+    for param in string.gmatch(query, "[^&]+") do
+        local key, value = string.match(param, "^([^=]*)=(.*)$")
+        if key == name and value ~= "" then
+            return true
+        end
+    end
+    return false
+end
+
+local function has_cookie(cookie_header, name)
+    for cookie in string.gmatch(cookie_header, "[^;]+") do
+        local key, value = string.match(cookie, "^%s*([^=]*)=(.*)$")
+        if key == name and value ~= "" then
+            return true
+        end
+    end
+    return false
+end
+
+-- returns true when the request carries a token in any of the locations the JWT authentication filter reads from
+local function has_token(raw_p, headers)
+    for _, token_header in ipairs(token_headers) do
+        local value = headers:get(token_header.name)
+        if value ~= nil and #value > #token_header.prefix and starts_with(value, token_header.prefix) then
+            return true
+        end
+    end
+    for _, name in ipairs(token_params) do
+        if has_query_param(raw_p, name) then
+            return true
+        end
+    end
+    local cookie_header = headers:get("cookie") or ""
+    for _, name in ipairs(token_cookies) do
+        if has_cookie(cookie_header, name) then
+            return true
+        end
+    end
+    return false
+end
+
+local function json_escape(value)
+    return (string.gsub(value, '[%c"\\]', function(c)
+        if c == '"' then
+            return '\\"'
+        elseif c == "\\" then
+            return "\\\\"
+        end
+        return string.format("\\u%04x", string.byte(c))
+    end))
+end
+
+local function www_authenticate(deny_response, error_code, error_description)
+    local attributes = {}
+    if deny_response.realm ~= "" then
+        table.insert(attributes, 'realm="' .. deny_response.realm .. '"')
+    end
+    if error_code ~= nil then
+        table.insert(attributes, 'error="' .. error_code .. '"')
+        table.insert(attributes, 'error_description="' .. error_description .. '"')
+    end
+    if deny_response.scope ~= "" then
+        table.insert(attributes, 'scope="' .. deny_response.scope .. '"')
+    end
+    if deny_response.resource_metadata ~= "" then
+        table.insert(attributes, 'resource_metadata="' .. deny_response.resource_metadata .. '"')
+    end
+    if #attributes == 0 then
+        return "Bearer"
+    end
+    return "Bearer " .. table.concat(attributes, ", ")
+end
+
+local function problem(deny_response, status, title, detail)
+    local problem_type = deny_response.problem_type
+    if problem_type == "" then
+        problem_type = "about:blank"
+    end
+    return '{"type":"' .. json_escape(problem_type) ..
+        '","title":"' .. json_escape(title) ..
+        '","status":' .. status ..
+        ',"detail":"' .. json_escape(detail) .. '"}'
+end
+
+function envoy_on_request(request_handle)
+    local headers = request_handle:headers()
+    local raw_p = headers:get(":path") or ""
+    local m = headers:get(":method") or ""
+    local p = string.match(raw_p, "^[^?]*")
+    local host = string.lower(headers:get(":authority") or "")
+
+    local index = find_deny_response(p, m, host, headers)
+    if index == nil then
+        return
+    end
+    local metadata = request_handle:streamInfo():dynamicMetadata()
+    metadata:set(metadata_namespace, "index", index)
+    metadata:set(metadata_namespace, "has_token", has_token(raw_p, headers))
+end
+
+function envoy_on_response(response_handle)
+    local status = response_handle:headers():get(":status") or ""
+    if status ~= "401" and status ~= "403" then
+        return
+    end
+    local metadata = response_handle:streamInfo():dynamicMetadata():get(metadata_namespace)
+    if metadata == nil or metadata["index"] == nil then
+        return
+    end
+    -- only local replies of the sidecar are rewritten, never responses of the application
+    local content_type = response_handle:headers():get("content-type") or ""
+    if not starts_with(content_type, "text/plain") then
+        return
+    end
+    local body = response_handle:body():getBytes(0, response_handle:body():length())
+
+    local deny_response = deny_responses[metadata["index"]]
+    local new_status, title, error_code, detail
+    if status == "401" then
+        local invalid_token = false
+        for _, prefix in ipairs(invalid_token_body_prefixes) do
+            if starts_with(body, prefix) then
+                invalid_token = true
+                break
+            end
+        end
+        if not invalid_token then
+            return
+        end
+        new_status, title, error_code = "401", "Unauthorized", "invalid_token"
+        detail = "The access token is invalid: " .. body
+    else
+        if body ~= access_denied_body then
+            return
+        end
+        if metadata["has_token"] then
+            new_status, title, error_code = "403", "Forbidden", "insufficient_scope"
+            detail = "The access token does not grant access to the resource"
+        else
+            -- requests lacking any authentication information carry no error code, see RFC 6750 section 3.1
+            new_status, title, error_code = "401", "Unauthorized", nil
+            detail = "An access token is required to access the resource"
+        end
+    end
+    if deny_response.error_description ~= "" then
+        detail = deny_response.error_description
+    end
+
+    response_handle:headers():replace(":status", new_status)
+    response_handle:headers():replace("content-type", "application/problem+json")
+    -- quoted-string attributes cannot carry control characters, quotes or backslashes
+    local error_description = string.gsub(detail, '[%c"\\]', "'")
+    response_handle:headers():replace("www-authenticate", www_authenticate(deny_response, error_code, error_description))
+    response_handle:body():setBytes(problem(deny_response, new_status, title, detail))
+end
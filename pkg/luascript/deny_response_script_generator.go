@@ -0,0 +1,86 @@
+package luascript
+
+import (
+	_ "embed"
+	"strings"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+)
+
+//go:embed deny_response.lua
+var denyResponseLuaScript string
+
+// GenerateDenyResponseLuaScript produces the Lua source code of the Envoy Lua
+// filter rewriting the responses of inbound requests denied by the sidecar.
+//
+// On request, the filter selects the deny response of the first auth rule
+// matching the request, falling back to the deny response of the AuthPolicy,
+// and records whether the request carries a token in any of the locations the
+// JWT authentication filter reads from.
+//
+// On response, local replies of the JWT authentication filter (401) and the
+// RBAC filter (403) are rewritten to carry a WWW-Authenticate header following
+// RFC 6750 and an application/problem+json body following RFC 9457. Requests
+// denied by the RBAC filter without a token are answered with 401 instead of
+// 403, as they lack authentication rather than sufficient claims.
+func GenerateDenyResponseLuaScript(authPolicy *v1alpha1.AuthPolicy) string {
+	var builder strings.Builder
+	builder.WriteString(requestMatcherLuaScript)
+	builder.WriteString("\nlocal deny_responses = {")
+	first := true
+	if authPolicy.Spec.AuthRules != nil {
+		for _, authRule := range *authPolicy.Spec.AuthRules {
+			if authRule.DenyResponse == nil {
+				continue
+			}
+			if !first {
+				builder.WriteString(",")
+			}
+			first = false
+			builder.WriteString("{rules=")
+			builder.WriteString(ConvertRequestMatchersToLuaTableString([]v1alpha1.RequestMatcher{authRule.RequestMatcher}))
+			builder.WriteString(",")
+			writeDenyResponse(&builder, *authRule.DenyResponse)
+			builder.WriteString("}")
+		}
+	}
+	if authPolicy.Spec.DenyResponse != nil {
+		if !first {
+			builder.WriteString(",")
+		}
+		builder.WriteString("{")
+		writeDenyResponse(&builder, *authPolicy.Spec.DenyResponse)
+		builder.WriteString("}")
+	}
+	builder.WriteString("}\n")
+
+	builder.WriteString(`local token_headers = {{name="authorization",prefix="Bearer "}`)
+	for _, header := range authPolicy.Spec.FromHeaders {
+		builder.WriteString(`,{name="` + EscapeLuaString(strings.ToLower(header.Name)) + `",prefix="` +
+			EscapeLuaString(header.Prefix) + `"}`)
+	}
+	builder.WriteString("}\n")
+	builder.WriteString("local token_params = ")
+	writeLuaStringList(&builder, append([]string{"access_token"}, authPolicy.Spec.FromParams...), nil)
+	builder.WriteString("\nlocal token_cookies = ")
+	writeLuaStringList(&builder, authPolicy.Spec.FromCookies, nil)
+	builder.WriteString("\n")
+
+	builder.WriteString(denyResponseLuaScript)
+	return builder.String()
+}
+
+func writeDenyResponse(builder *strings.Builder, denyResponse v1alpha1.DenyResponse) {
+	builder.WriteString(`realm="` + EscapeLuaString(valueOrEmpty(denyResponse.Realm)) + `"`)
+	builder.WriteString(`,scope="` + EscapeLuaString(strings.Join(denyResponse.Scopes, " ")) + `"`)
+	builder.WriteString(`,resource_metadata="` + EscapeLuaString(valueOrEmpty(denyResponse.ResourceMetadata)) + `"`)
+	builder.WriteString(`,error_description="` + EscapeLuaString(valueOrEmpty(denyResponse.ErrorDescription)) + `"`)
+	builder.WriteString(`,problem_type="` + EscapeLuaString(valueOrEmpty(denyResponse.ProblemType)) + `"`)
+}
+
+func valueOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,355 @@
+package luascript_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// mockStreamHandleStub defines make_stream_handle(initial_headers, body), returning a handle which additionally
+// supports streamInfo():dynamicMetadata() and body(). Dynamic metadata is kept in the global stream_metadata table,
+// shared between the request and response handles of a stream, and the body is stored in handle.body_bytes.
+const mockStreamHandleStub = `
+stream_metadata = {}
+
+local dynamic_metadata = {
+    get = function(_, namespace) return stream_metadata[namespace] end,
+    set = function(_, namespace, key, value)
+        stream_metadata[namespace] = stream_metadata[namespace] or {}
+        stream_metadata[namespace][key] = value
+    end,
+}
+
+function make_stream_handle(initial_headers, body)
+    local hdrs = {}
+    for k, v in pairs(initial_headers or {}) do hdrs[k] = v end
+
+    local headers_obj = {
+        get     = function(_, k) return hdrs[k] end,
+        add     = function(_, k, v) hdrs[k] = v end,
+        replace = function(_, k, v) hdrs[k] = v end,
+    }
+    local handle = {
+        hdrs       = hdrs,
+        body_bytes = body or "",
+        headers    = function(_) return headers_obj end,
+        streamInfo = function(_) return { dynamicMetadata = function(_) return dynamic_metadata end } end,
+    }
+    local body_obj = {
+        length   = function(_) return #handle.body_bytes end,
+        getBytes = function(_, index, length) return string.sub(handle.body_bytes, index + 1, index + length) end,
+        setBytes = function(_, bytes) handle.body_bytes = bytes end,
+    }
+    handle.body = function(_) return body_obj end
+    return handle
+end
+`
+
+type deniedResponse struct {
+	headers map[string]string
+	body    string
+}
+
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// runDenyResponse runs envoy_on_request with the given request headers and envoy_on_response with the given local
+// reply within the same stream, returning the resulting response.
+func runDenyResponse(
+	t *testing.T,
+	authPolicy *v1alpha1.AuthPolicy,
+	requestHeaders map[string]string,
+	status string,
+	body string,
+) deniedResponse {
+	t.Helper()
+	L := lua.NewState()
+	defer L.Close()
+	require.NoError(t, L.DoString(mockStreamHandleStub))
+	require.NoError(t, L.DoString(luascript.GenerateDenyResponseLuaScript(authPolicy)))
+
+	requestHandle := buildStreamHandle(t, L, requestHeaders, "")
+	require.NoError(t, L.CallByParam(lua.P{Fn: L.GetGlobal("envoy_on_request"), NRet: 0, Protect: true}, requestHandle))
+
+	responseHandle := buildStreamHandle(t, L, map[string]string{
+		":status":      status,
+		"content-type": "text/plain",
+	}, body)
+	require.NoError(t, L.CallByParam(lua.P{Fn: L.GetGlobal("envoy_on_response"), NRet: 0, Protect: true}, responseHandle))
+
+	return deniedResponse{
+		headers: readHeaders(t, L, responseHandle),
+		body:    L.GetField(responseHandle, "body_bytes").String(),
+	}
+}
+
+func buildStreamHandle(t *testing.T, L *lua.LState, headers map[string]string, body string) lua.LValue {
+	t.Helper()
+	initial := L.NewTable()
+	for k, v := range headers {
+		L.SetField(initial, k, lua.LString(v))
+	}
+	require.NoError(t, L.CallByParam(
+		lua.P{Fn: L.GetGlobal("make_stream_handle"), NRet: 1, Protect: true},
+		initial, lua.LString(body),
+	))
+	handle := L.Get(-1)
+	L.Pop(1)
+	return handle
+}
+
+func denyResponseAuthPolicy() *v1alpha1.AuthPolicy {
+	authPolicy := defaultAuthPolicy()
+	authPolicy.Spec.DenyResponse = &v1alpha1.DenyResponse{
+		Realm:            helperfunctions.Ptr("api"),
+		Scopes:           []string{"orders.read"},
+		ResourceMetadata: helperfunctions.Ptr("https://api.example.com/.well-known/oauth-protected-resource"),
+	}
+	authPolicy.Spec.AuthRules = &[]v1alpha1.RequestAuthRule{
+		{
+			RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/admin/*"}},
+			DenyResponse: &v1alpha1.DenyResponse{
+				Realm:            helperfunctions.Ptr("admin"),
+				Scopes:           []string{"admin"},
+				ErrorDescription: helperfunctions.Ptr("Administrators only"),
+				ProblemType:      helperfunctions.Ptr("https://errors.example.com/admin-only"),
+			},
+		},
+	}
+	return authPolicy
+}
+
+func parseProblem(t *testing.T, body string) problemDetails {
+	t.Helper()
+	var problem problemDetails
+	require.NoError(t, json.Unmarshal([]byte(body), &problem))
+	return problem
+}
+
+func TestDenyResponse_InvalidToken_RespondsInvalidToken(t *testing.T) {
+	// 1. Arrange
+	authPolicy := denyResponseAuthPolicy()
+
+	// 2. Act
+	response := runDenyResponse(t, authPolicy, map[string]string{
+		":path":         "/orders",
+		":method":       "GET",
+		"authorization": "Bearer expired-token",
+	}, "401", "Jwt is expired")
+
+	// 3. Assert
+	assert.Equal(t, "401", response.headers[":status"])
+	assert.Equal(t, "application/problem+json", response.headers["content-type"])
+	assert.Equal(
+		t,
+		`Bearer realm="api", error="invalid_token", error_description="The access token is invalid: Jwt is expired", `+
+			`scope="orders.read", resource_metadata="https://api.example.com/.well-known/oauth-protected-resource"`,
+		response.headers["www-authenticate"],
+	)
+	problem := parseProblem(t, response.body)
+	assert.Equal(t, problemDetails{
+		Type:   "about:blank",
+		Title:  "Unauthorized",
+		Status: 401,
+		Detail: "The access token is invalid: Jwt is expired",
+	}, problem)
+}
+
+func TestDenyResponse_AccessDeniedWithToken_RespondsInsufficientScope(t *testing.T) {
+	// 1. Arrange
+	authPolicy := denyResponseAuthPolicy()
+
+	// 2. Act
+	response := runDenyResponse(t, authPolicy, map[string]string{
+		":path":         "/orders",
+		":method":       "GET",
+		"authorization": "Bearer valid-token",
+	}, "403", "RBAC: access denied")
+
+	// 3. Assert
+	assert.Equal(t, "403", response.headers[":status"])
+	assert.Contains(t, response.headers["www-authenticate"], `error="insufficient_scope"`)
+	assert.Equal(t, 403, parseProblem(t, response.body).Status)
+}
+
+func TestDenyResponse_AccessDeniedWithoutToken_RespondsUnauthorizedWithoutErrorCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{
+			name:    "no authorization header",
+			headers: map[string]string{":path": "/orders", ":method": "GET"},
+		},
+		{
+			name:    "non-bearer authorization header",
+			headers: map[string]string{":path": "/orders", ":method": "GET", "authorization": "Basic dXNlcjpwdw=="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			authPolicy := denyResponseAuthPolicy()
+
+			// 2. Act
+			response := runDenyResponse(t, authPolicy, tt.headers, "403", "RBAC: access denied")
+
+			// 3. Assert
+			assert.Equal(t, "401", response.headers[":status"])
+			assert.Equal(
+				t,
+				`Bearer realm="api", scope="orders.read", `+
+					`resource_metadata="https://api.example.com/.well-known/oauth-protected-resource"`,
+				response.headers["www-authenticate"],
+			)
+			assert.Equal(t, 401, parseProblem(t, response.body).Status)
+		})
+	}
+}
+
+func TestDenyResponse_TokenInCustomLocation_RespondsInsufficientScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{
+			name:    "custom header",
+			headers: map[string]string{":path": "/orders", ":method": "GET", "x-api-token": "Token valid-token"},
+		},
+		{
+			name:    "custom query parameter",
+			headers: map[string]string{":path": "/orders?jwt=valid-token", ":method": "GET"},
+		},
+		{
+			name:    "default query parameter",
+			headers: map[string]string{":path": "/orders?page=2&access_token=valid-token", ":method": "GET"},
+		},
+		{
+			name:    "cookie",
+			headers: map[string]string{":path": "/orders", ":method": "GET", "cookie": "theme=dark; session=valid-token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			authPolicy := denyResponseAuthPolicy()
+			authPolicy.Spec.FromHeaders = []v1alpha1.JWTHeader{{Name: "X-Api-Token", Prefix: "Token "}}
+			authPolicy.Spec.FromParams = []string{"jwt"}
+			authPolicy.Spec.FromCookies = []string{"session"}
+
+			// 2. Act
+			response := runDenyResponse(t, authPolicy, tt.headers, "403", "RBAC: access denied")
+
+			// 3. Assert
+			assert.Equal(t, "403", response.headers[":status"])
+			assert.Contains(t, response.headers["www-authenticate"], `error="insufficient_scope"`)
+		})
+	}
+}
+
+func TestDenyResponse_AuthRuleOverridesPolicyDenyResponse(t *testing.T) {
+	// 1. Arrange
+	authPolicy := denyResponseAuthPolicy()
+
+	// 2. Act
+	response := runDenyResponse(t, authPolicy, map[string]string{
+		":path":         "/admin/users",
+		":method":       "DELETE",
+		"authorization": "Bearer valid-token",
+	}, "403", "RBAC: access denied")
+
+	// 3. Assert
+	assert.Equal(t, "403", response.headers[":status"])
+	assert.Equal(
+		t,
+		`Bearer realm="admin", error="insufficient_scope", error_description="Administrators only", scope="admin"`,
+		response.headers["www-authenticate"],
+	)
+	assert.Equal(t, problemDetails{
+		Type:   "https://errors.example.com/admin-only",
+		Title:  "Forbidden",
+		Status: 403,
+		Detail: "Administrators only",
+	}, parseProblem(t, response.body))
+}
+
+func TestDenyResponse_OnlyAuthRuleDenyResponse_LeavesOtherRequestsUntouched(t *testing.T) {
+	// 1. Arrange
+	authPolicy := denyResponseAuthPolicy()
+	authPolicy.Spec.DenyResponse = nil
+
+	// 2. Act
+	response := runDenyResponse(t, authPolicy, map[string]string{
+		":path":   "/orders",
+		":method": "GET",
+	}, "403", "RBAC: access denied")
+
+	// 3. Assert
+	assert.Equal(t, "403", response.headers[":status"])
+	assert.Equal(t, "text/plain", response.headers["content-type"])
+	assert.Empty(t, response.headers["www-authenticate"])
+	assert.Equal(t, "RBAC: access denied", response.body)
+}
+
+func TestDenyResponse_LeavesOtherResponsesUntouched(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		body   string
+	}{
+		{name: "successful response", status: "200", body: "ok"},
+		{name: "401 from the application", status: "401", body: "please log in"},
+		{name: "403 from the application", status: "403", body: "forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			authPolicy := denyResponseAuthPolicy()
+
+			// 2. Act
+			response := runDenyResponse(t, authPolicy, map[string]string{
+				":path":   "/orders",
+				":method": "GET",
+			}, tt.status, tt.body)
+
+			// 3. Assert
+			assert.Equal(t, tt.status, response.headers[":status"])
+			assert.Empty(t, response.headers["www-authenticate"])
+			assert.Equal(t, tt.body, response.body)
+		})
+	}
+}
+
+func TestDenyResponse_ErrorDescriptionEscapedInBody(t *testing.T) {
+	// 1. Arrange
+	authPolicy := denyResponseAuthPolicy()
+
+	// 2. Act
+	response := runDenyResponse(t, authPolicy, map[string]string{
+		":path":         "/orders",
+		":method":       "GET",
+		"authorization": "Bearer invalid-token",
+	}, "401", "Jwt verification fails: \"kid\" not found\n")
+
+	// 3. Assert
+	assert.Contains(
+		t,
+		response.headers["www-authenticate"],
+		`error_description="The access token is invalid: Jwt verification fails: 'kid' not found'"`,
+	)
+	assert.Equal(
+		t,
+		"The access token is invalid: Jwt verification fails: \"kid\" not found\n",
+		parseProblem(t, response.body).Detail,
+	)
+}
@@ -0,0 +1,382 @@
+package luascript_test
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/deny"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/ignore"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/require"
+	"github.com/stretchr/testify/assert"
+	testifyrequire "github.com/stretchr/testify/require"
+	"istio.io/api/security/v1beta1"
+	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// luaCase is a request or a response fed to the generated Lua script, along with the expected outcome.
+// Request cases set path and method, and expect the decisions of the script. Response cases set status and location,
+// and expect the location after the script has run.
+type luaCase struct {
+	name    string
+	path    string
+	method  string
+	host    string
+	headers map[string]string
+
+	status   string
+	location string
+
+	wantBypass       bool
+	wantDenyRedirect bool
+	wantLocation     string
+}
+
+// luaHarness runs the Lua script generated for a scope, and evaluates the AuthorizationPolicies generated for the
+// same scope the way Istio does, so that the decisions of both can be compared.
+type luaHarness struct {
+	script       string
+	allowPolicy  *istioclientsecurityv1.AuthorizationPolicy
+	denyPolicy   *istioclientsecurityv1.AuthorizationPolicy
+	ignorePolicy *istioclientsecurityv1.AuthorizationPolicy
+}
+
+func newLuaHarness(scope *state.Scope) *luaHarness {
+	objectMeta := metav1.ObjectMeta{Name: "conformance", Namespace: "default"}
+	return &luaHarness{
+		script:       luascript.GenerateLuaScript(&scope.AuthPolicy, scope.AutoLoginConfig, scope.IdentityProviderUris),
+		allowPolicy:  require.GetDesired(scope, objectMeta),
+		denyPolicy:   deny.GetDesired(scope, objectMeta),
+		ignorePolicy: ignore.GetDesired(scope, objectMeta),
+	}
+}
+
+// run runs every case against the generated Lua script. Every request case is also cross-checked against the
+// AuthorizationPolicies: login must be bypassed exactly for the requests Istio allows without a token, as a request
+// bypassing login would otherwise be denied, and a request not bypassing login would be redirected needlessly.
+func (h *luaHarness) run(t *testing.T, cases []luaCase) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.path != "" {
+				h.assertRequest(t, c)
+			}
+			if c.status != "" {
+				h.assertResponse(t, c)
+			}
+		})
+	}
+}
+
+func (h *luaHarness) assertRequest(t *testing.T, c luaCase) {
+	requestHeaders := map[string]string{":path": c.path, ":method": c.method}
+	if c.host != "" {
+		requestHeaders[":authority"] = c.host
+	}
+	for name, value := range c.headers {
+		requestHeaders[name] = value
+	}
+
+	headers := runOnRequest(t, h.script, requestHeaders)
+	bypass := headers[bypassLoginHeader] == "true"
+	denyRedirect := headers[denyRedirectHeader] == "true"
+	assert.Equal(t, c.wantBypass, bypass, "unexpected login bypass decision")
+	assert.Equal(t, c.wantDenyRedirect, denyRedirect, "unexpected deny redirect decision")
+
+	allowedWithoutToken := h.istioAllowsWithoutToken(t, c)
+	assert.Equal(
+		t,
+		allowedWithoutToken,
+		bypass,
+		"Lua bypasses login for %s %s, while the AuthorizationPolicies allow it without a token: %t",
+		c.method,
+		c.path,
+		allowedWithoutToken,
+	)
+	if denyRedirect {
+		assert.False(t, allowedWithoutToken, "Lua denies redirect for a request allowed without a token")
+	}
+}
+
+func (h *luaHarness) assertResponse(t *testing.T, c luaCase) {
+	headers := runOnResponse(t, h.script, map[string]string{":status": c.status, "location": c.location})
+	assertSameLocation(t, c.wantLocation, headers["location"])
+}
+
+// assertSameLocation compares two locations, ignoring the order of the query parameters, as the Lua script rebuilds
+// the query string from an unordered table.
+func assertSameLocation(t *testing.T, expected string, actual string) {
+	t.Helper()
+	expectedURL, err := url.Parse(expected)
+	testifyrequire.NoError(t, err)
+	actualURL, err := url.Parse(actual)
+	testifyrequire.NoError(t, err)
+	assert.Equal(t, expectedURL.Query(), actualURL.Query(), "unexpected query of location %s", actual)
+
+	expectedURL.RawQuery, actualURL.RawQuery = "", ""
+	assert.Equal(t, expectedURL.String(), actualURL.String(), "unexpected location %s", actual)
+}
+
+// istioAllowsWithoutToken evaluates the generated AuthorizationPolicies for the request of the case without a token.
+// As in Istio, a request is allowed if no deny rule matches and any allow rule matches.
+func (h *luaHarness) istioAllowsWithoutToken(t *testing.T, c luaCase) bool {
+	denied := h.denyPolicy != nil && anyIstioRuleMatches(t, h.denyPolicy.Spec.Rules, c)
+	allowed := (h.allowPolicy != nil && anyIstioRuleMatches(t, h.allowPolicy.Spec.Rules, c)) ||
+		(h.ignorePolicy != nil && anyIstioRuleMatches(t, h.ignorePolicy.Spec.Rules, c))
+	return !denied && allowed
+}
+
+// The functions below mimic how Istio evaluates rules of an AuthorizationPolicy for a request without a token.
+
+func anyIstioRuleMatches(t *testing.T, rules []*v1beta1.Rule, c luaCase) bool {
+	return slices.ContainsFunc(rules, func(rule *v1beta1.Rule) bool {
+		testifyrequire.Empty(t, rule.From, "sources are not supported by the harness")
+		if len(rule.To) > 0 && !slices.ContainsFunc(rule.To, func(to *v1beta1.Rule_To) bool {
+			return istioOperationMatches(to.Operation, c)
+		}) {
+			return false
+		}
+		return !slices.ContainsFunc(rule.When, func(condition *v1beta1.Condition) bool {
+			return !istioConditionMatches(t, condition, c)
+		})
+	})
+}
+
+// istioOperationMatches matches the operation of a rule, where paths are matched without the query string like Istio
+// does.
+func istioOperationMatches(operation *v1beta1.Operation, c luaCase) bool {
+	path, _, _ := strings.Cut(c.path, "?")
+	host := strings.ToLower(c.host)
+	return matchesAnyIstioPathOrEmpty(operation.Paths, path) &&
+		!slices.ContainsFunc(operation.NotPaths, func(p string) bool { return istioPathMatch(p, path) }) &&
+		matchesAnyIstioValueOrEmpty(operation.Methods, c.method) &&
+		!matchesAnyIstioValue(operation.NotMethods, c.method) &&
+		matchesAnyIstioValueOrEmpty(lowercaseAll(operation.Hosts), host) &&
+		!matchesAnyIstioValue(lowercaseAll(operation.NotHosts), host)
+}
+
+// istioConditionMatches evaluates a condition for a request without a token, which has no claims.
+func istioConditionMatches(t *testing.T, condition *v1beta1.Condition, c luaCase) bool {
+	var values []string
+	if header, found := strings.CutPrefix(condition.Key, "request.headers["); found {
+		if value, present := c.headers[strings.TrimSuffix(header, "]")]; present {
+			values = []string{value}
+		}
+	} else if !strings.HasPrefix(condition.Key, "request.auth.claims[") {
+		testifyrequire.Fail(t, fmt.Sprintf("unsupported condition key %s", condition.Key))
+	}
+
+	matchesValue := func(patterns []string) bool {
+		return slices.ContainsFunc(values, func(v string) bool { return matchesAnyIstioValue(patterns, v) })
+	}
+	if len(condition.Values) > 0 && !matchesValue(condition.Values) {
+		return false
+	}
+	if len(condition.NotValues) > 0 && matchesValue(condition.NotValues) {
+		return false
+	}
+	return true
+}
+
+func matchesAnyIstioPathOrEmpty(patterns []string, path string) bool {
+	return len(patterns) == 0 || slices.ContainsFunc(patterns, func(p string) bool { return istioPathMatch(p, path) })
+}
+
+// istioPathMatch matches a path against an Istio path, where {*} matches a single path segment and {**} matches any
+// remainder of the path. Paths without templates are matched as any other Istio string.
+func istioPathMatch(pattern string, path string) bool {
+	if !strings.Contains(pattern, "{") {
+		return istioValueMatch(pattern, path)
+	}
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.ReplaceAll(expression, regexp.QuoteMeta("{**}"), ".*")
+	expression = strings.ReplaceAll(expression, regexp.QuoteMeta("{*}"), "[^/]+")
+	return regexp.MustCompile("^" + expression + "$").MatchString(path)
+}
+
+func matchesAnyIstioValueOrEmpty(patterns []string, value string) bool {
+	return len(patterns) == 0 || matchesAnyIstioValue(patterns, value)
+}
+
+func matchesAnyIstioValue(patterns []string, value string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool { return istioValueMatch(pattern, value) })
+}
+
+func istioValueMatch(pattern string, value string) bool {
+	switch {
+	case pattern == "*":
+		return value != ""
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(value, strings.TrimPrefix(pattern, "*"))
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	default:
+		return pattern == value
+	}
+}
+
+func lowercaseAll(values []string) []string {
+	lowercased := make([]string, 0, len(values))
+	for _, value := range values {
+		lowercased = append(lowercased, strings.ToLower(value))
+	}
+	return lowercased
+}
+
+// conformanceScope returns the scope of an AuthPolicy with auto-login, combining ignore and auth rules with methods,
+// path templates, hosts and headers.
+func conformanceScope() state.Scope {
+	authPolicy := defaultAuthPolicy()
+	authPolicy.Spec.IgnoreAuthRules = &[]v1alpha1.RequestMatcher{
+		{Paths: []string{"/public", "/assets/{**}"}, Methods: []string{"GET"}},
+		{Paths: []string{"/docs/{*}/index.html"}},
+		{Paths: []string{"/health"}, Hosts: []string{"internal.example.com"}},
+		{Paths: []string{"/beta"}, Headers: []v1alpha1.HeaderMatcher{{Name: "x-beta", Values: []string{"enabled"}}}},
+	}
+	authPolicy.Spec.AuthRules = &[]v1alpha1.RequestAuthRule{
+		{RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/assets/private/{**}"}}},
+		{RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/api/{**}"}}, DenyRedirect: helperfunctions.Ptr(true)},
+		{RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/admin"}, Methods: []string{"POST"}}},
+	}
+
+	autoLoginConfig := defaultAutoLoginConfig()
+	autoLoginConfig.LoginParams = map[string]string{"acr_values": "idporten-loa-high", "ui_locales": "nb"}
+	autoLoginConfig.PostLogoutRedirectURI = helperfunctions.Ptr("https://app.example.com/logged-out")
+
+	identityProviderUris := defaultIdpUris()
+	identityProviderUris.IssuerURI = "https://idp.example.com"
+
+	return state.Scope{
+		AuthPolicy:           *authPolicy,
+		Audiences:            []string{"my-client"},
+		AutoLoginConfig:      autoLoginConfig,
+		IdentityProviderUris: identityProviderUris,
+	}
+}
+
+func TestLuaConformance_RequestDecisionsMatchAuthorizationPolicies(t *testing.T) {
+	// 1. Arrange
+	scope := conformanceScope()
+	harness := newLuaHarness(&scope)
+
+	// 2. Act & 3. Assert
+	harness.run(t, []luaCase{
+		{name: "ignored path", path: "/public", method: "GET", wantBypass: true},
+		{name: "ignored path with query", path: "/public?page=2", method: "GET", wantBypass: true},
+		{name: "ignored path with other method", path: "/public", method: "POST"},
+		{name: "ignored path template", path: "/assets/css/site.css", method: "GET", wantBypass: true},
+		{name: "auth rule overriding ignored path template", path: "/assets/private/key.pem", method: "GET"},
+		{name: "ignored single segment template", path: "/docs/v1/index.html", method: "GET", wantBypass: true},
+		{name: "single segment template spanning segments", path: "/docs/v1/beta/index.html", method: "GET"},
+		{name: "ignored path on matching host", path: "/health", method: "GET", host: "Internal.Example.com",
+			wantBypass: true},
+		{name: "ignored path on other host", path: "/health", method: "GET", host: "www.example.com"},
+		{name: "ignored path with matching header", path: "/beta", method: "GET",
+			headers: map[string]string{"x-beta": "enabled"}, wantBypass: true},
+		{name: "ignored path with other header value", path: "/beta", method: "GET",
+			headers: map[string]string{"x-beta": "disabled"}},
+		{name: "ignored path without header", path: "/beta", method: "GET"},
+		{name: "deny redirect path", path: "/api/items", method: "GET", wantDenyRedirect: true},
+		{name: "auth rule path", path: "/admin", method: "POST"},
+		{name: "auth rule path with other method", path: "/admin", method: "GET"},
+		{name: "path in no rule", path: "/unknown", method: "GET"},
+		{name: "login path", path: "/login", method: "GET"},
+		{name: "redirect path", path: "/oauth2/callback", method: "GET"},
+		{name: "logout path", path: "/logout", method: "GET"},
+	})
+}
+
+func TestLuaConformance_ResponseRewrites(t *testing.T) {
+	// 1. Arrange
+	scope := conformanceScope()
+	harness := newLuaHarness(&scope)
+
+	// 2. Act & 3. Assert
+	harness.run(t, []luaCase{
+		{
+			name:     "login params merged into authorize redirect",
+			status:   "302",
+			location: "https://idp.example.com/authorize?client_id=my-client&state=abc",
+			wantLocation: "https://idp.example.com/authorize?client_id=my-client&state=abc" +
+				"&acr_values=idporten-loa-high&ui_locales=nb",
+		},
+		{
+			name:         "login params override params of authorize redirect",
+			status:       "302",
+			location:     "https://idp.example.com/authorize?client_id=my-client&acr_values=low",
+			wantLocation: "https://idp.example.com/authorize?client_id=my-client&acr_values=idporten-loa-high&ui_locales=nb",
+		},
+		{
+			name:         "authorize location of other status left as is",
+			status:       "200",
+			location:     "https://idp.example.com/authorize?client_id=my-client",
+			wantLocation: "https://idp.example.com/authorize?client_id=my-client",
+		},
+		{
+			name:     "post logout redirect uri added to end session redirect",
+			status:   "302",
+			location: "https://idp.example.com/endsession?id_token_hint=token",
+			wantLocation: "https://idp.example.com/endsession?id_token_hint=token&post_logout_redirect_uri=" +
+				url.QueryEscape("https://app.example.com/logged-out"),
+		},
+		{
+			name:     "post logout redirect uri replaced in end session redirect",
+			status:   "302",
+			location: "https://idp.example.com/endsession?id_token_hint=token&post_logout_redirect_uri=https%3A%2F%2Fevil.com",
+			wantLocation: "https://idp.example.com/endsession?id_token_hint=token&post_logout_redirect_uri=" +
+				url.QueryEscape("https://app.example.com/logged-out"),
+		},
+		{
+			name:         "unrelated redirect left as is",
+			status:       "302",
+			location:     "https://app.example.com/next?return_to=/a?b=c",
+			wantLocation: "https://app.example.com/next?return_to=/a?b=c",
+		},
+	})
+}
+
+func TestLuaConformance_ResponseRewritesWithoutEndSessionEndpoint(t *testing.T) {
+	// 1. Arrange
+	scope := conformanceScope()
+	scope.IdentityProviderUris.EndSessionURI = nil
+	harness := newLuaHarness(&scope)
+
+	// 2. Act & 3. Assert
+	harness.run(t, []luaCase{
+		{
+			name:         "unrelated redirect left as is",
+			status:       "302",
+			location:     "https://app.example.com/next?return_to=/a?b=c",
+			wantLocation: "https://app.example.com/next?return_to=/a?b=c",
+		},
+		{
+			name:         "login params still merged into authorize redirect",
+			status:       "302",
+			location:     "https://idp.example.com/authorize?client_id=my-client",
+			wantLocation: "https://idp.example.com/authorize?client_id=my-client&acr_values=idporten-loa-high&ui_locales=nb",
+		},
+	})
+}
+
+func TestLuaConformance_WithoutIgnoreAuthRulesNothingBypassesLogin(t *testing.T) {
+	// 1. Arrange
+	scope := conformanceScope()
+	scope.AuthPolicy.Spec.IgnoreAuthRules = nil
+	scope.AuthPolicy.Spec.AuthRules = nil
+	harness := newLuaHarness(&scope)
+
+	// 2. Act & 3. Assert
+	harness.run(t, []luaCase{
+		{name: "root", path: "/", method: "GET"},
+		{name: "formerly ignored path", path: "/public", method: "GET"},
+		{name: "formerly denied redirect path", path: "/api/items", method: "GET"},
+	})
+}
@@ -1,7 +1,9 @@
 package luascript
 
 import (
+	"crypto/rand"
 	_ "embed"
+	"encoding/hex"
 	"fmt"
 	"net/url"
 
@@ -10,13 +12,48 @@ import (
 )
 
 const (
-	BypassOauthLoginHeaderName = "x-bypass-login"
-	DenyRedirectHeaderName     = "x-deny-redirect"
+	// ReservedHeaderPrefix prefixes every header used internally by the generated EnvoyFilters.
+	ReservedHeaderPrefix         = "x-ztoperator-"
+	BypassOauthLoginHeaderPrefix = ReservedHeaderPrefix + "bypass-login-"
+	DenyRedirectHeaderPrefix     = ReservedHeaderPrefix + "deny-redirect-"
+
+	// SignalHeaderSuffixSecretKey is the key of the generated Secret of the AuthPolicy holding the suffix of its
+	// signal header names, so that the suffix stays the same across reconciles.
+	SignalHeaderSuffixSecretKey = "signal-header-suffix"
 )
 
+// BypassOauthLoginHeaderName returns the name of the header by which the Lua filter tells the OAuth2 filter of the
+// AuthPolicy to let a request through without a login redirect.
+func BypassOauthLoginHeaderName(signalHeaderSuffix string) string {
+	return BypassOauthLoginHeaderPrefix + signalHeaderSuffix
+}
+
+// DenyRedirectHeaderName returns the name of the header by which the Lua filter tells the OAuth2 filter of the
+// AuthPolicy to deny a request instead of redirecting it to the identity provider.
+func DenyRedirectHeaderName(signalHeaderSuffix string) string {
+	return DenyRedirectHeaderPrefix + signalHeaderSuffix
+}
+
+// GenerateSignalHeaderSuffix returns a random suffix for the signal header names of an AuthPolicy, which keeps the
+// names of different AuthPolicies apart. Clients cannot forge the signal headers regardless of their names, as the Lua
+// filter strips any copies of them before setting them.
+func GenerateSignalHeaderSuffix() (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate signal header suffix: %w", err)
+	}
+	return hex.EncodeToString(suffix), nil
+}
+
 //go:embed ztoperator.lua
 var luaScriptTemplate string
 
+// requestMatcherLuaScript defines the local function match(rules, p, m, host, headers), evaluating a request against
+// rules produced by ConvertRequestMatchersToLuaTableString. It is shared by the generated Lua scripts.
+//
+//go:embed request_matcher.lua
+var requestMatcherLuaScript string
+
 // GenerateLuaScript produces the Lua source code that is embedded as an inline
 // Envoy Lua filter inside the generated EnvoyFilter resource.
 //
@@ -24,17 +61,20 @@ var luaScriptTemplate string
 // and response, and acts as a pre-processing layer for the Envoy OAuth2 filter
 // that sits immediately after it in the filter chain:
 //
-//   - On request: the script evaluates the request path and method against the
-//     configured ignore, require, and deny-redirect rules, then sets two
-//     synthetic headers that the OAuth2 filter reads to decide how to handle
-//     the request:
+//   - On request: the script strips any copies of its signal headers sent by
+//     the client, which is what keeps clients from forging them, evaluates the
+//     request path and method against the configured ignore, require, and
+//     deny-redirect rules, then sets two synthetic headers that the OAuth2
+//     filter reads to decide how to handle the request. Their names end with
+//     the signal header suffix of the AuthPolicy, see
+//     BypassOauthLoginHeaderName and DenyRedirectHeaderName:
 //
-//   - x-bypass-login: "true"  — the OAuth2 filter lets the request through
-//     without requiring authentication (used for public paths).
+//   - x-ztoperator-bypass-login-<suffix>: "true"  — the OAuth2 filter lets the
+//     request through without requiring authentication (used for public paths).
 //
-//   - x-deny-redirect: "true" — the OAuth2 filter returns a 401 instead of
-//     redirecting to the IdP (used for API paths where a browser redirect
-//     would be inappropriate).
+//   - x-ztoperator-deny-redirect-<suffix>: "true" — the OAuth2 filter returns a
+//     401 instead of redirecting to the IdP (used for API paths where a browser
+//     redirect would be inappropriate).
 //
 //   - On response: the script intercepts 302 redirects produced by the OAuth2
 //     filter and rewrites the Location header:
@@ -75,8 +115,10 @@ func GenerateLuaScript(
 		endSessionURI = ""
 	}
 
-	return fmt.Sprintf(
+	return requestMatcherLuaScript + "\n" + fmt.Sprintf(
 		luaScriptTemplate,
+		BypassOauthLoginHeaderName(autoLoginConfig.SignalHeaderSuffix),
+		DenyRedirectHeaderName(autoLoginConfig.SignalHeaderSuffix),
 		ignoreRulesLua,
 		requireRulesLua,
 		denyRedirectRulesLua,
@@ -84,8 +126,6 @@ func GenerateLuaScript(
 		loginParamsAsLua,
 		EscapeLuaString(endSessionURI),
 		EscapeLuaString(queryEscapedPostLogoutRedirectURI),
-		BypassOauthLoginHeaderName,
-		DenyRedirectHeaderName,
 	)
 }
 
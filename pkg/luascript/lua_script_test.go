@@ -19,6 +19,7 @@ import (
 //	handle:headers():get(key)
 //	handle:headers():add(key, val)
 //	handle:headers():replace(key, val)
+//	handle:headers():remove(key)
 //	handle:logCritical(msg)
 //
 // After calling envoy_on_request / envoy_on_response the test reads results
@@ -32,6 +33,7 @@ function make_handle(initial_headers)
         get     = function(_, k) return hdrs[k] end,
         add     = function(_, k, v) hdrs[k] = v end,
         replace = function(_, k, v) hdrs[k] = v end,
+        remove  = function(_, k) hdrs[k] = nil end,
     }
     return {
         hdrs        = hdrs,
@@ -97,12 +99,22 @@ func readHeaders(t *testing.T, L *lua.LState, handle lua.LValue) map[string]stri
 	return result
 }
 
+// testSignalHeaderSuffix is the signal header suffix of defaultAutoLoginConfig.
+const testSignalHeaderSuffix = "6f1c2b1e3d4a4c5b"
+
+// bypassLoginHeader and denyRedirectHeader are the signal headers of every script generated with
+// defaultAutoLoginConfig.
+var (
+	bypassLoginHeader  = luascript.BypassOauthLoginHeaderName(testSignalHeaderSuffix)
+	denyRedirectHeader = luascript.DenyRedirectHeaderName(testSignalHeaderSuffix)
+)
+
 func defaultAuthPolicy() *v1alpha1.AuthPolicy {
 	return &v1alpha1.AuthPolicy{
 		Spec: v1alpha1.AuthPolicySpec{
 			Enabled:      true,
 			WellKnownURI: "https://idp.example.com/.well-known/openid-configuration",
-			Selector:     v1alpha1.WorkloadSelector{MatchLabels: map[string]string{"app": "test"}},
+			Selector:     &v1alpha1.WorkloadSelector{MatchLabels: map[string]string{"app": "test"}},
 			IgnoreAuthRules: &[]v1alpha1.RequestMatcher{
 				{Paths: []string{"/public"}, Methods: []string{"GET"}},
 			},
@@ -122,10 +134,11 @@ func defaultAuthPolicy() *v1alpha1.AuthPolicy {
 
 func defaultAutoLoginConfig() state.AutoLoginConfig {
 	return state.AutoLoginConfig{
-		Enabled:      true,
-		LoginPath:    helperfunctions.Ptr("/login"),
-		RedirectPath: "/oauth2/callback",
-		LogoutPath:   "/logout",
+		Enabled:            true,
+		LoginPath:          helperfunctions.Ptr("/login"),
+		RedirectPath:       "/oauth2/callback",
+		LogoutPath:         "/logout",
+		SignalHeaderSuffix: testSignalHeaderSuffix,
 	}
 }
 
@@ -144,16 +157,16 @@ func TestGeneratedLuaScript_OnRequest_IgnoreAuthRules_BypassAsExpected(t *testin
 		":method": "GET",     // ignored path in defaultAuthPolicy
 	})
 
-	assert.Equal(t, "true", ignoredMethodHandle[luascript.BypassOauthLoginHeaderName])
-	assert.Equal(t, "false", ignoredMethodHandle[luascript.DenyRedirectHeaderName])
+	assert.Equal(t, "true", ignoredMethodHandle[bypassLoginHeader])
+	assert.Equal(t, "false", ignoredMethodHandle[denyRedirectHeader])
 
 	nonIgnoredMethodHandle := runOnRequest(t, script, map[string]string{
 		":path":   "/public", // ignored path in defaultAuthPolicy
 		":method": "POST",    // not ignored method in defaultAuthPolicy
 	})
 
-	assert.Equal(t, "false", nonIgnoredMethodHandle[luascript.BypassOauthLoginHeaderName])
-	assert.Equal(t, "false", nonIgnoredMethodHandle[luascript.DenyRedirectHeaderName])
+	assert.Equal(t, "false", nonIgnoredMethodHandle[bypassLoginHeader])
+	assert.Equal(t, "false", nonIgnoredMethodHandle[denyRedirectHeader])
 }
 
 func TestGeneratedLuaScript_OnRequest_AuthRules_DoNotBypass(t *testing.T) {
@@ -164,16 +177,16 @@ func TestGeneratedLuaScript_OnRequest_AuthRules_DoNotBypass(t *testing.T) {
 		":method": "GET",
 	})
 
-	assert.Equal(t, "false", authRuleHandle[luascript.BypassOauthLoginHeaderName])
-	assert.Equal(t, "false", authRuleHandle[luascript.DenyRedirectHeaderName])
+	assert.Equal(t, "false", authRuleHandle[bypassLoginHeader])
+	assert.Equal(t, "false", authRuleHandle[denyRedirectHeader])
 
 	noRuleHandle := runOnRequest(t, script, map[string]string{
 		":path":   "/noRulesHere",
 		":method": "GET",
 	})
 
-	assert.Equal(t, "false", noRuleHandle[luascript.BypassOauthLoginHeaderName])
-	assert.Equal(t, "false", noRuleHandle[luascript.DenyRedirectHeaderName])
+	assert.Equal(t, "false", noRuleHandle[bypassLoginHeader])
+	assert.Equal(t, "false", noRuleHandle[denyRedirectHeader])
 }
 
 func TestGeneratedLuaScript_OnRequest_AutoLoginPaths_DoNotBypass(t *testing.T) {
@@ -188,8 +201,8 @@ func TestGeneratedLuaScript_OnRequest_AutoLoginPaths_DoNotBypass(t *testing.T) {
 				":method": "GET",
 			})
 
-			assert.Equal(t, "false", handle[luascript.BypassOauthLoginHeaderName])
-			assert.Equal(t, "false", handle[luascript.DenyRedirectHeaderName])
+			assert.Equal(t, "false", handle[bypassLoginHeader])
+			assert.Equal(t, "false", handle[denyRedirectHeader])
 		})
 	}
 }
@@ -202,7 +215,7 @@ func TestGeneratedLuaScript_OnRequest_QueryStringStrippedBeforeMatching(t *testi
 		":method": "GET",             // ignored path in defaultAuthPolicy
 	})
 
-	assert.Equal(t, "true", handle[luascript.BypassOauthLoginHeaderName])
+	assert.Equal(t, "true", handle[bypassLoginHeader])
 }
 
 func TestGeneratedLuaScript_OnRequest_DenyRedirectDoesNotRedirect(t *testing.T) {
@@ -220,8 +233,107 @@ func TestGeneratedLuaScript_OnRequest_DenyRedirectDoesNotRedirect(t *testing.T)
 		":method": "GET",
 	})
 
-	assert.Equal(t, "false", handle[luascript.BypassOauthLoginHeaderName])
-	assert.Equal(t, "true", handle[luascript.DenyRedirectHeaderName])
+	assert.Equal(t, "false", handle[bypassLoginHeader])
+	assert.Equal(t, "true", handle[denyRedirectHeader])
+}
+
+func TestGeneratedLuaScript_OnRequest_IgnoreAuthRulesWithHosts_BypassOnlyMatchingHosts(t *testing.T) {
+	policy := defaultAuthPolicy()
+	policy.Spec.IgnoreAuthRules = &[]v1alpha1.RequestMatcher{
+		{Paths: []string{"/public"}, Hosts: []string{"www.example.com", "*.cdn.example.com"}},
+		{Paths: []string{"/docs"}, NotHosts: []string{"internal.*"}},
+	}
+	script := luascript.GenerateLuaScript(policy, defaultAutoLoginConfig(), defaultIdpUris())
+
+	testCases := []struct {
+		path     string
+		host     string
+		expected string
+	}{
+		{path: "/public", host: "www.example.com", expected: "true"},
+		{path: "/public", host: "WWW.Example.com", expected: "true"},
+		{path: "/public", host: "eu.cdn.example.com", expected: "true"},
+		{path: "/public", host: "api.example.com", expected: "false"},
+		{path: "/public", host: "", expected: "false"},
+		{path: "/docs", host: "www.example.com", expected: "true"},
+		{path: "/docs", host: "internal.example.com", expected: "false"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path+" "+tc.host, func(t *testing.T) {
+			handle := runOnRequest(t, script, map[string]string{
+				":path":      tc.path,
+				":method":    "GET",
+				":authority": tc.host,
+			})
+
+			assert.Equal(t, tc.expected, handle[bypassLoginHeader])
+		})
+	}
+}
+
+func TestGeneratedLuaScript_OnRequest_RulesWithHeaders_MatchOnlyMatchingHeaders(t *testing.T) {
+	policy := defaultAuthPolicy()
+	policy.Spec.IgnoreAuthRules = &[]v1alpha1.RequestMatcher{
+		{
+			Paths:   []string{"/api"},
+			Headers: []v1alpha1.HeaderMatcher{{Name: "X-Api-Key", Values: []string{"*"}}},
+		},
+	}
+	policy.Spec.AuthRules = &[]v1alpha1.RequestAuthRule{
+		{
+			RequestMatcher: v1alpha1.RequestMatcher{
+				Paths:   []string{"/api"},
+				Headers: []v1alpha1.HeaderMatcher{{Name: "Accept", NotValues: []string{"text/html*"}}},
+			},
+			DenyRedirect: helperfunctions.Ptr(true),
+		},
+	}
+	script := luascript.GenerateLuaScript(policy, defaultAutoLoginConfig(), defaultIdpUris())
+
+	testCases := []struct {
+		name                 string
+		headers              map[string]string
+		expectedBypass       string
+		expectedDenyRedirect string
+	}{
+		{
+			name:                 "api client with key",
+			headers:              map[string]string{"x-api-key": "secret", "accept": "text/html"},
+			expectedBypass:       "true",
+			expectedDenyRedirect: "false",
+		},
+		{
+			name:                 "api client without key",
+			headers:              map[string]string{"accept": "application/json"},
+			expectedBypass:       "false",
+			expectedDenyRedirect: "true",
+		},
+		{
+			name:                 "browser",
+			headers:              map[string]string{"accept": "text/html,application/xhtml+xml"},
+			expectedBypass:       "false",
+			expectedDenyRedirect: "false",
+		},
+		{
+			name:                 "client without accept header",
+			headers:              map[string]string{},
+			expectedBypass:       "false",
+			expectedDenyRedirect: "true",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requestHeaders := map[string]string{":path": "/api", ":method": "GET"}
+			for name, value := range tc.headers {
+				requestHeaders[name] = value
+			}
+
+			handle := runOnRequest(t, script, requestHeaders)
+
+			assert.Equal(t, tc.expectedBypass, handle[bypassLoginHeader])
+			assert.Equal(t, tc.expectedDenyRedirect, handle[denyRedirectHeader])
+		})
+	}
 }
 
 func TestGeneratedLuaScript_OnResponse_DoesNotRedirectNon302Responses(t *testing.T) {
@@ -296,7 +408,7 @@ func TestGeneratedLuaScript_OnRequest_SingleSegmentWildcard_MatchesOneSegment(t
 	script := luascript.GenerateLuaScript(policy, defaultAutoLoginConfig(), defaultIdpUris())
 
 	handle := runOnRequest(t, script, map[string]string{":path": "/api/v1/items", ":method": "GET"})
-	assert.Equal(t, "true", handle[luascript.BypassOauthLoginHeaderName], "/api/v1/items should be public")
+	assert.Equal(t, "true", handle[bypassLoginHeader], "/api/v1/items should be public")
 
 	invalidPaths := []string{
 		"/api/v1/extra/items",
@@ -305,7 +417,7 @@ func TestGeneratedLuaScript_OnRequest_SingleSegmentWildcard_MatchesOneSegment(t
 	for _, path := range invalidPaths {
 		t.Run(path, func(t *testing.T) {
 			handle = runOnRequest(t, script, map[string]string{":path": path, ":method": "GET"})
-			assert.Equal(t, "false", handle[luascript.BypassOauthLoginHeaderName], "%s should not match {*}", path)
+			assert.Equal(t, "false", handle[bypassLoginHeader], "%s should not match {*}", path)
 		})
 	}
 }
@@ -327,7 +439,7 @@ func TestGeneratedLuaScript_OnRequest_DoubleStarWildcard_MatchesMultipleSegments
 	for _, path := range validPaths {
 		t.Run(path, func(t *testing.T) {
 			handle := runOnRequest(t, script, map[string]string{":path": path, ":method": "GET"})
-			assert.Equal(t, "true", handle[luascript.BypassOauthLoginHeaderName], "%s should match /api/{**}", path)
+			assert.Equal(t, "true", handle[bypassLoginHeader], "%s should match /api/{**}", path)
 		})
 	}
 }
@@ -350,7 +462,7 @@ func TestGeneratedLuaScript_OnRequest_LegacyStarWildcard_MatchesMultipleSegments
 	for _, path := range validPaths {
 		t.Run(path, func(t *testing.T) {
 			handle := runOnRequest(t, script, map[string]string{":path": path, ":method": "GET"})
-			assert.Equal(t, "true", handle[luascript.BypassOauthLoginHeaderName], "%s should match /api/{**}", path)
+			assert.Equal(t, "true", handle[bypassLoginHeader], "%s should match /api/{**}", path)
 		})
 	}
 }
@@ -0,0 +1,63 @@
+-- returns true when value matches the expected value, where a leading or trailing "*"
+-- denotes a suffix or prefix match, and "*" alone matches any non-empty value
+local function match_value(expected, value)
+    if value == nil or value == "" then
+        return false
+    end
+    if expected == "*" then
+        return true
+    end
+    if string.sub(expected, 1, 1) == "*" then
+        local suffix = string.sub(expected, 2)
+        return string.sub(value, -#suffix) == suffix
+    end
+    if string.sub(expected, -1) == "*" then
+        local prefix = string.sub(expected, 1, -2)
+        return string.sub(value, 1, #prefix) == prefix
+    end
+    return value == expected
+end
+
+-- returns true when value matches any of the expected values
+local function match_any(expected_values, value)
+    for _, expected in ipairs(expected_values) do
+        if match_value(expected, value) then
+            return true
+        end
+    end
+    return false
+end
+
+-- returns true when the host and headers meet the host and header conditions of the rule
+local function match_host_and_headers(rule, host, headers)
+    -- absent "hosts" == all hosts
+    if rule.hosts ~= nil and not match_any(rule.hosts, host) then
+        return false
+    end
+    if rule.not_hosts ~= nil and match_any(rule.not_hosts, host) then
+        return false
+    end
+    for _, header in ipairs(rule.headers or {}) do
+        local value = headers:get(header.name)
+        if header.values ~= nil and not match_any(header.values, value) then
+            return false
+        end
+        if header.not_values ~= nil and match_any(header.not_values, value) then
+            return false
+        end
+    end
+    return true
+end
+
+-- returns true when {p,m,host,headers} matches any rule in the supplied table
+local function match(rules, p, m, host, headers)
+    for _, rule in ipairs(rules) do
+        if string.match(p, rule.regex) then
+        -- empty "methods" table == all methods
+            if (next(rule.methods) == nil or rule.methods[m]) and match_host_and_headers(rule, host, headers) then
+                return true
+            end
+        end
+    end
+    return false
+end
@@ -12,6 +12,11 @@ import (
 //	{regex="^/some%-path$",methods={["GET"]=true, ...}}
 //
 // An empty methods slice means all HTTP methods are permitted.
+// Hosts and header conditions are only included when set, e.g.
+//
+//	{regex="^/api$",methods={},hosts={"api.example.com"},headers={{name="accept",values={"application/json"}}}}
+//
+// Hosts and header names are lowercased, as they are matched case-insensitively.
 func ConvertRequestMatchersToLuaTableString(requestMatchers []v1alpha1.RequestMatcher) string {
 	var sb strings.Builder
 	sb.WriteString("{")
@@ -37,9 +42,60 @@ func ConvertRequestMatchersToLuaTableString(requestMatchers []v1alpha1.RequestMa
 					sb.WriteString(`"]=true`)
 				}
 			}
-			sb.WriteString("}}")
+			sb.WriteString("}")
+			writeHostsAndHeaders(&sb, matcher)
+			sb.WriteString("}")
 		}
 	}
 	sb.WriteString("}")
 	return sb.String()
 }
+
+func writeHostsAndHeaders(sb *strings.Builder, matcher v1alpha1.RequestMatcher) {
+	if len(matcher.Hosts) > 0 {
+		sb.WriteString(",hosts=")
+		writeLuaStringList(sb, matcher.Hosts, strings.ToLower)
+	}
+	if len(matcher.NotHosts) > 0 {
+		sb.WriteString(",not_hosts=")
+		writeLuaStringList(sb, matcher.NotHosts, strings.ToLower)
+	}
+	if len(matcher.Headers) == 0 {
+		return
+	}
+	sb.WriteString(",headers={")
+	for idx, header := range matcher.Headers {
+		if idx > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(`{name="`)
+		sb.WriteString(EscapeLuaString(strings.ToLower(header.Name)))
+		sb.WriteString(`"`)
+		if len(header.Values) > 0 {
+			sb.WriteString(",values=")
+			writeLuaStringList(sb, header.Values, nil)
+		}
+		if len(header.NotValues) > 0 {
+			sb.WriteString(",not_values=")
+			writeLuaStringList(sb, header.NotValues, nil)
+		}
+		sb.WriteString("}")
+	}
+	sb.WriteString("}")
+}
+
+func writeLuaStringList(sb *strings.Builder, values []string, transform func(string) string) {
+	sb.WriteString("{")
+	for idx, value := range values {
+		if idx > 0 {
+			sb.WriteString(",")
+		}
+		if transform != nil {
+			value = transform(value)
+		}
+		sb.WriteString(`"`)
+		sb.WriteString(EscapeLuaString(value))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString("}")
+}
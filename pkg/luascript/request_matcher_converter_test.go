@@ -120,4 +120,41 @@ func TestConvertRequestMatchersToLuaTableString(t *testing.T) {
 		assert.Contains(t, result, `\"`)
 		assert.NotContains(t, result, `os.execute("evil")`)
 	})
+
+	t.Run("hosts and not hosts are lowercased", func(t *testing.T) {
+		matcher := v1alpha1.RequestMatcher{
+			Paths:    []string{"/api"},
+			Hosts:    []string{"API.example.com", "*.example.org"},
+			NotHosts: []string{"internal.*"},
+		}
+		expected := `{{regex="^/api$",methods={},hosts={"api.example.com","*.example.org"},not_hosts={"internal.*"}}}`
+
+		result := luascript.ConvertRequestMatchersToLuaTableString([]v1alpha1.RequestMatcher{matcher})
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("header conditions", func(t *testing.T) {
+		matcher := v1alpha1.RequestMatcher{
+			Paths: []string{"/api"},
+			Headers: []v1alpha1.HeaderMatcher{
+				{Name: "Accept", Values: []string{"application/json*"}},
+				{Name: "X-Requested-With", NotValues: []string{"*"}},
+			},
+		}
+		expected := `{{regex="^/api$",methods={},headers={{name="accept",values={"application/json*"}},` +
+			`{name="x-requested-with",not_values={"*"}}}}}`
+
+		result := luascript.ConvertRequestMatchersToLuaTableString([]v1alpha1.RequestMatcher{matcher})
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("header value with double quote is escaped to prevent Lua injection", func(t *testing.T) {
+		matcher := v1alpha1.RequestMatcher{
+			Paths:   []string{"/api"},
+			Headers: []v1alpha1.HeaderMatcher{{Name: "accept", Values: []string{`json"} os.execute("evil") --`}}},
+		}
+
+		result := luascript.ConvertRequestMatchersToLuaTableString([]v1alpha1.RequestMatcher{matcher})
+		assert.NotContains(t, result, `os.execute("evil")`)
+	})
 }
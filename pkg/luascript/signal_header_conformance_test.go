@@ -0,0 +1,233 @@
+package luascript_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// envoyHandleStub defines make_envoy_handle(initial_headers), returning a handle whose header map follows the
+// semantics of the Envoy Lua filter more closely than make_handle: header names are case-insensitive, a header may
+// have several values, add appends a value, replace and remove drop every existing value, and get joins the values
+// with a comma. The initial headers map each name to a list of values.
+const envoyHandleStub = `
+function make_envoy_handle(initial_headers)
+    local hdrs = {}
+    for k, values in pairs(initial_headers or {}) do
+        hdrs[string.lower(k)] = values
+    end
+
+    local headers_obj = {
+        get = function(_, k)
+            local values = hdrs[string.lower(k)]
+            if values == nil then return nil end
+            return table.concat(values, ",")
+        end,
+        add = function(_, k, v)
+            local key = string.lower(k)
+            hdrs[key] = hdrs[key] or {}
+            table.insert(hdrs[key], v)
+        end,
+        replace = function(_, k, v) hdrs[string.lower(k)] = { v } end,
+        remove  = function(_, k) hdrs[string.lower(k)] = nil end,
+    }
+    return {
+        hdrs        = hdrs,
+        headers     = function(_) return headers_obj end,
+        logCritical = function(_, msg) end,
+    }
+end
+`
+
+// runOnRequestWithEnvoyHeaders runs envoy_on_request of the script against a handle from make_envoy_handle, and
+// returns every value of every header afterwards.
+func runOnRequestWithEnvoyHeaders(
+	t *testing.T,
+	script string,
+	requestHeaders map[string][]string,
+) map[string][]string {
+	t.Helper()
+	L := lua.NewState()
+	defer L.Close()
+
+	require.NoError(t, L.DoString(envoyHandleStub))
+	require.NoError(t, L.DoString(script))
+
+	initial := L.NewTable()
+	for name, values := range requestHeaders {
+		valueTable := L.NewTable()
+		for _, value := range values {
+			valueTable.Append(lua.LString(value))
+		}
+		L.SetField(initial, name, valueTable)
+	}
+	require.NoError(t, L.CallByParam(lua.P{Fn: L.GetGlobal("make_envoy_handle"), NRet: 1, Protect: true}, initial))
+	handle := L.Get(-1)
+	L.Pop(1)
+
+	require.NoError(t, L.CallByParam(lua.P{Fn: L.GetGlobal("envoy_on_request"), NRet: 0, Protect: true}, handle))
+
+	hdrs, ok := L.GetField(handle, "hdrs").(*lua.LTable)
+	require.True(t, ok, "handle.hdrs is not a Lua table")
+	result := make(map[string][]string)
+	hdrs.ForEach(func(name, values lua.LValue) {
+		valueTable, ok := values.(*lua.LTable)
+		require.True(t, ok, "values of header %s is not a Lua table", name)
+		valueTable.ForEach(func(_, value lua.LValue) {
+			result[name.String()] = append(result[name.String()], value.String())
+		})
+	})
+	return result
+}
+
+// matchesAnyHeaderMatcher reports whether the request headers match any of the header matchers of the OAuth2 filter.
+// Like Envoy, the values of a header with several values are joined with a comma before matching.
+func matchesAnyHeaderMatcher(t *testing.T, matchers []interface{}, headers map[string][]string) bool {
+	t.Helper()
+	for _, m := range matchers {
+		matcher, ok := m.(map[string]interface{})
+		require.True(t, ok, "header matcher has wrong type")
+		values, present := headers[matcher["name"].(string)]
+		if !present {
+			continue
+		}
+		value := strings.Join(values, ",")
+		if matcher["present_match"] == true {
+			return true
+		}
+		stringMatch, ok := matcher["string_match"].(map[string]interface{})
+		require.True(t, ok, "header matcher %v has no supported match", matcher)
+		if exact, ok := stringMatch["exact"].(string); ok && value == exact {
+			return true
+		}
+		if prefix, ok := stringMatch["prefix"].(string); ok && strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// oauth2FilterDecision runs the generated Lua script on a request, and evaluates the pass-through and deny-redirect
+// matchers of the OAuth2 filter generated for the same AuthPolicy on the resulting headers.
+func oauth2FilterDecision(
+	t *testing.T,
+	authPolicy *v1alpha1.AuthPolicy,
+	requestHeaders map[string][]string,
+) (passThrough bool, denyRedirect bool, headers map[string][]string) {
+	t.Helper()
+	scope := state.Scope{
+		AuthPolicy:           *authPolicy,
+		AutoLoginConfig:      defaultAutoLoginConfig(),
+		IdentityProviderUris: defaultIdpUris(),
+		OAuthCredentials:     state.OAuthCredentials{ClientID: helperfunctions.Ptr("my-client")},
+	}
+	script := luascript.GenerateLuaScript(authPolicy, scope.AutoLoginConfig, scope.IdentityProviderUris)
+	headers = runOnRequestWithEnvoyHeaders(t, script, requestHeaders)
+
+	oauth2Filter := configpatch.GetOAuthSidecarConfigPatchValue(scope)
+	oauth2Config := oauth2Filter["typed_config"].(map[string]interface{})["config"].(map[string]interface{})
+	passThrough = matchesAnyHeaderMatcher(t, oauth2Config["pass_through_matcher"].([]interface{}), headers)
+	denyRedirect = matchesAnyHeaderMatcher(t, oauth2Config["deny_redirect_matcher"].([]interface{}), headers)
+	return passThrough, denyRedirect, headers
+}
+
+func TestGenerateSignalHeaderSuffix_IsRandom(t *testing.T) {
+	// 1. Arrange
+	suffix, err := luascript.GenerateSignalHeaderSuffix()
+	require.NoError(t, err)
+
+	// 2. Act
+	otherSuffix, otherErr := luascript.GenerateSignalHeaderSuffix()
+
+	// 3. Assert
+	require.NoError(t, otherErr)
+	assert.Regexp(t, "^[0-9a-f]{16}$", suffix)
+	assert.NotEqual(t, suffix, otherSuffix)
+	assert.Equal(t, luascript.BypassOauthLoginHeaderPrefix+suffix, luascript.BypassOauthLoginHeaderName(suffix))
+	assert.Equal(t, luascript.DenyRedirectHeaderPrefix+suffix, luascript.DenyRedirectHeaderName(suffix))
+}
+
+func TestSignalHeaders_ForgedHeadersDoNotBypassLogin(t *testing.T) {
+	testCases := []struct {
+		name           string
+		requestHeaders map[string][]string
+	}{
+		{
+			name:           "forged bypass header",
+			requestHeaders: map[string][]string{bypassLoginHeader: {"true"}},
+		},
+		{
+			name:           "forged bypass header sent twice",
+			requestHeaders: map[string][]string{bypassLoginHeader: {"true", "true"}},
+		},
+		{
+			name:           "forged bypass header in upper case",
+			requestHeaders: map[string][]string{strings.ToUpper(bypassLoginHeader): {"true"}},
+		},
+		{
+			name:           "legacy bypass header",
+			requestHeaders: map[string][]string{"x-bypass-login": {"true"}},
+		},
+		{
+			name:           "bypass header of another AuthPolicy",
+			requestHeaders: map[string][]string{luascript.BypassOauthLoginHeaderPrefix + "0123456789abcdef": {"true"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 1. Arrange
+			tc.requestHeaders[":path"] = []string{"/secure"}
+			tc.requestHeaders[":method"] = []string{"GET"}
+
+			// 2. Act
+			passThrough, _, headers := oauth2FilterDecision(t, defaultAuthPolicy(), tc.requestHeaders)
+
+			// 3. Assert
+			assert.False(t, passThrough, "the OAuth2 filter must not let the request through without login")
+			assert.Equal(t, []string{"false"}, headers[bypassLoginHeader])
+		})
+	}
+}
+
+func TestSignalHeaders_ForgedHeadersDoNotDenyRedirect(t *testing.T) {
+	// 1. Arrange
+	requestHeaders := map[string][]string{
+		":path":            {"/secure"},
+		":method":          {"GET"},
+		denyRedirectHeader: {"true", "true"},
+	}
+
+	// 2. Act
+	_, denyRedirect, headers := oauth2FilterDecision(t, defaultAuthPolicy(), requestHeaders)
+
+	// 3. Assert
+	assert.False(t, denyRedirect, "the OAuth2 filter must redirect the request to login")
+	assert.Equal(t, []string{"false"}, headers[denyRedirectHeader])
+}
+
+func TestSignalHeaders_ForgedHeadersDoNotChangeDecisionOnIgnoredPath(t *testing.T) {
+	// 1. Arrange
+	requestHeaders := map[string][]string{
+		":path":            {"/public"},
+		":method":          {"GET"},
+		bypassLoginHeader:  {"false"},
+		denyRedirectHeader: {"true"},
+	}
+
+	// 2. Act
+	passThrough, denyRedirect, headers := oauth2FilterDecision(t, defaultAuthPolicy(), requestHeaders)
+
+	// 3. Assert
+	assert.True(t, passThrough, "the OAuth2 filter must let requests to ignored paths through")
+	assert.False(t, denyRedirect)
+	assert.Equal(t, []string{"true"}, headers[bypassLoginHeader])
+	assert.Equal(t, []string{"false"}, headers[denyRedirectHeader])
+}
@@ -0,0 +1,139 @@
+-- exchanged tokens are cached per subject token and audience until shortly before they expire
+local cache = {}
+local cache_size = 0
+local max_cache_size = 10000
+local expiry_margin_seconds = 30
+
+-- the client secret is re-read periodically to pick up rotations
+local client_secret = nil
+local client_secret_read_at = 0
+local client_secret_ttl_seconds = 60
+
+local function url_encode(value)
+    return (string.gsub(value, "[^%w%-%._~]", function(c)
+        return string.format("%%%02X", string.byte(c))
+    end))
+end
+
+local function read_client_secret(now)
+    if client_secret ~= nil and now - client_secret_read_at < client_secret_ttl_seconds then
+        return client_secret
+    end
+    local file = io.open(client_secret_path, "r")
+    if file == nil then
+        return client_secret
+    end
+    local content = file:read("*a")
+    file:close()
+    client_secret = (string.gsub(content, "%s+$", ""))
+    client_secret_read_at = now
+    return client_secret
+end
+
+-- returns the destination with the given host, or nil if the host is not a destination
+local function find_destination(host)
+    for _, destination in ipairs(destinations) do
+        for _, destination_host in ipairs(destination.hosts) do
+            if destination_host == host then
+                return destination
+            end
+        end
+    end
+    return nil
+end
+
+local function store(key, token, expires_at, now)
+    if cache[key] == nil then
+        if cache_size >= max_cache_size then
+            for cached_key, entry in pairs(cache) do
+                if entry.expires_at <= now then
+                    cache[cached_key] = nil
+                    cache_size = cache_size - 1
+                end
+            end
+        end
+        if cache_size >= max_cache_size then
+            cache = {}
+            cache_size = 0
+        end
+        cache_size = cache_size + 1
+    end
+    cache[key] = { token = token, expires_at = expires_at }
+end
+
+-- exchanges the subject token at the token endpoint, returning the exchanged token and its lifetime in seconds,
+-- or nil and an error message
+local function exchange(request_handle, subject_token, destination, now)
+    local secret = read_client_secret(now)
+    if secret == nil then
+        return nil, "client secret could not be read from " .. client_secret_path
+    end
+
+    local body = "grant_type=" .. url_encode("urn:ietf:params:oauth:grant-type:token-exchange")
+        .. "&subject_token=" .. url_encode(subject_token)
+        .. "&subject_token_type=" .. url_encode("urn:ietf:params:oauth:token-type:access_token")
+        .. "&requested_token_type=" .. url_encode("urn:ietf:params:oauth:token-type:access_token")
+        .. "&audience=" .. url_encode(destination.audience)
+        .. "&client_id=" .. url_encode(client_id)
+        .. "&client_secret=" .. url_encode(secret)
+    if destination.scope ~= "" then
+        body = body .. "&scope=" .. url_encode(destination.scope)
+    end
+
+    local headers, response_body = request_handle:httpCall(
+        token_endpoint_cluster,
+        {
+            [":method"] = "POST",
+            [":path"] = token_endpoint_path,
+            [":authority"] = token_endpoint_authority,
+            ["content-type"] = "application/x-www-form-urlencoded",
+            ["accept"] = "application/json",
+        },
+        body,
+        5000
+    )
+    local status = headers and headers[":status"]
+    if status ~= "200" or response_body == nil then
+        return nil, "token endpoint responded with status " .. tostring(status)
+    end
+
+    local token = string.match(response_body, '"access_token"%s*:%s*"([^"]+)"')
+    if token == nil then
+        return nil, "token endpoint response did not contain an access_token"
+    end
+    local expires_in = tonumber(string.match(response_body, '"expires_in"%s*:%s*"?(%d+)') or "0")
+    return token, expires_in
+end
+
+function envoy_on_request(request_handle)
+    local host = string.lower(request_handle:headers():get(":authority") or "")
+    host = string.match(host, "^[^:]*")
+    local destination = find_destination(host)
+    if destination == nil then
+        return
+    end
+
+    local authorization = request_handle:headers():get("authorization") or ""
+    local subject_token = string.match(authorization, "^[Bb]earer%s+(%S+)$")
+    if subject_token == nil then
+        return
+    end
+
+    local now = os.time()
+    local key = destination.audience .. " " .. subject_token
+    local entry = cache[key]
+    if entry == nil or entry.expires_at <= now then
+        local token, expires_in_or_error = exchange(request_handle, subject_token, destination, now)
+        if token == nil then
+            request_handle:logErr("Token exchange for host " .. host .. " failed: " .. expires_in_or_error)
+            request_handle:respond({ [":status"] = "401" }, "token exchange failed")
+            return
+        end
+        entry = { token = token, expires_at = now + expires_in_or_error - expiry_margin_seconds }
+        if entry.expires_at > now then
+            store(key, entry.token, entry.expires_at, now)
+        end
+    end
+
+    request_handle:headers():replace("authorization", "Bearer " .. entry.token)
+end
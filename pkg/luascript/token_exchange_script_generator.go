@@ -0,0 +1,68 @@
+package luascript
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+)
+
+//go:embed token_exchange.lua
+var tokenExchangeLuaScript string
+
+// GenerateTokenExchangeLuaScript produces the Lua source code of the Envoy Lua
+// filter exchanging tokens of outbound requests following RFC 8693.
+//
+// The Lua filter runs inside the Envoy sidecar on every outbound HTTP request.
+// When the Host of the request is one of the configured destinations and the
+// request carries a bearer token, the token is exchanged at the token endpoint
+// for a token with the audience of the destination, which replaces the original
+// Authorization header. Exchanged tokens are cached per subject token and
+// audience until shortly before they expire. If the exchange fails, the request
+// is answered with 401 without reaching the destination.
+//
+// The token endpoint is called through the given cluster, authenticating the
+// client with the client secret read from clientSecretPath.
+func GenerateTokenExchangeLuaScript(
+	tokenExchange v1alpha1.TokenExchange,
+	tokenEndpointCluster string,
+	tokenURI string,
+	clientID string,
+	clientSecretPath string,
+) (string, error) {
+	tokenURL, err := helperfunctions.GetParsedURL(tokenURI)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse token endpoint %s: %w", tokenURI, err)
+	}
+	tokenEndpointPath := tokenURL.EscapedPath()
+	if tokenURL.RawQuery != "" {
+		tokenEndpointPath += "?" + tokenURL.RawQuery
+	}
+
+	var builder strings.Builder
+	builder.WriteString("local destinations = {")
+	for i, destination := range tokenExchange.Destinations {
+		if i > 0 {
+			builder.WriteString(",")
+		}
+		builder.WriteString("{hosts={")
+		for j, host := range destination.Hosts {
+			if j > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString("\"" + EscapeLuaString(strings.ToLower(host)) + "\"")
+		}
+		builder.WriteString("},audience=\"" + EscapeLuaString(destination.Audience) + "\"")
+		builder.WriteString(",scope=\"" + EscapeLuaString(strings.Join(destination.Scopes, " ")) + "\"}")
+	}
+	builder.WriteString("}\n")
+	builder.WriteString("local token_endpoint_cluster = \"" + EscapeLuaString(tokenEndpointCluster) + "\"\n")
+	builder.WriteString("local token_endpoint_authority = \"" + EscapeLuaString(tokenURL.Host) + "\"\n")
+	builder.WriteString("local token_endpoint_path = \"" + EscapeLuaString(tokenEndpointPath) + "\"\n")
+	builder.WriteString("local client_id = \"" + EscapeLuaString(clientID) + "\"\n")
+	builder.WriteString("local client_secret_path = \"" + EscapeLuaString(clientSecretPath) + "\"\n")
+	builder.WriteString(tokenExchangeLuaScript)
+	return builder.String(), nil
+}
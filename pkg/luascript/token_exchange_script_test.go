@@ -0,0 +1,238 @@
+package luascript_test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// mockOutboundHandleStub defines make_outbound_handle(initial_headers, token_response), returning a handle which
+// additionally supports httpCall and respond. Calls made to the token endpoint are appended to the global
+// token_calls table, and local replies are stored in handle.response.
+const mockOutboundHandleStub = `
+token_calls = {}
+
+function make_outbound_handle(initial_headers, status, body)
+    local hdrs = {}
+    for k, v in pairs(initial_headers or {}) do hdrs[k] = v end
+
+    local headers_obj = {
+        get     = function(_, k) return hdrs[k] end,
+        add     = function(_, k, v) hdrs[k] = v end,
+        replace = function(_, k, v) hdrs[k] = v end,
+    }
+    local handle = {
+        hdrs    = hdrs,
+        headers = function(_) return headers_obj end,
+        logErr  = function(_, msg) end,
+    }
+    handle.httpCall = function(_, cluster, headers, request_body, timeout)
+        table.insert(token_calls, { cluster = cluster, headers = headers, body = request_body })
+        return { [":status"] = status }, body
+    end
+    handle.respond = function(_, headers, response_body)
+        handle.response = headers[":status"]
+    end
+    return handle
+end
+`
+
+type outboundResult struct {
+	headers  map[string]string
+	response string
+}
+
+type tokenExchangeVM struct {
+	t *testing.T
+	L *lua.LState
+}
+
+func newTokenExchangeVM(t *testing.T) *tokenExchangeVM {
+	t.Helper()
+	clientSecretPath := filepath.Join(t.TempDir(), "client-secret")
+	require.NoError(t, os.WriteFile(clientSecretPath, []byte("s3cr&t\n"), 0o600))
+
+	script, err := luascript.GenerateTokenExchangeLuaScript(
+		v1alpha1.TokenExchange{
+			Enabled: true,
+			Destinations: []v1alpha1.TokenExchangeDestination{
+				{Name: "orders", Hosts: []string{"Orders.Example.com"}, Audience: "orders-api", Scopes: []string{"read", "write"}},
+				{Name: "billing", Hosts: []string{"billing.example.com"}, Audience: "billing-api"},
+			},
+		},
+		"token-exchange",
+		"https://idp.example.com/oauth2/token",
+		"my-client",
+		clientSecretPath,
+	)
+	require.NoError(t, err)
+
+	L := lua.NewState()
+	t.Cleanup(L.Close)
+	require.NoError(t, L.DoString(mockOutboundHandleStub))
+	require.NoError(t, L.DoString(script))
+	return &tokenExchangeVM{t: t, L: L}
+}
+
+func (vm *tokenExchangeVM) onRequest(requestHeaders map[string]string, status string, body string) outboundResult {
+	vm.t.Helper()
+	initial := vm.L.NewTable()
+	for k, v := range requestHeaders {
+		vm.L.SetField(initial, k, lua.LString(v))
+	}
+	require.NoError(vm.t, vm.L.CallByParam(
+		lua.P{Fn: vm.L.GetGlobal("make_outbound_handle"), NRet: 1, Protect: true},
+		initial, lua.LString(status), lua.LString(body),
+	))
+	handle := vm.L.Get(-1)
+	vm.L.Pop(1)
+	require.NoError(vm.t, vm.L.CallByParam(
+		lua.P{Fn: vm.L.GetGlobal("envoy_on_request"), NRet: 0, Protect: true},
+		handle,
+	))
+
+	result := outboundResult{headers: readHeaders(vm.t, vm.L, handle)}
+	if response := vm.L.GetField(handle, "response"); response != lua.LNil {
+		result.response = response.String()
+	}
+	return result
+}
+
+func (vm *tokenExchangeVM) tokenCalls() []map[string]string {
+	vm.t.Helper()
+	var calls []map[string]string
+	vm.L.GetGlobal("token_calls").(*lua.LTable).ForEach(func(_, call lua.LValue) {
+		headers := vm.L.GetField(call, "headers").(*lua.LTable)
+		calls = append(calls, map[string]string{
+			"cluster":   vm.L.GetField(call, "cluster").String(),
+			"path":      vm.L.GetField(headers, ":path").String(),
+			"authority": vm.L.GetField(headers, ":authority").String(),
+			"body":      vm.L.GetField(call, "body").String(),
+		})
+	})
+	return calls
+}
+
+const exchangedTokenResponse = `{"access_token":"exchanged-token","token_type":"Bearer","expires_in":3600}`
+
+func TestTokenExchange_ExchangesTokenForDestinationHost(t *testing.T) {
+	// 1. Arrange
+	vm := newTokenExchangeVM(t)
+
+	// 2. Act
+	result := vm.onRequest(map[string]string{
+		":authority":    "orders.example.com:80",
+		"authorization": "Bearer user-token",
+	}, "200", exchangedTokenResponse)
+
+	// 3. Assert
+	assert.Equal(t, "Bearer exchanged-token", result.headers["authorization"])
+	calls := vm.tokenCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "token-exchange", calls[0]["cluster"])
+	assert.Equal(t, "/oauth2/token", calls[0]["path"])
+	assert.Equal(t, "idp.example.com", calls[0]["authority"])
+
+	form, err := url.ParseQuery(calls[0]["body"])
+	require.NoError(t, err)
+	assert.Equal(t, "urn:ietf:params:oauth:grant-type:token-exchange", form.Get("grant_type"))
+	assert.Equal(t, "user-token", form.Get("subject_token"))
+	assert.Equal(t, "urn:ietf:params:oauth:token-type:access_token", form.Get("subject_token_type"))
+	assert.Equal(t, "orders-api", form.Get("audience"))
+	assert.Equal(t, "read write", form.Get("scope"))
+	assert.Equal(t, "my-client", form.Get("client_id"))
+	assert.Equal(t, "s3cr&t", form.Get("client_secret"))
+}
+
+func TestTokenExchange_CachesTokenPerSubjectTokenAndAudience(t *testing.T) {
+	// 1. Arrange
+	vm := newTokenExchangeVM(t)
+	orders := map[string]string{":authority": "orders.example.com", "authorization": "Bearer user-token"}
+
+	// 2. Act
+	vm.onRequest(orders, "200", exchangedTokenResponse)
+	cached := vm.onRequest(orders, "200", exchangedTokenResponse)
+	vm.onRequest(map[string]string{
+		":authority":    "billing.example.com",
+		"authorization": "Bearer user-token",
+	}, "200", exchangedTokenResponse)
+	vm.onRequest(map[string]string{
+		":authority":    "orders.example.com",
+		"authorization": "Bearer other-user-token",
+	}, "200", exchangedTokenResponse)
+
+	// 3. Assert
+	assert.Equal(t, "Bearer exchanged-token", cached.headers["authorization"])
+	calls := vm.tokenCalls()
+	require.Len(t, calls, 3)
+	billingForm, err := url.ParseQuery(calls[1]["body"])
+	require.NoError(t, err)
+	assert.Equal(t, "billing-api", billingForm.Get("audience"))
+	assert.Empty(t, billingForm.Get("scope"))
+}
+
+func TestTokenExchange_DoesNotCacheShortLivedTokens(t *testing.T) {
+	// 1. Arrange
+	vm := newTokenExchangeVM(t)
+	orders := map[string]string{":authority": "orders.example.com", "authorization": "Bearer user-token"}
+	shortLived := `{"access_token":"exchanged-token","expires_in":"10"}`
+
+	// 2. Act
+	first := vm.onRequest(orders, "200", shortLived)
+	vm.onRequest(orders, "200", shortLived)
+
+	// 3. Assert
+	assert.Equal(t, "Bearer exchanged-token", first.headers["authorization"])
+	assert.Len(t, vm.tokenCalls(), 2)
+}
+
+func TestTokenExchange_LeavesOtherRequestsUntouched(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{
+			name:    "host is not a destination",
+			headers: map[string]string{":authority": "other.example.com", "authorization": "Bearer user-token"},
+		},
+		{
+			name:    "request without bearer token",
+			headers: map[string]string{":authority": "orders.example.com", "authorization": "Basic dXNlcjpwdw=="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			vm := newTokenExchangeVM(t)
+
+			// 2. Act
+			result := vm.onRequest(tt.headers, "200", exchangedTokenResponse)
+
+			// 3. Assert
+			assert.Equal(t, tt.headers["authorization"], result.headers["authorization"])
+			assert.Empty(t, vm.tokenCalls())
+		})
+	}
+}
+
+func TestTokenExchange_RespondsUnauthorizedWhenExchangeFails(t *testing.T) {
+	// 1. Arrange
+	vm := newTokenExchangeVM(t)
+
+	// 2. Act
+	result := vm.onRequest(map[string]string{
+		":authority":    "orders.example.com",
+		"authorization": "Bearer user-token",
+	}, "400", `{"error":"invalid_grant"}`)
+
+	// 3. Assert
+	assert.Equal(t, "401", result.response)
+	assert.Equal(t, "Bearer user-token", result.headers["authorization"])
+}
@@ -1,3 +1,5 @@
+local bypass_login_header = "%s"
+local deny_redirect_header = "%s"
 local ignore_rules = %s
 local require_rules = %s
 local deny_redirect_rules = %s
@@ -6,37 +8,24 @@ local login_params = %s
 local end_session_endpoint = "%s"
 local post_logout_redirect_uri = "%s"
 
--- returns true when {p,m} matches any rule in the supplied table
-local function match(rules, p, m)
-    for _, rule in ipairs(rules) do
-        if string.match(p, rule.regex) then
-        -- empty "methods" table == all methods
-            if next(rule.methods) == nil or rule.methods[m] then
-                return true
-            end
-        end
-    end
-    return false
-end
-
--- returns true if {p,m} is in ignore_rules *and* NOT in require_rules
-local function should_bypass(p, m)
+-- returns true if {p,m,host,headers} is in ignore_rules *and* NOT in require_rules
+local function should_bypass(p, m, host, headers)
     local bypass = false
     if p ~= "" and m ~= "" then
         -- bypass only when it is in ignore_rules *and* NOT in require_rules
-        if match(ignore_rules, p, m) and not match(require_rules, p, m) then
+        if match(ignore_rules, p, m, host, headers) and not match(require_rules, p, m, host, headers) then
             bypass = true
         end
     end
     return bypass
 end
 
--- returns true if {p,m} is in deny_redirect_rules
-local function should_deny_redirect(p, m)
+-- returns true if {p,m,host,headers} is in deny_redirect_rules
+local function should_deny_redirect(p, m, host, headers)
     local deny_redirect = false
     if p ~= "" and m ~= "" then
         -- deny redirect only when it is in deny_redirect_rules
-        if match(deny_redirect_rules, p, m) then
+        if match(deny_redirect_rules, p, m, host, headers) then
             deny_redirect = true
         end
     end
@@ -48,17 +37,23 @@ local function is_empty_table(t)
 end
 
 function envoy_on_request(request_handle)
+    -- strip any copies of the signal headers sent by the client, so that they cannot be forged
+    request_handle:headers():remove(bypass_login_header)
+    request_handle:headers():remove(deny_redirect_header)
+
     local raw_p = request_handle:headers():get(":path") or ""
     local m = request_handle:headers():get(":method") or ""
     local p = string.match(raw_p, "^[^?]*")
+    local host = string.lower(request_handle:headers():get(":authority") or "")
+    local headers = request_handle:headers()
 
-    local bypass = should_bypass(p, m)
+    local bypass = should_bypass(p, m, host, headers)
     request_handle:logCritical("Login bypassed?: " .. tostring(bypass))
-    request_handle:headers():add("%s", tostring(bypass))
+    request_handle:headers():replace(bypass_login_header, tostring(bypass))
 
-    local deny_redirect = should_deny_redirect(p, m)
+    local deny_redirect = should_deny_redirect(p, m, host, headers)
     request_handle:logCritical("Deny redirect?: " .. tostring(deny_redirect))
-    request_handle:headers():add("%s", tostring(deny_redirect))
+    request_handle:headers():replace(deny_redirect_header, tostring(deny_redirect))
 end
 
 function envoy_on_response(response_handle)
@@ -66,7 +61,8 @@ function envoy_on_response(response_handle)
     if status == "302" then
         local loc = response_handle:headers():get("location") or ""
         if loc ~= "" then
-            if string.sub(loc, 1, #authorize_endpoint) == authorize_endpoint and not is_empty_table(login_params) then
+            if authorize_endpoint ~= "" and string.sub(loc, 1, #authorize_endpoint) == authorize_endpoint
+                and not is_empty_table(login_params) then
                 local base, qs = loc:match("^([^?]+)%%??(.*)$")
                 local filtered = {}
                 if qs ~= "" then
@@ -87,7 +83,7 @@ function envoy_on_response(response_handle)
                 response_handle:headers():replace("location", new_url)
             end
 
-            if string.sub(loc, 1, #end_session_endpoint) == end_session_endpoint then
+            if end_session_endpoint ~= "" and string.sub(loc, 1, #end_session_endpoint) == end_session_endpoint then
                 local base, qs = loc:match("^([^?]+)%%??(.*)$")
                 local filtered = {}
                 if qs ~= "" then
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of fetching a discovery document, used as the value of the result label.
+const (
+	DiscoveryDocumentFetchResultOK          = "ok"
+	DiscoveryDocumentFetchResultNotModified = "not_modified"
+	DiscoveryDocumentFetchResultError       = "error"
+)
+
+var (
+	discoveryDocumentCacheHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name:      "cache_hits_total",
+			Namespace: "ztoperator",
+			Subsystem: "discovery_document",
+			Help:      "Number of discovery documents served from the cache without contacting the identity provider",
+		},
+	)
+	discoveryDocumentCacheMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name:      "cache_misses_total",
+			Namespace: "ztoperator",
+			Subsystem: "discovery_document",
+			Help:      "Number of discovery documents missing from the cache, or expired, which had to be fetched",
+		},
+	)
+	discoveryDocumentCacheStale = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name:      "cache_stale_total",
+			Namespace: "ztoperator",
+			Subsystem: "discovery_document",
+			Help:      "Number of expired discovery documents served from the cache as fetching them failed",
+		},
+	)
+	discoveryDocumentFetchDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:      "fetch_duration_seconds",
+			Namespace: "ztoperator",
+			Subsystem: "discovery_document",
+			Help:      "Latency of fetching discovery documents from identity providers, by result",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"result"},
+	)
+)
+
+func RecordDiscoveryDocumentCacheHit() {
+	discoveryDocumentCacheHits.Inc()
+}
+
+func RecordDiscoveryDocumentCacheMiss() {
+	discoveryDocumentCacheMisses.Inc()
+}
+
+func RecordDiscoveryDocumentCacheStale() {
+	discoveryDocumentCacheStale.Inc()
+}
+
+func ObserveDiscoveryDocumentFetch(result string, duration time.Duration) {
+	discoveryDocumentFetchDuration.WithLabelValues(result).Observe(duration.Seconds())
+}
@@ -45,7 +45,13 @@ var (
 )
 
 func MustRegister() {
-	metrics.Registry.MustRegister(authPolicyInfo)
+	metrics.Registry.MustRegister(
+		authPolicyInfo,
+		discoveryDocumentCacheHits,
+		discoveryDocumentCacheMisses,
+		discoveryDocumentCacheStale,
+		discoveryDocumentFetchDuration,
+	)
 }
 
 func StartAuthPolicyCollector(k8sClient client.Client, c cache.Cache, elected <-chan struct{}) error {
@@ -76,11 +82,12 @@ func RefreshAuthPolicyInfo(ctx context.Context, k8sClient client.Client, authPol
 	var namespace v1.Namespace
 	_ = k8sClient.Get(ctx, client.ObjectKey{Name: authPolicy.Namespace}, &namespace)
 
-	idpAsParsedURL, err := helperfunctions.GetParsedURL(authPolicy.Spec.WellKnownURI)
+	identityProviderURI := getIdentityProviderURI(ctx, k8sClient, authPolicy)
+	idpAsParsedURL, err := helperfunctions.GetParsedURL(identityProviderURI)
 	if err != nil {
 		return fmt.Errorf(
 			"failed to get issuer hostname from issuer URI %s due to the following error: %w",
-			authPolicy.Spec.WellKnownURI,
+			identityProviderURI,
 			err,
 		)
 	}
@@ -129,6 +136,29 @@ func RefreshAuthPolicyInfo(ctx context.Context, k8sClient client.Client, authPol
 	return nil
 }
 
+// getIdentityProviderURI returns the well-known URI of the default identity provider of the AuthPolicy, falling back to
+// the first of .spec.identityProviders. Referenced IdentityProviders are looked up to find their well-known URI, and
+// the issuer given by .endpoints is used for identity providers without a well-known URI.
+func getIdentityProviderURI(ctx context.Context, k8sClient client.Client, authPolicy v1alpha1.AuthPolicy) string {
+	wellKnownURI, endpoints, identityProviderRef :=
+		authPolicy.Spec.WellKnownURI, authPolicy.Spec.Endpoints, authPolicy.Spec.IdentityProviderRef
+	if !authPolicy.HasDefaultIdentityProvider() {
+		wellKnownURI = authPolicy.Spec.IdentityProviders[0].WellKnownURI
+		endpoints = authPolicy.Spec.IdentityProviders[0].Endpoints
+		identityProviderRef = authPolicy.Spec.IdentityProviders[0].IdentityProviderRef
+	}
+	if identityProviderRef != nil {
+		var identityProvider v1alpha1.IdentityProvider
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: *identityProviderRef}, &identityProvider); err == nil {
+			wellKnownURI, endpoints = identityProvider.Spec.WellKnownURI, identityProvider.Spec.Endpoints
+		}
+	}
+	if wellKnownURI == "" && endpoints != nil && endpoints.Issuer != nil {
+		return *endpoints.Issuer
+	}
+	return wellKnownURI
+}
+
 func DeleteAuthPolicyInfo(namespacedName types.NamespacedName) {
 	authPolicyInfo.DeletePartialMatch(map[string]string{
 		"name":      namespacedName.Name,
@@ -43,6 +43,7 @@ type ControllerResource interface {
 	GetResourceKind() string
 	GetResourceName() string
 	IsResourceNil() bool
+	GetDesiredResource() client.Object
 }
 
 type ReconcilerAdapter[T client.Object] struct {
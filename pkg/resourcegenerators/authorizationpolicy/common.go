@@ -2,11 +2,15 @@ package authorizationpolicy
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/targetref"
+	"github.com/kartverket/ztoperator/pkg/validation"
+	"istio.io/api/annotation"
 	"istio.io/api/security/v1beta1"
-	v1beta2 "istio.io/api/type/v1beta1"
 	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -14,44 +18,314 @@ import (
 func GetBaselineAuthConditionsForAllowPolicy(
 	baselineAuth *v1alpha1.BaselineAuth,
 ) []*v1beta1.Condition {
-	makeCondition := func(key string, values []string) *v1beta1.Condition {
-		return &v1beta1.Condition{
-			Key:    key,
-			Values: values,
-		}
+	if baselineAuth == nil {
+		return nil
 	}
-	return getBaselineAuthConditions(baselineAuth, makeCondition)
+	return GetClaimConditionsForAllowPolicy(baselineAuth.Claims)
 }
 
 func GetBaselineAuthConditionsForDenyPolicy(
 	baselineAuth *v1alpha1.BaselineAuth,
 ) []*v1beta1.Condition {
-	makeCondition := func(key string, values []string) *v1beta1.Condition {
-		return &v1beta1.Condition{
-			Key:       key,
-			NotValues: values, // NB! NotValues used in combination with deny rule
+	if baselineAuth == nil {
+		return nil
+	}
+	return GetClaimConditionsForDenyPolicy(baselineAuth.Claims)
+}
+
+/*
+GetClaimConditionsForAllowPolicy translates claim conditions into Istio conditions,
+which must all be met for a request to be allowed.
+*/
+func GetClaimConditionsForAllowPolicy(conditions []v1alpha1.Condition) []*v1beta1.Condition {
+	var istioConditions []*v1beta1.Condition
+	for _, condition := range conditions {
+		for _, literal := range claimConditionLiterals(condition) {
+			istioConditions = append(istioConditions, literal.allowCondition())
 		}
 	}
-	return getBaselineAuthConditions(baselineAuth, makeCondition)
+	return istioConditions
 }
 
-func getBaselineAuthConditions(
-	baselineAuth *v1alpha1.BaselineAuth,
-	makeConditionFunc func(key string, values []string) *v1beta1.Condition,
-) []*v1beta1.Condition {
-	var baselineAuthConditions []*v1beta1.Condition
-	if baselineAuth != nil && len(baselineAuth.Claims) > 0 {
-		for _, condition := range baselineAuth.Claims {
-			baselineAuthConditions = append(
-				baselineAuthConditions,
-				makeConditionFunc(
-					fmt.Sprintf("request.auth.claims[%s]", condition.Claim),
-					condition.Values,
-				),
-			)
+/*
+GetClaimConditionsForDenyPolicy translates claim conditions into negated Istio conditions.
+A request is denied if any of the negated conditions is met, thus each condition should result in a separate deny rule.
+*/
+func GetClaimConditionsForDenyPolicy(conditions []v1alpha1.Condition) []*v1beta1.Condition {
+	var istioConditions []*v1beta1.Condition
+	for _, condition := range conditions {
+		for _, literal := range claimConditionLiterals(condition) {
+			istioConditions = append(istioConditions, literal.denyCondition())
+		}
+	}
+	return istioConditions
+}
+
+/*
+GetConditionGroupsForAllowPolicy returns one set of conditions per group.
+A request is allowed if the conditions of any of the sets are met.
+*/
+func GetConditionGroupsForAllowPolicy(conditionGroups []v1alpha1.ConditionGroup) [][]*v1beta1.Condition {
+	conditionSets := make([][]*v1beta1.Condition, 0, len(conditionGroups))
+	for _, conditionGroup := range conditionGroups {
+		conditionSets = append(conditionSets, GetClaimConditionsForAllowPolicy(conditionGroup.AllOf))
+	}
+	return conditionSets
+}
+
+/*
+GetConditionGroupsForDenyPolicy returns sets of conditions where each set should result in a separate deny rule.
+By De Morgan's laws, a request satisfies none of the groups if, for every group, at least one negated condition is met.
+Thus, each set combines one negated condition from every group.
+*/
+func GetConditionGroupsForDenyPolicy(conditionGroups []v1alpha1.ConditionGroup) [][]*v1beta1.Condition {
+	if len(conditionGroups) == 0 {
+		return nil
+	}
+	conditionSets := [][]*v1beta1.Condition{{}}
+	for _, conditionGroup := range conditionGroups {
+		negatedConditions := GetClaimConditionsForDenyPolicy(conditionGroup.AllOf)
+		combinedConditionSets := make([][]*v1beta1.Condition, 0, len(conditionSets)*len(negatedConditions))
+		for _, conditionSet := range conditionSets {
+			for _, negatedCondition := range negatedConditions {
+				combinedConditionSets = append(
+					combinedConditionSets,
+					append(slices.Clone(conditionSet), negatedCondition),
+				)
+			}
+		}
+		conditionSets = combinedConditionSets
+	}
+	return conditionSets
+}
+
+/*
+CombineConditionSetsForAllowPolicy combines alternative sets of conditions, where a request must satisfy
+at least one set from each of the given alternatives. Each combination results in a separate set.
+Empty alternatives impose no restrictions and are skipped.
+*/
+func CombineConditionSetsForAllowPolicy(alternatives ...[][]*v1beta1.Condition) [][]*v1beta1.Condition {
+	combinedConditionSets := [][]*v1beta1.Condition{{}}
+	for _, conditionSets := range alternatives {
+		if len(conditionSets) == 0 {
+			continue
+		}
+		nextConditionSets := make([][]*v1beta1.Condition, 0, len(combinedConditionSets)*len(conditionSets))
+		for _, combinedConditionSet := range combinedConditionSets {
+			for _, conditionSet := range conditionSets {
+				nextConditionSets = append(nextConditionSets, slices.Concat(combinedConditionSet, conditionSet))
+			}
+		}
+		combinedConditionSets = nextConditionSets
+	}
+	return combinedConditionSets
+}
+
+// conditionLiteral is a single check on a claim or header, either requiring (negated=false)
+// or forbidding (negated=true) that it matches one of the values.
+type conditionLiteral struct {
+	key     string
+	values  []string
+	negated bool
+}
+
+func (l conditionLiteral) allowCondition() *v1beta1.Condition {
+	if l.negated {
+		return &v1beta1.Condition{Key: l.key, NotValues: l.values}
+	}
+	return &v1beta1.Condition{Key: l.key, Values: l.values}
+}
+
+func (l conditionLiteral) denyCondition() *v1beta1.Condition {
+	if l.negated {
+		return &v1beta1.Condition{Key: l.key, Values: l.values}
+	}
+	return &v1beta1.Condition{
+		Key:       l.key,
+		NotValues: l.values, // NB! NotValues used in combination with deny rule
+	}
+}
+
+/*
+Each operator set on a condition results in a separate literal, as all operators of a condition must be met.
+Presence is expressed with Istio's presence match `*`.
+*/
+func claimConditionLiterals(condition v1alpha1.Condition) []conditionLiteral {
+	key := fmt.Sprintf("request.auth.claims[%s]", condition.Claim)
+	var literals []conditionLiteral
+	if len(condition.Values) > 0 {
+		literals = append(literals, conditionLiteral{key: key, values: condition.Values})
+	}
+	if len(condition.NotValues) > 0 {
+		literals = append(literals, conditionLiteral{key: key, values: condition.NotValues, negated: true})
+	}
+	if condition.Present != nil {
+		literals = append(literals, conditionLiteral{key: key, values: []string{"*"}, negated: !*condition.Present})
+	}
+	return literals
+}
+
+// Header names are lowercased, as Envoy normalizes the names of request headers.
+func headerConditionLiterals(header v1alpha1.HeaderMatcher) []conditionLiteral {
+	key := fmt.Sprintf("request.headers[%s]", strings.ToLower(header.Name))
+	var literals []conditionLiteral
+	if len(header.Values) > 0 {
+		literals = append(literals, conditionLiteral{key: key, values: header.Values})
+	}
+	if len(header.NotValues) > 0 {
+		literals = append(literals, conditionLiteral{key: key, values: header.NotValues, negated: true})
+	}
+	return literals
+}
+
+/*
+GetHeaderConditionsForAllowPolicy translates the header matchers of a request matcher into Istio conditions,
+which must all be met for the request matcher to apply.
+*/
+func GetHeaderConditionsForAllowPolicy(matcher v1alpha1.RequestMatcher) []*v1beta1.Condition {
+	var istioConditions []*v1beta1.Condition
+	for _, header := range matcher.Headers {
+		for _, literal := range headerConditionLiterals(header) {
+			istioConditions = append(istioConditions, literal.allowCondition())
+		}
+	}
+	return istioConditions
+}
+
+/*
+GetHeaderConditionsForComplementPolicy translates the header matchers of a request matcher into negated Istio
+conditions. A request matching the paths of the request matcher is not covered by it if any of the negated
+conditions is met, thus each condition should result in a separate rule.
+*/
+func GetHeaderConditionsForComplementPolicy(matcher v1alpha1.RequestMatcher) []*v1beta1.Condition {
+	var istioConditions []*v1beta1.Condition
+	for _, header := range matcher.Headers {
+		for _, literal := range headerConditionLiterals(header) {
+			istioConditions = append(istioConditions, literal.denyCondition())
+		}
+	}
+	return istioConditions
+}
+
+// GetOperation translates the paths, methods and hosts of a request matcher into an Istio operation.
+func GetOperation(matcher v1alpha1.RequestMatcher) *v1beta1.Operation {
+	return &v1beta1.Operation{
+		Paths:    validation.TransformPathsForIstio(matcher.Paths),
+		Methods:  matcher.Methods,
+		Hosts:    matcher.Hosts,
+		NotHosts: matcher.NotHosts,
+	}
+}
+
+/*
+GetSourcesForAllowPolicy translates sources into Istio sources.
+A request is allowed if it originates from any of the sources.
+*/
+func GetSourcesForAllowPolicy(sources []v1alpha1.Source) []*v1beta1.Rule_From {
+	ruleFromList := make([]*v1beta1.Rule_From, 0, len(sources))
+	for _, source := range sources {
+		istioSource := &v1beta1.Source{}
+		for _, literal := range sourceLiterals(source) {
+			literal.addTo(istioSource)
 		}
+		ruleFromList = append(ruleFromList, &v1beta1.Rule_From{Source: istioSource})
 	}
-	return baselineAuthConditions
+	return ruleFromList
+}
+
+/*
+GetSourcesForDenyPolicy returns negated Istio sources, where each should result in a separate deny rule.
+By De Morgan's laws, a request originates from none of the sources if, for every source, at least one of its
+fields does not match. Thus, each negated source combines one negated field from every source.
+*/
+func GetSourcesForDenyPolicy(sources []v1alpha1.Source) []*v1beta1.Source {
+	if len(sources) == 0 {
+		return nil
+	}
+	literalCombinations := [][]sourceLiteral{{}}
+	for _, source := range sources {
+		literals := sourceLiterals(source)
+		nextLiteralCombinations := make([][]sourceLiteral, 0, len(literalCombinations)*len(literals))
+		for _, literalCombination := range literalCombinations {
+			for _, literal := range literals {
+				nextLiteralCombinations = append(
+					nextLiteralCombinations,
+					append(slices.Clone(literalCombination), literal),
+				)
+			}
+		}
+		literalCombinations = nextLiteralCombinations
+	}
+
+	negatedSources := make([]*v1beta1.Source, 0, len(literalCombinations))
+	for _, literalCombination := range literalCombinations {
+		negatedSource := &v1beta1.Source{}
+		for _, literal := range literalCombination {
+			literal.addNegatedTo(negatedSource)
+		}
+		negatedSources = append(negatedSources, negatedSource)
+	}
+	return negatedSources
+}
+
+type sourceField int
+
+const (
+	sourceFieldPrincipals sourceField = iota
+	sourceFieldNamespaces
+	sourceFieldIPBlocks
+)
+
+// sourceLiteral is a single check on the origin of a request, requiring that a field of the peer matches one
+// of the values.
+type sourceLiteral struct {
+	field  sourceField
+	values []string
+}
+
+func (l sourceLiteral) addTo(source *v1beta1.Source) {
+	switch l.field {
+	case sourceFieldPrincipals:
+		source.Principals = append(source.Principals, l.values...)
+	case sourceFieldNamespaces:
+		source.Namespaces = append(source.Namespaces, l.values...)
+	case sourceFieldIPBlocks:
+		source.IpBlocks = append(source.IpBlocks, l.values...)
+	}
+}
+
+func (l sourceLiteral) addNegatedTo(source *v1beta1.Source) {
+	switch l.field {
+	case sourceFieldPrincipals:
+		source.NotPrincipals = append(source.NotPrincipals, l.values...)
+	case sourceFieldNamespaces:
+		source.NotNamespaces = append(source.NotNamespaces, l.values...)
+	case sourceFieldIPBlocks:
+		source.NotIpBlocks = append(source.NotIpBlocks, l.values...)
+	}
+}
+
+/*
+Each field set on a source results in a separate literal, as all fields of a source must match.
+Service accounts are expressed as principals matching any trust domain.
+*/
+func sourceLiterals(source v1alpha1.Source) []sourceLiteral {
+	var literals []sourceLiteral
+	principals := slices.Clone(source.Principals)
+	for _, serviceAccount := range source.ServiceAccounts {
+		namespace, name, _ := strings.Cut(serviceAccount, "/")
+		principals = append(principals, fmt.Sprintf("*/ns/%s/sa/%s", namespace, name))
+	}
+	if len(principals) > 0 {
+		literals = append(literals, sourceLiteral{field: sourceFieldPrincipals, values: principals})
+	}
+	if len(source.Namespaces) > 0 {
+		literals = append(literals, sourceLiteral{field: sourceFieldNamespaces, values: source.Namespaces})
+	}
+	if len(source.IPBlocks) > 0 {
+		literals = append(literals, sourceLiteral{field: sourceFieldIPBlocks, values: source.IPBlocks})
+	}
+	return literals
 }
 
 func GetAudienceAndIssuerConditionsForAllowPolicy(acceptedResources []string, issuer string) []*v1beta1.Condition {
@@ -87,6 +361,111 @@ func getAudienceAndIssuerConditions(
 	return conditions
 }
 
+/*
+GetIdentityProviderConditionsForAllowPolicy returns one set of conditions per trusted issuer.
+A request is allowed if the conditions of any of the sets are met.
+Identity providers sharing an issuer are merged into a single set accepting the union of their accepted resources.
+*/
+func GetIdentityProviderConditionsForAllowPolicy(identityProviders []state.IdentityProvider) [][]*v1beta1.Condition {
+	issuers := groupAcceptedResourcesByIssuer(identityProviders)
+	conditionSets := make([][]*v1beta1.Condition, 0, len(issuers))
+	for _, issuer := range issuers {
+		conditionSets = append(
+			conditionSets,
+			GetAudienceAndIssuerConditionsForAllowPolicy(issuer.acceptedResources, issuer.issuer),
+		)
+	}
+	return conditionSets
+}
+
+/*
+GetIdentityProviderConditionsForDenyPolicy returns sets of conditions,
+where each set should result in a separate deny rule.
+A request is denied if it was not issued by any of the trusted issuers,
+or if it was issued by a trusted issuer but lacks all the accepted resources of that issuer.
+*/
+func GetIdentityProviderConditionsForDenyPolicy(identityProviders []state.IdentityProvider) [][]*v1beta1.Condition {
+	issuers := groupAcceptedResourcesByIssuer(identityProviders)
+	if len(issuers) == 1 {
+		var conditionSets [][]*v1beta1.Condition
+		for _, condition := range GetAudienceAndIssuerConditionsForDenyPolicy(
+			issuers[0].acceptedResources,
+			issuers[0].issuer,
+		) {
+			conditionSets = append(conditionSets, []*v1beta1.Condition{condition})
+		}
+		return conditionSets
+	}
+
+	issuerURIs := make([]string, 0, len(issuers))
+	for _, issuer := range issuers {
+		issuerURIs = append(issuerURIs, issuer.issuer)
+	}
+	conditionSets := [][]*v1beta1.Condition{
+		{
+			{
+				Key:       "request.auth.claims[iss]",
+				NotValues: issuerURIs, // NB! NotValues used in combination with deny rule
+			},
+		},
+	}
+	for _, issuer := range issuers {
+		if len(issuer.acceptedResources) == 0 {
+			continue
+		}
+		conditionSets = append(conditionSets, []*v1beta1.Condition{
+			{
+				Key:    "request.auth.claims[iss]",
+				Values: []string{issuer.issuer},
+			},
+			{
+				Key:       "request.auth.claims[aud]",
+				NotValues: issuer.acceptedResources, // NB! NotValues used in combination with deny rule
+			},
+		})
+	}
+	return conditionSets
+}
+
+type issuerAcceptedResources struct {
+	issuer            string
+	acceptedResources []string
+}
+
+func groupAcceptedResourcesByIssuer(identityProviders []state.IdentityProvider) []issuerAcceptedResources {
+	var issuers []issuerAcceptedResources
+	unrestricted := map[string]bool{}
+	for _, identityProvider := range identityProviders {
+		issuerURI := identityProvider.IdentityProviderUris.IssuerURI
+		acceptedResources := ConstructAcceptedResourcesForIdentityProvider(identityProvider)
+		index := slices.IndexFunc(issuers, func(i issuerAcceptedResources) bool { return i.issuer == issuerURI })
+		if index < 0 {
+			issuers = append(issuers, issuerAcceptedResources{issuer: issuerURI, acceptedResources: acceptedResources})
+			unrestricted[issuerURI] = len(acceptedResources) == 0
+			continue
+		}
+		// An identity provider without accepted resources accepts any audience for its issuer.
+		if unrestricted[issuerURI] || len(acceptedResources) == 0 {
+			unrestricted[issuerURI] = true
+			issuers[index].acceptedResources = nil
+			continue
+		}
+		for _, acceptedResource := range acceptedResources {
+			if !slices.Contains(issuers[index].acceptedResources, acceptedResource) {
+				issuers[index].acceptedResources = append(issuers[index].acceptedResources, acceptedResource)
+			}
+		}
+	}
+	return issuers
+}
+
+func ConstructAcceptedResourcesForIdentityProvider(identityProvider state.IdentityProvider) []string {
+	var acceptedResources []string
+	acceptedResources = append(acceptedResources, identityProvider.Audiences...)
+	acceptedResources = append(acceptedResources, identityProvider.AcceptedResources...)
+	return acceptedResources
+}
+
 func ConstructAcceptedResources(scope state.Scope) []string {
 	var acceptedResources []string
 	acceptedResources = append(acceptedResources, scope.Audiences...)
@@ -129,14 +508,17 @@ func authorizationPolicy(
 	action v1beta1.AuthorizationPolicy_Action,
 	rules []*v1beta1.Rule,
 ) *istioclientsecurityv1.AuthorizationPolicy {
+	if scope.AuthPolicy.IsAuditMode() {
+		// In audit mode, the sidecar evaluates the policy and records the result without enforcing it
+		objectMeta.Annotations = map[string]string{annotation.IoIstioDryRun.Name: "true"}
+	}
 	return &istioclientsecurityv1.AuthorizationPolicy{
 		ObjectMeta: objectMeta,
 		Spec: v1beta1.AuthorizationPolicy{
-			Action: action,
-			Selector: &v1beta2.WorkloadSelector{
-				MatchLabels: scope.AuthPolicy.Spec.Selector.MatchLabels,
-			},
-			Rules: rules,
+			Action:     action,
+			Selector:   targetref.GetWorkloadSelector(&scope.AuthPolicy),
+			TargetRefs: targetref.GetPolicyTargetReferences(&scope.AuthPolicy),
+			Rules:      rules,
 		},
 	}
 }
@@ -0,0 +1,116 @@
+package authorizationpolicy_test
+
+import (
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetClaimConditions_WithNotValues_InvertsBetweenAllowAndDeny(t *testing.T) {
+	// 1. Arrange
+	conditions := []ztoperatorv1alpha1.Condition{
+		{Claim: "role", NotValues: []string{"guest"}},
+	}
+
+	// 2. Act
+	allowConditions := authorizationpolicy.GetClaimConditionsForAllowPolicy(conditions)
+	denyConditions := authorizationpolicy.GetClaimConditionsForDenyPolicy(conditions)
+
+	// 3. Assert
+	require.Len(t, allowConditions, 1)
+	assert.Equal(t, "request.auth.claims[role]", allowConditions[0].Key)
+	assert.Equal(t, []string{"guest"}, allowConditions[0].NotValues)
+	assert.Empty(t, allowConditions[0].Values)
+
+	require.Len(t, denyConditions, 1)
+	assert.Equal(t, "request.auth.claims[role]", denyConditions[0].Key)
+	assert.Equal(t, []string{"guest"}, denyConditions[0].Values)
+	assert.Empty(t, denyConditions[0].NotValues)
+}
+
+func TestGetClaimConditions_WithPresentTrue_UsesPresenceMatch(t *testing.T) {
+	// 1. Arrange
+	conditions := []ztoperatorv1alpha1.Condition{
+		{Claim: "pid", Present: helperfunctions.Ptr(true)},
+	}
+
+	// 2. Act
+	allowConditions := authorizationpolicy.GetClaimConditionsForAllowPolicy(conditions)
+	denyConditions := authorizationpolicy.GetClaimConditionsForDenyPolicy(conditions)
+
+	// 3. Assert
+	require.Len(t, allowConditions, 1)
+	assert.Equal(t, "request.auth.claims[pid]", allowConditions[0].Key)
+	assert.Equal(t, []string{"*"}, allowConditions[0].Values)
+
+	require.Len(t, denyConditions, 1)
+	assert.Equal(t, []string{"*"}, denyConditions[0].NotValues)
+}
+
+func TestGetClaimConditions_WithPresentFalse_UsesNegatedPresenceMatch(t *testing.T) {
+	// 1. Arrange
+	conditions := []ztoperatorv1alpha1.Condition{
+		{Claim: "act", Present: helperfunctions.Ptr(false)},
+	}
+
+	// 2. Act
+	allowConditions := authorizationpolicy.GetClaimConditionsForAllowPolicy(conditions)
+	denyConditions := authorizationpolicy.GetClaimConditionsForDenyPolicy(conditions)
+
+	// 3. Assert
+	require.Len(t, allowConditions, 1)
+	assert.Equal(t, []string{"*"}, allowConditions[0].NotValues)
+	assert.Empty(t, allowConditions[0].Values)
+
+	require.Len(t, denyConditions, 1)
+	assert.Equal(t, []string{"*"}, denyConditions[0].Values)
+	assert.Empty(t, denyConditions[0].NotValues)
+}
+
+func TestGetClaimConditions_WithPrefixWildcard_PassesWildcardThrough(t *testing.T) {
+	// 1. Arrange
+	conditions := []ztoperatorv1alpha1.Condition{
+		{Claim: "groups", Values: []string{"team-*"}},
+	}
+
+	// 2. Act
+	allowConditions := authorizationpolicy.GetClaimConditionsForAllowPolicy(conditions)
+	denyConditions := authorizationpolicy.GetClaimConditionsForDenyPolicy(conditions)
+
+	// 3. Assert
+	require.Len(t, allowConditions, 1)
+	assert.Equal(t, []string{"team-*"}, allowConditions[0].Values)
+	require.Len(t, denyConditions, 1)
+	assert.Equal(t, []string{"team-*"}, denyConditions[0].NotValues)
+}
+
+func TestGetClaimConditions_WithCombinedOperators_ReturnsOneConditionPerOperator(t *testing.T) {
+	// 1. Arrange
+	conditions := []ztoperatorv1alpha1.Condition{
+		{Claim: "groups", Values: []string{"team-*"}, NotValues: []string{"team-external"}},
+		{Claim: "email", NotValues: []string{"*@example.com"}, Present: helperfunctions.Ptr(true)},
+	}
+
+	// 2. Act
+	allowConditions := authorizationpolicy.GetClaimConditionsForAllowPolicy(conditions)
+	denyConditions := authorizationpolicy.GetClaimConditionsForDenyPolicy(conditions)
+
+	// 3. Assert
+	require.Len(t, allowConditions, 4)
+	assert.Equal(t, []string{"team-*"}, allowConditions[0].Values)
+	assert.Equal(t, []string{"team-external"}, allowConditions[1].NotValues)
+	assert.Equal(t, []string{"*@example.com"}, allowConditions[2].NotValues)
+	assert.Equal(t, []string{"*"}, allowConditions[3].Values)
+
+	// Every allow condition must be negated on the deny side, each resulting in a separate deny rule
+	require.Len(t, denyConditions, len(allowConditions))
+	for i := range allowConditions {
+		assert.Equal(t, allowConditions[i].Key, denyConditions[i].Key)
+		assert.Equal(t, allowConditions[i].Values, denyConditions[i].NotValues)
+		assert.Equal(t, allowConditions[i].NotValues, denyConditions[i].Values)
+	}
+}
@@ -0,0 +1,86 @@
+package authorizationpolicy_test
+
+import (
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"istio.io/api/security/v1beta1"
+)
+
+func conditionGroupsForRoleOrOpsWithHighAcr() []ztoperatorv1alpha1.ConditionGroup {
+	return []ztoperatorv1alpha1.ConditionGroup{
+		{AllOf: []ztoperatorv1alpha1.Condition{{Claim: "role", Values: []string{"admin"}}}},
+		{AllOf: []ztoperatorv1alpha1.Condition{
+			{Claim: "group", Values: []string{"ops"}},
+			{Claim: "acr", Values: []string{"high"}},
+		}},
+	}
+}
+
+func TestGetConditionGroupsForAllowPolicy_ReturnsOneSetPerGroup(t *testing.T) {
+	// 1. Arrange
+	conditionGroups := conditionGroupsForRoleOrOpsWithHighAcr()
+
+	// 2. Act
+	conditionSets := authorizationpolicy.GetConditionGroupsForAllowPolicy(conditionGroups)
+
+	// 3. Assert
+	require.Len(t, conditionSets, 2)
+	require.Len(t, conditionSets[0], 1)
+	assert.Equal(t, "request.auth.claims[role]", conditionSets[0][0].Key)
+	assert.Equal(t, []string{"admin"}, conditionSets[0][0].Values)
+	require.Len(t, conditionSets[1], 2)
+	assert.Equal(t, "request.auth.claims[group]", conditionSets[1][0].Key)
+	assert.Equal(t, "request.auth.claims[acr]", conditionSets[1][1].Key)
+}
+
+func TestGetConditionGroupsForDenyPolicy_CombinesOneNegatedConditionFromEveryGroup(t *testing.T) {
+	// 1. Arrange
+	conditionGroups := conditionGroupsForRoleOrOpsWithHighAcr()
+
+	// 2. Act
+	conditionSets := authorizationpolicy.GetConditionGroupsForDenyPolicy(conditionGroups)
+
+	// 3. Assert
+	// NOT(role OR (group AND acr)) == (NOT role AND NOT group) OR (NOT role AND NOT acr)
+	require.Len(t, conditionSets, 2)
+	for i, negatedClaim := range []string{"group", "acr"} {
+		require.Len(t, conditionSets[i], 2)
+		assert.Equal(t, "request.auth.claims[role]", conditionSets[i][0].Key)
+		assert.Equal(t, []string{"admin"}, conditionSets[i][0].NotValues)
+		assert.Equal(t, "request.auth.claims["+negatedClaim+"]", conditionSets[i][1].Key)
+		assert.Empty(t, conditionSets[i][1].Values)
+		assert.NotEmpty(t, conditionSets[i][1].NotValues)
+	}
+}
+
+func TestGetConditionGroupsForDenyPolicy_WithoutGroups_ReturnsNil(t *testing.T) {
+	// 1. Arrange, 2. Act & 3. Assert
+	assert.Nil(t, authorizationpolicy.GetConditionGroupsForDenyPolicy(nil))
+}
+
+func TestCombineConditionSetsForAllowPolicy_ReturnsCrossProductAndSkipsEmptyAlternatives(t *testing.T) {
+	// 1. Arrange
+	issuerA := &v1beta1.Condition{Key: "request.auth.claims[iss]", Values: []string{"a"}}
+	issuerB := &v1beta1.Condition{Key: "request.auth.claims[iss]", Values: []string{"b"}}
+	role := &v1beta1.Condition{Key: "request.auth.claims[role]", Values: []string{"admin"}}
+	scope := &v1beta1.Condition{Key: "request.auth.claims[scope]", Values: []string{"write"}}
+
+	// 2. Act
+	conditionSets := authorizationpolicy.CombineConditionSetsForAllowPolicy(
+		[][]*v1beta1.Condition{{issuerA}, {issuerB}},
+		nil,
+		[][]*v1beta1.Condition{{role}, {scope}},
+	)
+
+	// 3. Assert
+	assert.Equal(t, [][]*v1beta1.Condition{
+		{issuerA, role},
+		{issuerA, scope},
+		{issuerB, role},
+		{issuerB, scope},
+	}, conditionSets)
+}
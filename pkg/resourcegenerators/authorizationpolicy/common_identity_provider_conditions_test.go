@@ -0,0 +1,134 @@
+package authorizationpolicy_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func identityProvider(name, issuer string, audiences ...string) state.IdentityProvider {
+	return state.IdentityProvider{
+		Name:                 name,
+		IdentityProviderUris: state.IdentityProviderUris{IssuerURI: issuer},
+		Audiences:            audiences,
+	}
+}
+
+func TestGetIdentityProviderConditionsForAllowPolicy_WithSingleIdentityProvider_ReturnsSingleSet(t *testing.T) {
+	// 1. Arrange
+	identityProviders := []state.IdentityProvider{identityProvider("default", "https://idp.example.com", "client")}
+
+	// 2. Act
+	result := authorizationpolicy.GetIdentityProviderConditionsForAllowPolicy(identityProviders)
+
+	// 3. Assert
+	require.Len(t, result, 1)
+	require.Len(t, result[0], 2)
+	assert.Equal(t, "request.auth.claims[iss]", result[0][0].Key)
+	assert.Equal(t, []string{"https://idp.example.com"}, result[0][0].Values)
+	assert.Equal(t, "request.auth.claims[aud]", result[0][1].Key)
+	assert.Equal(t, []string{"client"}, result[0][1].Values)
+}
+
+func TestGetIdentityProviderConditionsForAllowPolicy_WithMultipleIssuers_ReturnsOneSetPerIssuer(t *testing.T) {
+	// 1. Arrange
+	identityProviders := []state.IdentityProvider{
+		identityProvider("default", "https://idp.example.com", "client"),
+		identityProvider("maskinporten", "https://maskinporten.example.com"),
+	}
+
+	// 2. Act
+	result := authorizationpolicy.GetIdentityProviderConditionsForAllowPolicy(identityProviders)
+
+	// 3. Assert
+	require.Len(t, result, 2)
+	require.Len(t, result[0], 2)
+	assert.Equal(t, []string{"https://idp.example.com"}, result[0][0].Values)
+	assert.Equal(t, []string{"client"}, result[0][1].Values)
+	require.Len(t, result[1], 1)
+	assert.Equal(t, "request.auth.claims[iss]", result[1][0].Key)
+	assert.Equal(t, []string{"https://maskinporten.example.com"}, result[1][0].Values)
+}
+
+func TestGetIdentityProviderConditionsForAllowPolicy_WithSharedIssuer_MergesAcceptedResources(t *testing.T) {
+	// 1. Arrange
+	identityProviders := []state.IdentityProvider{
+		identityProvider("first", "https://idp.example.com", "client-a"),
+		identityProvider("second", "https://idp.example.com", "client-b", "client-a"),
+	}
+
+	// 2. Act
+	result := authorizationpolicy.GetIdentityProviderConditionsForAllowPolicy(identityProviders)
+
+	// 3. Assert
+	require.Len(t, result, 1)
+	require.Len(t, result[0], 2)
+	assert.Equal(t, []string{"client-a", "client-b"}, result[0][1].Values)
+}
+
+func TestGetIdentityProviderConditionsForAllowPolicy_WithSharedIssuerWithoutAudiences_AcceptsAnyAudience(t *testing.T) {
+	// 1. Arrange
+	identityProviders := []state.IdentityProvider{
+		identityProvider("first", "https://idp.example.com", "client-a"),
+		identityProvider("second", "https://idp.example.com"),
+	}
+
+	// 2. Act
+	result := authorizationpolicy.GetIdentityProviderConditionsForAllowPolicy(identityProviders)
+
+	// 3. Assert
+	require.Len(t, result, 1)
+	require.Len(t, result[0], 1)
+	assert.Equal(t, "request.auth.claims[iss]", result[0][0].Key)
+}
+
+func TestGetIdentityProviderConditionsForDenyPolicy_WithSingleIdentityProvider_ReturnsOneSetPerCondition(t *testing.T) {
+	// 1. Arrange
+	identityProviders := []state.IdentityProvider{identityProvider("default", "https://idp.example.com", "client")}
+
+	// 2. Act
+	result := authorizationpolicy.GetIdentityProviderConditionsForDenyPolicy(identityProviders)
+
+	// 3. Assert
+	require.Len(t, result, 2)
+	require.Len(t, result[0], 1)
+	assert.Equal(t, "request.auth.claims[iss]", result[0][0].Key)
+	assert.Equal(t, []string{"https://idp.example.com"}, result[0][0].NotValues)
+	require.Len(t, result[1], 1)
+	assert.Equal(t, "request.auth.claims[aud]", result[1][0].Key)
+	assert.Equal(t, []string{"client"}, result[1][0].NotValues)
+}
+
+func TestGetIdentityProviderConditionsForDenyPolicy_WithMultipleIssuers_ScopesAudienceToIssuer(t *testing.T) {
+	// 1. Arrange
+	identityProviders := []state.IdentityProvider{
+		identityProvider("default", "https://idp.example.com", "client"),
+		identityProvider("maskinporten", "https://maskinporten.example.com"),
+		identityProvider("entra", "https://entra.example.com", "entra-client"),
+	}
+
+	// 2. Act
+	result := authorizationpolicy.GetIdentityProviderConditionsForDenyPolicy(identityProviders)
+
+	// 3. Assert
+	require.Len(t, result, 3)
+
+	require.Len(t, result[0], 1)
+	assert.Equal(t, "request.auth.claims[iss]", result[0][0].Key)
+	assert.Equal(
+		t,
+		[]string{"https://idp.example.com", "https://maskinporten.example.com", "https://entra.example.com"},
+		result[0][0].NotValues,
+	)
+
+	require.Len(t, result[1], 2)
+	assert.Equal(t, []string{"https://idp.example.com"}, result[1][0].Values)
+	assert.Equal(t, []string{"client"}, result[1][1].NotValues)
+
+	require.Len(t, result[2], 2)
+	assert.Equal(t, []string{"https://entra.example.com"}, result[2][0].Values)
+	assert.Equal(t, []string{"entra-client"}, result[2][1].NotValues)
+}
@@ -0,0 +1,80 @@
+package authorizationpolicy_test
+
+import (
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"istio.io/api/security/v1beta1"
+)
+
+func TestGetOperation_IncludesPathsMethodsAndHosts(t *testing.T) {
+	// 1. Arrange
+	matcher := ztoperatorv1alpha1.RequestMatcher{
+		Paths:    []string{"/api/{**}"},
+		Methods:  []string{"GET"},
+		Hosts:    []string{"*.example.com"},
+		NotHosts: []string{"internal.example.com"},
+	}
+
+	// 2. Act
+	operation := authorizationpolicy.GetOperation(matcher)
+
+	// 3. Assert
+	assert.Equal(t, &v1beta1.Operation{
+		Paths:    []string{"/api/{**}"},
+		Methods:  []string{"GET"},
+		Hosts:    []string{"*.example.com"},
+		NotHosts: []string{"internal.example.com"},
+	}, operation)
+}
+
+func TestGetHeaderConditions_InvertsBetweenAllowAndComplement(t *testing.T) {
+	// 1. Arrange
+	matcher := ztoperatorv1alpha1.RequestMatcher{
+		Paths: []string{"/api"},
+		Headers: []ztoperatorv1alpha1.HeaderMatcher{
+			{Name: "Accept", Values: []string{"application/json"}, NotValues: []string{"text/html*"}},
+		},
+	}
+
+	// 2. Act
+	allowConditions := authorizationpolicy.GetHeaderConditionsForAllowPolicy(matcher)
+	complementConditions := authorizationpolicy.GetHeaderConditionsForComplementPolicy(matcher)
+
+	// 3. Assert
+	require.Len(t, allowConditions, 2)
+	assert.Equal(t, &v1beta1.Condition{
+		Key:    "request.headers[accept]",
+		Values: []string{"application/json"},
+	}, allowConditions[0])
+	assert.Equal(t, &v1beta1.Condition{
+		Key:       "request.headers[accept]",
+		NotValues: []string{"text/html*"},
+	}, allowConditions[1])
+
+	require.Len(t, complementConditions, 2)
+	assert.Equal(t, &v1beta1.Condition{
+		Key:       "request.headers[accept]",
+		NotValues: []string{"application/json"},
+	}, complementConditions[0])
+	assert.Equal(t, &v1beta1.Condition{
+		Key:    "request.headers[accept]",
+		Values: []string{"text/html*"},
+	}, complementConditions[1])
+}
+
+func TestGetHeaderConditions_WithoutHeaders_ReturnsNil(t *testing.T) {
+	// 1. Arrange
+	matcher := ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/api"}}
+
+	// 2. Act
+	allowConditions := authorizationpolicy.GetHeaderConditionsForAllowPolicy(matcher)
+	complementConditions := authorizationpolicy.GetHeaderConditionsForComplementPolicy(matcher)
+
+	// 3. Assert
+	assert.Nil(t, allowConditions)
+	assert.Nil(t, complementConditions)
+}
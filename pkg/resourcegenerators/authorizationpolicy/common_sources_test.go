@@ -0,0 +1,61 @@
+package authorizationpolicy_test
+
+import (
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"istio.io/api/security/v1beta1"
+)
+
+func TestGetSourcesForAllowPolicy_TranslatesServiceAccountsToPrincipals(t *testing.T) {
+	// 1. Arrange
+	sources := []ztoperatorv1alpha1.Source{
+		{ServiceAccounts: []string{"batch/report-job"}, IPBlocks: []string{"10.0.0.0/16"}},
+		{Namespaces: []string{"monitoring"}},
+	}
+
+	// 2. Act
+	ruleFromList := authorizationpolicy.GetSourcesForAllowPolicy(sources)
+
+	// 3. Assert
+	assert.Equal(t, []*v1beta1.Rule_From{
+		{Source: &v1beta1.Source{Principals: []string{"*/ns/batch/sa/report-job"}, IpBlocks: []string{"10.0.0.0/16"}}},
+		{Source: &v1beta1.Source{Namespaces: []string{"monitoring"}}},
+	}, ruleFromList)
+}
+
+func TestGetSourcesForDenyPolicy_CombinesOneNegatedFieldFromEverySource(t *testing.T) {
+	// 1. Arrange
+	sources := []ztoperatorv1alpha1.Source{
+		{Principals: []string{"cluster.local/ns/batch/sa/report-job"}, IPBlocks: []string{"10.0.0.0/16"}},
+		{Namespaces: []string{"monitoring"}},
+	}
+
+	// 2. Act
+	negatedSources := authorizationpolicy.GetSourcesForDenyPolicy(sources)
+
+	// 3. Assert
+	require.Len(t, negatedSources, 2)
+	assert.Equal(t, &v1beta1.Source{
+		NotPrincipals: []string{"cluster.local/ns/batch/sa/report-job"},
+		NotNamespaces: []string{"monitoring"},
+	}, negatedSources[0])
+	assert.Equal(t, &v1beta1.Source{
+		NotIpBlocks:   []string{"10.0.0.0/16"},
+		NotNamespaces: []string{"monitoring"},
+	}, negatedSources[1])
+}
+
+func TestGetSourcesForDenyPolicy_WithoutSources_ReturnsNil(t *testing.T) {
+	// 1. Arrange
+	var sources []ztoperatorv1alpha1.Source
+
+	// 2. Act
+	negatedSources := authorizationpolicy.GetSourcesForDenyPolicy(sources)
+
+	// 3. Assert
+	assert.Nil(t, negatedSources)
+}
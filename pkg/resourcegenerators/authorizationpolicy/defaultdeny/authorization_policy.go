@@ -0,0 +1,50 @@
+package defaultdeny
+
+import (
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/targetref"
+	"istio.io/api/security/v1beta1"
+	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GetDesired returns an AuthorizationPolicy allowing nothing for all workloads in a namespace selected by the
+// ClusterAuthPolicy. As ALLOW policies are combined, workloads protected by an AuthPolicy are still reachable through
+// the AuthorizationPolicies of the AuthPolicy, while requests to any other workload are denied.
+func GetDesired(
+	clusterAuthPolicy *v1alpha1.ClusterAuthPolicy,
+	objectMeta v1.ObjectMeta,
+) *istioclientsecurityv1.AuthorizationPolicy {
+	if !clusterAuthPolicy.Spec.DefaultDeny {
+		return nil
+	}
+
+	return &istioclientsecurityv1.AuthorizationPolicy{
+		ObjectMeta: objectMeta,
+		Spec: v1beta1.AuthorizationPolicy{
+			// An ALLOW policy without rules matches no request
+			Action: v1beta1.AuthorizationPolicy_ALLOW,
+		},
+	}
+}
+
+// GetDesiredExemption returns an AuthorizationPolicy allowing all requests to the workloads of an AuthPolicy which is
+// exempt from the default deny, as the AuthorizationPolicies of the AuthPolicy are either not enforced or not
+// generated at all. The exemption itself is always enforced, as it must outweigh the default deny.
+func GetDesiredExemption(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecurityv1.AuthorizationPolicy {
+	if !scope.IsExemptFromDefaultDeny() {
+		return nil
+	}
+
+	return &istioclientsecurityv1.AuthorizationPolicy{
+		ObjectMeta: objectMeta,
+		Spec: v1beta1.AuthorizationPolicy{
+			Action:     v1beta1.AuthorizationPolicy_ALLOW,
+			Selector:   targetref.GetWorkloadSelector(&scope.AuthPolicy),
+			TargetRefs: targetref.GetPolicyTargetReferences(&scope.AuthPolicy),
+			// A rule without any fields matches every request
+			Rules: []*v1beta1.Rule{{}},
+		},
+	}
+}
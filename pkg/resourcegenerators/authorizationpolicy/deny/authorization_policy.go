@@ -1,19 +1,17 @@
 package deny
 
 import (
-	"fmt"
 	"slices"
 
 	"github.com/kartverket/ztoperator/internal/state"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy"
-	"github.com/kartverket/ztoperator/pkg/validation"
 	"istio.io/api/security/v1beta1"
 	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecurityv1.AuthorizationPolicy {
-	if !scope.AuthPolicy.Spec.Enabled {
+	if !scope.IsEnabled() {
 		// AuthPolicy disabled, no deny rules to create
 		return nil
 	}
@@ -41,55 +39,66 @@ func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecuri
 
 	// Create deny rules based on the specified auth rules
 
-	baseDenyConditions := constructBaseConditionsForDenyPolicy(scope)
+	baselineAuthDenyConditions := authorizationpolicy.GetBaselineAuthConditionsForDenyPolicy(
+		scope.AuthPolicy.Spec.BaselineAuth,
+	)
+	var baselineAuthDenyConditionGroups [][]*v1beta1.Condition
+	if scope.AuthPolicy.Spec.BaselineAuth != nil {
+		baselineAuthDenyConditionGroups = authorizationpolicy.GetConditionGroupsForDenyPolicy(
+			scope.AuthPolicy.Spec.BaselineAuth.AnyOf,
+		)
+	}
 
 	var denyRules []*v1beta1.Rule
 	for _, rule := range *scope.AuthPolicy.Spec.AuthRules {
 		// Audience and issuer conditions are always included
-		authPolicyDenyConditions := baseDenyConditions
+		authPolicyDenyConditionSets := authorizationpolicy.GetIdentityProviderConditionsForDenyPolicy(
+			scope.GetIdentityProvidersForAuthRule(rule),
+		)
+		// Additional conditions from baseline auth
+		for _, condition := range baselineAuthDenyConditions {
+			authPolicyDenyConditionSets = append(authPolicyDenyConditionSets, []*v1beta1.Condition{condition})
+		}
+		authPolicyDenyConditionSets = append(authPolicyDenyConditionSets, baselineAuthDenyConditionGroups...)
 		// Additional conditions from the "when" clause
 		if rule.When != nil {
-			for _, condition := range *rule.When {
-				authPolicyDenyConditions = append(
-					authPolicyDenyConditions,
-					&v1beta1.Condition{
-						Key:       fmt.Sprintf("request.auth.claims[%s]", condition.Claim),
-						NotValues: condition.Values, // NB! NotValues used in combination with deny rule
-					},
-				)
+			for _, condition := range authorizationpolicy.GetClaimConditionsForDenyPolicy(*rule.When) {
+				authPolicyDenyConditionSets = append(authPolicyDenyConditionSets, []*v1beta1.Condition{condition})
 			}
 		}
-		// Create one rule per condition
-		for _, istioCondition := range authPolicyDenyConditions {
-			denyRules = append(denyRules, &v1beta1.Rule{
-				To: []*v1beta1.Rule_To{
-					{
-						Operation: &v1beta1.Operation{
-							Paths:   validation.TransformPathsForIstio(rule.Paths),
-							Methods: rule.Methods,
+		// Additional groups of conditions from the "anyOf" clause
+		if rule.AnyOf != nil {
+			authPolicyDenyConditionSets = append(
+				authPolicyDenyConditionSets,
+				authorizationpolicy.GetConditionGroupsForDenyPolicy(*rule.AnyOf)...,
+			)
+		}
+		// Requests originating from any of the sources of the "from" clause are never denied,
+		// resulting in one rule per negated source
+		ruleFromList := [][]*v1beta1.Rule_From{nil}
+		if rule.From != nil && len(*rule.From) > 0 {
+			ruleFromList = nil
+			for _, negatedSource := range authorizationpolicy.GetSourcesForDenyPolicy(*rule.From) {
+				ruleFromList = append(ruleFromList, []*v1beta1.Rule_From{{Source: negatedSource}})
+			}
+		}
+		// Create one rule per set of conditions.
+		// Header conditions restrict which requests the auth rule applies to, and are thus included in every rule.
+		headerConditions := authorizationpolicy.GetHeaderConditionsForAllowPolicy(rule.RequestMatcher)
+		for _, istioConditions := range authPolicyDenyConditionSets {
+			for _, ruleFrom := range ruleFromList {
+				denyRules = append(denyRules, &v1beta1.Rule{
+					From: ruleFrom,
+					To: []*v1beta1.Rule_To{
+						{
+							Operation: authorizationpolicy.GetOperation(rule.RequestMatcher),
 						},
 					},
-				},
-				When: []*v1beta1.Condition{istioCondition},
-			})
+					When: slices.Concat(istioConditions, headerConditions),
+				})
+			}
 		}
 	}
 
 	return authorizationpolicy.DenyAuthorizationPolicy(scope, objectMeta, denyRules)
 }
-
-/*
-Audience and issuer conditions are always included as base conditions.
-Additionally, any conditions specified as baseline auth are also included.
-*/
-func constructBaseConditionsForDenyPolicy(scope *state.Scope) []*v1beta1.Condition {
-	audienceAndIssuerConditions := authorizationpolicy.GetAudienceAndIssuerConditionsForDenyPolicy(
-		authorizationpolicy.ConstructAcceptedResources(*scope),
-		scope.IdentityProviderUris.IssuerURI,
-	)
-	baselineAuthConditions := authorizationpolicy.GetBaselineAuthConditionsForDenyPolicy(
-		scope.AuthPolicy.Spec.BaselineAuth,
-	)
-	allBaseConditions := slices.Concat(audienceAndIssuerConditions, baselineAuthConditions)
-	return allBaseConditions
-}
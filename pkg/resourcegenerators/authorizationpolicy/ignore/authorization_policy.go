@@ -3,14 +3,13 @@ package ignore
 import (
 	"github.com/kartverket/ztoperator/internal/state"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy"
-	"github.com/kartverket/ztoperator/pkg/validation"
 	"istio.io/api/security/v1beta1"
 	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecurityv1.AuthorizationPolicy {
-	if !scope.AuthPolicy.Spec.Enabled {
+	if !scope.IsEnabled() {
 		return nil
 	}
 
@@ -26,26 +25,31 @@ func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecuri
 		return nil
 	}
 
-	// Create allow rules based on the specified ignore auth rules
+	// Create allow rules based on the specified ignore auth rules.
+	// Matchers without header conditions share a single rule, while each matcher with header conditions
+	// requires a separate rule, as conditions apply to all operations of a rule.
 
 	var ruleToList []*v1beta1.Rule_To
+	var headerRules []*v1beta1.Rule
 	for _, ignoreAuthRequestMatcher := range ignoreAuthRequestMatchers {
 		ruleTo := &v1beta1.Rule_To{
-			Operation: &v1beta1.Operation{
-				Paths:   validation.TransformPathsForIstio(ignoreAuthRequestMatcher.Paths),
-				Methods: ignoreAuthRequestMatcher.Methods,
-			},
+			Operation: authorizationpolicy.GetOperation(ignoreAuthRequestMatcher),
+		}
+		if len(ignoreAuthRequestMatcher.Headers) > 0 {
+			headerRules = append(headerRules, &v1beta1.Rule{
+				To:   []*v1beta1.Rule_To{ruleTo},
+				When: authorizationpolicy.GetHeaderConditionsForAllowPolicy(ignoreAuthRequestMatcher),
+			})
+			continue
 		}
 		ruleToList = append(ruleToList, ruleTo)
 	}
 
-	return authorizationpolicy.AllowAuthorizationPolicy(
-		scope,
-		objectMeta,
-		[]*v1beta1.Rule{
-			{
-				To: ruleToList,
-			},
-		},
-	)
+	var rules []*v1beta1.Rule
+	if len(ruleToList) > 0 {
+		rules = append(rules, &v1beta1.Rule{To: ruleToList})
+	}
+	rules = append(rules, headerRules...)
+
+	return authorizationpolicy.AllowAuthorizationPolicy(scope, objectMeta, rules)
 }
@@ -1,7 +1,6 @@
 package require
 
 import (
-	"fmt"
 	"slices"
 
 	"github.com/kartverket/ztoperator/api/v1alpha1"
@@ -14,7 +13,7 @@ import (
 )
 
 func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecurityv1.AuthorizationPolicy {
-	if !scope.AuthPolicy.Spec.Enabled {
+	if !scope.IsEnabled() {
 		return nil
 	}
 
@@ -23,17 +22,18 @@ func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecuri
 		return nil
 	}
 
-	baseConditions := constructBaseConditions(scope)
+	baseConditionSets := constructBaseConditionSets(scope, scope.GetTrustedIdentityProviders())
 
 	hasAuthRules := scope.AuthPolicy.Spec.AuthRules != nil && len(*scope.AuthPolicy.Spec.AuthRules) > 0
 	hasIgnoreAuthRules := scope.AuthPolicy.Spec.IgnoreAuthRules != nil &&
 		len(*scope.AuthPolicy.Spec.IgnoreAuthRules) > 0
 
 	if !hasAuthRules && !hasIgnoreAuthRules {
-		// If there are no specific auth rules or ignore rules, we create an allow-rule
+		// If there are no specific auth rules or ignore rules, we create an allow-rule per trusted issuer
 		// matching all paths and all methods, with validation of audience and issuer.
-		allPathsRule := []*v1beta1.Rule{
-			{
+		allPathsRules := make([]*v1beta1.Rule, 0, len(baseConditionSets))
+		for _, baseConditions := range baseConditionSets {
+			allPathsRules = append(allPathsRules, &v1beta1.Rule{
 				To: []*v1beta1.Rule_To{
 					{
 						Operation: &v1beta1.Operation{
@@ -42,16 +42,18 @@ func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecuri
 					},
 				},
 				When: baseConditions,
-			},
+			})
 		}
-		return authorizationpolicy.AllowAuthorizationPolicy(scope, objectMeta, allPathsRule)
+		return authorizationpolicy.AllowAuthorizationPolicy(scope, objectMeta, allPathsRules)
 	}
 
-	specifiedPathsRules := constructSpecifiedPathsAllowRules(scope, baseConditions)
-
-	unspecifiedPathsRule := constructUnspecifiedPathsAllowRule(scope, baseConditions)
+	specifiedPathsRules := constructSpecifiedPathsAllowRules(scope)
 
-	allAllowRules := append([]*v1beta1.Rule{unspecifiedPathsRule}, specifiedPathsRules...)
+	var allAllowRules []*v1beta1.Rule
+	for _, baseConditions := range baseConditionSets {
+		allAllowRules = append(allAllowRules, constructUnspecifiedPathsAllowRules(scope, baseConditions)...)
+	}
+	allAllowRules = append(allAllowRules, specifiedPathsRules...)
 	return authorizationpolicy.AllowAuthorizationPolicy(
 		scope,
 		objectMeta,
@@ -60,69 +62,103 @@ func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecuri
 }
 
 /*
-Audience and issuer conditions are always included as base conditions.
-Additionally, any conditions specified as baseline auth are also included.
+Audience and issuer conditions are always included as base conditions,
+resulting in one set of conditions per trusted issuer.
+Additionally, any conditions specified as baseline auth are included in every set.
+If baseline auth specifies groups of conditions, every set is combined with each of the groups.
 */
-func constructBaseConditions(scope *state.Scope) []*v1beta1.Condition {
-	audienceAndIssuerConditions := authorizationpolicy.GetAudienceAndIssuerConditionsForAllowPolicy(
-		authorizationpolicy.ConstructAcceptedResources(*scope),
-		scope.IdentityProviderUris.IssuerURI,
-	)
+func constructBaseConditionSets(
+	scope *state.Scope,
+	identityProviders []state.IdentityProvider,
+) [][]*v1beta1.Condition {
+	audienceAndIssuerConditionSets := authorizationpolicy.GetIdentityProviderConditionsForAllowPolicy(identityProviders)
+	if len(audienceAndIssuerConditionSets) == 0 {
+		// Without any trusted issuer, no request should be allowed
+		return nil
+	}
+
 	baselineAuthConditions := authorizationpolicy.GetBaselineAuthConditionsForAllowPolicy(
 		scope.AuthPolicy.Spec.BaselineAuth,
 	)
+	var baselineAuthConditionGroups [][]*v1beta1.Condition
+	if scope.AuthPolicy.Spec.BaselineAuth != nil {
+		baselineAuthConditionGroups = authorizationpolicy.GetConditionGroupsForAllowPolicy(
+			scope.AuthPolicy.Spec.BaselineAuth.AnyOf,
+		)
+	}
 
-	allBaseConditions := slices.Concat(audienceAndIssuerConditions, baselineAuthConditions)
-	return allBaseConditions
+	return authorizationpolicy.CombineConditionSetsForAllowPolicy(
+		audienceAndIssuerConditionSets,
+		[][]*v1beta1.Condition{baselineAuthConditions},
+		baselineAuthConditionGroups,
+	)
 }
 
 /*
-Each auth rule should result in an allow rule for the specified paths, methods and conditions.
-Additionally, the audience and issuer conditions are always included.
+Each auth rule should result in an allow rule per accepted issuer for the specified paths, methods, hosts and
+conditions. Additionally, the audience and issuer conditions and any header conditions of the auth rule are always
+included.
+If the auth rule specifies groups of conditions, an allow rule is created for each of the groups.
+If the auth rule specifies sources, a separate allow rule without JWT conditions is created for them.
 */
-func constructSpecifiedPathsAllowRules(
-	scope *state.Scope,
-	audienceAndIssuerConditions []*v1beta1.Condition,
-) []*v1beta1.Rule {
+func constructSpecifiedPathsAllowRules(scope *state.Scope) []*v1beta1.Rule {
 	var specifiedPathsAllowRules []*v1beta1.Rule
 	if scope.AuthPolicy.Spec.AuthRules != nil {
 		for _, authRule := range *scope.AuthPolicy.Spec.AuthRules {
-			authPolicyConditionsAsIstioConditions := audienceAndIssuerConditions
-			if authRule.When != nil {
-				for _, condition := range *authRule.When {
-					authPolicyConditionsAsIstioConditions = append(
-						authPolicyConditionsAsIstioConditions,
-						&v1beta1.Condition{
-							Key:    fmt.Sprintf("request.auth.claims[%s]", condition.Claim),
-							Values: condition.Values,
+			if authRule.From != nil && len(*authRule.From) > 0 {
+				// Requests originating from any of the sources are allowed without a JWT
+				specifiedPathsAllowRules = append(specifiedPathsAllowRules, &v1beta1.Rule{
+					From: authorizationpolicy.GetSourcesForAllowPolicy(*authRule.From),
+					To: []*v1beta1.Rule_To{
+						{
+							Operation: authorizationpolicy.GetOperation(authRule.RequestMatcher),
 						},
-					)
-				}
+					},
+					When: authorizationpolicy.GetHeaderConditionsForAllowPolicy(authRule.RequestMatcher),
+				})
 			}
-			specifiedPathsAllowRules = append(specifiedPathsAllowRules, &v1beta1.Rule{
-				To: []*v1beta1.Rule_To{
-					{
-						Operation: &v1beta1.Operation{
-							Paths:   validation.TransformPathsForIstio(authRule.Paths),
-							Methods: authRule.Methods,
+			baseConditionSets := constructBaseConditionSets(scope, scope.GetIdentityProvidersForAuthRule(authRule))
+			if len(baseConditionSets) == 0 {
+				continue
+			}
+			var whenConditions []*v1beta1.Condition
+			if authRule.When != nil {
+				whenConditions = authorizationpolicy.GetClaimConditionsForAllowPolicy(*authRule.When)
+			}
+			var whenConditionGroups [][]*v1beta1.Condition
+			if authRule.AnyOf != nil {
+				whenConditionGroups = authorizationpolicy.GetConditionGroupsForAllowPolicy(*authRule.AnyOf)
+			}
+			for _, conditions := range authorizationpolicy.CombineConditionSetsForAllowPolicy(
+				baseConditionSets,
+				[][]*v1beta1.Condition{whenConditions},
+				whenConditionGroups,
+				[][]*v1beta1.Condition{authorizationpolicy.GetHeaderConditionsForAllowPolicy(authRule.RequestMatcher)},
+			) {
+				specifiedPathsAllowRules = append(specifiedPathsAllowRules, &v1beta1.Rule{
+					To: []*v1beta1.Rule_To{
+						{
+							Operation: authorizationpolicy.GetOperation(authRule.RequestMatcher),
 						},
 					},
-				},
-				When: authPolicyConditionsAsIstioConditions,
-			})
+					When: conditions,
+				})
+			}
 		}
 	}
 	return specifiedPathsAllowRules
 }
 
 /*
-All paths and methods not explicitly specified in any auth rule or ignore auth rule
-should result in an allow rule with only audience and issuer conditions.
+All requests not covered by any auth rule or ignore auth rule should be allowed with only the base conditions.
+A request to the paths of a matcher is not covered by it if its method, host or headers do not match.
+Requests not covered due to their headers are allowed by separate rules, one per negated header condition,
+as conditions apply to all operations of a rule.
 */
-func constructUnspecifiedPathsAllowRule(
+func constructUnspecifiedPathsAllowRules(
 	scope *state.Scope,
-	audienceAndIssuerConditions []*v1beta1.Condition,
-) *v1beta1.Rule {
+	baseConditions []*v1beta1.Condition,
+) []*v1beta1.Rule {
 	allRequestMatchers := append(
 		scope.AuthPolicy.GetRequireAuthRequestMatchers(),
 		scope.AuthPolicy.GetIgnoreAuthRequestMatchers()...,
@@ -130,19 +166,54 @@ func constructUnspecifiedPathsAllowRule(
 
 	// +1 for the rule that allows all paths and methods not defined in any matcher
 	unspecifiedPathsRuleList := make([]*v1beta1.Rule_To, 0, len(allRequestMatchers)+1)
+	var unspecifiedHeadersRules []*v1beta1.Rule
 
-	// For all request matchers, create to-rules for all methods not defined in the matcher
 	for _, matcher := range allRequestMatchers {
+		paths := validation.TransformPathsForIstio(matcher.Paths)
+
+		// Create to-rules for all methods not defined in the matcher
 		methods := matcher.Methods
 		if len(matcher.Methods) == 0 {
 			methods = v1alpha1.GetAcceptedHTTPMethods()
 		}
 		unspecifiedPathsRuleList = append(unspecifiedPathsRuleList, &v1beta1.Rule_To{
 			Operation: &v1beta1.Operation{
-				Paths:      validation.TransformPathsForIstio(matcher.Paths),
+				Paths:      paths,
 				NotMethods: methods, // NB: NotMethods used to create to-rules for all methods not defined in a matcher
 			},
 		})
+
+		// Create to-rules for all hosts not defined in the matcher
+		if len(matcher.Hosts) > 0 {
+			unspecifiedPathsRuleList = append(unspecifiedPathsRuleList, &v1beta1.Rule_To{
+				Operation: &v1beta1.Operation{
+					Paths:    paths,
+					NotHosts: matcher.Hosts, // NB: NotHosts used to create to-rules for all hosts not defined in a matcher
+				},
+			})
+		}
+		if len(matcher.NotHosts) > 0 {
+			unspecifiedPathsRuleList = append(unspecifiedPathsRuleList, &v1beta1.Rule_To{
+				Operation: &v1beta1.Operation{
+					Paths: paths,
+					Hosts: matcher.NotHosts, // NB: Hosts used to create to-rules for all hosts excluded by a matcher
+				},
+			})
+		}
+
+		// Create rules for all requests not meeting the header conditions of the matcher
+		for _, headerCondition := range authorizationpolicy.GetHeaderConditionsForComplementPolicy(matcher) {
+			unspecifiedHeadersRules = append(unspecifiedHeadersRules, &v1beta1.Rule{
+				To: []*v1beta1.Rule_To{
+					{
+						Operation: &v1beta1.Operation{
+							Paths: paths,
+						},
+					},
+				},
+				When: append(slices.Clone(baseConditions), headerCondition),
+			})
+		}
 	}
 
 	// For all request matchers, create to-rules for all paths not defined in a matcher
@@ -157,10 +228,10 @@ func constructUnspecifiedPathsAllowRule(
 		},
 	})
 
-	// Create allow rule for all unspecified paths and methods, with audience and issuer conditions
+	// Create allow rule for all unspecified paths, methods and hosts, with base conditions
 	unspecifiedPathsAllowRule := &v1beta1.Rule{
 		To:   unspecifiedPathsRuleList,
-		When: audienceAndIssuerConditions,
+		When: baseConditions,
 	}
-	return unspecifiedPathsAllowRule
+	return append([]*v1beta1.Rule{unspecifiedPathsAllowRule}, unspecifiedHeadersRules...)
 }
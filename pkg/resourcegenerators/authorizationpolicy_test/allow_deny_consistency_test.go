@@ -0,0 +1,511 @@
+package authorizationpolicytest_test
+
+import (
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/deny"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/ignore"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/require"
+	"github.com/stretchr/testify/assert"
+	testifyrequire "github.com/stretchr/testify/require"
+	"istio.io/api/security/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	consistencyIssuer      = "https://issuer.example.com"
+	consistencyOtherIssuer = "https://other-issuer.example.com"
+	consistencyAudience    = "my-audience"
+	consistencyPath        = "/api"
+	consistencyMethod      = "GET"
+)
+
+// request is a simplified representation of an authenticated request, holding the claims of its JWT.
+// A claim or header missing from the maps is absent from the token or request.
+// An empty principal denotes a request without mesh mTLS.
+type request struct {
+	path      string
+	method    string
+	host      string
+	headers   map[string]string
+	claims    map[string][]string
+	principal string
+	ip        string
+}
+
+func TestAllowAndDenyAgree_WithAnyOfInAuthRule(t *testing.T) {
+	// 1. Arrange
+	scope := consistencyScope()
+	scope.AuthPolicy.Spec.AuthRules = &[]v1alpha1.RequestAuthRule{
+		{
+			RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{consistencyPath}, Methods: []string{consistencyMethod}},
+			AnyOf: &[]v1alpha1.ConditionGroup{
+				{AllOf: []v1alpha1.Condition{{Claim: "role", Values: []string{"admin"}}}},
+				{AllOf: []v1alpha1.Condition{
+					{Claim: "group", Values: []string{"ops"}},
+					{Claim: "acr", Values: []string{"high"}},
+				}},
+			},
+		},
+	}
+	requests := enumerateRequests(map[string][][]string{
+		"iss":   {nil, {consistencyIssuer}},
+		"aud":   {nil, {consistencyAudience}, {"other"}},
+		"role":  {nil, {"admin"}, {"user"}},
+		"group": {nil, {"ops"}, {"dev"}},
+		"acr":   {nil, {"high"}, {"low"}},
+	})
+
+	// 2. Act & 3. Assert
+	assertAllowAndDenyAgree(t, &scope, requests, func(r request) bool {
+		return hasIssuerAndAudience(r) &&
+			(hasClaim(r, "role", "admin") || (hasClaim(r, "group", "ops") && hasClaim(r, "acr", "high")))
+	})
+}
+
+func TestAllowAndDenyAgree_WithAnyOfCombinedWithWhenAndBaselineAuth(t *testing.T) {
+	// 1. Arrange
+	scope := consistencyScope()
+	scope.AuthPolicy.Spec.BaselineAuth = &v1alpha1.BaselineAuth{
+		AnyOf: []v1alpha1.ConditionGroup{
+			{AllOf: []v1alpha1.Condition{{Claim: "pid", Present: helperfunctions.Ptr(true)}}},
+			{AllOf: []v1alpha1.Condition{{Claim: "client_id", NotValues: []string{"blocked-*"}}}},
+		},
+	}
+	scope.AuthPolicy.Spec.AuthRules = &[]v1alpha1.RequestAuthRule{
+		{
+			RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{consistencyPath}, Methods: []string{consistencyMethod}},
+			When:           &[]v1alpha1.Condition{{Claim: "acr", NotValues: []string{"low"}}},
+			AnyOf: &[]v1alpha1.ConditionGroup{
+				{AllOf: []v1alpha1.Condition{{Claim: "role", Values: []string{"admin", "*-owner"}}}},
+				{AllOf: []v1alpha1.Condition{
+					{Claim: "groups", Values: []string{"team-*"}},
+					{Claim: "act", Present: helperfunctions.Ptr(false)},
+				}},
+			},
+		},
+	}
+	requests := enumerateRequests(map[string][][]string{
+		"iss":       {{consistencyIssuer}},
+		"aud":       {{consistencyAudience}},
+		"pid":       {nil, {"12345678910"}},
+		"client_id": {nil, {"blocked-client"}, {"good-client"}},
+		"acr":       {nil, {"low"}, {"high"}},
+		"role":      {nil, {"admin"}, {"repo-owner"}, {"user"}},
+		"groups":    {nil, {"team-a"}, {"other", "team-b"}, {"other"}},
+		"act":       {nil, {"actor"}},
+	})
+
+	// 2. Act & 3. Assert
+	assertAllowAndDenyAgree(t, &scope, requests, func(r request) bool {
+		baseline := hasClaim(r, "pid", "*") || !hasClaim(r, "client_id", "blocked-*")
+		when := !hasClaim(r, "acr", "low")
+		anyOf := hasClaim(r, "role", "admin") || hasClaim(r, "role", "*-owner") ||
+			(hasClaim(r, "groups", "team-*") && !hasClaim(r, "act", "*"))
+		return hasIssuerAndAudience(r) && baseline && when && anyOf
+	})
+}
+
+func TestAllowAndDenyAgree_WithAnyOfAndMultipleIdentityProviders(t *testing.T) {
+	// 1. Arrange
+	scope := consistencyScope()
+	scope.AuthPolicy.Spec.IdentityProviders = []v1alpha1.TrustedIdentityProvider{{Name: "other"}}
+	scope.IdentityProviders = []state.IdentityProvider{
+		{
+			Name:                 "other",
+			IdentityProviderUris: state.IdentityProviderUris{IssuerURI: consistencyOtherIssuer},
+		},
+	}
+	scope.AuthPolicy.Spec.AuthRules = &[]v1alpha1.RequestAuthRule{
+		{
+			RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{consistencyPath}, Methods: []string{consistencyMethod}},
+			AnyOf: &[]v1alpha1.ConditionGroup{
+				{AllOf: []v1alpha1.Condition{{Claim: "role", Values: []string{"admin"}}}},
+				{AllOf: []v1alpha1.Condition{{Claim: "scope", Values: []string{"write"}}}},
+			},
+		},
+	}
+	requests := enumerateRequests(map[string][][]string{
+		"iss":   {nil, {consistencyIssuer}, {consistencyOtherIssuer}, {"https://unknown.example.com"}},
+		"aud":   {nil, {consistencyAudience}, {"other"}},
+		"role":  {nil, {"admin"}, {"user"}},
+		"scope": {nil, {"write"}, {"read"}},
+	})
+
+	// 2. Act & 3. Assert
+	assertAllowAndDenyAgree(t, &scope, requests, func(r request) bool {
+		trustedIssuer := hasIssuerAndAudience(r) || hasClaim(r, "iss", consistencyOtherIssuer)
+		return trustedIssuer && (hasClaim(r, "role", "admin") || hasClaim(r, "scope", "write"))
+	})
+}
+
+func TestAccessDecision_WithHostsAndHeaders(t *testing.T) {
+	// 1. Arrange
+	scope := consistencyScope()
+	scope.AuthPolicy.Spec.AuthRules = &[]v1alpha1.RequestAuthRule{
+		{
+			RequestMatcher: v1alpha1.RequestMatcher{
+				Paths:   []string{consistencyPath},
+				Hosts:   []string{"api.example.com"},
+				Headers: []v1alpha1.HeaderMatcher{{Name: "Accept", NotValues: []string{"text/html*"}}},
+			},
+			When: &[]v1alpha1.Condition{{Claim: "role", Values: []string{"admin"}}},
+		},
+	}
+	scope.AuthPolicy.Spec.IgnoreAuthRules = &[]v1alpha1.RequestMatcher{
+		{
+			Paths:   []string{consistencyPath},
+			Headers: []v1alpha1.HeaderMatcher{{Name: "X-Api-Key", Values: []string{"*"}}},
+		},
+		{Paths: []string{"/docs"}, NotHosts: []string{"internal.*"}},
+	}
+	requests := withPathsHostsAndHeaders(
+		enumerateRequests(map[string][][]string{
+			"iss":  {nil, {consistencyIssuer}},
+			"aud":  {{consistencyAudience}},
+			"role": {nil, {"admin"}, {"user"}},
+		}),
+		[]string{consistencyPath, "/docs", "/other"},
+		[]string{"api.example.com", "API.example.com", "www.example.com", "internal.example.com"},
+		map[string][]string{
+			"accept":    {"", "application/json", "text/html"},
+			"x-api-key": {"", "key"},
+		},
+	)
+
+	// 2. Act & 3. Assert
+	assertAccessDecision(t, &scope, requests, func(r request) bool {
+		authRuleApplies := r.path == consistencyPath && strings.EqualFold(r.host, "api.example.com") &&
+			!strings.HasPrefix(r.headers["accept"], "text/html")
+		ignoreAuthRuleApplies := (r.path == consistencyPath && r.headers["x-api-key"] != "") ||
+			(r.path == "/docs" && !strings.HasPrefix(r.host, "internal."))
+		switch {
+		case authRuleApplies:
+			return hasIssuerAndAudience(r) && hasClaim(r, "role", "admin")
+		case ignoreAuthRuleApplies:
+			return true
+		default:
+			return hasIssuerAndAudience(r)
+		}
+	})
+}
+
+func TestAllowAndDenyAgree_WithSourcesInAuthRule(t *testing.T) {
+	// 1. Arrange
+	scope := consistencyScope()
+	scope.AuthPolicy.Spec.AuthRules = &[]v1alpha1.RequestAuthRule{
+		{
+			RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{consistencyPath}, Methods: []string{consistencyMethod}},
+			When:           &[]v1alpha1.Condition{{Claim: "role", Values: []string{"admin"}}},
+			From: &[]v1alpha1.Source{
+				{Namespaces: []string{"batch"}},
+				{ServiceAccounts: []string{"monitoring/prometheus"}, IPBlocks: []string{"10.0.0.0/8"}},
+			},
+		},
+	}
+	requests := withSources(
+		enumerateRequests(map[string][][]string{
+			"iss":  {nil, {consistencyIssuer}},
+			"aud":  {{consistencyAudience}},
+			"role": {nil, {"admin"}, {"user"}},
+		}),
+		[]string{
+			"",
+			"cluster.local/ns/batch/sa/report-job",
+			"cluster.local/ns/monitoring/sa/prometheus",
+			"cluster.local/ns/monitoring/sa/grafana",
+		},
+		[]string{"10.1.2.3", "192.168.0.1"},
+	)
+
+	// 2. Act & 3. Assert
+	assertAllowAndDenyAgree(t, &scope, requests, func(r request) bool {
+		fromSource := namespaceOfPrincipal(r.principal) == "batch" ||
+			(strings.HasSuffix(r.principal, "/ns/monitoring/sa/prometheus") && strings.HasPrefix(r.ip, "10."))
+		return fromSource || (hasIssuerAndAudience(r) && hasClaim(r, "role", "admin"))
+	})
+}
+
+func consistencyScope() state.Scope {
+	return state.Scope{
+		AuthPolicy: v1alpha1.AuthPolicy{
+			Spec: v1alpha1.AuthPolicySpec{
+				Enabled:      true,
+				WellKnownURI: consistencyIssuer + "/.well-known/openid-configuration",
+			},
+		},
+		Audiences:            []string{consistencyAudience},
+		IdentityProviderUris: state.IdentityProviderUris{IssuerURI: consistencyIssuer},
+	}
+}
+
+// assertAllowAndDenyAgree verifies that, for every request, the generated allow policy matches exactly when the
+// generated deny policy does not, and that both agree with the expected outcome.
+func assertAllowAndDenyAgree(t *testing.T, scope *state.Scope, requests []request, expected func(request) bool) {
+	t.Helper()
+	objectMeta := metav1.ObjectMeta{Name: "consistency", Namespace: "default"}
+	allowPolicy := require.GetDesired(scope, objectMeta)
+	denyPolicy := deny.GetDesired(scope, objectMeta)
+	testifyrequire.NotNil(t, allowPolicy)
+	testifyrequire.NotNil(t, denyPolicy)
+
+	for _, r := range requests {
+		allowed := anyRuleMatches(t, allowPolicy.Spec.Rules, r)
+		denied := anyRuleMatches(t, denyPolicy.Spec.Rules, r)
+		assert.Equal(
+			t,
+			allowed,
+			!denied,
+			"allow and deny policies disagree for claims %v from principal %q and ip %s",
+			r.claims,
+			r.principal,
+			r.ip,
+		)
+		assert.Equal(
+			t,
+			expected(r),
+			allowed,
+			"unexpected outcome for claims %v from principal %q and ip %s",
+			r.claims,
+			r.principal,
+			r.ip,
+		)
+	}
+}
+
+// assertAccessDecision verifies that, for every request, the combination of the generated deny, allow and ignore
+// policies agrees with the expected outcome. As in Istio, a request is allowed if no deny rule matches and any
+// allow rule matches.
+func assertAccessDecision(t *testing.T, scope *state.Scope, requests []request, expected func(request) bool) {
+	t.Helper()
+	objectMeta := metav1.ObjectMeta{Name: "consistency", Namespace: "default"}
+	allowPolicy := require.GetDesired(scope, objectMeta)
+	denyPolicy := deny.GetDesired(scope, objectMeta)
+	ignorePolicy := ignore.GetDesired(scope, objectMeta)
+	testifyrequire.NotNil(t, allowPolicy)
+	testifyrequire.NotNil(t, denyPolicy)
+	testifyrequire.NotNil(t, ignorePolicy)
+
+	for _, r := range requests {
+		denied := anyRuleMatches(t, denyPolicy.Spec.Rules, r)
+		allowed := anyRuleMatches(t, allowPolicy.Spec.Rules, r) || anyRuleMatches(t, ignorePolicy.Spec.Rules, r)
+		assert.Equal(
+			t,
+			expected(r),
+			!denied && allowed,
+			"unexpected outcome for %s on host %s with headers %v and claims %v",
+			r.path,
+			r.host,
+			r.headers,
+			r.claims,
+		)
+	}
+}
+
+// withPathsHostsAndHeaders returns a copy of the requests for every combination of the given paths, hosts and
+// header values. An empty value represents a missing header.
+func withPathsHostsAndHeaders(
+	requests []request,
+	paths []string,
+	hosts []string,
+	headerValues map[string][]string,
+) []request {
+	var expanded []request
+	for _, r := range requests {
+		for _, path := range paths {
+			for _, host := range hosts {
+				expanded = append(expanded, request{
+					path:    path,
+					method:  r.method,
+					host:    host,
+					headers: map[string]string{},
+					claims:  r.claims,
+				})
+			}
+		}
+	}
+	for header, values := range headerValues {
+		next := make([]request, 0, len(expanded)*len(values))
+		for _, r := range expanded {
+			for _, value := range values {
+				headers := maps.Clone(r.headers)
+				if value != "" {
+					headers[header] = value
+				}
+				next = append(next, request{path: r.path, method: r.method, host: r.host, headers: headers, claims: r.claims})
+			}
+		}
+		expanded = next
+	}
+	return expanded
+}
+
+// enumerateRequests returns a request to the auth rule path for every combination of the given claim values.
+// A nil value represents a missing claim.
+func enumerateRequests(claimValues map[string][][]string) []request {
+	requests := []request{{path: consistencyPath, method: consistencyMethod, claims: map[string][]string{}}}
+	for claim, values := range claimValues {
+		expanded := make([]request, 0, len(requests)*len(values))
+		for _, r := range requests {
+			for _, value := range values {
+				claims := make(map[string][]string, len(r.claims)+1)
+				for k, v := range r.claims {
+					claims[k] = v
+				}
+				if value != nil {
+					claims[claim] = value
+				}
+				expanded = append(expanded, request{path: r.path, method: r.method, claims: claims})
+			}
+		}
+		requests = expanded
+	}
+	return requests
+}
+
+// withSources returns a copy of the requests for every combination of the given principals and IP addresses.
+func withSources(requests []request, principals []string, ips []string) []request {
+	expanded := make([]request, 0, len(requests)*len(principals)*len(ips))
+	for _, r := range requests {
+		for _, principal := range principals {
+			for _, ip := range ips {
+				withSource := r
+				withSource.principal = principal
+				withSource.ip = ip
+				expanded = append(expanded, withSource)
+			}
+		}
+	}
+	return expanded
+}
+
+func hasIssuerAndAudience(r request) bool {
+	return hasClaim(r, "iss", consistencyIssuer) && hasClaim(r, "aud", consistencyAudience)
+}
+
+func hasClaim(r request, claim string, value string) bool {
+	return slices.ContainsFunc(r.claims[claim], func(v string) bool { return istioStringMatch(value, v) })
+}
+
+// The functions below mimic how Istio evaluates rules of an AuthorizationPolicy.
+
+func anyRuleMatches(t *testing.T, rules []*v1beta1.Rule, r request) bool {
+	return slices.ContainsFunc(rules, func(rule *v1beta1.Rule) bool { return ruleMatches(t, rule, r) })
+}
+
+func ruleMatches(t *testing.T, rule *v1beta1.Rule, r request) bool {
+	if len(rule.From) > 0 && !slices.ContainsFunc(rule.From, func(from *v1beta1.Rule_From) bool {
+		return sourceMatches(t, from.Source, r)
+	}) {
+		return false
+	}
+	if len(rule.To) > 0 && !slices.ContainsFunc(rule.To, func(to *v1beta1.Rule_To) bool {
+		return operationMatches(to.Operation, r)
+	}) {
+		return false
+	}
+	for _, condition := range rule.When {
+		if !conditionMatches(t, condition, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func sourceMatches(t *testing.T, source *v1beta1.Source, r request) bool {
+	namespace := namespaceOfPrincipal(r.principal)
+	return matchesAnyOrEmpty(source.Principals, r.principal) &&
+		!matchesAny(source.NotPrincipals, r.principal) &&
+		matchesAnyOrEmpty(source.Namespaces, namespace) &&
+		!matchesAny(source.NotNamespaces, namespace) &&
+		(len(source.IpBlocks) == 0 || containsIP(t, source.IpBlocks, r.ip)) &&
+		!containsIP(t, source.NotIpBlocks, r.ip)
+}
+
+// namespaceOfPrincipal returns the namespace of a SPIFFE principal of the form <trust domain>/ns/<namespace>/sa/<name>.
+func namespaceOfPrincipal(principal string) string {
+	parts := strings.Split(principal, "/")
+	if len(parts) != 5 || parts[1] != "ns" {
+		return ""
+	}
+	return parts[2]
+}
+
+func containsIP(t *testing.T, ipBlocks []string, ip string) bool {
+	return slices.ContainsFunc(ipBlocks, func(ipBlock string) bool {
+		prefix, err := netip.ParsePrefix(ipBlock)
+		testifyrequire.NoError(t, err)
+		return prefix.Contains(netip.MustParseAddr(ip))
+	})
+}
+
+func operationMatches(operation *v1beta1.Operation, r request) bool {
+	host := strings.ToLower(r.host)
+	return matchesAnyOrEmpty(operation.Paths, r.path) &&
+		!matchesAny(operation.NotPaths, r.path) &&
+		matchesAnyOrEmpty(operation.Methods, r.method) &&
+		!matchesAny(operation.NotMethods, r.method) &&
+		matchesAnyOrEmpty(lowercase(operation.Hosts), host) &&
+		!matchesAny(lowercase(operation.NotHosts), host)
+}
+
+func lowercase(values []string) []string {
+	lowercased := make([]string, 0, len(values))
+	for _, value := range values {
+		lowercased = append(lowercased, strings.ToLower(value))
+	}
+	return lowercased
+}
+
+func conditionMatches(t *testing.T, condition *v1beta1.Condition, r request) bool {
+	var values []string
+	if claim, found := strings.CutPrefix(condition.Key, "request.auth.claims["); found {
+		values = r.claims[strings.TrimSuffix(claim, "]")]
+	} else if header, found := strings.CutPrefix(condition.Key, "request.headers["); found {
+		if value, present := r.headers[strings.TrimSuffix(header, "]")]; present {
+			values = []string{value}
+		}
+	} else {
+		testifyrequire.Fail(t, fmt.Sprintf("unsupported condition key %s", condition.Key))
+	}
+
+	matchesValue := func(patterns []string) bool {
+		return slices.ContainsFunc(values, func(v string) bool { return matchesAny(patterns, v) })
+	}
+	if len(condition.Values) > 0 && !matchesValue(condition.Values) {
+		return false
+	}
+	if len(condition.NotValues) > 0 && matchesValue(condition.NotValues) {
+		return false
+	}
+	return true
+}
+
+func matchesAnyOrEmpty(patterns []string, value string) bool {
+	return len(patterns) == 0 || matchesAny(patterns, value)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool { return istioStringMatch(pattern, value) })
+}
+
+func istioStringMatch(pattern string, value string) bool {
+	switch {
+	case pattern == "*":
+		return value != ""
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(value, strings.TrimPrefix(pattern, "*"))
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	default:
+		return pattern == value
+	}
+}
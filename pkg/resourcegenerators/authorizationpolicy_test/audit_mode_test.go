@@ -0,0 +1,73 @@
+package authorizationpolicytest_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/deny"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/ignore"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/require"
+	"github.com/stretchr/testify/assert"
+	testifyrequire "github.com/stretchr/testify/require"
+	"istio.io/api/annotation"
+	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type authorizationPolicyGenerator func(*state.Scope, metav1.ObjectMeta) *istioclientsecurityv1.AuthorizationPolicy
+
+var authorizationPolicyGenerators = map[string]authorizationPolicyGenerator{
+	"deny":    deny.GetDesired,
+	"ignore":  ignore.GetDesired,
+	"require": require.GetDesired,
+}
+
+func auditModeScope(enforcementMode v1alpha1.EnforcementMode) state.Scope {
+	scope := consistencyScope()
+	scope.AuthPolicy.Spec.EnforcementMode = enforcementMode
+	scope.AuthPolicy.Spec.AuthRules = &[]v1alpha1.RequestAuthRule{
+		{
+			RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{consistencyPath}},
+			When:           &[]v1alpha1.Condition{{Claim: "role", Values: []string{"admin"}}},
+		},
+	}
+	scope.AuthPolicy.Spec.IgnoreAuthRules = &[]v1alpha1.RequestMatcher{{Paths: []string{"/public"}}}
+	return scope
+}
+
+func TestAuditMode_GeneratesSameRulesInDryRunMode(t *testing.T) {
+	for name, getDesired := range authorizationPolicyGenerators {
+		t.Run(name, func(t *testing.T) {
+			// 1. Arrange
+			objectMeta := metav1.ObjectMeta{Name: "audit", Namespace: "default"}
+			enforceScope := auditModeScope(v1alpha1.EnforcementModeEnforce)
+			auditScope := auditModeScope(v1alpha1.EnforcementModeAudit)
+
+			// 2. Act
+			enforced := getDesired(&enforceScope, objectMeta)
+			audited := getDesired(&auditScope, objectMeta)
+
+			// 3. Assert
+			testifyrequire.NotNil(t, enforced)
+			testifyrequire.NotNil(t, audited)
+			assert.NotContains(t, enforced.Annotations, annotation.IoIstioDryRun.Name)
+			assert.Equal(t, "true", audited.Annotations[annotation.IoIstioDryRun.Name])
+			assert.Equal(t, enforced.Spec.GetAction(), audited.Spec.GetAction())
+			assert.Equal(t, enforced.Spec.GetRules(), audited.Spec.GetRules())
+		})
+	}
+}
+
+func TestAuditMode_InvalidConfigDenyAllPolicyInDryRunMode(t *testing.T) {
+	// 1. Arrange
+	scope := auditModeScope(v1alpha1.EnforcementModeAudit)
+	scope.InvalidConfig = true
+
+	// 2. Act
+	denyAll := deny.GetDesired(&scope, metav1.ObjectMeta{Name: "audit", Namespace: "default"})
+
+	// 3. Assert
+	testifyrequire.NotNil(t, denyAll)
+	assert.Equal(t, "true", denyAll.Annotations[annotation.IoIstioDryRun.Name])
+}
@@ -0,0 +1,103 @@
+package authorizationpolicytest_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/authorizationpolicy/defaultdeny"
+	"github.com/stretchr/testify/assert"
+	testifyrequire "github.com/stretchr/testify/require"
+	"istio.io/api/annotation"
+	"istio.io/api/security/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDefaultDenyExemption_InAuditMode_AllowsAllRequests(t *testing.T) {
+	// 1. Arrange
+	objectMeta := metav1.ObjectMeta{Name: "exemption", Namespace: "default"}
+	scope := auditModeScope(v1alpha1.EnforcementModeAudit)
+	scope.AuthPolicy.Spec.Selector = &v1alpha1.WorkloadSelector{MatchLabels: map[string]string{"app": "application"}}
+	scope.DefaultDeny = true
+
+	// 2. Act
+	exemption := defaultdeny.GetDesiredExemption(&scope, objectMeta)
+
+	// 3. Assert
+	testifyrequire.NotNil(t, exemption, "Audit mode must not be enforced through the default deny")
+	assert.NotContains(t, exemption.Annotations, annotation.IoIstioDryRun.Name, "The exemption must be enforced")
+	assert.Equal(t, v1beta1.AuthorizationPolicy_ALLOW, exemption.Spec.GetAction())
+	assert.Equal(t, map[string]string{"app": "application"}, exemption.Spec.GetSelector().GetMatchLabels())
+	testifyrequire.Len(t, exemption.Spec.GetRules(), 1)
+	assert.Empty(t, exemption.Spec.GetRules()[0].GetFrom())
+	assert.Empty(t, exemption.Spec.GetRules()[0].GetTo())
+	assert.Empty(t, exemption.Spec.GetRules()[0].GetWhen())
+}
+
+func TestDefaultDenyExemption_WhenDisabledWithoutSharedPods_AllowsAllRequests(t *testing.T) {
+	// 1. Arrange
+	objectMeta := metav1.ObjectMeta{Name: "exemption", Namespace: "default"}
+	scope := auditModeScope(v1alpha1.EnforcementModeEnforce)
+	scope.AuthPolicy.Spec.Enabled = false
+	scope.DefaultDeny = true
+
+	// 2. Act
+	exemption := defaultdeny.GetDesiredExemption(&scope, objectMeta)
+
+	// 3. Assert
+	testifyrequire.NotNil(t, exemption, "A disabled AuthPolicy must not deny all requests through the default deny")
+	testifyrequire.Len(t, exemption.Spec.GetRules(), 1)
+}
+
+func TestDefaultDenyExemption_GeneratesNothing(t *testing.T) {
+	tests := []struct {
+		name            string
+		enforcementMode v1alpha1.EnforcementMode
+		enabled         bool
+		defaultDeny     bool
+		overlapping     *string
+		sharing         *string
+	}{
+		{
+			name:            "when enforced",
+			enforcementMode: v1alpha1.EnforcementModeEnforce,
+			enabled:         true,
+			defaultDeny:     true,
+		},
+		{
+			name:            "without default deny",
+			enforcementMode: v1alpha1.EnforcementModeAudit,
+			enabled:         true,
+		},
+		{
+			name:            "when refused in audit mode",
+			enforcementMode: v1alpha1.EnforcementModeAudit,
+			enabled:         true,
+			defaultDeny:     true,
+			overlapping:     helperfunctions.Ptr("older-policy"),
+		},
+		{
+			name:            "when disabled and sharing pods with an applied AuthPolicy",
+			enforcementMode: v1alpha1.EnforcementModeEnforce,
+			defaultDeny:     true,
+			sharing:         helperfunctions.Ptr("other-policy"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			objectMeta := metav1.ObjectMeta{Name: "exemption", Namespace: "default"}
+			scope := auditModeScope(tt.enforcementMode)
+			scope.AuthPolicy.Spec.Enabled = tt.enabled
+			scope.DefaultDeny = tt.defaultDeny
+			scope.OverlappingAuthPolicy = tt.overlapping
+			scope.SharingAuthPolicy = tt.sharing
+
+			// 2. Act
+			exemption := defaultdeny.GetDesiredExemption(&scope, objectMeta)
+
+			// 3. Assert
+			assert.Nil(t, exemption)
+		})
+	}
+}
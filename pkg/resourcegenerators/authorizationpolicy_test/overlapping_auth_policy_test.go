@@ -0,0 +1,64 @@
+package authorizationpolicytest_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/requestauthentication"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOverlappingAuthPolicy_IgnoringAllPaths_GeneratesNothing(t *testing.T) {
+	for name, getDesired := range authorizationPolicyGenerators {
+		t.Run(name, func(t *testing.T) {
+			// 1. Arrange
+			objectMeta := metav1.ObjectMeta{Name: "overlapping", Namespace: "default"}
+			scope := auditModeScope(v1alpha1.EnforcementModeEnforce)
+			scope.AuthPolicy.Spec.IgnoreAuthRules = &[]v1alpha1.RequestMatcher{{Paths: []string{"/**"}}}
+			scope.OverlappingAuthPolicy = helperfunctions.Ptr("older-policy")
+
+			// 2. Act
+			authorizationPolicy := getDesired(&scope, objectMeta)
+
+			// 3. Assert
+			assert.Nil(
+				t,
+				authorizationPolicy,
+				"A refused AuthPolicy must not open paths on the pods of the AuthPolicy taking precedence",
+			)
+		})
+	}
+}
+
+func TestOverlappingAuthPolicy_GeneratesNoRequestAuthentication(t *testing.T) {
+	// 1. Arrange
+	objectMeta := metav1.ObjectMeta{Name: "overlapping", Namespace: "default"}
+	scope := auditModeScope(v1alpha1.EnforcementModeEnforce)
+	scope.OverlappingAuthPolicy = helperfunctions.Ptr("older-policy")
+
+	// 2. Act
+	requestAuthentication := requestauthentication.GetDesired(&scope, objectMeta)
+
+	// 3. Assert
+	assert.Nil(t, requestAuthentication, "A refused AuthPolicy must not add trusted issuers to shared pods")
+}
+
+func TestOverlappingAuthPolicy_WithInvalidConfig_GeneratesNothing(t *testing.T) {
+	for name, getDesired := range authorizationPolicyGenerators {
+		t.Run(name, func(t *testing.T) {
+			// 1. Arrange
+			objectMeta := metav1.ObjectMeta{Name: "overlapping", Namespace: "default"}
+			scope := auditModeScope(v1alpha1.EnforcementModeEnforce)
+			scope.OverlappingAuthPolicy = helperfunctions.Ptr("older-policy")
+			scope.InvalidConfig = true
+
+			// 2. Act
+			authorizationPolicy := getDesired(&scope, objectMeta)
+
+			// 3. Assert
+			assert.Nil(t, authorizationPolicy, "A refused AuthPolicy must not deny the pods of the AuthPolicy taking precedence")
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package authorizationpolicytest_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	testifyrequire "github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTargetRefs_ReplaceWorkloadSelector(t *testing.T) {
+	for name, getDesired := range authorizationPolicyGenerators {
+		t.Run(name, func(t *testing.T) {
+			// 1. Arrange
+			objectMeta := metav1.ObjectMeta{Name: "target-refs", Namespace: "default"}
+			scope := auditModeScope(v1alpha1.EnforcementModeEnforce)
+			scope.AuthPolicy.Spec.TargetRefs = []v1alpha1.PolicyTargetReference{
+				{Kind: v1alpha1.TargetRefKindGateway, Name: "my-waypoint"},
+			}
+
+			// 2. Act
+			authorizationPolicy := getDesired(&scope, objectMeta)
+
+			// 3. Assert
+			testifyrequire.NotNil(t, authorizationPolicy)
+			assert.Nil(t, authorizationPolicy.Spec.GetSelector())
+			testifyrequire.Len(t, authorizationPolicy.Spec.GetTargetRefs(), 1)
+			assert.Equal(t, "gateway.networking.k8s.io", authorizationPolicy.Spec.GetTargetRefs()[0].GetGroup())
+			assert.Equal(t, "Gateway", authorizationPolicy.Spec.GetTargetRefs()[0].GetKind())
+			assert.Equal(t, "my-waypoint", authorizationPolicy.Spec.GetTargetRefs()[0].GetName())
+		})
+	}
+}
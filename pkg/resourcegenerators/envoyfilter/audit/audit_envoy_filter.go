@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"google.golang.org/protobuf/types/known/structpb"
+	"istio.io/api/networking/v1alpha3"
+	v1alpha4 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kartverket/ztoperator/internal/names"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/targetref"
+)
+
+// GetDesired returns the desired EnvoyFilter resource logging inbound requests which would have been denied, for the
+// given AuthPolicy scope in audit mode
+//
+// The generated EnvoyFilter inserts a Lua HTTP filter (INSERT_BEFORE router) in the inbound sidecar HTTP chain.
+// As the filter follows the RBAC filters, it reads the results of the dry-run AuthorizationPolicies from the dynamic
+// metadata of the request, and logs the requests they would have denied together with the matched rule.
+// No EnvoyFilter is generated for AuthPolicies applying to Services, as EnvoyFilters cannot target Services.
+func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *v1alpha4.EnvoyFilter {
+	if !scope.IsEnabled() || !scope.AuthPolicy.IsAuditMode() ||
+		!targetref.SupportsInboundEnvoyFilters(&scope.AuthPolicy) {
+		return nil
+	}
+
+	luaConfigPatchValueAsPbStruct, err := structpb.NewStruct(
+		configpatch.GetAuditLuaConfigPatchValue(
+			luascript.GenerateAuditLuaScript(
+				scope.AuthPolicy.Namespace,
+				scope.AuthPolicy.Name,
+				names.DenyPolicy(scope.AuthPolicy.Name),
+			),
+		),
+	)
+	if err != nil {
+		panic(
+			"failed to serialize audit Lua config patch to protobuf struct due to the following error: " +
+				err.Error(),
+		)
+	}
+
+	return &v1alpha4.EnvoyFilter{
+		ObjectMeta: objectMeta,
+		Spec: v1alpha3.EnvoyFilter{
+			ConfigPatches: []*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: v1alpha3.EnvoyFilter_HTTP_FILTER,
+					Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+						Context: targetref.GetInboundPatchContext(&scope.AuthPolicy),
+						ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+							Listener: &v1alpha3.EnvoyFilter_ListenerMatch{
+								FilterChain: &v1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
+									Filter: &v1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
+										Name: "envoy.filters.network.http_connection_manager",
+										SubFilter: &v1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch{
+											Name: "envoy.filters.http.router",
+										},
+									},
+								},
+							},
+						},
+					},
+					Patch: &v1alpha3.EnvoyFilter_Patch{
+						Operation: v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
+						Value:     luaConfigPatchValueAsPbStruct,
+					},
+				},
+			},
+			WorkloadSelector: targetref.GetEnvoyFilterWorkloadSelector(&scope.AuthPolicy),
+			TargetRefs:       targetref.GetEnvoyFilterTargetReferences(&scope.AuthPolicy),
+		},
+	}
+}
@@ -0,0 +1,86 @@
+package audit_test
+
+import (
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"istio.io/api/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDesired_ReturnsNil_WhenEnforced(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.EnforcementMode = ztoperatorv1alpha1.EnforcementModeEnforce
+	assert.Nil(t, audit.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_WhenEnforcementModeUnset(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.EnforcementMode = ""
+	assert.Nil(t, audit.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_WhenDisabled(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.Enabled = false
+	assert.Nil(t, audit.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_WhenTargetingServices(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.Selector = nil
+	scope.AuthPolicy.Spec.TargetRefs = []ztoperatorv1alpha1.PolicyTargetReference{
+		{Kind: ztoperatorv1alpha1.TargetRefKindService, Name: "my-service"},
+	}
+	assert.Nil(t, audit.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_InsertsLuaFilterBeforeRouter(t *testing.T) {
+	scope := defaultScope()
+
+	ef := audit.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	assert.Equal(t, scope.AuthPolicy.Spec.Selector.MatchLabels, ef.Spec.WorkloadSelector.Labels)
+	require.Len(t, ef.Spec.ConfigPatches, 1)
+
+	lua := ef.Spec.ConfigPatches[0]
+	assert.Equal(t, v1alpha3.EnvoyFilter_HTTP_FILTER, lua.ApplyTo)
+	assert.Equal(t, v1alpha3.EnvoyFilter_SIDECAR_INBOUND, lua.Match.Context)
+	assert.Equal(t, v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE, lua.Patch.Operation)
+	assert.Equal(
+		t,
+		"envoy.filters.http.router",
+		lua.Match.GetListener().GetFilterChain().GetFilter().GetSubFilter().GetName(),
+	)
+	typedConfig, ok := lua.Patch.Value.AsMap()["typed_config"].(map[string]interface{})
+	require.True(t, ok)
+	source, ok := typedConfig["default_source_code"].(map[string]interface{})["inline_string"].(string)
+	require.True(t, ok)
+	assert.Contains(t, source, `local namespace = "default"`)
+	assert.Contains(t, source, `local deny_policy = "auth-policy-deny-auth-rules"`)
+}
+
+func defaultScope() state.Scope {
+	return state.Scope{
+		AuthPolicy: ztoperatorv1alpha1.AuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "auth-policy", Namespace: "default"},
+			Spec: ztoperatorv1alpha1.AuthPolicySpec{
+				Enabled:         true,
+				EnforcementMode: ztoperatorv1alpha1.EnforcementModeAudit,
+				WellKnownURI:    "https://login.example.com/v2.0/.well-known/openid-configuration",
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
+					MatchLabels: map[string]string{"app": "application"},
+				},
+			},
+		},
+	}
+}
+
+func defaultObjectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: "auth-policy-audit", Namespace: "default"}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/kartverket/ztoperator/internal/state"
 	"github.com/kartverket/ztoperator/pkg/helperfunctions"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/targetref"
 )
 
 // GetDesired returns the desired EnvoyFilter resource for the given AuthPolicy scope
@@ -29,8 +30,11 @@ import (
 //
 //  3. An OAuth2 HTTP filter (INSERT_BEFORE jwt_authn) that drives the Authorization Code Flow and
 //     exchanges the authorization code for tokens using the upstream OAuth2 cluster defined above.
+//
+// For AuthPolicies applying to gateways, the Lua and OAuth2 filters are inserted on every listener of the
+// targeted Gateways serving HTTP, each matched by its port and hostname, rather than on all listeners.
 func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *v1alpha4.EnvoyFilter {
-	if !scope.AuthPolicy.Spec.Enabled || scope.InvalidConfig || scope.AuthPolicy.Spec.AutoLogin == nil ||
+	if !scope.IsEnabled() || scope.InvalidConfig || scope.AuthPolicy.Spec.AutoLogin == nil ||
 		!scope.AuthPolicy.Spec.AutoLogin.Enabled {
 		return nil
 	}
@@ -82,28 +86,36 @@ func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *v1alpha4.EnvoyFil
 		)
 	}
 
-	// Pre-allocating the slice with a length of 3 since we know there will be exactly 3 patches.
-	configPatches := make([]*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch, 0, 3)
+	luaScriptListenerMatches := getInboundListenerMatches(scope, nil)
+	oAuthListenerMatches := getInboundListenerMatches(
+		scope,
+		&v1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch{Name: "envoy.filters.http.jwt_authn"},
+	)
+	if len(luaScriptListenerMatches) == 0 {
+		// None of the targeted gateways serve HTTP, which is reported when validating the AuthPolicy
+		return nil
+	}
 
-	configPatches = append(configPatches, &v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
-		ApplyTo: v1alpha3.EnvoyFilter_HTTP_FILTER,
-		Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
-			Context: v1alpha3.EnvoyFilter_SIDECAR_INBOUND,
-			ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
-				Listener: &v1alpha3.EnvoyFilter_ListenerMatch{
-					FilterChain: &v1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
-						Filter: &v1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
-							Name: "envoy.filters.network.http_connection_manager",
-						},
-					},
-				},
+	// One Lua script and OAuth2 patch per listener match, along with the OAuth2 cluster.
+	configPatches := make(
+		[]*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch,
+		0,
+		len(luaScriptListenerMatches)+len(oAuthListenerMatches)+1,
+	)
+
+	for _, listenerMatch := range luaScriptListenerMatches {
+		configPatches = append(configPatches, &v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+			ApplyTo: v1alpha3.EnvoyFilter_HTTP_FILTER,
+			Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+				Context:     targetref.GetInboundPatchContext(&scope.AuthPolicy),
+				ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{Listener: listenerMatch},
 			},
-		},
-		Patch: &v1alpha3.EnvoyFilter_Patch{
-			Operation: v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
-			Value:     luaScriptConfigPatchValue,
-		},
-	})
+			Patch: &v1alpha3.EnvoyFilter_Patch{
+				Operation: v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
+				Value:     luaScriptConfigPatchValue,
+			},
+		})
+	}
 
 	configPatches = append(configPatches, &v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
 		ApplyTo: v1alpha3.EnvoyFilter_CLUSTER,
@@ -120,36 +132,75 @@ func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *v1alpha4.EnvoyFil
 		},
 	})
 
-	configPatches = append(configPatches, &v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
-		ApplyTo: v1alpha3.EnvoyFilter_HTTP_FILTER,
-		Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
-			Context: v1alpha3.EnvoyFilter_SIDECAR_INBOUND,
-			ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
-				Listener: &v1alpha3.EnvoyFilter_ListenerMatch{
-					FilterChain: &v1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
-						Filter: &v1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
-							Name: "envoy.filters.network.http_connection_manager",
-							SubFilter: &v1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch{
-								Name: "envoy.filters.http.jwt_authn",
-							},
-						},
-					},
-				},
+	for _, listenerMatch := range oAuthListenerMatches {
+		configPatches = append(configPatches, &v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+			ApplyTo: v1alpha3.EnvoyFilter_HTTP_FILTER,
+			Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+				Context:     targetref.GetInboundPatchContext(&scope.AuthPolicy),
+				ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{Listener: listenerMatch},
 			},
-		},
-		Patch: &v1alpha3.EnvoyFilter_Patch{
-			Operation: v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
-			Value:     oAuthSidecarConfigPatchValueAsPbStruct,
-		},
-	})
+			Patch: &v1alpha3.EnvoyFilter_Patch{
+				Operation: v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
+				Value:     oAuthSidecarConfigPatchValueAsPbStruct,
+			},
+		})
+	}
 
 	return &v1alpha4.EnvoyFilter{
 		ObjectMeta: objectMeta,
 		Spec: v1alpha3.EnvoyFilter{
-			ConfigPatches: configPatches,
-			WorkloadSelector: &v1alpha3.WorkloadSelector{
-				Labels: scope.AuthPolicy.Spec.Selector.MatchLabels,
-			},
+			ConfigPatches:    configPatches,
+			WorkloadSelector: targetref.GetEnvoyFilterWorkloadSelector(&scope.AuthPolicy),
+			TargetRefs:       targetref.GetEnvoyFilterTargetReferences(&scope.AuthPolicy),
 		},
 	}
 }
+
+/*
+getInboundListenerMatches returns the listener matches of the patches inserting filters into the HTTP filter chain of
+inbound requests, optionally before the given sub filter. For AuthPolicies applying to gateways, a match is returned
+per listener of the targeted Gateways serving HTTP, matching its port, and its hostname through SNI for HTTPS
+listeners, so that other listeners of the gateway pods are not patched. Note that a plain HTTP listener serves all
+hostnames on its port. Listeners shared by the targeted Gateways are only matched once.
+*/
+func getInboundListenerMatches(
+	scope *state.Scope,
+	subFilter *v1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch,
+) []*v1alpha3.EnvoyFilter_ListenerMatch {
+	listenerMatch := func(portNumber uint32, sni string) *v1alpha3.EnvoyFilter_ListenerMatch {
+		return &v1alpha3.EnvoyFilter_ListenerMatch{
+			PortNumber: portNumber,
+			FilterChain: &v1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
+				Sni: sni,
+				Filter: &v1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
+					Name:      "envoy.filters.network.http_connection_manager",
+					SubFilter: subFilter,
+				},
+			},
+		}
+	}
+	if !scope.AuthPolicy.TargetsGateways() {
+		return []*v1alpha3.EnvoyFilter_ListenerMatch{listenerMatch(0, "")}
+	}
+
+	type listenerKey struct {
+		portNumber uint32
+		sni        string
+	}
+	var listenerMatches []*v1alpha3.EnvoyFilter_ListenerMatch
+	matched := map[listenerKey]bool{}
+	for _, gateway := range scope.Gateways {
+		for _, listener := range gateway.Listeners {
+			key := listenerKey{portNumber: listener.Port}
+			if listener.Hostname != nil {
+				key.sni = *listener.Hostname
+			}
+			if matched[key] {
+				continue
+			}
+			matched[key] = true
+			listenerMatches = append(listenerMatches, listenerMatch(key.portNumber, key.sni))
+		}
+	}
+	return listenerMatches
+}
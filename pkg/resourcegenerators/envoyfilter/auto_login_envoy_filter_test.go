@@ -38,6 +38,12 @@ func TestGetDesired_ReturnsNil_WhenInvalidConfig(t *testing.T) {
 	assert.Nil(t, envoyfilter.GetDesired(&scope, defaultObjectMeta()))
 }
 
+func TestGetDesired_ReturnsNil_WhenOverlappingAuthPolicy(t *testing.T) {
+	scope := defaultScope()
+	scope.OverlappingAuthPolicy = helperfunctions.Ptr("older-policy")
+	assert.Nil(t, envoyfilter.GetDesired(&scope, defaultObjectMeta()))
+}
+
 func TestGetDesired_ObjectMetaIsPreserved(t *testing.T) {
 	scope := defaultScope()
 	name := "auth-policy-login"
@@ -112,6 +118,104 @@ func TestGetDesired_OAuthSidecarPatch_ApplyToAndOperation(t *testing.T) {
 	assert.Equal(t, "envoy.filters.http.jwt_authn", subFilterName)
 }
 
+func TestGetDesired_GatewayTargetRefs_PatchesGatewayContext(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.Selector = nil
+	scope.AuthPolicy.Spec.TargetRefs = []ztoperatorv1alpha1.PolicyTargetReference{
+		{Kind: ztoperatorv1alpha1.TargetRefKindGateway, Name: "my-gateway"},
+	}
+	scope.Gateways = []state.Gateway{{Name: "my-gateway", Listeners: []state.GatewayListener{{Port: 80}}}}
+
+	ef := envoyfilter.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	assert.Nil(t, ef.Spec.WorkloadSelector)
+	require.Len(t, ef.Spec.TargetRefs, 1)
+	assert.Equal(t, "gateway.networking.k8s.io", ef.Spec.TargetRefs[0].Group)
+	assert.Equal(t, "Gateway", ef.Spec.TargetRefs[0].Kind)
+	assert.Equal(t, "my-gateway", ef.Spec.TargetRefs[0].Name)
+	for _, i := range []int{0, 2} {
+		p := ef.Spec.ConfigPatches[i]
+		assert.Equal(t, v1alpha3.EnvoyFilter_GATEWAY, p.Match.Context)
+		filterName := p.Match.GetListener().GetFilterChain().GetFilter().GetName()
+		assert.Equal(t, "envoy.filters.network.http_connection_manager", filterName)
+	}
+}
+
+func TestGetDesired_GatewayTargetRefs_MatchesListenersOfGateways(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.Selector = nil
+	scope.AuthPolicy.Spec.TargetRefs = []ztoperatorv1alpha1.PolicyTargetReference{
+		{Kind: ztoperatorv1alpha1.TargetRefKindGateway, Name: "my-gateway"},
+		{Kind: ztoperatorv1alpha1.TargetRefKindGateway, Name: "other-gateway"},
+	}
+	scope.Gateways = []state.Gateway{
+		{
+			Name: "my-gateway",
+			Listeners: []state.GatewayListener{
+				{Port: 80},
+				{Port: 443, Hostname: helperfunctions.Ptr("app.example.com")},
+			},
+		},
+		{
+			Name: "other-gateway",
+			Listeners: []state.GatewayListener{
+				{Port: 443, Hostname: helperfunctions.Ptr("app.example.com")},
+				{Port: 8443, Hostname: helperfunctions.Ptr("*.example.com")},
+			},
+		},
+	}
+
+	ef := envoyfilter.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	require.Len(t, ef.Spec.ConfigPatches, 7, "Lua and OAuth2 patches for each of the 3 distinct listeners, and the cluster")
+	assert.Equal(t, v1alpha3.EnvoyFilter_CLUSTER, ef.Spec.ConfigPatches[3].ApplyTo)
+	expectedListeners := []struct {
+		portNumber uint32
+		sni        string
+	}{
+		{portNumber: 80},
+		{portNumber: 443, sni: "app.example.com"},
+		{portNumber: 8443, sni: "*.example.com"},
+	}
+	for i, expected := range expectedListeners {
+		for _, p := range []*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{ef.Spec.ConfigPatches[i], ef.Spec.ConfigPatches[i+4]} {
+			assert.Equal(t, v1alpha3.EnvoyFilter_GATEWAY, p.Match.Context)
+			assert.Equal(t, expected.portNumber, p.Match.GetListener().GetPortNumber())
+			assert.Equal(t, expected.sni, p.Match.GetListener().GetFilterChain().GetSni())
+		}
+		assert.Nil(t, ef.Spec.ConfigPatches[i].Match.GetListener().GetFilterChain().GetFilter().GetSubFilter())
+		assert.Equal(
+			t,
+			"envoy.filters.http.jwt_authn",
+			ef.Spec.ConfigPatches[i+4].Match.GetListener().GetFilterChain().GetFilter().GetSubFilter().GetName(),
+		)
+	}
+}
+
+func TestGetDesired_ReturnsNil_WhenGatewaysServeNoHTTP(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.Selector = nil
+	scope.AuthPolicy.Spec.TargetRefs = []ztoperatorv1alpha1.PolicyTargetReference{
+		{Kind: ztoperatorv1alpha1.TargetRefKindGateway, Name: "passthrough"},
+	}
+	scope.Gateways = []state.Gateway{{Name: "passthrough"}}
+
+	assert.Nil(t, envoyfilter.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_Selector_MatchesAllInboundListeners(t *testing.T) {
+	ef := envoyfilter.GetDesired(helperfunctions.Ptr(defaultScope()), defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	for _, i := range []int{0, 2} {
+		listener := ef.Spec.ConfigPatches[i].Match.GetListener()
+		assert.Zero(t, listener.GetPortNumber())
+		assert.Empty(t, listener.GetFilterChain().GetSni())
+	}
+}
+
 func TestGetDesired_InternalIdP_ClusterPatchHasNoTLS(t *testing.T) {
 	scope := defaultScope() // token URI has port 8080
 
@@ -144,7 +248,7 @@ func defaultScope() state.Scope {
 			ObjectMeta: metav1.ObjectMeta{Name: "auth-policy", Namespace: "default"},
 			Spec: ztoperatorv1alpha1.AuthPolicySpec{
 				Enabled: true,
-				Selector: ztoperatorv1alpha1.WorkloadSelector{
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
 					MatchLabels: map[string]string{"app": "application"},
 				},
 				AutoLogin: &ztoperatorv1alpha1.AutoLogin{Enabled: true},
@@ -1,11 +1,11 @@
 package configpatch_test
 
 import (
+	"regexp"
 	"testing"
 
 	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/state"
-	"github.com/kartverket/ztoperator/pkg/luascript"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -118,12 +118,60 @@ func TestGetOAuthSidecarConfigPatch_PassThroughAndDenyRedirectMatchers(t *testin
 	authHeader := ptm[0].(map[string]interface{})
 	assert.Equal(t, "authorization", authHeader["name"])
 	bypassHeader := ptm[1].(map[string]interface{})
-	assert.Equal(t, luascript.BypassOauthLoginHeaderName, bypassHeader["name"])
+	assert.Equal(t, "x-ztoperator-bypass-login-6f1c2b1e3d4a4c5b", bypassHeader["name"])
 
 	drm := inner["deny_redirect_matcher"].([]interface{})
 	require.Len(t, drm, 1)
 	denyHeader := drm[0].(map[string]interface{})
-	assert.Equal(t, luascript.DenyRedirectHeaderName, denyHeader["name"])
+	assert.Equal(t, "x-ztoperator-deny-redirect-6f1c2b1e3d4a4c5b", denyHeader["name"])
+}
+
+func TestGetOAuthSidecarConfigPatch_PassThroughMatchers_IncludeCustomTokenLocations(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.FromHeaders = []ztoperatorv1alpha1.JWTHeader{
+		{Name: "X-Api-Token"},
+		{Name: "X-Custom-Auth", Prefix: "Token "},
+	}
+	scope.AuthPolicy.Spec.FromParams = []string{"token.v2"}
+	scope.AuthPolicy.Spec.FromCookies = []string{"session"}
+
+	result := configpatch.GetOAuthSidecarConfigPatchValue(scope)
+
+	inner := oauthInnerConfig(t, result)
+	ptm := inner["pass_through_matcher"].([]interface{})
+	require.Len(t, ptm, 7)
+
+	apiTokenHeader := ptm[2].(map[string]interface{})
+	assert.Equal(t, "x-api-token", apiTokenHeader["name"])
+	assert.Equal(t, true, apiTokenHeader["present_match"])
+	customAuthHeader := ptm[3].(map[string]interface{})
+	assert.Equal(t, "x-custom-auth", customAuthHeader["name"])
+	assert.Equal(t, map[string]interface{}{"prefix": "Token "}, customAuthHeader["string_match"])
+
+	regexOf := func(matcher interface{}) *regexp.Regexp {
+		stringMatch := matcher.(map[string]interface{})["string_match"].(map[string]interface{})
+		safeRegex := stringMatch["safe_regex"].(map[string]interface{})
+		// Envoy requires the regex to match the full header value
+		return regexp.MustCompile("^(?:" + safeRegex["regex"].(string) + ")$")
+	}
+
+	assert.Equal(t, ":path", ptm[4].(map[string]interface{})["name"])
+	accessTokenParam := regexOf(ptm[4])
+	assert.True(t, accessTokenParam.MatchString("/ws?access_token=abc"))
+	assert.False(t, accessTokenParam.MatchString("/ws?access_token="))
+
+	customParam := regexOf(ptm[5])
+	assert.True(t, customParam.MatchString("/ws?foo=bar&token.v2=abc&baz=qux"))
+	assert.False(t, customParam.MatchString("/ws?tokenXv2=abc"))
+	assert.False(t, customParam.MatchString("/ws?mytoken.v2=abc"))
+	assert.False(t, customParam.MatchString("/token.v2=abc"))
+
+	assert.Equal(t, "cookie", ptm[6].(map[string]interface{})["name"])
+	sessionCookie := regexOf(ptm[6])
+	assert.True(t, sessionCookie.MatchString("session=abc"))
+	assert.True(t, sessionCookie.MatchString("theme=dark; session=abc; lang=nb"))
+	assert.False(t, sessionCookie.MatchString("mysession=abc"))
+	assert.False(t, sessionCookie.MatchString("session="))
 }
 
 func TestGetOAuthSidecarConfigPatch_CookieConfigs_SameSiteLax(t *testing.T) {
@@ -168,7 +216,7 @@ func defaultScope() state.Scope {
 			ObjectMeta: metav1.ObjectMeta{Name: "auth-policy", Namespace: "default"},
 			Spec: ztoperatorv1alpha1.AuthPolicySpec{
 				Enabled: true,
-				Selector: ztoperatorv1alpha1.WorkloadSelector{
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
 					MatchLabels: map[string]string{"app": "myapp"},
 				},
 			},
@@ -182,10 +230,11 @@ func defaultScope() state.Scope {
 			EndSessionURI:    &endSession,
 		},
 		AutoLoginConfig: state.AutoLoginConfig{
-			Enabled:      true,
-			RedirectPath: "/oauth2/callback",
-			LogoutPath:   "/logout",
-			Scopes:       []string{"openid"},
+			Enabled:            true,
+			RedirectPath:       "/oauth2/callback",
+			LogoutPath:         "/logout",
+			Scopes:             []string{"openid"},
+			SignalHeaderSuffix: "6f1c2b1e3d4a4c5b",
 		},
 	}
 }
@@ -0,0 +1,14 @@
+package configpatch
+
+// GetDenyResponseLuaConfigPatchValue returns the Lua HTTP filter rewriting the responses of denied inbound requests.
+func GetDenyResponseLuaConfigPatchValue(luaScript string) map[string]interface{} {
+	return map[string]interface{}{
+		"name": "envoy.filters.http.lua",
+		"typed_config": map[string]interface{}{
+			"@type": "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+			"default_source_code": map[string]interface{}{
+				"inline_string": luaScript,
+			},
+		},
+	}
+}
@@ -0,0 +1,81 @@
+package configpatch
+
+// EgressTokenClusterName returns the name of the cluster used to obtain access tokens for egress from the
+// identity provider with the given name.
+func EgressTokenClusterName(identityProviderName string) string {
+	return "egress-token-" + identityProviderName
+}
+
+/*
+GetEgressCredentialInjectorConfigPatchValue returns the patch merged into an outbound cluster to inject an access token
+into every request sent through it.
+
+The credential injector is configured as an upstream HTTP filter of the cluster, and obtains the access token with the
+OAuth 2.0 client credentials grant from the token endpoint of the identity provider. The token is cached by Envoy and
+renewed before it expires. The client secret is read from the same SDS file as the one used by auto-login.
+*/
+func GetEgressCredentialInjectorConfigPatchValue(
+	tokenClusterName string,
+	tokenURI string,
+	clientID string,
+	scopes []string,
+) map[string]interface{} {
+	scopesInterface := make([]interface{}, len(scopes))
+	for i, scope := range scopes {
+		scopesInterface[i] = scope
+	}
+
+	credentialInjector := map[string]interface{}{
+		"name": "envoy.filters.http.credential_injector",
+		"typed_config": map[string]interface{}{
+			"@type":     "type.googleapis.com/envoy.extensions.filters.http.credential_injector.v3.CredentialInjector",
+			"overwrite": true,
+			"credential": map[string]interface{}{
+				"name": "envoy.http.injected_credentials.oauth2",
+				"typed_config": map[string]interface{}{
+					"@type": "type.googleapis.com/envoy.extensions.http.injected_credentials.oauth2.v3.OAuth2",
+					"token_endpoint": map[string]interface{}{
+						"cluster": tokenClusterName,
+						"uri":     tokenURI,
+						"timeout": "5s",
+					},
+					"scopes": scopesInterface,
+					"client_credentials": map[string]interface{}{
+						"client_id": clientID,
+						"client_secret": map[string]interface{}{
+							"name": "token",
+							"sds_config": map[string]interface{}{
+								"path_config_source": map[string]interface{}{
+									"path": IstioTokenSecretSource,
+									"watched_directory": map[string]interface{}{
+										"path": IstioCredentialsDirectory,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"typed_extension_protocol_options": map[string]interface{}{
+			"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": map[string]interface{}{
+				"@type": "type.googleapis.com/envoy.extensions.upstreams.http.v3.HttpProtocolOptions",
+				"explicit_http_config": map[string]interface{}{
+					"http_protocol_options": map[string]interface{}{},
+				},
+				"http_filters": []interface{}{
+					credentialInjector,
+					map[string]interface{}{
+						"name": "envoy.filters.http.upstream_codec",
+						"typed_config": map[string]interface{}{
+							"@type": "type.googleapis.com/envoy.extensions.filters.http.upstream_codec.v3.UpstreamCodec",
+						},
+					},
+				},
+			},
+		},
+	}
+}
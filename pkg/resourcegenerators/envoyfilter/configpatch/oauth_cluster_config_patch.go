@@ -1,37 +1,60 @@
 package configpatch
 
+import (
+	"strconv"
+
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+)
+
+const OAuthClusterName = "oauth"
+
 func GetInternalOAuthClusterConfigPatchValue(idpHostname string, port int) map[string]interface{} {
+	return GetInternalTokenEndpointClusterConfigPatchValue(OAuthClusterName, idpHostname, port)
+}
+
+func GetExternalOAuthClusterPatchValue(idpHostname string) map[string]interface{} {
+	return GetExternalTokenEndpointClusterConfigPatchValue(OAuthClusterName, idpHostname)
+}
+
+// GetTokenEndpointClusterConfigPatchValue returns a cluster with the given name for the host of the token endpoint.
+// Internal IdPs (with an explicit port) are reached in plain HTTP; external IdPs are reached in HTTPS.
+func GetTokenEndpointClusterConfigPatchValue(clusterName string, tokenURI string) (map[string]interface{}, error) {
+	tokenURL, err := helperfunctions.GetParsedURL(tokenURI)
+	if err != nil {
+		return nil, err
+	}
+	if tokenURL.Port() == "" {
+		return GetExternalTokenEndpointClusterConfigPatchValue(clusterName, tokenURL.Host), nil
+	}
+	port, err := strconv.Atoi(tokenURL.Port())
+	if err != nil {
+		return nil, err
+	}
+	return GetInternalTokenEndpointClusterConfigPatchValue(clusterName, tokenURL.Hostname(), port), nil
+}
+
+// GetInternalTokenEndpointClusterConfigPatchValue returns a cluster with the given name for an identity provider
+// reachable in plain HTTP on the given port, typically within the cluster.
+func GetInternalTokenEndpointClusterConfigPatchValue(
+	clusterName string,
+	idpHostname string,
+	port int,
+) map[string]interface{} {
 	return map[string]interface{}{
-		"name":              "oauth",
+		"name":              clusterName,
 		"dns_lookup_family": "V4_ONLY",
 		"type":              "LOGICAL_DNS",
 		"connect_timeout":   "10s",
 		"lb_policy":         "ROUND_ROBIN",
-		"load_assignment": map[string]interface{}{
-			"cluster_name": "oauth",
-			"endpoints": []interface{}{
-				map[string]interface{}{
-					"lb_endpoints": []interface{}{
-						map[string]interface{}{
-							"endpoint": map[string]interface{}{
-								"address": map[string]interface{}{
-									"socket_address": map[string]interface{}{
-										"address":    idpHostname,
-										"port_value": port,
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+		"load_assignment":   getLoadAssignment(clusterName, idpHostname, port),
 	}
 }
 
-func GetExternalOAuthClusterPatchValue(idpHostname string) map[string]interface{} {
+// GetExternalTokenEndpointClusterConfigPatchValue returns a cluster with the given name for an identity provider
+// reachable in HTTPS on port 443.
+func GetExternalTokenEndpointClusterConfigPatchValue(clusterName string, idpHostname string) map[string]interface{} {
 	return map[string]interface{}{
-		"name":              "oauth",
+		"name":              clusterName,
 		"dns_lookup_family": "V4_ONLY",
 		"type":              "LOGICAL_DNS",
 		"connect_timeout":   "10s",
@@ -43,18 +66,22 @@ func GetExternalOAuthClusterPatchValue(idpHostname string) map[string]interface{
 				"sni":   idpHostname,
 			},
 		},
-		"load_assignment": map[string]interface{}{
-			"cluster_name": "oauth",
-			"endpoints": []interface{}{
-				map[string]interface{}{
-					"lb_endpoints": []interface{}{
-						map[string]interface{}{
-							"endpoint": map[string]interface{}{
-								"address": map[string]interface{}{
-									"socket_address": map[string]interface{}{
-										"address":    idpHostname,
-										"port_value": 443,
-									},
+		"load_assignment": getLoadAssignment(clusterName, idpHostname, 443),
+	}
+}
+
+func getLoadAssignment(clusterName string, hostname string, port int) map[string]interface{} {
+	return map[string]interface{}{
+		"cluster_name": clusterName,
+		"endpoints": []interface{}{
+			map[string]interface{}{
+				"lb_endpoints": []interface{}{
+					map[string]interface{}{
+						"endpoint": map[string]interface{}{
+							"address": map[string]interface{}{
+								"socket_address": map[string]interface{}{
+									"address":    hostname,
+									"port_value": port,
 								},
 							},
 						},
@@ -1,7 +1,9 @@
 package configpatch
 
 import (
+	"regexp"
 	"slices"
+	"strings"
 
 	"github.com/kartverket/ztoperator/internal/state"
 	"github.com/kartverket/ztoperator/pkg/luascript"
@@ -10,8 +12,10 @@ import (
 const (
 	TokenSecretFileName       = "token-secret.yaml"
 	HmacSecretFileName        = "hmac-secret.yaml"
+	ClientSecretFileName      = "client-secret"
 	IstioTokenSecretSource    = "/etc/istio/config/" + TokenSecretFileName
 	IstioHmacSecretSource     = "/etc/istio/config/" + HmacSecretFileName
+	IstioClientSecretSource   = "/etc/istio/config/" + ClientSecretFileName
 	IstioCredentialsDirectory = "/etc/istio/config"
 )
 
@@ -55,23 +59,10 @@ func GetOAuthSidecarConfigPatchValue(
 		},
 		"forward_bearer_token": true,
 		"use_refresh_token":    true,
-		"pass_through_matcher": []interface{}{
-			map[string]interface{}{
-				"name": "authorization",
-				"string_match": map[string]interface{}{
-					"prefix": "Bearer ",
-				},
-			},
-			map[string]interface{}{
-				"name": luascript.BypassOauthLoginHeaderName,
-				"string_match": map[string]interface{}{
-					"exact": "true",
-				},
-			},
-		},
+		"pass_through_matcher": getPassThroughMatchers(scope),
 		"deny_redirect_matcher": []interface{}{
 			map[string]interface{}{
-				"name": luascript.DenyRedirectHeaderName,
+				"name": luascript.DenyRedirectHeaderName(scope.AutoLoginConfig.SignalHeaderSuffix),
 				"string_match": map[string]interface{}{
 					"exact": "true",
 				},
@@ -130,3 +121,65 @@ func GetOAuthSidecarConfigPatchValue(
 		},
 	}
 }
+
+/*
+Requests carrying a token in the Authorization header, or in any of the custom token locations, are passed through
+without a login redirect, and are authenticated by the RequestAuthentication instead.
+Query parameters and cookies are matched with regular expressions on the :path and cookie headers respectively.
+*/
+func getPassThroughMatchers(scope state.Scope) []interface{} {
+	passThroughMatchers := []interface{}{
+		map[string]interface{}{
+			"name": "authorization",
+			"string_match": map[string]interface{}{
+				"prefix": "Bearer ",
+			},
+		},
+		map[string]interface{}{
+			"name": luascript.BypassOauthLoginHeaderName(scope.AutoLoginConfig.SignalHeaderSuffix),
+			"string_match": map[string]interface{}{
+				"exact": "true",
+			},
+		},
+	}
+	if !scope.AuthPolicy.HasCustomTokenLocations() {
+		return passThroughMatchers
+	}
+
+	// The first token header is the default Authorization header, which is already matched
+	for _, header := range scope.AuthPolicy.GetTokenHeaders()[1:] {
+		headerMatcher := map[string]interface{}{
+			"name": strings.ToLower(header.Name),
+		}
+		if header.Prefix != "" {
+			headerMatcher["string_match"] = map[string]interface{}{"prefix": header.Prefix}
+		} else {
+			headerMatcher["present_match"] = true
+		}
+		passThroughMatchers = append(passThroughMatchers, headerMatcher)
+	}
+	for _, param := range scope.AuthPolicy.GetTokenParams() {
+		passThroughMatchers = append(passThroughMatchers, regexHeaderMatcher(
+			":path",
+			`[^?]*\?(.*&)?`+regexp.QuoteMeta(param)+`=[^&]+.*`,
+		))
+	}
+	for _, cookie := range scope.AuthPolicy.Spec.FromCookies {
+		passThroughMatchers = append(passThroughMatchers, regexHeaderMatcher(
+			"cookie",
+			`(.*;\s*)?`+regexp.QuoteMeta(cookie)+`=[^;]+.*`,
+		))
+	}
+	return passThroughMatchers
+}
+
+func regexHeaderMatcher(name string, regex string) map[string]interface{} {
+	return map[string]interface{}{
+		"name": name,
+		"string_match": map[string]interface{}{
+			"safe_regex": map[string]interface{}{
+				"regex": regex,
+			},
+		},
+	}
+}
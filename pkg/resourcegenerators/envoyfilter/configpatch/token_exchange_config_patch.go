@@ -0,0 +1,18 @@
+package configpatch
+
+// TokenExchangeClusterName is the name of the cluster used to exchange tokens at the token endpoint of the
+// identity provider.
+const TokenExchangeClusterName = "token-exchange"
+
+// GetTokenExchangeLuaConfigPatchValue returns the Lua HTTP filter exchanging tokens of outbound requests.
+func GetTokenExchangeLuaConfigPatchValue(luaScript string) map[string]interface{} {
+	return map[string]interface{}{
+		"name": "envoy.filters.http.lua",
+		"typed_config": map[string]interface{}{
+			"@type": "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+			"default_source_code": map[string]interface{}{
+				"inline_string": luaScript,
+			},
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package denyresponse
+
+import (
+	"google.golang.org/protobuf/types/known/structpb"
+	"istio.io/api/networking/v1alpha3"
+	v1alpha4 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/targetref"
+)
+
+// GetDesired returns the desired EnvoyFilter resource rewriting the responses of denied inbound requests for the
+// given AuthPolicy scope
+//
+// The generated EnvoyFilter inserts a Lua HTTP filter (INSERT_BEFORE jwt_authn) in the inbound sidecar HTTP chain.
+// As the filter precedes the JWT authentication and RBAC filters, it sees their local replies on response, and
+// rewrites them according to the deny response configured for the AuthPolicy or the matching auth rule.
+// No EnvoyFilter is generated in audit mode, as requests are then not denied by the AuthorizationPolicies.
+func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *v1alpha4.EnvoyFilter {
+	if !scope.IsEnabled() || scope.InvalidConfig || scope.AuthPolicy.IsAuditMode() ||
+		!scope.AuthPolicy.HasDenyResponse() {
+		return nil
+	}
+
+	luaConfigPatchValueAsPbStruct, err := structpb.NewStruct(
+		configpatch.GetDenyResponseLuaConfigPatchValue(luascript.GenerateDenyResponseLuaScript(&scope.AuthPolicy)),
+	)
+	if err != nil {
+		panic(
+			"failed to serialize deny response Lua config patch to protobuf struct due to the following error: " +
+				err.Error(),
+		)
+	}
+
+	return &v1alpha4.EnvoyFilter{
+		ObjectMeta: objectMeta,
+		Spec: v1alpha3.EnvoyFilter{
+			ConfigPatches: []*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: v1alpha3.EnvoyFilter_HTTP_FILTER,
+					Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+						Context: targetref.GetInboundPatchContext(&scope.AuthPolicy),
+						ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+							Listener: &v1alpha3.EnvoyFilter_ListenerMatch{
+								FilterChain: &v1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
+									Filter: &v1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
+										Name: "envoy.filters.network.http_connection_manager",
+										SubFilter: &v1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch{
+											Name: "envoy.filters.http.jwt_authn",
+										},
+									},
+								},
+							},
+						},
+					},
+					Patch: &v1alpha3.EnvoyFilter_Patch{
+						Operation: v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
+						Value:     luaConfigPatchValueAsPbStruct,
+					},
+				},
+			},
+			WorkloadSelector: targetref.GetEnvoyFilterWorkloadSelector(&scope.AuthPolicy),
+			TargetRefs:       targetref.GetEnvoyFilterTargetReferences(&scope.AuthPolicy),
+		},
+	}
+}
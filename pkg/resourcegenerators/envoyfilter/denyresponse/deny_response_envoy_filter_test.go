@@ -0,0 +1,109 @@
+package denyresponse_test
+
+import (
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/denyresponse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"istio.io/api/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDesired_ReturnsNil_WhenNoDenyResponse(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.DenyResponse = nil
+	assert.Nil(t, denyresponse.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_WhenDisabled(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.Enabled = false
+	assert.Nil(t, denyresponse.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_WhenInvalidConfig(t *testing.T) {
+	scope := defaultScope()
+	scope.InvalidConfig = true
+	assert.Nil(t, denyresponse.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_InAuditMode(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.EnforcementMode = ztoperatorv1alpha1.EnforcementModeAudit
+	assert.Nil(t, denyresponse.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsEnvoyFilter_WhenOnlyAuthRuleHasDenyResponse(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.DenyResponse = nil
+	scope.AuthPolicy.Spec.AuthRules = &[]ztoperatorv1alpha1.RequestAuthRule{
+		{
+			RequestMatcher: ztoperatorv1alpha1.RequestMatcher{Paths: []string{"/admin/*"}},
+			DenyResponse:   &ztoperatorv1alpha1.DenyResponse{Scopes: []string{"admin"}},
+		},
+	}
+
+	ef := denyresponse.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	source := luaSource(t, ef.Spec.ConfigPatches[0].Patch.Value.AsMap())
+	assert.Contains(t, source, `scope="admin"`)
+}
+
+func TestGetDesired_InsertsLuaFilterBeforeJwtAuthn(t *testing.T) {
+	scope := defaultScope()
+
+	ef := denyresponse.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	assert.Equal(t, scope.AuthPolicy.Spec.Selector.MatchLabels, ef.Spec.WorkloadSelector.Labels)
+	require.Len(t, ef.Spec.ConfigPatches, 1)
+
+	lua := ef.Spec.ConfigPatches[0]
+	assert.Equal(t, v1alpha3.EnvoyFilter_HTTP_FILTER, lua.ApplyTo)
+	assert.Equal(t, v1alpha3.EnvoyFilter_SIDECAR_INBOUND, lua.Match.Context)
+	assert.Equal(t, v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE, lua.Patch.Operation)
+	assert.Equal(
+		t,
+		"envoy.filters.http.jwt_authn",
+		lua.Match.GetListener().GetFilterChain().GetFilter().GetSubFilter().GetName(),
+	)
+	source := luaSource(t, lua.Patch.Value.AsMap())
+	assert.Contains(t, source, `{realm="orders",scope="orders.read",resource_metadata="",error_description=""`)
+}
+
+func luaSource(t *testing.T, patchValue map[string]interface{}) string {
+	t.Helper()
+	typedConfig, ok := patchValue["typed_config"].(map[string]interface{})
+	require.True(t, ok)
+	source, ok := typedConfig["default_source_code"].(map[string]interface{})["inline_string"].(string)
+	require.True(t, ok)
+	return source
+}
+
+func defaultScope() state.Scope {
+	return state.Scope{
+		AuthPolicy: ztoperatorv1alpha1.AuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "auth-policy", Namespace: "default"},
+			Spec: ztoperatorv1alpha1.AuthPolicySpec{
+				Enabled:      true,
+				WellKnownURI: "https://login.example.com/v2.0/.well-known/openid-configuration",
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
+					MatchLabels: map[string]string{"app": "application"},
+				},
+				DenyResponse: &ztoperatorv1alpha1.DenyResponse{
+					Realm:  helperfunctions.Ptr("orders"),
+					Scopes: []string{"orders.read"},
+				},
+			},
+		},
+	}
+}
+
+func defaultObjectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: "auth-policy-deny-response", Namespace: "default"}
+}
@@ -0,0 +1,137 @@
+package egress
+
+import (
+	"slices"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"istio.io/api/networking/v1alpha3"
+	v1alpha4 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/targetref"
+)
+
+// GetDesired returns the desired EnvoyFilter resource injecting access tokens into outbound requests for the given
+// AuthPolicy scope
+//
+// The generated EnvoyFilter contains the following config patches:
+//
+//  1. A cluster (ADD) for the token endpoint of each identity provider referenced by an egress destination.
+//     Internal IdPs (with an explicit port) are reached in plain HTTP; external IdPs are reached in HTTPS.
+//
+//  2. For each host of each egress destination, a patch (MERGE) of the outbound cluster of the host, adding a
+//     credential injector as upstream HTTP filter. The credential injector obtains an access token with the client
+//     credentials grant using the token endpoint cluster defined above, and sets it as the Authorization header.
+func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *v1alpha4.EnvoyFilter {
+	if !scope.IsEnabled() || scope.InvalidConfig || !scope.AuthPolicy.IsEgressEnabled() {
+		return nil
+	}
+
+	var tokenClusterNames []string
+	var tokenClusterConfigPatches []*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch
+	var credentialInjectorConfigPatches []*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch
+
+	for _, destination := range scope.AuthPolicy.Spec.Egress.Destinations {
+		identityProvider := scope.GetIdentityProviderForEgressDestination(destination)
+		if identityProvider == nil {
+			panic("egress destination " + destination.Name + " references an identity provider which is not trusted")
+		}
+		tokenClusterName := configpatch.EgressTokenClusterName(identityProvider.Name)
+
+		if !slices.Contains(tokenClusterNames, tokenClusterName) {
+			tokenClusterNames = append(tokenClusterNames, tokenClusterName)
+			tokenClusterConfigPatches = append(
+				tokenClusterConfigPatches,
+				getTokenClusterConfigPatch(tokenClusterName, identityProvider.IdentityProviderUris.TokenURI),
+			)
+		}
+
+		credentialInjectorConfigPatchValue, err := structpb.NewStruct(
+			configpatch.GetEgressCredentialInjectorConfigPatchValue(
+				tokenClusterName,
+				identityProvider.IdentityProviderUris.TokenURI,
+				*scope.OAuthCredentials.ClientID,
+				destination.Scopes,
+			),
+		)
+		if err != nil {
+			panic(
+				"failed to serialize egress credential injector config patch to protobuf struct due to the following error: " +
+					err.Error(),
+			)
+		}
+
+		var portNumber uint32
+		if destination.Port != nil {
+			portNumber = uint32(*destination.Port)
+		}
+		for _, host := range destination.Hosts {
+			credentialInjectorConfigPatches = append(
+				credentialInjectorConfigPatches,
+				&v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+					ApplyTo: v1alpha3.EnvoyFilter_CLUSTER,
+					Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+						Context: v1alpha3.EnvoyFilter_SIDECAR_OUTBOUND,
+						ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Cluster{
+							Cluster: &v1alpha3.EnvoyFilter_ClusterMatch{
+								Service:    host,
+								PortNumber: portNumber,
+							},
+						},
+					},
+					Patch: &v1alpha3.EnvoyFilter_Patch{
+						Operation: v1alpha3.EnvoyFilter_Patch_MERGE,
+						Value:     credentialInjectorConfigPatchValue,
+					},
+				},
+			)
+		}
+	}
+
+	return &v1alpha4.EnvoyFilter{
+		ObjectMeta: objectMeta,
+		Spec: v1alpha3.EnvoyFilter{
+			ConfigPatches:    slices.Concat(tokenClusterConfigPatches, credentialInjectorConfigPatches),
+			WorkloadSelector: targetref.GetEnvoyFilterWorkloadSelector(&scope.AuthPolicy),
+			TargetRefs:       targetref.GetEnvoyFilterTargetReferences(&scope.AuthPolicy),
+		},
+	}
+}
+
+func getTokenClusterConfigPatch(
+	tokenClusterName string,
+	tokenURI string,
+) *v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch {
+	tokenClusterConfigPatchValue, err := configpatch.GetTokenEndpointClusterConfigPatchValue(tokenClusterName, tokenURI)
+	if err != nil {
+		panic(
+			"failed to get token endpoint cluster from token URI " + tokenURI +
+				" due to the following error: " + err.Error(),
+		)
+	}
+
+	tokenClusterConfigPatchValueAsPbStruct, err := structpb.NewStruct(tokenClusterConfigPatchValue)
+	if err != nil {
+		panic(
+			"failed to serialize token endpoint cluster config patch to protobuf struct due to the following error: " +
+				err.Error(),
+		)
+	}
+
+	return &v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+		ApplyTo: v1alpha3.EnvoyFilter_CLUSTER,
+		Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+			ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Cluster{
+				Cluster: &v1alpha3.EnvoyFilter_ClusterMatch{
+					Service: tokenClusterName,
+				},
+			},
+		},
+		Patch: &v1alpha3.EnvoyFilter_Patch{
+			Operation: v1alpha3.EnvoyFilter_Patch_ADD,
+			Value:     tokenClusterConfigPatchValueAsPbStruct,
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package egress_test
+
+import (
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/egress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"istio.io/api/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDesired_ReturnsNil_WhenEgressNil(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.Egress = nil
+	assert.Nil(t, egress.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_WhenEgressDisabled(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.Egress.Enabled = false
+	assert.Nil(t, egress.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_WhenInvalidConfig(t *testing.T) {
+	scope := defaultScope()
+	scope.InvalidConfig = true
+	assert.Nil(t, egress.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_WorkloadSelectorMatchesAuthPolicySelector(t *testing.T) {
+	scope := defaultScope()
+
+	ef := egress.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	assert.Equal(t, defaultObjectMeta().Name, ef.Name)
+	assert.Equal(t, scope.AuthPolicy.Spec.Selector.MatchLabels, ef.Spec.WorkloadSelector.Labels)
+}
+
+func TestGetDesired_AddsOneTokenClusterPerIdentityProvider(t *testing.T) {
+	scope := defaultScope()
+
+	ef := egress.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	require.Len(t, ef.Spec.ConfigPatches, 5)
+	for i, expectedCluster := range []string{"egress-token-default", "egress-token-maskinporten"} {
+		p := ef.Spec.ConfigPatches[i]
+		assert.Equal(t, v1alpha3.EnvoyFilter_CLUSTER, p.ApplyTo)
+		assert.Equal(t, v1alpha3.EnvoyFilter_Patch_ADD, p.Patch.Operation)
+		assert.Equal(t, expectedCluster, p.Match.GetCluster().GetService())
+		assert.Equal(t, expectedCluster, p.Patch.Value.AsMap()["name"])
+	}
+
+	// The default identity provider is internal, while maskinporten is external and reached in TLS
+	assert.Nil(t, ef.Spec.ConfigPatches[0].Patch.Value.AsMap()["transport_socket"])
+	assert.NotNil(t, ef.Spec.ConfigPatches[1].Patch.Value.AsMap()["transport_socket"])
+}
+
+func TestGetDesired_MergesCredentialInjectorIntoOutboundClusterOfEveryHost(t *testing.T) {
+	scope := defaultScope()
+
+	ef := egress.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	require.Len(t, ef.Spec.ConfigPatches, 5)
+	expected := []struct {
+		host         string
+		port         uint32
+		tokenCluster string
+		tokenURI     string
+		scopes       []interface{}
+	}{
+		{"graph.microsoft.com", 80, "egress-token-default", "http://mock-oauth2.auth:8080/entraid/token",
+			[]interface{}{"https://graph.microsoft.com/.default"}},
+		{"graph.microsoft.us", 80, "egress-token-default", "http://mock-oauth2.auth:8080/entraid/token",
+			[]interface{}{"https://graph.microsoft.com/.default"}},
+		{"api.skatteetaten.no", 0, "egress-token-maskinporten", "https://maskinporten.no/token",
+			[]interface{}{"skatteetaten:inntekt"}},
+	}
+	for i, e := range expected {
+		p := ef.Spec.ConfigPatches[2+i]
+		assert.Equal(t, v1alpha3.EnvoyFilter_CLUSTER, p.ApplyTo)
+		assert.Equal(t, v1alpha3.EnvoyFilter_SIDECAR_OUTBOUND, p.Match.Context)
+		assert.Equal(t, e.host, p.Match.GetCluster().GetService())
+		assert.Equal(t, e.port, p.Match.GetCluster().GetPortNumber())
+		assert.Equal(t, v1alpha3.EnvoyFilter_Patch_MERGE, p.Patch.Operation)
+
+		oauth2 := credentialInjectorOAuth2Config(t, p.Patch.Value.AsMap())
+		tokenEndpoint := oauth2["token_endpoint"].(map[string]interface{})
+		assert.Equal(t, e.tokenCluster, tokenEndpoint["cluster"])
+		assert.Equal(t, e.tokenURI, tokenEndpoint["uri"])
+		assert.Equal(t, e.scopes, oauth2["scopes"])
+		clientCredentials := oauth2["client_credentials"].(map[string]interface{})
+		assert.Equal(t, "my-client-id", clientCredentials["client_id"])
+	}
+}
+
+func credentialInjectorOAuth2Config(t *testing.T, patch map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	protocolOptions := patch["typed_extension_protocol_options"].(map[string]interface{})
+	httpProtocolOptions := protocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"]
+	httpFilters := httpProtocolOptions.(map[string]interface{})["http_filters"].([]interface{})
+	require.Len(t, httpFilters, 2)
+	credentialInjector := httpFilters[0].(map[string]interface{})
+	assert.Equal(t, "envoy.filters.http.credential_injector", credentialInjector["name"])
+	assert.Equal(t, "envoy.filters.http.upstream_codec", httpFilters[1].(map[string]interface{})["name"])
+	credential := credentialInjector["typed_config"].(map[string]interface{})["credential"].(map[string]interface{})
+	return credential["typed_config"].(map[string]interface{})
+}
+
+func defaultScope() state.Scope {
+	clientID := "my-client-id"
+	return state.Scope{
+		AuthPolicy: ztoperatorv1alpha1.AuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "auth-policy", Namespace: "default"},
+			Spec: ztoperatorv1alpha1.AuthPolicySpec{
+				Enabled:      true,
+				WellKnownURI: "http://mock-oauth2.auth:8080/entraid/.well-known/openid-configuration",
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
+					MatchLabels: map[string]string{"app": "application"},
+				},
+				Egress: &ztoperatorv1alpha1.Egress{
+					Enabled: true,
+					Destinations: []ztoperatorv1alpha1.EgressDestination{
+						{
+							Name:   "graph",
+							Hosts:  []string{"graph.microsoft.com", "graph.microsoft.us"},
+							Port:   helperfunctions.Ptr(int32(80)),
+							Scopes: []string{"https://graph.microsoft.com/.default"},
+						},
+						{
+							Name:             "skatteetaten",
+							Hosts:            []string{"api.skatteetaten.no"},
+							Scopes:           []string{"skatteetaten:inntekt"},
+							IdentityProvider: helperfunctions.Ptr("maskinporten"),
+						},
+					},
+				},
+			},
+		},
+		OAuthCredentials: state.OAuthCredentials{
+			ClientID: &clientID,
+		},
+		IdentityProviderUris: state.IdentityProviderUris{
+			IssuerURI: "http://mock-oauth2.auth:8080/entraid",
+			TokenURI:  "http://mock-oauth2.auth:8080/entraid/token",
+		},
+		IdentityProviders: []state.IdentityProvider{
+			{
+				Name: "maskinporten",
+				IdentityProviderUris: state.IdentityProviderUris{
+					IssuerURI: "https://maskinporten.no/",
+					TokenURI:  "https://maskinporten.no/token",
+				},
+			},
+		},
+	}
+}
+
+func defaultObjectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: "auth-policy-egress", Namespace: "default"}
+}
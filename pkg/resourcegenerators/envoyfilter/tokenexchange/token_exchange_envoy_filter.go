@@ -0,0 +1,114 @@
+package tokenexchange
+
+import (
+	"google.golang.org/protobuf/types/known/structpb"
+	"istio.io/api/networking/v1alpha3"
+	v1alpha4 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/targetref"
+)
+
+// GetDesired returns the desired EnvoyFilter resource exchanging tokens of outbound requests for the given
+// AuthPolicy scope
+//
+// The generated EnvoyFilter inserts two config patches:
+//
+//  1. A cluster (ADD) for the token endpoint of the identity provider given by .wellKnownURI.
+//     Internal IdPs (with an explicit port) are reached in plain HTTP; external IdPs are reached in HTTPS.
+//
+//  2. A Lua HTTP filter (INSERT_BEFORE router) in the outbound sidecar HTTP chain, which exchanges the bearer token
+//     of requests to the configured destinations following RFC 8693, using the token endpoint cluster defined above.
+func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *v1alpha4.EnvoyFilter {
+	if !scope.IsEnabled() || scope.InvalidConfig || !scope.AuthPolicy.IsTokenExchangeEnabled() {
+		return nil
+	}
+
+	tokenURI := scope.IdentityProviderUris.TokenURI
+	tokenClusterConfigPatchValue, err := configpatch.GetTokenEndpointClusterConfigPatchValue(
+		configpatch.TokenExchangeClusterName,
+		tokenURI,
+	)
+	if err != nil {
+		panic(
+			"failed to get token endpoint cluster from token URI " + tokenURI +
+				" due to the following error: " + err.Error(),
+		)
+	}
+	tokenClusterConfigPatchValueAsPbStruct, err := structpb.NewStruct(tokenClusterConfigPatchValue)
+	if err != nil {
+		panic(
+			"failed to serialize token endpoint cluster config patch to protobuf struct due to the following error: " +
+				err.Error(),
+		)
+	}
+
+	luaScript, err := luascript.GenerateTokenExchangeLuaScript(
+		*scope.AuthPolicy.Spec.TokenExchange,
+		configpatch.TokenExchangeClusterName,
+		tokenURI,
+		*scope.OAuthCredentials.ClientID,
+		configpatch.IstioClientSecretSource,
+	)
+	if err != nil {
+		panic("failed to generate token exchange Lua script due to the following error: " + err.Error())
+	}
+	luaConfigPatchValueAsPbStruct, err := structpb.NewStruct(
+		configpatch.GetTokenExchangeLuaConfigPatchValue(luaScript),
+	)
+	if err != nil {
+		panic(
+			"failed to serialize token exchange Lua config patch to protobuf struct due to the following error: " +
+				err.Error(),
+		)
+	}
+
+	return &v1alpha4.EnvoyFilter{
+		ObjectMeta: objectMeta,
+		Spec: v1alpha3.EnvoyFilter{
+			ConfigPatches: []*v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: v1alpha3.EnvoyFilter_CLUSTER,
+					Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+						ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Cluster{
+							Cluster: &v1alpha3.EnvoyFilter_ClusterMatch{
+								Service: configpatch.TokenExchangeClusterName,
+							},
+						},
+					},
+					Patch: &v1alpha3.EnvoyFilter_Patch{
+						Operation: v1alpha3.EnvoyFilter_Patch_ADD,
+						Value:     tokenClusterConfigPatchValueAsPbStruct,
+					},
+				},
+				{
+					ApplyTo: v1alpha3.EnvoyFilter_HTTP_FILTER,
+					Match: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+						Context: v1alpha3.EnvoyFilter_SIDECAR_OUTBOUND,
+						ObjectTypes: &v1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+							Listener: &v1alpha3.EnvoyFilter_ListenerMatch{
+								FilterChain: &v1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
+									Filter: &v1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
+										Name: "envoy.filters.network.http_connection_manager",
+										SubFilter: &v1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch{
+											Name: "envoy.filters.http.router",
+										},
+									},
+								},
+							},
+						},
+					},
+					Patch: &v1alpha3.EnvoyFilter_Patch{
+						Operation: v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
+						Value:     luaConfigPatchValueAsPbStruct,
+					},
+				},
+			},
+			WorkloadSelector: targetref.GetEnvoyFilterWorkloadSelector(&scope.AuthPolicy),
+			TargetRefs:       targetref.GetEnvoyFilterTargetReferences(&scope.AuthPolicy),
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package tokenexchange_test
+
+import (
+	"testing"
+
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/tokenexchange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"istio.io/api/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDesired_ReturnsNil_WhenTokenExchangeNil(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.TokenExchange = nil
+	assert.Nil(t, tokenexchange.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_WhenTokenExchangeDisabled(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.TokenExchange.Enabled = false
+	assert.Nil(t, tokenexchange.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_ReturnsNil_WhenInvalidConfig(t *testing.T) {
+	scope := defaultScope()
+	scope.InvalidConfig = true
+	assert.Nil(t, tokenexchange.GetDesired(&scope, defaultObjectMeta()))
+}
+
+func TestGetDesired_AddsTokenEndpointClusterAndOutboundLuaFilter(t *testing.T) {
+	scope := defaultScope()
+
+	ef := tokenexchange.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ef)
+	assert.Equal(t, scope.AuthPolicy.Spec.Selector.MatchLabels, ef.Spec.WorkloadSelector.Labels)
+	require.Len(t, ef.Spec.ConfigPatches, 2)
+
+	cluster := ef.Spec.ConfigPatches[0]
+	assert.Equal(t, v1alpha3.EnvoyFilter_CLUSTER, cluster.ApplyTo)
+	assert.Equal(t, v1alpha3.EnvoyFilter_Patch_ADD, cluster.Patch.Operation)
+	assert.Equal(t, "token-exchange", cluster.Patch.Value.AsMap()["name"])
+	assert.NotNil(t, cluster.Patch.Value.AsMap()["transport_socket"], "external IdP must be reached in TLS")
+
+	lua := ef.Spec.ConfigPatches[1]
+	assert.Equal(t, v1alpha3.EnvoyFilter_HTTP_FILTER, lua.ApplyTo)
+	assert.Equal(t, v1alpha3.EnvoyFilter_SIDECAR_OUTBOUND, lua.Match.Context)
+	assert.Equal(t, v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE, lua.Patch.Operation)
+	assert.Equal(
+		t,
+		"envoy.filters.http.router",
+		lua.Match.GetListener().GetFilterChain().GetFilter().GetSubFilter().GetName(),
+	)
+	typedConfig := lua.Patch.Value.AsMap()["typed_config"].(map[string]interface{})
+	source := typedConfig["default_source_code"].(map[string]interface{})["inline_string"].(string)
+	assert.Contains(t, source, `local token_endpoint_path = "/oauth2/v2.0/token"`)
+	assert.Contains(t, source, `local client_id = "my-client-id"`)
+	assert.Contains(t, source, `local client_secret_path = "/etc/istio/config/client-secret"`)
+	assert.Contains(t, source, `{hosts={"api.example.com"},audience="api://orders",scope=""}`)
+}
+
+func defaultScope() state.Scope {
+	clientID := "my-client-id"
+	return state.Scope{
+		AuthPolicy: ztoperatorv1alpha1.AuthPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "auth-policy", Namespace: "default"},
+			Spec: ztoperatorv1alpha1.AuthPolicySpec{
+				Enabled:      true,
+				WellKnownURI: "https://login.example.com/v2.0/.well-known/openid-configuration",
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
+					MatchLabels: map[string]string{"app": "application"},
+				},
+				TokenExchange: &ztoperatorv1alpha1.TokenExchange{
+					Enabled: true,
+					Destinations: []ztoperatorv1alpha1.TokenExchangeDestination{
+						{Name: "orders", Hosts: []string{"api.example.com"}, Audience: "api://orders"},
+					},
+				},
+			},
+		},
+		OAuthCredentials: state.OAuthCredentials{
+			ClientID: &clientID,
+		},
+		IdentityProviderUris: state.IdentityProviderUris{
+			IssuerURI: "https://login.example.com/v2.0",
+			TokenURI:  "https://login.example.com/oauth2/v2.0/token",
+		},
+	}
+}
+
+func defaultObjectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: "auth-policy-token-exchange", Namespace: "default"}
+}
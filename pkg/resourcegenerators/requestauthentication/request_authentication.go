@@ -1,16 +1,17 @@
 package requestauthentication
 
 import (
+	ztoperatorv1alpha1 "github.com/kartverket/ztoperator/api/v1alpha1"
 	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/targetref"
 	securityv1 "istio.io/api/security/v1"
 	"istio.io/api/security/v1beta1"
-	istiotypev1beta1 "istio.io/api/type/v1beta1"
 	istioclientsecurityv1 "istio.io/client-go/pkg/apis/security/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecurityv1.RequestAuthentication {
-	if !scope.AuthPolicy.Spec.Enabled {
+	if !scope.IsEnabled() {
 		return nil
 	}
 
@@ -18,27 +19,53 @@ func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecuri
 		return nil
 	}
 
+	identityProviders := scope.GetTrustedIdentityProviders()
+	jwtRules := make([]*securityv1.JWTRule, 0, len(identityProviders))
+	for _, identityProvider := range identityProviders {
+		jwtRule := constructJWTRule(identityProvider)
+		setTokenLocations(jwtRule, scope.AuthPolicy)
+		jwtRules = append(jwtRules, jwtRule)
+	}
+
+	return &istioclientsecurityv1.RequestAuthentication{
+		ObjectMeta: objectMeta,
+		Spec: securityv1.RequestAuthentication{
+			Selector:   targetref.GetWorkloadSelector(&scope.AuthPolicy),
+			TargetRefs: targetref.GetPolicyTargetReferences(&scope.AuthPolicy),
+			JwtRules:   jwtRules,
+		},
+	}
+}
+
+func constructJWTRule(identityProvider state.IdentityProvider) *securityv1.JWTRule {
 	var audiences []string
 
-	if len(scope.Audiences) > 0 {
-		audiences = scope.Audiences
+	if len(identityProvider.Audiences) > 0 {
+		audiences = identityProvider.Audiences
 	}
 
 	jwtRule := &securityv1.JWTRule{
-		Issuer:    scope.IdentityProviderUris.IssuerURI,
+		Issuer:    identityProvider.IdentityProviderUris.IssuerURI,
 		Audiences: audiences,
-		JwksUri:   scope.IdentityProviderUris.JwksURI,
 	}
 
-	if scope.AuthPolicy.Spec.ForwardJwt != nil {
-		jwtRule.ForwardOriginalToken = *scope.AuthPolicy.Spec.ForwardJwt
+	// A JWKS given inline or from a ConfigMap/Secret is handed to the sidecar directly, so that it does not need to
+	// reach the JWKS URI of the identity provider.
+	if identityProvider.Jwks != nil {
+		jwtRule.Jwks = *identityProvider.Jwks
+	} else {
+		jwtRule.JwksUri = identityProvider.IdentityProviderUris.JwksURI
+	}
+
+	if identityProvider.ForwardJwt != nil {
+		jwtRule.ForwardOriginalToken = *identityProvider.ForwardJwt
 	} else {
 		jwtRule.ForwardOriginalToken = true
 	}
 
-	if scope.AuthPolicy.Spec.OutputClaimToHeaders != nil && len(*scope.AuthPolicy.Spec.OutputClaimToHeaders) > 0 {
-		claimsToHeaders := make([]*v1beta1.ClaimToHeader, len(*scope.AuthPolicy.Spec.OutputClaimToHeaders))
-		for i, claimToHeader := range *scope.AuthPolicy.Spec.OutputClaimToHeaders {
+	if identityProvider.OutputClaimToHeaders != nil && len(*identityProvider.OutputClaimToHeaders) > 0 {
+		claimsToHeaders := make([]*v1beta1.ClaimToHeader, len(*identityProvider.OutputClaimToHeaders))
+		for i, claimToHeader := range *identityProvider.OutputClaimToHeaders {
 			claimsToHeaders[i] = &v1beta1.ClaimToHeader{
 				Header: claimToHeader.Header,
 				Claim:  claimToHeader.Claim,
@@ -47,11 +74,21 @@ func GetDesired(scope *state.Scope, objectMeta v1.ObjectMeta) *istioclientsecuri
 		jwtRule.OutputClaimToHeaders = claimsToHeaders
 	}
 
-	return &istioclientsecurityv1.RequestAuthentication{
-		ObjectMeta: objectMeta,
-		Spec: securityv1.RequestAuthentication{
-			Selector: &istiotypev1beta1.WorkloadSelector{MatchLabels: scope.AuthPolicy.Spec.Selector.MatchLabels},
-			JwtRules: []*securityv1.JWTRule{jwtRule},
-		},
+	return jwtRule
+}
+
+/*
+Istio only extracts JWTs from the default locations when no locations are specified.
+Thus, the default locations are added along with any custom token locations,
+so that tokens forwarded by the OAuth2 filter in the Authorization header are still accepted.
+*/
+func setTokenLocations(jwtRule *securityv1.JWTRule, authPolicy ztoperatorv1alpha1.AuthPolicy) {
+	if !authPolicy.HasCustomTokenLocations() {
+		return
+	}
+	for _, header := range authPolicy.GetTokenHeaders() {
+		jwtRule.FromHeaders = append(jwtRule.FromHeaders, &v1beta1.JWTHeader{Name: header.Name, Prefix: header.Prefix})
 	}
+	jwtRule.FromParams = authPolicy.GetTokenParams()
+	jwtRule.FromCookies = authPolicy.Spec.FromCookies
 }
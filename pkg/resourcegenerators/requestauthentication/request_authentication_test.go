@@ -47,6 +47,27 @@ func TestGetDesired_WorkloadSelectorMatchesAuthPolicySelector(t *testing.T) {
 	assert.Equal(t, scope.AuthPolicy.Spec.Selector.MatchLabels, ra.Spec.Selector.MatchLabels)
 }
 
+func TestGetDesired_TargetRefsReplaceWorkloadSelector(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.Selector = nil
+	scope.AuthPolicy.Spec.TargetRefs = []ztoperatorv1alpha1.PolicyTargetReference{
+		{Kind: ztoperatorv1alpha1.TargetRefKindGateway, Name: "my-gateway"},
+		{Kind: ztoperatorv1alpha1.TargetRefKindService, Name: "my-service"},
+	}
+
+	ra := requestauthentication.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ra)
+	assert.Nil(t, ra.Spec.Selector)
+	require.Len(t, ra.Spec.TargetRefs, 2)
+	assert.Equal(t, "gateway.networking.k8s.io", ra.Spec.TargetRefs[0].Group)
+	assert.Equal(t, "Gateway", ra.Spec.TargetRefs[0].Kind)
+	assert.Equal(t, "my-gateway", ra.Spec.TargetRefs[0].Name)
+	assert.Empty(t, ra.Spec.TargetRefs[1].Group)
+	assert.Equal(t, "Service", ra.Spec.TargetRefs[1].Kind)
+	assert.Equal(t, "my-service", ra.Spec.TargetRefs[1].Name)
+}
+
 func TestGetDesired_JWTRuleHasCorrectIssuerAndJWKSURI(t *testing.T) {
 	scope := defaultScope()
 
@@ -58,6 +79,41 @@ func TestGetDesired_JWTRuleHasCorrectIssuerAndJWKSURI(t *testing.T) {
 	assert.Equal(t, scope.IdentityProviderUris.JwksURI, ra.Spec.JwtRules[0].JwksUri)
 }
 
+func TestGetDesired_JWTRuleHasInlineJWKS_WhenJWKSIsResolved(t *testing.T) {
+	scope := defaultScope()
+	jwks := `{"keys":[{"kid":"key-1","kty":"RSA","n":"AQAB","e":"AQAB"}]}`
+	scope.Jwks = &jwks
+
+	ra := requestauthentication.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ra)
+	require.Len(t, ra.Spec.JwtRules, 1)
+	assert.Equal(t, scope.IdentityProviderUris.IssuerURI, ra.Spec.JwtRules[0].Issuer)
+	assert.Equal(t, jwks, ra.Spec.JwtRules[0].Jwks)
+	assert.Empty(t, ra.Spec.JwtRules[0].JwksUri)
+}
+
+func TestGetDesired_InlineJWKSAppliesOnlyToItsIdentityProvider(t *testing.T) {
+	scope := defaultScope()
+	jwks := `{"keys":[{"kid":"key-1","kty":"RSA","n":"AQAB","e":"AQAB"}]}`
+	scope.IdentityProviders = []state.IdentityProvider{
+		{
+			Name:                 "internal",
+			IdentityProviderUris: state.IdentityProviderUris{IssuerURI: "https://internal.example.com"},
+			Jwks:                 &jwks,
+		},
+	}
+
+	ra := requestauthentication.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ra)
+	require.Len(t, ra.Spec.JwtRules, 2)
+	assert.Equal(t, scope.IdentityProviderUris.JwksURI, ra.Spec.JwtRules[0].JwksUri)
+	assert.Empty(t, ra.Spec.JwtRules[0].Jwks)
+	assert.Equal(t, jwks, ra.Spec.JwtRules[1].Jwks)
+	assert.Empty(t, ra.Spec.JwtRules[1].JwksUri)
+}
+
 func TestGetDesired_AudiencesAreIncluded_WhenPopulated(t *testing.T) {
 	scope := defaultScope()
 	scope.Audiences = []string{"api://my-api", "https://example.com"}
@@ -164,7 +220,7 @@ func defaultScope() state.Scope {
 			ObjectMeta: metav1.ObjectMeta{Name: "my-policy", Namespace: "default"},
 			Spec: ztoperatorv1alpha1.AuthPolicySpec{
 				Enabled: true,
-				Selector: ztoperatorv1alpha1.WorkloadSelector{
+				Selector: &ztoperatorv1alpha1.WorkloadSelector{
 					MatchLabels: map[string]string{"app": "my-app"},
 				},
 			},
@@ -181,3 +237,117 @@ func defaultScope() state.Scope {
 func defaultObjectMeta() metav1.ObjectMeta {
 	return metav1.ObjectMeta{Name: "my-policy", Namespace: "default"}
 }
+
+func TestGetDesired_OneJWTRulePerTrustedIdentityProvider(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.WellKnownURI = "https://login.example.com/.well-known/openid-configuration"
+	falseValue := false
+	scope.IdentityProviders = []state.IdentityProvider{
+		{
+			Name: "maskinporten",
+			IdentityProviderUris: state.IdentityProviderUris{
+				IssuerURI: "https://maskinporten.example.com",
+				JwksURI:   "https://maskinporten.example.com/jwks",
+			},
+			Audiences:  []string{"maskinporten-client"},
+			ForwardJwt: &falseValue,
+			OutputClaimToHeaders: &[]ztoperatorv1alpha1.ClaimToHeader{
+				{Claim: "consumer", Header: "X-Consumer"},
+			},
+		},
+	}
+
+	ra := requestauthentication.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ra)
+	require.Len(t, ra.Spec.JwtRules, 2)
+	assert.Equal(t, "https://login.example.com", ra.Spec.JwtRules[0].Issuer)
+	assert.True(t, ra.Spec.JwtRules[0].ForwardOriginalToken)
+	assert.Nil(t, ra.Spec.JwtRules[0].OutputClaimToHeaders)
+
+	assert.Equal(t, "https://maskinporten.example.com", ra.Spec.JwtRules[1].Issuer)
+	assert.Equal(t, "https://maskinporten.example.com/jwks", ra.Spec.JwtRules[1].JwksUri)
+	assert.Equal(t, []string{"maskinporten-client"}, ra.Spec.JwtRules[1].Audiences)
+	assert.False(t, ra.Spec.JwtRules[1].ForwardOriginalToken)
+	require.Len(t, ra.Spec.JwtRules[1].OutputClaimToHeaders, 1)
+	assert.Equal(t, "X-Consumer", ra.Spec.JwtRules[1].OutputClaimToHeaders[0].Header)
+}
+
+func TestGetDesired_DefaultIdentityProviderOmitted_WhenOnlyIdentityProvidersAreListed(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.IdentityProviders = []ztoperatorv1alpha1.TrustedIdentityProvider{
+		{Name: "maskinporten", WellKnownURI: "https://maskinporten.example.com/.well-known/oauth-authorization-server"},
+	}
+	scope.IdentityProviders = []state.IdentityProvider{
+		{
+			Name: "maskinporten",
+			IdentityProviderUris: state.IdentityProviderUris{
+				IssuerURI: "https://maskinporten.example.com",
+				JwksURI:   "https://maskinporten.example.com/jwks",
+			},
+		},
+	}
+
+	ra := requestauthentication.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ra)
+	require.Len(t, ra.Spec.JwtRules, 1)
+	assert.Equal(t, "https://maskinporten.example.com", ra.Spec.JwtRules[0].Issuer)
+}
+
+func TestGetDesired_TokenLocationsAreEmpty_WhenNotPopulated(t *testing.T) {
+	scope := defaultScope()
+
+	ra := requestauthentication.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ra)
+	require.Len(t, ra.Spec.JwtRules, 1)
+	assert.Nil(t, ra.Spec.JwtRules[0].FromHeaders)
+	assert.Nil(t, ra.Spec.JwtRules[0].FromParams)
+	assert.Nil(t, ra.Spec.JwtRules[0].FromCookies)
+}
+
+func TestGetDesired_TokenLocationsIncludeDefaults_WhenPopulated(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.FromHeaders = []ztoperatorv1alpha1.JWTHeader{
+		{Name: "X-Api-Token"},
+		{Name: "authorization", Prefix: "Bearer "},
+	}
+	scope.AuthPolicy.Spec.FromParams = []string{"access_token", "token"}
+	scope.AuthPolicy.Spec.FromCookies = []string{"session"}
+
+	ra := requestauthentication.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ra)
+	require.Len(t, ra.Spec.JwtRules, 1)
+	jwtRule := ra.Spec.JwtRules[0]
+	require.Len(t, jwtRule.FromHeaders, 2)
+	assert.Equal(t, "Authorization", jwtRule.FromHeaders[0].Name)
+	assert.Equal(t, "Bearer ", jwtRule.FromHeaders[0].Prefix)
+	assert.Equal(t, "X-Api-Token", jwtRule.FromHeaders[1].Name)
+	assert.Empty(t, jwtRule.FromHeaders[1].Prefix)
+	assert.Equal(t, []string{"access_token", "token"}, jwtRule.FromParams)
+	assert.Equal(t, []string{"session"}, jwtRule.FromCookies)
+}
+
+func TestGetDesired_TokenLocationsApplyToAllIdentityProviders(t *testing.T) {
+	scope := defaultScope()
+	scope.AuthPolicy.Spec.FromCookies = []string{"session"}
+	scope.IdentityProviders = []state.IdentityProvider{
+		{
+			Name: "maskinporten",
+			IdentityProviderUris: state.IdentityProviderUris{
+				IssuerURI: "https://maskinporten.example.com",
+				JwksURI:   "https://maskinporten.example.com/jwks",
+			},
+		},
+	}
+
+	ra := requestauthentication.GetDesired(&scope, defaultObjectMeta())
+
+	require.NotNil(t, ra)
+	require.Len(t, ra.Spec.JwtRules, 2)
+	for _, jwtRule := range ra.Spec.JwtRules {
+		assert.Equal(t, []string{"session"}, jwtRule.FromCookies)
+	}
+}
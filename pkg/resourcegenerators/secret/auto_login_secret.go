@@ -5,6 +5,7 @@ import (
 
 	"github.com/kartverket/ztoperator/internal/state"
 	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/luascript"
 	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -12,8 +13,7 @@ import (
 )
 
 func GetDesired(scope *state.Scope, objectMeta metav1.ObjectMeta) *v1.Secret {
-	if !scope.AuthPolicy.Spec.Enabled || scope.InvalidConfig || scope.AuthPolicy.Spec.AutoLogin == nil ||
-		!scope.AuthPolicy.Spec.AutoLogin.Enabled {
+	if !scope.IsEnabled() || scope.InvalidConfig || !scope.AuthPolicy.UsesOAuthCredentials() {
 		return nil
 	}
 
@@ -21,6 +21,14 @@ func GetDesired(scope *state.Scope, objectMeta metav1.ObjectMeta) *v1.Secret {
 	if err != nil {
 		return nil
 	}
+	if scope.AuthPolicy.IsTokenExchangeEnabled() {
+		// The Lua filter exchanging tokens cannot use SDS, and reads the client secret as is
+		envoySecret.Data[configpatch.ClientSecretFileName] = []byte(*scope.OAuthCredentials.ClientSecret)
+	}
+	if scope.AutoLoginConfig.SignalHeaderSuffix != "" {
+		// Kept for the next reconcile, so that the signal header names of the EnvoyFilter stay the same
+		envoySecret.Data[luascript.SignalHeaderSuffixSecretKey] = []byte(scope.AutoLoginConfig.SignalHeaderSuffix)
+	}
 	return envoySecret
 }
 
@@ -0,0 +1,80 @@
+package targetref
+
+import (
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"istio.io/api/networking/v1alpha3"
+	istiotypev1beta1 "istio.io/api/type/v1beta1"
+)
+
+// GatewayGroup is the API group of Kubernetes Gateway API resources, including waypoints.
+const GatewayGroup = "gateway.networking.k8s.io"
+
+// GetWorkloadSelector returns the workload selector of RequestAuthentications and AuthorizationPolicies generated
+// for the AuthPolicy, or nil when the AuthPolicy uses targetRefs.
+func GetWorkloadSelector(authPolicy *v1alpha1.AuthPolicy) *istiotypev1beta1.WorkloadSelector {
+	if authPolicy.Spec.Selector == nil {
+		return nil
+	}
+	return &istiotypev1beta1.WorkloadSelector{MatchLabels: authPolicy.Spec.Selector.MatchLabels}
+}
+
+// GetPolicyTargetReferences returns the targetRefs of RequestAuthentications and AuthorizationPolicies generated for
+// the AuthPolicy, or nil when the AuthPolicy uses a selector.
+func GetPolicyTargetReferences(authPolicy *v1alpha1.AuthPolicy) []*istiotypev1beta1.PolicyTargetReference {
+	if len(authPolicy.Spec.TargetRefs) == 0 {
+		return nil
+	}
+	targetRefs := make([]*istiotypev1beta1.PolicyTargetReference, 0, len(authPolicy.Spec.TargetRefs))
+	for _, targetRef := range authPolicy.Spec.TargetRefs {
+		targetRefs = append(targetRefs, &istiotypev1beta1.PolicyTargetReference{
+			Group: getGroup(targetRef.Kind),
+			Kind:  string(targetRef.Kind),
+			Name:  targetRef.Name,
+		})
+	}
+	return targetRefs
+}
+
+// GetEnvoyFilterWorkloadSelector returns the workload selector of EnvoyFilters generated for the AuthPolicy, or nil
+// when the AuthPolicy uses targetRefs.
+func GetEnvoyFilterWorkloadSelector(authPolicy *v1alpha1.AuthPolicy) *v1alpha3.WorkloadSelector {
+	if authPolicy.Spec.Selector == nil {
+		return nil
+	}
+	return &v1alpha3.WorkloadSelector{Labels: authPolicy.Spec.Selector.MatchLabels}
+}
+
+// GetEnvoyFilterTargetReferences returns the targetRefs of EnvoyFilters generated for the AuthPolicy, or nil when the
+// AuthPolicy uses a selector. EnvoyFilters can only target gateways, thus targetRefs of other kinds are left out.
+func GetEnvoyFilterTargetReferences(authPolicy *v1alpha1.AuthPolicy) []*istiotypev1beta1.PolicyTargetReference {
+	var targetRefs []*istiotypev1beta1.PolicyTargetReference
+	for _, targetRef := range GetPolicyTargetReferences(authPolicy) {
+		if targetRef.GetKind() == string(v1alpha1.TargetRefKindGateway) {
+			targetRefs = append(targetRefs, targetRef)
+		}
+	}
+	return targetRefs
+}
+
+// GetInboundPatchContext returns the context in which EnvoyFilters generated for the AuthPolicy patch the HTTP filter
+// chain of inbound requests, being GATEWAY for AuthPolicies applying to gateways and SIDECAR_INBOUND otherwise.
+func GetInboundPatchContext(authPolicy *v1alpha1.AuthPolicy) v1alpha3.EnvoyFilter_PatchContext {
+	if authPolicy.TargetsGateways() {
+		return v1alpha3.EnvoyFilter_GATEWAY
+	}
+	return v1alpha3.EnvoyFilter_SIDECAR_INBOUND
+}
+
+// SupportsInboundEnvoyFilters reports whether EnvoyFilters patching inbound requests can be applied for the
+// AuthPolicy, which is not the case when the AuthPolicy applies to Services.
+func SupportsInboundEnvoyFilters(authPolicy *v1alpha1.AuthPolicy) bool {
+	return authPolicy.Spec.Selector != nil || authPolicy.TargetsGateways()
+}
+
+func getGroup(kind v1alpha1.TargetRefKind) string {
+	if kind == v1alpha1.TargetRefKindGateway {
+		return GatewayGroup
+	}
+	// Services belong to the core API group
+	return ""
+}
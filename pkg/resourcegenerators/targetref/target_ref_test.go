@@ -0,0 +1,101 @@
+package targetref_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/targetref"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"istio.io/api/networking/v1alpha3"
+)
+
+func TestGetWorkloadSelector_ReturnsNil_WhenTargetRefsAreUsed(t *testing.T) {
+	authPolicy := targetRefsAuthPolicy(v1alpha1.TargetRefKindGateway)
+
+	assert.Nil(t, targetref.GetWorkloadSelector(&authPolicy))
+	assert.Nil(t, targetref.GetEnvoyFilterWorkloadSelector(&authPolicy))
+}
+
+func TestGetPolicyTargetReferences_ReturnsNil_WhenSelectorIsUsed(t *testing.T) {
+	authPolicy := selectorAuthPolicy()
+
+	assert.Nil(t, targetref.GetPolicyTargetReferences(&authPolicy))
+	assert.Nil(t, targetref.GetEnvoyFilterTargetReferences(&authPolicy))
+	assert.Equal(t, map[string]string{"app": "application"}, targetref.GetWorkloadSelector(&authPolicy).MatchLabels)
+	assert.Equal(t, map[string]string{"app": "application"}, targetref.GetEnvoyFilterWorkloadSelector(&authPolicy).Labels)
+}
+
+func TestGetPolicyTargetReferences_SetsGroupPerKind(t *testing.T) {
+	authPolicy := targetRefsAuthPolicy(v1alpha1.TargetRefKindGateway, v1alpha1.TargetRefKindService)
+
+	targetRefs := targetref.GetPolicyTargetReferences(&authPolicy)
+
+	require.Len(t, targetRefs, 2)
+	assert.Equal(t, targetref.GatewayGroup, targetRefs[0].GetGroup())
+	assert.Equal(t, "Gateway", targetRefs[0].GetKind())
+	assert.Empty(t, targetRefs[1].GetGroup())
+	assert.Equal(t, "Service", targetRefs[1].GetKind())
+}
+
+func TestGetEnvoyFilterTargetReferences_LeavesOutServices(t *testing.T) {
+	authPolicy := targetRefsAuthPolicy(v1alpha1.TargetRefKindGateway, v1alpha1.TargetRefKindService)
+
+	targetRefs := targetref.GetEnvoyFilterTargetReferences(&authPolicy)
+
+	require.Len(t, targetRefs, 1)
+	assert.Equal(t, "Gateway", targetRefs[0].GetKind())
+}
+
+func TestGetInboundPatchContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		authPolicy v1alpha1.AuthPolicy
+		expected   v1alpha3.EnvoyFilter_PatchContext
+		supported  bool
+	}{
+		{
+			name:       "selector",
+			authPolicy: selectorAuthPolicy(),
+			expected:   v1alpha3.EnvoyFilter_SIDECAR_INBOUND,
+			supported:  true,
+		},
+		{
+			name:       "gateway",
+			authPolicy: targetRefsAuthPolicy(v1alpha1.TargetRefKindGateway),
+			expected:   v1alpha3.EnvoyFilter_GATEWAY,
+			supported:  true,
+		},
+		{
+			name:       "service",
+			authPolicy: targetRefsAuthPolicy(v1alpha1.TargetRefKindService),
+			expected:   v1alpha3.EnvoyFilter_SIDECAR_INBOUND,
+			supported:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, targetref.GetInboundPatchContext(&tt.authPolicy))
+			assert.Equal(t, tt.supported, targetref.SupportsInboundEnvoyFilters(&tt.authPolicy))
+		})
+	}
+}
+
+func selectorAuthPolicy() v1alpha1.AuthPolicy {
+	return v1alpha1.AuthPolicy{
+		Spec: v1alpha1.AuthPolicySpec{
+			Selector: &v1alpha1.WorkloadSelector{MatchLabels: map[string]string{"app": "application"}},
+		},
+	}
+}
+
+func targetRefsAuthPolicy(kinds ...v1alpha1.TargetRefKind) v1alpha1.AuthPolicy {
+	authPolicy := v1alpha1.AuthPolicy{}
+	for _, kind := range kinds {
+		authPolicy.Spec.TargetRefs = append(authPolicy.Spec.TargetRefs, v1alpha1.PolicyTargetReference{
+			Kind: kind,
+			Name: "target",
+		})
+	}
+	return authPolicy
+}
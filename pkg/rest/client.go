@@ -1,52 +1,212 @@
 package rest
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
+	"golang.org/x/sync/singleflight"
 	"resty.dev/v3"
 
+	"github.com/kartverket/ztoperator/pkg/config"
 	"github.com/kartverket/ztoperator/pkg/log"
+	"github.com/kartverket/ztoperator/pkg/metrics"
 )
 
 type DiscoveryDocumentResolver interface {
 	GetOAuthDiscoveryDocument(uri string, rLog log.Logger) (*DiscoveryDocument, error)
 }
 
-type DefaultDiscoveryDocumentResolver struct{}
+// DefaultDiscoveryDocumentResolver fetches discovery documents over HTTP, and caches them in memory by well-known uri.
+//
+// Cached discovery documents are served for the configured TTL, after which they are revalidated using the ETag and
+// Last-Modified headers of the previous response. Concurrent fetches of the same well-known uri are deduplicated, and
+// an expired discovery document is served if it cannot be fetched, so that reconciles survive identity provider
+// outages.
+type DefaultDiscoveryDocumentResolver struct {
+	client *resty.Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]discoveryDocumentCacheEntry
+	fetches singleflight.Group
+}
+
+type discoveryDocumentCacheEntry struct {
+	discoveryDocument DiscoveryDocument
+	etag              string
+	lastModified      string
+	expiresAt         time.Time
+}
 
 func NewDefaultDiscoveryDocumentResolver() *DefaultDiscoveryDocumentResolver {
-	return &DefaultDiscoveryDocumentResolver{}
+	return newDiscoveryDocumentResolver(resty.New())
+}
+
+// NewDiscoveryDocumentResolverWithCABundle returns a DefaultDiscoveryDocumentResolver trusting the PEM encoded CA
+// certificates in caBundle, in addition to the system trust store.
+func NewDiscoveryDocumentResolverWithCABundle(caBundle string) (*DefaultDiscoveryDocumentResolver, error) {
+	client, err := newClientWithCABundle(caBundle)
+	if err != nil {
+		return nil, err
+	}
+	return newDiscoveryDocumentResolver(client), nil
+}
+
+func newDiscoveryDocumentResolver(client *resty.Client) *DefaultDiscoveryDocumentResolver {
+	return &DefaultDiscoveryDocumentResolver{
+		client:  client,
+		ttl:     config.Get().DiscoveryDocumentCacheTTL,
+		now:     time.Now,
+		entries: map[string]discoveryDocumentCacheEntry{},
+	}
+}
+
+// Close releases the idle connections of the HTTP client of the resolver.
+func (r *DefaultDiscoveryDocumentResolver) Close() error {
+	return r.client.Close()
 }
 
 func (r *DefaultDiscoveryDocumentResolver) GetOAuthDiscoveryDocument(
 	uri string,
 	rLog log.Logger,
 ) (*DiscoveryDocument, error) {
+	r.mu.Lock()
+	entry, cached := r.entries[uri]
+	r.mu.Unlock()
+	if cached && r.now().Before(entry.expiresAt) {
+		metrics.RecordDiscoveryDocumentCacheHit()
+		discoveryDocument := entry.discoveryDocument
+		return &discoveryDocument, nil
+	}
+	metrics.RecordDiscoveryDocumentCacheMiss()
+
+	result, err, _ := r.fetches.Do(uri, func() (interface{}, error) {
+		return r.fetchDiscoveryDocument(uri, rLog)
+	})
+	if err != nil {
+		if cached {
+			rLog.Info(fmt.Sprintf(
+				"Using expired discovery document for well-known uri: %s, as fetching it failed: %s",
+				uri,
+				err.Error(),
+			))
+			metrics.RecordDiscoveryDocumentCacheStale()
+			discoveryDocument := entry.discoveryDocument
+			return &discoveryDocument, nil
+		}
+		return nil, err
+	}
+	discoveryDocument := result.(DiscoveryDocument)
+	return &discoveryDocument, nil
+}
+
+// fetchDiscoveryDocument fetches the discovery document from the well-known uri, revalidating any cached discovery
+// document, and updates the cache.
+func (r *DefaultDiscoveryDocumentResolver) fetchDiscoveryDocument(
+	uri string,
+	rLog log.Logger,
+) (DiscoveryDocument, error) {
+	r.mu.Lock()
+	entry, cached := r.entries[uri]
+	r.mu.Unlock()
+
+	request := r.client.R()
+	if cached {
+		rLog.Info(fmt.Sprintf("Revalidating discovery document for well-known uri: %s", uri))
+		if entry.etag != "" {
+			request.SetHeader("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			request.SetHeader("If-Modified-Since", entry.lastModified)
+		}
+	} else {
+		rLog.Info(fmt.Sprintf("Fetching discovery document for well-known uri: %s", uri))
+	}
+
 	var discoveryDocument DiscoveryDocument
+	start := time.Now()
+	res, err := request.SetResult(&discoveryDocument).Get(uri)
+	switch {
+	case err != nil:
+		metrics.ObserveDiscoveryDocumentFetch(metrics.DiscoveryDocumentFetchResultError, time.Since(start))
+		return DiscoveryDocument{}, err
+	case cached && res.StatusCode() == http.StatusNotModified:
+		metrics.ObserveDiscoveryDocumentFetch(metrics.DiscoveryDocumentFetchResultNotModified, time.Since(start))
+		discoveryDocument = entry.discoveryDocument
+	case res.StatusCode() == http.StatusOK:
+		metrics.ObserveDiscoveryDocumentFetch(metrics.DiscoveryDocumentFetchResultOK, time.Since(start))
+		entry.etag = res.Header().Get("ETag")
+		entry.lastModified = res.Header().Get("Last-Modified")
+	default:
+		metrics.ObserveDiscoveryDocumentFetch(metrics.DiscoveryDocumentFetchResultError, time.Since(start))
+		return DiscoveryDocument{}, errors.New(res.Status())
+	}
 
-	wellknownURIToDiscoveryDocument := GetWellknownURIToDiscoveryDocument()
+	entry.discoveryDocument = discoveryDocument
+	entry.expiresAt = r.now().Add(r.ttl)
+	r.mu.Lock()
+	r.entries[uri] = entry
+	r.mu.Unlock()
+	return discoveryDocument, nil
+}
+
+type JWKSResolver interface {
+	GetJWKS(uri string, rLog log.Logger) (*JWKS, error)
+}
+
+// DefaultJWKSResolver fetches JWKS over HTTP.
+type DefaultJWKSResolver struct {
+	client *resty.Client
+}
+
+func NewDefaultJWKSResolver() *DefaultJWKSResolver {
+	return &DefaultJWKSResolver{client: resty.New()}
+}
 
-	if _, exists := wellknownURIToDiscoveryDocument[uri]; exists {
-		rLog.Info(fmt.Sprintf("Using cached discovery document for well-known uri: %s", uri))
-		cachedDiscoveryDocument := wellknownURIToDiscoveryDocument[uri]
-		return &cachedDiscoveryDocument, nil
+// NewJWKSResolverWithCABundle returns a DefaultJWKSResolver trusting the PEM encoded CA certificates in caBundle,
+// in addition to the system trust store.
+func NewJWKSResolverWithCABundle(caBundle string) (*DefaultJWKSResolver, error) {
+	client, err := newClientWithCABundle(caBundle)
+	if err != nil {
+		return nil, err
 	}
-	rLog.Info(fmt.Sprintf("Fetching discovery document for well-known uri: %s", uri))
-	client := resty.New()
-	defer func(client *resty.Client) {
-		closeErr := client.Close()
-		if closeErr != nil {
-			panic(closeErr)
-		}
-	}(client)
+	return &DefaultJWKSResolver{client: client}, nil
+}
+
+// Close releases the idle connections of the HTTP client of the resolver.
+func (r *DefaultJWKSResolver) Close() error {
+	return r.client.Close()
+}
 
-	res, err := client.R().SetResult(&discoveryDocument).Get(uri)
+func (r *DefaultJWKSResolver) GetJWKS(uri string, rLog log.Logger) (*JWKS, error) {
+	rLog.Info(fmt.Sprintf("Fetching JWKS from uri: %s", uri))
+	var jwks JWKS
+	res, err := r.client.R().SetResult(&jwks).Get(uri)
 	if err != nil {
 		return nil, err
 	}
-	if res.StatusCode() != 200 {
+	if res.StatusCode() != http.StatusOK {
 		return nil, errors.New(res.Status())
 	}
-	return &discoveryDocument, nil
+	return &jwks, nil
+}
+
+func newClientWithCABundle(caBundle string) (*resty.Client, error) {
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM([]byte(caBundle)) {
+		return nil, errors.New("caBundle does not contain any valid PEM encoded certificates")
+	}
+	return resty.New().SetTLSClientConfig(&tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS12,
+	}), nil
 }
@@ -1,37 +1,19 @@
 package rest
 
 import (
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	ztlog "github.com/kartverket/ztoperator/pkg/log"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
-func TestGetOAuthDiscoveryDocument_ReturnsCachedDocumentForKnownURI(t *testing.T) {
-	t.Parallel()
-
-	resolver := NewDefaultDiscoveryDocumentResolver()
-	uri := "https://idporten.no/.well-known/openid-configuration"
-
-	doc, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger())
-	if err != nil {
-		t.Fatalf("expected no error for cached URI, got: %v", err)
-	}
-	if doc == nil {
-		t.Fatal("expected discovery document, got nil")
-	}
-
-	want := GetWellknownURIToDiscoveryDocument()[uri]
-	assertStringPtrEqual(t, "issuer", doc.Issuer, want.Issuer)
-	assertStringPtrEqual(t, "authorization_endpoint", doc.AuthorizationEndpoint, want.AuthorizationEndpoint)
-	assertStringPtrEqual(t, "token_endpoint", doc.TokenEndpoint, want.TokenEndpoint)
-	assertStringPtrEqual(t, "jwks_uri", doc.JwksURI, want.JwksURI)
-	assertStringPtrEqual(t, "end_session_endpoint", doc.EndSessionEndpoint, want.EndSessionEndpoint)
-}
-
 func TestGetOAuthDiscoveryDocument_FetchesUnknownURIOverHTTP(t *testing.T) {
 	t.Parallel()
 
@@ -86,6 +68,256 @@ func TestGetOAuthDiscoveryDocument_ReturnsErrorForNon200Response(t *testing.T) {
 	}
 }
 
+func TestGetOAuthDiscoveryDocument_ServesCachedDocumentWithinTTL(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		writeDiscoveryDocument(w)
+	}))
+	defer server.Close()
+
+	resolver, clock := newTestResolver(time.Minute)
+	uri := server.URL + "/.well-known/openid-configuration"
+
+	for range 3 {
+		doc, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger())
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		assertStringPtrValue(t, "issuer", doc.Issuer, "https://issuer.example.com")
+		clock.advance(10 * time.Second)
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("expected discovery document to be fetched once within TTL, got %d requests", got)
+	}
+}
+
+func TestGetOAuthDiscoveryDocument_RevalidatesExpiredDocumentWithETag(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	var revalidations atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` &&
+			r.Header.Get("If-Modified-Since") == "Wed, 21 Oct 2015 07:28:00 GMT" {
+			revalidations.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		writeDiscoveryDocument(w)
+	}))
+	defer server.Close()
+
+	resolver, clock := newTestResolver(time.Minute)
+	uri := server.URL + "/.well-known/openid-configuration"
+
+	if _, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	clock.advance(2 * time.Minute)
+	doc, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger())
+	if err != nil {
+		t.Fatalf("expected no error when revalidating, got: %v", err)
+	}
+	assertStringPtrValue(t, "jwks_uri", doc.JwksURI, "https://issuer.example.com/jwks")
+
+	// The revalidated document is cached for another TTL
+	if _, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("expected 2 requests, got %d", got)
+	}
+	if got := revalidations.Load(); got != 1 {
+		t.Fatalf("expected 1 conditional request, got %d", got)
+	}
+}
+
+func TestGetOAuthDiscoveryDocument_ServesExpiredDocumentWhenFetchFails(t *testing.T) {
+	t.Parallel()
+
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if failing.Load() {
+			http.Error(w, "temporary failure", http.StatusServiceUnavailable)
+			return
+		}
+		writeDiscoveryDocument(w)
+	}))
+	defer server.Close()
+
+	resolver, clock := newTestResolver(time.Minute)
+	uri := server.URL + "/.well-known/openid-configuration"
+
+	if _, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	failing.Store(true)
+	clock.advance(2 * time.Minute)
+
+	doc, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger())
+	if err != nil {
+		t.Fatalf("expected expired document to be served when fetching fails, got: %v", err)
+	}
+	assertStringPtrValue(t, "issuer", doc.Issuer, "https://issuer.example.com")
+}
+
+func TestGetOAuthDiscoveryDocument_DeduplicatesConcurrentFetches(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		<-release
+		writeDiscoveryDocument(w)
+	}))
+	defer server.Close()
+
+	resolver, _ := newTestResolver(time.Minute)
+	uri := server.URL + "/.well-known/openid-configuration"
+
+	const concurrentReconciles = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrentReconciles)
+	for range concurrentReconciles {
+		wg.Go(func() {
+			_, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger())
+			errs <- err
+		})
+	}
+	// Give all goroutines the chance to join the in-flight fetch before the response is sent
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("expected concurrent fetches to be deduplicated into 1 request, got %d", got)
+	}
+}
+
+func TestGetOAuthDiscoveryDocument_WithCABundle_TrustsServerCertificate(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeDiscoveryDocument(w)
+	}))
+	defer server.Close()
+	uri := server.URL + "/.well-known/openid-configuration"
+
+	if _, err := NewDefaultDiscoveryDocumentResolver().GetOAuthDiscoveryDocument(uri, testLogger()); err == nil {
+		t.Fatal("expected error when the server certificate is not trusted, got nil")
+	}
+
+	caBundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	resolver, err := NewDiscoveryDocumentResolverWithCABundle(caBundle)
+	if err != nil {
+		t.Fatalf("expected no error for valid caBundle, got: %v", err)
+	}
+	doc, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger())
+	if err != nil {
+		t.Fatalf("expected no error when the server certificate is trusted, got: %v", err)
+	}
+	assertStringPtrValue(t, "issuer", doc.Issuer, "https://issuer.example.com")
+}
+
+func TestNewDiscoveryDocumentResolverWithCABundle_ReturnsErrorForInvalidPEM(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewDiscoveryDocumentResolverWithCABundle("not a certificate"); err == nil {
+		t.Fatal("expected error for invalid caBundle, got nil")
+	}
+	if _, err := NewJWKSResolverWithCABundle("not a certificate"); err == nil {
+		t.Fatal("expected error for invalid caBundle, got nil")
+	}
+}
+
+func TestGetJWKS_ReturnsKeys(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"keys":[
+			{"kid":"key-1","kty":"RSA","alg":"RS256","use":"sig","n":"AQAB","e":"AQAB"},
+			{"kid":"key-2","kty":"EC","crv":"P-256","x":"AQAB","y":"AQAB"}
+		]}`))
+	}))
+	defer server.Close()
+
+	jwks, err := NewDefaultJWKSResolver().GetJWKS(server.URL+"/jwks", testLogger())
+	if err != nil {
+		t.Fatalf("expected no error when fetching JWKS, got: %v", err)
+	}
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(jwks.Keys))
+	}
+	if jwks.Keys[0].KeyID != "key-1" || jwks.Keys[0].Algorithm != "RS256" || jwks.Keys[1].KeyType != "EC" {
+		t.Fatalf("unexpected keys: %+v", jwks.Keys)
+	}
+}
+
+func TestGetJWKS_ReturnsErrorForNon200Response(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if _, err := NewDefaultJWKSResolver().GetJWKS(server.URL+"/jwks", testLogger()); err == nil {
+		t.Fatal("expected error for non-200 response, got nil")
+	}
+}
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestResolver(ttl time.Duration) (*DefaultDiscoveryDocumentResolver, *testClock) {
+	clock := &testClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	resolver := NewDefaultDiscoveryDocumentResolver()
+	resolver.ttl = ttl
+	resolver.now = clock.Now
+	return resolver, clock
+}
+
+func writeDiscoveryDocument(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{
+		"issuer":"https://issuer.example.com",
+		"token_endpoint":"https://issuer.example.com/token",
+		"jwks_uri":"https://issuer.example.com/jwks"
+	}`))
+}
+
 func testLogger() ztlog.Logger {
 	return ztlog.Logger{Logger: ctrl.Log.WithName("rest-client-test")}
 }
@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kartverket/ztoperator/pkg/config"
+	"github.com/kartverket/ztoperator/pkg/log"
+)
+
+// StaticDiscoveryDocumentResolver serves discovery documents from a ConfigMap, and falls back to another
+// DiscoveryDocumentResolver for well-known URIs not found in the ConfigMap.
+//
+// Every entry of the ConfigMap holds a StaticDiscoveryDocument in YAML or JSON, which lets ztoperator be used with
+// identity providers it cannot reach, such as identity providers only reachable from within the cluster when running
+// ztoperator locally.
+type StaticDiscoveryDocumentResolver struct {
+	reader    client.Reader
+	configMap types.NamespacedName
+	next      DiscoveryDocumentResolver
+}
+
+// NewStaticDiscoveryDocumentResolver returns a StaticDiscoveryDocumentResolver reading the ConfigMap configured by
+// ZTOPERATOR_STATIC_DISCOVERY_DOCUMENTS_CONFIG_MAP, given as `<namespace>/<name>`. next is returned as is when no
+// ConfigMap is configured.
+func NewStaticDiscoveryDocumentResolver(
+	reader client.Reader,
+	next DiscoveryDocumentResolver,
+) (DiscoveryDocumentResolver, error) {
+	configMap, err := GetStaticDiscoveryDocumentsConfigMap()
+	if err != nil {
+		return nil, err
+	}
+	if configMap == nil {
+		return next, nil
+	}
+	return &StaticDiscoveryDocumentResolver{reader: reader, configMap: *configMap, next: next}, nil
+}
+
+// GetStaticDiscoveryDocumentsConfigMap returns the namespaced name of the ConfigMap configured by
+// ZTOPERATOR_STATIC_DISCOVERY_DOCUMENTS_CONFIG_MAP, or nil if none is configured.
+func GetStaticDiscoveryDocumentsConfigMap() (*types.NamespacedName, error) {
+	return parseStaticDiscoveryDocumentsConfigMap(config.Get().StaticDiscoveryDocumentsConfigMap)
+}
+
+func parseStaticDiscoveryDocumentsConfigMap(configMap string) (*types.NamespacedName, error) {
+	if configMap == "" {
+		return nil, nil
+	}
+	namespace, name, found := strings.Cut(configMap, "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("static discovery documents ConfigMap %s must be given as <namespace>/<name>", configMap)
+	}
+	return &types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+func (r *StaticDiscoveryDocumentResolver) GetOAuthDiscoveryDocument(
+	uri string,
+	rLog log.Logger,
+) (*DiscoveryDocument, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := r.reader.Get(context.Background(), r.configMap, configMap); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get static discovery documents ConfigMap %s: %w", r.configMap, err)
+		}
+		rLog.Debug(fmt.Sprintf("Static discovery documents ConfigMap %s not found", r.configMap))
+		return r.next.GetOAuthDiscoveryDocument(uri, rLog)
+	}
+
+	for key, value := range configMap.Data {
+		var staticDiscoveryDocument StaticDiscoveryDocument
+		if err := yaml.Unmarshal([]byte(value), &staticDiscoveryDocument); err != nil {
+			rLog.Error(err, fmt.Sprintf("Ignoring invalid static discovery document %s in ConfigMap %s", key, r.configMap))
+			continue
+		}
+		if staticDiscoveryDocument.WellKnownURI == uri {
+			rLog.Info(fmt.Sprintf("Using static discovery document %s for well-known uri: %s", key, uri))
+			return &staticDiscoveryDocument.DiscoveryDocument, nil
+		}
+	}
+
+	return r.next.GetOAuthDiscoveryDocument(uri, rLog)
+}
@@ -0,0 +1,177 @@
+package rest
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	ztlog "github.com/kartverket/ztoperator/pkg/log"
+)
+
+type recordingDiscoveryDocumentResolver struct {
+	uris []string
+}
+
+func (r *recordingDiscoveryDocumentResolver) GetOAuthDiscoveryDocument(
+	uri string,
+	_ ztlog.Logger,
+) (*DiscoveryDocument, error) {
+	r.uris = append(r.uris, uri)
+	return nil, errors.New("not found")
+}
+
+func TestStaticDiscoveryDocumentResolver_ReturnsDocumentFromConfigMap(t *testing.T) {
+	t.Parallel()
+
+	next := &recordingDiscoveryDocumentResolver{}
+	resolver := newTestStaticResolver(t, next, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "static-discovery-documents", Namespace: "ztoperator-system"},
+		Data: map[string]string{
+			"entraid.yaml": `
+wellKnownURI: http://mock-oauth2.auth:8080/entraid/.well-known/openid-configuration
+issuer: http://mock-oauth2.auth:8080/entraid
+authorization_endpoint: http://mock-oauth2.auth:8080/entraid/authorize
+token_endpoint: http://mock-oauth2.auth:8080/entraid/token
+jwks_uri: http://mock-oauth2.auth:8080/entraid/jwks
+end_session_endpoint: http://mock-oauth2.auth:8080/entraid/endsession
+`,
+			"maskinporten.json": `{
+				"wellKnownURI": "http://mock-oauth2.auth:8080/maskinporten/.well-known/openid-configuration",
+				"issuer": "http://mock-oauth2.auth:8080/maskinporten",
+				"token_endpoint": "http://mock-oauth2.auth:8080/maskinporten/token",
+				"jwks_uri": "http://mock-oauth2.auth:8080/maskinporten/jwks"
+			}`,
+		},
+	})
+
+	doc, err := resolver.GetOAuthDiscoveryDocument(
+		"http://mock-oauth2.auth:8080/entraid/.well-known/openid-configuration",
+		testLogger(),
+	)
+	if err != nil {
+		t.Fatalf("expected no error for static discovery document, got: %v", err)
+	}
+	assertStringPtrValue(t, "issuer", doc.Issuer, "http://mock-oauth2.auth:8080/entraid")
+	assertStringPtrValue(
+		t, "authorization_endpoint", doc.AuthorizationEndpoint, "http://mock-oauth2.auth:8080/entraid/authorize",
+	)
+	assertStringPtrValue(t, "token_endpoint", doc.TokenEndpoint, "http://mock-oauth2.auth:8080/entraid/token")
+	assertStringPtrValue(t, "jwks_uri", doc.JwksURI, "http://mock-oauth2.auth:8080/entraid/jwks")
+	assertStringPtrValue(
+		t, "end_session_endpoint", doc.EndSessionEndpoint, "http://mock-oauth2.auth:8080/entraid/endsession",
+	)
+
+	doc, err = resolver.GetOAuthDiscoveryDocument(
+		"http://mock-oauth2.auth:8080/maskinporten/.well-known/openid-configuration",
+		testLogger(),
+	)
+	if err != nil {
+		t.Fatalf("expected no error for static discovery document, got: %v", err)
+	}
+	assertStringPtrValue(t, "issuer", doc.Issuer, "http://mock-oauth2.auth:8080/maskinporten")
+	assertStringPtrEqual(t, "end_session_endpoint", doc.EndSessionEndpoint, nil)
+
+	if len(next.uris) != 0 {
+		t.Fatalf("expected static discovery documents not to be fetched, got: %v", next.uris)
+	}
+}
+
+func TestStaticDiscoveryDocumentResolver_FallsBackForUnknownURI(t *testing.T) {
+	t.Parallel()
+
+	next := &recordingDiscoveryDocumentResolver{}
+	resolver := newTestStaticResolver(t, next, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "static-discovery-documents", Namespace: "ztoperator-system"},
+		Data: map[string]string{
+			"invalid.yaml": "wellKnownURI: [",
+		},
+	})
+	uri := "https://idp.example.com/.well-known/openid-configuration"
+
+	if _, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger()); err == nil {
+		t.Fatal("expected error from the fallback resolver, got nil")
+	}
+	if len(next.uris) != 1 || next.uris[0] != uri {
+		t.Fatalf("expected fallback resolver to be called with %s, got: %v", uri, next.uris)
+	}
+}
+
+func TestStaticDiscoveryDocumentResolver_FallsBackWhenConfigMapIsMissing(t *testing.T) {
+	t.Parallel()
+
+	next := &recordingDiscoveryDocumentResolver{}
+	resolver := newTestStaticResolver(t, next)
+	uri := "https://idp.example.com/.well-known/openid-configuration"
+
+	if _, err := resolver.GetOAuthDiscoveryDocument(uri, testLogger()); err == nil {
+		t.Fatal("expected error from the fallback resolver, got nil")
+	}
+	if len(next.uris) != 1 {
+		t.Fatalf("expected fallback resolver to be called once, got: %v", next.uris)
+	}
+}
+
+func TestParseStaticDiscoveryDocumentsConfigMap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		configMap string
+		want      *types.NamespacedName
+		wantErr   bool
+	}{
+		{name: "disabled", configMap: "", want: nil},
+		{
+			name:      "namespaced name",
+			configMap: "auth/static-discovery-documents",
+			want:      &types.NamespacedName{Namespace: "auth", Name: "static-discovery-documents"},
+		},
+		{name: "missing namespace", configMap: "static-discovery-documents", wantErr: true},
+		{name: "empty name", configMap: "auth/", wantErr: true},
+		{name: "too many segments", configMap: "auth/static/discovery-documents", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseStaticDiscoveryDocumentsConfigMap(tt.configMap)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error for %q, got nil", tt.configMap)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error for %q, got: %v", tt.configMap, err)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func newTestStaticResolver(
+	t *testing.T,
+	next DiscoveryDocumentResolver,
+	objects ...client.Object,
+) *StaticDiscoveryDocumentResolver {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add corev1 to scheme: %v", err)
+	}
+	return &StaticDiscoveryDocumentResolver{
+		reader:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		configMap: types.NamespacedName{Name: "static-discovery-documents", Namespace: "ztoperator-system"},
+		next:      next,
+	}
+}
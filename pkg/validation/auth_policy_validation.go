@@ -0,0 +1,37 @@
+package validation
+
+import "github.com/kartverket/ztoperator/api/v1alpha1"
+
+// AuthPolicyValidation is a validation of an AuthPolicy which requires no lookups in the cluster.
+type AuthPolicyValidation struct {
+	Description string
+	Validate    func(authPolicy v1alpha1.AuthPolicy) error
+}
+
+// AuthPolicyValidations are run by both the AuthPolicy controller and the AuthPolicy validating webhook, in order.
+var AuthPolicyValidations = []AuthPolicyValidation{
+	{
+		Description: "paths",
+		Validate:    func(authPolicy v1alpha1.AuthPolicy) error { return ValidatePaths(authPolicy.GetPaths()) },
+	},
+	{
+		Description: "identity provider references",
+		Validate:    ValidateIdentityProviderReferences,
+	},
+	{
+		Description: "condition groups",
+		Validate:    ValidateConditionGroups,
+	},
+	{
+		Description: "auto-login paths",
+		Validate:    ValidateAutoLoginPaths,
+	},
+	{
+		Description: "output claim to headers",
+		Validate:    ValidateOutputClaimToHeaders,
+	},
+	{
+		Description: "reserved headers",
+		Validate:    ValidateReservedHeaders,
+	},
+}
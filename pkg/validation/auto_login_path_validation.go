@@ -0,0 +1,98 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+)
+
+// ValidateAutoLoginPaths checks that none of the login, redirect and logout paths of auto-login is matched by an
+// ignore auth rule, as the OAuth2 filter lets ignored requests through without handling them.
+// Assumes the paths of the ignore auth rules have already been validated with ValidatePaths.
+func ValidateAutoLoginPaths(authPolicy v1alpha1.AuthPolicy) error {
+	if authPolicy.Spec.AutoLogin == nil || !authPolicy.Spec.AutoLogin.Enabled {
+		return nil
+	}
+
+	autoLoginConfig := state.AutoLoginConfig{}
+	autoLoginConfig.SetSaneDefaults(*authPolicy.Spec.AutoLogin)
+	autoLoginPaths := map[string]string{
+		"redirectPath": autoLoginConfig.RedirectPath,
+		"logoutPath":   autoLoginConfig.LogoutPath,
+	}
+	if authPolicy.Spec.AutoLogin.LoginPath != nil {
+		autoLoginPaths["loginPath"] = *authPolicy.Spec.AutoLogin.LoginPath
+	}
+
+	for _, ignoreAuthRule := range authPolicy.GetIgnoreAuthRequestMatchers() {
+		for _, ignoredPath := range ignoreAuthRule.Paths {
+			pattern := toPathRegexp(ignoredPath)
+			for _, field := range []string{"loginPath", "redirectPath", "logoutPath"} {
+				autoLoginPath, ok := autoLoginPaths[field]
+				if ok && pattern.MatchString(autoLoginPath) {
+					return fmt.Errorf(
+						"autoLogin %s %s collides with ignoreAuthRules path %s; auto-login would not be handled",
+						field,
+						autoLoginPath,
+						ignoredPath,
+					)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// toPathRegexp converts a validated request matcher path into an anchored regular expression, following the
+// semantics of the generated AuthorizationPolicies: {*} matches a single path segment, while {**} and a trailing *
+// match any remaining path.
+func toPathRegexp(path string) *regexp.Regexp {
+	var builder strings.Builder
+	builder.WriteString("^")
+	if kind, _ := classifyPath(path); kind == pathKindLegacyStar {
+		builder.WriteString(regexp.QuoteMeta(strings.TrimSuffix(path, "*")))
+		builder.WriteString(".*")
+	} else {
+		rest := path
+		for rest != "" {
+			switch {
+			case strings.HasPrefix(rest, matchAnyTemplate):
+				builder.WriteString(".*")
+				rest = strings.TrimPrefix(rest, matchAnyTemplate)
+			case strings.HasPrefix(rest, matchOneTemplate):
+				builder.WriteString("[^/]+")
+				rest = strings.TrimPrefix(rest, matchOneTemplate)
+			default:
+				next := strings.Index(rest, "{")
+				if next <= 0 {
+					next = len(rest)
+				}
+				builder.WriteString(regexp.QuoteMeta(rest[:next]))
+				rest = rest[next:]
+			}
+		}
+	}
+	builder.WriteString("$")
+	return regexp.MustCompile(builder.String())
+}
+
+// ValidateAutoLoginGateways checks that the Gateways targeted by an AuthPolicy with auto-login enabled, given by
+// gateways, are not waypoints, and serve HTTP on at least one listener. Waypoints handle requests between workloads in
+// the mesh rather than from browsers, and the OAuth2 filter is only inserted on listeners serving HTTP.
+func ValidateAutoLoginGateways(authPolicy v1alpha1.AuthPolicy, gateways []state.Gateway) error {
+	if authPolicy.Spec.AutoLogin == nil || !authPolicy.Spec.AutoLogin.Enabled {
+		return nil
+	}
+	for _, gateway := range gateways {
+		if gateway.Waypoint {
+			return fmt.Errorf("autoLogin is not supported for waypoint %s; target an ingress gateway instead", gateway.Name)
+		}
+		if len(gateway.Listeners) == 0 {
+			return fmt.Errorf("autoLogin requires Gateway %s to have a listener with protocol HTTP or HTTPS", gateway.Name)
+		}
+	}
+	return nil
+}
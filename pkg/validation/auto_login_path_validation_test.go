@@ -0,0 +1,142 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/state"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func authPolicyWithAutoLoginAndIgnoredPaths(autoLogin *v1alpha1.AutoLogin, ignoredPaths ...string) v1alpha1.AuthPolicy {
+	return v1alpha1.AuthPolicy{
+		Spec: v1alpha1.AuthPolicySpec{
+			AutoLogin:       autoLogin,
+			IgnoreAuthRules: &[]v1alpha1.RequestMatcher{{Paths: ignoredPaths}},
+		},
+	}
+}
+
+func TestValidateAutoLoginPaths(t *testing.T) {
+	loginPath := &v1alpha1.AutoLogin{Enabled: true, LoginPath: helperfunctions.Ptr("/login")}
+
+	tests := []struct {
+		name         string
+		authPolicy   v1alpha1.AuthPolicy
+		wantErrMatch string
+	}{
+		{
+			name:       "auto-login disabled",
+			authPolicy: authPolicyWithAutoLoginAndIgnoredPaths(&v1alpha1.AutoLogin{Enabled: false}, "/*"),
+		},
+		{
+			name:       "ignored paths not colliding",
+			authPolicy: authPolicyWithAutoLoginAndIgnoredPaths(loginPath, "/", "/favicon.*", "/assets/*", "/api/{*}"),
+		},
+		{
+			name:         "ignored path equal to login path",
+			authPolicy:   authPolicyWithAutoLoginAndIgnoredPaths(loginPath, "/login"),
+			wantErrMatch: "autoLogin loginPath /login collides with ignoreAuthRules path /login",
+		},
+		{
+			name:         "legacy wildcard matching default redirect path",
+			authPolicy:   authPolicyWithAutoLoginAndIgnoredPaths(loginPath, "/oauth2/*"),
+			wantErrMatch: "autoLogin redirectPath /oauth2/callback collides with ignoreAuthRules path /oauth2/*",
+		},
+		{
+			name: "single segment template matching custom redirect path",
+			authPolicy: authPolicyWithAutoLoginAndIgnoredPaths(
+				&v1alpha1.AutoLogin{Enabled: true, RedirectPath: helperfunctions.Ptr("/auth/callback")},
+				"/auth/{*}",
+			),
+			wantErrMatch: "autoLogin redirectPath /auth/callback collides with ignoreAuthRules path /auth/{*}",
+		},
+		{
+			name:         "match any template matching default redirect path",
+			authPolicy:   authPolicyWithAutoLoginAndIgnoredPaths(&v1alpha1.AutoLogin{Enabled: true}, "/{**}"),
+			wantErrMatch: "autoLogin redirectPath /oauth2/callback collides with ignoreAuthRules path /{**}",
+		},
+		{
+			name:         "suffix match any template matching default logout path",
+			authPolicy:   authPolicyWithAutoLoginAndIgnoredPaths(&v1alpha1.AutoLogin{Enabled: true}, "/log{**}"),
+			wantErrMatch: "autoLogin logoutPath /logout collides with ignoreAuthRules path /log{**}",
+		},
+		{
+			name: "single segment template not matching nested paths",
+			authPolicy: authPolicyWithAutoLoginAndIgnoredPaths(
+				&v1alpha1.AutoLogin{
+					Enabled:      true,
+					RedirectPath: helperfunctions.Ptr("/auth/callback"),
+					LogoutPath:   helperfunctions.Ptr("/auth/logout"),
+				},
+				"/{*}",
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateAutoLoginPaths(tt.authPolicy)
+			if tt.wantErrMatch == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErrMatch)
+		})
+	}
+}
+
+func TestValidateAutoLoginGateways(t *testing.T) {
+	autoLogin := v1alpha1.AuthPolicy{Spec: v1alpha1.AuthPolicySpec{AutoLogin: &v1alpha1.AutoLogin{Enabled: true}}}
+	ingressGateway := state.Gateway{Name: "ingress", Listeners: []state.GatewayListener{{Port: 80}}}
+
+	tests := []struct {
+		name         string
+		authPolicy   v1alpha1.AuthPolicy
+		gateways     []state.Gateway
+		wantErrMatch string
+	}{
+		{
+			name:       "auto-login disabled",
+			authPolicy: v1alpha1.AuthPolicy{},
+			gateways:   []state.Gateway{{Name: "waypoint", Waypoint: true}},
+		},
+		{
+			name:       "selector",
+			authPolicy: autoLogin,
+		},
+		{
+			name:       "ingress gateway serving HTTP",
+			authPolicy: autoLogin,
+			gateways:   []state.Gateway{ingressGateway},
+		},
+		{
+			name:         "waypoint",
+			authPolicy:   autoLogin,
+			gateways:     []state.Gateway{ingressGateway, {Name: "waypoint", Waypoint: true}},
+			wantErrMatch: "autoLogin is not supported for waypoint waypoint",
+		},
+		{
+			name:         "gateway without listeners serving HTTP",
+			authPolicy:   autoLogin,
+			gateways:     []state.Gateway{{Name: "passthrough"}},
+			wantErrMatch: "autoLogin requires Gateway passthrough to have a listener with protocol HTTP or HTTPS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateAutoLoginGateways(tt.authPolicy, tt.gateways)
+			if tt.wantErrMatch == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErrMatch)
+		})
+	}
+}
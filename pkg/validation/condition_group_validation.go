@@ -0,0 +1,114 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+)
+
+const (
+	// MaxConditionGroupExpansion limits the number of deny rules a single list of condition groups may expand to.
+	MaxConditionGroupExpansion = 128
+	// MaxConditionGroups and MaxConditionsPerGroup mirror the limits of the CRD,
+	// which may be exceeded when merging ClusterAuthPolicies into an AuthPolicy.
+	MaxConditionGroups    = 8
+	MaxConditionsPerGroup = 8
+)
+
+// ValidateConditionGroups checks that the condition groups of baseline auth and every auth rule can be expanded
+// into a reasonable number of deny rules. Denying requests satisfying none of the groups requires one deny rule
+// per combination of a negated condition from every group. Likewise, sources of an auth rule multiply its deny rules
+// by the number of combinations of a negated field from every source.
+func ValidateConditionGroups(authPolicy v1alpha1.AuthPolicy) error {
+	if authPolicy.Spec.BaselineAuth != nil {
+		if _, err := validateConditionGroupExpansion(authPolicy.Spec.BaselineAuth.AnyOf); err != nil {
+			return fmt.Errorf("invalid baselineAuth.anyOf: %w", err)
+		}
+	}
+	if authPolicy.Spec.AuthRules != nil {
+		for _, authRule := range *authPolicy.Spec.AuthRules {
+			expansion := 1
+			if authRule.AnyOf != nil {
+				var err error
+				expansion, err = validateConditionGroupExpansion(*authRule.AnyOf)
+				if err != nil {
+					return fmt.Errorf("invalid anyOf in auth rule for paths %v: %w", authRule.Paths, err)
+				}
+			}
+			if authRule.From != nil && expansion*countSourceExpansion(*authRule.From) > MaxConditionGroupExpansion {
+				return fmt.Errorf(
+					"invalid from in auth rule for paths %v: anyOf and from expand to more than %d deny rules; "+
+						"reduce the number of groups or fields per source",
+					authRule.Paths,
+					MaxConditionGroupExpansion,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+func validateConditionGroupExpansion(conditionGroups []v1alpha1.ConditionGroup) (int, error) {
+	if len(conditionGroups) > MaxConditionGroups {
+		return 0, fmt.Errorf(
+			"found %d condition groups; at most %d are allowed",
+			len(conditionGroups),
+			MaxConditionGroups,
+		)
+	}
+	expansion := 1
+	for _, conditionGroup := range conditionGroups {
+		if len(conditionGroup.AllOf) > MaxConditionsPerGroup {
+			return 0, fmt.Errorf(
+				"found condition group with %d conditions; at most %d are allowed",
+				len(conditionGroup.AllOf),
+				MaxConditionsPerGroup,
+			)
+		}
+		operators := 0
+		for _, condition := range conditionGroup.AllOf {
+			operators += countConditionOperators(condition)
+		}
+		expansion *= operators
+		if expansion > MaxConditionGroupExpansion {
+			return 0, fmt.Errorf(
+				"condition groups expand to more than %d deny rules; reduce the number of groups or conditions per group",
+				MaxConditionGroupExpansion,
+			)
+		}
+	}
+	return expansion, nil
+}
+
+// Principals and service accounts are both matched against the principal of the peer, and thus count as one field.
+func countSourceExpansion(sources []v1alpha1.Source) int {
+	expansion := 1
+	for _, source := range sources {
+		fields := 0
+		if len(source.Principals) > 0 || len(source.ServiceAccounts) > 0 {
+			fields++
+		}
+		if len(source.Namespaces) > 0 {
+			fields++
+		}
+		if len(source.IPBlocks) > 0 {
+			fields++
+		}
+		expansion *= max(fields, 1)
+	}
+	return expansion
+}
+
+func countConditionOperators(condition v1alpha1.Condition) int {
+	operators := 0
+	if len(condition.Values) > 0 {
+		operators++
+	}
+	if len(condition.NotValues) > 0 {
+		operators++
+	}
+	if condition.Present != nil {
+		operators++
+	}
+	return operators
+}
@@ -0,0 +1,131 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func conditionGroups(groups int, conditionsPerGroup int) []v1alpha1.ConditionGroup {
+	result := make([]v1alpha1.ConditionGroup, 0, groups)
+	for range groups {
+		conditions := make([]v1alpha1.Condition, 0, conditionsPerGroup)
+		for range conditionsPerGroup {
+			conditions = append(conditions, v1alpha1.Condition{Claim: "role", Values: []string{"admin"}})
+		}
+		result = append(result, v1alpha1.ConditionGroup{AllOf: conditions})
+	}
+	return result
+}
+
+func TestValidateConditionGroups(t *testing.T) {
+	tests := []struct {
+		name         string
+		authPolicy   v1alpha1.AuthPolicy
+		wantErrMatch string
+	}{
+		{
+			name:       "no condition groups",
+			authPolicy: v1alpha1.AuthPolicy{},
+		},
+		{
+			name: "baseline auth condition groups within limit",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					BaselineAuth: &v1alpha1.BaselineAuth{AnyOf: conditionGroups(7, 2)},
+				},
+			},
+		},
+		{
+			name: "baseline auth condition groups exceeding limit",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					BaselineAuth: &v1alpha1.BaselineAuth{AnyOf: conditionGroups(8, 2)},
+				},
+			},
+			wantErrMatch: "invalid baselineAuth.anyOf",
+		},
+		{
+			name: "baseline auth with too many condition groups",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					BaselineAuth: &v1alpha1.BaselineAuth{AnyOf: conditionGroups(9, 1)},
+				},
+			},
+			wantErrMatch: "found 9 condition groups",
+		},
+		{
+			name: "baseline auth with too many conditions in a group",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					BaselineAuth: &v1alpha1.BaselineAuth{AnyOf: conditionGroups(1, 9)},
+				},
+			},
+			wantErrMatch: "found condition group with 9 conditions",
+		},
+		{
+			name: "auth rule condition groups exceeding limit",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					AuthRules: &[]v1alpha1.RequestAuthRule{
+						{
+							RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/api"}},
+							AnyOf:          func() *[]v1alpha1.ConditionGroup { g := conditionGroups(3, 8); return &g }(),
+						},
+					},
+				},
+			},
+			wantErrMatch: "invalid anyOf in auth rule for paths [/api]",
+		},
+		{
+			name: "auth rule sources within limit",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					AuthRules: &[]v1alpha1.RequestAuthRule{
+						{
+							RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/internal"}},
+							AnyOf:          func() *[]v1alpha1.ConditionGroup { g := conditionGroups(2, 4); return &g }(),
+							From: &[]v1alpha1.Source{
+								{Namespaces: []string{"batch"}, IPBlocks: []string{"10.0.0.0/16"}},
+								{ServiceAccounts: []string{"batch/report-job"}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "auth rule condition groups and sources exceeding limit",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					AuthRules: &[]v1alpha1.RequestAuthRule{
+						{
+							RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/internal"}},
+							AnyOf:          func() *[]v1alpha1.ConditionGroup { g := conditionGroups(2, 8); return &g }(),
+							From: &[]v1alpha1.Source{
+								{Namespaces: []string{"batch"}, IPBlocks: []string{"10.0.0.0/16"}},
+								{Principals: []string{"cluster.local/ns/batch/sa/report-job"}, Namespaces: []string{"batch"}},
+							},
+						},
+					},
+				},
+			},
+			wantErrMatch: "invalid from in auth rule for paths [/internal]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateConditionGroups(tt.authPolicy)
+			if tt.wantErrMatch == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErrMatch)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/luascript"
+)
+
+// ValidateOutputClaimToHeaders checks that every header is written to at most once per identity provider.
+// Header names are compared case-insensitively.
+func ValidateOutputClaimToHeaders(authPolicy v1alpha1.AuthPolicy) error {
+	if err := validateUniqueClaimToHeaders("outputClaimToHeaders", authPolicy.Spec.OutputClaimToHeaders); err != nil {
+		return err
+	}
+	for _, identityProvider := range authPolicy.Spec.IdentityProviders {
+		field := fmt.Sprintf("identityProviders[%s].outputClaimToHeaders", identityProvider.Name)
+		if err := validateUniqueClaimToHeaders(field, identityProvider.OutputClaimToHeaders); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateUniqueClaimToHeaders(field string, claimToHeaders *[]v1alpha1.ClaimToHeader) error {
+	if claimToHeaders == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, claimToHeader := range *claimToHeaders {
+		header := strings.ToLower(claimToHeader.Header)
+		if seen[header] {
+			return fmt.Errorf("%s contains duplicate header %s", field, claimToHeader.Header)
+		}
+		seen[header] = true
+	}
+	return nil
+}
+
+// ValidateReservedHeaders checks that no header written by outputClaimToHeaders, read by fromHeaders or matched on by
+// an auth rule or an ignore auth rule shadows the headers used internally by the generated EnvoyFilters, which are
+// set to signal the OAuth2 filter and all start with luascript.ReservedHeaderPrefix.
+func ValidateReservedHeaders(authPolicy v1alpha1.AuthPolicy) error {
+	type headerUsage struct {
+		field  string
+		header string
+	}
+	var usages []headerUsage
+	if authPolicy.Spec.OutputClaimToHeaders != nil {
+		for _, claimToHeader := range *authPolicy.Spec.OutputClaimToHeaders {
+			usages = append(usages, headerUsage{"outputClaimToHeaders", claimToHeader.Header})
+		}
+	}
+	for _, identityProvider := range authPolicy.Spec.IdentityProviders {
+		if identityProvider.OutputClaimToHeaders == nil {
+			continue
+		}
+		field := fmt.Sprintf("identityProviders[%s].outputClaimToHeaders", identityProvider.Name)
+		for _, claimToHeader := range *identityProvider.OutputClaimToHeaders {
+			usages = append(usages, headerUsage{field, claimToHeader.Header})
+		}
+	}
+	for _, fromHeader := range authPolicy.Spec.FromHeaders {
+		usages = append(usages, headerUsage{"fromHeaders", fromHeader.Name})
+	}
+	for _, requestMatcher := range v1alpha1.GetRequestMatchers(authPolicy.Spec.AuthRules) {
+		for _, headerMatcher := range requestMatcher.Headers {
+			usages = append(usages, headerUsage{"authRules", headerMatcher.Name})
+		}
+	}
+	for _, requestMatcher := range authPolicy.GetIgnoreAuthRequestMatchers() {
+		for _, headerMatcher := range requestMatcher.Headers {
+			usages = append(usages, headerUsage{"ignoreAuthRules", headerMatcher.Name})
+		}
+	}
+
+	for _, usage := range usages {
+		if strings.HasPrefix(strings.ToLower(usage.header), luascript.ReservedHeaderPrefix) {
+			return fmt.Errorf(
+				"%s uses header %s, which is reserved for internal use by ztoperator; must not start with %s",
+				usage.field,
+				usage.header,
+				luascript.ReservedHeaderPrefix,
+			)
+		}
+	}
+	return nil
+}
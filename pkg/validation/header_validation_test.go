@@ -0,0 +1,163 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateOutputClaimToHeaders(t *testing.T) {
+	tests := []struct {
+		name         string
+		authPolicy   v1alpha1.AuthPolicy
+		wantErrMatch string
+	}{
+		{
+			name:       "no output claim to headers",
+			authPolicy: v1alpha1.AuthPolicy{},
+		},
+		{
+			name: "same header for different identity providers",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					OutputClaimToHeaders: &[]v1alpha1.ClaimToHeader{
+						{Header: "x-sub", Claim: "sub"},
+						{Header: "x-aud", Claim: "aud"},
+					},
+					IdentityProviders: []v1alpha1.TrustedIdentityProvider{
+						{
+							Name:                 "maskinporten",
+							OutputClaimToHeaders: &[]v1alpha1.ClaimToHeader{{Header: "x-sub", Claim: "consumer"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "duplicate header differing in case",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					OutputClaimToHeaders: &[]v1alpha1.ClaimToHeader{
+						{Header: "x-sub", Claim: "sub"},
+						{Header: "X-Sub", Claim: "oid"},
+					},
+				},
+			},
+			wantErrMatch: "outputClaimToHeaders contains duplicate header X-Sub",
+		},
+		{
+			name: "duplicate header for identity provider",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					IdentityProviders: []v1alpha1.TrustedIdentityProvider{
+						{
+							Name: "maskinporten",
+							OutputClaimToHeaders: &[]v1alpha1.ClaimToHeader{
+								{Header: "x-consumer", Claim: "consumer"},
+								{Header: "x-consumer", Claim: "client_id"},
+							},
+						},
+					},
+				},
+			},
+			wantErrMatch: "identityProviders[maskinporten].outputClaimToHeaders contains duplicate header x-consumer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateOutputClaimToHeaders(tt.authPolicy)
+			if tt.wantErrMatch == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErrMatch)
+		})
+	}
+}
+
+func TestValidateReservedHeaders(t *testing.T) {
+	headerMatcher := []v1alpha1.HeaderMatcher{{Name: "X-Ztoperator-Deny-Redirect-Abc", Values: []string{"true"}}}
+
+	tests := []struct {
+		name         string
+		authPolicy   v1alpha1.AuthPolicy
+		wantErrMatch string
+	}{
+		{
+			name: "no reserved headers",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					OutputClaimToHeaders: &[]v1alpha1.ClaimToHeader{{Header: "x-sub", Claim: "sub"}},
+					FromHeaders:          []v1alpha1.JWTHeader{{Name: "x-api-token"}},
+				},
+			},
+		},
+		{
+			name: "output claim to reserved header",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					OutputClaimToHeaders: &[]v1alpha1.ClaimToHeader{{Header: "x-ztoperator-bypass-login-abc", Claim: "sub"}},
+				},
+			},
+			wantErrMatch: "outputClaimToHeaders uses header x-ztoperator-bypass-login-abc",
+		},
+		{
+			name: "output claim of identity provider to reserved header",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					IdentityProviders: []v1alpha1.TrustedIdentityProvider{
+						{
+							Name:                 "maskinporten",
+							OutputClaimToHeaders: &[]v1alpha1.ClaimToHeader{{Header: "X-Ztoperator-Deny-Redirect-Abc", Claim: "sub"}},
+						},
+					},
+				},
+			},
+			wantErrMatch: "identityProviders[maskinporten].outputClaimToHeaders uses header X-Ztoperator-Deny-Redirect-Abc",
+		},
+		{
+			name: "token read from reserved header",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{FromHeaders: []v1alpha1.JWTHeader{{Name: "X-Ztoperator-Bypass-Login-Abc"}}},
+			},
+			wantErrMatch: "fromHeaders uses header X-Ztoperator-Bypass-Login-Abc",
+		},
+		{
+			name: "auth rule matching on reserved header",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					AuthRules: &[]v1alpha1.RequestAuthRule{
+						{RequestMatcher: v1alpha1.RequestMatcher{Paths: []string{"/api"}, Headers: headerMatcher}},
+					},
+				},
+			},
+			wantErrMatch: "authRules uses header X-Ztoperator-Deny-Redirect-Abc",
+		},
+		{
+			name: "ignore auth rule matching on reserved header",
+			authPolicy: v1alpha1.AuthPolicy{
+				Spec: v1alpha1.AuthPolicySpec{
+					IgnoreAuthRules: &[]v1alpha1.RequestMatcher{{Paths: []string{"/public"}, Headers: headerMatcher}},
+				},
+			},
+			wantErrMatch: "ignoreAuthRules uses header X-Ztoperator-Deny-Redirect-Abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateReservedHeaders(tt.authPolicy)
+			if tt.wantErrMatch == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErrMatch)
+		})
+	}
+}
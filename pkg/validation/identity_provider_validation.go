@@ -0,0 +1,96 @@
+package validation
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+)
+
+// ValidateIdentityProviderReferences checks that every identity provider referenced by an auth rule
+// or an egress destination is trusted by the AuthPolicy.
+func ValidateIdentityProviderReferences(authPolicy v1alpha1.AuthPolicy) error {
+	identityProviderNames := authPolicy.GetIdentityProviderNames()
+	if authPolicy.IsEgressEnabled() {
+		for _, destination := range authPolicy.Spec.Egress.Destinations {
+			identityProviderName := v1alpha1.DefaultIdentityProviderName
+			if destination.IdentityProvider != nil {
+				identityProviderName = *destination.IdentityProvider
+			}
+			if !slices.Contains(identityProviderNames, identityProviderName) {
+				return fmt.Errorf(
+					"egress destination %s references unknown identity provider %s; must be one of %v",
+					destination.Name,
+					identityProviderName,
+					identityProviderNames,
+				)
+			}
+		}
+	}
+	if authPolicy.Spec.AuthRules == nil {
+		return nil
+	}
+	for _, authRule := range *authPolicy.Spec.AuthRules {
+		for _, identityProviderName := range authRule.IdentityProviders {
+			if !slices.Contains(identityProviderNames, identityProviderName) {
+				return fmt.Errorf(
+					"auth rule for paths %v references unknown identity provider %s; must be one of %v",
+					authRule.Paths,
+					identityProviderName,
+					identityProviderNames,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateIdentityProviderRequirements checks that the AuthPolicy sets every field required by the IdentityProviders
+// it references, given by identityProviderRefs.
+func ValidateIdentityProviderRequirements(
+	authPolicy v1alpha1.AuthPolicy,
+	identityProviderRefs map[string]v1alpha1.IdentityProvider,
+) error {
+	if authPolicy.Spec.IdentityProviderRef != nil {
+		if err := validateRequiredFields(
+			identityProviderRefs[*authPolicy.Spec.IdentityProviderRef],
+			len(authPolicy.Spec.AllowedAudiences) > 0,
+			authPolicy.Spec.AcceptedResources != nil && len(*authPolicy.Spec.AcceptedResources) > 0,
+		); err != nil {
+			return err
+		}
+	}
+	for _, identityProvider := range authPolicy.Spec.IdentityProviders {
+		if identityProvider.IdentityProviderRef == nil {
+			continue
+		}
+		if err := validateRequiredFields(
+			identityProviderRefs[*identityProvider.IdentityProviderRef],
+			len(identityProvider.AllowedAudiences) > 0,
+			identityProvider.AcceptedResources != nil && len(*identityProvider.AcceptedResources) > 0,
+		); err != nil {
+			return fmt.Errorf("identity provider %s: %w", identityProvider.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateRequiredFields(
+	identityProvider v1alpha1.IdentityProvider,
+	hasAllowedAudiences bool,
+	hasAcceptedResources bool,
+) error {
+	for _, requiredField := range identityProvider.Spec.RequiredFields {
+		isSet := true
+		switch requiredField {
+		case v1alpha1.RequiredFieldAllowedAudiences:
+			isSet = hasAllowedAudiences
+		case v1alpha1.RequiredFieldAcceptedResources:
+			isSet = hasAcceptedResources
+		}
+		if !isSet {
+			return fmt.Errorf("%s must be non-empty when using IdentityProvider %s", requiredField, identityProvider.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/pkg/helperfunctions"
+	"github.com/kartverket/ztoperator/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func authPolicyWithIdentityProviders(wellKnownURI string, authRuleIdentityProviders []string) v1alpha1.AuthPolicy {
+	return v1alpha1.AuthPolicy{
+		Spec: v1alpha1.AuthPolicySpec{
+			WellKnownURI: wellKnownURI,
+			IdentityProviders: []v1alpha1.TrustedIdentityProvider{
+				{Name: "maskinporten", WellKnownURI: "https://maskinporten.example.com/.well-known/oauth-authorization-server"},
+			},
+			AuthRules: &[]v1alpha1.RequestAuthRule{
+				{
+					RequestMatcher:    v1alpha1.RequestMatcher{Paths: []string{"/api"}},
+					IdentityProviders: authRuleIdentityProviders,
+				},
+			},
+		},
+	}
+}
+
+func authPolicyWithEgressIdentityProvider(wellKnownURI string, identityProvider *string) v1alpha1.AuthPolicy {
+	authPolicy := authPolicyWithIdentityProviders(wellKnownURI, nil)
+	authPolicy.Spec.Egress = &v1alpha1.Egress{
+		Enabled: true,
+		Destinations: []v1alpha1.EgressDestination{
+			{
+				Name:             "api",
+				Hosts:            []string{"api.example.com"},
+				Scopes:           []string{"api.read"},
+				IdentityProvider: identityProvider,
+			},
+		},
+	}
+	return authPolicy
+}
+
+func TestValidateIdentityProviderReferences(t *testing.T) {
+	tests := []struct {
+		name         string
+		authPolicy   v1alpha1.AuthPolicy
+		wantErrMatch string
+	}{
+		{
+			name:       "no auth rules",
+			authPolicy: v1alpha1.AuthPolicy{},
+		},
+		{
+			name:       "auth rule without identity providers",
+			authPolicy: authPolicyWithIdentityProviders("https://idp.example.com/.well-known/openid-configuration", nil),
+		},
+		{
+			name: "auth rule referencing default and listed identity provider",
+			authPolicy: authPolicyWithIdentityProviders(
+				"https://idp.example.com/.well-known/openid-configuration",
+				[]string{"default", "maskinporten"},
+			),
+		},
+		{
+			name: "auth rule referencing unknown identity provider",
+			authPolicy: authPolicyWithIdentityProviders(
+				"https://idp.example.com/.well-known/openid-configuration",
+				[]string{"unknown"},
+			),
+			wantErrMatch: "unknown identity provider unknown",
+		},
+		{
+			name:         "auth rule referencing default without wellKnownURI",
+			authPolicy:   authPolicyWithIdentityProviders("", []string{"default"}),
+			wantErrMatch: "unknown identity provider default",
+		},
+		{
+			name: "egress destination referencing listed identity provider",
+			authPolicy: authPolicyWithEgressIdentityProvider(
+				"https://idp.example.com/.well-known/openid-configuration",
+				helperfunctions.Ptr("maskinporten"),
+			),
+		},
+		{
+			name:         "egress destination using default without wellKnownURI",
+			authPolicy:   authPolicyWithEgressIdentityProvider("", nil),
+			wantErrMatch: "egress destination api references unknown identity provider default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateIdentityProviderReferences(tt.authPolicy)
+			if tt.wantErrMatch == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErrMatch)
+		})
+	}
+}
+
+func TestValidateIdentityProviderRequirements(t *testing.T) {
+	identityProviderRefs := map[string]v1alpha1.IdentityProvider{
+		"idporten": {
+			ObjectMeta: metav1.ObjectMeta{Name: "idporten"},
+			Spec: v1alpha1.IdentityProviderSpec{
+				RequiredFields: []v1alpha1.IdentityProviderRequiredField{v1alpha1.RequiredFieldAcceptedResources},
+			},
+		},
+		"entra": {
+			ObjectMeta: metav1.ObjectMeta{Name: "entra"},
+			Spec: v1alpha1.IdentityProviderSpec{
+				RequiredFields: []v1alpha1.IdentityProviderRequiredField{v1alpha1.RequiredFieldAllowedAudiences},
+			},
+		},
+	}
+	allowedAudiences := []v1alpha1.AllowedAudience{{Value: helperfunctions.Ptr("client-id")}}
+	acceptedResources := &[]string{"https://api.example.com"}
+
+	tests := []struct {
+		name         string
+		spec         v1alpha1.AuthPolicySpec
+		wantErrMatch string
+	}{
+		{
+			name: "without identity provider references",
+			spec: v1alpha1.AuthPolicySpec{WellKnownURI: "https://idporten.no/.well-known/openid-configuration"},
+		},
+		{
+			name: "identityProviderRef with required field set",
+			spec: v1alpha1.AuthPolicySpec{
+				IdentityProviderRef: helperfunctions.Ptr("idporten"),
+				AcceptedResources:   acceptedResources,
+			},
+		},
+		{
+			name:         "identityProviderRef without required field",
+			spec:         v1alpha1.AuthPolicySpec{IdentityProviderRef: helperfunctions.Ptr("idporten")},
+			wantErrMatch: "acceptedResources must be non-empty when using IdentityProvider idporten",
+		},
+		{
+			name: "identityProviderRef with required field set to an empty list",
+			spec: v1alpha1.AuthPolicySpec{
+				IdentityProviderRef: helperfunctions.Ptr("idporten"),
+				AcceptedResources:   &[]string{},
+			},
+			wantErrMatch: "acceptedResources must be non-empty when using IdentityProvider idporten",
+		},
+		{
+			name: "identity provider with required field set",
+			spec: v1alpha1.AuthPolicySpec{
+				IdentityProviders: []v1alpha1.TrustedIdentityProvider{
+					{Name: "entra", IdentityProviderRef: helperfunctions.Ptr("entra"), AllowedAudiences: allowedAudiences},
+				},
+			},
+		},
+		{
+			name: "identity provider without required field",
+			spec: v1alpha1.AuthPolicySpec{
+				AllowedAudiences: allowedAudiences,
+				IdentityProviders: []v1alpha1.TrustedIdentityProvider{
+					{Name: "entra", IdentityProviderRef: helperfunctions.Ptr("entra")},
+				},
+			},
+			wantErrMatch: "identity provider entra: allowedAudiences must be non-empty when using IdentityProvider entra",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateIdentityProviderRequirements(v1alpha1.AuthPolicy{Spec: tt.spec}, identityProviderRefs)
+			if tt.wantErrMatch == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErrMatch)
+		})
+	}
+}
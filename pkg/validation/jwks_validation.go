@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kartverket/ztoperator/pkg/rest"
+)
+
+// supportedJWKSAlgorithms maps the signing algorithms supported by the JWT authentication filter of Envoy to the key
+// type they require. Symmetric algorithms are not supported, since the JWKS is not treated as a secret by the sidecar.
+var supportedJWKSAlgorithms = map[string]string{
+	"RS256": "RSA",
+	"RS384": "RSA",
+	"RS512": "RSA",
+	"PS256": "RSA",
+	"PS384": "RSA",
+	"PS512": "RSA",
+	"ES256": "EC",
+	"ES384": "EC",
+	"ES512": "EC",
+	"EdDSA": "OKP",
+}
+
+// ValidateJWKS checks that jwks is a JSON Web Key Set containing at least one key, and that every key has a supported
+// key type and signing algorithm along with the key material it requires.
+func ValidateJWKS(jwks string) error {
+	var keySet rest.JWKS
+	if err := json.Unmarshal([]byte(jwks), &keySet); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+	if len(keySet.Keys) == 0 {
+		return errors.New("JWKS must contain at least one key")
+	}
+	for i, key := range keySet.Keys {
+		if err := validateJWK(key); err != nil {
+			if key.KeyID != "" {
+				return fmt.Errorf("invalid key %s in JWKS: %w", key.KeyID, err)
+			}
+			return fmt.Errorf("invalid key at index %d in JWKS: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateJWK(key rest.JWK) error {
+	if key.Use != "" && key.Use != "sig" {
+		return fmt.Errorf("unsupported use %s; must be sig", key.Use)
+	}
+	if key.Algorithm != "" {
+		keyType, ok := supportedJWKSAlgorithms[key.Algorithm]
+		if !ok {
+			return fmt.Errorf("unsupported algorithm %s", key.Algorithm)
+		}
+		if key.KeyType != keyType {
+			return fmt.Errorf("algorithm %s requires key type %s, got %q", key.Algorithm, keyType, key.KeyType)
+		}
+	}
+	switch key.KeyType {
+	case "RSA":
+		if key.Modulus == "" || key.Exponent == "" {
+			return errors.New("RSA key must set n and e")
+		}
+	case "EC":
+		if key.Curve == "" || key.X == "" || key.Y == "" {
+			return errors.New("EC key must set crv, x and y")
+		}
+	case "OKP":
+		if key.Curve == "" || key.X == "" {
+			return errors.New("OKP key must set crv and x")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %q; must be one of RSA, EC or OKP", key.KeyType)
+	}
+	return nil
+}
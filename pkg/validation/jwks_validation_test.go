@@ -0,0 +1,74 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJWKS(t *testing.T) {
+	tests := []struct {
+		name         string
+		jwks         string
+		wantErrMatch string
+	}{
+		{
+			name: "RSA key with algorithm",
+			jwks: `{"keys":[{"kid":"rsa","kty":"RSA","alg":"RS256","use":"sig","n":"AQAB","e":"AQAB"}]}`,
+		},
+		{
+			name: "EC and OKP keys without algorithm",
+			jwks: `{"keys":[{"kty":"EC","crv":"P-256","x":"eA","y":"eQ"},{"kty":"OKP","crv":"Ed25519","x":"eA"}]}`,
+		},
+		{
+			name:         "not JSON",
+			jwks:         "keys: []",
+			wantErrMatch: "failed to parse JWKS",
+		},
+		{
+			name:         "no keys",
+			jwks:         `{"keys":[]}`,
+			wantErrMatch: "JWKS must contain at least one key",
+		},
+		{
+			name:         "symmetric algorithm",
+			jwks:         `{"keys":[{"kid":"hmac","kty":"oct","alg":"HS256","k":"c2VjcmV0"}]}`,
+			wantErrMatch: "invalid key hmac in JWKS: unsupported algorithm HS256",
+		},
+		{
+			name:         "algorithm does not match key type",
+			jwks:         `{"keys":[{"kty":"EC","alg":"RS256","crv":"P-256","x":"eA","y":"eQ"}]}`,
+			wantErrMatch: "invalid key at index 0 in JWKS: algorithm RS256 requires key type RSA",
+		},
+		{
+			name:         "encryption key",
+			jwks:         `{"keys":[{"kid":"enc","kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"}]}`,
+			wantErrMatch: "unsupported use enc",
+		},
+		{
+			name:         "missing key material",
+			jwks:         `{"keys":[{"kid":"rsa","kty":"RSA","alg":"RS256"}]}`,
+			wantErrMatch: "RSA key must set n and e",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 1. Arrange
+			jwks := tt.jwks
+
+			// 2. Act
+			err := validation.ValidateJWKS(jwks)
+
+			// 3. Assert
+			if tt.wantErrMatch == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErrMatch)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchPath reports whether the path of a request, without its query string, matches a validated request matcher
+// path, with the same semantics as the generated AuthorizationPolicies and Lua script:
+//   - {*} matches a single, non-empty path segment.
+//   - {**} matches the remainder of the path, including any '/'.
+//   - A trailing '*' (legacy syntax) matches any remainder of the path, and '*' alone matches any path.
+//   - Any other path matches only itself.
+func MatchPath(validatedPath string, requestPath string) bool {
+	kind, err := classifyPath(validatedPath)
+	if err != nil {
+		return false
+	}
+
+	switch kind {
+	case pathKindLegacyStar:
+		return strings.HasPrefix(requestPath, strings.TrimSuffix(validatedPath, "*"))
+	case pathKindTemplate:
+		return pathTemplateRegexp(validatedPath).MatchString(requestPath)
+	default:
+		return requestPath == validatedPath
+	}
+}
+
+func pathTemplateRegexp(validatedPath string) *regexp.Regexp {
+	var expression strings.Builder
+	expression.WriteString("^")
+	for i, part := range strings.Split(validatedPath, matchAnyTemplate) {
+		if i > 0 {
+			expression.WriteString(".*")
+		}
+		for j, literal := range strings.Split(part, matchOneTemplate) {
+			if j > 0 {
+				expression.WriteString("[^/]+")
+			}
+			expression.WriteString(regexp.QuoteMeta(literal))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.MustCompile(expression.String())
+}
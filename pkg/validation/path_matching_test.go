@@ -0,0 +1,38 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/kartverket/ztoperator/pkg/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		requestPath string
+		expected    bool
+	}{
+		{name: "plain path matches itself", path: "/api", requestPath: "/api", expected: true},
+		{name: "plain path does not match sub path", path: "/api", requestPath: "/api/items", expected: false},
+		{name: "plain path escapes regexp characters", path: "/v1.0", requestPath: "/v1x0", expected: false},
+		{name: "legacy star matches any path", path: "*", requestPath: "/anything/at/all", expected: true},
+		{name: "legacy trailing star matches prefix", path: "/api/*", requestPath: "/api/items/3", expected: true},
+		{name: "legacy trailing star requires prefix", path: "/api/*", requestPath: "/apis", expected: false},
+		{name: "{*} matches a single segment", path: "/api/{*}/items", requestPath: "/api/v1/items", expected: true},
+		{name: "{*} does not match several segments", path: "/api/{*}", requestPath: "/api/v1/items", expected: false},
+		{name: "{*} does not match an empty segment", path: "/api/{*}/items", requestPath: "/api//items", expected: false},
+		{name: "{**} matches several segments", path: "/api/{**}", requestPath: "/api/v1/items/3", expected: true},
+		{name: "{**} does not match the parent", path: "/api/{**}", requestPath: "/api", expected: false},
+		{name: "{**} suffix matches prefix", path: "/api{**}", requestPath: "/apis/items", expected: true},
+		{name: "{*} and {**} combined", path: "/api/{*}/items/{**}", requestPath: "/api/v1/items/3/x", expected: true},
+		{name: "{*} and {**} mismatch", path: "/api/{*}/items/{**}", requestPath: "/api/v1/other/3", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, validation.MatchPath(tt.path, tt.requestPath))
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/kartverket/ztoperator/api/v1alpha1"
+	"github.com/kartverket/ztoperator/internal/names"
+	"github.com/kartverket/ztoperator/pkg/resourcegenerators/envoyfilter/configpatch"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// EnvoySecretVolumeName is the name of the istio-proxy volume injected by MutatePodAnnotations and
+	// MutateIstioProxyContainer.
+	EnvoySecretVolumeName = "ztoperator-envoy-secret"
+
+	// IstioProxyContainerName is the name of the sidecar injected by Istio, either as a container or, for native
+	// sidecars, as an init container.
+	IstioProxyContainerName = "istio-proxy"
+)
+
+// MutatePodAnnotations merges the istio-proxy user volume and volume mount for the Secret generated for the AuthPolicy
+// into the annotations of the pod, preserving any other user volumes and volume mounts.
+// Returns whether the annotations were changed.
+func MutatePodAnnotations(pod *corev1.Pod, authPolicy v1alpha1.AuthPolicy) (bool, error) {
+	if !authPolicy.UsesOAuthCredentials() {
+		return false, nil
+	}
+
+	volumes, err := unmarshalIstioUserAnnotation(pod.Annotations, IstioUserVolumeAnnotation)
+	if err != nil {
+		return false, err
+	}
+	volumeMounts, err := unmarshalIstioUserAnnotation(pod.Annotations, istioUserVolumeMountAnnotation)
+	if err != nil {
+		return false, err
+	}
+
+	// Replace any volume using the injected name, and any volume mount shadowing the credentials directory
+	var typedVolumes []istioUserVolume
+	var mergedVolumes []json.RawMessage
+	for _, volume := range volumes {
+		var v istioUserVolume
+		if unmarshalErr := json.Unmarshal(volume, &v); unmarshalErr != nil {
+			return false, invalidIstioUserAnnotationError(IstioUserVolumeAnnotation)
+		}
+		typedVolumes = append(typedVolumes, v)
+		if v.Name != EnvoySecretVolumeName {
+			mergedVolumes = append(mergedVolumes, volume)
+		}
+	}
+	var credentialsVolumeMounts []istioUserVolumeMount
+	var mergedVolumeMounts []json.RawMessage
+	for _, volumeMount := range volumeMounts {
+		var m istioUserVolumeMount
+		if unmarshalErr := json.Unmarshal(volumeMount, &m); unmarshalErr != nil {
+			return false, invalidIstioUserAnnotationError(istioUserVolumeMountAnnotation)
+		}
+		if m.MountPath == configpatch.IstioCredentialsDirectory {
+			credentialsVolumeMounts = append(credentialsVolumeMounts, m)
+		}
+		if m.Name != EnvoySecretVolumeName && m.MountPath != configpatch.IstioCredentialsDirectory {
+			mergedVolumeMounts = append(mergedVolumeMounts, volumeMount)
+		}
+	}
+
+	envoySecretName := names.EnvoySecret(authPolicy.Name)
+	if validateSecretVolumeMount(credentialsVolumeMounts, typedVolumes, envoySecretName) {
+		// The Secret is already mounted, either by the user or by a previous invocation of the webhook
+		return false, nil
+	}
+
+	envoySecretVolume, err := json.Marshal(istioUserVolume{
+		Name:   EnvoySecretVolumeName,
+		Secret: &istioUserVolumeSecret{SecretName: envoySecretName},
+	})
+	if err != nil {
+		return false, err
+	}
+	envoySecretVolumeMount, err := json.Marshal(istioUserVolumeMount{
+		Name:      EnvoySecretVolumeName,
+		MountPath: configpatch.IstioCredentialsDirectory,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	volumesAnnotation, err := json.Marshal(append(mergedVolumes, envoySecretVolume))
+	if err != nil {
+		return false, err
+	}
+	volumeMountsAnnotation, err := json.Marshal(append(mergedVolumeMounts, envoySecretVolumeMount))
+	if err != nil {
+		return false, err
+	}
+
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[IstioUserVolumeAnnotation] = string(volumesAnnotation)
+	pod.Annotations[istioUserVolumeMountAnnotation] = string(volumeMountsAnnotation)
+	return true, nil
+}
+
+// MutateIstioProxyContainer mounts the Secret generated for the AuthPolicy directly into the istio-proxy container of
+// the pod, if the sidecar has already been injected. Mutating webhooks run in order of name, so the Istio sidecar
+// injector renders istio-proxy before the annotations set by MutatePodAnnotations exist, and it is not reinvoked by
+// default. Returns whether the pod was changed.
+func MutateIstioProxyContainer(pod *corev1.Pod, authPolicy v1alpha1.AuthPolicy) bool {
+	if !authPolicy.UsesOAuthCredentials() {
+		return false
+	}
+	container := findIstioProxyContainer(pod)
+	if container == nil {
+		// The sidecar injector renders the volume from the annotations when it runs after the webhook
+		return false
+	}
+
+	envoySecretName := names.EnvoySecret(authPolicy.Name)
+	if mountsSecret(pod, container, envoySecretName) {
+		return false
+	}
+
+	// Replace any volume using the injected name, and any volume mount shadowing the credentials directory
+	pod.Spec.Volumes = slices.DeleteFunc(pod.Spec.Volumes, func(volume corev1.Volume) bool {
+		return volume.Name == EnvoySecretVolumeName
+	})
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: EnvoySecretVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: envoySecretName},
+		},
+	})
+	container.VolumeMounts = slices.DeleteFunc(container.VolumeMounts, func(volumeMount corev1.VolumeMount) bool {
+		return volumeMount.Name == EnvoySecretVolumeName ||
+			volumeMount.MountPath == configpatch.IstioCredentialsDirectory
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      EnvoySecretVolumeName,
+		MountPath: configpatch.IstioCredentialsDirectory,
+		ReadOnly:  true,
+	})
+	return true
+}
+
+// findIstioProxyContainer returns the istio-proxy container of the pod, or nil if the sidecar has not been injected.
+func findIstioProxyContainer(pod *corev1.Pod) *corev1.Container {
+	for i := range pod.Spec.InitContainers {
+		if pod.Spec.InitContainers[i].Name == IstioProxyContainerName {
+			return &pod.Spec.InitContainers[i]
+		}
+	}
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == IstioProxyContainerName {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	return nil
+}
+
+// mountsSecret reports whether the container mounts the Secret at the credentials directory.
+func mountsSecret(pod *corev1.Pod, container *corev1.Container, secretName string) bool {
+	for _, volumeMount := range container.VolumeMounts {
+		if volumeMount.MountPath != configpatch.IstioCredentialsDirectory {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Name == volumeMount.Name && volume.Secret != nil && volume.Secret.SecretName == secretName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unmarshalIstioUserAnnotation returns the raw entries of a JSON list annotation, so that fields unknown to ztoperator
+// are preserved when the annotation is written back.
+func unmarshalIstioUserAnnotation(podAnnotations map[string]string, annotation string) ([]json.RawMessage, error) {
+	value, ok := podAnnotations[annotation]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, invalidIstioUserAnnotationError(annotation)
+	}
+	return entries, nil
+}
+
+func invalidIstioUserAnnotationError(annotation string) error {
+	return fmt.Errorf(
+		"the annotation '%s' is not properly formatted, %s",
+		annotation,
+		PodAnnotationErrorMessageSuffix(),
+	)
+}
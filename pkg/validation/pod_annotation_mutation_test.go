@@ -0,0 +1,227 @@
+package validation_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kartverket/ztoperator/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMutatePodAnnotations_WithoutOAuthCredentials_DoesNotMutate(t *testing.T) {
+	// 1. Arrange
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, nil, time.Now())
+	authPolicy := BuildAuthPolicy(authPolicyName, false, map[string]string{"app": "test"}, nil)
+
+	// 2. Act
+	mutated, err := validation.MutatePodAnnotations(pod, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.False(t, mutated)
+	assert.Nil(t, pod.Annotations)
+}
+
+func TestMutatePodAnnotations_WithoutAnnotations_AddsVolumeAndMount(t *testing.T) {
+	// 1. Arrange
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, nil, time.Now())
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+
+	// 2. Act
+	mutated, err := validation.MutatePodAnnotations(pod, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.True(t, mutated)
+	assert.JSONEq(
+		t,
+		VolumeAnnotation(validation.EnvoySecretVolumeName, envoySecretName(authPolicyName)),
+		pod.Annotations["sidecar.istio.io/userVolume"],
+	)
+	assert.JSONEq(
+		t,
+		`[{"name":"ztoperator-envoy-secret","mountPath":"/etc/istio/config","readonly":true}]`,
+		pod.Annotations["sidecar.istio.io/userVolumeMount"],
+	)
+	assert.NoError(t, validation.ValidatePodAnnotations(pod, authPolicy))
+}
+
+func TestMutatePodAnnotations_PreservesExistingUserVolumes(t *testing.T) {
+	// 1. Arrange
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, map[string]string{
+		"sidecar.istio.io/userVolume":      `[{"name":"certs","configMap":{"name":"ca-bundle"}}]`,
+		"sidecar.istio.io/userVolumeMount": `[{"name":"certs","mountPath":"/etc/certs","readonly":true}]`,
+	}, time.Now())
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+
+	// 2. Act
+	mutated, err := validation.MutatePodAnnotations(pod, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.True(t, mutated)
+
+	var volumes []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(pod.Annotations["sidecar.istio.io/userVolume"]), &volumes))
+	require.Len(t, volumes, 2)
+	assert.Equal(t, map[string]any{"name": "certs", "configMap": map[string]any{"name": "ca-bundle"}}, volumes[0])
+	assert.Equal(t, validation.EnvoySecretVolumeName, volumes[1]["name"])
+
+	var volumeMounts []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(pod.Annotations["sidecar.istio.io/userVolumeMount"]), &volumeMounts))
+	require.Len(t, volumeMounts, 2)
+	assert.Equal(t, "/etc/certs", volumeMounts[0]["mountPath"])
+	assert.Equal(t, istioCredentialsDirectory, volumeMounts[1]["mountPath"])
+	assert.NoError(t, validation.ValidatePodAnnotations(pod, authPolicy))
+}
+
+func TestMutatePodAnnotations_WithSecretAlreadyMounted_DoesNotMutate(t *testing.T) {
+	// 1. Arrange
+	annotations := CorrectAnnotations("envoy-secret", envoySecretName(authPolicyName))
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, annotations, time.Now())
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+
+	// 2. Act
+	mutated, err := validation.MutatePodAnnotations(pod, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.False(t, mutated)
+	assert.Equal(t, CorrectAnnotations("envoy-secret", envoySecretName(authPolicyName)), pod.Annotations)
+}
+
+func TestMutatePodAnnotations_ReplacesMountOfOtherSecretInCredentialsDirectory(t *testing.T) {
+	// 1. Arrange
+	annotations := CorrectAnnotations("envoy-secret", envoySecretName("other-policy"))
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, annotations, time.Now())
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+
+	// 2. Act
+	mutated, err := validation.MutatePodAnnotations(pod, authPolicy)
+
+	// 3. Assert
+	require.NoError(t, err)
+	assert.True(t, mutated)
+
+	var volumeMounts []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(pod.Annotations["sidecar.istio.io/userVolumeMount"]), &volumeMounts))
+	require.Len(t, volumeMounts, 1, "Only one volume can be mounted in the credentials directory")
+	assert.Equal(t, validation.EnvoySecretVolumeName, volumeMounts[0]["name"])
+	assert.NoError(t, validation.ValidatePodAnnotations(pod, authPolicy))
+}
+
+func TestMutatePodAnnotations_WithMalformedAnnotation_ReturnsError(t *testing.T) {
+	// 1. Arrange
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, map[string]string{
+		"sidecar.istio.io/userVolume": "not-valid-json",
+	}, time.Now())
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+
+	// 2. Act
+	mutated, err := validation.MutatePodAnnotations(pod, authPolicy)
+
+	// 3. Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sidecar.istio.io/userVolume")
+	assert.False(t, mutated)
+	assert.Equal(t, "not-valid-json", pod.Annotations["sidecar.istio.io/userVolume"])
+}
+
+func TestMutateIstioProxyContainer_WithoutIstioProxy_DoesNotMutate(t *testing.T) {
+	// 1. Arrange
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, nil, time.Now())
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+
+	// 2. Act
+	mutated := validation.MutateIstioProxyContainer(pod, authPolicy)
+
+	// 3. Assert
+	assert.False(t, mutated)
+	assert.Empty(t, pod.Spec.Volumes)
+}
+
+func TestMutateIstioProxyContainer_WithoutOAuthCredentials_DoesNotMutate(t *testing.T) {
+	// 1. Arrange
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, nil, time.Now())
+	pod.Spec.Containers = []corev1.Container{{Name: validation.IstioProxyContainerName}}
+	authPolicy := BuildAuthPolicy(authPolicyName, false, map[string]string{"app": "test"}, nil)
+
+	// 2. Act
+	mutated := validation.MutateIstioProxyContainer(pod, authPolicy)
+
+	// 3. Assert
+	assert.False(t, mutated)
+	assert.Empty(t, pod.Spec.Containers[0].VolumeMounts)
+}
+
+func TestMutateIstioProxyContainer_WithInjectedSidecar_MountsSecret(t *testing.T) {
+	// 1. Arrange
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, nil, time.Now())
+	pod.Spec.Containers = []corev1.Container{{Name: "app"}}
+	pod.Spec.InitContainers = []corev1.Container{{
+		Name:         validation.IstioProxyContainerName,
+		VolumeMounts: []corev1.VolumeMount{{Name: "istio-envoy", MountPath: "/etc/istio/proxy"}},
+	}}
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+	require.Error(t, validation.ValidatePodAnnotations(pod, authPolicy))
+
+	// 2. Act
+	mutated := validation.MutateIstioProxyContainer(pod, authPolicy)
+
+	// 3. Assert
+	assert.True(t, mutated)
+	assert.Equal(t, []corev1.Volume{{
+		Name: validation.EnvoySecretVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: envoySecretName(authPolicyName)},
+		},
+	}}, pod.Spec.Volumes)
+	assert.Equal(t, []corev1.VolumeMount{
+		{Name: "istio-envoy", MountPath: "/etc/istio/proxy"},
+		{Name: validation.EnvoySecretVolumeName, MountPath: istioCredentialsDirectory, ReadOnly: true},
+	}, pod.Spec.InitContainers[0].VolumeMounts)
+	assert.Empty(t, pod.Spec.Containers[0].VolumeMounts)
+	assert.NoError(t, validation.ValidatePodAnnotations(pod, authPolicy))
+}
+
+func TestMutateIstioProxyContainer_ReplacesMountOfOtherSecretInCredentialsDirectory(t *testing.T) {
+	// 1. Arrange
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, nil, time.Now())
+	pod.Spec.Volumes = []corev1.Volume{{
+		Name:         "other",
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "other-secret"}},
+	}}
+	pod.Spec.Containers = []corev1.Container{{
+		Name:         validation.IstioProxyContainerName,
+		VolumeMounts: []corev1.VolumeMount{{Name: "other", MountPath: istioCredentialsDirectory}},
+	}}
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+
+	// 2. Act
+	mutated := validation.MutateIstioProxyContainer(pod, authPolicy)
+
+	// 3. Assert
+	assert.True(t, mutated)
+	require.Len(t, pod.Spec.Containers[0].VolumeMounts, 1)
+	assert.Equal(t, validation.EnvoySecretVolumeName, pod.Spec.Containers[0].VolumeMounts[0].Name)
+	assert.NoError(t, validation.ValidatePodAnnotations(pod, authPolicy))
+}
+
+func TestMutateIstioProxyContainer_WithSecretAlreadyMounted_DoesNotMutate(t *testing.T) {
+	// 1. Arrange
+	pod := MakePod("test-pod", map[string]string{"app": "test"}, nil, time.Now())
+	pod.Spec.Containers = []corev1.Container{{Name: validation.IstioProxyContainerName}}
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+	require.True(t, validation.MutateIstioProxyContainer(pod, authPolicy))
+
+	// 2. Act
+	mutated := validation.MutateIstioProxyContainer(pod, authPolicy)
+
+	// 3. Assert
+	assert.False(t, mutated)
+	assert.Len(t, pod.Spec.Volumes, 1)
+	assert.Len(t, pod.Spec.Containers[0].VolumeMounts, 1)
+}
@@ -36,6 +36,19 @@ func ValidatePodAnnotations(pod *corev1.Pod, authPolicy v1alpha1.AuthPolicy) err
 		return nil
 	}
 
+	envoySecretName := names.EnvoySecret(authPolicy.Name)
+	if container := findIstioProxyContainer(pod); container != nil {
+		// The annotations only take effect if the sidecar injector reads them, so check the injected sidecar itself
+		if !mountsSecret(pod, container, envoySecretName) {
+			return fmt.Errorf(
+				"secret with name '%s' used by OAuth-EnvoyFilter is not mounted in istio-proxy, %s",
+				envoySecretName,
+				PodAnnotationErrorMessageSuffix(),
+			)
+		}
+		return nil
+	}
+
 	volumes, volumeMounts, collectIstioMountsErr := collectIstioVolumesAndMountsFromPod(pod.Annotations)
 	if collectIstioMountsErr != nil {
 		return collectIstioMountsErr
@@ -47,7 +60,6 @@ func ValidatePodAnnotations(pod *corev1.Pod, authPolicy v1alpha1.AuthPolicy) err
 		}
 	}
 
-	envoySecretName := names.EnvoySecret(authPolicy.Name)
 	if len(envoySecretVolumeMounts) == 0 || !validateSecretVolumeMount(
 		envoySecretVolumeMounts,
 		volumes,
@@ -166,7 +166,7 @@ func BuildAuthPolicy(
 			Namespace: "default",
 		},
 		Spec: ztoperatorv1alpha1.AuthPolicySpec{
-			Selector: ztoperatorv1alpha1.WorkloadSelector{
+			Selector: &ztoperatorv1alpha1.WorkloadSelector{
 				MatchLabels: matchLabels,
 			},
 		},
@@ -193,3 +193,22 @@ func MakePod(name string, labels map[string]string, annotations map[string]strin
 		},
 	}
 }
+
+func TestValidatePodAnnotations_IstioProxyWithoutSecret_ReturnsError(t *testing.T) {
+	pod := MakePod(
+		"test-pod",
+		map[string]string{"app": "test"},
+		CorrectAnnotations(validation.EnvoySecretVolumeName, envoySecretName(authPolicyName)),
+		time.Now(),
+	)
+	pod.Spec.Containers = []corev1.Container{{Name: validation.IstioProxyContainerName}}
+
+	authPolicy := BuildAuthPolicy(authPolicyName, true, map[string]string{"app": "test"}, nil)
+
+	err := validation.ValidatePodAnnotations(pod, authPolicy)
+	if err == nil {
+		t.Error("expected error when istio-proxy does not mount the secret, got nil")
+	} else if !strings.Contains(err.Error(), "is not mounted in istio-proxy") {
+		t.Errorf("expected error to mention 'is not mounted in istio-proxy', got: %v", err)
+	}
+}
@@ -61,6 +61,7 @@ EOF
 )"
 
 "${KUBECTL_BIN}" apply -f <(echo "$DEPLOYMENT") --context "$KUBECONTEXT"
+"${KUBECTL_BIN}" apply -f "${STATIC_DISCOVERY_DOCUMENTS:-scripts/static-discovery-documents.yaml}" --context "$KUBECONTEXT"
 
 while true; do
   SUMMARY_STATUS=$("${KUBECTL_BIN}" get application.skiperator.kartverket.no/mock-oauth2 -n auth -o jsonpath='{.status.summary.status}')